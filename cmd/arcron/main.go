@@ -0,0 +1,390 @@
+// Command arcron is the intelligent cron scheduler. By default it serves
+// the API and schedules the configured jobs; its subcommands run jobs in
+// the foreground, check or print the configuration, export execution
+// history, install the Windows
+// service and import Task Scheduler tasks.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/makalin/arcron/internal/alerts"
+	"github.com/makalin/arcron/internal/api"
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/export"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/logging"
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/monitoring"
+	"github.com/makalin/arcron/internal/runner"
+	"github.com/makalin/arcron/internal/scheduler"
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/taskscheduler"
+	"github.com/makalin/arcron/internal/version"
+	"github.com/makalin/arcron/internal/winsvc"
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// newRootCommand creates the arcron command with its subcommands. The
+// configuration flags, e.g. --server.port, apply to all of them.
+func newRootCommand() *cobra.Command {
+	var configPath string
+	root := &cobra.Command{
+		Use:          "arcron",
+		Short:        "Intelligent cron scheduler",
+		Version:      version.Version,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadWithFlags(configPath, cmd.Flags())
+			if err != nil {
+				return err
+			}
+			isService, err := winsvc.IsService()
+			if err != nil {
+				return err
+			}
+			if isService {
+				return winsvc.Run(winsvc.DefaultName, func(ctx context.Context) error {
+					return serve(ctx, cfg)
+				})
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return serve(ctx, cfg)
+		},
+	}
+	root.PersistentFlags().StringVarP(&configPath, "config", "c", "", "configuration file (default: environment only)")
+	config.RegisterFlags(root.PersistentFlags())
+
+	root.AddCommand(
+		newRunCommand(&configPath),
+		newValidateCommand(&configPath),
+		newConfigCommand(&configPath),
+		newExportCommand(&configPath),
+		newServiceCommand(&configPath),
+		newImportTasksCommand(),
+	)
+	return root
+}
+
+// serve runs the API server with the scheduler, monitor and ML engine
+// until ctx is done
+func serve(ctx context.Context, cfg *config.Config) error {
+	store, err := storage.New(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %v", err)
+	}
+	jobManager, err := jobs.New(cfg.Jobs, cfg.Security, store)
+	if err != nil {
+		return err
+	}
+	defer jobManager.Stop()
+	monitor, err := monitoring.New(cfg)
+	if err != nil {
+		return err
+	}
+	mlEngine, err := ml.New(cfg.ML)
+	if err != nil {
+		return err
+	}
+	sched, err := scheduler.New(cfg, jobManager, mlEngine, monitor)
+	if err != nil {
+		return err
+	}
+	var alertManager *alerts.Manager
+	if cfg.Alerts.Enabled {
+		if alertManager, err = alerts.New(cfg); err != nil {
+			return err
+		}
+	}
+	server, err := api.New(cfg, store, jobManager, sched, monitor, mlEngine, alertManager)
+	if err != nil {
+		return err
+	}
+
+	if err := monitor.Start(ctx); err != nil {
+		return err
+	}
+	defer monitor.Stop()
+	if err := mlEngine.Start(ctx); err != nil {
+		return err
+	}
+	defer mlEngine.Stop()
+	if err := sched.Start(ctx); err != nil {
+		return err
+	}
+	defer sched.Stop()
+
+	return server.Start(ctx)
+}
+
+// newRunCommand creates the command running jobs in the foreground
+func newRunCommand(configPath *string) *cobra.Command {
+	var opts runner.Options
+	cmd := &cobra.Command{
+		Use:   "run [job...]",
+		Short: "Run jobs in the foreground without the API server",
+		Long: `Run jobs in the foreground without the API, WebSocket or metrics servers,
+e.g. in containers and CI. With --once the due jobs run and arcron exits,
+non-zero if any failed; named jobs run whether due or not. Without --once
+the jobs are scheduled until arcron is stopped.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadWithFlags(*configPath, cmd.Flags())
+			if err != nil {
+				return err
+			}
+			opts.Jobs = args
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			_, err = runner.Run(ctx, cfg, opts)
+			return err
+		},
+	}
+	cmd.Flags().BoolVar(&opts.Once, "once", false, "run the due jobs and exit")
+	cmd.Flags().DurationVar(&opts.Window, "window", runner.DefaultWindow, "how far back a run still counts as due with --once")
+	return cmd
+}
+
+// newValidateCommand creates the command checking a configuration file
+func newValidateCommand(configPath *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Check a configuration file without starting arcron",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if *configPath == "" {
+				return fmt.Errorf("no configuration file given, use --config")
+			}
+			if err := config.Validate(*configPath, scheduler.CheckSchedules, storage.CheckConnection, logging.CheckConfig); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s is valid\n", *configPath)
+			return nil
+		},
+	}
+}
+
+// newConfigCommand creates the command inspecting the configuration
+func newConfigCommand(configPath *string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+
+	var format string
+	dump := &cobra.Command{
+		Use:   "dump",
+		Short: "Print the effective configuration with secrets redacted",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.LoadWithFlags(*configPath, cmd.Flags())
+			if err != nil {
+				return err
+			}
+			data, err := config.Dump(cfg, format)
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		},
+	}
+	dump.Flags().StringVar(&format, "format", config.FormatYAML, "output format: yaml, json or toml")
+	cmd.AddCommand(dump)
+	return cmd
+}
+
+// newExportCommand creates the command exporting the execution history of
+// a job
+func newExportCommand(configPath *string) *cobra.Command {
+	var jobName, format, since, until, out string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the execution history of a job as CSV or JSON",
+		Long: `Export the execution history of a job, oldest first, with the duration,
+status and resource usage of each run, as CSV or JSON. --since and --until
+limit it to the runs started in that range (RFC 3339 times or dates).`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if jobName == "" {
+				return fmt.Errorf("no job given, use --job")
+			}
+			if err := export.CheckFormat(format); err != nil {
+				return err
+			}
+			sinceTime, err := parseExportTime("since", since)
+			if err != nil {
+				return err
+			}
+			untilTime, err := parseExportTime("until", until)
+			if err != nil {
+				return err
+			}
+
+			cfg, err := config.LoadWithFlags(*configPath, cmd.Flags())
+			if err != nil {
+				return err
+			}
+			store, err := storage.New(cfg.Database)
+			if err != nil {
+				return fmt.Errorf("failed to open storage: %v", err)
+			}
+			defer store.Close()
+
+			if out == "" {
+				return export.ExecutionsBetween(cmd.OutOrStdout(), format, jobName, sinceTime, untilTime, store, store)
+			}
+			file, err := os.Create(out)
+			if err != nil {
+				return fmt.Errorf("failed to create export file: %v", err)
+			}
+			if err := export.ExecutionsBetween(file, format, jobName, sinceTime, untilTime, store, store); err != nil {
+				file.Close()
+				return err
+			}
+			return file.Close()
+		},
+	}
+	cmd.Flags().StringVar(&jobName, "job", "", "job to export")
+	cmd.Flags().StringVar(&format, "format", export.FormatCSV, "output format: csv or json")
+	cmd.Flags().StringVar(&since, "since", "", "export runs started at or after this time")
+	cmd.Flags().StringVar(&until, "until", "", "export runs started at or before this time")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "file to write (default: standard output)")
+	return cmd
+}
+
+// parseExportTime parses an RFC 3339 time or a date; empty leaves the
+// range open
+func parseExportTime(flag, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --%s time %q, expected RFC 3339 or YYYY-MM-DD", flag, value)
+	}
+	return t, nil
+}
+
+// newServiceCommand creates the commands installing and removing the
+// Windows service
+func newServiceCommand(configPath *string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "service",
+		Short: "Install or remove the Windows service",
+	}
+
+	var name string
+	install := &cobra.Command{
+		Use:   "install",
+		Short: "Register arcron with the service control manager",
+		Long: `Register arcron with the service control manager, started automatically and
+restarted when it fails. The service runs this executable with the given
+--config file.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			executable, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to find the arcron executable: %v", err)
+			}
+			var serviceArgs []string
+			if *configPath != "" {
+				path, err := filepath.Abs(*configPath)
+				if err != nil {
+					return err
+				}
+				serviceArgs = []string{"--config", path}
+			}
+			err = winsvc.Install(winsvc.Config{
+				Name:        name,
+				DisplayName: "Arcron",
+				Description: "Intelligent cron scheduler",
+				Executable:  executable,
+				Args:        serviceArgs,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "service %s installed\n", name)
+			return nil
+		},
+	}
+	install.Flags().StringVar(&name, "name", winsvc.DefaultName, "service name")
+
+	uninstall := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the service from the service control manager",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := winsvc.Uninstall(name); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "service %s removed\n", name)
+			return nil
+		},
+	}
+	uninstall.Flags().StringVar(&name, "name", winsvc.DefaultName, "service name")
+
+	cmd.AddCommand(install, uninstall)
+	return cmd
+}
+
+// newImportTasksCommand creates the command converting Task Scheduler
+// task definitions into a jobs file
+func newImportTasksCommand() *cobra.Command {
+	var out string
+	cmd := &cobra.Command{
+		Use:   "import-tasks <path>...",
+		Short: "Convert Windows Task Scheduler tasks into a jobs file",
+		Long: `Convert exported Task Scheduler task definitions (schtasks /query /xml), or
+directories of them such as C:\Windows\System32\Tasks, into a YAML jobs file
+for the jobs directory. Settings that cannot be carried over are reported as
+warnings.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := taskscheduler.ImportFiles(args...)
+			if err != nil {
+				return err
+			}
+			for _, warning := range result.Warnings {
+				fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s\n", warning)
+			}
+
+			if out == "" {
+				return result.WriteJobsFile(cmd.OutOrStdout())
+			}
+			file, err := os.Create(out)
+			if err != nil {
+				return fmt.Errorf("failed to create jobs file: %v", err)
+			}
+			if err := result.WriteJobsFile(file); err != nil {
+				file.Close()
+				return err
+			}
+			if err := file.Close(); err != nil {
+				return fmt.Errorf("failed to write jobs file: %v", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "imported %d jobs into %s\n", len(result.Jobs), out)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&out, "out", "o", "", "jobs file to write (default: standard output)")
+	return cmd
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/export"
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// writeConfig writes a configuration file on a temporary SQLite database
+// with the given jobs section
+func writeConfig(t *testing.T, jobs string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	content := `database:
+  driver: sqlite
+  dsn: ` + filepath.Join(dir, "arcron.db") + `
+advanced:
+  dashboard_auth:
+    enabled: true
+    username: admin
+    password: s3cret
+` + jobs
+	path := filepath.Join(dir, "arcron.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// execute runs arcron with args and returns its output
+func execute(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	cmd := newRootCommand()
+	cmd.SetArgs(args)
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestValidateCommand(t *testing.T) {
+	valid := writeConfig(t, `jobs:
+  - name: backup
+    schedule: "0 0 2 * * *"
+    command: "true"
+`)
+	if output, err := execute(t, "validate", "--config", valid); err != nil || !strings.Contains(output, "is valid") {
+		t.Errorf("validate = %q, %v, want the file reported valid", output, err)
+	}
+
+	invalid := writeConfig(t, `jobs:
+  - name: backup
+    schedule: "every night"
+    command: "true"
+`)
+	if _, err := execute(t, "validate", "--config", invalid); err == nil || !strings.Contains(err.Error(), "backup") {
+		t.Errorf("validate error = %v, want the invalid schedule reported", err)
+	}
+	if _, err := execute(t, "validate"); err == nil {
+		t.Error("validate without a configuration file succeeded")
+	}
+}
+
+func TestValidateShippedConfigs(t *testing.T) {
+	// The default written when no configuration file exists
+	path := filepath.Join(t.TempDir(), "arcron.yaml")
+	if _, err := config.Load(path); err != nil {
+		t.Fatalf("Load() creating the default error = %v", err)
+	}
+	for _, path := range []string{path, filepath.Join("..", "..", "config", "arcron.yaml")} {
+		if _, err := execute(t, "validate", "--config", path); err != nil {
+			t.Errorf("validate %s error = %v", path, err)
+		}
+	}
+}
+
+func TestConfigDumpCommand(t *testing.T) {
+	path := writeConfig(t, "")
+
+	output, err := execute(t, "config", "dump", "--config", path, "--server.port=9090", "--format", "json")
+	if err != nil {
+		t.Fatalf("config dump error = %v", err)
+	}
+	if strings.Contains(output, "s3cret") {
+		t.Errorf("config dump printed the password:\n%s", output)
+	}
+	if !strings.Contains(output, `"port": 9090`) {
+		t.Errorf("config dump = %s, want the flag override", output)
+	}
+}
+
+func TestRunOnceCommand(t *testing.T) {
+	path := writeConfig(t, `jobs:
+  - name: startup
+    schedule: "@reboot"
+    command: "true"
+  - name: broken
+    schedule: "0 0 2 * * *"
+    command: "false"
+`)
+
+	if _, err := execute(t, "run", "--config", path, "--once"); err != nil {
+		t.Errorf("run --once error = %v, want only the due job run", err)
+	}
+	if _, err := execute(t, "run", "--config", path, "--once", "broken"); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("run --once broken error = %v, want the failed job named", err)
+	}
+}
+
+func TestImportTasksCommand(t *testing.T) {
+	dir := t.TempDir()
+	task := `<?xml version="1.0" encoding="UTF-8"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo><URI>\Nightly</URI></RegistrationInfo>
+  <Triggers>
+    <CalendarTrigger>
+      <StartBoundary>2024-01-01T02:30:00</StartBoundary>
+      <ScheduleByDay><DaysInterval>1</DaysInterval></ScheduleByDay>
+    </CalendarTrigger>
+    <LogonTrigger />
+  </Triggers>
+  <Actions><Exec><Command>backup.exe</Command></Exec></Actions>
+</Task>`
+	if err := os.WriteFile(filepath.Join(dir, "Nightly.xml"), []byte(task), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := execute(t, "import-tasks", dir)
+	if err != nil {
+		t.Fatalf("import-tasks error = %v", err)
+	}
+	if !strings.Contains(output, "name: nightly") || !strings.Contains(output, "warning:") {
+		t.Errorf("import-tasks = %s, want the job and the logon warning", output)
+	}
+
+	out := filepath.Join(t.TempDir(), "imported.yaml")
+	if _, err := execute(t, "import-tasks", filepath.Join(dir, "Nightly.xml"), "--out", out); err != nil {
+		t.Fatalf("import-tasks --out error = %v", err)
+	}
+	jobs, err := config.LoadJobsDir(filepath.Dir(out), nil, true)
+	if err != nil || len(jobs) != 1 || jobs[0].Command != "backup.exe" {
+		t.Errorf("imported jobs = %+v, %v, want the task's job", jobs, err)
+	}
+
+	if _, err := execute(t, "import-tasks"); err == nil {
+		t.Error("import-tasks without a path succeeded")
+	}
+}
+
+func TestExportCommand(t *testing.T) {
+	path := writeConfig(t, "")
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := storage.New(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC)
+	for i, id := range []string{"first", "second"} {
+		execution := &types.JobExecution{ID: id, JobName: "backup", Status: types.StatusCompleted, Attempt: 1,
+			StartTime: start.AddDate(0, 0, i), EndTime: start.AddDate(0, 0, i).Add(time.Minute), Duration: 60}
+		if err := store.StoreJobExecution(execution); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	output, err := execute(t, "export", "--config", path, "--job", "backup")
+	if err != nil {
+		t.Fatalf("export error = %v", err)
+	}
+	if !strings.HasPrefix(output, "id,job_name") || !strings.Contains(output, "first") || !strings.Contains(output, "second") {
+		t.Errorf("export = %s, want both executions as CSV", output)
+	}
+
+	output, err = execute(t, "export", "--config", path, "--job", "backup", "--format", "json", "--since", "2024-06-02")
+	if err != nil {
+		t.Fatalf("export --since error = %v", err)
+	}
+	var rows []export.ExecutionRow
+	if err := json.Unmarshal([]byte(output), &rows); err != nil || len(rows) != 1 || rows[0].ID != "second" {
+		t.Errorf("export --since = %s, want only the second execution", output)
+	}
+
+	if _, err := execute(t, "export", "--config", path); err == nil {
+		t.Error("export without a job succeeded")
+	}
+	if _, err := execute(t, "export", "--config", path, "--job", "backup", "--format", "parquet"); err == nil {
+		t.Error("export as parquet succeeded")
+	}
+	if _, err := execute(t, "export", "--config", path, "--job", "backup", "--until", "yesterday"); err == nil {
+		t.Error("export with an invalid --until succeeded")
+	}
+}
@@ -0,0 +1,28 @@
+package alerts
+
+import (
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// acknowledgedFailure returns the acknowledged failure alert of the job
+// of a failure alert, if its failure is not resolved yet. Until the job
+// recovers, its further failures are recorded without notifying anyone
+// again.
+func (m *Manager) acknowledgedFailure(event string, alert Alert) *types.AlertEntry {
+	if event != EventFailure || alert.JobName == "" || m.store == nil {
+		return nil
+	}
+
+	failures, err := m.store.GetUnresolvedAlerts(alert.JobName, EventFailure)
+	if err != nil {
+		logrus.Errorf("Failed to look up acknowledged alerts: %v", err)
+		return nil
+	}
+	for _, failure := range failures {
+		if failure.AcknowledgedAt != nil {
+			return failure
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// Teams message formats
+const (
+	teamsMessageCard  = "messagecard"
+	teamsAdaptiveCard = "adaptivecard"
+)
+
+// levelColor returns the RGB color of an alert level
+func levelColor(level string) int {
+	switch level {
+	case "error", "critical":
+		return 0xff0000 // Red
+	case "warning":
+		return 0xffaa00 // Orange
+	default:
+		return 0x36a64f // Green
+	}
+}
+
+// alertFacts lists the details shown with an alert
+func alertFacts(alert Alert) [][2]string {
+	facts := [][2]string{
+		{"Level", alert.Level},
+		{"Time", alert.Timestamp.Format(time.RFC3339)},
+	}
+	if alert.JobName != "" {
+		facts = append(facts, [2]string{"Job", alert.JobName})
+	}
+	if alert.ExecutionID != "" {
+		facts = append(facts, [2]string{"Execution", alert.ExecutionID})
+	}
+	return facts
+}
+
+// postJSON posts a JSON payload to a chat service, which must answer with a
+// 2xx status
+func (m *Manager) postJSON(service, url string, payload interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s payload: %v", service, err)
+	}
+
+	resp, err := m.client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to send %s alert: %v", service, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d", service, resp.StatusCode)
+	}
+	return nil
+}
+
+// sendTeamsAlert sends a Microsoft Teams alert as a MessageCard, or as an
+// Adaptive Card for Workflows webhooks
+func (m *Manager) sendTeamsAlert(alert Alert) error {
+	teamsCfg := m.config.Alerts.Teams
+
+	if teamsCfg.WebhookURL == "" {
+		return fmt.Errorf("teams webhook URL not configured")
+	}
+
+	runURL := m.templateData(alert).RunURL
+	var payload map[string]interface{}
+
+	if teamsCfg.Format == teamsAdaptiveCard {
+		facts := []map[string]string{}
+		for _, fact := range alertFacts(alert) {
+			facts = append(facts, map[string]string{"title": fact[0], "value": fact[1]})
+		}
+		card := map[string]interface{}{
+			"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+			"type":    "AdaptiveCard",
+			"version": "1.4",
+			"body": []map[string]interface{}{
+				{"type": "TextBlock", "text": alert.Title, "weight": "Bolder", "size": "Medium", "wrap": true},
+				{"type": "TextBlock", "text": alert.Message, "wrap": true},
+				{"type": "FactSet", "facts": facts},
+			},
+		}
+		if runURL != "" {
+			card["actions"] = []map[string]string{{"type": "Action.OpenUrl", "title": "View run", "url": runURL}}
+		}
+		payload = map[string]interface{}{
+			"type": "message",
+			"attachments": []map[string]interface{}{
+				{"contentType": "application/vnd.microsoft.card.adaptive", "content": card},
+			},
+		}
+	} else {
+		facts := []map[string]string{}
+		for _, fact := range alertFacts(alert) {
+			facts = append(facts, map[string]string{"name": fact[0], "value": fact[1]})
+		}
+		payload = map[string]interface{}{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    alert.Title,
+			"themeColor": fmt.Sprintf("%06x", levelColor(alert.Level)),
+			"title":      alert.Title,
+			"sections": []map[string]interface{}{
+				{"text": alert.Message, "facts": facts},
+			},
+		}
+		if runURL != "" {
+			payload["potentialAction"] = []map[string]interface{}{
+				{"@type": "OpenUri", "name": "View run", "targets": []map[string]string{{"os": "default", "uri": runURL}}},
+			}
+		}
+	}
+
+	if err := m.postJSON("teams", teamsCfg.WebhookURL, payload); err != nil {
+		return err
+	}
+
+	alert.log().Infof("Teams alert sent: %s", alert.Title)
+	return nil
+}
+
+// sendDiscordAlert sends a Discord alert as an embed
+func (m *Manager) sendDiscordAlert(alert Alert) error {
+	discordCfg := m.config.Alerts.Discord
+
+	if discordCfg.WebhookURL == "" {
+		return fmt.Errorf("discord webhook URL not configured")
+	}
+
+	fields := []map[string]interface{}{}
+	for _, fact := range alertFacts(alert) {
+		fields = append(fields, map[string]interface{}{"name": fact[0], "value": fact[1], "inline": true})
+	}
+
+	embed := map[string]interface{}{
+		"title":       alert.Title,
+		"description": alert.Message,
+		"color":       levelColor(alert.Level),
+		"timestamp":   alert.Timestamp.Format(time.RFC3339),
+		"fields":      fields,
+	}
+	if runURL := m.templateData(alert).RunURL; runURL != "" {
+		embed["url"] = runURL
+	}
+
+	payload := map[string]interface{}{
+		"embeds": []map[string]interface{}{embed},
+	}
+	if discordCfg.Username != "" {
+		payload["username"] = discordCfg.Username
+	}
+
+	if err := m.postJSON("discord", discordCfg.WebhookURL, payload); err != nil {
+		return err
+	}
+
+	alert.log().Infof("Discord alert sent: %s", alert.Title)
+	return nil
+}
+
+// sendTelegramAlert sends a Telegram alert through the Bot API
+func (m *Manager) sendTelegramAlert(alert Alert) error {
+	telegramCfg := m.config.Alerts.Telegram
+
+	if telegramCfg.BotToken == "" || telegramCfg.ChatID == "" {
+		return fmt.Errorf("telegram bot token or chat ID not configured")
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "<b>%s</b>\n%s\n", html.EscapeString(alert.Title), html.EscapeString(alert.Message))
+	for _, fact := range alertFacts(alert) {
+		fmt.Fprintf(&text, "\n<b>%s:</b> %s", fact[0], html.EscapeString(fact[1]))
+	}
+	if runURL := m.templateData(alert).RunURL; runURL != "" {
+		fmt.Fprintf(&text, "\n<a href=\"%s\">View run</a>", html.EscapeString(runURL))
+	}
+
+	payload := map[string]interface{}{
+		"chat_id":                  telegramCfg.ChatID,
+		"text":                     text.String(),
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	}
+
+	url := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimSuffix(telegramCfg.APIURL, "/"), telegramCfg.BotToken)
+	if err := m.postJSON("telegram", url, payload); err != nil {
+		// The URL holds the bot token, keep it out of logs
+		return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), telegramCfg.BotToken, "<token>"))
+	}
+
+	alert.log().Infof("Telegram alert sent: %s", alert.Title)
+	return nil
+}
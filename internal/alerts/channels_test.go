@@ -0,0 +1,125 @@
+package alerts
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestChatChannels(t *testing.T) {
+	var mu sync.Mutex
+	payloads := make(map[string]map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		payloads[r.URL.Path] = payload
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	manager, err := New(&config.Config{
+		Server: config.ServerConfig{ExternalURL: "http://arcron:8080"},
+		Alerts: config.AlertsConfig{
+			Enabled:  true,
+			Teams:    config.TeamsConfig{Enabled: true, WebhookURL: server.URL + "/teams", Format: teamsAdaptiveCard},
+			Discord:  config.DiscordConfig{Enabled: true, WebhookURL: server.URL + "/discord", Username: "Arcron"},
+			Telegram: config.TelegramConfig{Enabled: true, BotToken: "123:abc", ChatID: "-42", APIURL: server.URL},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	err = manager.SendJobAlert(&types.JobExecution{ID: "run-1", JobName: "backup", Status: types.StatusFailed})
+	if err != nil {
+		t.Fatalf("SendJobAlert() error = %v", err)
+	}
+
+	teams := payloads["/teams"]
+	attachments, _ := teams["attachments"].([]interface{})
+	if teams["type"] != "message" || len(attachments) != 1 {
+		t.Errorf("Teams payload = %v, want a message with an Adaptive Card", teams)
+	}
+
+	discord := payloads["/discord"]
+	embeds, _ := discord["embeds"].([]interface{})
+	if discord["username"] != "Arcron" || len(embeds) != 1 {
+		t.Fatalf("Discord payload = %v, want one embed", discord)
+	}
+	embed := embeds[0].(map[string]interface{})
+	if embed["color"] != float64(0xff0000) || embed["url"] != "http://arcron:8080/api/v1/executions/run-1" {
+		t.Errorf("Discord embed = %v, want a red embed linking the run", embed)
+	}
+
+	telegram := payloads["/bot123:abc/sendMessage"]
+	text, _ := telegram["text"].(string)
+	if telegram["chat_id"] != "-42" || telegram["parse_mode"] != "HTML" || !strings.HasPrefix(text, "<b>Job Failed: backup</b>") {
+		t.Errorf("Telegram payload = %v", telegram)
+	}
+}
+
+func TestUnknownTeamsFormat(t *testing.T) {
+	_, err := New(&config.Config{Alerts: config.AlertsConfig{Teams: config.TeamsConfig{Format: "card"}}})
+	if err == nil {
+		t.Error("New() accepted an unknown Teams format")
+	}
+}
+
+func TestTwilioChannel(t *testing.T) {
+	var mu sync.Mutex
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		requests = append(requests, r)
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	manager, err := New(&config.Config{
+		Alerts: config.AlertsConfig{
+			Enabled: true,
+			Twilio: config.TwilioConfig{Enabled: true, AccountSID: "AC123", AuthToken: "secret", From: "+15550000",
+				To: []string{"+15551111"}, Mode: twilioBoth, MinSeverity: "critical", APIURL: server.URL},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Below the Twilio threshold, nothing is sent
+	if err := manager.SendJobAlert(&types.JobExecution{JobName: "backup", Status: types.StatusFailed}); err != nil {
+		t.Fatalf("SendJobAlert() error = %v", err)
+	}
+	if len(requests) != 0 {
+		t.Fatalf("Twilio requests = %d for an error alert, want none", len(requests))
+	}
+
+	if err := manager.SendSystemAlert("critical", "Backup failing", "3 failures in a row", nil); err != nil {
+		t.Fatalf("SendSystemAlert() error = %v", err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("Twilio requests = %d, want a text and a call", len(requests))
+	}
+
+	sms, call := requests[0], requests[1]
+	if user, password, _ := sms.BasicAuth(); user != "AC123" || password != "secret" {
+		t.Errorf("basic auth = %s:%s, want the account SID and auth token", user, password)
+	}
+	if sms.URL.Path != "/2010-04-01/Accounts/AC123/Messages.json" || sms.PostForm.Get("To") != "+15551111" ||
+		!strings.Contains(sms.PostForm.Get("Body"), "Backup failing") {
+		t.Errorf("SMS request = %s %v, want a text about the alert", sms.URL.Path, sms.PostForm)
+	}
+	if call.URL.Path != "/2010-04-01/Accounts/AC123/Calls.json" || !strings.HasPrefix(call.PostForm.Get("Twiml"), "<Response><Say>") {
+		t.Errorf("call request = %s %v, want a call reading the alert", call.URL.Path, call.PostForm)
+	}
+}
@@ -0,0 +1,189 @@
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/makalin/arcron/internal/telemetry"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	alertRetries = telemetry.NewCounter("arcron_alerts_retries_total",
+		"Number of alert delivery retries by channel", "channel")
+	alertsDeadLettered = telemetry.NewCounter("arcron_alerts_dead_lettered_total",
+		"Number of alert deliveries given up and kept as dead letters by channel", "channel")
+)
+
+// dispatch tracks the deliveries of an alert that failed their first
+// attempt, so its history entry is updated once all of them settled
+type dispatch struct {
+	alert        Alert
+	slackChannel string
+	historyID    uint
+
+	// Guarded by the manager's retryMu
+	delivered   []string
+	failures    []string
+	outstanding int
+}
+
+// delivery is the delivery of an alert to one channel
+type delivery struct {
+	dispatch *dispatch
+	channel  string
+	attempts int
+	err      error
+	timer    *time.Timer
+}
+
+// retryBackoff returns how long to wait after a number of failed attempts,
+// doubling from the initial backoff up to the maximum
+func (m *Manager) retryBackoff(attempts int) time.Duration {
+	retry := m.config.Alerts.Retry
+	backoff := retry.InitialBackoff
+	for i := 1; i < attempts && backoff < retry.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if retry.MaxBackoff > 0 && backoff > retry.MaxBackoff {
+		backoff = retry.MaxBackoff
+	}
+	return backoff
+}
+
+// retryLater schedules a failed delivery for another attempt, or gives it
+// up if it is out of attempts, the retry queue is full or the manager is
+// closed
+func (m *Manager) retryLater(f *delivery) {
+	retry := m.config.Alerts.Retry
+
+	m.retryMu.Lock()
+	if f.attempts >= retry.MaxAttempts || len(m.pending) >= retry.QueueSize || m.closed {
+		m.retryMu.Unlock()
+		m.giveUp(f)
+		return
+	}
+	m.pending[f] = true
+	f.timer = time.AfterFunc(m.retryBackoff(f.attempts), func() { m.retry(f) })
+	m.retryMu.Unlock()
+}
+
+// retry makes another attempt at a delivery
+func (m *Manager) retry(f *delivery) {
+	m.retryMu.Lock()
+	if !m.pending[f] {
+		m.retryMu.Unlock()
+		return // Given up on close
+	}
+	delete(m.pending, f)
+	m.retryMu.Unlock()
+
+	alertRetries.Inc(f.channel)
+	err := m.deliver(f.channel, f.dispatch.alert, f.dispatch.slackChannel)
+	recordDelivery(f.channel, err)
+	f.attempts++
+	if err == nil {
+		f.dispatch.alert.log().Infof("Delivered alert %q to %s after %d attempts", f.dispatch.alert.Title, f.channel, f.attempts)
+		m.settle(f, nil)
+		return
+	}
+
+	f.err = err
+	m.retryLater(f)
+}
+
+// giveUp keeps a delivery that cannot be retried as a dead letter
+func (m *Manager) giveUp(f *delivery) {
+	alertsDeadLettered.Inc(f.channel)
+	f.dispatch.alert.log().Errorf("Giving up delivering alert %q to %s after %d attempts: %v",
+		f.dispatch.alert.Title, f.channel, f.attempts, f.err)
+
+	if m.store != nil {
+		payload, err := json.Marshal(f.dispatch.alert)
+		if err == nil {
+			err = m.store.StoreDeadLetter(&types.DeadLetter{
+				AlertID:      f.dispatch.historyID,
+				Channel:      f.channel,
+				SlackChannel: f.dispatch.slackChannel,
+				Alert:        string(payload),
+				Attempts:     f.attempts,
+				LastError:    f.err.Error(),
+			})
+		}
+		if err != nil {
+			f.dispatch.alert.log().Errorf("Failed to store dead letter of alert %q: %v", f.dispatch.alert.Title, err)
+		}
+	}
+
+	m.settle(f, f.err)
+}
+
+// settle records the outcome of a retried delivery. Once every delivery of
+// the alert settled, its history entry gets the final status.
+func (m *Manager) settle(f *delivery, err error) {
+	d := f.dispatch
+
+	m.retryMu.Lock()
+	if err != nil {
+		d.failures = append(d.failures, fmt.Sprintf("%s: %v", f.channel, err))
+	} else {
+		d.delivered = append(d.delivered, f.channel)
+	}
+	d.outstanding--
+	done := d.outstanding == 0
+	entry := &types.AlertEntry{ID: d.historyID, Status: types.AlertStatusSent, Channels: append([]string(nil), d.delivered...)}
+	if len(d.failures) > 0 {
+		entry.Status = types.AlertStatusFailed
+		entry.Error = strings.Join(d.failures, "; ")
+	}
+	m.retryMu.Unlock()
+
+	if !done || m.store == nil || d.historyID == 0 {
+		return
+	}
+	if err := m.store.UpdateAlert(entry); err != nil {
+		logrus.Errorf("Failed to update alert %d: %v", d.historyID, err)
+	}
+}
+
+// Close stops retrying and keeps the deliveries still waiting for a retry
+// as dead letters, so they can be redriven after a restart
+func (m *Manager) Close() {
+	m.retryMu.Lock()
+	m.closed = true
+	var waiting []*delivery
+	for f := range m.pending {
+		f.timer.Stop()
+		waiting = append(waiting, f)
+	}
+	m.pending = make(map[*delivery]bool)
+	m.retryMu.Unlock()
+
+	for _, f := range waiting {
+		m.giveUp(f)
+	}
+}
+
+// Redrive makes another attempt at delivering a dead letter, updating its
+// attempts and last error, or the time it was redriven if it succeeded
+func (m *Manager) Redrive(letter *types.DeadLetter) error {
+	var alert Alert
+	if err := json.Unmarshal([]byte(letter.Alert), &alert); err != nil {
+		return fmt.Errorf("invalid dead letter alert: %v", err)
+	}
+
+	err := m.deliver(letter.Channel, alert, letter.SlackChannel)
+	recordDelivery(letter.Channel, err)
+	letter.Attempts++
+	if err != nil {
+		letter.LastError = err.Error()
+		return err
+	}
+
+	now := time.Now()
+	letter.RedrivenAt = &now
+	return nil
+}
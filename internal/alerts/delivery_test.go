@@ -0,0 +1,133 @@
+package alerts
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// failingWebhook fails the first requests it receives
+func failingWebhook(failures int32) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	return server, &requests
+}
+
+func retryingManager(t *testing.T, url string, retry config.AlertRetryConfig) (*Manager, *memoryStore) {
+	t.Helper()
+	manager, err := New(&config.Config{
+		Alerts: config.AlertsConfig{
+			Enabled: true,
+			Webhook: config.WebhookConfig{Enabled: true, URL: url, Method: http.MethodPost},
+			Retry:   retry,
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	store := &memoryStore{}
+	manager.SetStore(store)
+	return manager, store
+}
+
+// waitFor polls until a condition holds or a second passed
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAlertDeliveryRetries(t *testing.T) {
+	server, requests := failingWebhook(2)
+	defer server.Close()
+
+	manager, store := retryingManager(t, server.URL, config.AlertRetryConfig{
+		MaxAttempts: 5, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, QueueSize: 10,
+	})
+
+	if err := manager.SendSystemAlert("warning", "Disk filling up", "90% used", nil); err == nil {
+		t.Fatal("SendSystemAlert() error = nil, want the failed first attempt")
+	}
+	if entry := store.entry(1); entry.Status != types.AlertStatusRetrying {
+		t.Errorf("status after first attempt = %q, want %q", entry.Status, types.AlertStatusRetrying)
+	}
+
+	waitFor(t, "the retried delivery", func() bool { return store.entry(1).Status == types.AlertStatusSent })
+	if entry := store.entry(1); len(entry.Channels) != 1 || entry.Channels[0] != ChannelWebhook || entry.Error != "" {
+		t.Errorf("entry = %+v, want delivered to the webhook", entry)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("webhook requests = %d, want 3", got)
+	}
+	if letters := store.letters(); len(letters) != 0 {
+		t.Errorf("dead letters = %+v, want none", letters)
+	}
+}
+
+func TestAlertDeliveryDeadLetter(t *testing.T) {
+	server, requests := failingWebhook(1000)
+	defer server.Close()
+
+	manager, store := retryingManager(t, server.URL, config.AlertRetryConfig{
+		MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, QueueSize: 10,
+	})
+
+	manager.SendSystemAlert("error", "Database down", "", nil)
+
+	waitFor(t, "the dead letter", func() bool { return len(store.letters()) == 1 })
+	letter := store.letters()[0]
+	if letter.AlertID != 1 || letter.Channel != ChannelWebhook || letter.Attempts != 3 || letter.LastError == "" {
+		t.Errorf("dead letter = %+v, want the webhook delivery after 3 attempts", letter)
+	}
+	waitFor(t, "the failed status", func() bool { return store.entry(1).Status == types.AlertStatusFailed })
+	if got := requests.Load(); got != 3 {
+		t.Errorf("webhook requests = %d, want 3", got)
+	}
+
+	// Redriving makes one more attempt
+	if err := manager.Redrive(letter); err == nil || letter.Attempts != 4 || letter.RedrivenAt != nil {
+		t.Errorf("Redrive() = %v, letter %+v, want a failed fourth attempt", err, letter)
+	}
+}
+
+func TestCloseDeadLettersPendingDeliveries(t *testing.T) {
+	server, requests := failingWebhook(1)
+	defer server.Close()
+
+	manager, store := retryingManager(t, server.URL, config.AlertRetryConfig{
+		MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour, QueueSize: 10,
+	})
+
+	manager.SendSystemAlert("error", "Database down", "", nil)
+	manager.Close()
+
+	letters := store.letters()
+	if len(letters) != 1 || letters[0].Attempts != 1 {
+		t.Fatalf("dead letters = %+v, want the pending delivery", letters)
+	}
+	if entry := store.entry(1); entry.Status != types.AlertStatusFailed {
+		t.Errorf("status = %q, want %q", entry.Status, types.AlertStatusFailed)
+	}
+
+	// The webhook recovered, so redriving delivers the alert
+	if err := manager.Redrive(letters[0]); err != nil || letters[0].RedrivenAt == nil {
+		t.Errorf("Redrive() = %v, letter %+v, want it delivered", err, letters[0])
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("webhook requests = %d, want 2", got)
+	}
+}
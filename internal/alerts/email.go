@@ -0,0 +1,217 @@
+package alerts
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+// Email security modes
+const (
+	emailSecurityAuto     = "auto"
+	emailSecurityTLS      = "tls"
+	emailSecurityStartTLS = "starttls"
+	emailSecurityNone     = "none"
+)
+
+// sendEmailAlert sends an email alert to all recipients in a single
+// message. Without a username the server is used as an open relay.
+func (m *Manager) sendEmailAlert(alert Alert) error {
+	emailCfg := m.config.Alerts.Email
+
+	if emailCfg.SMTPHost == "" || emailCfg.From == "" || len(emailCfg.To) == 0 {
+		return fmt.Errorf("email configuration incomplete")
+	}
+
+	subject := fmt.Sprintf("[%s] %s", strings.ToUpper(alert.Level), alert.Title)
+	body := fmt.Sprintf(`
+Alert: %s
+Level: %s
+Time: %s
+Message: %s
+`, alert.Title, alert.Level, alert.Timestamp.Format(time.RFC3339), alert.Message)
+	var htmlBody string
+
+	var err error
+	if m.templates.emailSubject != nil {
+		if subject, err = m.render(m.templates.emailSubject, alert); err != nil {
+			return err
+		}
+	}
+	if m.templates.emailBody != nil {
+		if body, err = m.render(m.templates.emailBody, alert); err != nil {
+			return err
+		}
+	}
+	if m.templates.emailHTML != nil {
+		if htmlBody, err = m.renderHTML(m.templates.emailHTML, alert); err != nil {
+			return err
+		}
+	}
+
+	msg, err := buildEmail(emailCfg.From, emailCfg.To, subject, body, htmlBody, alert.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to build email: %v", err)
+	}
+	if err := m.deliverEmail(emailCfg, msg); err != nil {
+		alert.log().Errorf("Failed to send email to %s: %v", strings.Join(emailCfg.To, ", "), err)
+		return err
+	}
+
+	alert.log().Infof("Email alert sent: %s", alert.Title)
+	return nil
+}
+
+// buildEmail formats an email message, as multipart/alternative if it has
+// an HTML body
+func buildEmail(from string, to []string, subject, body, htmlBody string, date time.Time) ([]byte, error) {
+	var msg bytes.Buffer
+	// A header must stay on one line
+	subject = strings.Join(strings.Fields(subject), " ")
+
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+
+	if htmlBody == "" {
+		msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		msg.WriteString(body)
+		return msg.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", body},
+		{"text/html; charset=utf-8", htmlBody},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+// deliverEmail sends a message over SMTP with the configured security,
+// authenticating only if a username is set
+func (m *Manager) deliverEmail(cfg config.EmailConfig, msg []byte) error {
+	tlsConfig, err := emailTLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	security := cfg.Security
+	if security == "" || security == emailSecurityAuto {
+		security = emailSecurityAuto
+		if cfg.SMTPPort == 465 {
+			security = emailSecurityTLS
+		}
+	}
+
+	addr := net.JoinHostPort(cfg.SMTPHost, fmt.Sprint(cfg.SMTPPort))
+	dialer := &net.Dialer{Timeout: m.client.Timeout}
+	var conn net.Conn
+	switch security {
+	case emailSecurityTLS:
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	case emailSecurityAuto, emailSecurityStartTLS, emailSecurityNone:
+		conn, err = dialer.Dial("tcp", addr)
+	default:
+		return fmt.Errorf("unknown email security %q", cfg.Security)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(m.client.Timeout))
+
+	client, err := smtp.NewClient(conn, cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if security == emailSecurityAuto || security == emailSecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("STARTTLS failed: %v", err)
+			}
+		} else if security == emailSecurityStartTLS {
+			return fmt.Errorf("%s does not support STARTTLS", cfg.SMTPHost)
+		}
+	}
+
+	if cfg.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("%s does not support authentication", cfg.SMTPHost)
+		}
+		// PlainAuth refuses to send credentials unencrypted except to
+		// localhost
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.SMTPHost)); err != nil {
+			return fmt.Errorf("authentication failed: %v", err)
+		}
+	}
+
+	if err := client.Mail(cfg.From); err != nil {
+		return err
+	}
+	for _, to := range cfg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("recipient %s rejected: %v", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// emailTLSConfig returns the TLS configuration for the SMTP server
+func emailTLSConfig(cfg config.EmailConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.SMTPHost,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.ServerName != "" {
+		tlsConfig.ServerName = cfg.ServerName
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read email CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in email CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,137 @@
+package alerts
+
+import (
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// smtpMessage is a message received by fakeSMTP
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP runs a minimal SMTP relay without TLS or authentication that
+// accepts a single message
+func fakeSMTP(t *testing.T) (port int, received chan smtpMessage) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	received = make(chan smtpMessage, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ESMTP")
+
+		var msg smtpMessage
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch command {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "MAIL":
+				msg.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+				text.PrintfLine("250 OK")
+			case "RCPT":
+				msg.to = append(msg.to, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 Go ahead")
+				data, err := text.ReadDotLines()
+				if err != nil {
+					return
+				}
+				msg.data = strings.Join(data, "\n")
+				text.PrintfLine("250 OK")
+				received <- msg
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				return
+			default:
+				text.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+
+	_, portStr, _ := net.SplitHostPort(listener.Addr().String())
+	port, _ = strconv.Atoi(portStr)
+	return port, received
+}
+
+func TestEmailRelay(t *testing.T) {
+	port, received := fakeSMTP(t)
+
+	manager, err := New(&config.Config{Alerts: config.AlertsConfig{
+		Enabled: true,
+		Email: config.EmailConfig{
+			Enabled:  true,
+			SMTPHost: "127.0.0.1",
+			SMTPPort: port,
+			From:     "arcron@example.com",
+			To:       []string{"ops@example.com", "dba@example.com"},
+			Security: "auto",
+			HTMLBody: `<p>{{ .Title }} &mdash; {{ .Message }}</p>`,
+		},
+	}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	err = manager.SendJobAlert(&types.JobExecution{JobName: "backup<1>", Status: types.StatusFailed})
+	if err != nil {
+		t.Fatalf("SendJobAlert() error = %v", err)
+	}
+
+	msg := <-received
+	if msg.from != "arcron@example.com" || len(msg.to) != 2 {
+		t.Errorf("envelope from %s to %v, want both recipients in one message", msg.from, msg.to)
+	}
+	for _, want := range []string{
+		"To: ops@example.com, dba@example.com",
+		"Subject: [ERROR] Job Failed: backup<1>",
+		"Content-Type: multipart/alternative",
+		"Content-Type: text/plain; charset=utf-8",
+		"<p>Job Failed: backup&lt;1&gt; &mdash;",
+	} {
+		if !strings.Contains(msg.data, want) {
+			t.Errorf("message does not contain %q:\n%s", want, msg.data)
+		}
+	}
+}
+
+func TestEmailRequiresStartTLS(t *testing.T) {
+	port, _ := fakeSMTP(t)
+
+	manager, err := New(&config.Config{Alerts: config.AlertsConfig{
+		Enabled: true,
+		Email: config.EmailConfig{Enabled: true, SMTPHost: "127.0.0.1", SMTPPort: port,
+			From: "arcron@example.com", To: []string{"ops@example.com"}, Security: "starttls"},
+	}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	err = manager.sendEmailAlert(Alert{Level: "error", Title: "Job Failed: backup"})
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("sendEmailAlert() error = %v, want STARTTLS to be required", err)
+	}
+}
@@ -0,0 +1,32 @@
+package alerts
+
+import (
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/logging"
+	"github.com/makalin/arcron/internal/monitoring"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// Attach alerts on the executions finished by the job manager and on the
+// threshold crossings of the monitor
+func (m *Manager) Attach(jobManager *jobs.Manager, monitor *monitoring.Monitor) {
+	jobManager.AddListener(m.observeExecution)
+	monitor.SetAlertSender(m)
+}
+
+// observeExecution alerts on a finished execution. Failed attempts that
+// will be retried are left out; the last one alerts once the retries are
+// exhausted. Alerts are sent in the background so slow channels never hold
+// up the job.
+func (m *Manager) observeExecution(execution *types.JobExecution) {
+	job, _ := m.jobConfig(execution.JobName)
+	if execution.Status == types.StatusFailed && execution.Attempt <= job.Retries {
+		return
+	}
+
+	go func() {
+		if err := m.SendJobAlert(execution); err != nil {
+			logging.ForJob(execution.JobName, execution.CorrelationID).Errorf("Failed to send job alert: %v", err)
+		}
+	}()
+}
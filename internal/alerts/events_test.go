@@ -0,0 +1,42 @@
+package alerts
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestObserveExecutionWaitsForRetries(t *testing.T) {
+	recorder := &webhookRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	manager, err := New(&config.Config{
+		Alerts: config.AlertsConfig{
+			Enabled: true,
+			Webhook: config.WebhookConfig{Enabled: true, URL: server.URL, Method: http.MethodPost},
+		},
+		Jobs: []config.JobConfig{{Name: "backup", Retries: 2}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// The first two attempts are retried, the third exhausts the retries
+	for attempt := 1; attempt <= 3; attempt++ {
+		manager.observeExecution(&types.JobExecution{JobName: "backup", Status: types.StatusFailed, Attempt: attempt})
+	}
+	waitFor(t, "the failure alert", func() bool { return len(recorder.titles()) == 1 })
+
+	manager.observeExecution(&types.JobExecution{JobName: "backup", Status: types.StatusCompleted, Attempt: 1})
+	waitFor(t, "the completion alert", func() bool { return len(recorder.titles()) == 2 })
+
+	want := []string{"Job Failed After Retries: backup", "Job Completed: backup"}
+	if got := recorder.titles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("webhook alerts = %v, want %v", got, want)
+	}
+}
@@ -5,28 +5,77 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"net/smtp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/logging"
+	"github.com/makalin/arcron/internal/telemetry"
 	"github.com/makalin/arcron/internal/types"
 	"github.com/sirupsen/logrus"
 )
 
+var (
+	alertsSent = telemetry.NewCounter("arcron_alerts_sent_total",
+		"Number of alert deliveries by channel and result", "channel", "result")
+	alertsSilenced = telemetry.NewCounter("arcron_alerts_silenced_total",
+		"Number of alerts suppressed by a silence")
+)
+
 // Manager manages alerting
 type Manager struct {
-	config *config.Config
-	client *http.Client
+	config    *config.Config
+	client    *http.Client
+	routes    []route
+	store     Store
+	templates templates
+
+	// Deliveries waiting for a retry
+	retryMu sync.Mutex
+	pending map[*delivery]bool
+	closed  bool
+
+	// jobs returns the configured jobs as they change; nil for those of
+	// the configuration
+	jobs func() []config.JobConfig
 }
 
 // New creates a new alert manager
 func New(cfg *config.Config) (*Manager, error) {
+	if err := validatePolicies(cfg); err != nil {
+		return nil, err
+	}
+	routes, err := compileRoutes(cfg.Alerts.Routes)
+	if err != nil {
+		return nil, err
+	}
+	if format := cfg.Alerts.Teams.Format; format != "" && format != teamsMessageCard && format != teamsAdaptiveCard {
+		return nil, fmt.Errorf("unknown Teams format %q, use %s or %s", format, teamsMessageCard, teamsAdaptiveCard)
+	}
+	switch cfg.Alerts.Twilio.Mode {
+	case "", twilioSMS, twilioVoice, twilioBoth:
+	default:
+		return nil, fmt.Errorf("unknown Twilio mode %q, use %s, %s or %s", cfg.Alerts.Twilio.Mode, twilioSMS, twilioVoice, twilioBoth)
+	}
+	switch cfg.Alerts.Email.Security {
+	case "", emailSecurityAuto, emailSecurityTLS, emailSecurityStartTLS, emailSecurityNone:
+	default:
+		return nil, fmt.Errorf("unknown email security %q, use %s, %s, %s or %s", cfg.Alerts.Email.Security,
+			emailSecurityAuto, emailSecurityTLS, emailSecurityStartTLS, emailSecurityNone)
+	}
+	templates, err := parseTemplates(cfg.Alerts)
+	if err != nil {
+		return nil, err
+	}
 	return &Manager{
 		config: cfg,
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		routes:    routes,
+		templates: templates,
+		pending:   make(map[*delivery]bool),
 	}, nil
 }
 
@@ -37,40 +86,167 @@ type Alert struct {
 	Message     string    `json:"message"`
 	Timestamp   time.Time `json:"timestamp"`
 	JobName     string    `json:"job_name,omitempty"`
+	Namespace   string    `json:"namespace,omitempty"`
 	ExecutionID string    `json:"execution_id,omitempty"`
-	Metrics     interface{} `json:"metrics,omitempty"`
+	// CorrelationID is that of the execution's run, for finding its log
+	// lines
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// Labels are the labels of the alert's job
+	Labels  map[string]string `json:"labels,omitempty"`
+	Metrics interface{}       `json:"metrics,omitempty"`
+	// Event is the job event alerted on, empty for system alerts
+	Event string `json:"event,omitempty"`
+	// ResolvesID is the ID of the failure alert a recovered alert resolves
+	ResolvesID uint `json:"resolves_id,omitempty"`
+
+	// execution is the execution a job alert is about, for templates
+	execution *types.JobExecution
 }
 
-// SendJobAlert sends an alert for a job execution
+// log returns a log entry for an alert, carrying its job and correlation
+// ID if it is a job alert
+func (a Alert) log() *logrus.Entry {
+	if a.JobName == "" {
+		return logging.WithCorrelation(a.CorrelationID)
+	}
+	return logging.ForJob(a.JobName, a.CorrelationID)
+}
+
+// SendJobAlert sends an alert for a finished job execution if the job's
+// alerting policy asks for it, and an SLA alert if the run took longer
+// than the job's SLA
 func (m *Manager) SendJobAlert(execution *types.JobExecution) error {
 	if !m.config.Alerts.Enabled {
 		return nil
 	}
 
-	var level string
+	var event string
 	var title string
 
+	message := fmt.Sprintf("Job %s %s. Duration: %.2fs", execution.JobName, execution.Status, execution.Duration)
+
 	switch execution.Status {
 	case types.StatusFailed:
-		level = "error"
+		event = EventFailure
 		title = fmt.Sprintf("Job Failed: %s", execution.JobName)
+		if job, _ := m.jobConfig(execution.JobName); job.Retries > 0 && execution.Attempt > job.Retries {
+			title = fmt.Sprintf("Job Failed After Retries: %s", execution.JobName)
+			message = fmt.Sprintf("Job %s failed after %d attempts. Duration of the last: %.2fs", execution.JobName, execution.Attempt, execution.Duration)
+		}
 	case types.StatusCompleted:
-		level = "info"
+		event = EventSuccess
 		title = fmt.Sprintf("Job Completed: %s", execution.JobName)
 	default:
 		return nil // Don't alert for other statuses
 	}
 
 	alert := Alert{
-		Level:       level,
-		Title:       title,
-		Message:     fmt.Sprintf("Job %s %s. Duration: %.2fs", execution.JobName, execution.Status, execution.Duration),
+		Level:         eventLevels[event],
+		Title:         title,
+		Message:       message,
+		Timestamp:     time.Now(),
+		JobName:       execution.JobName,
+		Namespace:     m.jobNamespace(execution.JobName),
+		ExecutionID:   execution.ID,
+		CorrelationID: execution.CorrelationID,
+		Labels:        m.jobLabels(execution.JobName),
+		execution:     execution,
+	}
+
+	policy := m.PolicyFor(execution.JobName)
+	var errs []string
+	if err := m.sendPolicyAlert(policy, event, alert); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if event == EventSuccess {
+		if err := m.resolveFailures(policy, execution); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if policy.SLA > 0 && execution.Duration > policy.SLA.Seconds() {
+		alert.Level = eventLevels[EventSLA]
+		alert.Title = fmt.Sprintf("Job SLA Breached: %s", execution.JobName)
+		alert.Message = fmt.Sprintf("Job %s took %.2fs, longer than its SLA of %s", execution.JobName, execution.Duration, policy.SLA)
+		if err := m.sendPolicyAlert(policy, EventSLA, alert); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// SendMissedRunAlert sends an alert for a scheduled run of a job that did
+// not happen, if the job's alerting policy asks for it
+func (m *Manager) SendMissedRunAlert(jobName string, scheduled time.Time, reason string) error {
+	if !m.config.Alerts.Enabled {
+		return nil
+	}
+
+	alert := Alert{
+		Level:     eventLevels[EventMissed],
+		Title:     fmt.Sprintf("Job Run Missed: %s", jobName),
+		Message:   fmt.Sprintf("Run of job %s scheduled at %s was missed: %s", jobName, scheduled.Format(time.RFC3339), reason),
+		Timestamp: time.Now(),
+		JobName:   jobName,
+		Namespace: m.jobNamespace(jobName),
+		Labels:    m.jobLabels(jobName),
+	}
+
+	return m.sendPolicyAlert(m.PolicyFor(jobName), EventMissed, alert)
+}
+
+// SendRegressionAlert sends an alert for a job whose runs have become
+// significantly slower, if the job's alerting policy asks for it
+func (m *Manager) SendRegressionAlert(regression *types.DurationRegression) error {
+	if !m.config.Alerts.Enabled {
+		return nil
+	}
+
+	message := fmt.Sprintf("Job %s runs have slowed from %.2fs to %.2fs (+%.0f%%) over its last %d runs",
+		regression.JobName, regression.BaselineDuration, regression.RecentDuration, regression.IncreasePercent, regression.Runs)
+	if regression.RunsToTimeout > 0 {
+		message += fmt.Sprintf("; at this pace a run times out in about %d runs", regression.RunsToTimeout)
+	}
+	alert := Alert{
+		Level:       eventLevels[EventRegression],
+		Title:       fmt.Sprintf("Job Slowing Down: %s", regression.JobName),
+		Message:     message,
 		Timestamp:   time.Now(),
-		JobName:     execution.JobName,
-		ExecutionID: execution.ID,
+		JobName:     regression.JobName,
+		Namespace:   m.jobNamespace(regression.JobName),
+		ExecutionID: regression.ExecutionID,
+		Labels:      m.jobLabels(regression.JobName),
+		Metrics:     regression,
+	}
+
+	return m.sendPolicyAlert(m.PolicyFor(regression.JobName), EventRegression, alert)
+}
+
+// SendFlakyAlert sends an alert for a job whose flakiness score rose to
+// the threshold, if the job's alerting policy asks for it
+func (m *Manager) SendFlakyAlert(jobName string, flakiness *types.Flakiness) error {
+	if !m.config.Alerts.Enabled {
+		return nil
 	}
 
-	return m.sendAlert(alert)
+	message := fmt.Sprintf("Job %s has a flakiness score of %.2f over its last %d runs: %d passed only through a retry, %d outcome flips",
+		jobName, flakiness.Score, flakiness.Runs, flakiness.Recovered, flakiness.Flips)
+	alert := Alert{
+		Level:     eventLevels[EventFlaky],
+		Title:     fmt.Sprintf("Job Flaky: %s", jobName),
+		Message:   message,
+		Timestamp: time.Now(),
+		JobName:   jobName,
+		Namespace: m.jobNamespace(jobName),
+		Labels:    m.jobLabels(jobName),
+		Metrics:   flakiness,
+	}
+
+	return m.sendPolicyAlert(m.PolicyFor(jobName), EventFlaky, alert)
 }
 
 // SendSystemAlert sends a system-level alert
@@ -87,82 +263,159 @@ func (m *Manager) SendSystemAlert(level, title, message string, metrics interfac
 		Metrics:   metrics,
 	}
 
-	return m.sendAlert(alert)
+	return m.sendPolicyAlert(m.PolicyFor(""), "", alert)
 }
 
-// sendAlert sends an alert through all configured channels
-func (m *Manager) sendAlert(alert Alert) error {
+// sendPolicyAlert sends an alert for an event through the channels of a
+// policy, unless the policy filters it out. Alerts matching an active
+// silence, and repeated failures of a job whose failure alert is
+// acknowledged, are only recorded. Failed deliveries are retried in the
+// background; the returned error is that of the first attempts.
+func (m *Manager) sendPolicyAlert(policy Policy, event string, alert Alert) error {
+	if !policy.allows(event, alert.Level) {
+		return nil
+	}
+	alert.Event = event
+
+	if silence := m.silencedBy(alert); silence != nil {
+		alert.log().Infof("Alert %q silenced by silence %d", alert.Title, silence.ID)
+		alertsSilenced.Inc()
+		m.record(alert, types.AlertStatusSilenced, nil, silence.ID, nil)
+		return nil
+	}
+	if acknowledged := m.acknowledgedFailure(event, alert); acknowledged != nil {
+		alert.log().Infof("Alert %q suppressed, alert %d was acknowledged by %s", alert.Title, acknowledged.ID, acknowledged.AcknowledgedBy)
+		m.record(alert, types.AlertStatusSuppressed, nil, 0, nil)
+		return nil
+	}
+
+	return m.sendRouted(policy, alert, m.Route(alert))
+}
+
+// sendRouted sends an alert through the routed channels the policy sends
+// to and records it
+func (m *Manager) sendRouted(policy Policy, alert Alert, routed RouteResult) error {
+	d := &dispatch{alert: alert, slackChannel: routed.SlackChannel}
 	var errors []string
+	var failed []*delivery
 
-	// Send email alert
-	if m.config.Alerts.Email.Enabled {
-		if err := m.sendEmailAlert(alert); err != nil {
-			errors = append(errors, fmt.Sprintf("email: %v", err))
+	for _, channel := range routed.Channels {
+		if !policy.sendsTo(channel) {
+			continue
 		}
-	}
 
-	// Send Slack alert
-	if m.config.Alerts.Slack.Enabled {
-		if err := m.sendSlackAlert(alert); err != nil {
-			errors = append(errors, fmt.Sprintf("slack: %v", err))
+		err := m.deliver(channel, alert, routed.SlackChannel)
+		recordDelivery(channel, err)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", channel, err))
+			failed = append(failed, &delivery{dispatch: d, channel: channel, attempts: 1, err: err})
+		} else {
+			d.delivered = append(d.delivered, channel)
 		}
 	}
 
-	// Send webhook alert
-	if m.config.Alerts.Webhook.Enabled {
-		if err := m.sendWebhookAlert(alert); err != nil {
-			errors = append(errors, fmt.Sprintf("webhook: %v", err))
+	var err error
+	status := types.AlertStatusSent
+	if len(errors) > 0 {
+		err = fmt.Errorf("alert sending errors: %s", strings.Join(errors, "; "))
+		status = types.AlertStatusRetrying
+		if m.config.Alerts.Retry.MaxAttempts <= 1 {
+			status = types.AlertStatusFailed
 		}
 	}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("alert sending errors: %s", strings.Join(errors, "; "))
+	d.historyID = m.record(alert, status, d.delivered, 0, err)
+	d.outstanding = len(failed)
+	for _, f := range failed {
+		m.retryLater(f)
 	}
+	return err
+}
 
-	return nil
+// jobLabels returns the labels of a configured job
+func (m *Manager) jobLabels(jobName string) map[string]string {
+	job, _ := m.jobConfig(jobName)
+	return job.Labels
 }
 
-// sendEmailAlert sends an email alert
-func (m *Manager) sendEmailAlert(alert Alert) error {
-	emailCfg := m.config.Alerts.Email
+// jobNamespace returns the namespace of a job
+func (m *Manager) jobNamespace(jobName string) string {
+	job, _ := m.jobConfig(jobName)
+	return job.GetNamespace()
+}
 
-	if emailCfg.SMTPHost == "" || emailCfg.Username == "" {
-		return fmt.Errorf("email configuration incomplete")
+// deliver sends an alert through a channel, to a Slack channel picked by
+// a route if set
+func (m *Manager) deliver(channel string, alert Alert, slackChannel string) error {
+	switch channel {
+	case ChannelEmail:
+		return m.sendEmailAlert(alert)
+	case ChannelSlack:
+		return m.sendSlackAlert(alert, slackChannel)
+	case ChannelWebhook:
+		return m.sendWebhookAlert(alert)
+	case ChannelPagerDuty:
+		return m.sendPagerDutyAlert(alert)
+	case ChannelTeams:
+		return m.sendTeamsAlert(alert)
+	case ChannelDiscord:
+		return m.sendDiscordAlert(alert)
+	case ChannelTelegram:
+		return m.sendTelegramAlert(alert)
+	case ChannelTwilio:
+		return m.sendTwilioAlert(alert)
 	}
+	return fmt.Errorf("unknown alert channel %q", channel)
+}
 
-	auth := smtp.PlainAuth("", emailCfg.Username, emailCfg.Password, emailCfg.SMTPHost)
-
-	subject := fmt.Sprintf("[%s] %s", strings.ToUpper(alert.Level), alert.Title)
-	body := fmt.Sprintf(`
-Alert: %s
-Level: %s
-Time: %s
-Message: %s
-`, alert.Title, alert.Level, alert.Timestamp.Format(time.RFC3339), alert.Message)
-
-	msg := []byte(fmt.Sprintf("Subject: %s\r\n\r\n%s", subject, body))
-
-	addr := fmt.Sprintf("%s:%d", emailCfg.SMTPHost, emailCfg.SMTPPort)
-	
-	for _, to := range emailCfg.To {
-		if err := smtp.SendMail(addr, auth, emailCfg.From, []string{to}, msg); err != nil {
-			logrus.Errorf("Failed to send email to %s: %v", to, err)
-			return err
-		}
+// recordDelivery records the outcome of an alert delivery
+func recordDelivery(channel string, err error) {
+	if err != nil {
+		alertsSent.Inc(channel, "failure")
+	} else {
+		alertsSent.Inc(channel, "success")
 	}
-
-	logrus.Infof("Email alert sent: %s", alert.Title)
-	return nil
 }
 
-// sendSlackAlert sends a Slack alert
-func (m *Manager) sendSlackAlert(alert Alert) error {
+// sendSlackAlert sends a Slack alert, to the configured channel unless
+// a route picked another one
+func (m *Manager) sendSlackAlert(alert Alert, channel string) error {
 	slackCfg := m.config.Alerts.Slack
+	if channel == "" {
+		channel = slackCfg.Channel
+	}
 
 	if slackCfg.WebhookURL == "" {
 		return fmt.Errorf("slack webhook URL not configured")
 	}
 
+	var jsonData []byte
+	var err error
+	if m.templates.slack != nil {
+		jsonData, err = m.slackTemplatePayload(alert, channel)
+	} else {
+		jsonData, err = json.Marshal(slackPayload(alert, channel, slackCfg.Username))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to build Slack payload: %v", err)
+	}
+
+	resp, err := m.client.Post(slackCfg.WebhookURL, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to send Slack alert: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack webhook returned status %d", resp.StatusCode)
+	}
+
+	alert.log().Infof("Slack alert sent: %s", alert.Title)
+	return nil
+}
+
+// slackPayload builds the default Slack message of an alert
+func slackPayload(alert Alert, channel, username string) map[string]interface{} {
 	color := "#36a64f" // Green
 	if alert.Level == "error" || alert.Level == "critical" {
 		color = "#ff0000" // Red
@@ -171,8 +424,8 @@ func (m *Manager) sendSlackAlert(alert Alert) error {
 	}
 
 	payload := map[string]interface{}{
-		"channel":  slackCfg.Channel,
-		"username": slackCfg.Username,
+		"channel":  channel,
+		"username": username,
 		"attachments": []map[string]interface{}{
 			{
 				"color":     color,
@@ -206,23 +459,28 @@ func (m *Manager) sendSlackAlert(alert Alert) error {
 		)
 	}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal Slack payload: %v", err)
-	}
+	return payload
+}
 
-	resp, err := m.client.Post(slackCfg.WebhookURL, "application/json", bytes.NewBuffer(jsonData))
+// slackTemplatePayload renders the Slack template of an alert, adding the
+// channel and username unless the template sets them
+func (m *Manager) slackTemplatePayload(alert Alert, channel string) ([]byte, error) {
+	rendered, err := m.renderJSON(m.templates.slack, alert)
 	if err != nil {
-		return fmt.Errorf("failed to send Slack alert: %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("slack webhook returned status %d", resp.StatusCode)
+	var payload map[string]interface{}
+	if err := json.Unmarshal(rendered, &payload); err != nil {
+		return nil, fmt.Errorf("slack template must produce a JSON object: %v", err)
 	}
-
-	logrus.Infof("Slack alert sent: %s", alert.Title)
-	return nil
+	if _, ok := payload["channel"]; !ok && channel != "" {
+		payload["channel"] = channel
+	}
+	if _, ok := payload["username"]; !ok && m.config.Alerts.Slack.Username != "" {
+		payload["username"] = m.config.Alerts.Slack.Username
+	}
+	return json.Marshal(payload)
 }
 
 // sendWebhookAlert sends a webhook alert
@@ -233,9 +491,15 @@ func (m *Manager) sendWebhookAlert(alert Alert) error {
 		return fmt.Errorf("webhook URL not configured")
 	}
 
-	jsonData, err := json.Marshal(alert)
+	var jsonData []byte
+	var err error
+	if m.templates.webhook != nil {
+		jsonData, err = m.renderJSON(m.templates.webhook, alert)
+	} else {
+		jsonData, err = json.Marshal(alert)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to marshal webhook payload: %v", err)
+		return fmt.Errorf("failed to build webhook payload: %v", err)
 	}
 
 	req, err := http.NewRequest(webhookCfg.Method, webhookCfg.URL, bytes.NewBuffer(jsonData))
@@ -257,7 +521,60 @@ func (m *Manager) sendWebhookAlert(alert Alert) error {
 		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
 	}
 
-	logrus.Infof("Webhook alert sent: %s", alert.Title)
+	alert.log().Infof("Webhook alert sent: %s", alert.Title)
 	return nil
 }
 
+// sendPagerDutyAlert triggers a PagerDuty incident through the Events API v2
+func (m *Manager) sendPagerDutyAlert(alert Alert) error {
+	pagerDutyCfg := m.config.Alerts.PagerDuty
+
+	if pagerDutyCfg.RoutingKey == "" {
+		return fmt.Errorf("PagerDuty routing key not configured")
+	}
+
+	// PagerDuty severities are the alert levels. Failures of a job share a
+	// dedup key, so its recovery resolves the incident.
+	payload := map[string]interface{}{
+		"routing_key":  pagerDutyCfg.RoutingKey,
+		"event_action": "trigger",
+		"payload": map[string]interface{}{
+			"summary":   alert.Title,
+			"source":    "arcron",
+			"severity":  alert.Level,
+			"timestamp": alert.Timestamp.Format(time.RFC3339),
+			"component": alert.JobName,
+			"custom_details": map[string]interface{}{
+				"message":      alert.Message,
+				"execution_id": alert.ExecutionID,
+				"labels":       alert.Labels,
+			},
+		},
+	}
+
+	if alert.JobName != "" && (alert.Event == EventFailure || alert.Event == EventRecovered) {
+		payload["dedup_key"] = "arcron/job/" + alert.JobName
+	}
+	if alert.Event == EventRecovered {
+		payload["event_action"] = "resolve"
+		delete(payload, "payload")
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal PagerDuty payload: %v", err)
+	}
+
+	resp, err := m.client.Post(pagerDutyCfg.URL, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to send PagerDuty alert: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("PagerDuty returned status %d", resp.StatusCode)
+	}
+
+	alert.log().Infof("PagerDuty alert sent: %s", alert.Title)
+	return nil
+}
@@ -0,0 +1,184 @@
+package alerts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+// Job events alerts can be sent for
+const (
+	EventFailure = "failure"
+	EventSuccess = "success"
+	EventMissed  = "missed"
+	EventSLA     = "sla"
+	// EventRecovered is a successful run after failure alerts
+	EventRecovered = "recovered"
+	// EventRegression is a job whose runs have become significantly slower
+	EventRegression = "regression"
+	// EventFlaky is a job whose flakiness score rose to the threshold
+	EventFlaky = "flaky"
+)
+
+// Alert channels
+const (
+	ChannelEmail     = "email"
+	ChannelSlack     = "slack"
+	ChannelWebhook   = "webhook"
+	ChannelPagerDuty = "pagerduty"
+	ChannelTeams     = "teams"
+	ChannelDiscord   = "discord"
+	ChannelTelegram  = "telegram"
+	ChannelTwilio    = "twilio"
+)
+
+// levelRanks orders alert levels from least to most severe
+var levelRanks = map[string]int{
+	"info":     0,
+	"warning":  1,
+	"error":    2,
+	"critical": 3,
+}
+
+// eventLevels is the level of the alert sent for each job event
+var eventLevels = map[string]string{
+	EventFailure:    "error",
+	EventSuccess:    "info",
+	EventMissed:     "warning",
+	EventSLA:        "warning",
+	EventRecovered:  "info",
+	EventRegression: "warning",
+	EventFlaky:      "warning",
+}
+
+// channels lists every alert channel
+var channels = []string{ChannelEmail, ChannelSlack, ChannelWebhook, ChannelPagerDuty,
+	ChannelTeams, ChannelDiscord, ChannelTelegram, ChannelTwilio}
+
+// Policy decides which alerts of a job are sent and where. A nil NotifyOn
+// alerts on every event.
+type Policy struct {
+	Enabled     bool
+	NotifyOn    []string
+	Channels    []string
+	MinSeverity string
+	SLA         time.Duration
+}
+
+// allows reports whether an event at a level is alerted on
+func (p Policy) allows(event, level string) bool {
+	if !p.Enabled || levelRanks[level] < levelRanks[p.MinSeverity] {
+		return false
+	}
+	return event == "" || p.NotifyOn == nil || contains(p.NotifyOn, event)
+}
+
+// sendsTo reports whether alerts are sent to a channel; an empty channel
+// list means all of them
+func (p Policy) sendsTo(channel string) bool {
+	return len(p.Channels) == 0 || contains(p.Channels, channel)
+}
+
+// SetJobSource makes the manager look up the policies of jobs in the jobs
+// returned by jobs, which include jobs added after the start, e.g. those
+// of the jobs directory. Without a source only the jobs of the
+// configuration are known.
+func (m *Manager) SetJobSource(jobs func() []config.JobConfig) {
+	m.jobs = jobs
+}
+
+// jobConfig returns the configuration of a job, if it is configured
+func (m *Manager) jobConfig(jobName string) (config.JobConfig, bool) {
+	jobs := m.config.Jobs
+	if m.jobs != nil {
+		jobs = m.jobs()
+	}
+	for _, job := range jobs {
+		if job.Name == jobName {
+			return job, true
+		}
+	}
+	return config.JobConfig{}, false
+}
+
+// PolicyFor resolves the alerting policy of a job against the global
+// alerts configuration. Jobs without their own settings, and system
+// alerts (an empty job name), get the global policy.
+func (m *Manager) PolicyFor(jobName string) Policy {
+	policy := Policy{
+		Enabled:     true,
+		NotifyOn:    m.config.Alerts.NotifyOn,
+		MinSeverity: m.config.Alerts.MinSeverity,
+	}
+	if jobName == "" {
+		return policy
+	}
+
+	job, ok := m.jobConfig(jobName)
+	if !ok {
+		return policy
+	}
+	own := job.Alerts
+	if own.Enabled != nil {
+		policy.Enabled = *own.Enabled
+	}
+	if own.NotifyOn != nil {
+		policy.NotifyOn = own.NotifyOn
+	}
+	if own.MinSeverity != "" {
+		policy.MinSeverity = own.MinSeverity
+	}
+	policy.Channels = own.Channels
+	policy.SLA = own.SLA
+	return policy
+}
+
+// validatePolicies checks the events, channels and severities named in
+// the global and per-job alerting settings
+func validatePolicies(cfg *config.Config) error {
+	check := func(scope string, notifyOn, channelNames []string, minSeverity string) error {
+		for _, event := range notifyOn {
+			if _, ok := eventLevels[event]; !ok {
+				return fmt.Errorf("%s: unknown alert event %q", scope, event)
+			}
+		}
+		for _, name := range channelNames {
+			if !contains(channels, name) {
+				return fmt.Errorf("%s: unknown alert channel %q", scope, name)
+			}
+		}
+		if _, ok := levelRanks[minSeverity]; minSeverity != "" && !ok {
+			return fmt.Errorf("%s: unknown alert severity %q", scope, minSeverity)
+		}
+		return nil
+	}
+
+	if err := check("alerts", cfg.Alerts.NotifyOn, nil, cfg.Alerts.MinSeverity); err != nil {
+		return err
+	}
+	if err := check("alerts.twilio", nil, nil, cfg.Alerts.Twilio.MinSeverity); err != nil {
+		return err
+	}
+	for _, job := range cfg.Jobs {
+		if err := check("job "+job.Name, job.Alerts.NotifyOn, job.Alerts.Channels, job.Alerts.MinSeverity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLevel reports whether level is an alert level
+func IsLevel(level string) bool {
+	_, ok := levelRanks[level]
+	return ok
+}
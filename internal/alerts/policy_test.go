@@ -0,0 +1,288 @@
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// webhookRecorder collects the alerts posted to a test webhook
+type webhookRecorder struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var alert Alert
+	json.NewDecoder(req.Body).Decode(&alert)
+	r.mu.Lock()
+	r.alerts = append(r.alerts, alert)
+	r.mu.Unlock()
+}
+
+func (r *webhookRecorder) titles() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var titles []string
+	for _, alert := range r.alerts {
+		titles = append(titles, alert.Title)
+	}
+	return titles
+}
+
+func TestJobAlertPolicy(t *testing.T) {
+	recorder := &webhookRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	disabled := false
+	cfg := &config.Config{
+		Alerts: config.AlertsConfig{
+			Enabled:     true,
+			NotifyOn:    []string{EventFailure, EventSuccess, EventSLA},
+			MinSeverity: "info",
+			Webhook:     config.WebhookConfig{Enabled: true, URL: server.URL, Method: http.MethodPost},
+		},
+		Jobs: []config.JobConfig{
+			{Name: "backup", Alerts: config.JobAlertsConfig{NotifyOn: []string{EventFailure, EventSLA}, SLA: time.Minute}},
+			{Name: "logrotate", Alerts: config.JobAlertsConfig{Enabled: &disabled}},
+			{Name: "report", Alerts: config.JobAlertsConfig{Channels: []string{ChannelSlack}}},
+			{Name: "sync", Alerts: config.JobAlertsConfig{MinSeverity: "error"}},
+		},
+	}
+	manager, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for _, execution := range []*types.JobExecution{
+		{JobName: "backup", Status: types.StatusCompleted, Duration: 30},
+		{JobName: "backup", Status: types.StatusCompleted, Duration: 90},
+		{JobName: "backup", Status: types.StatusFailed},
+		{JobName: "logrotate", Status: types.StatusFailed},
+		{JobName: "report", Status: types.StatusFailed},
+		{JobName: "sync", Status: types.StatusCompleted},
+		{JobName: "sync", Status: types.StatusFailed},
+		{JobName: "adhoc", Status: types.StatusCompleted},
+	} {
+		if err := manager.SendJobAlert(execution); err != nil {
+			t.Fatalf("SendJobAlert() error = %v", err)
+		}
+	}
+
+	want := []string{"Job SLA Breached: backup", "Job Failed: backup", "Job Failed: sync", "Job Completed: adhoc"}
+	got := recorder.titles()
+	if len(got) != len(want) {
+		t.Fatalf("alerts sent = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("alert %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestJobSource(t *testing.T) {
+	cfg := &config.Config{Alerts: config.AlertsConfig{NotifyOn: []string{EventFailure}, MinSeverity: "info"}}
+	manager, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if policy := manager.PolicyFor("report"); contains(policy.NotifyOn, EventSuccess) {
+		t.Fatalf("PolicyFor() = %+v, want the global policy for an unknown job", policy)
+	}
+
+	// A job added after the start, e.g. from the jobs directory
+	manager.SetJobSource(func() []config.JobConfig {
+		return []config.JobConfig{{Name: "report", Alerts: config.JobAlertsConfig{NotifyOn: []string{EventSuccess}}}}
+	})
+	if policy := manager.PolicyFor("report"); !contains(policy.NotifyOn, EventSuccess) {
+		t.Errorf("PolicyFor() = %+v, want the policy of the job from the source", policy)
+	}
+}
+
+func TestInvalidAlertPolicy(t *testing.T) {
+	cfg := &config.Config{Jobs: []config.JobConfig{
+		{Name: "backup", Alerts: config.JobAlertsConfig{NotifyOn: []string{"always"}}},
+	}}
+	if _, err := New(cfg); err == nil {
+		t.Error("New() accepted an unknown alert event")
+	}
+
+	cfg.Jobs[0].Alerts = config.JobAlertsConfig{Channels: []string{"pager"}}
+	if _, err := New(cfg); err == nil {
+		t.Error("New() accepted an unknown alert channel")
+	}
+}
+
+func TestAlertRoutes(t *testing.T) {
+	cfg := &config.Config{
+		Alerts: config.AlertsConfig{
+			Enabled:   true,
+			Slack:     config.SlackConfig{Enabled: true, Channel: "#alerts"},
+			Webhook:   config.WebhookConfig{Enabled: true},
+			PagerDuty: config.PagerDutyConfig{Enabled: true},
+			Routes: []config.AlertRoute{
+				{Name: "page", Match: config.AlertMatch{Levels: []string{"critical"}}, Channels: []string{ChannelPagerDuty}, Continue: true},
+				{Name: "ops", Match: config.AlertMatch{Levels: []string{"warning"}, Labels: map[string]string{"team": "ops"},
+					Hours: "09:00-17:00", Weekdays: []string{"mon", "tue", "wed", "thu", "fri"}, Timezone: "UTC"},
+					Channels: []string{ChannelSlack}, SlackChannel: "#ops", Continue: true},
+				{Name: "all", Channels: []string{ChannelWebhook, ChannelEmail}},
+			},
+		},
+		Jobs: []config.JobConfig{{Name: "backup", Labels: map[string]string{"team": "ops"}}},
+	}
+	manager, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Wednesday
+	businessHours := time.Date(2024, 6, 5, 10, 0, 0, 0, time.UTC)
+	night := time.Date(2024, 6, 5, 22, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		alert Alert
+		want  string
+	}{
+		{"critical", Alert{Level: "critical", Timestamp: night}, "[page all] [pagerduty webhook] "},
+		{"ops warning by day", Alert{Level: "warning", JobName: "backup", Timestamp: businessHours}, "[ops all] [slack webhook] #ops"},
+		{"ops warning at night", Alert{Level: "warning", JobName: "backup", Timestamp: night}, "[all] [webhook] "},
+		{"other warning", Alert{Level: "warning", JobName: "report", Timestamp: businessHours}, "[all] [webhook] "},
+	}
+	for _, tt := range tests {
+		result := manager.TestRoute(tt.alert)
+		if got := fmt.Sprintf("%v %v %s", result.Routes, result.Channels, result.SlackChannel); got != tt.want {
+			t.Errorf("%s: TestRoute() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	cfg.Alerts.Routes = []config.AlertRoute{{Match: config.AlertMatch{Hours: "9-5"}}}
+	if _, err := New(cfg); err == nil {
+		t.Error("New() accepted invalid route hours")
+	}
+}
+
+// memoryStore keeps the alert history, silences and dead letters in memory
+type memoryStore struct {
+	mu          sync.Mutex
+	entries     []*types.AlertEntry
+	silences    []*types.Silence
+	deadLetters []*types.DeadLetter
+}
+
+func (s *memoryStore) StoreAlert(entry *types.AlertEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.ID = uint(len(s.entries) + 1)
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *memoryStore) UpdateAlert(entry *types.AlertEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.entries[entry.ID-1]
+	stored.Status, stored.Channels, stored.Error = entry.Status, entry.Channels, entry.Error
+	return nil
+}
+
+func (s *memoryStore) GetUnresolvedAlerts(jobName, event string) ([]*types.AlertEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var unresolved []*types.AlertEntry
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if entry := s.entries[i]; entry.JobName == jobName && entry.Event == event && entry.ResolvedAt == nil {
+			unresolved = append(unresolved, entry)
+		}
+	}
+	return unresolved, nil
+}
+
+func (s *memoryStore) ResolveAlert(id uint, resolvedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[id-1].ResolvedAt = &resolvedAt
+	return nil
+}
+
+func (s *memoryStore) StoreDeadLetter(letter *types.DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	letter.ID = uint(len(s.deadLetters) + 1)
+	s.deadLetters = append(s.deadLetters, letter)
+	return nil
+}
+
+// entry returns a copy of an alert history entry
+func (s *memoryStore) entry(id uint) types.AlertEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.entries[id-1]
+}
+
+func (s *memoryStore) letters() []*types.DeadLetter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*types.DeadLetter(nil), s.deadLetters...)
+}
+
+func (s *memoryStore) GetSilences(endedAfter time.Time) ([]*types.Silence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.silences, nil
+}
+
+func TestSilencedAlertsAreRecorded(t *testing.T) {
+	recorder := &webhookRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	now := time.Now()
+	store := &memoryStore{silences: []*types.Silence{
+		{ID: 1, JobName: "backup", Labels: map[string]string{"team": "ops"}, StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour)},
+		{ID: 2, Level: "error", StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)},
+	}}
+
+	manager, err := New(&config.Config{
+		Alerts: config.AlertsConfig{
+			Enabled: true,
+			Webhook: config.WebhookConfig{Enabled: true, URL: server.URL, Method: http.MethodPost},
+		},
+		Jobs: []config.JobConfig{{Name: "backup", Labels: map[string]string{"team": "ops"}}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	manager.SetStore(store)
+
+	for _, execution := range []*types.JobExecution{
+		{JobName: "backup", Status: types.StatusFailed},
+		{JobName: "report", Status: types.StatusFailed},
+	} {
+		if err := manager.SendJobAlert(execution); err != nil {
+			t.Fatalf("SendJobAlert() error = %v", err)
+		}
+	}
+
+	if titles := recorder.titles(); len(titles) != 1 || titles[0] != "Job Failed: report" {
+		t.Errorf("alerts sent = %v, want only the report failure", titles)
+	}
+	if len(store.entries) != 2 {
+		t.Fatalf("recorded %d alerts, want 2", len(store.entries))
+	}
+	if silenced := store.entries[0]; silenced.Status != types.AlertStatusSilenced || silenced.SilenceID != 1 {
+		t.Errorf("backup alert recorded as %+v, want silenced by silence 1", silenced)
+	}
+	if sent := store.entries[1]; sent.Status != types.AlertStatusSent || len(sent.Channels) != 1 || sent.Channels[0] != ChannelWebhook {
+		t.Errorf("report alert recorded as %+v, want sent to the webhook", sent)
+	}
+}
@@ -0,0 +1,65 @@
+package alerts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/logging"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// resolveFailures marks the unresolved failure alerts of a job resolved
+// after a successful run and, if the policy alerts on recoveries, sends a
+// recovered alert referencing the latest of them to the channels they were
+// delivered to
+func (m *Manager) resolveFailures(policy Policy, execution *types.JobExecution) error {
+	if m.store == nil {
+		return nil
+	}
+
+	failures, err := m.store.GetUnresolvedAlerts(execution.JobName, EventFailure)
+	if err != nil {
+		return fmt.Errorf("failed to look up failure alerts: %v", err)
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var delivered []string
+	for _, failure := range failures {
+		if err := m.store.ResolveAlert(failure.ID, now); err != nil {
+			logging.ForJob(execution.JobName, execution.CorrelationID).Errorf("Failed to resolve alert %d: %v", failure.ID, err)
+		}
+		for _, channel := range failure.Channels {
+			if !contains(delivered, channel) && m.channelAccepts(channel, eventLevels[EventRecovered]) {
+				delivered = append(delivered, channel)
+			}
+		}
+	}
+
+	// Failures newest first
+	latest := failures[0]
+	alert := Alert{
+		Level:       eventLevels[EventRecovered],
+		Title:       fmt.Sprintf("Job Recovered: %s", execution.JobName),
+		Message:     fmt.Sprintf("Job %s completed after %d failed run(s), resolving alert %d from %s", execution.JobName, len(failures), latest.ID, latest.Timestamp.Format(time.RFC3339)),
+		Timestamp:   now,
+		JobName:     execution.JobName,
+		Namespace:   m.jobNamespace(execution.JobName),
+		ExecutionID: execution.ID,
+		Labels:      m.jobLabels(execution.JobName),
+		Event:       EventRecovered,
+		ResolvesID:  latest.ID,
+		execution:   execution,
+	}
+	if len(delivered) == 0 || !policy.allows(EventRecovered, alert.Level) {
+		return nil
+	}
+
+	// Route like the failure to reach the same Slack channel, but only
+	// through the channels that got it
+	routed := m.Route(Alert{Level: latest.Level, JobName: latest.JobName, Labels: alert.Labels, Timestamp: now})
+	routed.Channels = delivered
+	return m.sendRouted(policy, alert, routed)
+}
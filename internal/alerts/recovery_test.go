@@ -0,0 +1,103 @@
+package alerts
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestRecoveredAlertResolvesFailures(t *testing.T) {
+	recorder := &webhookRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	manager, err := New(&config.Config{
+		Alerts: config.AlertsConfig{
+			Enabled: true,
+			Webhook: config.WebhookConfig{Enabled: true, URL: server.URL, Method: http.MethodPost},
+		},
+		Jobs: []config.JobConfig{
+			{Name: "backup", Alerts: config.JobAlertsConfig{NotifyOn: []string{EventFailure, EventRecovered}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	store := &memoryStore{}
+	manager.SetStore(store)
+
+	for _, status := range []types.JobStatus{types.StatusFailed, types.StatusFailed, types.StatusCompleted, types.StatusCompleted} {
+		if err := manager.SendJobAlert(&types.JobExecution{JobName: "backup", Status: status}); err != nil {
+			t.Fatalf("SendJobAlert(%s) error = %v", status, err)
+		}
+	}
+
+	want := []string{"Job Failed: backup", "Job Failed: backup", "Job Recovered: backup"}
+	if got := recorder.titles(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("webhook alerts = %v, want %v", got, want)
+	}
+	if recovered := recorder.alerts[2]; recovered.Event != EventRecovered || recovered.ResolvesID != 2 {
+		t.Errorf("recovered alert = %+v, want it to reference the latest failure", recovered)
+	}
+
+	for _, id := range []uint{1, 2} {
+		if entry := store.entry(id); entry.ResolvedAt == nil {
+			t.Errorf("failure alert %d not resolved", id)
+		}
+	}
+	if entry := store.entry(3); entry.Event != EventRecovered || entry.ResolvesID != 2 || entry.Status != types.AlertStatusSent {
+		t.Errorf("recovered entry = %+v, want a sent recovery of alert 2", entry)
+	}
+}
+
+func TestAcknowledgedFailureSuppressesRepeats(t *testing.T) {
+	recorder := &webhookRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	manager, err := New(&config.Config{
+		Alerts: config.AlertsConfig{
+			Enabled: true,
+			Webhook: config.WebhookConfig{Enabled: true, URL: server.URL, Method: http.MethodPost},
+		},
+		Jobs: []config.JobConfig{
+			{Name: "backup", Alerts: config.JobAlertsConfig{NotifyOn: []string{EventFailure, EventRecovered}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	store := &memoryStore{}
+	manager.SetStore(store)
+
+	send := func(status types.JobStatus) {
+		t.Helper()
+		if err := manager.SendJobAlert(&types.JobExecution{JobName: "backup", Status: status}); err != nil {
+			t.Fatalf("SendJobAlert(%s) error = %v", status, err)
+		}
+	}
+
+	send(types.StatusFailed)
+	now := time.Now()
+	store.entries[0].AcknowledgedAt, store.entries[0].AcknowledgedBy = &now, "alice"
+
+	send(types.StatusFailed)
+	if entry := store.entry(2); entry.Status != types.AlertStatusSuppressed {
+		t.Errorf("repeated failure status = %q, want %q", entry.Status, types.AlertStatusSuppressed)
+	}
+
+	// The recovery resolves the acknowledged failure, so the next failure
+	// notifies again
+	send(types.StatusCompleted)
+	send(types.StatusFailed)
+
+	want := []string{"Job Failed: backup", "Job Recovered: backup", "Job Failed: backup"}
+	if got := recorder.titles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("webhook alerts = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,214 @@
+package alerts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+// weekdays maps the weekday names accepted in routes
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// route is a compiled alert route
+type route struct {
+	config   config.AlertRoute
+	weekdays map[time.Weekday]bool
+	// from and to are minutes since midnight; hours is false without a
+	// time of day range
+	hours    bool
+	from, to int
+	location *time.Location
+}
+
+// RouteResult is where an alert is sent
+type RouteResult struct {
+	// Routes names the matching routes, by index if they have no name
+	Routes       []string `json:"routes"`
+	Channels     []string `json:"channels"`
+	SlackChannel string   `json:"slack_channel,omitempty"`
+}
+
+// compileRoutes checks the configured routes and parses their times
+func compileRoutes(routes []config.AlertRoute) ([]route, error) {
+	compiled := make([]route, 0, len(routes))
+	for i, cfg := range routes {
+		if cfg.Name == "" {
+			cfg.Name = fmt.Sprintf("#%d", i+1)
+		}
+		r := route{config: cfg, location: time.Local}
+
+		for _, name := range cfg.Channels {
+			if !contains(channels, name) {
+				return nil, fmt.Errorf("alert route %s: unknown channel %q", cfg.Name, name)
+			}
+		}
+		for _, level := range cfg.Match.Levels {
+			if _, ok := levelRanks[level]; !ok {
+				return nil, fmt.Errorf("alert route %s: unknown level %q", cfg.Name, level)
+			}
+		}
+		if cfg.Match.Timezone != "" {
+			location, err := time.LoadLocation(cfg.Match.Timezone)
+			if err != nil {
+				return nil, fmt.Errorf("alert route %s: invalid timezone: %v", cfg.Name, err)
+			}
+			r.location = location
+		}
+		if len(cfg.Match.Weekdays) > 0 {
+			r.weekdays = make(map[time.Weekday]bool)
+			for _, name := range cfg.Match.Weekdays {
+				day, ok := weekdays[strings.ToLower(name)[:min(3, len(name))]]
+				if !ok {
+					return nil, fmt.Errorf("alert route %s: unknown weekday %q", cfg.Name, name)
+				}
+				r.weekdays[day] = true
+			}
+		}
+		if cfg.Match.Hours != "" {
+			from, to, err := parseHours(cfg.Match.Hours)
+			if err != nil {
+				return nil, fmt.Errorf("alert route %s: %v", cfg.Name, err)
+			}
+			r.hours, r.from, r.to = true, from, to
+		}
+
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
+
+// parseHours parses a time of day range such as "09:00-17:00" into
+// minutes since midnight
+func parseHours(hours string) (int, int, error) {
+	parts := strings.Split(hours, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid hours %q, expected HH:MM-HH:MM", hours)
+	}
+	var minutes [2]int
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid hours %q, expected HH:MM-HH:MM", hours)
+		}
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+	return minutes[0], minutes[1], nil
+}
+
+// matches reports whether the route matches an alert
+func (r route) matches(alert Alert) bool {
+	match := r.config.Match
+	if len(match.Levels) > 0 && !contains(match.Levels, alert.Level) {
+		return false
+	}
+	if len(match.Jobs) > 0 && !contains(match.Jobs, alert.JobName) {
+		return false
+	}
+	for key, value := range match.Labels {
+		if alert.Labels[key] != value {
+			return false
+		}
+	}
+
+	local := alert.Timestamp.In(r.location)
+	if r.weekdays != nil && !r.weekdays[local.Weekday()] {
+		return false
+	}
+	if r.hours {
+		minute := local.Hour()*60 + local.Minute()
+		if r.from <= r.to {
+			return minute >= r.from && minute < r.to
+		}
+		return minute >= r.from || minute < r.to
+	}
+	return true
+}
+
+// Route evaluates the alert routes against an alert. Without routes the
+// alert goes to every enabled channel.
+func (m *Manager) Route(alert Alert) RouteResult {
+	result := RouteResult{Routes: []string{}, Channels: []string{}}
+	if len(m.routes) == 0 {
+		for _, channel := range channels {
+			if m.channelEnabled(channel) && m.channelAccepts(channel, alert.Level) {
+				result.Channels = append(result.Channels, channel)
+			}
+		}
+		return result
+	}
+
+	for _, r := range m.routes {
+		if !r.matches(alert) {
+			continue
+		}
+		result.Routes = append(result.Routes, r.config.Name)
+		for _, channel := range r.config.Channels {
+			if m.channelEnabled(channel) && m.channelAccepts(channel, alert.Level) && !contains(result.Channels, channel) {
+				result.Channels = append(result.Channels, channel)
+			}
+		}
+		if result.SlackChannel == "" {
+			result.SlackChannel = r.config.SlackChannel
+		}
+		if !r.config.Continue {
+			break
+		}
+	}
+	return result
+}
+
+// channelEnabled reports whether a channel is enabled in the configuration
+func (m *Manager) channelEnabled(channel string) bool {
+	alerts := m.config.Alerts
+	switch channel {
+	case ChannelEmail:
+		return alerts.Email.Enabled
+	case ChannelSlack:
+		return alerts.Slack.Enabled
+	case ChannelWebhook:
+		return alerts.Webhook.Enabled
+	case ChannelPagerDuty:
+		return alerts.PagerDuty.Enabled
+	case ChannelTeams:
+		return alerts.Teams.Enabled
+	case ChannelDiscord:
+		return alerts.Discord.Enabled
+	case ChannelTelegram:
+		return alerts.Telegram.Enabled
+	case ChannelTwilio:
+		return alerts.Twilio.Enabled
+	}
+	return false
+}
+
+// channelAccepts reports whether a channel takes alerts of a level. Twilio
+// has its own severity threshold, so calls and texts are kept for the
+// alerts that warrant them.
+func (m *Manager) channelAccepts(channel, level string) bool {
+	if channel == ChannelTwilio {
+		return levelRanks[level] >= levelRanks[m.config.Alerts.Twilio.MinSeverity]
+	}
+	return true
+}
+
+// TestRoute evaluates the alert routes against a sample alert. The alert
+// defaults to the current time and the labels of its job.
+func (m *Manager) TestRoute(alert Alert) RouteResult {
+	if alert.Timestamp.IsZero() {
+		alert.Timestamp = time.Now()
+	}
+	if alert.Labels == nil {
+		alert.Labels = m.jobLabels(alert.JobName)
+	}
+	return m.Route(alert)
+}
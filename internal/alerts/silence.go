@@ -0,0 +1,95 @@
+package alerts
+
+import (
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Store persists the alert history, silences and dead letters, see
+// storage.Storage
+type Store interface {
+	StoreAlert(entry *types.AlertEntry) error
+	UpdateAlert(entry *types.AlertEntry) error
+	GetUnresolvedAlerts(jobName, event string) ([]*types.AlertEntry, error)
+	ResolveAlert(id uint, resolvedAt time.Time) error
+	GetSilences(endedAfter time.Time) ([]*types.Silence, error)
+	StoreDeadLetter(letter *types.DeadLetter) error
+}
+
+// SetStore records sent and silenced alerts in store, suppresses the
+// alerts matched by its active silences and keeps undeliverable alerts as
+// dead letters. Without a store alerts are sent unrecorded, cannot be
+// silenced and are dropped once their retries are exhausted.
+func (m *Manager) SetStore(store Store) {
+	m.store = store
+}
+
+// silenceMatches reports whether a silence matches an alert: its job,
+// level and every label must equal those of the alert
+func silenceMatches(silence *types.Silence, alert Alert) bool {
+	if silence.JobName != "" && silence.JobName != alert.JobName {
+		return false
+	}
+	if silence.Level != "" && silence.Level != alert.Level {
+		return false
+	}
+	for key, value := range silence.Labels {
+		if alert.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// silencedBy returns the active silence matching an alert, or nil
+func (m *Manager) silencedBy(alert Alert) *types.Silence {
+	if m.store == nil {
+		return nil
+	}
+
+	silences, err := m.store.GetSilences(alert.Timestamp)
+	if err != nil {
+		// Better a page during maintenance than a lost one
+		logrus.Errorf("Failed to load silences: %v", err)
+		return nil
+	}
+	for _, silence := range silences {
+		if silence.State(alert.Timestamp) == types.SilenceStateActive && silenceMatches(silence, alert) {
+			return silence
+		}
+	}
+	return nil
+}
+
+// record stores an alert in the alert history, if there is a store, and
+// returns its ID
+func (m *Manager) record(alert Alert, status string, channels []string, silenceID uint, err error) uint {
+	if m.store == nil {
+		return 0
+	}
+
+	entry := &types.AlertEntry{
+		Timestamp:   alert.Timestamp,
+		Level:       alert.Level,
+		Title:       alert.Title,
+		Message:     alert.Message,
+		JobName:     alert.JobName,
+		Namespace:   alert.Namespace,
+		ExecutionID: alert.ExecutionID,
+		Labels:      alert.Labels,
+		Status:      status,
+		Channels:    channels,
+		SilenceID:   silenceID,
+		Event:       alert.Event,
+		ResolvesID:  alert.ResolvesID,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if err := m.store.StoreAlert(entry); err != nil {
+		alert.log().Errorf("Failed to record alert %q: %v", alert.Title, err)
+	}
+	return entry.ID
+}
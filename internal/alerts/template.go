@@ -0,0 +1,121 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// templateFuncs are available in alert templates. json encodes a value as
+// JSON, for embedding text in JSON payloads.
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// TemplateData is what alert templates are executed with: the alert, the
+// execution of job alerts and links to the run and the job
+type TemplateData struct {
+	Alert
+	Execution *types.JobExecution
+	RunURL    string
+	JobURL    string
+}
+
+// templates are the configured alert templates; nil ones use the default
+// format of their channel
+type templates struct {
+	emailSubject *template.Template
+	emailBody    *template.Template
+	emailHTML    *htmltemplate.Template
+	slack        *template.Template
+	webhook      *template.Template
+}
+
+// parseTemplates parses the alert templates of the configuration
+func parseTemplates(cfg config.AlertsConfig) (templates, error) {
+	var t templates
+	for _, tmpl := range []struct {
+		name   string
+		text   string
+		target **template.Template
+	}{
+		{"email subject", cfg.Email.Subject, &t.emailSubject},
+		{"email body", cfg.Email.Body, &t.emailBody},
+		{"slack", cfg.Slack.Template, &t.slack},
+		{"webhook", cfg.Webhook.Template, &t.webhook},
+	} {
+		if tmpl.text == "" {
+			continue
+		}
+		parsed, err := template.New(tmpl.name).Funcs(templateFuncs).Option("missingkey=zero").Parse(tmpl.text)
+		if err != nil {
+			return templates{}, fmt.Errorf("invalid %s alert template: %v", tmpl.name, err)
+		}
+		*tmpl.target = parsed
+	}
+
+	if cfg.Email.HTMLBody != "" {
+		parsed, err := htmltemplate.New("email HTML body").Funcs(htmltemplate.FuncMap(templateFuncs)).
+			Option("missingkey=zero").Parse(cfg.Email.HTMLBody)
+		if err != nil {
+			return templates{}, fmt.Errorf("invalid email HTML body alert template: %v", err)
+		}
+		t.emailHTML = parsed
+	}
+	return t, nil
+}
+
+// templateData returns the data alert templates are executed with
+func (m *Manager) templateData(alert Alert) TemplateData {
+	data := TemplateData{Alert: alert, Execution: alert.execution}
+	base := strings.TrimSuffix(m.config.Server.ExternalURL, "/")
+	if alert.ExecutionID != "" {
+		data.RunURL = base + "/api/v1/executions/" + url.PathEscape(alert.ExecutionID)
+	}
+	if alert.JobName != "" {
+		data.JobURL = base + "/api/v1/jobs/" + url.PathEscape(alert.JobName)
+	}
+	return data
+}
+
+// render executes an alert template
+func (m *Manager) render(tmpl *template.Template, alert Alert) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, m.templateData(alert)); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %v", tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+// renderHTML executes an HTML alert template, escaping the alert's text
+func (m *Manager) renderHTML(tmpl *htmltemplate.Template, alert Alert) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, m.templateData(alert)); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %v", tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+// renderJSON executes an alert template that produces a JSON payload
+func (m *Manager) renderJSON(tmpl *template.Template, alert Alert) ([]byte, error) {
+	text, err := m.render(tmpl, alert)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid([]byte(text)) {
+		return nil, fmt.Errorf("%s template did not produce valid JSON", tmpl.Name())
+	}
+	return []byte(text), nil
+}
@@ -0,0 +1,67 @@
+package alerts
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestAlertTemplates(t *testing.T) {
+	bodies := make(chan []byte, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	manager, err := New(&config.Config{
+		Server: config.ServerConfig{ExternalURL: "https://arcron.example.com/"},
+		Alerts: config.AlertsConfig{
+			Enabled: true,
+			Slack: config.SlackConfig{Enabled: true, WebhookURL: server.URL, Channel: "#alerts",
+				Template: `{"blocks": [{"type": "section", "text": {"type": "mrkdwn", "text": {{ json (printf "*%s* <%s|run>" .Title .RunURL) }}}}]}`},
+			Webhook: config.WebhookConfig{Enabled: true, URL: server.URL, Method: http.MethodPost,
+				Template: `{"job": {{ json .JobName }}, "exit_code": {{ .Execution.ExitCode }}, "level": {{ json (upper .Level) }}}`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	err = manager.SendJobAlert(&types.JobExecution{ID: "abc", JobName: "backup", Status: types.StatusFailed, ExitCode: 2})
+	if err != nil {
+		t.Fatalf("SendJobAlert() error = %v", err)
+	}
+
+	var slack struct {
+		Channel string `json:"channel"`
+		Blocks  []struct {
+			Text struct {
+				Text string `json:"text"`
+			} `json:"text"`
+		} `json:"blocks"`
+	}
+	if err := json.Unmarshal(<-bodies, &slack); err != nil {
+		t.Fatalf("Slack payload is not JSON: %v", err)
+	}
+	want := "*Job Failed: backup* <https://arcron.example.com/api/v1/executions/abc|run>"
+	if slack.Channel != "#alerts" || len(slack.Blocks) != 1 || slack.Blocks[0].Text.Text != want {
+		t.Errorf("Slack payload = %+v, want channel #alerts and text %q", slack, want)
+	}
+
+	if got := string(<-bodies); got != `{"job": "backup", "exit_code": 2, "level": "ERROR"}` {
+		t.Errorf("webhook body = %s", got)
+	}
+}
+
+func TestInvalidAlertTemplate(t *testing.T) {
+	_, err := New(&config.Config{Alerts: config.AlertsConfig{Email: config.EmailConfig{Subject: "{{ .Title"}}})
+	if err == nil {
+		t.Error("New() accepted an invalid template")
+	}
+}
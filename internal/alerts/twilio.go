@@ -0,0 +1,92 @@
+package alerts
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Twilio delivery modes
+const (
+	twilioSMS   = "sms"
+	twilioVoice = "voice"
+	twilioBoth  = "both"
+)
+
+// twilioSMSLimit keeps texts within a few SMS segments
+const twilioSMSLimit = 480
+
+// sendTwilioAlert texts and/or calls every configured number through the
+// Twilio REST API
+func (m *Manager) sendTwilioAlert(alert Alert) error {
+	twilioCfg := m.config.Alerts.Twilio
+
+	if twilioCfg.AccountSID == "" || twilioCfg.AuthToken == "" || twilioCfg.From == "" || len(twilioCfg.To) == 0 {
+		return fmt.Errorf("twilio account, auth token, from or to numbers not configured")
+	}
+
+	text := fmt.Sprintf("[%s] %s: %s", strings.ToUpper(alert.Level), alert.Title, alert.Message)
+	if len(text) > twilioSMSLimit {
+		text = text[:twilioSMSLimit-3] + "..."
+	}
+
+	var errors []string
+	for _, to := range twilioCfg.To {
+		if twilioCfg.Mode != twilioVoice {
+			form := url.Values{"To": {to}, "From": {twilioCfg.From}, "Body": {text}}
+			if err := m.postTwilio("Messages.json", form); err != nil {
+				errors = append(errors, fmt.Sprintf("sms to %s: %v", to, err))
+			}
+		}
+		if twilioCfg.Mode == twilioVoice || twilioCfg.Mode == twilioBoth {
+			form := url.Values{"To": {to}, "From": {twilioCfg.From}, "Twiml": {twilioSay(alert)}}
+			if err := m.postTwilio("Calls.json", form); err != nil {
+				errors = append(errors, fmt.Sprintf("call to %s: %v", to, err))
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("%s", strings.Join(errors, "; "))
+	}
+
+	alert.log().Infof("Twilio alert sent: %s", alert.Title)
+	return nil
+}
+
+// postTwilio posts a form to a resource of the configured Twilio account
+func (m *Manager) postTwilio(resource string, form url.Values) error {
+	twilioCfg := m.config.Alerts.Twilio
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/%s", strings.TrimSuffix(twilioCfg.APIURL, "/"),
+		url.PathEscape(twilioCfg.AccountSID), resource)
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(twilioCfg.AccountSID, twilioCfg.AuthToken)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send Twilio alert: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("twilio returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// twilioSay returns the TwiML of a call reading out an alert twice
+func twilioSay(alert Alert) string {
+	var text strings.Builder
+	xml.EscapeText(&text, []byte(fmt.Sprintf("Arcron %s alert. %s. %s", alert.Level, alert.Title, alert.Message)))
+	say := "<Say>" + text.String() + "</Say>"
+	return "<Response>" + say + `<Pause length="1"/>` + say + "</Response>"
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// handleMLAccuracy returns the rolling prediction accuracy per job and
+// method, the jobs currently falling back to the heuristics and the
+// accuracy of load forecasts
+func (s *Server) handleMLAccuracy(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+
+	// Catch up on predictions that became due since the last periodic pass
+	if err := s.accuracy.Evaluate(now); err != nil {
+		logrus.Errorf("Failed to evaluate predictions: %v", err)
+	}
+
+	accuracy, err := s.accuracy.Accuracy(now)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if requestNamespace(r) != "" {
+		visible := make([]*types.PredictionAccuracy, 0, len(accuracy))
+		for _, jobAccuracy := range accuracy {
+			if s.jobVisible(r, jobAccuracy.JobName) {
+				visible = append(visible, jobAccuracy)
+			}
+		}
+		accuracy = visible
+	}
+
+	forecast, err := s.accuracy.ForecastAccuracy(now)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.writeSuccess(w, map[string]interface{}{
+		"window":        s.config.ML.AccuracyWindow.String(),
+		"jobs":          accuracy,
+		"fallback_jobs": s.visibleJobNames(r, s.accuracy.FallbackJobs()),
+		"forecast":      forecast,
+	})
+}
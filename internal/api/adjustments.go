@@ -0,0 +1,117 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// adjustmentSummary quantifies a set of schedule adjustments
+type adjustmentSummary struct {
+	Total            int            `json:"total"`
+	Outcomes         map[string]int `json:"outcomes"`
+	MeanShiftMinutes float64        `json:"mean_shift_minutes"`
+	SuccessRate      float64        `json:"success_rate"`
+}
+
+// handleSchedulerAdjustments returns the persisted schedule adjustments
+// filtered by query parameters, with a summary of their outcomes
+func (s *Server) handleSchedulerAdjustments(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if jobName := query.Get("job"); jobName != "" && !s.jobVisible(r, jobName) {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", jobName))
+		return
+	}
+	filter := storage.AdjustmentFilter{
+		JobName: query.Get("job"),
+		Outcome: query.Get("outcome"),
+		Limit:   100,
+	}
+
+	if dryRunStr := query.Get("dry_run"); dryRunStr != "" {
+		dryRun, err := strconv.ParseBool(dryRunStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("dry_run", "invalid dry_run: %s", dryRunStr))
+			return
+		}
+		filter.DryRun = &dryRun
+	}
+
+	if sinceStr := query.Get("since"); sinceStr != "" {
+		since, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("since", "invalid since time: %v", err))
+			return
+		}
+		filter.Since = since
+	}
+
+	if untilStr := query.Get("until"); untilStr != "" {
+		until, err := time.Parse(time.RFC3339, untilStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("until", "invalid until time: %v", err))
+			return
+		}
+		filter.Until = until
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			s.writeError(w, http.StatusBadRequest, invalidField("limit", "invalid limit: %s", limitStr))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	adjustments, err := s.store.GetScheduleAdjustments(filter)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if requestNamespace(r) != "" {
+		visible := make([]*types.ScheduleAdjustment, 0, len(adjustments))
+		for _, adjustment := range adjustments {
+			if s.jobVisible(r, adjustment.JobName) {
+				visible = append(visible, adjustment)
+			}
+		}
+		adjustments = visible
+	}
+
+	s.writeSuccess(w, map[string]interface{}{
+		"adjustments": adjustments,
+		"summary":     summarizeAdjustments(adjustments),
+	})
+}
+
+// summarizeAdjustments counts adjustments by outcome and computes how far
+// runs were moved on average and how many moved runs succeeded
+func summarizeAdjustments(adjustments []*types.ScheduleAdjustment) adjustmentSummary {
+	summary := adjustmentSummary{
+		Total:    len(adjustments),
+		Outcomes: make(map[string]int),
+	}
+
+	var shift time.Duration
+	for _, adjustment := range adjustments {
+		summary.Outcomes[adjustment.Outcome]++
+		if adjustment.Outcome != types.AdjustmentSkipped {
+			shift += adjustment.NewTime.Sub(adjustment.OriginalTime).Abs()
+		}
+	}
+
+	// Skipped adjustments moved nothing
+	if moved := summary.Total - summary.Outcomes[types.AdjustmentSkipped]; moved > 0 {
+		summary.MeanShiftMinutes = shift.Minutes() / float64(moved)
+	}
+	ran := summary.Outcomes[types.AdjustmentCompleted] + summary.Outcomes[types.AdjustmentFailed]
+	if ran > 0 {
+		summary.SuccessRate = float64(summary.Outcomes[types.AdjustmentCompleted]) / float64(ran)
+	}
+	return summary
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/makalin/arcron/internal/scheduler"
+)
+
+// handleSchedulerAdvisories returns the adjustments the scheduler would have
+// made in dry-run mode, optionally filtered by job
+func (s *Server) handleSchedulerAdvisories(w http.ResponseWriter, r *http.Request) {
+	jobName := r.URL.Query().Get("job")
+	if jobName != "" && !s.jobVisible(r, jobName) {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", jobName))
+		return
+	}
+
+	advisories := s.scheduler.Advisories(jobName)
+	if requestNamespace(r) != "" {
+		visible := make([]*scheduler.Adjustment, 0, len(advisories))
+		for _, advisory := range advisories {
+			if s.jobVisible(r, advisory.JobName) {
+				visible = append(visible, advisory)
+			}
+		}
+		advisories = visible
+	}
+	s.writeSuccess(w, advisories)
+}
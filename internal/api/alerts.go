@@ -0,0 +1,370 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/makalin/arcron/internal/alerts"
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// handleTestAlertRoutes evaluates the alert routes against a sample alert
+// sent as the request body and returns the matching routes and channels
+func (s *Server) handleTestAlertRoutes(w http.ResponseWriter, r *http.Request) {
+	if s.alertManager == nil {
+		s.writeError(w, http.StatusServiceUnavailable, fmt.Errorf("alerting is not configured"))
+		return
+	}
+
+	var alert alerts.Alert
+	if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	if alert.Level == "" {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("level is required"))
+		return
+	}
+
+	s.writeSuccess(w, s.alertManager.TestRoute(alert))
+}
+
+// handleGetAlerts returns the alert history filtered by query parameters,
+// including alerts suppressed by silences
+func (s *Server) handleGetAlerts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := storage.AlertFilter{
+		JobName:   query.Get("job"),
+		Level:     query.Get("level"),
+		Status:    query.Get("status"),
+		Event:     query.Get("event"),
+		Namespace: query.Get("namespace"),
+		Limit:     100,
+	}
+	if namespace := requestNamespace(r); namespace != "" {
+		filter.Namespace = namespace
+	}
+
+	if sinceStr := query.Get("since"); sinceStr != "" {
+		since, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("since", "invalid since time: %v", err))
+			return
+		}
+		filter.Since = since
+	}
+
+	if untilStr := query.Get("until"); untilStr != "" {
+		until, err := time.Parse(time.RFC3339, untilStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("until", "invalid until time: %v", err))
+			return
+		}
+		filter.Until = until
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			s.writeError(w, http.StatusBadRequest, invalidField("limit", "invalid limit: %s", limitStr))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := s.store.GetAlerts(filter)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.writeSuccess(w, entries)
+}
+
+// feedAlerts is how many of the latest alerts the dashboard feed carries
+const feedAlerts = 10
+
+// recentAlerts returns the latest alerts with their acknowledgements for
+// the dashboard feed, or nil if they cannot be read
+func (s *Server) recentAlerts() []*types.AlertEntry {
+	entries, err := s.store.GetAlerts(storage.AlertFilter{Limit: feedAlerts})
+	if err != nil {
+		logrus.Debugf("Failed to read alerts for the dashboard feed: %v", err)
+		return nil
+	}
+	return entries
+}
+
+// handleAcknowledgeAlert records that the requesting principal took
+// ownership of an alert. Until its job recovers, further failures of the
+// job are recorded without notifying again.
+func (s *Server) handleAcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid alert ID: %s", mux.Vars(r)["id"]))
+		return
+	}
+
+	if requestNamespace(r) != "" {
+		entry, err := s.store.GetAlert(uint(id))
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if entry == nil || !inNamespace(r, entry.Namespace) {
+			s.writeError(w, http.StatusNotFound, fmt.Errorf("alert not found: %d", id))
+			return
+		}
+	}
+
+	entry, err := s.store.AcknowledgeAlert(uint(id), requestPrincipal(r), time.Now())
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if entry == nil {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("alert not found: %d", id))
+		return
+	}
+	s.audit(r, AuditActionAlertAck, fmt.Sprintf("alert/%d", id), nil)
+
+	s.writeSuccess(w, entry)
+}
+
+// silenceView is a silence with its current state
+type silenceView struct {
+	*types.Silence
+	State string `json:"state"`
+}
+
+// handleGetSilences returns the pending and active silences, or those in
+// the state given by the state parameter; "all" includes expired ones
+func (s *Server) handleGetSilences(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	state := r.URL.Query().Get("state")
+
+	endedAfter := now
+	switch state {
+	case "", types.SilenceStatePending, types.SilenceStateActive:
+	case "all", types.SilenceStateExpired:
+		endedAfter = time.Time{}
+	default:
+		s.writeError(w, http.StatusBadRequest, invalidField("state", "invalid state: %s", state))
+		return
+	}
+
+	silences, err := s.store.GetSilences(endedAfter)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	views := []silenceView{}
+	for _, silence := range silences {
+		current := silence.State(now)
+		if state == "" || state == "all" || state == current {
+			views = append(views, silenceView{Silence: silence, State: current})
+		}
+	}
+
+	s.writeSuccess(w, views)
+}
+
+// createSilenceRequest creates a silence lasting Duration from StartsAt,
+// or until EndsAt
+type createSilenceRequest struct {
+	JobName  string            `json:"job_name"`
+	Level    string            `json:"level"`
+	Labels   map[string]string `json:"labels"`
+	Comment  string            `json:"comment"`
+	StartsAt time.Time         `json:"starts_at"`
+	EndsAt   time.Time         `json:"ends_at"`
+	Duration string            `json:"duration"`
+}
+
+// handleCreateSilence creates a silence suppressing the alerts it matches.
+// At least one matcher is required so a silence never mutes everything by
+// accident.
+func (s *Server) handleCreateSilence(w http.ResponseWriter, r *http.Request) {
+	var req createSilenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+
+	var problems fieldErrors
+	if req.JobName == "" && req.Level == "" && len(req.Labels) == 0 {
+		problems.add("job_name", "a silence needs a job_name, level or labels matcher")
+	}
+	if req.Level != "" && !alerts.IsLevel(req.Level) {
+		problems.add("level", "unknown level: %s", req.Level)
+	}
+
+	silence := &types.Silence{
+		JobName:   req.JobName,
+		Level:     req.Level,
+		Labels:    req.Labels,
+		Comment:   req.Comment,
+		CreatedBy: requestPrincipal(r),
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+	}
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = time.Now()
+	}
+	if req.Duration != "" {
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil || duration <= 0 {
+			problems.add("duration", "invalid duration: %s", req.Duration)
+		} else {
+			silence.EndsAt = silence.StartsAt.Add(duration)
+		}
+	}
+	if req.Duration == "" && !silence.EndsAt.After(silence.StartsAt) {
+		problems.add("ends_at", "a silence needs a duration or an ends_at after starts_at")
+	}
+	if err := problems.err(); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err := s.store.CreateSilence(silence)
+	s.audit(r, AuditActionSilenceCreate, fmt.Sprintf("silence/%d", silence.ID), req)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.writeSuccess(w, silenceView{Silence: silence, State: silence.State(time.Now())})
+}
+
+// handleExpireSilence ends a silence now
+func (s *Server) handleExpireSilence(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid silence ID: %s", mux.Vars(r)["id"]))
+		return
+	}
+
+	silence, err := s.store.ExpireSilence(uint(id), time.Now())
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if silence == nil {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("silence not found: %d", id))
+		return
+	}
+	s.audit(r, AuditActionSilenceExpire, fmt.Sprintf("silence/%d", id), nil)
+
+	s.writeSuccess(w, silenceView{Silence: silence, State: silence.State(time.Now())})
+}
+
+// handleGetDeadLetters returns the alert deliveries given up after their
+// retries; all=true includes those already redriven
+func (s *Server) handleGetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := 100
+	if limitStr := query.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			s.writeError(w, http.StatusBadRequest, invalidField("limit", "invalid limit: %s", limitStr))
+			return
+		}
+		limit = l
+	}
+
+	letters, err := s.store.GetDeadLetters(query.Get("all") == "true", limit)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.writeSuccess(w, letters)
+}
+
+// handleRedriveDeadLetter makes another attempt at delivering a dead
+// letter. A failed attempt keeps it in the dead-letter table.
+func (s *Server) handleRedriveDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if s.alertManager == nil {
+		s.writeError(w, http.StatusServiceUnavailable, fmt.Errorf("alerting is not configured"))
+		return
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid dead letter ID: %s", mux.Vars(r)["id"]))
+		return
+	}
+
+	letter, err := s.store.GetDeadLetter(uint(id))
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if letter == nil {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("dead letter not found: %d", id))
+		return
+	}
+	if letter.RedrivenAt != nil {
+		s.writeError(w, http.StatusConflict, fmt.Errorf("dead letter %d was already redriven", id))
+		return
+	}
+
+	err = s.redrive(letter)
+	s.audit(r, AuditActionAlertRedrive, fmt.Sprintf("dead-letter/%d", id), nil)
+	if err != nil {
+		s.writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	s.writeSuccess(w, letter)
+}
+
+// redriveResult is the outcome of redriving all dead letters
+type redriveResult struct {
+	Redriven []uint          `json:"redriven"`
+	Failed   map[uint]string `json:"failed"`
+}
+
+// handleRedriveDeadLetters makes another attempt at delivering every dead
+// letter not redriven yet
+func (s *Server) handleRedriveDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if s.alertManager == nil {
+		s.writeError(w, http.StatusServiceUnavailable, fmt.Errorf("alerting is not configured"))
+		return
+	}
+
+	letters, err := s.store.GetDeadLetters(false, 0)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	result := redriveResult{Redriven: []uint{}, Failed: map[uint]string{}}
+	for _, letter := range letters {
+		if err := s.redrive(letter); err != nil {
+			result.Failed[letter.ID] = err.Error()
+		} else {
+			result.Redriven = append(result.Redriven, letter.ID)
+		}
+	}
+	s.audit(r, AuditActionAlertRedrive, "dead-letter/*", result)
+
+	s.writeSuccess(w, result)
+}
+
+// redrive delivers a dead letter again and stores the outcome
+func (s *Server) redrive(letter *types.DeadLetter) error {
+	deliveryErr := s.alertManager.Redrive(letter)
+	if err := s.store.UpdateDeadLetter(letter); err != nil {
+		return err
+	}
+	return deliveryErr
+}
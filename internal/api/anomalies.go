@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/storage"
+)
+
+// handleGetAnomalies returns detected anomalies filtered by query
+// parameters. severity selects that severity and everything above it.
+func (s *Server) handleGetAnomalies(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := storage.AnomalyFilter{
+		Type:  query.Get("type"),
+		Limit: 100,
+	}
+
+	if severity := query.Get("severity"); severity != "" {
+		severities, err := ml.SeveritiesAtLeast(severity)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		filter.Severities = severities
+	}
+
+	if sinceStr := query.Get("since"); sinceStr != "" {
+		since, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("since", "invalid since time: %v", err))
+			return
+		}
+		filter.Since = since
+	}
+
+	if untilStr := query.Get("until"); untilStr != "" {
+		until, err := time.Parse(time.RFC3339, untilStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("until", "invalid until time: %v", err))
+			return
+		}
+		filter.Until = until
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			s.writeError(w, http.StatusBadRequest, invalidField("limit", "invalid limit: %s", limitStr))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	anomalies, err := s.store.GetAnomalies(filter)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.writeSuccess(w, anomalies)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestGetAnomalies(t *testing.T) {
+	server := newTestServer(t, &config.Config{})
+	now := time.Now().Truncate(time.Second)
+
+	for _, anomaly := range []*types.Anomaly{
+		{Timestamp: now.Add(-2 * time.Hour), Type: "cpu", Severity: "low", Value: 70, Expected: 40, Deviation: 3},
+		{Timestamp: now.Add(-time.Hour), Type: "memory", Severity: "high", Value: 95, Expected: 50, Deviation: 5},
+		{Timestamp: now, Type: "cpu", Severity: "critical", Value: 99, Expected: 40, Deviation: 8},
+	} {
+		if err := server.store.StoreAnomaly(anomaly); err != nil {
+			t.Fatalf("StoreAnomaly() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query url.Values
+		want  []string // severities, newest first
+	}{
+		{"all", nil, []string{"critical", "high", "low"}},
+		{"type", url.Values{"type": {"cpu"}}, []string{"critical", "low"}},
+		{"severity and above", url.Values{"severity": {"high"}}, []string{"critical", "high"}},
+		{"since", url.Values{"since": {now.Add(-90 * time.Minute).Format(time.RFC3339)}}, []string{"critical", "high"}},
+		{"until", url.Values{"until": {now.Add(-90 * time.Minute).Format(time.RFC3339)}}, []string{"low"}},
+		{"limit", url.Values{"limit": {"2"}}, []string{"critical", "high"}},
+		{"combined", url.Values{"type": {"cpu"}, "severity": {"medium"}}, []string{"critical"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			server.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/anomalies?"+tt.query.Encode(), nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status code = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+			}
+
+			var body struct {
+				Data []types.Anomaly `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+			}
+			var got []string
+			for _, anomaly := range body.Data {
+				got = append(got, anomaly.Severity)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("severities = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("severities = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetAnomaliesInvalidQuery(t *testing.T) {
+	server := newTestServer(t, &config.Config{})
+
+	for _, query := range []string{"severity=severe", "since=yesterday", "until=2024-13-01", "limit=0", "limit=ten"} {
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/anomalies?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET /api/v1/anomalies?%s status code = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
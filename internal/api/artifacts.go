@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// handleGetArtifacts lists the artifacts collected from an execution
+func (s *Server) handleGetArtifacts(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if !s.executionVisible(w, r, id) {
+		return
+	}
+
+	artifacts, err := s.jobManager.Artifacts(id)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.writeSuccess(w, artifacts)
+}
+
+// handleDownloadArtifact returns the file of an artifact of an execution
+func (s *Server) handleDownloadArtifact(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, name := vars["id"], vars["artifact"]
+	if !s.executionVisible(w, r, id) {
+		return
+	}
+
+	artifact, file, err := s.jobManager.OpenArtifact(r.Context(), id, name)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if artifact == nil {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("artifact not found: %s", name))
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": artifact.Name}))
+	w.Header().Set("Content-Length", strconv.FormatInt(artifact.Size, 10))
+	io.Copy(w, file)
+}
+
+// executionVisible writes a not found error unless the execution exists
+// in the namespace of the request
+func (s *Server) executionVisible(w http.ResponseWriter, r *http.Request, id string) bool {
+	execution, err := s.jobManager.GetExecution(id)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return false
+	}
+	if execution == nil || !inNamespace(r, execution.Namespace) {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("execution not found: %s", id))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Audit actions recorded for mutating operations
+const (
+	AuditActionJobExecute      = "job.execute"
+	AuditActionJobSync         = "job.sync"
+	AuditActionJobRollback     = "job.rollback"
+	AuditActionScheduleUpdate  = "job.schedule_update"
+	AuditActionSchedulerPause  = "scheduler.pause"
+	AuditActionSchedulerResume = "scheduler.resume"
+	AuditActionDatabaseBackup  = "database.backup"
+	AuditActionDatabaseRestore = "database.restore"
+	AuditActionSilenceCreate   = "alert.silence_create"
+	AuditActionSilenceExpire   = "alert.silence_expire"
+	AuditActionAlertRedrive    = "alert.redrive"
+	AuditActionAlertAck        = "alert.ack"
+	AuditActionLogLevel        = "logging.level"
+	AuditActionQueueMove       = "queue.move"
+	AuditActionQueueCancel     = "queue.cancel"
+)
+
+// audit records a mutating action performed through the API.
+// Failures are logged but never block the request itself.
+func (s *Server) audit(r *http.Request, action, target string, payload interface{}) {
+	entry := &types.AuditEntry{
+		Timestamp: time.Now(),
+		Principal: requestPrincipal(r),
+		SourceIP:  s.requestSourceIP(r),
+		Action:    action,
+		Target:    target,
+	}
+
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			logrus.WithField(requestIDField, requestID(r)).Warnf("Failed to marshal audit payload for %s: %v", action, err)
+		} else {
+			entry.Payload = string(data)
+		}
+	}
+
+	if err := s.store.StoreAuditEntry(entry); err != nil {
+		logrus.WithField(requestIDField, requestID(r)).Errorf("Failed to store audit entry for %s on %s: %v", action, target, err)
+	}
+}
+
+// requestPrincipal returns the identity that issued the request
+func requestPrincipal(r *http.Request) string {
+	if token := requestToken(r); token != nil {
+		return "token:" + token.Name
+	}
+	if username, _, ok := r.BasicAuth(); ok && username != "" {
+		return username
+	}
+	return "anonymous"
+}
+
+// requestSourceIP returns the originating client IP of the request. The
+// forwarding headers are only believed from trusted proxies, and of
+// X-Forwarded-For the last address not added by a trusted proxy is taken,
+// since clients can prepend any address they like.
+func (s *Server) requestSourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !s.trustedProxy(host) {
+		return host
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if i == 0 || !s.trustedProxy(hop) {
+				return hop
+			}
+		}
+	}
+	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+		return realIP
+	}
+	return host
+}
+
+// trustedProxy reports whether an address is one of the trusted proxies
+func (s *Server) trustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range s.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleGetAudit returns audit entries filtered by query parameters
+func (s *Server) handleGetAudit(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		s.writeError(w, http.StatusForbidden, fmt.Errorf("the audit trail is only shown to authenticated or local users"))
+		return
+	}
+
+	query := r.URL.Query()
+	filter := storage.AuditFilter{
+		Action:    query.Get("action"),
+		Principal: query.Get("principal"),
+		Target:    query.Get("target"),
+		Limit:     100,
+	}
+
+	if sinceStr := query.Get("since"); sinceStr != "" {
+		since, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("since", "invalid since time: %v", err))
+			return
+		}
+		filter.Since = since
+	}
+
+	if untilStr := query.Get("until"); untilStr != "" {
+		until, err := time.Parse(time.RFC3339, untilStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("until", "invalid until time: %v", err))
+			return
+		}
+		filter.Until = until
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			s.writeError(w, http.StatusBadRequest, invalidField("limit", "invalid limit: %s", limitStr))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := s.store.GetAuditEntries(filter)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.writeSuccess(w, entries)
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/storage"
+)
+
+func TestAuditSourceIP(t *testing.T) {
+	server := newTestServer(t, &config.Config{
+		Server: config.ServerConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+	})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"direct", "203.0.113.7:52100", nil, "203.0.113.7"},
+		{"untrusted forwarder", "203.0.113.7:52100", map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "198.51.100.2"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:52100", map[string]string{"X-Forwarded-For": "198.51.100.3"}, "198.51.100.3"},
+		{"proxy chain", "10.0.0.2:52100", map[string]string{"X-Forwarded-For": "198.51.100.4, 10.0.0.3"}, "198.51.100.4"},
+		{"spoofed hop", "10.0.0.2:52100", map[string]string{"X-Forwarded-For": "192.0.2.1, 198.51.100.5"}, "198.51.100.5"},
+		{"real ip", "10.0.0.2:52100", map[string]string{"X-Real-IP": "198.51.100.6"}, "198.51.100.6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/scheduler/pause", strings.NewReader(`{"reason":"`+tt.name+`"}`))
+			req.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			server.router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+
+			entries, err := server.store.GetAuditEntries(storage.AuditFilter{Action: AuditActionSchedulerPause, Limit: 100})
+			if err != nil {
+				t.Fatalf("GetAuditEntries() error = %v", err)
+			}
+			for _, entry := range entries {
+				if strings.Contains(entry.Payload, `"`+tt.name+`"`) {
+					if entry.SourceIP != tt.want {
+						t.Errorf("recorded source IP = %q, want %q", entry.SourceIP, tt.want)
+					}
+					return
+				}
+			}
+			t.Fatalf("no audit entry recorded among %d", len(entries))
+		})
+	}
+}
+
+func TestGetAuditRequiresAdmin(t *testing.T) {
+	server := newTestServer(t, &config.Config{})
+
+	// Without dashboard authentication only local users are admins
+	for remoteAddr, want := range map[string]int{"203.0.113.7:52100": http.StatusForbidden, "127.0.0.1:52100": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/audit", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		server.router.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("audit from %s: status = %d, want %d", remoteAddr, rec.Code, want)
+		}
+	}
+}
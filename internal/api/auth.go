@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// basicAuth holds the credentials protecting the dashboard,
+// API and WebSocket endpoints
+type basicAuth struct {
+	username     string
+	passwordHash []byte
+	tokens       []config.APITokenConfig
+}
+
+// newBasicAuth creates the basic auth middleware from the dashboard
+// auth configuration. Passwords are expected to be bcrypt hashes; plain
+// text passwords are hashed in memory and a warning is logged.
+func newBasicAuth(cfg config.DashboardAuthConfig) (*basicAuth, error) {
+	if cfg.Username == "" || cfg.Password == "" {
+		return nil, fmt.Errorf("dashboard auth is enabled but username or password is empty")
+	}
+
+	hash := []byte(cfg.Password)
+	if _, err := bcrypt.Cost(hash); err != nil {
+		logrus.Warn("Dashboard password is not a bcrypt hash; store a hash generated with bcrypt instead")
+		hash, err = bcrypt.GenerateFromPassword([]byte(cfg.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash dashboard password: %v", err)
+		}
+	}
+
+	return &basicAuth{
+		username:     cfg.Username,
+		passwordHash: hash,
+		tokens:       cfg.Tokens,
+	}, nil
+}
+
+// unauthenticatedPaths are reachable without credentials so that
+// load balancer and orchestrator probes keep working
+var unauthenticatedPaths = map[string]bool{
+	"/health":  true,
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// requireAuth rejects requests without valid credentials
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unauthenticatedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if bearer, ok := bearerToken(r); ok {
+			token := s.auth.verifyToken(bearer)
+			if token == nil {
+				s.writeError(w, http.StatusUnauthorized, fmt.Errorf("unauthorized"))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenContextKey{}, token)))
+			return
+		}
+
+		username, password, ok := r.BasicAuth()
+		if !ok || !s.auth.verify(username, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="arcron", charset="UTF-8"`)
+			s.writeError(w, http.StatusUnauthorized, fmt.Errorf("unauthorized"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// verify checks the supplied credentials
+func (a *basicAuth) verify(username, password string) bool {
+	usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(a.username)) == 1
+	passwordMatch := bcrypt.CompareHashAndPassword(a.passwordHash, []byte(password)) == nil
+	return usernameMatch && passwordMatch
+}
+
+// tokenContextKey is the request context key of the API token a request
+// was authenticated with
+type tokenContextKey struct{}
+
+// bearerToken returns the bearer token of a request, if any
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(header[len("Bearer "):]), true
+}
+
+// verifyToken returns the API token matching a bearer token, or nil
+func (a *basicAuth) verifyToken(bearer string) *config.APITokenConfig {
+	var match *config.APITokenConfig
+	for i := range a.tokens {
+		token := &a.tokens[i]
+		if token.Token != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(token.Token)) == 1 {
+			match = token
+		}
+	}
+	return match
+}
+
+// requestToken returns the API token a request was authenticated with
+func requestToken(r *http.Request) *config.APITokenConfig {
+	token, _ := r.Context().Value(tokenContextKey{}).(*config.APITokenConfig)
+	return token
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestBasicAuthVerify(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+
+	auth, err := newBasicAuth(config.DashboardAuthConfig{
+		Enabled:  true,
+		Username: "admin",
+		Password: string(hash),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create basic auth: %v", err)
+	}
+
+	if !auth.verify("admin", "s3cret") {
+		t.Error("Expected valid credentials to be accepted")
+	}
+	if auth.verify("admin", "wrong") {
+		t.Error("Expected wrong password to be rejected")
+	}
+	if auth.verify("root", "s3cret") {
+		t.Error("Expected wrong username to be rejected")
+	}
+}
+
+func TestBasicAuthPlaintextPassword(t *testing.T) {
+	auth, err := newBasicAuth(config.DashboardAuthConfig{
+		Enabled:  true,
+		Username: "admin",
+		Password: "plaintext",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create basic auth: %v", err)
+	}
+
+	if !auth.verify("admin", "plaintext") {
+		t.Error("Expected plaintext password to be hashed and accepted")
+	}
+}
+
+func TestBasicAuthRequiresCredentials(t *testing.T) {
+	if _, err := newBasicAuth(config.DashboardAuthConfig{Enabled: true, Username: "admin"}); err == nil {
+		t.Error("Expected error when password is empty")
+	}
+}
+
+func TestBearerTokens(t *testing.T) {
+	auth, err := newBasicAuth(config.DashboardAuthConfig{
+		Enabled:  true,
+		Username: "admin",
+		Password: "plaintext",
+		Tokens: []config.APITokenConfig{
+			{Name: "ci", Token: "ci-token"},
+			{Name: "team-a", Token: "team-a-token", Namespace: "team-a"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create basic auth: %v", err)
+	}
+	server := &Server{auth: auth}
+
+	var principal, namespace string
+	handler := server.requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, namespace = requestPrincipal(r), requestNamespace(r)
+	}))
+
+	tests := []struct {
+		header        string
+		wantStatus    int
+		wantPrincipal string
+		wantNamespace string
+	}{
+		{"Bearer team-a-token", http.StatusOK, "token:team-a", "team-a"},
+		{"bearer ci-token", http.StatusOK, "token:ci", ""},
+		{"Bearer wrong", http.StatusUnauthorized, "", ""},
+	}
+	for _, tt := range tests {
+		principal, namespace = "", ""
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs", nil)
+		req.Header.Set("Authorization", tt.header)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus || principal != tt.wantPrincipal || namespace != tt.wantNamespace {
+			t.Errorf("%q: status %d, principal %q, namespace %q, want %d, %q, %q",
+				tt.header, rec.Code, principal, namespace, tt.wantStatus, tt.wantPrincipal, tt.wantNamespace)
+		}
+	}
+}
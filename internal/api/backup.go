@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// handleBackup streams a consistent snapshot of the database
+func (s *Server) handleBackup(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		s.writeError(w, http.StatusForbidden, fmt.Errorf("database backups are only taken by authenticated or local users"))
+		return
+	}
+	s.audit(r, AuditActionDatabaseBackup, "database", nil)
+
+	name := "arcron-" + time.Now().UTC().Format("20060102T150405Z") + s.store.BackupExtension()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+
+	// Once streaming started the status is sent, so a failure can only
+	// cut the response short
+	if err := s.store.Backup(w); err != nil {
+		logrus.Errorf("Failed to stream database backup: %v", err)
+	}
+}
+
+// handleRestore replaces the database with a backup sent as the request
+// body, of at most database.backup.max_restore_size bytes. Restart Arcron
+// afterwards so every component reloads its state.
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		s.writeError(w, http.StatusForbidden, fmt.Errorf("the database is only restored by authenticated or local users"))
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, s.config.Database.Backup.MaxRestoreSize)
+	err := s.store.Restore(body)
+	s.audit(r, AuditActionDatabaseRestore, "database", nil)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("failed to restore backup: %v", err))
+		return
+	}
+
+	s.writeSuccess(w, map[string]interface{}{
+		"message": "Database restored; restart to reload scheduler state",
+	})
+}
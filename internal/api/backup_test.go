@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+func TestBackupRequiresAdmin(t *testing.T) {
+	server := &Server{config: &config.Config{}}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		handler http.HandlerFunc
+	}{
+		{"backup", http.MethodGet, "/api/v1/admin/backup", server.handleBackup},
+		{"restore", http.MethodPost, "/api/v1/admin/restore", server.handleRestore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Without dashboard authentication only local users are admins
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader("not a database"))
+			req.RemoteAddr = "203.0.113.7:52100"
+			rec := httptest.NewRecorder()
+			tt.handler(rec, req)
+
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d for a remote caller", rec.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+const (
+	// calendarDays is how far ahead the calendar feed reaches by default
+	calendarDays = 7
+	// maxCalendarDays bounds how far ahead the calendar feed may reach
+	maxCalendarDays = 31
+	// calendarRunsPerJob caps the events of a job, e.g. one running every
+	// minute
+	calendarRunsPerJob = 200
+	// calendarDurationSamples is how many recent executions the length of
+	// events is averaged over
+	calendarDurationSamples = 20
+	// icsTimeFormat is the UTC date-time format of iCalendar
+	icsTimeFormat = "20060102T150405Z"
+)
+
+// handleGetCalendar serves the upcoming runs of all jobs, adjusted runs at
+// their new time, as an iCalendar feed calendar applications subscribe to.
+// Events last as long as recent runs of the job took on average.
+func (s *Server) handleGetCalendar(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	days := calendarDays
+	if daysStr := query.Get("days"); daysStr != "" {
+		parsed, err := strconv.Atoi(daysStr)
+		if err != nil || parsed <= 0 || parsed > maxCalendarDays {
+			s.writeError(w, http.StatusBadRequest, invalidField("days", "invalid days: %s (1-%d)", daysStr, maxCalendarDays))
+			return
+		}
+		days = parsed
+	}
+	only := query["job"]
+
+	now := time.Now()
+	end := now.AddDate(0, 0, days)
+	maintenance := s.scheduler.Maintenance()
+
+	allJobs := s.jobManager.GetAllJobs()
+	var names []string
+	for name, job := range allJobs {
+		if inNamespace(r, job.GetNamespace()) && (len(only) == 0 || slices.Contains(only, name)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	calendar := &icsWriter{}
+	calendar.line("BEGIN", "VCALENDAR")
+	calendar.line("VERSION", "2.0")
+	calendar.line("PRODID", "-//arcron//scheduled runs//EN")
+	calendar.line("CALSCALE", "GREGORIAN")
+	calendar.line("METHOD", "PUBLISH")
+	calendar.text("X-WR-CALNAME", "arcron scheduled runs")
+	for _, name := range names {
+		job := allJobs[name]
+		runs, scheduled := s.scheduler.NextRuns(name, calendarRunsPerJob)
+		if !scheduled {
+			continue
+		}
+		length := s.expectedDuration(name)
+
+		for _, run := range runs {
+			if run.Time.After(end) {
+				break
+			}
+			description := []string{
+				"Schedule: " + job.GetSchedule(),
+				"Type: " + job.GetType(),
+				"Namespace: " + job.GetNamespace(),
+			}
+			if run.Adjusted {
+				description = append(description,
+					"Moved by the intelligent scheduler from "+run.Scheduled.UTC().Format(time.RFC3339))
+			}
+			status := "CONFIRMED"
+			if maintenance.Paused {
+				// The run is skipped unless the scheduler is resumed first
+				status = "TENTATIVE"
+				description = append(description, fmt.Sprintf("Scheduler paused for maintenance since %s: %s",
+					maintenance.Since.UTC().Format(time.RFC3339), maintenance.Reason))
+			}
+
+			calendar.line("BEGIN", "VEVENT")
+			calendar.line("UID", fmt.Sprintf("%s-%d@arcron", name, run.Scheduled.Unix()))
+			calendar.line("DTSTAMP", now.UTC().Format(icsTimeFormat))
+			calendar.line("DTSTART", run.Time.UTC().Format(icsTimeFormat))
+			calendar.line("DTEND", run.Time.Add(length).UTC().Format(icsTimeFormat))
+			calendar.text("SUMMARY", name)
+			calendar.text("DESCRIPTION", strings.Join(description, "\n"))
+			calendar.text("CATEGORIES", job.GetType())
+			calendar.line("STATUS", status)
+			calendar.line("TRANSP", "TRANSPARENT")
+			calendar.line("END", "VEVENT")
+		}
+	}
+	calendar.line("END", "VCALENDAR")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="arcron.ics"`)
+	w.Write([]byte(calendar.String()))
+}
+
+// expectedDuration returns how long recent completed runs of a job took on
+// average, rounded to the minute and at least one
+func (s *Server) expectedDuration(jobName string) time.Duration {
+	executions, _ := s.jobManager.GetJobExecutions(jobName, calendarDurationSamples)
+	var total float64
+	var completed int
+	for _, execution := range executions {
+		if execution.Status == types.StatusCompleted {
+			total += execution.Duration
+			completed++
+		}
+	}
+	if completed == 0 {
+		return time.Minute
+	}
+	average := time.Duration(total / float64(completed) * float64(time.Second))
+	return max(average.Round(time.Minute), time.Minute)
+}
+
+// icsWriter writes iCalendar content lines, folded at 75 octets and ended
+// with CRLF as RFC 5545 requires
+type icsWriter struct {
+	strings.Builder
+}
+
+// line writes a property with a value that needs no escaping
+func (c *icsWriter) line(name, value string) {
+	content := name + ":" + value
+	// Continuation lines start with a space counting towards their length
+	for limit := 75; len(content) > limit; limit = 74 {
+		cut := limit
+		for !utf8Start(content[cut]) {
+			cut--
+		}
+		c.WriteString(content[:cut] + "\r\n ")
+		content = content[cut:]
+	}
+	c.WriteString(content + "\r\n")
+}
+
+// text writes a property with a text value, escaping it
+func (c *icsWriter) text(name, value string) {
+	c.line(name, strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value))
+}
+
+// utf8Start reports whether a byte starts a UTF-8 encoded character
+func utf8Start(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestICSWriterFoldsAndEscapes(t *testing.T) {
+	var calendar icsWriter
+	calendar.text("DESCRIPTION", "Schedule: 0 2 * * *\nNote; with, "+strings.Repeat("é", 60))
+	output := calendar.String()
+
+	lines := strings.Split(strings.TrimSuffix(output, "\r\n"), "\r\n")
+	if len(lines) < 2 {
+		t.Fatalf("output = %q, want the long line folded", output)
+	}
+	for i, line := range lines {
+		if len(line) > 75 {
+			t.Errorf("line %d has %d octets, want at most 75", i, len(line))
+		}
+		if i > 0 && !strings.HasPrefix(line, " ") {
+			t.Errorf("continuation line %d = %q, want a leading space", i, line)
+		}
+	}
+
+	unfolded := strings.ReplaceAll(output, "\r\n ", "")
+	want := `DESCRIPTION:Schedule: 0 2 * * *\nNote\; with\, ` + strings.Repeat("é", 60) + "\r\n"
+	if unfolded != want {
+		t.Errorf("unfolded = %q, want %q", unfolded, want)
+	}
+}
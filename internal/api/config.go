@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+// handleGetConfig returns the effective configuration with secrets
+// redacted: after defaults, overrides and jobs directory merging. It is
+// wrapped in the usual response as JSON, or returned as a configuration
+// file with ?format=yaml, json or toml.
+func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		s.writeError(w, http.StatusForbidden, fmt.Errorf("the configuration is only shown to authenticated or local users"))
+		return
+	}
+
+	// The jobs change as the jobs directory is reloaded and jobs are
+	// synced through the API, so they are read from the scheduler
+	cfg := *s.config
+	cfg.Jobs = s.scheduler.Jobs()
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		settings, err := config.Redacted(&cfg)
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		s.writeSuccess(w, settings)
+		return
+	}
+
+	switch format {
+	case config.FormatYAML, config.FormatJSON, config.FormatTOML:
+	default:
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("unknown config format %q, use yaml, json or toml", format))
+		return
+	}
+	data, err := config.Dump(&cfg, format)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(data)
+}
+
+// isAdmin reports whether a request may use admin endpoints exposing
+// sensitive data: any authenticated request not limited to a namespace,
+// or without dashboard authentication only requests from the local host
+func (s *Server) isAdmin(r *http.Request) bool {
+	if s.auth != nil {
+		return requestNamespace(r) == "" // requireAuth has checked the credentials
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error codes tell clients what kind of error a request failed with,
+// independently of the message
+const (
+	ErrorValidationFailed = "validation_failed"
+	ErrorUnauthorized     = "unauthorized"
+	ErrorForbidden        = "forbidden"
+	ErrorNotFound         = "not_found"
+	ErrorConflict         = "conflict"
+	ErrorUnavailable      = "unavailable"
+	ErrorUpstreamFailed   = "upstream_failed"
+	ErrorInternal         = "internal"
+)
+
+// APIError is the error of a failed request
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Fields lists the invalid fields of requests failing validation
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError is a problem with one field of a request body or one query
+// parameter
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// fieldErrors is the error of a request with invalid fields, answered with
+// every problem found rather than the first
+type fieldErrors []FieldError
+
+func (e fieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, field := range e {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// add records a problem with a field
+func (e *fieldErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns the problems as an error, or nil if there are none
+func (e fieldErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// invalidField returns the error of a request with one invalid field
+func invalidField(field, format string, args ...interface{}) error {
+	var problems fieldErrors
+	problems.add(field, format, args...)
+	return problems
+}
+
+// errorCode returns the code of errors answered with a status
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrorValidationFailed
+	case http.StatusUnauthorized:
+		return ErrorUnauthorized
+	case http.StatusForbidden:
+		return ErrorForbidden
+	case http.StatusNotFound:
+		return ErrorNotFound
+	case http.StatusConflict:
+		return ErrorConflict
+	case http.StatusServiceUnavailable:
+		return ErrorUnavailable
+	case http.StatusBadGateway:
+		return ErrorUpstreamFailed
+	}
+	return ErrorInternal
+}
+
+// newAPIError describes an error answered with a status
+func newAPIError(status int, err error) *APIError {
+	apiErr := &APIError{Code: errorCode(status), Message: err.Error()}
+	var problems fieldErrors
+	if errors.As(err, &problems) {
+		apiErr.Code = ErrorValidationFailed
+		apiErr.Fields = problems
+	}
+	return apiErr
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorResponses(t *testing.T) {
+	server := &Server{}
+	var problems fieldErrors
+	problems.add("level", "unknown level: loud")
+	problems.add("duration", "invalid duration: soon")
+
+	tests := []struct {
+		name      string
+		requestID string
+		status    int
+		err       error
+		code      string
+		fields    int
+	}{
+		{"not found", "", http.StatusNotFound, errors.New("job not found: backup"), ErrorNotFound, 0},
+		{"conflict", "", http.StatusConflict, errors.New("dead letter 3 was already redriven"), ErrorConflict, 0},
+		{"internal", "", http.StatusInternalServerError, errors.New("database is locked"), ErrorInternal, 0},
+		{"invalid parameter", "client-id-1", http.StatusBadRequest, invalidField("limit", "invalid limit: x"), ErrorValidationFailed, 1},
+		{"invalid fields", "client id", http.StatusBadRequest, problems, ErrorValidationFailed, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := server.assignRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				server.writeError(w, tt.status, tt.err)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/jobs", nil)
+			if tt.requestID != "" {
+				req.Header.Set(requestIDHeader, tt.requestID)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			var response struct {
+				Success   bool      `json:"success"`
+				Error     *APIError `json:"error"`
+				RequestID string    `json:"request_id"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if rec.Code != tt.status || response.Success || response.Error == nil {
+				t.Fatalf("status = %d, response = %+v, want a %d error", rec.Code, response, tt.status)
+			}
+			if response.Error.Code != tt.code || response.Error.Message != tt.err.Error() || len(response.Error.Fields) != tt.fields {
+				t.Errorf("error = %+v, want code %s, message %q and %d fields", response.Error, tt.code, tt.err, tt.fields)
+			}
+
+			header := rec.Header().Get(requestIDHeader)
+			if header == "" || response.RequestID != header {
+				t.Errorf("request ID = %q, header = %q, want the same ID in both", response.RequestID, header)
+			}
+			// IDs with spaces are not echoed
+			if validRequestID(tt.requestID) && header != tt.requestID {
+				t.Errorf("request ID = %q, want the client's %q", header, tt.requestID)
+			}
+			if strings.Contains(header, " ") {
+				t.Errorf("request ID = %q, want no spaces", header)
+			}
+		})
+	}
+}
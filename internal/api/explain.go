@@ -0,0 +1,23 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// handleMLExplain returns what drove the scheduling decisions for a job:
+// the latest prediction with its feature contributions or heuristic branch,
+// and the last schedule adjustment it caused
+func (s *Server) handleMLExplain(w http.ResponseWriter, r *http.Request) {
+	jobName := mux.Vars(r)["jobName"]
+
+	explanation, exists := s.scheduler.Explain(jobName)
+	if !exists {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", jobName))
+		return
+	}
+
+	s.writeSuccess(w, explanation)
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/makalin/arcron/internal/export"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// handleExportJobExecutions streams the full execution history of a job
+// with the resource usage of each run, as CSV (default) or JSON
+func (s *Server) handleExportJobExecutions(w http.ResponseWriter, r *http.Request) {
+	jobName := mux.Vars(r)["name"]
+	if _, exists := s.jobManager.GetJob(jobName); !exists {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", jobName))
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if err := export.CheckFormat(format); err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", jobName+"-executions."+format))
+
+	// Once streaming started the status is sent, so a failure can only
+	// cut the response short
+	store := s.store.WithContext(r.Context())
+	if err := export.Executions(w, format, jobName, store, store); err != nil {
+		logrus.Errorf("Failed to export executions of job %s: %v", jobName, err)
+	}
+}
+
+// Number of previous runs an execution is compared with, by default and
+// at most
+const (
+	defaultCompareRuns = 10
+	maxCompareRuns     = 100
+)
+
+// handleCompareExecution compares a finished execution with the previous
+// ?runs= runs of its job: changes of duration, output size, exit code and
+// resource usage, flagging a regression when the duration grew by more
+// than ?threshold= percent, advanced.regression_threshold by default
+func (s *Server) handleCompareExecution(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	query := r.URL.Query()
+
+	runs := defaultCompareRuns
+	if runsStr := query.Get("runs"); runsStr != "" {
+		parsed, err := strconv.Atoi(runsStr)
+		if err != nil || parsed <= 0 || parsed > maxCompareRuns {
+			s.writeError(w, http.StatusBadRequest, invalidField("runs", "invalid runs: %s, expected 1 to %d", runsStr, maxCompareRuns))
+			return
+		}
+		runs = parsed
+	}
+	threshold := s.config.Advanced.RegressionThreshold
+	if thresholdStr := query.Get("threshold"); thresholdStr != "" {
+		parsed, err := strconv.ParseFloat(thresholdStr, 64)
+		if err != nil || parsed < 0 {
+			s.writeError(w, http.StatusBadRequest, invalidField("threshold", "invalid threshold: %s", thresholdStr))
+			return
+		}
+		threshold = parsed
+	}
+
+	store := s.store.WithContext(r.Context())
+	execution, err := store.GetJobExecution(id)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if execution == nil || !inNamespace(r, execution.Namespace) {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("execution not found: %s", id))
+		return
+	}
+	if execution.Status != types.StatusCompleted && execution.Status != types.StatusFailed {
+		s.writeError(w, http.StatusConflict, fmt.Errorf("execution %s has not finished", id))
+		return
+	}
+
+	comparison, err := export.Compare(execution, runs, threshold, store, store)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.writeSuccess(w, comparison)
+}
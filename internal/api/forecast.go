@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// maxForecastHorizon bounds how far ahead load can be forecast
+const maxForecastHorizon = 7 * 24 * time.Hour
+
+// handleMLForecast returns the predicted load per interval with confidence
+// bands. horizon defaults to the configured forecast horizon.
+func (s *Server) handleMLForecast(w http.ResponseWriter, r *http.Request) {
+	horizon := s.config.ML.ForecastHorizon
+	if horizonStr := r.URL.Query().Get("horizon"); horizonStr != "" {
+		parsed, err := time.ParseDuration(horizonStr)
+		if err != nil || parsed < s.config.ML.ForecastInterval || parsed > maxForecastHorizon {
+			s.writeError(w, http.StatusBadRequest, invalidField("horizon", "invalid horizon: %s (must be between %s and %s)",
+				horizonStr, s.config.ML.ForecastInterval, maxForecastHorizon))
+			return
+		}
+		horizon = parsed
+	}
+
+	forecast, err := s.mlEngine.Forecast(horizon)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.writeSuccess(w, map[string]interface{}{
+		"horizon":      horizon.String(),
+		"interval":     s.config.ML.ForecastInterval.String(),
+		"generated_at": forecast.GeneratedAt,
+		"points":       forecast.Points,
+	})
+}
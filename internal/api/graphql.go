@@ -0,0 +1,253 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/makalin/arcron/internal/graphql"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// maxGraphQLLimit bounds the limit arguments of list fields
+const maxGraphQLLimit = 1000
+
+// handleGraphQL answers GraphQL queries over jobs, executions, metrics,
+// anomalies and alerts, for views that need several of them at once.
+// Queries are posted as JSON or passed as query parameters with GET.
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var request graphql.Request
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			s.writeGraphQLError(w, fmt.Errorf("invalid request body: %v", err))
+			return
+		}
+	} else {
+		query := r.URL.Query()
+		request.Query = query.Get("query")
+		request.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				s.writeGraphQLError(w, fmt.Errorf("invalid variables: %v", err))
+				return
+			}
+		}
+	}
+	if request.Query == "" {
+		s.writeGraphQLError(w, fmt.Errorf("query is required"))
+		return
+	}
+
+	response, err := graphql.Execute(r.Context(), s.graphQLSchema(r), request)
+	if err != nil {
+		s.writeGraphQLError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+// writeGraphQLError answers a request that could not be executed the way
+// GraphQL clients expect errors
+func (s *Server) writeGraphQLError(w http.ResponseWriter, err error) {
+	s.writeJSON(w, http.StatusBadRequest, graphql.Response{
+		Errors: []*graphql.Error{{Message: err.Error()}},
+	})
+}
+
+// graphQLSchema returns the schema queries of a request run against,
+// scoped to the namespace of the request
+func (s *Server) graphQLSchema(r *http.Request) *graphql.Object {
+	artifact := &graphql.Object{Name: "Artifact", Fields: graphql.JSONFields(types.Artifact{})}
+	alert := &graphql.Object{Name: "Alert", Fields: graphql.JSONFields(types.AlertEntry{})}
+	anomaly := &graphql.Object{Name: "Anomaly", Fields: graphql.JSONFields(types.Anomaly{})}
+	metrics := &graphql.Object{Name: "SystemMetrics", Fields: graphql.JSONFields(types.SystemMetrics{})}
+	nextRun := &graphql.Object{Name: "NextRun", Fields: map[string]*graphql.Field{
+		"time": {}, "scheduled": {}, "adjusted": {},
+	}}
+
+	execution := &graphql.Object{Name: "Execution", Fields: graphql.JSONFields(types.JobExecution{})}
+	execution.Fields["artifacts"] = &graphql.Field{Type: artifact, Resolve: func(p graphql.Params) (interface{}, error) {
+		return s.jobManager.Artifacts(p.Source.(*types.JobExecution).ID)
+	}}
+
+	job := &graphql.Object{Name: "Job", Fields: map[string]*graphql.Field{
+		"name": {}, "namespace": {}, "type": {}, "schedule": {}, "status": {},
+		"next_run": {}, "last_run": {}, "run_count": {},
+	}}
+	job.Fields["executions"] = &graphql.Field{Type: execution, Args: []string{"limit"}, Resolve: func(p graphql.Params) (interface{}, error) {
+		limit, err := graphQLLimit(p, 10)
+		if err != nil {
+			return nil, err
+		}
+		return s.jobManager.GetJobExecutions(graphQLJobName(p), limit)
+	}}
+	job.Fields["next_runs"] = &graphql.Field{Type: nextRun, Args: []string{"count"}, Resolve: func(p graphql.Params) (interface{}, error) {
+		count, err := p.Int("count", 10)
+		if err != nil {
+			return nil, err
+		}
+		if count <= 0 || count > maxNextRuns {
+			return nil, fmt.Errorf("invalid count: %d (1-%d)", count, maxNextRuns)
+		}
+		runs, _ := s.scheduler.NextRuns(graphQLJobName(p), count)
+		return runs, nil
+	}}
+	job.Fields["alerts"] = &graphql.Field{Type: alert, Args: []string{"status", "limit"}, Resolve: func(p graphql.Params) (interface{}, error) {
+		status, err := p.String("status", "")
+		if err != nil {
+			return nil, err
+		}
+		limit, err := graphQLLimit(p, 10)
+		if err != nil {
+			return nil, err
+		}
+		return s.store.GetAlerts(storage.AlertFilter{JobName: graphQLJobName(p), Status: status, Limit: limit})
+	}}
+
+	return &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"jobs": {Type: job, Args: []string{"namespace"}, Resolve: func(p graphql.Params) (interface{}, error) {
+			namespace, err := p.String("namespace", "")
+			if err != nil {
+				return nil, err
+			}
+			var list []map[string]interface{}
+			for _, j := range s.jobManager.GetAllJobs() {
+				if inNamespace(r, j.GetNamespace()) && (namespace == "" || j.GetNamespace() == namespace) {
+					list = append(list, s.graphQLJob(j))
+				}
+			}
+			sort.Slice(list, func(a, b int) bool {
+				return list[a]["name"].(string) < list[b]["name"].(string)
+			})
+			return list, nil
+		}},
+		"job": {Type: job, Args: []string{"name"}, Resolve: func(p graphql.Params) (interface{}, error) {
+			name, err := p.String("name", "")
+			if err != nil {
+				return nil, err
+			}
+			j, exists := s.jobManager.GetJob(name)
+			if !exists || !inNamespace(r, j.GetNamespace()) {
+				return nil, nil
+			}
+			return s.graphQLJob(j), nil
+		}},
+		"execution": {Type: execution, Args: []string{"id"}, Resolve: func(p graphql.Params) (interface{}, error) {
+			id, err := p.String("id", "")
+			if err != nil {
+				return nil, err
+			}
+			found, err := s.jobManager.GetExecution(id)
+			if err != nil || found == nil || !inNamespace(r, found.Namespace) {
+				return nil, err
+			}
+			return found, nil
+		}},
+		"metrics": {Type: metrics, Args: []string{"start", "end", "limit"}, Resolve: func(p graphql.Params) (interface{}, error) {
+			start, err := p.Time("start", time.Now().Add(-24*time.Hour))
+			if err != nil {
+				return nil, err
+			}
+			end, err := p.Time("end", time.Now())
+			if err != nil {
+				return nil, err
+			}
+			limit, err := graphQLLimit(p, maxGraphQLLimit)
+			if err != nil {
+				return nil, err
+			}
+			return s.store.WithContext(p.Context).GetSystemMetrics(start, end, limit)
+		}},
+		"anomalies": {Type: anomaly, Args: []string{"type", "severity", "since", "until", "limit"}, Resolve: func(p graphql.Params) (interface{}, error) {
+			var filter storage.AnomalyFilter
+			var err error
+			if filter.Type, err = p.String("type", ""); err != nil {
+				return nil, err
+			}
+			if severity, err := p.String("severity", ""); err != nil {
+				return nil, err
+			} else if severity != "" {
+				if filter.Severities, err = ml.SeveritiesAtLeast(severity); err != nil {
+					return nil, err
+				}
+			}
+			if filter.Since, err = p.Time("since", time.Time{}); err != nil {
+				return nil, err
+			}
+			if filter.Until, err = p.Time("until", time.Time{}); err != nil {
+				return nil, err
+			}
+			if filter.Limit, err = graphQLLimit(p, 100); err != nil {
+				return nil, err
+			}
+			return s.store.GetAnomalies(filter)
+		}},
+		"alerts": {Type: alert, Args: []string{"job", "level", "status", "event", "namespace", "since", "until", "limit"}, Resolve: func(p graphql.Params) (interface{}, error) {
+			var filter storage.AlertFilter
+			var err error
+			for arg, value := range map[string]*string{
+				"job":       &filter.JobName,
+				"level":     &filter.Level,
+				"status":    &filter.Status,
+				"event":     &filter.Event,
+				"namespace": &filter.Namespace,
+			} {
+				if *value, err = p.String(arg, ""); err != nil {
+					return nil, err
+				}
+			}
+			if namespace := requestNamespace(r); namespace != "" {
+				filter.Namespace = namespace
+			}
+			if filter.Since, err = p.Time("since", time.Time{}); err != nil {
+				return nil, err
+			}
+			if filter.Until, err = p.Time("until", time.Time{}); err != nil {
+				return nil, err
+			}
+			if filter.Limit, err = graphQLLimit(p, 100); err != nil {
+				return nil, err
+			}
+			return s.store.GetAlerts(filter)
+		}},
+	}}
+}
+
+// graphQLJob returns the fields of a job as the jobs endpoint lists them
+func (s *Server) graphQLJob(job *jobs.Job) map[string]interface{} {
+	fields := map[string]interface{}{
+		"name":      job.GetName(),
+		"namespace": job.GetNamespace(),
+		"type":      job.GetType(),
+		"schedule":  job.GetSchedule(),
+		"status":    job.GetStatus(),
+	}
+	if scheduledJob, _ := s.scheduler.GetJobStatus(job.GetName()); scheduledJob != nil {
+		fields["next_run"] = scheduledJob.NextRun
+		fields["last_run"] = scheduledJob.LastRun
+		fields["run_count"] = scheduledJob.RunCount
+	}
+	return fields
+}
+
+// graphQLJobName returns the name of the job a field is selected on
+func graphQLJobName(p graphql.Params) string {
+	return p.Source.(map[string]interface{})["name"].(string)
+}
+
+// graphQLLimit returns the limit argument of a list field
+func graphQLLimit(p graphql.Params, def int) (int, error) {
+	limit, err := p.Int("limit", def)
+	if err != nil {
+		return 0, err
+	}
+	if limit <= 0 || limit > maxGraphQLLimit {
+		return 0, fmt.Errorf("invalid limit: %d (1-%d)", limit, maxGraphQLLimit)
+	}
+	return limit, nil
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/makalin/arcron/internal/version"
+)
+
+// Health states reported for arcron and its components
+const (
+	HealthHealthy   = "healthy"
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+)
+
+// ComponentHealth represents the health of a single component
+type ComponentHealth struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// checkComponents checks the health of storage, scheduler and monitor
+// and returns the overall status, which is the worst component status
+func (s *Server) checkComponents() (string, map[string]ComponentHealth) {
+	components := map[string]ComponentHealth{
+		"storage":   s.checkStorage(),
+		"scheduler": s.checkScheduler(),
+		"monitor":   s.checkMonitor(),
+	}
+
+	overall := HealthHealthy
+	for _, component := range components {
+		switch component.Status {
+		case HealthUnhealthy:
+			overall = HealthUnhealthy
+		case HealthDegraded:
+			if overall == HealthHealthy {
+				overall = HealthDegraded
+			}
+		}
+	}
+
+	return overall, components
+}
+
+func (s *Server) checkStorage() ComponentHealth {
+	if err := s.store.Ping(); err != nil {
+		return ComponentHealth{Status: HealthUnhealthy, Message: fmt.Sprintf("database unreachable: %v", err)}
+	}
+	return ComponentHealth{Status: HealthHealthy}
+}
+
+// checkScheduler reports a paused scheduler as healthy, so the instance
+// stays reachable to be resumed, but says so in the message
+func (s *Server) checkScheduler() ComponentHealth {
+	if !s.scheduler.IsRunning() {
+		return ComponentHealth{Status: HealthUnhealthy, Message: "scheduler is not running"}
+	}
+	if maintenance := s.scheduler.Maintenance(); maintenance.Paused {
+		return ComponentHealth{Status: HealthHealthy, Message: fmt.Sprintf("paused for maintenance since %s",
+			maintenance.Since.Format(time.RFC3339))}
+	}
+	return ComponentHealth{Status: HealthHealthy}
+}
+
+// checkMonitor reports the monitor degraded while its metrics are stale,
+// as jobs then run on their cron schedules
+func (s *Server) checkMonitor() ComponentHealth {
+	if !s.monitor.IsRunning() {
+		return ComponentHealth{Status: HealthDegraded, Message: "monitor is not running"}
+	}
+	if s.monitor.GetLastMetrics() == nil {
+		return ComponentHealth{Status: HealthDegraded, Message: "no metrics collected yet"}
+	}
+	if !s.monitor.MetricsStale() {
+		return ComponentHealth{Status: HealthHealthy}
+	}
+
+	message := fmt.Sprintf("metrics are stale (last collected %s ago)", s.monitor.MetricsAge().Round(time.Second))
+	if err := s.monitor.LastCollectionError(); err != nil {
+		message += fmt.Sprintf(": %v", err)
+	}
+	return ComponentHealth{Status: HealthDegraded, Message: message}
+}
+
+// checkWatchdog fails while a component is unhealthy, so systemd restarts
+// arcron if it does not recover within the watchdog interval
+func (s *Server) checkWatchdog() error {
+	status, components := s.checkComponents()
+	if status != HealthUnhealthy {
+		return nil
+	}
+
+	var problems []string
+	for name, component := range components {
+		if component.Status == HealthUnhealthy {
+			problems = append(problems, fmt.Sprintf("%s: %s", name, component.Message))
+		}
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("%s", strings.Join(problems, "; "))
+}
+
+// Health check handler. Responds with 503 when any component is
+// unhealthy so that load balancers stop routing to this instance.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	status, components := s.checkComponents()
+
+	code := http.StatusOK
+	if status == HealthUnhealthy {
+		code = http.StatusServiceUnavailable
+	}
+
+	s.writeJSON(w, code, Response{
+		Success: status != HealthUnhealthy,
+		Data: map[string]interface{}{
+			"status":      status,
+			"version":     version.Get(),
+			"started_at":  version.StartTime(),
+			"uptime":      version.Uptime().Round(time.Second).String(),
+			"maintenance": s.scheduler.Maintenance(),
+			"components":  components,
+		},
+		RequestID: w.Header().Get(requestIDHeader),
+	})
+}
+
+// Liveness handler. Reports whether the process is able to serve
+// requests at all; a failure means the process should be restarted.
+func (s *Server) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	s.writeSuccess(w, map[string]interface{}{
+		"status": "alive",
+		"uptime": version.Uptime().Round(time.Second).String(),
+	})
+}
+
+// checkDraining fails once shutdown began draining the running jobs, so
+// Kubernetes stops routing requests to the instance
+func (s *Server) checkDraining() ComponentHealth {
+	if s.jobManager.Draining() {
+		return ComponentHealth{Status: HealthUnhealthy, Message: "draining for shutdown"}
+	}
+	return ComponentHealth{Status: HealthHealthy}
+}
+
+// Readiness handler. Reports whether arcron is ready to take traffic:
+// the database is reachable, the scheduler is started, the monitor is
+// collecting metrics and shutdown has not begun.
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]ComponentHealth{
+		"storage":   s.checkStorage(),
+		"scheduler": s.checkScheduler(),
+		"monitor":   s.checkMonitor(),
+		"draining":  s.checkDraining(),
+	}
+
+	ready := true
+	for _, check := range checks {
+		if check.Status != HealthHealthy {
+			ready = false
+		}
+	}
+
+	code := http.StatusOK
+	status := "ready"
+	if !ready {
+		code = http.StatusServiceUnavailable
+		status = "not_ready"
+	}
+
+	s.writeJSON(w, code, Response{
+		Success: ready,
+		Data: map[string]interface{}{
+			"status": status,
+			"checks": checks,
+		},
+		RequestID: w.Header().Get(requestIDHeader),
+	})
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+// healthResponse is the body of the health and readiness endpoints
+type healthResponse struct {
+	Success bool `json:"success"`
+	Data    struct {
+		Status     string                     `json:"status"`
+		Components map[string]ComponentHealth `json:"components"`
+		Checks     map[string]ComponentHealth `json:"checks"`
+	} `json:"data"`
+}
+
+// getHealth requests a health endpoint through the router
+func getHealth(t *testing.T, server *Server, path string) (int, healthResponse) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var body healthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("GET %s returned invalid JSON %q: %v", path, rec.Body.String(), err)
+	}
+	return rec.Code, body
+}
+
+// startScheduler starts the scheduler until the test ends
+func startScheduler(t *testing.T, server *Server) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := server.scheduler.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(server.scheduler.Stop)
+}
+
+// waitForMetrics waits until the monitor collected a sample
+func waitForMetrics(t *testing.T, server *Server) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); server.monitor.GetLastMetrics() == nil; {
+		if time.Now().After(deadline) {
+			t.Fatal("no metrics collected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHealthComponentStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(t *testing.T, server *Server)
+		wantCode   int
+		wantStatus string
+		components map[string]string
+	}{
+		{
+			name: "healthy",
+			setup: func(t *testing.T, server *Server) {
+				startScheduler(t, server)
+				startMonitor(t, server)
+				waitForMetrics(t, server)
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: HealthHealthy,
+			components: map[string]string{"storage": HealthHealthy, "scheduler": HealthHealthy, "monitor": HealthHealthy},
+		},
+		{
+			name: "monitor not running",
+			setup: func(t *testing.T, server *Server) {
+				startScheduler(t, server)
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: HealthDegraded,
+			components: map[string]string{"storage": HealthHealthy, "scheduler": HealthHealthy, "monitor": HealthDegraded},
+		},
+		{
+			name: "scheduler not running",
+			setup: func(t *testing.T, server *Server) {
+				startMonitor(t, server)
+				waitForMetrics(t, server)
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: HealthUnhealthy,
+			components: map[string]string{"storage": HealthHealthy, "scheduler": HealthUnhealthy, "monitor": HealthHealthy},
+		},
+		{
+			name: "database closed",
+			setup: func(t *testing.T, server *Server) {
+				startScheduler(t, server)
+				server.store.Close()
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: HealthUnhealthy,
+			components: map[string]string{"storage": HealthUnhealthy, "scheduler": HealthHealthy, "monitor": HealthDegraded},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, &config.Config{})
+			tt.setup(t, server)
+
+			code, body := getHealth(t, server, "/health")
+			if code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", code, tt.wantCode)
+			}
+			if body.Data.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", body.Data.Status, tt.wantStatus)
+			}
+			if body.Success != (tt.wantStatus != HealthUnhealthy) {
+				t.Errorf("success = %v with status %q", body.Success, body.Data.Status)
+			}
+			for name, want := range tt.components {
+				if got := body.Data.Components[name].Status; got != want {
+					t.Errorf("%s status = %q (%s), want %q", name, got, body.Data.Components[name].Message, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	// Alive even with nothing started, so a slow start is not restarted
+	server := newTestServer(t, &config.Config{})
+
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /healthz status code = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var body struct {
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("GET /healthz returned invalid JSON: %v", err)
+	}
+	if body.Data["status"] != "alive" {
+		t.Errorf("status = %q, want alive", body.Data["status"])
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	server := newTestServer(t, &config.Config{})
+
+	// Not ready until the scheduler runs and metrics are collected
+	code, body := getHealth(t, server, "/readyz")
+	if code != http.StatusServiceUnavailable || body.Data.Status != "not_ready" {
+		t.Errorf("GET /readyz before start = %d %q, want 503 not_ready", code, body.Data.Status)
+	}
+
+	startScheduler(t, server)
+	startMonitor(t, server)
+	waitForMetrics(t, server)
+	code, body = getHealth(t, server, "/readyz")
+	if code != http.StatusOK || body.Data.Status != "ready" || !body.Success {
+		t.Fatalf("GET /readyz = %d %q, want 200 ready; checks %+v", code, body.Data.Status, body.Data.Checks)
+	}
+
+	// Draining for shutdown takes the instance out of rotation
+	if err := server.jobManager.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	code, body = getHealth(t, server, "/readyz")
+	if code != http.StatusServiceUnavailable || body.Data.Status != "not_ready" || body.Success {
+		t.Errorf("GET /readyz while draining = %d %q, want 503 not_ready", code, body.Data.Status)
+	}
+	if check := body.Data.Checks["draining"]; check.Status != HealthUnhealthy {
+		t.Errorf("draining check = %+v, want unhealthy", check)
+	}
+	for _, name := range []string{"storage", "scheduler", "monitor"} {
+		if check := body.Data.Checks[name]; check.Status != HealthHealthy {
+			t.Errorf("%s check while draining = %+v, want healthy", name, check)
+		}
+	}
+
+	// Liveness is unaffected by the drain
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /healthz while draining status code = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/types"
+)
+
+const (
+	// impactWindow is how far back impact scores are averaged without since
+	impactWindow = 7 * 24 * time.Hour
+	// impactLimit is how many recent impact scores of a job are returned
+	impactLimit = 50
+)
+
+// jobImpact is the average impact of a job next to the type it is
+// configured with and the type its impact measures it to be
+type jobImpact struct {
+	types.ImpactSummary
+	ConfiguredType string `json:"configured_type"`
+	MeasuredType   string `json:"measured_type"`
+}
+
+// impactSince returns the start of the range impact scores are averaged over
+func impactSince(r *http.Request) (time.Time, error) {
+	sinceStr := r.URL.Query().Get("since")
+	if sinceStr == "" {
+		return time.Now().Add(-impactWindow), nil
+	}
+	since, err := time.Parse(time.RFC3339, sinceStr)
+	if err != nil {
+		return time.Time{}, invalidField("since", "invalid since time: %v", err)
+	}
+	return since, nil
+}
+
+// handleGetImpact returns the average impact of every job with scored
+// executions
+func (s *Server) handleGetImpact(w http.ResponseWriter, r *http.Request) {
+	since, err := impactSince(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	summaries, err := s.store.WithContext(r.Context()).GetImpactSummaries(since)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	impacts := make([]jobImpact, 0, len(summaries))
+	for _, summary := range summaries {
+		if !s.jobVisible(r, summary.JobName) {
+			continue
+		}
+		impacts = append(impacts, s.jobImpact(summary))
+	}
+
+	s.writeSuccess(w, map[string]interface{}{
+		"since":     since,
+		"threshold": s.config.ML.ImpactThreshold,
+		"jobs":      impacts,
+	})
+}
+
+// handleGetJobImpact returns the average impact of a job and its latest
+// impact scores
+func (s *Server) handleGetJobImpact(w http.ResponseWriter, r *http.Request) {
+	jobName := mux.Vars(r)["name"]
+	if _, exists := s.jobManager.GetJob(jobName); !exists {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", jobName))
+		return
+	}
+	since, err := impactSince(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit := impactLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			s.writeError(w, http.StatusBadRequest, invalidField("limit", "invalid limit: %s", limitStr))
+			return
+		}
+	}
+
+	store := s.store.WithContext(r.Context())
+	summaries, err := store.GetImpactSummaries(since)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	impact := s.jobImpact(types.ImpactSummary{JobName: jobName})
+	for _, summary := range summaries {
+		if summary.JobName == jobName {
+			impact = s.jobImpact(summary)
+		}
+	}
+
+	recent, err := store.GetJobImpacts(jobName, limit)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.writeSuccess(w, map[string]interface{}{
+		"since":      since,
+		"threshold":  s.config.ML.ImpactThreshold,
+		"impact":     impact,
+		"executions": recent,
+	})
+}
+
+// jobImpact adds the configured and measured type to an impact summary.
+// Jobs without scored executions have no measured type.
+func (s *Server) jobImpact(summary types.ImpactSummary) jobImpact {
+	impact := jobImpact{ImpactSummary: summary}
+	if job, exists := s.jobManager.GetJob(summary.JobName); exists {
+		impact.ConfiguredType = job.GetType()
+	}
+	if summary.Executions > 0 {
+		impact.MeasuredType = ml.ImpactClass(summary.AvgScore, s.config.ML.ImpactThreshold)
+	}
+	return impact
+}
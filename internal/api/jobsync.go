@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+// maxJobSyncBody bounds the size of job sync requests
+const maxJobSyncBody = 10 << 20
+
+// handleSyncJobs reconciles the jobs managed through the API with the
+// full set of jobs in the body, {"jobs": [...]} in JSON or YAML as in a
+// jobs file: missing jobs are created, changed ones updated and the others
+// deleted. With ?dry_run=true only the plan of the changes is returned.
+// Jobs run commands on the host, so only admins may sync them.
+func (s *Server) handleSyncJobs(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		s.writeError(w, http.StatusForbidden, fmt.Errorf("jobs are only synced by authenticated or local users"))
+		return
+	}
+
+	dryRun := false
+	if dryRunStr := r.URL.Query().Get("dry_run"); dryRunStr != "" {
+		parsed, err := strconv.ParseBool(dryRunStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("dry_run", "invalid dry_run: %s", dryRunStr))
+			return
+		}
+		dryRun = parsed
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxJobSyncBody))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	jobs, err := config.ParseJobs(body)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, invalidField("jobs", "%v", err))
+		return
+	}
+
+	plan, err := s.scheduler.SyncJobDefinitions(jobs, dryRun, requestPrincipal(r))
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		var problems fieldErrors
+		for _, problem := range invalid.Errors {
+			problems.add("jobs", "%v", problem)
+		}
+		s.writeError(w, http.StatusBadRequest, problems)
+		return
+	}
+	if !dryRun {
+		s.audit(r, AuditActionJobSync, "jobs", plan)
+	}
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.writeSuccess(w, plan)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/makalin/arcron/internal/logging"
+)
+
+// logLevelRequest is the body of a log level change
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// handleGetLogLevel returns the current log level
+func (s *Server) handleGetLogLevel(w http.ResponseWriter, r *http.Request) {
+	s.writeSuccess(w, map[string]string{"level": logging.Level()})
+}
+
+// handleSetLogLevel changes the log level until the next restart, e.g. to
+// debug a misbehaving job without restarting
+func (s *Server) handleSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		s.writeError(w, http.StatusForbidden, fmt.Errorf("the log level is only changed by authenticated or local users"))
+		return
+	}
+
+	var req logLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	if req.Level == "" {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("level is required"))
+		return
+	}
+
+	err := logging.SetLevel(req.Level)
+	s.audit(r, AuditActionLogLevel, "logging", req)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.writeSuccess(w, map[string]string{"level": logging.Level()})
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// pauseRequest is the body of a pause request
+type pauseRequest struct {
+	Reason string `json:"reason"`
+}
+
+// handleGetMaintenance returns whether the scheduler is paused
+func (s *Server) handleGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	s.writeSuccess(w, s.scheduler.Maintenance())
+}
+
+// handlePauseScheduler pauses all scheduling, e.g. for a deploy. Running
+// jobs finish; runs falling due while paused are skipped. The body, with
+// an optional reason, may be omitted.
+func (s *Server) handlePauseScheduler(w http.ResponseWriter, r *http.Request) {
+	var req pauseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+
+	state, err := s.scheduler.Pause(req.Reason, requestPrincipal(r))
+	s.audit(r, AuditActionSchedulerPause, "scheduler", req)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.writeSuccess(w, state)
+}
+
+// handleResumeScheduler lets the scheduler start jobs again
+func (s *Server) handleResumeScheduler(w http.ResponseWriter, r *http.Request) {
+	state, err := s.scheduler.Resume(requestPrincipal(r))
+	s.audit(r, AuditActionSchedulerResume, "scheduler", nil)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.writeSuccess(w, state)
+}
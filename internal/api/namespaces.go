@@ -0,0 +1,143 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// requestNamespace returns the namespace a request is limited to, or ""
+// for requests that may see every namespace
+func requestNamespace(r *http.Request) string {
+	if token := requestToken(r); token != nil {
+		return token.Namespace
+	}
+	return ""
+}
+
+// inNamespace reports whether a request may see an object of a namespace
+func inNamespace(r *http.Request, namespace string) bool {
+	scope := requestNamespace(r)
+	return scope == "" || scope == namespace
+}
+
+// scopeNamespace answers requests for jobs outside the namespace of the
+// request as if the job did not exist
+func (s *Server) scopeNamespace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestNamespace(r) != "" {
+			vars := mux.Vars(r)
+			for _, key := range []string{"name", "jobName"} {
+				name, ok := vars[key]
+				if !ok {
+					continue
+				}
+				if job, exists := s.jobManager.GetJob(name); exists && !inNamespace(r, job.GetNamespace()) {
+					s.writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", name))
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unscoped rejects requests limited to a namespace, for endpoints acting
+// on the whole instance
+func (s *Server) unscoped(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if namespace := requestNamespace(r); namespace != "" {
+			s.writeError(w, http.StatusForbidden, fmt.Errorf("token of namespace %s cannot use this endpoint", namespace))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// jobVisible reports whether a request may see a job. Jobs no longer
+// configured are only visible to requests not limited to a namespace.
+func (s *Server) jobVisible(r *http.Request, name string) bool {
+	if requestNamespace(r) == "" {
+		return true
+	}
+	job, exists := s.jobManager.GetJob(name)
+	return exists && inNamespace(r, job.GetNamespace())
+}
+
+// scopeTimeline drops the intervals of jobs a request may not see
+func (s *Server) scopeTimeline(r *http.Request, timeline *types.Timeline) {
+	if requestNamespace(r) == "" {
+		return
+	}
+	visible := make(map[string]bool)
+	intervals := make([]types.TimelineInterval, 0, len(timeline.Intervals))
+	for _, interval := range timeline.Intervals {
+		if s.jobVisible(r, interval.JobName) {
+			visible[interval.ExecutionID] = true
+			intervals = append(intervals, interval)
+		}
+	}
+	for i := range intervals {
+		overlaps := make([]string, 0, len(intervals[i].Overlaps))
+		for _, id := range intervals[i].Overlaps {
+			if visible[id] {
+				overlaps = append(overlaps, id)
+			}
+		}
+		intervals[i].Overlaps = overlaps
+	}
+	timeline.Intervals = intervals
+}
+
+// visibleJobNames drops the names of jobs a request may not see
+func (s *Server) visibleJobNames(r *http.Request, names []string) []string {
+	if requestNamespace(r) == "" {
+		return names
+	}
+	visible := make([]string, 0, len(names))
+	for _, name := range names {
+		if s.jobVisible(r, name) {
+			visible = append(visible, name)
+		}
+	}
+	return visible
+}
+
+// scopeSchedulerStatus returns the scheduler status with only the jobs a
+// request may see
+func (s *Server) scopeSchedulerStatus(r *http.Request, status map[string]interface{}) map[string]interface{} {
+	if requestNamespace(r) == "" {
+		return status
+	}
+	jobStatuses, _ := status["jobs"].(map[string]interface{})
+	visible := make(map[string]interface{}, len(jobStatuses))
+	for name, jobStatus := range jobStatuses {
+		if s.jobVisible(r, name) {
+			visible[name] = jobStatus
+		}
+	}
+	scoped := make(map[string]interface{}, len(status))
+	for key, value := range status {
+		scoped[key] = value
+	}
+	scoped["jobs"] = visible
+	scoped["jobs_count"] = len(visible)
+	return scoped
+}
+
+// scopeMLStatus returns the ML engine status with only the fallback jobs
+// a request may see
+func (s *Server) scopeMLStatus(r *http.Request, status map[string]interface{}) map[string]interface{} {
+	fallbackJobs, ok := status["fallback_jobs"].([]string)
+	if !ok || requestNamespace(r) == "" {
+		return status
+	}
+	scoped := make(map[string]interface{}, len(status))
+	for key, value := range status {
+		scoped[key] = value
+	}
+	scoped["fallback_jobs"] = s.visibleJobNames(r, fallbackJobs)
+	return scoped
+}
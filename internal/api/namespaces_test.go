@@ -0,0 +1,166 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// newNamespacedServer serves a job in each of two namespaces, with a token
+// limited to team-a
+func newNamespacedServer(t *testing.T) *Server {
+	t.Helper()
+
+	return newTestServer(t, &config.Config{
+		Jobs: []config.JobConfig{
+			{Name: "a-job", Command: "true", Schedule: "@daily", Namespace: "team-a"},
+			{Name: "b-job", Command: "true", Schedule: "@daily", Namespace: "team-b"},
+		},
+		Advanced: config.AdvancedConfig{DashboardAuth: config.DashboardAuthConfig{
+			Enabled:  true,
+			Username: "admin",
+			Password: "s3cret",
+			Tokens:   []config.APITokenConfig{{Name: "team-a", Token: "team-a-token", Namespace: "team-a"}},
+		}},
+	})
+}
+
+// getScoped requests path with the team-a token and decodes the data of
+// the response into data
+func getScoped(t *testing.T, server *Server, path string, data interface{}) int {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer team-a-token")
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	if rec.Code == http.StatusOK && data != nil {
+		body := struct {
+			Data interface{} `json:"data"`
+		}{Data: data}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: invalid JSON %q: %v", path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestScopedSchedulerAdvisories(t *testing.T) {
+	server := newNamespacedServer(t)
+
+	if code := getScoped(t, server, "/api/v1/scheduler/advisories?job=b-job", nil); code != http.StatusNotFound {
+		t.Errorf("advisories of a foreign job: status %d, want %d", code, http.StatusNotFound)
+	}
+	var advisories []map[string]interface{}
+	if code := getScoped(t, server, "/api/v1/scheduler/advisories?job=a-job", &advisories); code != http.StatusOK {
+		t.Errorf("advisories of an own job: status %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestScopedSchedulerAdjustments(t *testing.T) {
+	server := newNamespacedServer(t)
+	now := time.Now()
+	for _, name := range []string{"a-job", "b-job"} {
+		adjustment := &types.ScheduleAdjustment{JobName: name, AdjustedAt: now, OriginalTime: now, NewTime: now.Add(time.Hour),
+			Outcome: types.AdjustmentPending}
+		if err := server.store.StoreScheduleAdjustment(adjustment); err != nil {
+			t.Fatalf("StoreScheduleAdjustment() error = %v", err)
+		}
+	}
+
+	if code := getScoped(t, server, "/api/v1/scheduler/adjustments?job=b-job", nil); code != http.StatusNotFound {
+		t.Errorf("adjustments of a foreign job: status %d, want %d", code, http.StatusNotFound)
+	}
+	var data struct {
+		Adjustments []types.ScheduleAdjustment `json:"adjustments"`
+	}
+	if code := getScoped(t, server, "/api/v1/scheduler/adjustments", &data); code != http.StatusOK {
+		t.Fatalf("adjustments: status %d, want %d", code, http.StatusOK)
+	}
+	if len(data.Adjustments) != 1 || data.Adjustments[0].JobName != "a-job" {
+		t.Errorf("adjustments = %+v, want only those of a-job", data.Adjustments)
+	}
+}
+
+func TestScopedMLAccuracy(t *testing.T) {
+	server := newNamespacedServer(t)
+	now := time.Now()
+	for _, name := range []string{"a-job", "b-job"} {
+		prediction := &types.Prediction{JobName: name, PredictedAt: now.Add(-time.Hour), OptimalTime: now.Add(time.Minute), Method: "heuristic"}
+		if err := server.store.StoreMLPrediction(prediction); err != nil {
+			t.Fatalf("StoreMLPrediction() error = %v", err)
+		}
+		outcome := &types.PredictionOutcome{Prediction: *prediction, ActualLoad: 40, AbsError: 5, EvaluatedAt: now}
+		if err := server.store.StorePredictionOutcome(outcome); err != nil {
+			t.Fatalf("StorePredictionOutcome() error = %v", err)
+		}
+	}
+
+	var data struct {
+		Jobs []types.PredictionAccuracy `json:"jobs"`
+	}
+	if code := getScoped(t, server, "/api/v1/ml/accuracy", &data); code != http.StatusOK {
+		t.Fatalf("accuracy: status %d, want %d", code, http.StatusOK)
+	}
+	if len(data.Jobs) != 1 || data.Jobs[0].JobName != "a-job" {
+		t.Errorf("accuracy = %+v, want only that of a-job", data.Jobs)
+	}
+}
+
+func TestScopedSchedulerStatus(t *testing.T) {
+	server := newNamespacedServer(t)
+	startScheduler(t, server)
+
+	var status struct {
+		JobsCount int                    `json:"jobs_count"`
+		Jobs      map[string]interface{} `json:"jobs"`
+	}
+	if code := getScoped(t, server, "/api/v1/scheduler/status", &status); code != http.StatusOK {
+		t.Fatalf("scheduler status: status %d, want %d", code, http.StatusOK)
+	}
+	if _, foreign := status.Jobs["b-job"]; foreign || status.JobsCount != 1 || status.Jobs["a-job"] == nil {
+		t.Errorf("scheduler status = %+v, want only a-job", status)
+	}
+}
+
+func TestScopedSystemStatus(t *testing.T) {
+	server := newNamespacedServer(t)
+	startScheduler(t, server)
+
+	var status struct {
+		Scheduler struct {
+			JobsCount int                    `json:"jobs_count"`
+			Jobs      map[string]interface{} `json:"jobs"`
+		} `json:"scheduler"`
+	}
+	if code := getScoped(t, server, "/api/v1/system/status", &status); code != http.StatusOK {
+		t.Fatalf("system status: status %d, want %d", code, http.StatusOK)
+	}
+	if _, foreign := status.Scheduler.Jobs["b-job"]; foreign || status.Scheduler.JobsCount != 1 {
+		t.Errorf("system status scheduler = %+v, want only a-job", status.Scheduler)
+	}
+}
+
+func TestScopedArtifactNamedLikeForeignJob(t *testing.T) {
+	server := newNamespacedServer(t)
+	execution := &types.JobExecution{ID: "a-run", JobName: "a-job", Namespace: "team-a", Status: types.StatusCompleted}
+	if err := server.store.StoreJobExecution(execution); err != nil {
+		t.Fatalf("StoreJobExecution() error = %v", err)
+	}
+
+	// The artifact name is no job name, so it is not checked against the
+	// jobs of the namespace
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/executions/a-run/artifacts/b-job", nil)
+	req.Header.Set("Authorization", "Bearer team-a-token")
+	rec := httptest.NewRecorder()
+	server.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "artifact not found") {
+		t.Errorf("artifact named like a foreign job: status %d %s, want the artifact looked up", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// maxNextRuns bounds how many upcoming runs can be previewed at once
+const maxNextRuns = 100
+
+// handleGetJobNextRuns previews the upcoming runs of a job, including runs
+// moved by pending schedule adjustments
+func (s *Server) handleGetJobNextRuns(w http.ResponseWriter, r *http.Request) {
+	jobName := mux.Vars(r)["name"]
+
+	count := 10
+	if countStr := r.URL.Query().Get("count"); countStr != "" {
+		parsed, err := strconv.Atoi(countStr)
+		if err != nil || parsed <= 0 || parsed > maxNextRuns {
+			s.writeError(w, http.StatusBadRequest, invalidField("count", "invalid count: %s (1-%d)", countStr, maxNextRuns))
+			return
+		}
+		count = parsed
+	}
+
+	runs, exists := s.scheduler.NextRuns(jobName, count)
+	if !exists {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", jobName))
+		return
+	}
+	timezone, _ := s.scheduler.Timezone(jobName)
+
+	s.writeSuccess(w, map[string]interface{}{
+		"job_name": jobName,
+		"timezone": timezone,
+		"runs":     runs,
+	})
+}
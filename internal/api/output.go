@@ -0,0 +1,42 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// handleGetExecutionOutput returns the output of an execution from the
+// offset query parameter, what a running job wrote so far included, with
+// the offset to poll from next
+func (s *Server) handleGetExecutionOutput(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		var err error
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			s.writeError(w, http.StatusBadRequest, invalidField("offset", "invalid offset: %s", offsetStr))
+			return
+		}
+	}
+
+	if !s.executionVisible(w, r, id) {
+		return
+	}
+
+	chunk, err := s.jobManager.Output(id, offset)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if chunk == nil {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("execution not found: %s", id))
+		return
+	}
+
+	s.writeSuccess(w, chunk)
+}
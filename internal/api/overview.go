@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+const (
+	// overviewWindow is how far back the overview counts executions
+	overviewWindow = 24 * time.Hour
+	// overviewTop is how many jobs the overview ranks
+	overviewTop = 5
+)
+
+// Overview is a fleet-wide summary for dashboards
+type Overview struct {
+	GeneratedAt time.Time              `json:"generated_at"`
+	Jobs        JobsOverview           `json:"jobs"`
+	Executions  *types.FleetStatistics `json:"executions"`
+	System      interface{}            `json:"system"`
+	Alerts      AlertsOverview         `json:"alerts"`
+	Scheduler   SchedulerOverview      `json:"scheduler"`
+}
+
+// JobsOverview counts the configured jobs by their current status
+type JobsOverview struct {
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by_status"`
+}
+
+// AlertsOverview lists what is currently alerting: resources above their
+// critical threshold, and anomalies detected in the overview window
+type AlertsOverview struct {
+	CriticalResources []string `json:"critical_resources"`
+	Anomalies         int      `json:"anomalies"`
+}
+
+// SchedulerOverview is the health and maintenance state of the scheduler
+type SchedulerOverview struct {
+	ComponentHealth
+	Maintenance types.MaintenanceState `json:"maintenance"`
+}
+
+// handleOverview returns jobs by status, executions and failure rate over
+// the last 24 hours with the slowest and most failing jobs, the current
+// system load, active alerts and scheduler health in a single payload
+func (s *Server) handleOverview(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	store := s.store.WithContext(r.Context())
+
+	executions, err := store.GetFleetStatistics(now.Add(-overviewWindow), overviewTop)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	anomalies, err := store.GetAnomalies(storage.AnomalyFilter{Since: now.Add(-overviewWindow)})
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	jobs := JobsOverview{ByStatus: make(map[string]int)}
+	for _, job := range s.jobManager.GetAllJobs() {
+		if !inNamespace(r, job.GetNamespace()) {
+			continue
+		}
+		jobs.Total++
+		jobs.ByStatus[string(job.GetStatus())]++
+	}
+
+	critical := s.monitor.CriticalResources()
+	if critical == nil {
+		critical = []string{}
+	}
+
+	s.writeSuccess(w, Overview{
+		GeneratedAt: now,
+		Jobs:        jobs,
+		Executions:  executions,
+		System:      s.monitor.GetLastMetrics(),
+		Alerts: AlertsOverview{
+			CriticalResources: critical,
+			Anomalies:         len(anomalies),
+		},
+		Scheduler: SchedulerOverview{
+			ComponentHealth: s.checkScheduler(),
+			Maintenance:     s.scheduler.Maintenance(),
+		},
+	})
+}
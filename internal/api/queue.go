@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/makalin/arcron/internal/scheduler"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// moveRequest is the body of a request moving a queued run
+type moveRequest struct {
+	Position int `json:"position"`
+}
+
+// handleGetQueue returns the runs waiting in the execution queue, in the
+// order they start in once due
+func (s *Server) handleGetQueue(w http.ResponseWriter, r *http.Request) {
+	runs, err := s.scheduler.QueuedRuns()
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	visible := make([]*types.QueuedRun, 0, len(runs))
+	for _, run := range runs {
+		if s.jobVisible(r, run.JobName) {
+			visible = append(visible, run)
+		}
+	}
+	s.writeSuccess(w, visible)
+}
+
+// handleMoveQueuedRun moves a queued run to a position in the queue,
+// counted from 1, and returns the reordered queue
+func (s *Server) handleMoveQueuedRun(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid queued run ID: %s", mux.Vars(r)["id"]))
+		return
+	}
+	var req moveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	if req.Position < 1 {
+		s.writeError(w, http.StatusBadRequest, invalidField("position", "invalid position: %d", req.Position))
+		return
+	}
+
+	runs, err := s.scheduler.MoveQueuedRun(uint(id), req.Position)
+	if errors.Is(err, scheduler.ErrQueuedRunNotFound) {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.audit(r, AuditActionQueueMove, fmt.Sprintf("queue/%d", id), req)
+
+	s.writeSuccess(w, runs)
+}
+
+// handleCancelQueuedRun takes a run off the execution queue before it
+// starts
+func (s *Server) handleCancelQueuedRun(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid queued run ID: %s", mux.Vars(r)["id"]))
+		return
+	}
+
+	run, err := s.scheduler.CancelQueuedRun(uint(id))
+	if errors.Is(err, scheduler.ErrQueuedRunNotFound) {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.audit(r, AuditActionQueueCancel, fmt.Sprintf("queue/%d", id), nil)
+
+	s.writeSuccess(w, run)
+}
+
+// executeJobQueued adds a manual run to the end of the execution queue,
+// to start once the runs ahead of it have
+func (s *Server) executeJobQueued(w http.ResponseWriter, r *http.Request, jobName string) {
+	correlationID := r.Header.Get("X-Correlation-ID")
+	if len(correlationID) > 64 {
+		correlationID = ""
+	}
+
+	run, err := s.scheduler.QueueRun(jobName, correlationID)
+	if errors.Is(err, scheduler.ErrNoQueue) {
+		s.writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.audit(r, AuditActionJobExecute, jobName, map[string]interface{}{
+		"mode":          "queue",
+		"queued_run_id": run.ID,
+	})
+
+	s.writeSuccess(w, run)
+}
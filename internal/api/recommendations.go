@@ -0,0 +1,108 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/makalin/arcron/internal/ml"
+)
+
+// defaultSeasonalityDays is how much history seasonality detection uses
+// unless the days query parameter says otherwise
+const defaultSeasonalityDays = 14
+
+// seasonalPattern detects the seasonal load pattern over the number of
+// days given in the request
+func (s *Server) seasonalPattern(r *http.Request) (*ml.SeasonalPattern, error) {
+	days := defaultSeasonalityDays
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		d, err := strconv.Atoi(daysStr)
+		if err != nil || d <= 0 {
+			return nil, invalidField("days", "invalid days: %s", daysStr)
+		}
+		days = d
+	}
+
+	return s.seasonality.DetectSeasonality("", days)
+}
+
+// handleSeasonality returns the detected seasonal load pattern
+func (s *Server) handleSeasonality(w http.ResponseWriter, r *http.Request) {
+	pattern, err := s.seasonalPattern(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if pattern == nil {
+		s.writeError(w, http.StatusServiceUnavailable, fmt.Errorf("not enough metrics history to detect seasonality"))
+		return
+	}
+
+	s.writeSuccess(w, pattern)
+}
+
+// handleRecommendations returns a schedule recommendation for every job
+func (s *Server) handleRecommendations(w http.ResponseWriter, r *http.Request) {
+	pattern, err := s.seasonalPattern(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	allJobs := s.jobManager.GetAllJobs()
+	recommendations := make([]*ml.ScheduleRecommendation, 0, len(allJobs))
+	for name, job := range allJobs {
+		if !inNamespace(r, job.GetNamespace()) {
+			continue
+		}
+		recommendations = append(recommendations, ml.RecommendSchedule(name, job.GetSchedule(), pattern))
+	}
+	sort.Slice(recommendations, func(i, j int) bool {
+		return recommendations[i].JobName < recommendations[j].JobName
+	})
+
+	s.writeSuccess(w, map[string]interface{}{
+		"pattern":         pattern,
+		"recommendations": recommendations,
+	})
+}
+
+// handleAcceptRecommendation applies the current recommendation for a job
+// by rewriting its schedule
+func (s *Server) handleAcceptRecommendation(w http.ResponseWriter, r *http.Request) {
+	jobName := mux.Vars(r)["name"]
+
+	job, exists := s.jobManager.GetJob(jobName)
+	if !exists {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", jobName))
+		return
+	}
+
+	pattern, err := s.seasonalPattern(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rec := ml.RecommendSchedule(jobName, job.GetSchedule(), pattern)
+	if !rec.Actionable() {
+		s.writeError(w, http.StatusConflict, fmt.Errorf("no schedule change recommended: %s", rec.Reason))
+		return
+	}
+
+	if err := s.scheduler.UpdateSchedule(jobName, rec.RecommendedSchedule, requestPrincipal(r)); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.audit(r, AuditActionScheduleUpdate, jobName, map[string]string{
+		"previous": rec.CurrentSchedule,
+		"schedule": rec.RecommendedSchedule,
+		"reason":   rec.Reason,
+	})
+
+	s.writeSuccess(w, rec)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/makalin/arcron/internal/logging"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// requestIDHeader carries the ID of a request, given by the client or
+	// a proxy in front of arcron, or assigned otherwise
+	requestIDHeader = "X-Request-ID"
+	// requestIDField is the log field of request IDs
+	requestIDField = "request_id"
+	// maxRequestIDLength bounds request IDs taken from clients
+	maxRequestIDLength = 64
+)
+
+type requestIDKey struct{}
+
+// assignRequestID gives every request an ID, echoed in the X-Request-ID
+// header and the body of the response and logged with its errors, so a
+// failure a client sees can be found in the logs
+func (s *Server) assignRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = logging.NewCorrelationID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		logrus.WithField(requestIDField, id).Debugf("%s %s", r.Method, r.URL.Path)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID returns the ID of a request, or an empty string
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID reports whether a request ID given by a client is safe
+// to echo and log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
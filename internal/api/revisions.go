@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/scheduler"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// handleGetJobRevisions lists the revisions of a job, newest first, at
+// most ?limit=. Revisions of deleted jobs stay listed.
+func (s *Server) handleGetJobRevisions(w http.ResponseWriter, r *http.Request) {
+	jobName := mux.Vars(r)["name"]
+	if !s.jobVisible(r, jobName) {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", jobName))
+		return
+	}
+	limit := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			s.writeError(w, http.StatusBadRequest, invalidField("limit", "invalid limit: %s", limitStr))
+			return
+		}
+		limit = parsed
+	}
+
+	revisions, err := s.store.WithContext(r.Context()).GetJobRevisions(jobName, limit)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, revision := range revisions {
+		redactRevision(revision)
+	}
+	s.writeSuccess(w, revisions)
+}
+
+// handleGetJobRevision returns a revision of a job
+func (s *Server) handleGetJobRevision(w http.ResponseWriter, r *http.Request) {
+	jobName := mux.Vars(r)["name"]
+	if !s.jobVisible(r, jobName) {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", jobName))
+		return
+	}
+	number, err := revisionNumber(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	revision, err := s.store.WithContext(r.Context()).GetJobRevision(jobName, number)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if revision == nil {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("job %s has no revision %d", jobName, number))
+		return
+	}
+	redactRevision(revision)
+	s.writeSuccess(w, revision)
+}
+
+// handleRollbackJob restores the definition a job synced through the API
+// had at a revision, returning the plan of the changes made. Jobs run
+// commands on the host, so only admins may roll them back.
+func (s *Server) handleRollbackJob(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		s.writeError(w, http.StatusForbidden, fmt.Errorf("jobs are only rolled back by authenticated or local users"))
+		return
+	}
+	jobName := mux.Vars(r)["name"]
+	number, err := revisionNumber(r)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	plan, err := s.scheduler.RollbackJob(jobName, number, requestPrincipal(r))
+	var invalid *config.ValidationError
+	switch {
+	case errors.Is(err, scheduler.ErrRevisionNotFound):
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	case errors.Is(err, scheduler.ErrRollbackUnsupported):
+		s.writeError(w, http.StatusConflict, err)
+		return
+	case errors.As(err, &invalid):
+		// The job may clash with jobs defined since the revision
+		var problems fieldErrors
+		for _, problem := range invalid.Errors {
+			problems.add("revision", "%v", problem)
+		}
+		s.writeError(w, http.StatusBadRequest, problems)
+		return
+	case err != nil:
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.audit(r, AuditActionJobRollback, jobName, map[string]interface{}{
+		"revision": number,
+		"plan":     plan,
+	})
+	s.writeSuccess(w, plan)
+}
+
+// revisionNumber parses the revision of a request path
+func revisionNumber(r *http.Request) (int, error) {
+	revisionStr := mux.Vars(r)["revision"]
+	number, err := strconv.Atoi(revisionStr)
+	if err != nil || number <= 0 {
+		return 0, invalidField("revision", "invalid revision: %s", revisionStr)
+	}
+	return number, nil
+}
+
+// redactRevision hides the secrets of a revision of a job synced through
+// the API, whose definition is recorded as given
+func redactRevision(revision *types.JobRevision) {
+	if revision.Definition != nil {
+		config.RedactJob(revision.Definition)
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/makalin/arcron/internal/scheduler"
+)
+
+// validateScheduleRequest is the body of a schedule validation request
+type validateScheduleRequest struct {
+	Expression string `json:"expression"`
+}
+
+// handleValidateSchedule checks a schedule expression as the scheduler
+// would parse it. Invalid expressions are reported with the position of
+// each error; valid ones are described with their next five runs.
+func (s *Server) handleValidateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req validateScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+
+	s.writeSuccess(w, scheduler.ValidateSchedule(req.Expression, time.Now(), 5))
+}
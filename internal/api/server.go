@@ -4,19 +4,27 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 	"github.com/makalin/arcron/internal/alerts"
 	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/debug"
 	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/logging"
+	"github.com/makalin/arcron/internal/metrics"
 	"github.com/makalin/arcron/internal/ml"
 	"github.com/makalin/arcron/internal/monitoring"
 	"github.com/makalin/arcron/internal/scheduler"
 	"github.com/makalin/arcron/internal/storage"
-	"github.com/gorilla/mux"
-	"github.com/gorilla/websocket"
+	"github.com/makalin/arcron/internal/systemd"
+	"github.com/makalin/arcron/internal/telemetry"
+	"github.com/makalin/arcron/internal/tracing"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Server represents the API server
@@ -28,18 +36,71 @@ type Server struct {
 	monitor      *monitoring.Monitor
 	mlEngine     *ml.Engine
 	alertManager *alerts.Manager
+	seasonality  *ml.SeasonalityDetector
+	flakiness    *ml.FlakinessDetector
+	accuracy     *ml.AccuracyTracker
 	router       *mux.Router
 	httpServer   *http.Server
+	auth         *basicAuth
+	wsConns      *wsRegistry
 	upgrader     websocket.Upgrader
+	closeLogs    func() error
+
+	// trustedProxies may name the client in forwarding headers
+	trustedProxies []*net.IPNet
 }
 
 // New creates a new API server instance
-func New(cfg *config.Config, store *storage.Storage, jobManager *jobs.Manager, 
+func New(cfg *config.Config, store *storage.Storage, jobManager *jobs.Manager,
 	sched *scheduler.Scheduler, monitor *monitoring.Monitor, mlEngine *ml.Engine,
 	alertManager *alerts.Manager) (*Server, error) {
-	
+
 	router := mux.NewRouter()
-	
+
+	accuracy := mlEngine.AccuracyTracker()
+	if accuracy == nil {
+		accuracy = ml.NewAccuracyTracker(store, cfg.ML)
+		mlEngine.SetAccuracyTracker(accuracy)
+	}
+	if mlEngine.Forecaster() == nil {
+		mlEngine.SetForecaster(ml.NewLSTMPredictor(store))
+	}
+	sched.SetAdjustmentStore(store)
+	sched.SetMaintenanceStore(store)
+	sched.SetJobDefinitionStore(store)
+	sched.SetRevisionStore(store)
+	sched.SetImpactStore(store)
+	sched.SetQueueStore(store)
+	jobManager.SetNamespaces(cfg.Namespaces)
+	jobManager.SetRateLimits(cfg.RateLimits)
+	jobManager.SetTimeouts(cfg.Advanced.DefaultTimeout, cfg.Advanced.MaxTimeout)
+	jobManager.SetArtifacts(cfg.Artifacts, store)
+	jobManager.SetMetricsSource(monitor)
+	// Collected samples are stored for the metrics history, the ML
+	// detectors and forecasts, simulations and rollups
+	monitor.AddListener(store.MetricsListener())
+	// The built-in model learns from every finished execution
+	mlEngine.Attach(monitor, jobManager)
+	// Executions are scored by the load they add once its aftermath is collected
+	ml.NewImpactScorer(store, monitor.GetInterval()).Attach(jobManager)
+	// Anomalies and duration regressions are recorded for the API and,
+	// with alerting, alerted on, as are flaky jobs
+	anomalies := ml.NewAnomalyDetector(store)
+	regressions := ml.NewRegressionDetector(cfg.ML.DurationRegression, store)
+	flakiness := ml.NewFlakinessDetector(cfg.ML.Flakiness, store)
+	if alertManager != nil {
+		alertManager.SetStore(store)
+		alertManager.SetJobSource(sched.Jobs)
+		alertManager.Attach(jobManager, monitor)
+		anomalies.Attach(monitor, store, alertManager)
+		regressions.Attach(jobManager, alertManager)
+		flakiness.Attach(jobManager, alertManager)
+	} else {
+		anomalies.Attach(monitor, store, nil)
+		regressions.Attach(jobManager, nil)
+		flakiness.Attach(jobManager, nil)
+	}
+
 	server := &Server{
 		config:       cfg,
 		store:        store,
@@ -48,7 +109,11 @@ func New(cfg *config.Config, store *storage.Storage, jobManager *jobs.Manager,
 		monitor:      monitor,
 		mlEngine:     mlEngine,
 		alertManager: alertManager,
+		seasonality:  ml.NewSeasonalityDetector(store),
+		flakiness:    flakiness,
+		accuracy:     accuracy,
 		router:       router,
+		wsConns:      newWSRegistry(cfg.Server.MaxWebSocketConns),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins in development
@@ -56,6 +121,33 @@ func New(cfg *config.Config, store *storage.Storage, jobManager *jobs.Manager,
 		},
 	}
 
+	closeLogs, err := logging.Setup(cfg.Logging)
+	if err != nil {
+		return nil, err
+	}
+	server.closeLogs = closeLogs
+
+	// Forwarding headers are only believed from the trusted proxies
+	server.trustedProxies, err = config.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	// Request IDs are assigned first so rejected requests have one too
+	router.Use(server.assignRequestID)
+	if cfg.Advanced.DashboardAuth.Enabled {
+		auth, err := newBasicAuth(cfg.Advanced.DashboardAuth)
+		if err != nil {
+			return nil, err
+		}
+		server.auth = auth
+		router.Use(server.requireAuth)
+	}
+
+	telemetry.NewGaugeFunc("arcron_websocket_connections", "Number of open WebSocket connections", func() float64 {
+		return float64(server.WebSocketConnections())
+	})
+
 	server.setupRoutes()
 
 	httpServer := &http.Server{
@@ -72,62 +164,204 @@ func New(cfg *config.Config, store *storage.Storage, jobManager *jobs.Manager,
 // setupRoutes sets up all API routes
 func (s *Server) setupRoutes() {
 	api := s.router.PathPrefix("/api/v1").Subrouter()
-	
+	api.Use(s.scopeNamespace)
+
 	// Health check
 	s.router.HandleFunc("/health", s.handleHealth).Methods("GET")
-	
+	s.router.HandleFunc("/healthz", s.handleLiveness).Methods("GET")
+	s.router.HandleFunc("/readyz", s.handleReadiness).Methods("GET")
+
+	// Overview for dashboards
+	api.HandleFunc("/overview", s.handleOverview).Methods("GET")
+
 	// Metrics endpoints
 	api.HandleFunc("/metrics", s.handleGetMetrics).Methods("GET")
 	api.HandleFunc("/metrics/realtime", s.handleRealtimeMetrics).Methods("GET")
-	
+
 	// Job endpoints
 	api.HandleFunc("/jobs", s.handleListJobs).Methods("GET")
+	api.HandleFunc("/jobs/sync", s.unscoped(s.handleSyncJobs)).Methods("PUT")
 	api.HandleFunc("/jobs/{name}", s.handleGetJob).Methods("GET")
 	api.HandleFunc("/jobs/{name}/execute", s.handleExecuteJob).Methods("POST")
 	api.HandleFunc("/jobs/{name}/executions", s.handleGetJobExecutions).Methods("GET")
+	api.HandleFunc("/jobs/{name}/executions/export", s.handleExportJobExecutions).Methods("GET")
 	api.HandleFunc("/jobs/{name}/statistics", s.handleGetJobStatistics).Methods("GET")
-	
+	api.HandleFunc("/jobs/{name}/next-runs", s.handleGetJobNextRuns).Methods("GET")
+	api.HandleFunc("/jobs/{name}/impact", s.handleGetJobImpact).Methods("GET")
+	api.HandleFunc("/jobs/{name}/revisions", s.handleGetJobRevisions).Methods("GET")
+	api.HandleFunc("/jobs/{name}/revisions/{revision}", s.handleGetJobRevision).Methods("GET")
+	api.HandleFunc("/jobs/{name}/revisions/{revision}/rollback", s.unscoped(s.handleRollbackJob)).Methods("POST")
+	api.HandleFunc("/impact", s.handleGetImpact).Methods("GET")
+	api.HandleFunc("/executions/{id}", s.handleGetExecution).Methods("GET")
+	api.HandleFunc("/executions/{id}/output", s.handleGetExecutionOutput).Methods("GET")
+	api.HandleFunc("/executions/{id}/compare", s.handleCompareExecution).Methods("GET")
+	api.HandleFunc("/executions/{id}/artifacts", s.handleGetArtifacts).Methods("GET")
+	api.HandleFunc("/executions/{id}/artifacts/{artifact}", s.handleDownloadArtifact).Methods("GET")
+	api.HandleFunc("/timeline", s.handleGetTimeline).Methods("GET")
+	api.HandleFunc("/calendar.ics", s.handleGetCalendar).Methods("GET")
+	api.HandleFunc("/graphql", s.handleGraphQL).Methods("GET", "POST")
+
 	// Scheduler endpoints
 	api.HandleFunc("/scheduler/status", s.handleSchedulerStatus).Methods("GET")
 	api.HandleFunc("/scheduler/jobs/{name}/status", s.handleGetJobStatus).Methods("GET")
-	
+	api.HandleFunc("/scheduler/advisories", s.handleSchedulerAdvisories).Methods("GET")
+	api.HandleFunc("/scheduler/adjustments", s.handleSchedulerAdjustments).Methods("GET")
+	api.HandleFunc("/scheduler/maintenance", s.handleGetMaintenance).Methods("GET")
+	api.HandleFunc("/scheduler/pause", s.unscoped(s.handlePauseScheduler)).Methods("POST")
+	api.HandleFunc("/scheduler/resume", s.unscoped(s.handleResumeScheduler)).Methods("POST")
+	api.HandleFunc("/scheduler/simulate", s.unscoped(s.handleSimulate)).Methods("POST")
+	api.HandleFunc("/queue", s.handleGetQueue).Methods("GET")
+	api.HandleFunc("/queue/{id}/move", s.unscoped(s.handleMoveQueuedRun)).Methods("POST")
+	api.HandleFunc("/queue/{id}", s.unscoped(s.handleCancelQueuedRun)).Methods("DELETE")
+	api.HandleFunc("/schedule/validate", s.handleValidateSchedule).Methods("POST")
+
 	// ML endpoints
 	api.HandleFunc("/ml/status", s.handleMLStatus).Methods("GET")
 	api.HandleFunc("/ml/predict/{jobName}", s.handleMLPredict).Methods("GET")
-	
+	api.HandleFunc("/ml/accuracy", s.handleMLAccuracy).Methods("GET")
+	api.HandleFunc("/ml/forecast", s.handleMLForecast).Methods("GET")
+	api.HandleFunc("/ml/explain/{jobName}", s.handleMLExplain).Methods("GET")
+	api.HandleFunc("/ml/seasonality", s.handleSeasonality).Methods("GET")
+	api.HandleFunc("/ml/recommendations", s.handleRecommendations).Methods("GET")
+	api.HandleFunc("/ml/recommendations/{name}/accept", s.handleAcceptRecommendation).Methods("POST")
+
 	// System endpoints
 	api.HandleFunc("/system/status", s.handleSystemStatus).Methods("GET")
-	
+
+	// Anomaly endpoints
+	api.HandleFunc("/anomalies", s.handleGetAnomalies).Methods("GET")
+
+	// Alert endpoints
+	api.HandleFunc("/alerts", s.handleGetAlerts).Methods("GET")
+	api.HandleFunc("/alerts/routes/test", s.unscoped(s.handleTestAlertRoutes)).Methods("POST")
+	api.HandleFunc("/alerts/dead-letters", s.unscoped(s.handleGetDeadLetters)).Methods("GET")
+	api.HandleFunc("/alerts/dead-letters/redrive", s.unscoped(s.handleRedriveDeadLetters)).Methods("POST")
+	api.HandleFunc("/alerts/dead-letters/{id}/redrive", s.unscoped(s.handleRedriveDeadLetter)).Methods("POST")
+	api.HandleFunc("/alerts/{id}/ack", s.handleAcknowledgeAlert).Methods("POST")
+	api.HandleFunc("/silences", s.handleGetSilences).Methods("GET")
+	api.HandleFunc("/silences", s.unscoped(s.handleCreateSilence)).Methods("POST")
+	api.HandleFunc("/silences/{id}", s.unscoped(s.handleExpireSilence)).Methods("DELETE")
+
+	// Audit endpoints
+	api.HandleFunc("/audit", s.unscoped(s.handleGetAudit)).Methods("GET")
+
+	// Admin endpoints
+	api.HandleFunc("/admin/backup", s.unscoped(s.handleBackup)).Methods("GET")
+	api.HandleFunc("/admin/restore", s.unscoped(s.handleRestore)).Methods("POST")
+	api.HandleFunc("/admin/log-level", s.unscoped(s.handleGetLogLevel)).Methods("GET")
+	api.HandleFunc("/admin/log-level", s.unscoped(s.handleSetLogLevel)).Methods("PUT")
+	api.HandleFunc("/config", s.handleGetConfig).Methods("GET")
+
 	// WebSocket for real-time updates
-	s.router.HandleFunc("/ws", s.handleWebSocket)
-	
+	s.router.HandleFunc("/ws", s.unscoped(s.handleWebSocket))
+
 	// Serve static files for dashboard
 	s.router.PathPrefix("/").Handler(http.FileServer(http.Dir("./web/dist/")))
 }
 
-// Start starts the API server
+// Start starts the API server, on the socket passed by systemd if it was
+// socket activated. Under systemd it reports readiness once listening and
+// pings the watchdog while no component is unhealthy.
 func (s *Server) Start(ctx context.Context) error {
-	logrus.Infof("Starting API server on %s", s.httpServer.Addr)
-	
+	listener, err := systemd.Listener("api")
+	if err != nil {
+		return fmt.Errorf("failed to start server: %v", err)
+	}
+	if listener != nil {
+		logrus.Infof("Starting API server on %s passed by systemd", listener.Addr())
+	} else {
+		logrus.Infof("Starting API server on %s", s.httpServer.Addr)
+		if listener, err = net.Listen("tcp", s.httpServer.Addr); err != nil {
+			return fmt.Errorf("failed to start server: %v", err)
+		}
+	}
+	shutdownTracing, err := tracing.Setup(ctx, s.config.Tracing)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	debugServer := debug.New(s.config.Advanced.Debug)
+	if err := debugServer.Start(); err != nil {
+		listener.Close()
+		shutdownTracing(context.Background())
+		return err
+	}
+	exporter := metrics.NewExporter(s.config, s.jobManager, s.scheduler, s.monitor)
+	if err := exporter.Start(); err != nil {
+		listener.Close()
+		debugServer.Stop()
+		shutdownTracing(context.Background())
+		return err
+	}
+	statsd := metrics.NewStatsDEmitter(s.config.Metrics.StatsD, s.jobManager, s.monitor)
+	if err := statsd.Start(ctx); err != nil {
+		logrus.Warnf("Failed to start StatsD emitter: %v", err)
+	}
+	pusher := metrics.NewPusher(s.config.Metrics.Pushgateway, s.jobManager)
+	if err := pusher.Start(); err != nil {
+		logrus.Warnf("Failed to start Pushgateway pusher: %v", err)
+	}
+
+	go s.store.RunCleanup(ctx)
+	go s.store.RunRollups(ctx)
+	go s.store.RunBackups(ctx)
+	go systemd.RunWatchdog(ctx, s.checkWatchdog)
+	if err := systemd.Ready(); err != nil {
+		logrus.Warnf("Failed to report readiness: %v", err)
+	}
+
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		<-ctx.Done()
+		systemd.Stopping()
+		// Start no more jobs, then fail readiness and let running jobs
+		// finish before going away
+		s.scheduler.Stop()
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), s.config.Server.DrainTimeout)
+		if err := s.jobManager.Drain(drainCtx); err != nil {
+			logrus.Warnf("Jobs still running after %s, stopping anyway", s.config.Server.DrainTimeout)
+		}
+		cancelDrain()
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		s.httpServer.Shutdown(shutdownCtx)
+		s.wsConns.closeAll()
+		debugServer.Stop()
+		exporter.Stop()
+		statsd.Stop()
+		// Pushes the metrics of the executions the drain waited for
+		pusher.Stop()
+		if s.alertManager != nil {
+			s.alertManager.Close()
+		}
+		// Exports the spans still buffered
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			logrus.Warnf("Failed to shut down tracing: %v", err)
+		}
+		// Writes the system metrics still queued
+		if err := s.store.Close(); err != nil {
+			logrus.Warnf("Failed to close storage: %v", err)
+		}
+		s.closeLogs()
 	}()
-	
-	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+
+	if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("failed to start server: %v", err)
 	}
-	
+	// Serve returns as soon as the shutdown begins; wait for the metrics,
+	// spans and storage to be flushed
+	<-stopped
+
 	return nil
 }
 
 // Response represents a standard API response
 type Response struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success   bool        `json:"success"`
+	Data      interface{} `json:"data,omitempty"`
+	Error     *APIError   `json:"error,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
 }
 
 func (s *Server) writeJSON(w http.ResponseWriter, status int, data interface{}) {
@@ -136,26 +370,25 @@ func (s *Server) writeJSON(w http.ResponseWriter, status int, data interface{})
 	json.NewEncoder(w).Encode(data)
 }
 
+// writeError answers with an error, coded by the status unless it lists
+// invalid fields. Server-side failures are logged with the request ID.
 func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	id := w.Header().Get(requestIDHeader)
+	if status >= http.StatusInternalServerError {
+		logrus.WithField(requestIDField, id).Warnf("Request failed with status %d: %v", status, err)
+	}
 	s.writeJSON(w, status, Response{
-		Success: false,
-		Error:   err.Error(),
+		Success:   false,
+		Error:     newAPIError(status, err),
+		RequestID: id,
 	})
 }
 
 func (s *Server) writeSuccess(w http.ResponseWriter, data interface{}) {
 	s.writeJSON(w, http.StatusOK, Response{
-		Success: true,
-		Data:    data,
-	})
-}
-
-// Health check handler
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	s.writeSuccess(w, map[string]interface{}{
-		"status":  "healthy",
-		"version": "1.0.0",
-		"uptime":  time.Since(time.Now()).String(), // Placeholder
+		Success:   true,
+		Data:      data,
+		RequestID: w.Header().Get(requestIDHeader),
 	})
 }
 
@@ -165,100 +398,89 @@ func (s *Server) handleGetMetrics(w http.ResponseWriter, r *http.Request) {
 	startStr := query.Get("start")
 	endStr := query.Get("end")
 	limit := 1000
-	
+
 	var start, end time.Time
 	var err error
-	
+
 	if startStr != "" {
 		start, err = time.Parse(time.RFC3339, startStr)
 		if err != nil {
-			s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid start time: %v", err))
+			s.writeError(w, http.StatusBadRequest, invalidField("start", "invalid start time: %v", err))
 			return
 		}
 	} else {
 		start = time.Now().Add(-24 * time.Hour)
 	}
-	
+
 	if endStr != "" {
 		end, err = time.Parse(time.RFC3339, endStr)
 		if err != nil {
-			s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid end time: %v", err))
+			s.writeError(w, http.StatusBadRequest, invalidField("end", "invalid end time: %v", err))
 			return
 		}
 	} else {
 		end = time.Now()
 	}
-	
-	metrics, err := s.store.GetSystemMetrics(start, end, limit)
+
+	metrics, err := s.store.WithContext(r.Context()).GetSystemMetrics(start, end, limit)
 	if err != nil {
 		s.writeError(w, http.StatusInternalServerError, err)
 		return
 	}
-	
+
 	s.writeSuccess(w, metrics)
 }
 
 func (s *Server) handleRealtimeMetrics(w http.ResponseWriter, r *http.Request) {
-	conn, err := s.upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		logrus.Errorf("WebSocket upgrade failed: %v", err)
-		return
-	}
-	defer conn.Close()
-	
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-	
-	for {
-		select {
-		case <-ticker.C:
-			metrics := s.monitor.GetLastMetrics()
-			if metrics != nil {
-				if err := conn.WriteJSON(metrics); err != nil {
-					logrus.Errorf("WebSocket write error: %v", err)
-					return
-				}
-			}
+	s.serveWebSocket(w, r, 5*time.Second, func() interface{} {
+		if metrics := s.monitor.GetLastMetrics(); metrics != nil {
+			return metrics
 		}
-	}
+		return nil
+	})
 }
 
 // Job handlers
 func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
 	allJobs := s.jobManager.GetAllJobs()
 	jobsList := make([]map[string]interface{}, 0, len(allJobs))
-	
+
+	namespace := r.URL.Query().Get("namespace")
 	for name, job := range allJobs {
+		if !inNamespace(r, job.GetNamespace()) || (namespace != "" && job.GetNamespace() != namespace) {
+			continue
+		}
 		scheduledJob, _ := s.scheduler.GetJobStatus(name)
 		jobData := map[string]interface{}{
-			"name":     name,
-			"type":    job.GetType(),
-			"schedule": job.GetSchedule(),
-			"status":   job.GetStatus(),
+			"name":      name,
+			"namespace": job.GetNamespace(),
+			"type":      job.GetType(),
+			"schedule":  job.GetSchedule(),
+			"status":    job.GetStatus(),
 		}
-		
+
 		if scheduledJob != nil {
 			jobData["next_run"] = scheduledJob.NextRun
 			jobData["last_run"] = scheduledJob.LastRun
 			jobData["run_count"] = scheduledJob.RunCount
 		}
-		
+
 		jobsList = append(jobsList, jobData)
 	}
-	
+
 	s.writeSuccess(w, jobsList)
 }
 
 func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	jobName := vars["name"]
-	
+
 	job, exists := s.jobManager.GetJob(jobName)
 	if !exists {
 		s.writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", jobName))
 		return
 	}
-	
+
 	scheduledJob, _ := s.scheduler.GetJobStatus(jobName)
 	jobData := map[string]interface{}{
 		"name":     job.GetName(),
@@ -267,7 +489,7 @@ func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
 		"status":   job.GetStatus(),
 		"config":   job.GetConfig(),
 	}
-	
+
 	if scheduledJob != nil {
 		jobData["next_run"] = scheduledJob.NextRun
 		jobData["last_run"] = scheduledJob.LastRun
@@ -276,116 +498,199 @@ func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
 			jobData["prediction"] = scheduledJob.Prediction
 		}
 	}
-	
+
 	s.writeSuccess(w, jobData)
 }
 
 func (s *Server) handleExecuteJob(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	jobName := vars["name"]
-	
+
 	job, exists := s.jobManager.GetJob(jobName)
 	if !exists {
 		s.writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", jobName))
 		return
 	}
-	
-	go func() {
-		if err := s.jobManager.ExecuteJob(job); err != nil {
-			logrus.Errorf("Failed to execute job %s: %v", jobName, err)
-		}
-	}()
-	
-	s.writeSuccess(w, map[string]string{
-		"message": fmt.Sprintf("Job %s execution started", jobName),
+
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", "now":
+	case "smart":
+		s.executeJobSmart(w, r, jobName)
+		return
+	case "queue":
+		s.executeJobQueued(w, r, jobName)
+		return
+	default:
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid mode %q: expected now, smart or queue", mode))
+		return
+	}
+
+	s.audit(r, AuditActionJobExecute, jobName, nil)
+
+	// Callers may pass their own correlation ID to find the run's log lines
+	ctx := context.Background()
+	if id := r.Header.Get("X-Correlation-ID"); id != "" && len(id) <= 64 {
+		ctx = logging.WithCorrelationID(ctx, id)
+	}
+	ctx, span := tracing.Start(ctx, "api.execute",
+		attribute.String("job.name", jobName),
+		attribute.String("trigger.principal", requestPrincipal(r)),
+	)
+	execution, err := s.jobManager.StartJob(ctx, job)
+	tracing.End(span, err)
+	if err == jobs.ErrDraining {
+		s.writeError(w, http.StatusServiceUnavailable, fmt.Errorf("failed to start job %s: %v", jobName, err))
+		return
+	}
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to start job %s: %v", jobName, err))
+		return
+	}
+
+	s.writeSuccess(w, map[string]interface{}{
+		"message":        fmt.Sprintf("Job %s execution started", jobName),
+		"execution_id":   execution.ID,
+		"correlation_id": execution.CorrelationID,
+		"status":         execution.Status,
+		"status_url":     "/api/v1/executions/" + execution.ID,
 	})
 }
 
+// handleGetExecution returns the status and output of a single execution,
+// for following a manual run to completion
+func (s *Server) handleGetExecution(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	execution, err := s.jobManager.GetExecution(id)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if execution == nil || !inNamespace(r, execution.Namespace) {
+		s.writeError(w, http.StatusNotFound, fmt.Errorf("execution not found: %s", id))
+		return
+	}
+
+	s.writeSuccess(w, execution)
+}
+
 func (s *Server) handleGetJobExecutions(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	jobName := vars["name"]
-	
+
 	limit := 100
 	executions, err := s.jobManager.GetJobExecutions(jobName, limit)
 	if err != nil {
 		s.writeError(w, http.StatusInternalServerError, err)
 		return
 	}
-	
+
 	s.writeSuccess(w, executions)
 }
 
 func (s *Server) handleGetJobStatistics(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	jobName := vars["name"]
-	
-	stats, err := s.store.GetJobStatistics(jobName)
+
+	filter := storage.StatisticsFilter{JobName: jobName}
+	query := r.URL.Query()
+	if sinceStr := query.Get("since"); sinceStr != "" {
+		since, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("since", "invalid since time: %v", err))
+			return
+		}
+		filter.Since = since
+	}
+	if untilStr := query.Get("until"); untilStr != "" {
+		until, err := time.Parse(time.RFC3339, untilStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("until", "invalid until time: %v", err))
+			return
+		}
+		filter.Until = until
+	}
+
+	store := s.store.WithContext(r.Context())
+	stats, err := store.GetJobStatistics(filter)
 	if err != nil {
 		s.writeError(w, http.StatusInternalServerError, err)
 		return
 	}
-	
+	regressions, err := store.GetDurationRegressions(jobName, filter.Since, filter.Until)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	stats["duration_regressions"] = regressions
+	flakiness, err := s.flakiness.Score(jobName)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	stats["flakiness"] = flakiness
+
 	s.writeSuccess(w, stats)
 }
 
 // Scheduler handlers
 func (s *Server) handleSchedulerStatus(w http.ResponseWriter, r *http.Request) {
-	status := s.scheduler.GetStatus()
+	status := s.scopeSchedulerStatus(r, s.scheduler.GetStatus())
 	s.writeSuccess(w, status)
 }
 
 func (s *Server) handleGetJobStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	jobName := vars["name"]
-	
+
 	scheduledJob, exists := s.scheduler.GetJobStatus(jobName)
 	if !exists {
 		s.writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", jobName))
 		return
 	}
-	
+
 	status := map[string]interface{}{
 		"status":    scheduledJob.Status,
 		"next_run":  scheduledJob.NextRun,
 		"last_run":  scheduledJob.LastRun,
 		"run_count": scheduledJob.RunCount,
 	}
-	
+
 	if scheduledJob.Prediction != nil {
 		status["prediction"] = scheduledJob.Prediction
 	}
-	
+
 	s.writeSuccess(w, status)
 }
 
 // ML handlers
 func (s *Server) handleMLStatus(w http.ResponseWriter, r *http.Request) {
-	status := s.mlEngine.GetStatus()
+	status := s.scopeMLStatus(r, s.mlEngine.GetStatus())
 	s.writeSuccess(w, status)
 }
 
 func (s *Server) handleMLPredict(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	jobName := vars["name"]
-	
+
 	job, exists := s.jobManager.GetJob(jobName)
 	if !exists {
 		s.writeError(w, http.StatusNotFound, fmt.Errorf("job not found: %s", jobName))
 		return
 	}
-	
+
 	metrics := s.monitor.GetLastMetrics()
 	if metrics == nil {
 		s.writeError(w, http.StatusServiceUnavailable, fmt.Errorf("no metrics available"))
 		return
 	}
-	
+
 	prediction, err := s.mlEngine.PredictOptimalTime(jobName, job.GetType(), *metrics)
 	if err != nil {
 		s.writeError(w, http.StatusInternalServerError, err)
 		return
 	}
-	
+
 	s.writeSuccess(w, prediction)
 }
 
@@ -393,39 +698,21 @@ func (s *Server) handleMLPredict(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleSystemStatus(w http.ResponseWriter, r *http.Request) {
 	status := map[string]interface{}{
 		"monitor":   s.monitor.GetStatus(),
-		"ml_engine": s.mlEngine.GetStatus(),
-		"scheduler": s.scheduler.GetStatus(),
+		"ml_engine": s.scopeMLStatus(r, s.mlEngine.GetStatus()),
+		"scheduler": s.scopeSchedulerStatus(r, s.scheduler.GetStatus()),
 	}
-	
+
 	s.writeSuccess(w, status)
 }
 
 // WebSocket handler
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := s.upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		logrus.Errorf("WebSocket upgrade failed: %v", err)
-		return
-	}
-	defer conn.Close()
-	
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-	
-	for {
-		select {
-		case <-ticker.C:
-			update := map[string]interface{}{
-				"timestamp": time.Now(),
-				"metrics":   s.monitor.GetLastMetrics(),
-				"scheduler": s.scheduler.GetStatus(),
-			}
-			
-			if err := conn.WriteJSON(update); err != nil {
-				logrus.Errorf("WebSocket write error: %v", err)
-				return
-			}
+	s.serveWebSocket(w, r, 1*time.Second, func() interface{} {
+		return map[string]interface{}{
+			"timestamp": time.Now(),
+			"metrics":   s.monitor.GetLastMetrics(),
+			"scheduler": s.scheduler.GetStatus(),
+			"alerts":    s.recentAlerts(),
 		}
-	}
+	})
 }
-
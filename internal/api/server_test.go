@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/monitoring"
+	"github.com/makalin/arcron/internal/scheduler"
+	"github.com/makalin/arcron/internal/storage"
+)
+
+// newTestServer wires a server for a configuration the way arcron does, on
+// a temporary SQLite database writing metrics samples every few
+// milliseconds
+func newTestServer(t *testing.T, cfg *config.Config) *Server {
+	t.Helper()
+
+	cfg.Database = config.DatabaseConfig{
+		Driver:   "sqlite",
+		DSN:      filepath.Join(t.TempDir(), "arcron.db"),
+		MaxConns: 1,
+		Batch:    config.BatchConfig{Size: 10, FlushInterval: 10 * time.Millisecond, QueueSize: 100},
+	}
+	store, err := storage.New(cfg.Database)
+	if err != nil {
+		t.Fatalf("storage.New() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	jobManager, err := jobs.New(cfg.Jobs, cfg.Security, store)
+	if err != nil {
+		t.Fatalf("jobs.New() error = %v", err)
+	}
+	t.Cleanup(jobManager.Stop)
+	monitor, err := monitoring.New(cfg)
+	if err != nil {
+		t.Fatalf("monitoring.New() error = %v", err)
+	}
+	mlEngine, err := ml.New(cfg.ML)
+	if err != nil {
+		t.Fatalf("ml.New() error = %v", err)
+	}
+	sched, err := scheduler.New(cfg, jobManager, mlEngine, monitor)
+	if err != nil {
+		t.Fatalf("scheduler.New() error = %v", err)
+	}
+
+	server, err := New(cfg, store, jobManager, sched, monitor, mlEngine, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return server
+}
+
+// startMonitor collects metrics every few milliseconds until the test ends
+func startMonitor(t *testing.T, server *Server) {
+	t.Helper()
+
+	server.monitor.SetInterval(10 * time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := server.monitor.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(server.monitor.Stop)
+}
+
+func TestCollectedMetricsAreStored(t *testing.T) {
+	server := newTestServer(t, &config.Config{})
+	startMonitor(t, server)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		samples, err := server.store.GetSystemMetrics(time.Now().Add(-time.Hour), time.Now().Add(time.Hour), 10)
+		if err != nil {
+			t.Fatalf("GetSystemMetrics() error = %v", err)
+		}
+		if len(samples) > 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no collected sample reached the store")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestModelLearnsFromFinishedExecutions(t *testing.T) {
+	server := newTestServer(t, &config.Config{
+		Jobs: []config.JobConfig{{Name: "flaky", Command: "false", Schedule: "@daily"}},
+		ML: config.MLConfig{
+			UpdateInterval: time.Hour,
+			OnlineLearning: config.OnlineLearningConfig{Enabled: true, LearningRate: 0.05, HalfLife: 200},
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := server.mlEngine.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer server.mlEngine.Stop()
+	startMonitor(t, server)
+	for deadline := time.Now().Add(5 * time.Second); server.monitor.GetLastMetrics() == nil; {
+		if time.Now().After(deadline) {
+			t.Fatal("no metrics collected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A failure teaches the model to delay jobs under the conditions
+	job, _ := server.jobManager.GetJob("flaky")
+	if err := server.jobManager.ExecuteJob(context.Background(), job); err == nil {
+		t.Fatal("ExecuteJob() of a failing job succeeded")
+	}
+	if seen := server.mlEngine.GetStatus()["samples_seen"]; seen != 1 {
+		t.Errorf("samples seen = %v, want 1", seen)
+	}
+}
+
+func TestStartReturnsAfterShutdown(t *testing.T) {
+	server := newTestServer(t, &config.Config{Server: config.ServerConfig{Host: "127.0.0.1"}})
+	var closed atomic.Bool
+	server.closeLogs = func() error {
+		// The last step of the shutdown, after the storage is flushed
+		time.Sleep(50 * time.Millisecond)
+		closed.Store(true)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- server.Start(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Start() did not return after the context was cancelled")
+	}
+	if !closed.Load() {
+		t.Error("Start() returned before the shutdown finished")
+	}
+}
+
+func TestShutdownStopsSchedulerBeforeDrain(t *testing.T) {
+	server := newTestServer(t, &config.Config{Server: config.ServerConfig{Host: "127.0.0.1"}})
+	startScheduler(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- server.Start(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	for deadline := time.Now().Add(5 * time.Second); !server.jobManager.Draining(); {
+		if time.Now().After(deadline) {
+			t.Fatal("job manager not draining after the context was cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if server.scheduler.IsRunning() {
+		t.Error("scheduler still running while the job manager drains")
+	}
+	if err := <-result; err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/scheduler"
+)
+
+// simulationWindow is the range simulated without start and end
+const simulationWindow = 24 * time.Hour
+
+// handleSimulate replays the stored metrics between start and end, by
+// default the last 24 hours, through the scheduler and ML engine and
+// returns when the jobs would have run and the projected load per
+// ?interval=. The body may propose jobs to simulate instead of the
+// configured ones, {"jobs": [...]} in JSON or YAML as in a jobs file.
+// Nothing is run or changed, but the simulation reads the history of any
+// job, so only admins may run it.
+func (s *Server) handleSimulate(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		s.writeError(w, http.StatusForbidden, fmt.Errorf("simulations are only run by authenticated or local users"))
+		return
+	}
+
+	query := r.URL.Query()
+	request := scheduler.SimulationRequest{End: time.Now(), Interval: s.config.ML.ForecastInterval}
+	if endStr := query.Get("end"); endStr != "" {
+		parsed, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("end", "invalid end time: %v", err))
+			return
+		}
+		request.End = parsed
+	}
+	request.Start = request.End.Add(-simulationWindow)
+	if startStr := query.Get("start"); startStr != "" {
+		parsed, err := time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("start", "invalid start time: %v", err))
+			return
+		}
+		request.Start = parsed
+	}
+	if intervalStr := query.Get("interval"); intervalStr != "" {
+		parsed, err := time.ParseDuration(intervalStr)
+		if err != nil || parsed <= 0 {
+			s.writeError(w, http.StatusBadRequest, invalidField("interval", "invalid interval: %s", intervalStr))
+			return
+		}
+		request.Interval = parsed
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxJobSyncBody))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		jobs, err := config.ParseJobs(body)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("jobs", "%v", err))
+			return
+		}
+		request.Jobs = jobs
+	}
+
+	report, err := s.scheduler.Simulate(s.store.WithContext(r.Context()), request)
+	var invalid *config.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		var problems fieldErrors
+		for _, problem := range invalid.Errors {
+			problems.add("jobs", "%v", problem)
+		}
+		s.writeError(w, http.StatusBadRequest, problems)
+		return
+	case errors.Is(err, scheduler.ErrInvalidSimulation):
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.writeSuccess(w, report)
+}
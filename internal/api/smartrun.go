@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// executeJobSmart queues a manual run at the ML-predicted optimal time,
+// waiting at most max_wait (capped at advanced.smart_run_max_wait), and
+// returns when the job will start
+func (s *Server) executeJobSmart(w http.ResponseWriter, r *http.Request, jobName string) {
+	var maxWait time.Duration
+	if maxWaitStr := r.URL.Query().Get("max_wait"); maxWaitStr != "" {
+		parsed, err := time.ParseDuration(maxWaitStr)
+		if err != nil || parsed <= 0 {
+			s.writeError(w, http.StatusBadRequest, invalidField("max_wait", "invalid max_wait: %s", maxWaitStr))
+			return
+		}
+		maxWait = parsed
+	}
+
+	run, err := s.scheduler.RunSmart(jobName, maxWait)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.audit(r, AuditActionJobExecute, jobName, map[string]interface{}{
+		"mode":          "smart",
+		"planned_start": run.PlannedStart,
+	})
+
+	s.writeSuccess(w, run)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// timelineWindow is the range of the timeline without start and end
+	timelineWindow = 24 * time.Hour
+	// timelineLimit caps the number of executions on the timeline
+	timelineLimit = 1000
+)
+
+// handleGetTimeline returns the executions of all jobs between start and
+// end, by default the last 24 hours, as intervals with their queue wait
+// and overlapping executions for rendering Gantt views
+func (s *Server) handleGetTimeline(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	end := time.Now()
+	limit := timelineLimit
+
+	if endStr := query.Get("end"); endStr != "" {
+		parsed, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("end", "invalid end time: %v", err))
+			return
+		}
+		end = parsed
+	}
+
+	start := end.Add(-timelineWindow)
+	if startStr := query.Get("start"); startStr != "" {
+		parsed, err := time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, invalidField("start", "invalid start time: %v", err))
+			return
+		}
+		start = parsed
+	}
+	if !start.Before(end) {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("start must be before end"))
+		return
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			s.writeError(w, http.StatusBadRequest, invalidField("limit", "invalid limit: %s", limitStr))
+			return
+		}
+		limit = parsed
+	}
+
+	timeline, err := s.store.WithContext(r.Context()).GetTimeline(start, end, limit)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.scopeTimeline(r, timeline)
+
+	s.writeSuccess(w, timeline)
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Time allowed to write a message to the peer
+	wsWriteWait = 10 * time.Second
+	// Time allowed to read the next pong message from the peer
+	wsPongWait = 60 * time.Second
+	// Send pings to the peer with this period; must be less than wsPongWait
+	wsPingPeriod = (wsPongWait * 9) / 10
+	// Maximum message size accepted from the peer
+	wsMaxMessageSize = 512
+)
+
+// wsRegistry tracks open WebSocket connections and enforces a cap
+type wsRegistry struct {
+	mutex sync.Mutex
+	conns map[*websocket.Conn]struct{}
+	max   int
+}
+
+// newWSRegistry creates a registry allowing at most max connections
+func newWSRegistry(max int) *wsRegistry {
+	return &wsRegistry{
+		conns: make(map[*websocket.Conn]struct{}),
+		max:   max,
+	}
+}
+
+// add registers a connection, returning false when the cap is reached
+func (r *wsRegistry) add(conn *websocket.Conn) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.max > 0 && len(r.conns) >= r.max {
+		return false
+	}
+	r.conns[conn] = struct{}{}
+	return true
+}
+
+// remove unregisters a connection
+func (r *wsRegistry) remove(conn *websocket.Conn) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.conns, conn)
+}
+
+// count returns the number of open connections
+func (r *wsRegistry) count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.conns)
+}
+
+// full reports whether the connection cap has been reached
+func (r *wsRegistry) full() bool {
+	return r.max > 0 && r.count() >= r.max
+}
+
+// closeAll sends a going-away close frame to every open connection
+func (r *wsRegistry) closeAll() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	deadline := time.Now().Add(wsWriteWait)
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for conn := range r.conns {
+		conn.WriteControl(websocket.CloseMessage, msg, deadline)
+		conn.Close()
+	}
+}
+
+// WebSocketConnections returns the number of open WebSocket connections
+func (s *Server) WebSocketConnections() int {
+	return s.wsConns.count()
+}
+
+// serveWebSocket upgrades the request and pushes the value returned by
+// next every interval until the client disconnects. A nil value skips
+// the update.
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request, interval time.Duration, next func() interface{}) {
+	if s.wsConns.full() {
+		s.writeError(w, http.StatusServiceUnavailable, fmt.Errorf("too many WebSocket connections"))
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logrus.Errorf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	if !s.wsConns.add(conn) {
+		msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too many connections")
+		conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteWait))
+		return
+	}
+	defer s.wsConns.remove(conn)
+
+	done := make(chan struct{})
+	go readPump(conn, done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	pingTicker := time.NewTicker(wsPingPeriod)
+	defer pingTicker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			update := next()
+			if update == nil {
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(update); err != nil {
+				logrus.Debugf("WebSocket write error: %v", err)
+				return
+			}
+		case <-pingTicker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				logrus.Debugf("WebSocket ping error: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// readPump drains incoming messages so that control frames (pong and
+// close) are processed, and closes done once the peer goes away
+func readPump(conn *websocket.Conn, done chan struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		return nil
+	})
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				logrus.Debugf("WebSocket read error: %v", err)
+			}
+			return
+		}
+	}
+}
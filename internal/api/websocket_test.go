@@ -0,0 +1,153 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/makalin/arcron/internal/config"
+)
+
+// newWebSocketServer serves a WebSocket pushing an update every few
+// milliseconds, returning its URL and a channel receiving a value each
+// time a handler returns
+func newWebSocketServer(t *testing.T, server *Server) (string, chan struct{}) {
+	t.Helper()
+
+	returned := make(chan struct{}, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() { returned <- struct{}{} }()
+		server.serveWebSocket(w, r, 10*time.Millisecond, func() interface{} {
+			return map[string]string{"status": "ok"}
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	return "ws" + strings.TrimPrefix(ts.URL, "http"), returned
+}
+
+// waitForConnections waits until the server counts n open connections
+func waitForConnections(t *testing.T, server *Server, n int) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); server.WebSocketConnections() != n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("open WebSocket connections = %d, want %d", server.WebSocketConnections(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// waitForReturn waits until a WebSocket handler returned
+func waitForReturn(t *testing.T, returned chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("WebSocket handler did not return")
+	}
+}
+
+func TestWebSocketClientDisconnect(t *testing.T) {
+	server := newTestServer(t, &config.Config{})
+	url, returned := newWebSocketServer(t, server)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	var update map[string]string
+	if err := conn.ReadJSON(&update); err != nil {
+		t.Fatalf("ReadJSON() error = %v", err)
+	}
+	if update["status"] != "ok" {
+		t.Errorf("update = %v, want status ok", update)
+	}
+	waitForConnections(t, server, 1)
+
+	// The read pump notices the close and the handler stops pushing
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	conn.Close()
+
+	waitForReturn(t, returned)
+	waitForConnections(t, server, 0)
+}
+
+func TestWebSocketConnectionCap(t *testing.T) {
+	server := newTestServer(t, &config.Config{Server: config.ServerConfig{MaxWebSocketConns: 1}})
+	url, returned := newWebSocketServer(t, server)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	waitForConnections(t, server, 1)
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("Dial() past the connection cap succeeded")
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Dial() past the connection cap response = %v, want status 503", resp)
+	}
+	waitForReturn(t, returned)
+	if n := server.WebSocketConnections(); n != 1 {
+		t.Errorf("open WebSocket connections = %d, want 1", n)
+	}
+}
+
+func TestWebSocketRegistryCap(t *testing.T) {
+	registry := newWSRegistry(1)
+
+	if !registry.add(&websocket.Conn{}) {
+		t.Fatal("add() refused the first connection")
+	}
+	if registry.add(&websocket.Conn{}) {
+		t.Error("add() accepted a connection past the cap")
+	}
+	if !registry.full() {
+		t.Error("full() = false with the cap reached")
+	}
+}
+
+func TestWebSocketCloseAllOnShutdown(t *testing.T) {
+	server := newTestServer(t, &config.Config{})
+	url, returned := newWebSocketServer(t, server)
+
+	conns := make([]*websocket.Conn, 2)
+	for i := range conns {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+	}
+	waitForConnections(t, server, len(conns))
+
+	server.wsConns.closeAll()
+
+	for _, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			_, _, err := conn.ReadMessage()
+			if err == nil {
+				continue // An update sent before the close frame
+			}
+			if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+				t.Errorf("ReadMessage() error = %v, want a going-away close", err)
+			}
+			break
+		}
+	}
+	for range conns {
+		waitForReturn(t, returned)
+	}
+	waitForConnections(t, server, 0)
+}
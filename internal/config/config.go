@@ -1,12 +1,13 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"time"
 
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
-	"gopkg.in/yaml.v3"
 )
 
 // Config represents the main configuration structure
@@ -14,46 +15,395 @@ type Config struct {
 	Server   ServerConfig   `yaml:"server" mapstructure:"server"`
 	Database DatabaseConfig `yaml:"database" mapstructure:"database"`
 	Jobs     []JobConfig    `yaml:"jobs" mapstructure:"jobs"`
-	ML       MLConfig       `yaml:"ml" mapstructure:"ml"`
-	Logging  LoggingConfig  `yaml:"logging" mapstructure:"logging"`
-	Advanced AdvancedConfig `yaml:"advanced" mapstructure:"advanced"`
-	Alerts   AlertsConfig   `yaml:"alerts" mapstructure:"alerts"`
-	Thresholds ThresholdsConfig `yaml:"thresholds" mapstructure:"thresholds"`
+	// JobsDir is a directory of YAML, JSON or TOML files each defining
+	// more jobs under a "jobs" key, merged with Jobs at load and watched
+	// for changes
+	JobsDir string `yaml:"jobs_dir,omitempty" mapstructure:"jobs_dir"`
+	// DataDir is the directory relative paths of the files arcron writes
+	// resolve against: the SQLite database, backups, artifacts, the ML
+	// model and the logs. Pointing it at a writable volume lets arcron run
+	// with a read-only root filesystem.
+	DataDir string `yaml:"data_dir,omitempty" mapstructure:"data_dir"`
+	// JobTemplates are expanded into the jobs instantiating them at load
+	JobTemplates []JobTemplate    `yaml:"job_templates,omitempty" mapstructure:"job_templates"`
+	ML           MLConfig         `yaml:"ml" mapstructure:"ml"`
+	Logging      LoggingConfig    `yaml:"logging" mapstructure:"logging"`
+	Advanced     AdvancedConfig   `yaml:"advanced" mapstructure:"advanced"`
+	Alerts       AlertsConfig     `yaml:"alerts" mapstructure:"alerts"`
+	Thresholds   ThresholdsConfig `yaml:"thresholds" mapstructure:"thresholds"`
+	Security     SecurityConfig   `yaml:"security" mapstructure:"security"`
+	Tracing      TracingConfig    `yaml:"tracing" mapstructure:"tracing"`
+	Metrics      MetricsConfig    `yaml:"metrics" mapstructure:"metrics"`
+	Monitoring   MonitoringConfig `yaml:"monitoring" mapstructure:"monitoring"`
+	Secrets      SecretsConfig    `yaml:"secrets" mapstructure:"secrets"`
+	Artifacts    ArtifactsConfig  `yaml:"artifacts" mapstructure:"artifacts"`
+	// Namespaces set limits of the namespaces jobs belong to; namespaces
+	// of jobs that are not listed have no limits
+	Namespaces []NamespaceConfig `yaml:"namespaces" mapstructure:"namespaces"`
+	// RateLimits limit how many runs start within a window, for all jobs
+	// or a group of them
+	RateLimits []RateLimitConfig `yaml:"rate_limits" mapstructure:"rate_limits"`
+}
+
+// DefaultNamespace is the namespace of jobs that do not set one
+const DefaultNamespace = "default"
+
+// NamespaceConfig holds the limits of a namespace
+type NamespaceConfig struct {
+	Name string `yaml:"name" mapstructure:"name"`
+	// MaxConcurrentJobs is how many jobs of the namespace may run at
+	// once; further runs wait for a slot. Zero means no limit.
+	MaxConcurrentJobs int `yaml:"max_concurrent_jobs" mapstructure:"max_concurrent_jobs"`
+}
+
+// RateLimitConfig limits how many runs of a group of jobs start within a
+// window, protecting the systems the jobs call from many runs starting at
+// once when their schedules align
+type RateLimitConfig struct {
+	Name string `yaml:"name" mapstructure:"name"`
+	// MaxStarts is how many runs may start within any Window; further
+	// runs wait, in order, until they may start
+	MaxStarts int           `yaml:"max_starts" mapstructure:"max_starts"`
+	Window    time.Duration `yaml:"window" mapstructure:"window"`
+	// Namespace and Labels select the jobs of the group; a limit selecting
+	// neither applies to all jobs
+	Namespace string            `yaml:"namespace,omitempty" mapstructure:"namespace"`
+	Labels    map[string]string `yaml:"labels,omitempty" mapstructure:"labels"`
+}
+
+// Matches reports whether a job is in the group of the rate limit
+func (r RateLimitConfig) Matches(job JobConfig) bool {
+	if r.Namespace != "" && r.Namespace != job.GetNamespace() {
+		return false
+	}
+	for key, value := range r.Labels {
+		if job.Labels[key] != value {
+			return false
+		}
+	}
+	return true
 }
 
 // ServerConfig holds server-related configuration
 type ServerConfig struct {
-	Host         string        `yaml:"host" mapstructure:"host"`
-	Port         int           `yaml:"port" mapstructure:"port"`
-	ReadTimeout  time.Duration `yaml:"read_timeout" mapstructure:"read_timeout"`
-	WriteTimeout time.Duration `yaml:"write_timeout" mapstructure:"write_timeout"`
+	Host              string        `yaml:"host" mapstructure:"host"`
+	Port              int           `yaml:"port" mapstructure:"port"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" mapstructure:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" mapstructure:"write_timeout"`
+	MaxWebSocketConns int           `yaml:"max_websocket_conns" mapstructure:"max_websocket_conns"`
+	// DrainTimeout is how long shutdown waits for running jobs, with
+	// /readyz failing and no new runs started, before stopping
+	DrainTimeout time.Duration `yaml:"drain_timeout" mapstructure:"drain_timeout"`
+	// ExternalURL is where users reach the server, used for links in
+	// alerts; defaults to http://host:port
+	ExternalURL string `yaml:"external_url" mapstructure:"external_url"`
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies
+	// whose X-Forwarded-For and X-Real-IP headers name the client
+	TrustedProxies []string `yaml:"trusted_proxies" mapstructure:"trusted_proxies"`
 }
 
 // DatabaseConfig holds database configuration
 type DatabaseConfig struct {
-	Driver   string `yaml:"driver" mapstructure:"driver"`
-	DSN      string `yaml:"dsn" mapstructure:"dsn"`
-	MaxConns int    `yaml:"max_conns" mapstructure:"max_conns"`
+	Driver     string           `yaml:"driver" mapstructure:"driver"`
+	DSN        string           `yaml:"dsn" mapstructure:"dsn"`
+	MaxConns   int              `yaml:"max_conns" mapstructure:"max_conns"`
+	Retention  RetentionConfig  `yaml:"retention" mapstructure:"retention"`
+	TimeSeries TimeSeriesConfig `yaml:"timeseries" mapstructure:"timeseries"`
+	Cleanup    CleanupConfig    `yaml:"cleanup" mapstructure:"cleanup"`
+	Backup     BackupConfig     `yaml:"backup" mapstructure:"backup"`
+	Batch      BatchConfig      `yaml:"batch" mapstructure:"batch"`
+	// ReadReplicas are DSNs of read-only copies of the database that serve
+	// analytical queries, e.g. metrics history read by the ML detectors
+	ReadReplicas     []string      `yaml:"read_replicas" mapstructure:"read_replicas"`
+	QueryTimeout     time.Duration `yaml:"query_timeout" mapstructure:"query_timeout"`
+	AnalyticsTimeout time.Duration `yaml:"analytics_timeout" mapstructure:"analytics_timeout"`
+}
+
+// TimeSeriesConfig selects where system metrics are stored. The default
+// backend keeps them in the main database.
+type TimeSeriesConfig struct {
+	Backend string        `yaml:"backend" mapstructure:"backend"`
+	URL     string        `yaml:"url" mapstructure:"url"`
+	Token   string        `yaml:"token" mapstructure:"token"`
+	Org     string        `yaml:"org" mapstructure:"org"`
+	Bucket  string        `yaml:"bucket" mapstructure:"bucket"`
+	Timeout time.Duration `yaml:"timeout" mapstructure:"timeout"`
+}
+
+// RetentionConfig holds system metrics rollup and retention configuration
+type RetentionConfig struct {
+	RollupInterval time.Duration `yaml:"rollup_interval" mapstructure:"rollup_interval"`
+	Raw            time.Duration `yaml:"raw" mapstructure:"raw"`
+	Minute         time.Duration `yaml:"minute" mapstructure:"minute"`
+	Hour           time.Duration `yaml:"hour" mapstructure:"hour"`
+}
+
+// CleanupConfig holds how often old records are deleted and how long each
+// table keeps them. Retentions left unset default to advanced.cleanup_after.
+// System metrics are kept as set in RetentionConfig instead.
+type CleanupConfig struct {
+	Interval    time.Duration `yaml:"interval" mapstructure:"interval"`
+	Executions  time.Duration `yaml:"executions" mapstructure:"executions"`
+	Predictions time.Duration `yaml:"predictions" mapstructure:"predictions"`
+	Forecasts   time.Duration `yaml:"forecasts" mapstructure:"forecasts"`
+	Adjustments time.Duration `yaml:"adjustments" mapstructure:"adjustments"`
+	Anomalies   time.Duration `yaml:"anomalies" mapstructure:"anomalies"`
+	Alerts      time.Duration `yaml:"alerts" mapstructure:"alerts"`
+	Audit       time.Duration `yaml:"audit" mapstructure:"audit"`
+	// Vacuum reclaims the space of deleted records after each cleanup
+	Vacuum bool `yaml:"vacuum" mapstructure:"vacuum"`
+}
+
+// BatchConfig holds how system metrics samples are buffered and written in
+// batches. Samples arriving while QueueSize are waiting are dropped. A
+// Size of 1 or less writes every sample on its own.
+type BatchConfig struct {
+	Size          int           `yaml:"size" mapstructure:"size"`
+	FlushInterval time.Duration `yaml:"flush_interval" mapstructure:"flush_interval"`
+	QueueSize     int           `yaml:"queue_size" mapstructure:"queue_size"`
+}
+
+// BackupConfig holds scheduled database backups. Backups are written to
+// Directory, of which the newest Keep are retained, and uploaded to S3 if
+// a bucket is configured. An Interval of zero disables them.
+type BackupConfig struct {
+	Interval  time.Duration `yaml:"interval" mapstructure:"interval"`
+	Directory string        `yaml:"directory" mapstructure:"directory"`
+	Keep      int           `yaml:"keep" mapstructure:"keep"`
+	S3        S3Config      `yaml:"s3" mapstructure:"s3"`
+	// MaxRestoreSize is the largest backup, in bytes, accepted by
+	// POST /admin/restore
+	MaxRestoreSize int64 `yaml:"max_restore_size" mapstructure:"max_restore_size"`
+}
+
+// S3Config holds an S3 or S3-compatible bucket backups are uploaded to
+type S3Config struct {
+	Endpoint        string        `yaml:"endpoint" mapstructure:"endpoint"`
+	Region          string        `yaml:"region" mapstructure:"region"`
+	Bucket          string        `yaml:"bucket" mapstructure:"bucket"`
+	Prefix          string        `yaml:"prefix" mapstructure:"prefix"`
+	AccessKeyID     string        `yaml:"access_key_id" mapstructure:"access_key_id"`
+	SecretAccessKey string        `yaml:"secret_access_key" mapstructure:"secret_access_key"`
+	Timeout         time.Duration `yaml:"timeout" mapstructure:"timeout"`
+}
+
+// ArtifactsConfig holds where the files collected from job runs are
+// kept: in Directory, or in an S3 bucket if one is configured. Files
+// larger than MaxSize bytes are not collected.
+type ArtifactsConfig struct {
+	Directory string   `yaml:"directory" mapstructure:"directory"`
+	MaxSize   int64    `yaml:"max_size" mapstructure:"max_size"`
+	S3        S3Config `yaml:"s3" mapstructure:"s3"`
 }
 
 // JobConfig represents a single job configuration
 type JobConfig struct {
-	Name        string            `yaml:"name" mapstructure:"name"`
-	Command     string            `yaml:"command" mapstructure:"command"`
-	Type        string            `yaml:"type" mapstructure:"type"`
-	Schedule    string            `yaml:"schedule" mapstructure:"schedule"`
+	Name     string `yaml:"name" mapstructure:"name"`
+	Command  string `yaml:"command" mapstructure:"command"`
+	Type     string `yaml:"type" mapstructure:"type"`
+	Schedule string `yaml:"schedule" mapstructure:"schedule"`
+	// Timeout bounds each run of the job; zero means the global
+	// advanced.default_timeout
 	Timeout     time.Duration     `yaml:"timeout" mapstructure:"timeout"`
 	Retries     int               `yaml:"retries" mapstructure:"retries"`
 	Environment map[string]string `yaml:"environment" mapstructure:"environment"`
 	Priority    int               `yaml:"priority" mapstructure:"priority"`
+	// Labels are free-form attributes of the job, e.g. team or tier,
+	// that alert routes match on
+	Labels map[string]string `yaml:"labels" mapstructure:"labels"`
+	// Gate overrides the global resource gate limits for this job
+	Gate ResourceLimits `yaml:"gate" mapstructure:"gate"`
+	// Adaptive lets the intelligent scheduler move runs of this job;
+	// unset means true
+	Adaptive *bool `yaml:"adaptive,omitempty" mapstructure:"adaptive"`
+	// Adjustment overrides the global adjustment limits for this job
+	Adjustment AdjustmentLimits `yaml:"adjustment" mapstructure:"adjustment"`
+	// DryRun overrides the global advisory mode for this job
+	DryRun *bool `yaml:"dry_run,omitempty" mapstructure:"dry_run"`
+	// Alerts overrides the global alerting policy for this job
+	Alerts JobAlertsConfig `yaml:"alerts" mapstructure:"alerts"`
+	// Namespace isolates the job in the API and bounds its concurrency
+	// together with the other jobs of the namespace; empty means the
+	// default namespace
+	Namespace string `yaml:"namespace,omitempty" mapstructure:"namespace"`
+	// Artifacts are glob patterns of files the job produces, e.g.
+	// reports, collected after each run
+	Artifacts []string `yaml:"artifacts,omitempty" mapstructure:"artifacts"`
+	// Metrics extract values from the output of each run, stored with the
+	// execution and exported to Prometheus
+	Metrics []OutputMetric `yaml:"metrics,omitempty" mapstructure:"metrics"`
+	// Source is the file in the jobs directory defining the job, SourceAPI
+	// for jobs synced through the API; empty for jobs of the main
+	// configuration file
+	Source string `yaml:"-" mapstructure:"-"`
+}
+
+// SourceAPI is the Source of jobs managed through the job sync endpoint
+// rather than configuration files
+const SourceAPI = "api"
+
+// Output metric types
+const (
+	// OutputGauge exports the value of the latest run
+	OutputGauge = "gauge"
+	// OutputCounter exports the sum of the values of all runs
+	OutputCounter = "counter"
+)
+
+// OutputMetric is a rule extracting a value from the output of a job.
+// Regex matches the output, its first group being the value; the last
+// match counts. JSONPath is instead a dotted path such as $.stats.rows
+// into the last line of the output holding a JSON object with it. Exactly
+// one of them is set.
+type OutputMetric struct {
+	Name     string `yaml:"name" mapstructure:"name"`
+	Regex    string `yaml:"regex,omitempty" mapstructure:"regex"`
+	JSONPath string `yaml:"json_path,omitempty" mapstructure:"json_path"`
+	// Type is gauge (the default) or counter
+	Type string `yaml:"type,omitempty" mapstructure:"type"`
+}
+
+// JobAlertsConfig is the alerting policy of a job. Unset fields fall back
+// to the global alerts configuration.
+type JobAlertsConfig struct {
+	// Enabled turns alerts for the job off; unset means true
+	Enabled *bool `yaml:"enabled,omitempty" mapstructure:"enabled"`
+	// NotifyOn lists the events alerted on: failure, success, missed, sla,
+	// recovered
+	NotifyOn []string `yaml:"notify_on" mapstructure:"notify_on"`
+	// Channels limits alerts to these channels, e.g. email or slack
+	Channels []string `yaml:"channels" mapstructure:"channels"`
+	// MinSeverity is the lowest level alerted on: info, warning, error
+	// or critical
+	MinSeverity string `yaml:"min_severity" mapstructure:"min_severity"`
+	// SLA is how long a run may take before an sla event is raised;
+	// zero disables the check
+	SLA time.Duration `yaml:"sla" mapstructure:"sla"`
+}
+
+// IsAdaptive reports whether the intelligent scheduler may move runs of
+// the job
+func (j JobConfig) IsAdaptive() bool {
+	return j.Adaptive == nil || *j.Adaptive
+}
+
+// GetNamespace returns the namespace of the job
+func (j JobConfig) GetNamespace() string {
+	if j.Namespace == "" {
+		return DefaultNamespace
+	}
+	return j.Namespace
+}
+
+// IsDryRun reports whether adjustments of the job are only advised, given
+// the global advisory mode
+func (j JobConfig) IsDryRun(global bool) bool {
+	if j.DryRun == nil {
+		return global
+	}
+	return *j.DryRun
+}
+
+// AdjustmentLimits bound how the intelligent scheduler may move a job's runs
+type AdjustmentLimits struct {
+	// MaxDelta is how far a run may be moved from its cron time
+	MaxDelta time.Duration `yaml:"max_delta" mapstructure:"max_delta"`
+	// MaxPerDay is how many runs may be moved in any 24 hours
+	MaxPerDay int `yaml:"max_per_day" mapstructure:"max_per_day"`
+}
+
+// QuietHoursConfig is a recurring window in which no schedule is adjusted,
+// e.g. during month-end processing. Start and end are times of day
+// ("15:04"); a window ending before it starts runs past midnight, and
+// without either the window covers whole days. Weekdays and days of the
+// month restrict the days the window starts on, negative days counting
+// from the end of the month (-1 is the last day).
+type QuietHoursConfig struct {
+	Name      string   `yaml:"name" mapstructure:"name"`
+	Start     string   `yaml:"start" mapstructure:"start"`
+	End       string   `yaml:"end" mapstructure:"end"`
+	Weekdays  []string `yaml:"weekdays,omitempty" mapstructure:"weekdays"`
+	MonthDays []int    `yaml:"month_days,omitempty" mapstructure:"month_days"`
+	// Timezone the window is evaluated in; empty means local time
+	Timezone string `yaml:"timezone,omitempty" mapstructure:"timezone"`
 }
 
 // MLConfig holds machine learning configuration
 type MLConfig struct {
-	ModelPath     string        `yaml:"model_path" mapstructure:"model_path"`
-	TrainingData  string        `yaml:"training_data" mapstructure:"training_data"`
+	ModelPath      string        `yaml:"model_path" mapstructure:"model_path"`
+	TrainingData   string        `yaml:"training_data" mapstructure:"training_data"`
 	UpdateInterval time.Duration `yaml:"update_interval" mapstructure:"update_interval"`
-	Features      []string      `yaml:"features" mapstructure:"features"`
+	Features       []string      `yaml:"features" mapstructure:"features"`
+	// Backend selects the predictor: "builtin" or "remote"
+	Backend        string                `yaml:"backend" mapstructure:"backend"`
+	Remote         RemotePredictorConfig `yaml:"remote" mapstructure:"remote"`
+	OnlineLearning OnlineLearningConfig  `yaml:"online_learning" mapstructure:"online_learning"`
+	// ForecastHorizon and ForecastInterval shape the load forecast the
+	// engine consults when predicting optimal times
+	ForecastHorizon  time.Duration `yaml:"forecast_horizon" mapstructure:"forecast_horizon"`
+	ForecastInterval time.Duration `yaml:"forecast_interval" mapstructure:"forecast_interval"`
+	// AccuracyWindow is how far back prediction accuracy is computed
+	AccuracyWindow time.Duration `yaml:"accuracy_window" mapstructure:"accuracy_window"`
+	// FallbackMAE is the mean absolute load error above which a job's
+	// predictions fall back to the heuristics
+	FallbackMAE float64 `yaml:"fallback_mae" mapstructure:"fallback_mae"`
+	// FallbackMinSamples is how many evaluated model predictions a job
+	// needs before it can fall back
+	FallbackMinSamples int `yaml:"fallback_min_samples" mapstructure:"fallback_min_samples"`
+	// ImpactThreshold is the average impact score, in percentage points
+	// of combined CPU and memory load, from which a job is measured to be
+	// resource-intensive
+	ImpactThreshold    float64                  `yaml:"impact_threshold" mapstructure:"impact_threshold"`
+	DurationRegression DurationRegressionConfig `yaml:"duration_regression" mapstructure:"duration_regression"`
+	Flakiness          FlakinessConfig          `yaml:"flakiness" mapstructure:"flakiness"`
+}
+
+// FlakinessConfig holds the scoring of jobs that alternate between failing
+// and passing, or only pass through retries
+type FlakinessConfig struct {
+	// Window is how many of the latest runs are scored
+	Window int `yaml:"window" mapstructure:"window"`
+	// MinRuns is how many finished runs a job needs before it is alerted on
+	MinRuns int `yaml:"min_runs" mapstructure:"min_runs"`
+	// Alert sends a flaky alert when the score of a job rises to Threshold
+	Alert     bool    `yaml:"alert" mapstructure:"alert"`
+	Threshold float64 `yaml:"threshold" mapstructure:"threshold"`
+}
+
+// DurationRegressionConfig holds the detection of jobs whose runs have
+// become significantly slower, tested after every completed run
+type DurationRegressionConfig struct {
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// Window is how many of the latest completed runs are tested
+	Window int `yaml:"window" mapstructure:"window"`
+	// MinRuns is how many completed runs a job needs before it is tested
+	MinRuns int `yaml:"min_runs" mapstructure:"min_runs"`
+	// Confidence is that of the Mann-Kendall test the upward trend must
+	// pass, e.g. 0.95
+	Confidence float64 `yaml:"confidence" mapstructure:"confidence"`
+	// MinIncrease is the percentage by which the later half of the window
+	// must be slower than the earlier half, so slight drifts are ignored
+	MinIncrease float64 `yaml:"min_increase" mapstructure:"min_increase"`
+	// Cooldown is how long a job is not reported again after a regression
+	Cooldown time.Duration `yaml:"cooldown" mapstructure:"cooldown"`
+}
+
+// OnlineLearningConfig holds configuration for updating the built-in model
+// from every completed execution
+type OnlineLearningConfig struct {
+	Enabled      bool    `yaml:"enabled" mapstructure:"enabled"`
+	LearningRate float64 `yaml:"learning_rate" mapstructure:"learning_rate"`
+	// HalfLife is the number of executions after which the influence of
+	// older observations on feature statistics has halved
+	HalfLife int `yaml:"half_life" mapstructure:"half_life"`
+}
+
+// RemotePredictorConfig holds configuration of an external prediction
+// service, e.g. a model server hosting an ONNX model
+type RemotePredictorConfig struct {
+	URL     string            `yaml:"url" mapstructure:"url"`
+	Timeout time.Duration     `yaml:"timeout" mapstructure:"timeout"`
+	Headers map[string]string `yaml:"headers" mapstructure:"headers"`
 }
 
 // LoggingConfig holds logging configuration
@@ -61,19 +411,98 @@ type LoggingConfig struct {
 	Level      string `yaml:"level" mapstructure:"level"`
 	Format     string `yaml:"format" mapstructure:"format"`
 	OutputFile string `yaml:"output_file" mapstructure:"output_file"`
+	// The output file is rotated once it grows past MaxSizeMB or has been
+	// written to for MaxAge, if set, keeping the newest MaxBackups rotated
+	// files
+	MaxSizeMB  int           `yaml:"max_size_mb" mapstructure:"max_size_mb"`
+	MaxAge     time.Duration `yaml:"max_age" mapstructure:"max_age"`
+	MaxBackups int           `yaml:"max_backups" mapstructure:"max_backups"`
+	// JobLogDir, if set, is a directory the log lines about each job are
+	// also written to, one <job>.log file per job
+	JobLogDir string `yaml:"job_log_dir" mapstructure:"job_log_dir"`
 }
 
 // AdvancedConfig holds advanced configuration
 type AdvancedConfig struct {
-	MetricsInterval    time.Duration `yaml:"metrics_interval" mapstructure:"metrics_interval"`
-	AdjustmentThreshold int          `yaml:"adjustment_threshold" mapstructure:"adjustment_threshold"`
-	MaxConcurrentJobs  int          `yaml:"max_concurrent_jobs" mapstructure:"max_concurrent_jobs"`
-	JobQueueSize       int          `yaml:"job_queue_size" mapstructure:"job_queue_size"`
-	CleanupAfter       time.Duration `yaml:"cleanup_after" mapstructure:"cleanup_after"`
-	EnableDashboard    bool         `yaml:"enable_dashboard" mapstructure:"enable_dashboard"`
-	DashboardAuth      DashboardAuthConfig `yaml:"dashboard_auth" mapstructure:"dashboard_auth"`
-	Prometheus         PrometheusConfig    `yaml:"prometheus" mapstructure:"prometheus"`
-	EnableAlerts       bool         `yaml:"enable_alerts" mapstructure:"enable_alerts"`
+	MetricsInterval     time.Duration `yaml:"metrics_interval" mapstructure:"metrics_interval"`
+	AdjustmentThreshold int           `yaml:"adjustment_threshold" mapstructure:"adjustment_threshold"`
+	// AdjustmentWindow is how far ahead runs are considered for adjustment;
+	// jobs running later are not predicted for
+	AdjustmentWindow  time.Duration       `yaml:"adjustment_window" mapstructure:"adjustment_window"`
+	MaxConcurrentJobs int                 `yaml:"max_concurrent_jobs" mapstructure:"max_concurrent_jobs"`
+	JobQueueSize      int                 `yaml:"job_queue_size" mapstructure:"job_queue_size"`
+	CleanupAfter      time.Duration       `yaml:"cleanup_after" mapstructure:"cleanup_after"`
+	EnableDashboard   bool                `yaml:"enable_dashboard" mapstructure:"enable_dashboard"`
+	DashboardAuth     DashboardAuthConfig `yaml:"dashboard_auth" mapstructure:"dashboard_auth"`
+	Prometheus        PrometheusConfig    `yaml:"prometheus" mapstructure:"prometheus"`
+	EnableAlerts      bool                `yaml:"enable_alerts" mapstructure:"enable_alerts"`
+	ResourceGate      ResourceGateConfig  `yaml:"resource_gate" mapstructure:"resource_gate"`
+	Adjustment        AdjustmentLimits    `yaml:"adjustment" mapstructure:"adjustment"`
+	// DryRun makes the scheduler log and expose the adjustments it would
+	// make without moving any runs
+	DryRun bool `yaml:"dry_run" mapstructure:"dry_run"`
+	// QuietHours are windows in which the intelligent scheduler moves no
+	// runs; jobs still run at their cron times
+	QuietHours []QuietHoursConfig `yaml:"quiet_hours" mapstructure:"quiet_hours"`
+	// Paused starts the scheduler in maintenance mode: no job is started
+	// until it is resumed through the API
+	Paused bool `yaml:"paused" mapstructure:"paused"`
+	// SmartRunMaxWait is the longest a manual run in smart mode is held
+	// back for the predicted optimal time
+	SmartRunMaxWait time.Duration `yaml:"smart_run_max_wait" mapstructure:"smart_run_max_wait"`
+	// DefaultTimeout bounds the runs of jobs that set no timeout
+	DefaultTimeout time.Duration `yaml:"default_timeout" mapstructure:"default_timeout"`
+	// MaxTimeout is the longest timeout a job may set; zero means no
+	// maximum
+	MaxTimeout time.Duration `yaml:"max_timeout" mapstructure:"max_timeout"`
+	// RegressionThreshold is the percentage by which a run may take longer
+	// than the average of the previous runs before a comparison flags it
+	// as a regression
+	RegressionThreshold float64 `yaml:"regression_threshold" mapstructure:"regression_threshold"`
+	// LoadShaping spaces out heavy jobs to keep the forecast load under a
+	// global target
+	LoadShaping LoadShapingConfig `yaml:"load_shaping" mapstructure:"load_shaping"`
+	// Stagger runs heavy jobs predicted to overlap one after the other
+	Stagger StaggerConfig `yaml:"stagger" mapstructure:"stagger"`
+	Debug   DebugConfig   `yaml:"debug" mapstructure:"debug"`
+}
+
+// LoadShapingConfig holds the global utilization target the intelligent
+// scheduler plans the runs of heavy jobs against, so that their
+// independently predicted times do not pile up in the same quiet window
+type LoadShapingConfig struct {
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// TargetUtilization is the combined CPU and memory load, in percent,
+	// the forecast load plus heavy jobs is kept under
+	TargetUtilization float64 `yaml:"target_utilization" mapstructure:"target_utilization"`
+	// DefaultImpact is the load, in percentage points, planned for heavy
+	// jobs whose impact has not been measured yet
+	DefaultImpact float64 `yaml:"default_impact" mapstructure:"default_impact"`
+}
+
+// StaggerConfig holds how the intelligent scheduler staggers heavy jobs
+// whose predicted runs would overlap, in the order of their priority
+type StaggerConfig struct {
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// Gap is left between the end of a heavy job and the start of the next
+	Gap time.Duration `yaml:"gap" mapstructure:"gap"`
+}
+
+// ResourceGateConfig holds the launch-time gate that defers
+// resource-intensive jobs while the system is busy
+type ResourceGateConfig struct {
+	Enabled       bool           `yaml:"enabled" mapstructure:"enabled"`
+	Limits        ResourceLimits `yaml:",inline" mapstructure:",squash"`
+	CheckInterval time.Duration  `yaml:"check_interval" mapstructure:"check_interval"`
+}
+
+// ResourceLimits holds the usage above which a job start is deferred and
+// how long it may be deferred. Zero values are unset.
+type ResourceLimits struct {
+	MaxCPU    float64       `yaml:"max_cpu" mapstructure:"max_cpu"`
+	MaxMemory float64       `yaml:"max_memory" mapstructure:"max_memory"`
+	MaxLoad   float64       `yaml:"max_load" mapstructure:"max_load"`
+	MaxDelay  time.Duration `yaml:"max_delay" mapstructure:"max_delay"`
 }
 
 // DashboardAuthConfig holds dashboard authentication configuration
@@ -81,6 +510,18 @@ type DashboardAuthConfig struct {
 	Enabled  bool   `yaml:"enabled" mapstructure:"enabled"`
 	Username string `yaml:"username" mapstructure:"username"`
 	Password string `yaml:"password" mapstructure:"password"`
+	// Tokens are API bearer tokens, accepted besides the user's
+	// credentials while authentication is enabled
+	Tokens []APITokenConfig `yaml:"tokens" mapstructure:"tokens"`
+}
+
+// APITokenConfig is an API bearer token. A token with a namespace only
+// sees and acts on the jobs, executions and alerts of that namespace, and
+// cannot use admin endpoints; one without has full access.
+type APITokenConfig struct {
+	Name      string `yaml:"name" mapstructure:"name"`
+	Token     string `yaml:"token" mapstructure:"token"`
+	Namespace string `yaml:"namespace" mapstructure:"namespace"`
 }
 
 // PrometheusConfig holds Prometheus metrics configuration
@@ -90,12 +531,117 @@ type PrometheusConfig struct {
 	Port    int    `yaml:"port" mapstructure:"port"`
 }
 
+// DebugConfig holds the pprof/expvar debug server configuration
+type DebugConfig struct {
+	Enabled bool   `yaml:"enabled" mapstructure:"enabled"`
+	Host    string `yaml:"host" mapstructure:"host"`
+	Port    int    `yaml:"port" mapstructure:"port"`
+}
+
 // AlertsConfig holds alerting configuration
 type AlertsConfig struct {
-	Enabled bool          `yaml:"enabled" mapstructure:"enabled"`
-	Email   EmailConfig   `yaml:"email" mapstructure:"email"`
-	Slack   SlackConfig   `yaml:"slack" mapstructure:"slack"`
-	Webhook WebhookConfig `yaml:"webhook" mapstructure:"webhook"`
+	Enabled bool `yaml:"enabled" mapstructure:"enabled"`
+	// NotifyOn lists the job events alerted on unless a job sets its own:
+	// failure, success, missed, sla and recovered
+	NotifyOn []string `yaml:"notify_on" mapstructure:"notify_on"`
+	// MinSeverity is the lowest level alerted on: info, warning, error or
+	// critical
+	MinSeverity string           `yaml:"min_severity" mapstructure:"min_severity"`
+	Email       EmailConfig      `yaml:"email" mapstructure:"email"`
+	Slack       SlackConfig      `yaml:"slack" mapstructure:"slack"`
+	Webhook     WebhookConfig    `yaml:"webhook" mapstructure:"webhook"`
+	PagerDuty   PagerDutyConfig  `yaml:"pagerduty" mapstructure:"pagerduty"`
+	Teams       TeamsConfig      `yaml:"teams" mapstructure:"teams"`
+	Discord     DiscordConfig    `yaml:"discord" mapstructure:"discord"`
+	Telegram    TelegramConfig   `yaml:"telegram" mapstructure:"telegram"`
+	Twilio      TwilioConfig     `yaml:"twilio" mapstructure:"twilio"`
+	Retry       AlertRetryConfig `yaml:"retry" mapstructure:"retry"`
+	// Routes pick the channels of each alert. Without routes every
+	// enabled channel receives every alert.
+	Routes []AlertRoute `yaml:"routes" mapstructure:"routes"`
+}
+
+// AlertRetryConfig holds how failed alert deliveries are retried. After
+// MaxAttempts, or if QueueSize deliveries are already waiting, a delivery
+// is moved to the dead-letter table.
+type AlertRetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts" mapstructure:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" mapstructure:"max_backoff"`
+	QueueSize      int           `yaml:"queue_size" mapstructure:"queue_size"`
+}
+
+// AlertRoute sends the alerts it matches to a set of channels. Routes are
+// evaluated in order and the first match wins unless it continues.
+type AlertRoute struct {
+	Name     string     `yaml:"name" mapstructure:"name"`
+	Match    AlertMatch `yaml:"match" mapstructure:"match"`
+	Channels []string   `yaml:"channels" mapstructure:"channels"`
+	// SlackChannel posts matched alerts to this Slack channel instead of
+	// the configured one
+	SlackChannel string `yaml:"slack_channel" mapstructure:"slack_channel"`
+	// Continue evaluates the following routes after a match
+	Continue bool `yaml:"continue" mapstructure:"continue"`
+}
+
+// AlertMatch selects alerts by their attributes. Empty fields match
+// everything.
+type AlertMatch struct {
+	Levels []string `yaml:"levels" mapstructure:"levels"`
+	Jobs   []string `yaml:"jobs" mapstructure:"jobs"`
+	// Labels must all be set to these values on the alert's job
+	Labels map[string]string `yaml:"labels" mapstructure:"labels"`
+	// Hours is a time of day range such as "09:00-17:00"; ranges may
+	// wrap past midnight
+	Hours    string   `yaml:"hours" mapstructure:"hours"`
+	Weekdays []string `yaml:"weekdays" mapstructure:"weekdays"`
+	// Timezone of hours and weekdays, local time if empty
+	Timezone string `yaml:"timezone" mapstructure:"timezone"`
+}
+
+// TeamsConfig holds Microsoft Teams alert configuration
+type TeamsConfig struct {
+	Enabled    bool   `yaml:"enabled" mapstructure:"enabled"`
+	WebhookURL string `yaml:"webhook_url" mapstructure:"webhook_url"`
+	// Format is "messagecard" for Office 365 connectors or "adaptivecard"
+	// for Workflows webhooks
+	Format string `yaml:"format" mapstructure:"format"`
+}
+
+// DiscordConfig holds Discord webhook alert configuration
+type DiscordConfig struct {
+	Enabled    bool   `yaml:"enabled" mapstructure:"enabled"`
+	WebhookURL string `yaml:"webhook_url" mapstructure:"webhook_url"`
+	Username   string `yaml:"username" mapstructure:"username"`
+}
+
+// TelegramConfig holds Telegram bot alert configuration
+type TelegramConfig struct {
+	Enabled  bool   `yaml:"enabled" mapstructure:"enabled"`
+	BotToken string `yaml:"bot_token" mapstructure:"bot_token"`
+	ChatID   string `yaml:"chat_id" mapstructure:"chat_id"`
+	APIURL   string `yaml:"api_url" mapstructure:"api_url"`
+}
+
+// TwilioConfig holds Twilio SMS and voice call alert configuration. Only
+// alerts at MinSeverity or above, critical by default, are sent.
+type TwilioConfig struct {
+	Enabled    bool     `yaml:"enabled" mapstructure:"enabled"`
+	AccountSID string   `yaml:"account_sid" mapstructure:"account_sid"`
+	AuthToken  string   `yaml:"auth_token" mapstructure:"auth_token"`
+	From       string   `yaml:"from" mapstructure:"from"`
+	To         []string `yaml:"to" mapstructure:"to"`
+	// Mode is sms, voice or both
+	Mode        string `yaml:"mode" mapstructure:"mode"`
+	MinSeverity string `yaml:"min_severity" mapstructure:"min_severity"`
+	APIURL      string `yaml:"api_url" mapstructure:"api_url"`
+}
+
+// PagerDutyConfig holds PagerDuty Events API v2 alert configuration
+type PagerDutyConfig struct {
+	Enabled    bool   `yaml:"enabled" mapstructure:"enabled"`
+	RoutingKey string `yaml:"routing_key" mapstructure:"routing_key"`
+	URL        string `yaml:"url" mapstructure:"url"`
 }
 
 // EmailConfig holds email alert configuration
@@ -107,6 +653,21 @@ type EmailConfig struct {
 	Password string   `yaml:"password" mapstructure:"password"`
 	From     string   `yaml:"from" mapstructure:"from"`
 	To       []string `yaml:"to" mapstructure:"to"`
+	// Subject and Body are text/template templates replacing the
+	// default message
+	Subject string `yaml:"subject" mapstructure:"subject"`
+	Body    string `yaml:"body" mapstructure:"body"`
+	// HTMLBody is an html/template template adding an HTML version of
+	// the message
+	HTMLBody string `yaml:"html_body" mapstructure:"html_body"`
+	// Security is "auto" (implicit TLS on port 465, otherwise STARTTLS
+	// if the server offers it), "tls", "starttls" or "none"
+	Security string `yaml:"security" mapstructure:"security"`
+	// CAFile verifies the server with these CA certificates instead of
+	// the system ones
+	CAFile             string `yaml:"ca_file" mapstructure:"ca_file"`
+	ServerName         string `yaml:"server_name" mapstructure:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" mapstructure:"insecure_skip_verify"`
 }
 
 // SlackConfig holds Slack alert configuration
@@ -115,6 +676,9 @@ type SlackConfig struct {
 	WebhookURL string `yaml:"webhook_url" mapstructure:"webhook_url"`
 	Channel    string `yaml:"channel" mapstructure:"channel"`
 	Username   string `yaml:"username" mapstructure:"username"`
+	// Template is a text/template producing the JSON message payload,
+	// e.g. with blocks; channel and username are added unless it sets them
+	Template string `yaml:"template" mapstructure:"template"`
 }
 
 // WebhookConfig holds webhook alert configuration
@@ -123,14 +687,23 @@ type WebhookConfig struct {
 	URL     string            `yaml:"url" mapstructure:"url"`
 	Method  string            `yaml:"method" mapstructure:"method"`
 	Headers map[string]string `yaml:"headers" mapstructure:"headers"`
+	// Template is a text/template producing the JSON request body in
+	// place of the alert
+	Template string `yaml:"template" mapstructure:"template"`
 }
 
 // ThresholdsConfig holds monitoring thresholds
 type ThresholdsConfig struct {
-	CPU     ThresholdLevels `yaml:"cpu" mapstructure:"cpu"`
-	Memory  ThresholdLevels `yaml:"memory" mapstructure:"memory"`
-	Disk    ThresholdLevels `yaml:"disk" mapstructure:"disk"`
-	Network ThresholdLevels `yaml:"network" mapstructure:"network"`
+	CPU             ThresholdLevels `yaml:"cpu" mapstructure:"cpu"`
+	Memory          ThresholdLevels `yaml:"memory" mapstructure:"memory"`
+	Disk            ThresholdLevels `yaml:"disk" mapstructure:"disk"`
+	Network         ThresholdLevels `yaml:"network" mapstructure:"network"`
+	GPU             ThresholdLevels `yaml:"gpu" mapstructure:"gpu"`
+	CPUTemperature  ThresholdLevels `yaml:"cpu_temperature" mapstructure:"cpu_temperature"`
+	DiskTemperature ThresholdLevels `yaml:"disk_temperature" mapstructure:"disk_temperature"`
+	// Hysteresis is how far a value must drop below a threshold
+	// before the alert clears
+	Hysteresis float64 `yaml:"hysteresis" mapstructure:"hysteresis"`
 }
 
 // ThresholdLevels holds warning and critical thresholds
@@ -139,35 +712,206 @@ type ThresholdLevels struct {
 	Critical float64 `yaml:"critical" mapstructure:"critical"`
 }
 
-// Load loads configuration from file
+// SecurityConfig holds the sandbox policy applied to job commands
+type SecurityConfig struct {
+	AllowedCommands     []string `yaml:"allowed_commands" mapstructure:"allowed_commands"`
+	AllowedPathPrefixes []string `yaml:"allowed_path_prefixes" mapstructure:"allowed_path_prefixes"`
+	DenyShell           bool     `yaml:"deny_shell" mapstructure:"deny_shell"`
+	AllowedEnv          []string `yaml:"allowed_env" mapstructure:"allowed_env"`
+	DeniedEnv           []string `yaml:"denied_env" mapstructure:"denied_env"`
+}
+
+// SecretsConfig holds the secret stores that ${vault:path#key} and
+// ${aws-sm:name} placeholders in the configuration are resolved from
+type SecretsConfig struct {
+	Vault   VaultConfig      `yaml:"vault" mapstructure:"vault"`
+	AWS     AWSSecretsConfig `yaml:"aws" mapstructure:"aws"`
+	Timeout time.Duration    `yaml:"timeout" mapstructure:"timeout"`
+}
+
+// VaultConfig holds HashiCorp Vault access. Address and Token default to
+// the VAULT_ADDR and VAULT_TOKEN environment variables.
+type VaultConfig struct {
+	Address   string `yaml:"address" mapstructure:"address"`
+	Token     string `yaml:"token" mapstructure:"token"`
+	Namespace string `yaml:"namespace" mapstructure:"namespace"`
+}
+
+// AWSSecretsConfig holds AWS Secrets Manager access. Unset fields default
+// to the standard AWS_REGION, AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN environment variables.
+type AWSSecretsConfig struct {
+	Region          string `yaml:"region" mapstructure:"region"`
+	AccessKeyID     string `yaml:"access_key_id" mapstructure:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key" mapstructure:"secret_access_key"`
+	SessionToken    string `yaml:"session_token" mapstructure:"session_token"`
+	// Endpoint replaces the regional endpoint, e.g. for a VPC endpoint
+	Endpoint string `yaml:"endpoint" mapstructure:"endpoint"`
+}
+
+// TracingConfig holds OpenTelemetry tracing configuration
+type TracingConfig struct {
+	Enabled     bool              `yaml:"enabled" mapstructure:"enabled"`
+	Endpoint    string            `yaml:"endpoint" mapstructure:"endpoint"`
+	URLPath     string            `yaml:"url_path" mapstructure:"url_path"`
+	Insecure    bool              `yaml:"insecure" mapstructure:"insecure"`
+	Headers     map[string]string `yaml:"headers" mapstructure:"headers"`
+	ServiceName string            `yaml:"service_name" mapstructure:"service_name"`
+	SampleRatio float64           `yaml:"sample_ratio" mapstructure:"sample_ratio"`
+}
+
+// MetricsConfig holds configuration for metrics backends other than the
+// Prometheus scrape endpoint
+type MetricsConfig struct {
+	StatsD      StatsDConfig      `yaml:"statsd" mapstructure:"statsd"`
+	Pushgateway PushgatewayConfig `yaml:"pushgateway" mapstructure:"pushgateway"`
+}
+
+// StatsDConfig holds StatsD/DogStatsD emitter configuration
+type StatsDConfig struct {
+	Enabled  bool          `yaml:"enabled" mapstructure:"enabled"`
+	Address  string        `yaml:"address" mapstructure:"address"`
+	Prefix   string        `yaml:"prefix" mapstructure:"prefix"`
+	Tags     []string      `yaml:"tags" mapstructure:"tags"`
+	Interval time.Duration `yaml:"interval" mapstructure:"interval"`
+}
+
+// PushgatewayConfig holds Prometheus Pushgateway configuration
+type PushgatewayConfig struct {
+	Enabled  bool          `yaml:"enabled" mapstructure:"enabled"`
+	URL      string        `yaml:"url" mapstructure:"url"`
+	Job      string        `yaml:"job" mapstructure:"job"`
+	Instance string        `yaml:"instance" mapstructure:"instance"`
+	Username string        `yaml:"username" mapstructure:"username"`
+	Password string        `yaml:"password" mapstructure:"password"`
+	Timeout  time.Duration `yaml:"timeout" mapstructure:"timeout"`
+}
+
+// MonitoringConfig holds system metrics collection configuration
+type MonitoringConfig struct {
+	// Mode selects where CPU and memory usage is read from: "host",
+	// "cgroup" or "auto", which uses cgroup limits when they are set
+	Mode       string       `yaml:"mode" mapstructure:"mode"`
+	Disks      DeviceFilter `yaml:"disks" mapstructure:"disks"`
+	Interfaces DeviceFilter `yaml:"interfaces" mapstructure:"interfaces"`
+	// GPU enables NVIDIA GPU metrics through NVML
+	GPU bool `yaml:"gpu" mapstructure:"gpu"`
+	// Temperatures enables CPU and disk temperature sensors
+	Temperatures bool `yaml:"temperatures" mapstructure:"temperatures"`
+
+	Smoothing SmoothingConfig `yaml:"smoothing" mapstructure:"smoothing"`
+	// StaleAfter is the age past which metrics are stale and jobs run on
+	// their cron schedules; zero means three collection intervals
+	StaleAfter time.Duration `yaml:"stale_after" mapstructure:"stale_after"`
+}
+
+// SmoothingConfig selects the window of smoothed metrics, 1m, 5m or 15m,
+// that scheduling decisions are based on
+type SmoothingConfig struct {
+	// PredictionWindow smooths the metrics optimal times are predicted from
+	PredictionWindow time.Duration `yaml:"prediction_window" mapstructure:"prediction_window"`
+	// GateWindow smooths the metrics the resource gate defers jobs on
+	GateWindow time.Duration `yaml:"gate_window" mapstructure:"gate_window"`
+}
+
+// DeviceFilter selects devices by glob pattern. An empty include list
+// includes every device not matched by exclude.
+type DeviceFilter struct {
+	Include []string `yaml:"include" mapstructure:"include"`
+	Exclude []string `yaml:"exclude" mapstructure:"exclude"`
+}
+
+// Load loads configuration from file. Environment variables named after
+// the keys, e.g. ARCRON_SERVER_PORT, override the file. With an empty
+// path the configuration comes from the environment alone, e.g. in a
+// container, with jobs from the jobs directory (ARCRON_JOBS_DIR).
 func Load(configPath string) (*Config, error) {
-	// Check if file exists
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+	return LoadWithFlags(configPath, nil)
+}
+
+// LoadWithFlags loads configuration from file like Load, with the flags
+// registered by RegisterFlags and set on fs overriding both the file and
+// the environment
+func LoadWithFlags(configPath string, fs *pflag.FlagSet) (*Config, error) {
+	return load(configPath, fs, false)
+}
+
+// LoadStrict loads configuration from file like Load, but rejects keys
+// that are not configuration settings, e.g. misspelled ones
+func LoadStrict(configPath string) (*Config, error) {
+	return load(configPath, nil, true)
+}
+
+// load loads configuration from file, rejecting unknown keys if strict
+func load(configPath string, fs *pflag.FlagSet, strict bool) (*Config, error) {
+	// Check if file exists; without a file the configuration comes from
+	// the environment and flags alone
+	if _, err := os.Stat(configPath); configPath != "" && os.IsNotExist(err) {
 		// Create default config if it doesn't exist
 		if err := createDefaultConfig(configPath); err != nil {
 			return nil, fmt.Errorf("failed to create default config: %v", err)
 		}
 	}
 
-	viper.SetConfigFile(configPath)
-	viper.SetConfigType("yaml")
-
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("failed to read config file: %v", err)
+	v, err := readConfig(configPath, fs)
+	if err != nil {
+		return nil, err
 	}
 
 	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
+	unmarshal := v.Unmarshal
+	if strict {
+		unmarshal = v.UnmarshalExact
+	}
+	if err := unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %v", err)
 	}
+	if config.JobsDir != "" {
+		dirJobs, err := LoadJobsDir(config.JobsDir, config.JobTemplates, strict)
+		if err != nil {
+			return nil, err
+		}
+		if config.Jobs, err = MergeJobs(config.Jobs, dirJobs); err != nil {
+			return nil, err
+		}
+	}
 
 	// Set defaults for missing values
 	setDefaults(&config)
+	if problems := CheckBatch(config.Database.Batch); len(problems) > 0 {
+		return nil, errors.Join(problems...)
+	}
+
+	if err := ResolveSecrets(&config); err != nil {
+		return nil, err
+	}
+	resolveDataDir(&config)
 
 	return &config, nil
 }
 
-// createDefaultConfig creates a default configuration file
+// readConfig reads a configuration file with its overrides
+func readConfig(configPath string, fs *pflag.FlagSet) (*viper.Viper, error) {
+	v := viper.New()
+	if configPath != "" {
+		v.SetConfigFile(configPath)
+		v.SetConfigType(FormatOf(configPath))
+
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+	}
+	if err := expandJobTemplates(v); err != nil {
+		return nil, err
+	}
+	if err := bindOverrides(v, fs); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// createDefaultConfig creates a default configuration file in the format
+// of its extension
 func createDefaultConfig(configPath string) error {
 	// Ensure directory exists
 	dir := "config"
@@ -186,13 +930,14 @@ func createDefaultConfig(configPath string) error {
 			Driver:   "sqlite",
 			DSN:      "arcron.db",
 			MaxConns: 10,
+			Cleanup:  CleanupConfig{Vacuum: true},
 		},
 		Jobs: []JobConfig{
 			{
 				Name:        "backup",
 				Command:     "rsync -av /data /backup",
 				Type:        "resource-intensive",
-				Schedule:    "0 2 * * *",
+				Schedule:    "0 0 2 * * *",
 				Timeout:     1 * time.Hour,
 				Retries:     3,
 				Priority:    1,
@@ -202,7 +947,7 @@ func createDefaultConfig(configPath string) error {
 				Name:        "logrotate",
 				Command:     "logrotate /etc/logrotate.conf",
 				Type:        "light",
-				Schedule:    "0 0 * * *",
+				Schedule:    "0 0 0 * * *",
 				Timeout:     5 * time.Minute,
 				Retries:     1,
 				Priority:    5,
@@ -210,19 +955,30 @@ func createDefaultConfig(configPath string) error {
 			},
 		},
 		ML: MLConfig{
-			ModelPath:      "models/arcron_model",
-			TrainingData:   "data/metrics.csv",
-			UpdateInterval: 24 * time.Hour,
-			Features:       []string{"cpu_usage", "memory_usage", "io_wait", "network_io"},
+			ModelPath:          "models/arcron_model",
+			TrainingData:       "data/metrics.csv",
+			UpdateInterval:     24 * time.Hour,
+			Features:           []string{"cpu_usage", "memory_usage", "io_wait", "network_io"},
+			Backend:            "builtin",
+			Remote:             RemotePredictorConfig{Timeout: 5 * time.Second},
+			OnlineLearning:     OnlineLearningConfig{Enabled: true, LearningRate: 0.05, HalfLife: 200},
+			ForecastHorizon:    6 * time.Hour,
+			ForecastInterval:   15 * time.Minute,
+			AccuracyWindow:     7 * 24 * time.Hour,
+			FallbackMAE:        15,
+			FallbackMinSamples: 10,
+			ImpactThreshold:    10,
 		},
 		Logging: LoggingConfig{
 			Level:      "info",
 			Format:     "json",
 			OutputFile: "logs/arcron.log",
+			MaxSizeMB:  100,
+			MaxBackups: 5,
 		},
 	}
 
-	data, err := yaml.Marshal(defaultConfig)
+	data, err := marshalConfig(defaultConfig, FormatOf(configPath))
 	if err != nil {
 		return fmt.Errorf("failed to marshal default config: %v", err)
 	}
@@ -245,9 +1001,18 @@ func setDefaults(config *Config) {
 	if config.Server.ReadTimeout == 0 {
 		config.Server.ReadTimeout = 30 * time.Second
 	}
+	if config.Server.ExternalURL == "" {
+		config.Server.ExternalURL = fmt.Sprintf("http://%s:%d", config.Server.Host, config.Server.Port)
+	}
 	if config.Server.WriteTimeout == 0 {
 		config.Server.WriteTimeout = 30 * time.Second
 	}
+	if config.Server.MaxWebSocketConns == 0 {
+		config.Server.MaxWebSocketConns = 100
+	}
+	if config.Server.DrainTimeout == 0 {
+		config.Server.DrainTimeout = 30 * time.Second
+	}
 
 	if config.Database.Driver == "" {
 		config.Database.Driver = "sqlite"
@@ -258,6 +1023,66 @@ func setDefaults(config *Config) {
 	if config.Database.MaxConns == 0 {
 		config.Database.MaxConns = 10
 	}
+	if config.Database.TimeSeries.Backend == "" {
+		config.Database.TimeSeries.Backend = "database"
+	}
+	if config.Database.TimeSeries.Timeout == 0 {
+		config.Database.TimeSeries.Timeout = 10 * time.Second
+	}
+	if config.Database.QueryTimeout == 0 {
+		config.Database.QueryTimeout = 10 * time.Second
+	}
+	if config.Database.AnalyticsTimeout == 0 {
+		config.Database.AnalyticsTimeout = time.Minute
+	}
+	if config.Database.Batch.Size == 0 {
+		config.Database.Batch.Size = 100
+	}
+	if config.Database.Batch.FlushInterval == 0 {
+		config.Database.Batch.FlushInterval = 10 * time.Second
+	}
+	if config.Database.Batch.QueueSize == 0 {
+		config.Database.Batch.QueueSize = 10000
+	}
+	if config.Database.Backup.Directory == "" {
+		config.Database.Backup.Directory = "backups"
+	}
+	if config.Database.Backup.Keep == 0 {
+		config.Database.Backup.Keep = 7
+	}
+	if config.Database.Backup.MaxRestoreSize == 0 {
+		config.Database.Backup.MaxRestoreSize = 1 << 30
+	}
+	if config.Database.Backup.S3.Region == "" {
+		config.Database.Backup.S3.Region = "us-east-1"
+	}
+	if config.Database.Backup.S3.Timeout == 0 {
+		config.Database.Backup.S3.Timeout = 5 * time.Minute
+	}
+	if config.Artifacts.Directory == "" {
+		config.Artifacts.Directory = "artifacts"
+	}
+	if config.Artifacts.MaxSize == 0 {
+		config.Artifacts.MaxSize = 100 << 20
+	}
+	if config.Artifacts.S3.Region == "" {
+		config.Artifacts.S3.Region = "us-east-1"
+	}
+	if config.Artifacts.S3.Timeout == 0 {
+		config.Artifacts.S3.Timeout = 5 * time.Minute
+	}
+	if config.Database.Retention.RollupInterval == 0 {
+		config.Database.Retention.RollupInterval = 1 * time.Minute
+	}
+	if config.Database.Retention.Raw == 0 {
+		config.Database.Retention.Raw = 24 * time.Hour
+	}
+	if config.Database.Retention.Minute == 0 {
+		config.Database.Retention.Minute = 7 * 24 * time.Hour
+	}
+	if config.Database.Retention.Hour == 0 {
+		config.Database.Retention.Hour = 365 * 24 * time.Hour
+	}
 
 	if config.ML.UpdateInterval == 0 {
 		config.ML.UpdateInterval = 24 * time.Hour
@@ -265,6 +1090,60 @@ func setDefaults(config *Config) {
 	if len(config.ML.Features) == 0 {
 		config.ML.Features = []string{"cpu_usage", "memory_usage", "io_wait", "network_io"}
 	}
+	if config.ML.Backend == "" {
+		config.ML.Backend = "builtin"
+	}
+	if config.ML.Remote.Timeout == 0 {
+		config.ML.Remote.Timeout = 5 * time.Second
+	}
+	if config.ML.OnlineLearning.LearningRate == 0 {
+		config.ML.OnlineLearning.LearningRate = 0.05
+	}
+	if config.ML.OnlineLearning.HalfLife == 0 {
+		config.ML.OnlineLearning.HalfLife = 200
+	}
+	if config.ML.ForecastHorizon == 0 {
+		config.ML.ForecastHorizon = 6 * time.Hour
+	}
+	if config.ML.ForecastInterval == 0 {
+		config.ML.ForecastInterval = 15 * time.Minute
+	}
+	if config.ML.AccuracyWindow == 0 {
+		config.ML.AccuracyWindow = 7 * 24 * time.Hour
+	}
+	if config.ML.ImpactThreshold == 0 {
+		config.ML.ImpactThreshold = 10
+	}
+	if config.ML.DurationRegression.Window == 0 {
+		config.ML.DurationRegression.Window = 20
+	}
+	if config.ML.DurationRegression.MinRuns == 0 {
+		config.ML.DurationRegression.MinRuns = 10
+	}
+	if config.ML.DurationRegression.Confidence == 0 {
+		config.ML.DurationRegression.Confidence = 0.95
+	}
+	if config.ML.DurationRegression.MinIncrease == 0 {
+		config.ML.DurationRegression.MinIncrease = 20
+	}
+	if config.ML.DurationRegression.Cooldown == 0 {
+		config.ML.DurationRegression.Cooldown = 24 * time.Hour
+	}
+	if config.ML.Flakiness.Window == 0 {
+		config.ML.Flakiness.Window = 50
+	}
+	if config.ML.Flakiness.MinRuns == 0 {
+		config.ML.Flakiness.MinRuns = 10
+	}
+	if config.ML.Flakiness.Threshold == 0 {
+		config.ML.Flakiness.Threshold = 0.3
+	}
+	if config.ML.FallbackMAE == 0 {
+		config.ML.FallbackMAE = 15
+	}
+	if config.ML.FallbackMinSamples == 0 {
+		config.ML.FallbackMinSamples = 10
+	}
 
 	if config.Logging.Level == "" {
 		config.Logging.Level = "info"
@@ -272,6 +1151,64 @@ func setDefaults(config *Config) {
 	if config.Logging.Format == "" {
 		config.Logging.Format = "json"
 	}
+	if config.Logging.MaxSizeMB == 0 {
+		config.Logging.MaxSizeMB = 100
+	}
+	if config.Logging.MaxBackups == 0 {
+		config.Logging.MaxBackups = 5
+	}
+
+	if config.Tracing.Endpoint == "" {
+		config.Tracing.Endpoint = "localhost:4318"
+	}
+	if config.Tracing.ServiceName == "" {
+		config.Tracing.ServiceName = "arcron"
+	}
+	if config.Tracing.SampleRatio == 0 {
+		config.Tracing.SampleRatio = 1.0
+	}
+
+	if config.Metrics.StatsD.Address == "" {
+		config.Metrics.StatsD.Address = "localhost:8125"
+	}
+	if config.Metrics.StatsD.Prefix == "" {
+		config.Metrics.StatsD.Prefix = "arcron."
+	}
+	if config.Metrics.StatsD.Interval == 0 {
+		config.Metrics.StatsD.Interval = 10 * time.Second
+	}
+
+	if config.Metrics.Pushgateway.Job == "" {
+		config.Metrics.Pushgateway.Job = "arcron"
+	}
+	if config.Metrics.Pushgateway.Instance == "" {
+		if hostname, err := os.Hostname(); err == nil {
+			config.Metrics.Pushgateway.Instance = hostname
+		}
+	}
+	if config.Metrics.Pushgateway.Timeout == 0 {
+		config.Metrics.Pushgateway.Timeout = 10 * time.Second
+	}
+
+	if config.Monitoring.Mode == "" {
+		config.Monitoring.Mode = "auto"
+	}
+	if config.Monitoring.Disks.Exclude == nil {
+		config.Monitoring.Disks.Exclude = []string{"loop*", "ram*"}
+	}
+	if config.Monitoring.Interfaces.Exclude == nil {
+		config.Monitoring.Interfaces.Exclude = []string{"lo", "Loopback*"}
+	}
+	if config.Monitoring.Smoothing.PredictionWindow == 0 {
+		config.Monitoring.Smoothing.PredictionWindow = 5 * time.Minute
+	}
+	if config.Monitoring.Smoothing.GateWindow == 0 {
+		config.Monitoring.Smoothing.GateWindow = time.Minute
+	}
+
+	if config.Thresholds.Hysteresis == 0 {
+		config.Thresholds.Hysteresis = 5
+	}
 
 	// Advanced defaults
 	if config.Advanced.MetricsInterval == 0 {
@@ -280,6 +1217,24 @@ func setDefaults(config *Config) {
 	if config.Advanced.AdjustmentThreshold == 0 {
 		config.Advanced.AdjustmentThreshold = 5
 	}
+	if config.Advanced.AdjustmentWindow == 0 {
+		config.Advanced.AdjustmentWindow = 2 * time.Hour
+	}
+	if config.Advanced.Adjustment.MaxDelta == 0 {
+		config.Advanced.Adjustment.MaxDelta = 2 * time.Hour
+	}
+	if config.Advanced.Adjustment.MaxPerDay == 0 {
+		config.Advanced.Adjustment.MaxPerDay = 4
+	}
+	if config.Advanced.SmartRunMaxWait == 0 {
+		config.Advanced.SmartRunMaxWait = time.Hour
+	}
+	if config.Advanced.DefaultTimeout == 0 {
+		config.Advanced.DefaultTimeout = time.Hour
+	}
+	if config.Advanced.RegressionThreshold == 0 {
+		config.Advanced.RegressionThreshold = 20
+	}
 	if config.Advanced.MaxConcurrentJobs == 0 {
 		config.Advanced.MaxConcurrentJobs = 10
 	}
@@ -289,8 +1244,114 @@ func setDefaults(config *Config) {
 	if config.Advanced.CleanupAfter == 0 {
 		config.Advanced.CleanupAfter = 168 * time.Hour // 7 days
 	}
+	if config.Database.Cleanup.Interval == 0 {
+		config.Database.Cleanup.Interval = 24 * time.Hour
+	}
+	for _, retention := range []*time.Duration{
+		&config.Database.Cleanup.Executions,
+		&config.Database.Cleanup.Predictions,
+		&config.Database.Cleanup.Forecasts,
+		&config.Database.Cleanup.Adjustments,
+		&config.Database.Cleanup.Anomalies,
+		&config.Database.Cleanup.Alerts,
+	} {
+		if *retention == 0 {
+			*retention = config.Advanced.CleanupAfter
+		}
+	}
+	if config.Database.Cleanup.Audit == 0 {
+		config.Database.Cleanup.Audit = 90 * 24 * time.Hour
+	}
+	if config.Alerts.NotifyOn == nil {
+		config.Alerts.NotifyOn = []string{"failure", "success", "missed", "sla", "recovered", "regression", "flaky"}
+	}
+	if config.Alerts.MinSeverity == "" {
+		config.Alerts.MinSeverity = "info"
+	}
+	if config.Alerts.PagerDuty.URL == "" {
+		config.Alerts.PagerDuty.URL = "https://events.pagerduty.com/v2/enqueue"
+	}
+	if config.Alerts.Retry.MaxAttempts == 0 {
+		config.Alerts.Retry.MaxAttempts = 5
+	}
+	if config.Alerts.Retry.InitialBackoff == 0 {
+		config.Alerts.Retry.InitialBackoff = 10 * time.Second
+	}
+	if config.Alerts.Retry.MaxBackoff == 0 {
+		config.Alerts.Retry.MaxBackoff = 10 * time.Minute
+	}
+	if config.Alerts.Retry.QueueSize == 0 {
+		config.Alerts.Retry.QueueSize = 1000
+	}
+	if config.Alerts.Email.Security == "" {
+		config.Alerts.Email.Security = "auto"
+	}
+	if config.Alerts.Teams.Format == "" {
+		config.Alerts.Teams.Format = "messagecard"
+	}
+	if config.Alerts.Telegram.APIURL == "" {
+		config.Alerts.Telegram.APIURL = "https://api.telegram.org"
+	}
+	if config.Alerts.Twilio.Mode == "" {
+		config.Alerts.Twilio.Mode = "sms"
+	}
+	if config.Alerts.Twilio.MinSeverity == "" {
+		config.Alerts.Twilio.MinSeverity = "critical"
+	}
+	if config.Alerts.Twilio.APIURL == "" {
+		config.Alerts.Twilio.APIURL = "https://api.twilio.com"
+	}
+	if config.Advanced.ResourceGate.Limits.MaxCPU == 0 {
+		config.Advanced.ResourceGate.Limits.MaxCPU = 80
+	}
+	if config.Advanced.ResourceGate.Limits.MaxMemory == 0 {
+		config.Advanced.ResourceGate.Limits.MaxMemory = 85
+	}
+	if config.Advanced.ResourceGate.Limits.MaxDelay == 0 {
+		config.Advanced.ResourceGate.Limits.MaxDelay = 30 * time.Minute
+	}
+	if config.Advanced.ResourceGate.CheckInterval == 0 {
+		config.Advanced.ResourceGate.CheckInterval = 30 * time.Second
+	}
+	if config.Advanced.LoadShaping.TargetUtilization == 0 {
+		config.Advanced.LoadShaping.TargetUtilization = 70
+	}
+	if config.Advanced.LoadShaping.DefaultImpact == 0 {
+		config.Advanced.LoadShaping.DefaultImpact = 10
+	}
+	if config.Advanced.Stagger.Gap == 0 {
+		config.Advanced.Stagger.Gap = time.Minute
+	}
+	if config.Advanced.Debug.Host == "" {
+		config.Advanced.Debug.Host = "localhost"
+	}
+	if config.Advanced.Debug.Port == 0 {
+		config.Advanced.Debug.Port = 6060
+	}
 	if !config.Advanced.Prometheus.Enabled {
 		config.Advanced.Prometheus.Path = "/metrics"
 		config.Advanced.Prometheus.Port = 9090
 	}
+
+	if config.Secrets.Timeout == 0 {
+		config.Secrets.Timeout = 10 * time.Second
+	}
+	setFromEnv(&config.Secrets.Vault.Address, "VAULT_ADDR")
+	setFromEnv(&config.Secrets.Vault.Token, "VAULT_TOKEN")
+	setFromEnv(&config.Secrets.Vault.Namespace, "VAULT_NAMESPACE")
+	setFromEnv(&config.Secrets.AWS.Region, "AWS_REGION", "AWS_DEFAULT_REGION")
+	setFromEnv(&config.Secrets.AWS.AccessKeyID, "AWS_ACCESS_KEY_ID")
+	setFromEnv(&config.Secrets.AWS.SecretAccessKey, "AWS_SECRET_ACCESS_KEY")
+	setFromEnv(&config.Secrets.AWS.SessionToken, "AWS_SESSION_TOKEN")
+}
+
+// setFromEnv sets an empty setting to the first of the environment
+// variables that is set
+func setFromEnv(setting *string, names ...string) {
+	for _, name := range names {
+		if *setting != "" {
+			return
+		}
+		*setting = os.Getenv(name)
+	}
 }
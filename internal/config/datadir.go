@@ -0,0 +1,34 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// resolveDataDir places the files arcron writes under the data directory,
+// unless their paths are absolute
+func resolveDataDir(cfg *Config) {
+	if cfg.DataDir == "" {
+		return
+	}
+	resolve := func(path *string) {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(cfg.DataDir, *path)
+		}
+	}
+
+	if cfg.Database.Driver == "sqlite" && isSQLiteFile(cfg.Database.DSN) {
+		resolve(&cfg.Database.DSN)
+	}
+	resolve(&cfg.Database.Backup.Directory)
+	resolve(&cfg.Artifacts.Directory)
+	resolve(&cfg.ML.ModelPath)
+	resolve(&cfg.Logging.OutputFile)
+	resolve(&cfg.Logging.JobLogDir)
+}
+
+// isSQLiteFile reports whether a SQLite DSN is a plain file path rather
+// than an in-memory database or a file: URI
+func isSQLiteFile(dsn string) bool {
+	return dsn != "" && !strings.HasPrefix(dsn, ":memory:") && !strings.HasPrefix(dsn, "file:")
+}
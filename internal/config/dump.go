@@ -0,0 +1,126 @@
+package config
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces secret values in configuration dumps
+const redacted = "[REDACTED]"
+
+// secretKeys are the configuration keys holding secrets
+var secretKeys = map[string]bool{
+	"password":          true,
+	"token":             true,
+	"auth_token":        true,
+	"bot_token":         true,
+	"session_token":     true,
+	"secret_access_key": true,
+	"routing_key":       true,
+	"webhook_url":       true,
+}
+
+// secretEnvName matches job environment variables likely to hold secrets
+var secretEnvName = regexp.MustCompile(`(?i)pass|secret|token|key|credential|auth`)
+
+// dsnPassword matches password parameters of key=value DSNs
+var dsnPassword = regexp.MustCompile(`(?i)(password=)[^\s&;]*`)
+
+// Redacted returns the settings of a configuration, as nested maps keyed
+// by the configuration keys, with secrets replaced: passwords, tokens,
+// keys and webhook URLs, request headers, database DSN passwords and job
+// environment variables named like secrets. Unset secrets stay empty, so
+// a dump still shows whether they are set.
+func Redacted(cfg *Config) (map[string]interface{}, error) {
+	settings, err := configSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	redactSettings(settings)
+	return settings, nil
+}
+
+// RedactJob replaces the secrets in the settings of a job, as returned by
+// JobSettings, in place
+func RedactJob(settings map[string]interface{}) {
+	redactSettings(settings)
+}
+
+// Dump encodes the effective configuration, with secrets redacted, in a
+// format: after defaults, environment and flag overrides, jobs directory
+// merging and secret resolution
+func Dump(cfg *Config, format string) ([]byte, error) {
+	settings, err := Redacted(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSettings(settings, format)
+}
+
+// redactSettings replaces the secrets in nested settings in place
+func redactSettings(value interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			switch {
+			case secretKeys[key]:
+				value[key] = redact(item)
+			case key == "headers":
+				redactMap(item, func(string) bool { return true })
+			case key == "environment":
+				redactMap(item, secretEnvName.MatchString)
+			case key == "dsn":
+				if dsn, ok := item.(string); ok {
+					value[key] = redactDSN(dsn)
+				}
+			case key == "read_replicas":
+				if replicas, ok := item.([]interface{}); ok {
+					for i, replica := range replicas {
+						if dsn, ok := replica.(string); ok {
+							replicas[i] = redactDSN(dsn)
+						}
+					}
+				}
+			default:
+				redactSettings(item)
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			redactSettings(item)
+		}
+	}
+}
+
+// redactMap redacts the values of a map whose names are secret
+func redactMap(value interface{}, secret func(name string) bool) {
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for name, item := range values {
+		if secret(name) {
+			values[name] = redact(item)
+		}
+	}
+}
+
+// redact hides a set value
+func redact(value interface{}) interface{} {
+	if s, ok := value.(string); ok && s == "" {
+		return s
+	}
+	return redacted
+}
+
+// redactDSN hides the password of a URL or key=value DSN
+func redactDSN(dsn string) string {
+	if parsed, err := url.Parse(dsn); err == nil && parsed.User != nil {
+		if _, set := parsed.User.Password(); set {
+			parsed.User = url.UserPassword(parsed.User.Username(), redacted)
+			return strings.Replace(parsed.String(), url.QueryEscape(redacted), redacted, 1)
+		}
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
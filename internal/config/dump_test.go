@@ -0,0 +1,54 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDumpRedactsSecrets(t *testing.T) {
+	cfg := &Config{
+		Database: DatabaseConfig{
+			DSN:          "postgres://arcron:db-secret@db:5432/arcron",
+			ReadReplicas: []string{"host=replica user=arcron password=replica-secret dbname=arcron"},
+		},
+		Alerts: AlertsConfig{
+			Email:   EmailConfig{Username: "alerts", Password: "smtp-secret"},
+			Slack:   SlackConfig{WebhookURL: "https://hooks.slack.com/services/T/B/slack-secret"},
+			Webhook: WebhookConfig{Headers: map[string]string{"Authorization": "Bearer header-secret"}},
+		},
+		Jobs: []JobConfig{{
+			Name:        "deploy",
+			Timeout:     90 * time.Minute,
+			Environment: map[string]string{"API_TOKEN": "env-secret", "REGION": "eu-west-1"},
+		}},
+	}
+	setDefaults(cfg)
+
+	for _, format := range []string{FormatYAML, FormatJSON, FormatTOML} {
+		data, err := Dump(cfg, format)
+		if err != nil {
+			t.Fatalf("Dump(%s) error = %v", format, err)
+		}
+		dump := string(data)
+		if strings.Contains(dump, "secret\"") || strings.Contains(dump, "-secret") {
+			t.Errorf("Dump(%s) leaks a secret:\n%s", format, dump)
+		}
+		for _, want := range []string{"postgres://arcron:[REDACTED]@db:5432/arcron", "password=[REDACTED]", "eu-west-1", "1h30m0s", "alerts"} {
+			if !strings.Contains(dump, want) {
+				t.Errorf("Dump(%s) is missing %q", format, want)
+			}
+		}
+	}
+
+	settings, err := Redacted(cfg)
+	if err != nil {
+		t.Fatalf("Redacted() error = %v", err)
+	}
+	if password := settings["advanced"].(map[string]interface{})["dashboard_auth"].(map[string]interface{})["password"]; password != "" {
+		t.Errorf("unset password = %v, want it left empty", password)
+	}
+	if cfg.Alerts.Email.Password != "smtp-secret" {
+		t.Error("Redacted() changed the configuration")
+	}
+}
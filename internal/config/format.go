@@ -0,0 +1,93 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration file formats
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// FormatOf returns the format of a configuration file from its extension.
+// Files without a .json or .toml extension are YAML.
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatYAML
+	}
+}
+
+// marshalConfig encodes a configuration in a format. Durations are
+// written as strings such as "1h0m0s" in every format, the form they are
+// read in; JSON and TOML numbers would be taken as nanoseconds.
+func marshalConfig(cfg *Config, format string) ([]byte, error) {
+	if format == FormatYAML {
+		return yaml.Marshal(cfg)
+	}
+	settings, err := configSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return encodeSettings(settings, format)
+}
+
+// configSettings converts a configuration to nested maps keyed by the
+// configuration keys, with durations as strings
+func configSettings(cfg *Config) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+	dropNulls(settings)
+	return settings, nil
+}
+
+// encodeSettings encodes the settings of configSettings in a format
+func encodeSettings(settings map[string]interface{}, format string) ([]byte, error) {
+	switch format {
+	case FormatYAML:
+		return yaml.Marshal(settings)
+	case FormatJSON:
+		data, err := json.MarshalIndent(settings, "", "  ")
+		return append(data, '\n'), err
+	case FormatTOML:
+		return toml.Marshal(settings)
+	default:
+		return nil, fmt.Errorf("unknown config format %q", format)
+	}
+}
+
+// dropNulls removes unset values, which TOML cannot represent
+func dropNulls(value interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if item == nil {
+				delete(value, key)
+				continue
+			}
+			dropNulls(item)
+		}
+	case []interface{}:
+		for _, item := range value {
+			dropNulls(item)
+		}
+	}
+}
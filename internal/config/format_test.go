@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDefaultConfigFormats(t *testing.T) {
+	for _, name := range []string{"arcron.yaml", "arcron.json", "arcron.toml"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if _, err := Load(path); err != nil {
+				t.Fatalf("Load() creating the default error = %v", err)
+			}
+
+			cfg, err := LoadStrict(path)
+			if err != nil {
+				t.Fatalf("LoadStrict() of the default error = %v", err)
+			}
+			if cfg.Server.ReadTimeout != 30*time.Second || cfg.ML.UpdateInterval != 24*time.Hour {
+				t.Errorf("durations = %v, %v, want 30s and 24h", cfg.Server.ReadTimeout, cfg.ML.UpdateInterval)
+			}
+			if len(cfg.Jobs) != 2 || cfg.Jobs[0].Timeout != time.Hour {
+				t.Errorf("jobs = %+v, want the default jobs", cfg.Jobs)
+			}
+		})
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	files := map[string]string{
+		"arcron.json": `{"server": {"port": 9000, "read_timeout": "45s"},
+			"jobs": [{"name": "backup", "command": "backup.sh", "schedule": "@daily", "timeout": "1h30m"}]}`,
+		"arcron.toml": `[server]
+port = 9000
+read_timeout = "45s"
+
+[[jobs]]
+name = "backup"
+command = "backup.sh"
+schedule = "@daily"
+timeout = "1h30m"
+`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.Server.Port != 9000 || cfg.Server.ReadTimeout != 45*time.Second {
+				t.Errorf("server = %+v, want port 9000 and a 45s read timeout", cfg.Server)
+			}
+			if len(cfg.Jobs) != 1 || cfg.Jobs[0].Timeout != 90*time.Minute {
+				t.Errorf("jobs = %+v, want backup with a 1h30m timeout", cfg.Jobs)
+			}
+		})
+	}
+}
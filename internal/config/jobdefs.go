@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/makalin/arcron/internal/types"
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v3"
+)
+
+// JobSettings converts a job to nested maps keyed by its configuration
+// keys, as written in a jobs file, with durations as strings
+func JobSettings(job JobConfig) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+	dropNulls(settings)
+	return settings, nil
+}
+
+// DecodeJob reads a job from settings keyed as in a jobs file, rejecting
+// unknown keys
+func DecodeJob(settings map[string]interface{}) (JobConfig, error) {
+	var job JobConfig
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		ErrorUnused:      true,
+		WeaklyTypedInput: true,
+		Result:           &job,
+	})
+	if err != nil {
+		return job, err
+	}
+	if err := decoder.Decode(settings); err != nil {
+		return job, err
+	}
+	return job, nil
+}
+
+// ParseJobs reads the jobs of a JSON or YAML document shaped like a jobs
+// file, {"jobs": [...]}, rejecting unknown keys
+func ParseJobs(data []byte) ([]JobConfig, error) {
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse jobs: %v", err)
+	}
+	for key := range document {
+		if key != "jobs" {
+			return nil, fmt.Errorf("unknown key %q, expected jobs", key)
+		}
+	}
+	items, ok := document["jobs"].([]interface{})
+	if !ok && document["jobs"] != nil {
+		return nil, fmt.Errorf("jobs must be a list")
+	}
+
+	jobs := make([]JobConfig, 0, len(items))
+	for i, item := range items {
+		settings, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("jobs[%d] must be an object", i)
+		}
+		job, err := DecodeJob(settings)
+		if err != nil {
+			return nil, fmt.Errorf("jobs[%d]: %v", i, err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// DiffJobs returns the configuration keys whose values differ between two
+// definitions of a job, in key order. Values of secrets are redacted.
+func DiffJobs(before, after JobConfig) ([]types.FieldChange, error) {
+	beforeSettings, err := JobSettings(before)
+	if err != nil {
+		return nil, err
+	}
+	afterSettings, err := JobSettings(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for field := range beforeSettings {
+		fields = append(fields, field)
+	}
+	for field := range afterSettings {
+		if _, ok := beforeSettings[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	var changes []types.FieldChange
+	for _, field := range fields {
+		if reflect.DeepEqual(beforeSettings[field], afterSettings[field]) {
+			continue
+		}
+		// Redacted as the values of their key in a configuration dump
+		values := []interface{}{
+			map[string]interface{}{field: beforeSettings[field]},
+			map[string]interface{}{field: afterSettings[field]},
+		}
+		redactSettings(values)
+		changes = append(changes, types.FieldChange{
+			Field:  field,
+			Before: values[0].(map[string]interface{})[field],
+			After:  values[1].(map[string]interface{})[field],
+		})
+	}
+	return changes, nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJobs(t *testing.T) {
+	json := `{"jobs": [{"name": "backup", "command": "backup.sh", "schedule": "@daily", "timeout": "10m",
+		"environment": {"DB_PASSWORD": "s3cret"}, "alerts": {"notify_on": ["failure"]}}]}`
+	yaml := "jobs:\n  - name: backup\n    command: backup.sh\n    schedule: \"@daily\"\n    timeout: 10m\n" +
+		"    environment:\n      DB_PASSWORD: s3cret\n    alerts:\n      notify_on: [failure]\n"
+
+	for name, document := range map[string]string{"json": json, "yaml": yaml} {
+		jobs, err := ParseJobs([]byte(document))
+		if err != nil {
+			t.Fatalf("ParseJobs(%s) error = %v", name, err)
+		}
+		if len(jobs) != 1 || jobs[0].Timeout != 10*time.Minute || jobs[0].Environment["DB_PASSWORD"] != "s3cret" ||
+			len(jobs[0].Alerts.NotifyOn) != 1 {
+			t.Errorf("ParseJobs(%s) = %+v", name, jobs)
+		}
+
+		settings, err := JobSettings(jobs[0])
+		if err != nil {
+			t.Fatalf("JobSettings() error = %v", err)
+		}
+		decoded, err := DecodeJob(settings)
+		if err != nil || decoded.Timeout != jobs[0].Timeout || decoded.Schedule != jobs[0].Schedule {
+			t.Errorf("DecodeJob(JobSettings()) = %+v, %v, want the job back", decoded, err)
+		}
+	}
+
+	for _, document := range []string{
+		`{"jobs": [{"name": "backup", "comand": "backup.sh"}]}`,
+		`{"job": []}`,
+		`{"jobs": {"name": "backup"}}`,
+	} {
+		if _, err := ParseJobs([]byte(document)); err == nil {
+			t.Errorf("ParseJobs(%s) accepted an invalid document", document)
+		}
+	}
+}
+
+func TestDiffJobs(t *testing.T) {
+	before := JobConfig{Name: "backup", Command: "backup.sh", Schedule: "@daily",
+		Environment: map[string]string{"DB_PASSWORD": "old"}}
+	after := JobConfig{Name: "backup", Command: "backup.sh", Schedule: "@hourly", Timeout: time.Minute,
+		Environment: map[string]string{"DB_PASSWORD": "new"}}
+
+	changes, err := DiffJobs(before, after)
+	if err != nil {
+		t.Fatalf("DiffJobs() error = %v", err)
+	}
+	var fields []string
+	for _, change := range changes {
+		fields = append(fields, change.Field)
+	}
+	if strings.Join(fields, ",") != "environment,schedule,timeout" {
+		t.Fatalf("changed fields = %v, want environment, schedule and timeout", fields)
+	}
+	if env := changes[0].After.(map[string]interface{}); env["DB_PASSWORD"] != redacted {
+		t.Errorf("environment = %v, want the password redacted", env)
+	}
+	if changes[2].Before != "0s" || changes[2].After != "1m0s" {
+		t.Errorf("timeout change = %+v, want durations as strings", changes[2])
+	}
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// jobsDirDebounce is how long the jobs directory must be quiet after a
+// change before it is read again, so a file is not read half written
+var jobsDirDebounce = 500 * time.Millisecond
+
+// jobsFile is the content of a file in the jobs directory
+type jobsFile struct {
+	Jobs []JobConfig `mapstructure:"jobs"`
+}
+
+// LoadJobsDir reads the jobs defined by the .yaml, .yml, .json and .toml
+// files of a directory, in file name order, rejecting unknown keys if strict. Each
+// job records the file it came from as its Source. Jobs may instantiate
+// the templates of the main configuration.
+func LoadJobsDir(dir string, templates []JobTemplate, strict bool) ([]JobConfig, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jobs directory: %v", err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && isJobsFile(entry.Name()) {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+
+	var jobs []JobConfig
+	for _, path := range paths {
+		v := viper.New()
+		v.SetConfigFile(path)
+		v.SetConfigType(FormatOf(path))
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read jobs file %s: %v", path, err)
+		}
+		if err := expandJobs(v, templates); err != nil {
+			return nil, fmt.Errorf("jobs file %s: %v", path, err)
+		}
+
+		var file jobsFile
+		unmarshal := v.Unmarshal
+		if strict {
+			unmarshal = v.UnmarshalExact
+		}
+		if err := unmarshal(&file); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal jobs file %s: %v", path, err)
+		}
+		for _, job := range file.Jobs {
+			job.Source = path
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// MergeJobs appends the jobs of the jobs directory to those of the main
+// configuration file. Job names must be unique across all files.
+func MergeJobs(jobs, dirJobs []JobConfig) ([]JobConfig, error) {
+	sources := make(map[string]string, len(jobs)+len(dirJobs))
+	merged := make([]JobConfig, 0, len(jobs)+len(dirJobs))
+	for _, job := range append(append([]JobConfig{}, jobs...), dirJobs...) {
+		source := job.Source
+		if source == "" {
+			source = "the configuration file"
+		}
+		if previous, ok := sources[job.Name]; ok {
+			return nil, fmt.Errorf("job %s in %s is already defined in %s", job.Name, source, previous)
+		}
+		sources[job.Name] = source
+		merged = append(merged, job)
+	}
+	return merged, nil
+}
+
+// WatchJobsDir calls onChange with the jobs of a directory, as read by
+// LoadJobsDir with the templates, whenever its job files change, until the context is done.
+// Unreadable files are passed on as an error so the previous jobs can be
+// kept.
+func WatchJobsDir(ctx context.Context, dir string, templates []JobTemplate, onChange func(jobs []JobConfig, err error)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch jobs directory: %v", err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch jobs directory: %v", err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var reload <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if isJobsFile(event.Name) && !event.Has(fsnotify.Chmod) {
+					reload = time.After(jobsDirDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				onChange(nil, fmt.Errorf("failed to watch jobs directory: %v", err))
+			case <-reload:
+				reload = nil
+				onChange(LoadJobsDir(dir, templates, false))
+			}
+		}
+	}()
+	return nil
+}
+
+// isJobsFile reports whether a file in the jobs directory defines jobs
+func isJobsFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json", ".toml":
+		return !strings.HasPrefix(filepath.Base(name), ".")
+	}
+	return false
+}
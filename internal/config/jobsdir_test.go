@@ -0,0 +1,163 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadMergesJobsDir(t *testing.T) {
+	dir := t.TempDir()
+	jobsDir := filepath.Join(dir, "jobs.d")
+	if err := os.Mkdir(jobsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "arcron.yaml")
+	writeFile(t, path, "jobs_dir: "+jobsDir+"\njobs:\n  - name: backup\n    command: backup.sh\n    schedule: \"@daily\"\n")
+	writeFile(t, filepath.Join(jobsDir, "b-reports.yaml"),
+		"jobs:\n  - name: report\n    command: report.sh\n    schedule: \"@hourly\"\n    timeout: 10m\n")
+	writeFile(t, filepath.Join(jobsDir, "a-cleanup.yml"), "jobs:\n  - name: cleanup\n    command: cleanup.sh\n    schedule: \"@weekly\"\n")
+	writeFile(t, filepath.Join(jobsDir, "notes.txt"), "not a job file")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	var names []string
+	for _, job := range cfg.Jobs {
+		names = append(names, job.Name)
+	}
+	if strings.Join(names, ",") != "backup,cleanup,report" {
+		t.Fatalf("jobs = %v, want the main file's, then the directory's in file name order", names)
+	}
+	if cfg.Jobs[0].Source != "" || cfg.Jobs[2].Source != filepath.Join(jobsDir, "b-reports.yaml") {
+		t.Errorf("sources = %q, %q", cfg.Jobs[0].Source, cfg.Jobs[2].Source)
+	}
+	if cfg.Jobs[2].Timeout != 10*time.Minute {
+		t.Errorf("timeout = %v, want 10m", cfg.Jobs[2].Timeout)
+	}
+
+	writeFile(t, filepath.Join(jobsDir, "c-duplicate.yaml"), "jobs:\n  - name: backup\n    command: other.sh\n")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Errorf("Load() error = %v, want a duplicate job error", err)
+	}
+}
+
+func TestWatchJobsDir(t *testing.T) {
+	debounce := jobsDirDebounce
+	jobsDirDebounce = 10 * time.Millisecond
+	defer func() { jobsDirDebounce = debounce }()
+
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan []JobConfig, 10)
+	err := WatchJobsDir(ctx, dir, nil, func(jobs []JobConfig, err error) {
+		if err != nil {
+			t.Errorf("WatchJobsDir() error = %v", err)
+			return
+		}
+		changes <- jobs
+	})
+	if err != nil {
+		t.Fatalf("WatchJobsDir() error = %v", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "team.yaml"), "jobs:\n  - name: report\n    command: report.sh\n")
+	select {
+	case jobs := <-changes:
+		if len(jobs) != 1 || jobs[0].Name != "report" {
+			t.Errorf("jobs = %+v, want report", jobs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("change was not reported")
+	}
+}
+
+// templatesConfig defines a job template used by jobs directory files
+const templatesConfig = `job_templates:
+  - name: customer-export
+    params: [customer]
+    job:
+      name: "export-{{.customer}}"
+      command: "/opt/export.sh --customer {{.customer}}"
+      schedule: "@daily"
+`
+
+func TestJobsDirTemplates(t *testing.T) {
+	dir := t.TempDir()
+	jobsDir := filepath.Join(dir, "jobs.d")
+	if err := os.Mkdir(jobsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "arcron.yaml")
+	writeFile(t, path, templatesConfig+"jobs_dir: "+jobsDir+"\n")
+	writeFile(t, filepath.Join(jobsDir, "acme.yaml"),
+		"jobs:\n  - template: customer-export\n    params:\n      customer: acme\n    timeout: 10m\n")
+
+	cfg, err := LoadStrict(path)
+	if err != nil {
+		t.Fatalf("LoadStrict() error = %v", err)
+	}
+	if len(cfg.Jobs) != 1 || cfg.Jobs[0].Name != "export-acme" || cfg.Jobs[0].Command != "/opt/export.sh --customer acme" ||
+		cfg.Jobs[0].Timeout != 10*time.Minute {
+		t.Fatalf("jobs = %+v, want the expanded export-acme", cfg.Jobs)
+	}
+	if err := Validate(path); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	writeFile(t, filepath.Join(jobsDir, "unknown.yaml"), "jobs:\n  - template: missing\n")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "unknown job template") {
+		t.Errorf("Load() error = %v, want the unknown template reported", err)
+	}
+}
+
+func TestWatchJobsDirTemplates(t *testing.T) {
+	debounce := jobsDirDebounce
+	jobsDirDebounce = 10 * time.Millisecond
+	defer func() { jobsDirDebounce = debounce }()
+
+	path := filepath.Join(t.TempDir(), "arcron.yaml")
+	writeFile(t, path, templatesConfig)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan []JobConfig, 10)
+	err = WatchJobsDir(ctx, dir, cfg.JobTemplates, func(jobs []JobConfig, err error) {
+		if err != nil {
+			t.Errorf("WatchJobsDir() error = %v", err)
+			return
+		}
+		changes <- jobs
+	})
+	if err != nil {
+		t.Fatalf("WatchJobsDir() error = %v", err)
+	}
+
+	writeFile(t, filepath.Join(dir, "acme.yaml"), "jobs:\n  - template: customer-export\n    params:\n      customer: acme\n")
+	select {
+	case jobs := <-changes:
+		if len(jobs) != 1 || jobs[0].Name != "export-acme" || jobs[0].Schedule != "@daily" {
+			t.Errorf("jobs = %+v, want the expanded export-acme", jobs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("change was not reported")
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// envPrefix prefixes the environment variables overriding configuration
+// keys, e.g. ARCRON_SERVER_PORT for server.port
+const envPrefix = "ARCRON"
+
+// Keys returns the configuration keys that can be overridden from the
+// environment or the command line: every scalar and string list field,
+// outside of the lists of jobs and routes
+func Keys() []string {
+	return keysOf(reflect.TypeOf(Config{}), "")
+}
+
+func keysOf(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if options == "squash" {
+			keys = append(keys, keysOf(field.Type, prefix)...)
+			continue
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := prefix + name
+		switch kind := field.Type.Kind(); {
+		case kind == reflect.Struct:
+			keys = append(keys, keysOf(field.Type, key+".")...)
+		case kind == reflect.Map, kind == reflect.Pointer:
+		case kind == reflect.Slice && field.Type.Elem().Kind() != reflect.String:
+		default:
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// EnvName returns the environment variable overriding a configuration key
+func EnvName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// RegisterFlags adds a flag for every configuration key to fs, named
+// after the key, e.g. --server.port. Values are parsed like those of the
+// configuration file: durations as "30s", lists comma separated.
+func RegisterFlags(fs *pflag.FlagSet) {
+	for _, key := range Keys() {
+		fs.String(key, "", fmt.Sprintf("overrides %s (env %s)", key, EnvName(key)))
+	}
+}
+
+// bindOverrides makes the environment, and the flags set on fs if it is
+// not nil, override the configuration file. Flags take precedence over
+// the environment.
+func bindOverrides(v *viper.Viper, fs *pflag.FlagSet) error {
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	// AutomaticEnv only applies to keys viper already knows of, so keys
+	// missing from the file are bound explicitly
+	for _, key := range Keys() {
+		if err := v.BindEnv(key, EnvName(key)); err != nil {
+			return fmt.Errorf("failed to bind %s: %v", EnvName(key), err)
+		}
+	}
+
+	if fs == nil {
+		return nil
+	}
+	var err error
+	fs.Visit(func(flag *pflag.Flag) {
+		if bindErr := v.BindPFlag(flag.Name, flag); bindErr != nil && err == nil {
+			err = fmt.Errorf("failed to bind flag --%s: %v", flag.Name, bindErr)
+		}
+	})
+	return err
+}
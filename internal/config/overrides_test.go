@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestEnvAndFlagOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arcron.yaml")
+	err := os.WriteFile(path, []byte("server:\n  port: 9000\ndatabase:\n  dsn: file.db\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("ARCRON_SERVER_PORT", "9100")
+	t.Setenv("ARCRON_DATABASE_DSN", "env.db")
+	t.Setenv("ARCRON_SERVER_READ_TIMEOUT", "45s")
+	t.Setenv("ARCRON_ALERTS_NOTIFY_ON", "failure,sla")
+
+	fs := pflag.NewFlagSet("arcron", pflag.ContinueOnError)
+	RegisterFlags(fs)
+	if err := fs.Parse([]string{"--database.dsn=flag.db", "--alerts.slack.enabled=true"}); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	cfg, err := LoadWithFlags(path, fs)
+	if err != nil {
+		t.Fatalf("LoadWithFlags() error = %v", err)
+	}
+
+	if cfg.Server.Port != 9100 {
+		t.Errorf("server.port = %d, want the environment's 9100", cfg.Server.Port)
+	}
+	if cfg.Database.DSN != "flag.db" {
+		t.Errorf("database.dsn = %q, want the flag's flag.db", cfg.Database.DSN)
+	}
+	if cfg.Server.ReadTimeout != 45*time.Second {
+		t.Errorf("server.read_timeout = %v, want 45s from a key missing from the file", cfg.Server.ReadTimeout)
+	}
+	if len(cfg.Alerts.NotifyOn) != 2 || cfg.Alerts.NotifyOn[1] != "sla" {
+		t.Errorf("alerts.notify_on = %v, want the comma separated list", cfg.Alerts.NotifyOn)
+	}
+	if !cfg.Alerts.Slack.Enabled {
+		t.Error("alerts.slack.enabled = false, want the flag's true")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	keys := make(map[string]bool)
+	for _, key := range Keys() {
+		keys[key] = true
+	}
+
+	for _, key := range []string{"server.port", "database.cleanup.alerts", "alerts.email.to"} {
+		if !keys[key] {
+			t.Errorf("Keys() is missing %s", key)
+		}
+	}
+	for _, key := range []string{"jobs", "alerts.routes", "alerts.webhook.headers"} {
+		if keys[key] {
+			t.Errorf("Keys() includes %s, which cannot be overridden", key)
+		}
+	}
+	if got := EnvName("database.max_conns"); got != "ARCRON_DATABASE_MAX_CONNS" {
+		t.Errorf("EnvName() = %s, want ARCRON_DATABASE_MAX_CONNS", got)
+	}
+}
+
+func TestLoadFromEnvironmentOnly(t *testing.T) {
+	dir := t.TempDir()
+	jobsDir := filepath.Join(dir, "jobs")
+	if err := os.Mkdir(jobsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	jobs := "jobs:\n  - name: report\n    command: echo report\n    schedule: \"@daily\"\n"
+	if err := os.WriteFile(filepath.Join(jobsDir, "report.yaml"), []byte(jobs), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("ARCRON_SERVER_PORT", "9100")
+	t.Setenv("ARCRON_JOBS_DIR", jobsDir)
+	t.Setenv("ARCRON_DATA_DIR", "/var/lib/arcron")
+	t.Setenv("ARCRON_LOGGING_OUTPUT_FILE", "logs/arcron.log")
+	t.Setenv("ARCRON_ML_MODEL_PATH", "/models/arcron_model")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Server.Port != 9100 || len(cfg.Jobs) != 1 || cfg.Jobs[0].Name != "report" {
+		t.Errorf("port = %d, jobs = %+v, want both from the environment", cfg.Server.Port, cfg.Jobs)
+	}
+	if cfg.Database.DSN != "/var/lib/arcron/arcron.db" || cfg.Database.Backup.Directory != "/var/lib/arcron/backups" {
+		t.Errorf("dsn = %s, backups = %s, want them in the data directory", cfg.Database.DSN, cfg.Database.Backup.Directory)
+	}
+	if cfg.Logging.OutputFile != "/var/lib/arcron/logs/arcron.log" || cfg.ML.ModelPath != "/models/arcron_model" {
+		t.Errorf("log file = %s, model = %s, want relative paths in the data directory only", cfg.Logging.OutputFile, cfg.ML.ModelPath)
+	}
+}
@@ -0,0 +1,322 @@
+package config
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// secretPattern matches ${vault:path#key} and ${aws-sm:name} placeholders;
+// an AWS secret holding JSON may also be given a #key
+var secretPattern = regexp.MustCompile(`\$\{(vault|aws-sm):([^}#]+)(?:#([^}]+))?\}`)
+
+// ResolveSecrets replaces secret placeholders in the database DSNs, the
+// SMTP password, the Slack webhook URL, API tokens and job environment
+// values with the secrets they reference. Each secret is fetched once per call.
+func ResolveSecrets(cfg *Config) error {
+	resolver := newSecretResolver(cfg.Secrets)
+
+	type setting struct {
+		name  string
+		value *string
+	}
+	settings := []setting{
+		{"database.dsn", &cfg.Database.DSN},
+		{"alerts.email.password", &cfg.Alerts.Email.Password},
+		{"alerts.slack.webhook_url", &cfg.Alerts.Slack.WebhookURL},
+	}
+	for i := range cfg.Database.ReadReplicas {
+		settings = append(settings, setting{fmt.Sprintf("database.read_replicas[%d]", i), &cfg.Database.ReadReplicas[i]})
+	}
+	for i := range cfg.Advanced.DashboardAuth.Tokens {
+		settings = append(settings, setting{fmt.Sprintf("advanced.dashboard_auth.tokens[%d].token", i), &cfg.Advanced.DashboardAuth.Tokens[i].Token})
+	}
+	for _, setting := range settings {
+		if err := resolver.resolve(setting.value); err != nil {
+			return fmt.Errorf("failed to resolve %s: %v", setting.name, err)
+		}
+	}
+	return resolver.resolveJobs(cfg.Jobs)
+}
+
+// ResolveJobSecrets replaces secret placeholders in the environment values
+// of jobs, e.g. of jobs reloaded from the jobs directory
+func ResolveJobSecrets(secrets SecretsConfig, jobs []JobConfig) error {
+	return newSecretResolver(secrets).resolveJobs(jobs)
+}
+
+// HasSecretPlaceholder reports whether a value references a secret
+func HasSecretPlaceholder(value string) bool {
+	return secretPattern.MatchString(value)
+}
+
+// secretResolver fetches the secrets referenced by placeholders, caching
+// them for the duration of one resolution
+type secretResolver struct {
+	config SecretsConfig
+	client *http.Client
+	cache  map[string]map[string]interface{}
+	raw    map[string]string
+}
+
+func newSecretResolver(cfg SecretsConfig) *secretResolver {
+	return &secretResolver{
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		cache:  make(map[string]map[string]interface{}),
+		raw:    make(map[string]string),
+	}
+}
+
+// resolveJobs resolves the environment values of jobs in place. The
+// environment maps are copied first since job configurations share them
+// with earlier copies.
+func (r *secretResolver) resolveJobs(jobs []JobConfig) error {
+	for i := range jobs {
+		job := &jobs[i]
+		names := make([]string, 0, len(job.Environment))
+		for name, value := range job.Environment {
+			if HasSecretPlaceholder(value) {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		sort.Strings(names)
+
+		environment := make(map[string]string, len(job.Environment))
+		for name, value := range job.Environment {
+			environment[name] = value
+		}
+		for _, name := range names {
+			value := environment[name]
+			if err := r.resolve(&value); err != nil {
+				return fmt.Errorf("failed to resolve environment variable %s of job %s: %v", name, job.Name, err)
+			}
+			environment[name] = value
+		}
+		job.Environment = environment
+	}
+	return nil
+}
+
+// resolve replaces the placeholders in a value
+func (r *secretResolver) resolve(value *string) error {
+	var resolveErr error
+	*value = secretPattern.ReplaceAllStringFunc(*value, func(placeholder string) string {
+		if resolveErr != nil {
+			return placeholder
+		}
+		match := secretPattern.FindStringSubmatch(placeholder)
+		secret, err := r.lookup(match[1], match[2], match[3])
+		if err != nil {
+			resolveErr = fmt.Errorf("%s:%s: %v", match[1], match[2], err)
+			return placeholder
+		}
+		return secret
+	})
+	return resolveErr
+}
+
+// lookup returns a secret from a store
+func (r *secretResolver) lookup(store, path, key string) (string, error) {
+	switch store {
+	case "vault":
+		if key == "" {
+			return "", fmt.Errorf("a #key is required")
+		}
+		data, err := r.vaultSecret(path)
+		if err != nil {
+			return "", err
+		}
+		return secretField(data, key)
+	default:
+		secret, err := r.awsSecret(path)
+		if err != nil || key == "" {
+			return secret, err
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(secret), &data); err != nil {
+			return "", fmt.Errorf("secret is not JSON, so it has no key %s", key)
+		}
+		return secretField(data, key)
+	}
+}
+
+// secretField returns a field of a secret as a string
+func secretField(data map[string]interface{}, key string) (string, error) {
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %s", key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// vaultSecret reads a secret from Vault. Secrets of KV version 2 engines,
+// read from their data/ path, are unwrapped.
+func (r *secretResolver) vaultSecret(path string) (map[string]interface{}, error) {
+	if data, ok := r.cache[path]; ok {
+		return data, nil
+	}
+	vault := r.config.Vault
+	if vault.Address == "" {
+		return nil, fmt.Errorf("secrets.vault.address is not set")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(vault.Address, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", vault.Token)
+	if vault.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", vault.Namespace)
+	}
+
+	var response struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := r.do(req, &response); err != nil {
+		return nil, err
+	}
+	data := response.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = nested
+		}
+	}
+	r.cache[path] = data
+	return data, nil
+}
+
+// awsSecret reads the string value of a secret from AWS Secrets Manager
+func (r *secretResolver) awsSecret(name string) (string, error) {
+	if secret, ok := r.raw[name]; ok {
+		return secret, nil
+	}
+	aws := r.config.AWS
+	if aws.Region == "" {
+		return "", fmt.Errorf("secrets.aws.region is not set")
+	}
+
+	endpoint := aws.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", aws.Region)
+	}
+	body, err := json.Marshal(map[string]string{"SecretId": name})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAWSRequest(req, aws, body, time.Now())
+
+	var response struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := r.do(req, &response); err != nil {
+		return "", err
+	}
+	r.raw[name] = response.SecretString
+	return response.SecretString, nil
+}
+
+// do sends a request to a secret store and decodes its JSON response
+func (r *secretResolver) do(req *http.Request, response interface{}) error {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("secret store returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("failed to decode secret: %v", err)
+	}
+	return nil
+}
+
+// signAWSRequest adds the AWS Signature Version 4 headers to a Secrets
+// Manager request without query parameters
+func signAWSRequest(req *http.Request, aws AWSSecretsConfig, body []byte, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if aws.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", aws.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + aws.Region + "/secretsmanager/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+aws.SecretAccessKey), date)
+	for _, part := range []string{aws.Region, "secretsmanager", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		aws.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	vaultRequests := 0
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vaultRequests++
+		if r.Header.Get("X-Vault-Token") != "root" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/arcron":
+			// KV version 2
+			w.Write([]byte(`{"data": {"data": {"smtp_password": "hunter2", "db_password": "s3cret"}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/slack":
+			w.Write([]byte(`{"data": {"webhook": "https://hooks.slack.com/services/T/B/X"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer vault.Close()
+
+	aws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var body struct{ SecretId string }
+		json.NewDecoder(r.Body).Decode(&body)
+		secrets := map[string]string{"deploy-token": "tok-123", "reports": `{"api_key": "abc"}`}
+		json.NewEncoder(w).Encode(map[string]string{"SecretString": secrets[body.SecretId]})
+	}))
+	defer aws.Close()
+
+	cfg := &Config{
+		Database: DatabaseConfig{DSN: "postgres://arcron:${vault:secret/data/arcron#db_password}@db/arcron"},
+		Alerts: AlertsConfig{
+			Email: EmailConfig{Password: "${vault:secret/data/arcron#smtp_password}"},
+			Slack: SlackConfig{WebhookURL: "${vault:kv/slack#webhook}"},
+		},
+		Jobs: []JobConfig{{Name: "deploy", Environment: map[string]string{
+			"TOKEN":   "${aws-sm:deploy-token}",
+			"API_KEY": "${aws-sm:reports#api_key}",
+			"PLAIN":   "value",
+		}}},
+		Secrets: SecretsConfig{
+			Vault: VaultConfig{Address: vault.URL, Token: "root"},
+			AWS:   AWSSecretsConfig{Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret", Endpoint: aws.URL},
+		},
+	}
+	environment := cfg.Jobs[0].Environment
+
+	if err := ResolveSecrets(cfg); err != nil {
+		t.Fatalf("ResolveSecrets() error = %v", err)
+	}
+	if cfg.Database.DSN != "postgres://arcron:s3cret@db/arcron" {
+		t.Errorf("dsn = %q", cfg.Database.DSN)
+	}
+	if cfg.Alerts.Email.Password != "hunter2" {
+		t.Errorf("smtp password = %q", cfg.Alerts.Email.Password)
+	}
+	if cfg.Alerts.Slack.WebhookURL != "https://hooks.slack.com/services/T/B/X" {
+		t.Errorf("slack webhook = %q", cfg.Alerts.Slack.WebhookURL)
+	}
+	if env := cfg.Jobs[0].Environment; env["TOKEN"] != "tok-123" || env["API_KEY"] != "abc" || env["PLAIN"] != "value" {
+		t.Errorf("job environment = %v", env)
+	}
+	if environment["TOKEN"] != "${aws-sm:deploy-token}" {
+		t.Error("resolved secrets were written to the original environment map")
+	}
+	if vaultRequests != 2 {
+		t.Errorf("vault requests = %d, want each secret read once", vaultRequests)
+	}
+
+	cfg.Alerts.Email.Password = "${vault:secret/data/arcron#missing}"
+	if err := ResolveSecrets(cfg); err == nil || !strings.Contains(err.Error(), "alerts.email.password") {
+		t.Errorf("ResolveSecrets() error = %v, want the missing key reported", err)
+	}
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// JobTemplate declares a job once for many similar jobs. Jobs instantiate
+// it with "template: <name>" and "params", and may override any of its
+// settings.
+type JobTemplate struct {
+	Name string `yaml:"name" mapstructure:"name"`
+	// Params lists the parameters jobs must set; when empty any
+	// parameters are accepted
+	Params []string `yaml:"params" mapstructure:"params"`
+	// Defaults are values of parameters jobs may leave out
+	Defaults map[string]string `yaml:"defaults" mapstructure:"defaults"`
+	// Job holds the job settings. String values are text/template
+	// templates of the parameters, e.g. "export-{{.customer}}"; parameter
+	// names are lower case.
+	Job map[string]interface{} `yaml:"job" mapstructure:"job"`
+}
+
+// expandJobTemplates replaces the jobs that instantiate a template with
+// the template's settings, parameters substituted and the job's own
+// settings on top. It works on the raw settings so that any setting, e.g.
+// a timeout, may be parameterized.
+func expandJobTemplates(v *viper.Viper) error {
+	rawTemplates, _ := v.Get("job_templates").([]interface{})
+	templates := make([]JobTemplate, len(rawTemplates))
+	for i, raw := range rawTemplates {
+		if err := decodeRaw(raw, &templates[i]); err != nil {
+			return fmt.Errorf("job_templates[%d]: %v", i, err)
+		}
+	}
+	return expandJobs(v, templates)
+}
+
+// expandJobs expands the jobs of v that instantiate one of the templates,
+// e.g. those of a file in the jobs directory with the templates of the
+// main configuration
+func expandJobs(v *viper.Viper, jobTemplates []JobTemplate) error {
+	templates := make(map[string]JobTemplate, len(jobTemplates))
+	for i, jobTemplate := range jobTemplates {
+		if jobTemplate.Name == "" {
+			return fmt.Errorf("job_templates[%d]: name is required", i)
+		}
+		templates[jobTemplate.Name] = jobTemplate
+	}
+
+	rawJobs, _ := v.Get("jobs").([]interface{})
+	jobs := make([]interface{}, len(rawJobs))
+	instantiated := false
+	for i, raw := range rawJobs {
+		job, ok := raw.(map[string]interface{})
+		if !ok || job["template"] == nil {
+			jobs[i] = raw
+			continue
+		}
+		expanded, err := instantiate(templates, job)
+		if err != nil {
+			return fmt.Errorf("jobs[%d]: %v", i, err)
+		}
+		jobs[i] = expanded
+		instantiated = true
+	}
+	if instantiated {
+		v.Set("jobs", jobs)
+	}
+	return nil
+}
+
+// instantiate expands a job instantiating a template
+func instantiate(templates map[string]JobTemplate, job map[string]interface{}) (map[string]interface{}, error) {
+	name := fmt.Sprint(job["template"])
+	jobTemplate, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown job template %q", name)
+	}
+
+	var given map[string]string
+	if err := decodeRaw(job["params"], &given); err != nil {
+		return nil, fmt.Errorf("invalid params: %v", err)
+	}
+	params := make(map[string]string, len(jobTemplate.Defaults)+len(given))
+	for param, value := range jobTemplate.Defaults {
+		params[strings.ToLower(param)] = value
+	}
+	for param, value := range given {
+		params[strings.ToLower(param)] = value
+	}
+	if len(jobTemplate.Params) > 0 {
+		declared := make(map[string]bool, len(jobTemplate.Params))
+		for _, param := range jobTemplate.Params {
+			param = strings.ToLower(param)
+			declared[param] = true
+			if _, set := params[param]; !set {
+				return nil, fmt.Errorf("job template %s requires parameter %s", name, param)
+			}
+		}
+		for param := range params {
+			if !declared[param] {
+				return nil, fmt.Errorf("job template %s has no parameter %s", name, param)
+			}
+		}
+	}
+
+	expanded, err := substitute(jobTemplate.Job, params)
+	if err != nil {
+		return nil, fmt.Errorf("job template %s: %v", name, err)
+	}
+	settings, _ := expanded.(map[string]interface{})
+	if settings == nil {
+		settings = make(map[string]interface{})
+	}
+	for key, value := range job {
+		if key != "template" && key != "params" {
+			settings[key] = value
+		}
+	}
+	return settings, nil
+}
+
+// substitute returns a copy of raw settings with the parameters
+// substituted into every string
+func substitute(value interface{}, params map[string]string) (interface{}, error) {
+	switch value := value.(type) {
+	case string:
+		if !strings.Contains(value, "{{") {
+			return value, nil
+		}
+		tmpl, err := template.New("job").Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, params); err != nil {
+			return nil, err
+		}
+		return buf.String(), nil
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			substituted, err := substitute(item, params)
+			if err != nil {
+				return nil, err
+			}
+			copied[key] = substituted
+		}
+		return copied, nil
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, item := range value {
+			substituted, err := substitute(item, params)
+			if err != nil {
+				return nil, err
+			}
+			copied[i] = substituted
+		}
+		return copied, nil
+	default:
+		return value, nil
+	}
+}
+
+// decodeRaw decodes raw settings, converting scalars as needed, e.g. a
+// numeric parameter to a string
+func decodeRaw(raw interface{}, target interface{}) error {
+	return mapstructure.WeakDecode(raw, target)
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arcron.yaml")
+	content := `job_templates:
+  - name: customer-export
+    params: [customer, timeout]
+    defaults:
+      timeout: 30m
+    job:
+      name: "export-{{.customer}}"
+      command: "/opt/export.sh --customer {{.customer}}"
+      schedule: "0 0 3 * * *"
+      timeout: "{{.timeout}}"
+      labels:
+        customer: "{{.customer}}"
+jobs:
+  - name: backup
+    command: backup.sh
+    schedule: "@daily"
+  - template: customer-export
+    params:
+      customer: acme
+  - template: customer-export
+    params:
+      customer: 42
+      timeout: 2h
+    schedule: "0 0 4 * * *"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadStrict(path)
+	if err != nil {
+		t.Fatalf("LoadStrict() error = %v", err)
+	}
+	if len(cfg.Jobs) != 3 {
+		t.Fatalf("jobs = %+v, want backup and two exports", cfg.Jobs)
+	}
+
+	acme := cfg.Jobs[1]
+	if acme.Name != "export-acme" || acme.Command != "/opt/export.sh --customer acme" ||
+		acme.Timeout != 30*time.Minute || acme.Labels["customer"] != "acme" {
+		t.Errorf("acme job = %+v", acme)
+	}
+	other := cfg.Jobs[2]
+	if other.Name != "export-42" || other.Timeout != 2*time.Hour || other.Schedule != "0 0 4 * * *" {
+		t.Errorf("overridden job = %+v, want the given timeout and schedule", other)
+	}
+}
+
+func TestJobTemplateErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown job template": "jobs:\n  - template: missing\n",
+		"requires parameter":   "job_templates:\n  - name: t\n    params: [customer]\n    job: {name: x}\njobs:\n  - template: t\n",
+		"has no parameter":     "job_templates:\n  - name: t\n    params: [customer]\n    job: {name: x}\njobs:\n  - template: t\n    params: {customer: a, region: b}\n",
+		"map has no entry":     "job_templates:\n  - name: t\n    job: {name: \"{{.customer}}\"}\njobs:\n  - template: t\n",
+	}
+	for want, content := range tests {
+		path := filepath.Join(t.TempDir(), "arcron.yaml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Load() error = %v, want %q", err, want)
+		}
+	}
+}
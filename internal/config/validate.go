@@ -0,0 +1,247 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// Check is a validation of a loaded configuration run by Validate, e.g.
+// scheduler.CheckSchedules or storage.CheckConnection
+type Check func(cfg *Config) []error
+
+// ValidationError lists every problem found in a configuration file
+type ValidationError struct {
+	Path   string
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		lines[i] = "  " + err.Error()
+	}
+	return fmt.Sprintf("%s has %d problem(s):\n%s", e.Path, len(e.Errors), strings.Join(lines, "\n"))
+}
+
+// Validate checks a configuration file, with its jobs directory, without
+// running anything: unknown keys and values that do not decode, such as
+// invalid durations, job definitions, and then the given checks. It returns a *ValidationError
+// listing all problems found, rather than stopping at the first.
+func Validate(configPath string, checks ...Check) error {
+	if _, err := os.Stat(configPath); err != nil {
+		return err
+	}
+	v, err := readConfig(configPath, nil)
+	if err != nil {
+		return err
+	}
+
+	var problems []error
+	var config Config
+	if err := v.UnmarshalExact(&config); err != nil {
+		var decodeErr *mapstructure.Error
+		if !errors.As(err, &decodeErr) {
+			return fmt.Errorf("failed to unmarshal config: %v", err)
+		}
+		for _, message := range decodeErr.Errors {
+			problems = append(problems, errors.New(message))
+		}
+	}
+	if config.JobsDir != "" {
+		dirJobs, err := LoadJobsDir(config.JobsDir, config.JobTemplates, true)
+		if err != nil {
+			problems = append(problems, err)
+		}
+		config.Jobs = append(config.Jobs, dirJobs...)
+	}
+	setDefaults(&config)
+	if err := ResolveSecrets(&config); err != nil {
+		problems = append(problems, err)
+	}
+
+	problems = append(problems, CheckJobs(config.Jobs, config.Advanced)...)
+	problems = append(problems, CheckRateLimits(config.RateLimits)...)
+	problems = append(problems, CheckSmoothing(config.Monitoring.Smoothing)...)
+	problems = append(problems, CheckBatch(config.Database.Batch)...)
+	if _, err := ParseTrustedProxies(config.Server.TrustedProxies); err != nil {
+		problems = append(problems, err)
+	}
+	for _, check := range checks {
+		problems = append(problems, check(&config)...)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Path: configPath, Errors: problems}
+	}
+	return nil
+}
+
+// CheckJobs checks that every job has a unique name and a command, a
+// timeout within the maximum, and valid artifact patterns and output
+// metrics
+func CheckJobs(jobs []JobConfig, advanced AdvancedConfig) []error {
+	var problems []error
+	if advanced.DefaultTimeout < 0 || advanced.MaxTimeout < 0 {
+		problems = append(problems, fmt.Errorf("advanced: default_timeout and max_timeout cannot be negative"))
+	}
+	if advanced.MaxTimeout > 0 && advanced.DefaultTimeout > advanced.MaxTimeout {
+		problems = append(problems, fmt.Errorf("advanced: default_timeout %s exceeds max_timeout %s",
+			advanced.DefaultTimeout, advanced.MaxTimeout))
+	}
+	seen := make(map[string]bool)
+	for i, job := range jobs {
+		if job.Name == "" {
+			problems = append(problems, fmt.Errorf("jobs[%d]: name is required", i))
+		} else if seen[job.Name] {
+			problems = append(problems, fmt.Errorf("job %s: duplicate job name", job.Name))
+		}
+		seen[job.Name] = true
+
+		if job.Command == "" {
+			problems = append(problems, fmt.Errorf("job %s: command is required", job.Name))
+		}
+		if job.Timeout < 0 {
+			problems = append(problems, fmt.Errorf("job %s: timeout cannot be negative", job.Name))
+		}
+		if advanced.MaxTimeout > 0 && job.Timeout > advanced.MaxTimeout {
+			problems = append(problems, fmt.Errorf("job %s: timeout %s exceeds max_timeout %s",
+				job.Name, job.Timeout, advanced.MaxTimeout))
+		}
+		if job.Retries < 0 {
+			problems = append(problems, fmt.Errorf("job %s: retries cannot be negative", job.Name))
+		}
+		for _, pattern := range job.Artifacts {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				problems = append(problems, fmt.Errorf("job %s: invalid artifact pattern %q: %v", job.Name, pattern, err))
+			}
+		}
+		problems = append(problems, checkOutputMetrics(job)...)
+	}
+	return problems
+}
+
+// CheckRateLimits checks that every rate limit has a unique name and a
+// positive number of starts and window
+func CheckRateLimits(limits []RateLimitConfig) []error {
+	var problems []error
+	seen := make(map[string]bool)
+	for i, limit := range limits {
+		if limit.Name == "" {
+			problems = append(problems, fmt.Errorf("rate_limits[%d]: name is required", i))
+		} else if seen[limit.Name] {
+			problems = append(problems, fmt.Errorf("rate limit %s: duplicate name", limit.Name))
+		}
+		seen[limit.Name] = true
+
+		if limit.MaxStarts <= 0 {
+			problems = append(problems, fmt.Errorf("rate limit %s: max_starts must be positive", limit.Name))
+		}
+		if limit.Window <= 0 {
+			problems = append(problems, fmt.Errorf("rate limit %s: window must be positive", limit.Name))
+		}
+	}
+	return problems
+}
+
+// CheckSmoothing checks that decisions use one of the smoothing windows
+// the monitor keeps
+func CheckSmoothing(smoothing SmoothingConfig) []error {
+	var problems []error
+	windows := []struct {
+		key    string
+		window time.Duration
+	}{
+		{"prediction_window", smoothing.PredictionWindow},
+		{"gate_window", smoothing.GateWindow},
+	}
+	for _, w := range windows {
+		switch w.window {
+		case time.Minute, 5 * time.Minute, 15 * time.Minute:
+		default:
+			problems = append(problems, fmt.Errorf("monitoring.smoothing.%s: %s is not 1m, 5m or 15m", w.key, w.window))
+		}
+	}
+	return problems
+}
+
+// CheckBatch checks that metrics samples are written in batches of a
+// positive size, queue size and flush interval, reporting each setting
+// that is zero or negative. Load and Validate check the settings after
+// replacing unset ones by the defaults.
+func CheckBatch(batch BatchConfig) []error {
+	var problems []error
+	if batch.Size <= 0 {
+		problems = append(problems, fmt.Errorf("database.batch.size: %d is not positive", batch.Size))
+	}
+	if batch.FlushInterval <= 0 {
+		problems = append(problems, fmt.Errorf("database.batch.flush_interval: %s is not positive", batch.FlushInterval))
+	}
+	if batch.QueueSize <= 0 {
+		problems = append(problems, fmt.Errorf("database.batch.queue_size: %d is not positive", batch.QueueSize))
+	}
+	return problems
+}
+
+// ParseTrustedProxies parses the trusted proxies, each an address or a
+// CIDR range, into networks
+func ParseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("server.trusted_proxies: %q is not an address or CIDR range", proxy)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// metricName is the syntax of Prometheus metric names
+var metricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// checkOutputMetrics checks the output metric rules of a job: unique
+// valid names, a type, and one compiling regex with a group or JSON path
+func checkOutputMetrics(job JobConfig) []error {
+	var problems []error
+	seen := make(map[string]bool)
+	for i, metric := range job.Metrics {
+		if !metricName.MatchString(metric.Name) {
+			problems = append(problems, fmt.Errorf("job %s: metrics[%d]: invalid name %q", job.Name, i, metric.Name))
+		} else if seen[metric.Name] {
+			problems = append(problems, fmt.Errorf("job %s: duplicate metric %s", job.Name, metric.Name))
+		}
+		seen[metric.Name] = true
+
+		if metric.Type != "" && metric.Type != OutputGauge && metric.Type != OutputCounter {
+			problems = append(problems, fmt.Errorf("job %s: metric %s: type must be gauge or counter", job.Name, metric.Name))
+		}
+		switch {
+		case (metric.Regex == "") == (metric.JSONPath == ""):
+			problems = append(problems, fmt.Errorf("job %s: metric %s: set either regex or json_path", job.Name, metric.Name))
+		case metric.Regex != "":
+			re, err := regexp.Compile(metric.Regex)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("job %s: metric %s: invalid regex: %v", job.Name, metric.Name, err))
+			} else if re.NumSubexp() == 0 {
+				problems = append(problems, fmt.Errorf("job %s: metric %s: regex needs a group capturing the value", job.Name, metric.Name))
+			}
+		}
+	}
+	return problems
+}
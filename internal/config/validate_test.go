@@ -0,0 +1,183 @@
+package config
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arcron.yaml")
+	content := `server:
+  port: 8080
+  read_timeout: 5 minutes
+  colour: blue
+jobs:
+  - name: backup
+    schedule: "0 0 2 * * *"
+    command: /usr/local/bin/backup.sh
+  - name: backup
+    schedule: "0 0 3 * * *"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	checked := false
+	err := Validate(path, func(cfg *Config) []error {
+		checked = true
+		return []error{errors.New("custom problem")}
+	})
+
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("Validate() error = %v, want a *ValidationError", err)
+	}
+	if !checked {
+		t.Error("check was not run")
+	}
+
+	message := err.Error()
+	for _, want := range []string{"read_timeout", "colour", "duplicate job name", "command is required", "custom problem"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("Validate() error does not mention %q:\n%s", want, message)
+		}
+	}
+}
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arcron.yaml")
+	content := "server:\n  read_timeout: 30s\njobs:\n  - name: backup\n    schedule: \"0 0 2 * * *\"\n    command: backup.sh\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Validate(path); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := Validate(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Validate() of a missing file succeeded")
+	}
+}
+
+func TestValidateTimeoutMaximum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arcron.yaml")
+	content := `advanced:
+  max_timeout: 2h
+jobs:
+  - name: quick
+    schedule: "@hourly"
+    command: /usr/local/bin/quick
+  - name: endless
+    schedule: "@daily"
+    command: /usr/local/bin/endless
+    timeout: 3h
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var validation *ValidationError
+	if err := Validate(path); !errors.As(err, &validation) || len(validation.Errors) != 1 {
+		t.Fatalf("Validate() error = %v, want only the timeout above the maximum", err)
+	}
+	if !strings.Contains(validation.Errors[0].Error(), "endless") {
+		t.Errorf("problem = %v, want the job named", validation.Errors[0])
+	}
+}
+
+func TestCheckOutputMetrics(t *testing.T) {
+	job := JobConfig{Name: "etl", Command: "etl", Metrics: []OutputMetric{
+		{Name: "rows_processed", Regex: `rows_processed=(\d+)`},
+		{Name: "bytes", JSONPath: "$.stats.bytes", Type: OutputCounter},
+		{Name: "rows_processed", Regex: `rows=(\d+)`},
+		{Name: "no-dashes", Regex: `x=(\d+)`},
+		{Name: "no_group", Regex: `rows=\d+`},
+		{Name: "both", Regex: `x=(\d+)`, JSONPath: "x"},
+		{Name: "histogram", JSONPath: "x", Type: "histogram"},
+	}}
+
+	problems := checkOutputMetrics(job)
+	if len(problems) != 5 {
+		t.Fatalf("checkOutputMetrics() = %v, want 5 problems", problems)
+	}
+	for i, want := range []string{"duplicate metric", "invalid name", "group", "either regex or json_path", "gauge or counter"} {
+		if !strings.Contains(problems[i].Error(), want) {
+			t.Errorf("problem %d = %v, want %q", i, problems[i], want)
+		}
+	}
+}
+
+func TestCheckRateLimits(t *testing.T) {
+	problems := CheckRateLimits([]RateLimitConfig{
+		{Name: "global", MaxStarts: 10, Window: time.Minute},
+		{Name: "global", MaxStarts: 1, Window: time.Minute},
+		{MaxStarts: 1, Window: time.Minute},
+		{Name: "db", Window: time.Minute},
+		{Name: "api", MaxStarts: 1},
+	})
+	if len(problems) != 4 {
+		t.Fatalf("CheckRateLimits() = %v, want 4 problems", problems)
+	}
+	for i, want := range []string{"duplicate name", "name is required", "max_starts", "window"} {
+		if !strings.Contains(problems[i].Error(), want) {
+			t.Errorf("problem %d = %v, want %q", i, problems[i], want)
+		}
+	}
+
+	job := JobConfig{Name: "invoices", Namespace: "billing", Labels: map[string]string{"db": "billing"}}
+	if !(RateLimitConfig{}).Matches(job) || !(RateLimitConfig{Namespace: "billing", Labels: map[string]string{"db": "billing"}}).Matches(job) {
+		t.Error("rate limit does not match a job of its group")
+	}
+	if (RateLimitConfig{Labels: map[string]string{"db": "orders"}}).Matches(job) || (RateLimitConfig{Namespace: "default"}).Matches(job) {
+		t.Error("rate limit matches a job outside its group")
+	}
+}
+
+func TestCheckSmoothing(t *testing.T) {
+	if problems := CheckSmoothing(SmoothingConfig{PredictionWindow: 15 * time.Minute, GateWindow: time.Minute}); len(problems) != 0 {
+		t.Errorf("CheckSmoothing() of kept windows = %v, want none", problems)
+	}
+	problems := CheckSmoothing(SmoothingConfig{PredictionWindow: 10 * time.Minute, GateWindow: time.Minute})
+	if len(problems) != 1 || !strings.Contains(problems[0].Error(), "prediction_window") {
+		t.Errorf("CheckSmoothing() = %v, want a prediction_window problem", problems)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	networks, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::1"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+	if len(networks) != 3 || !networks[1].Contains(net.ParseIP("192.0.2.1")) || networks[1].Contains(net.ParseIP("192.0.2.2")) {
+		t.Errorf("ParseTrustedProxies() = %v, want a range and two single addresses", networks)
+	}
+	if _, err := ParseTrustedProxies([]string{"proxy.internal"}); err == nil {
+		t.Error("ParseTrustedProxies() of a host name succeeded")
+	}
+}
+
+func TestCheckBatch(t *testing.T) {
+	if problems := CheckBatch(BatchConfig{Size: 100, FlushInterval: 10 * time.Second, QueueSize: 1000}); len(problems) != 0 {
+		t.Errorf("CheckBatch() of a valid batch = %v, want none", problems)
+	}
+	problems := CheckBatch(BatchConfig{Size: -1, FlushInterval: -time.Second, QueueSize: 1000})
+	if len(problems) != 2 || !strings.Contains(problems[0].Error(), "size") || !strings.Contains(problems[1].Error(), "flush_interval") {
+		t.Errorf("CheckBatch() = %v, want size and flush_interval problems", problems)
+	}
+
+	path := filepath.Join(t.TempDir(), "arcron.yaml")
+	if err := os.WriteFile(path, []byte("database:\n  batch:\n    flush_interval: -5s\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(path); err == nil || !strings.Contains(err.Error(), "flush_interval") {
+		t.Errorf("Validate() error = %v, want the negative flush_interval reported", err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "flush_interval") {
+		t.Errorf("Load() error = %v, want the negative flush_interval rejected", err)
+	}
+}
@@ -0,0 +1,97 @@
+package debug
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/version"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+	expvar.Publish("uptime_seconds", expvar.Func(func() interface{} {
+		return version.Uptime().Seconds()
+	}))
+	expvar.Publish("build", expvar.Func(func() interface{} {
+		return version.Get()
+	}))
+}
+
+// Server exposes pprof and expvar on a dedicated admin port
+type Server struct {
+	config   config.DebugConfig
+	server   *http.Server
+	listener net.Listener
+}
+
+// New creates a new debug server
+func New(cfg config.DebugConfig) *Server {
+	return &Server{
+		config: cfg,
+	}
+}
+
+// Start starts the debug server if it is enabled. The port is bound
+// before Start returns, so a port in use fails the start.
+func (s *Server) Start() error {
+	if !s.config.Enabled {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
+	s.server = &http.Server{
+		Addr:        addr,
+		Handler:     mux,
+		ReadTimeout: 10 * time.Second,
+		// No write timeout: CPU profiles and traces stream for their full duration
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start debug server: %v", err)
+	}
+	s.listener = listener
+
+	go func() {
+		logrus.Infof("Starting debug server on %s", listener.Addr())
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logrus.Errorf("Debug server error: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Addr returns the address the debug server listens on, or nil if it is
+// not running
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Stop stops the debug server
+func (s *Server) Stop() error {
+	if s.server != nil {
+		return s.server.Close()
+	}
+	return nil
+}
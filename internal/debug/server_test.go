@@ -0,0 +1,37 @@
+package debug
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+func TestServerBindsOnlyWhenEnabled(t *testing.T) {
+	disabled := New(config.DebugConfig{Host: "127.0.0.1"})
+	if err := disabled.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if addr := disabled.Addr(); addr != nil {
+		t.Errorf("disabled debug server listening on %s", addr)
+	}
+
+	// Port 0 lets the system pick a free port
+	enabled := New(config.DebugConfig{Enabled: true, Host: "127.0.0.1"})
+	if err := enabled.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer enabled.Stop()
+	if enabled.Addr() == nil {
+		t.Fatal("enabled debug server not listening")
+	}
+
+	resp, err := http.Get("http://" + enabled.Addr().String() + "/debug/vars")
+	if err != nil {
+		t.Fatalf("GET /debug/vars error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /debug/vars status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
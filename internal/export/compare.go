@@ -0,0 +1,151 @@
+package export
+
+import (
+	"fmt"
+
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// Delta compares a value of an execution with its average over the
+// previous runs. ChangePercent is left out when the average is zero.
+type Delta struct {
+	Current       float64  `json:"current"`
+	Previous      float64  `json:"previous"`
+	Change        float64  `json:"change"`
+	ChangePercent *float64 `json:"change_percent,omitempty"`
+}
+
+// ExitCodeChange compares the exit code of an execution with that of the
+// run before it
+type ExitCodeChange struct {
+	Current  int  `json:"current"`
+	Previous int  `json:"previous"`
+	Changed  bool `json:"changed"`
+}
+
+// ResourceDeltas compares the system resource usage measured while an
+// execution ran with the previous runs
+type ResourceDeltas struct {
+	AvgCPU    Delta `json:"avg_cpu"`
+	MaxCPU    Delta `json:"max_cpu"`
+	AvgMemory Delta `json:"avg_memory"`
+	MaxMemory Delta `json:"max_memory"`
+}
+
+// Comparison compares an execution with the previous runs of its job.
+// Regression is set when the duration grew by more than
+// RegressionThreshold percent over their average.
+type Comparison struct {
+	ExecutionID         string          `json:"execution_id"`
+	JobName             string          `json:"job_name"`
+	Previous            []string        `json:"previous"`
+	Duration            Delta           `json:"duration"`
+	OutputSize          Delta           `json:"output_size"`
+	ExitCode            *ExitCodeChange `json:"exit_code,omitempty"`
+	Resources           ResourceDeltas  `json:"resources"`
+	Regression          bool            `json:"regression"`
+	RegressionThreshold float64         `json:"regression_threshold"`
+}
+
+// Compare compares a finished execution with up to runs finished runs of
+// its job that started before it. Earlier attempts of the same run are
+// not compared against. Resource usage is taken from the system metrics
+// collected during each run; metrics may be nil to leave it out.
+func Compare(execution *types.JobExecution, runs int, threshold float64, executions storage.JobExecutionRepo, metrics storage.MetricsRepo) (*Comparison, error) {
+	if execution.Status != types.StatusCompleted && execution.Status != types.StatusFailed {
+		return nil, fmt.Errorf("execution %s has not finished", execution.ID)
+	}
+
+	// Earlier attempts of the run are fetched too and skipped
+	candidates, err := executions.GetPreviousJobExecutions(execution.JobName, execution.StartTime, runs+execution.Attempt-1)
+	if err != nil {
+		return nil, err
+	}
+	current, err := executionRow(execution, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &Comparison{
+		ExecutionID:         execution.ID,
+		JobName:             execution.JobName,
+		Previous:            []string{},
+		RegressionThreshold: threshold,
+	}
+	var previous []*ExecutionRow
+	var outputSize float64
+	for _, candidate := range candidates {
+		if len(previous) == runs {
+			break
+		}
+		if sameRun(execution, candidate) {
+			continue
+		}
+		row, err := executionRow(candidate, metrics)
+		if err != nil {
+			return nil, err
+		}
+		if len(previous) == 0 {
+			comparison.ExitCode = &ExitCodeChange{
+				Current:  execution.ExitCode,
+				Previous: candidate.ExitCode,
+				Changed:  execution.ExitCode != candidate.ExitCode,
+			}
+		}
+		previous = append(previous, row)
+		outputSize += float64(len(candidate.Output))
+		comparison.Previous = append(comparison.Previous, candidate.ID)
+	}
+
+	average := func(value func(row *ExecutionRow) float64) float64 {
+		if len(previous) == 0 {
+			return 0
+		}
+		var sum float64
+		for _, row := range previous {
+			sum += value(row)
+		}
+		return sum / float64(len(previous))
+	}
+	compare := func(value func(row *ExecutionRow) float64) Delta {
+		return newDelta(value(current), average(value))
+	}
+	comparison.Duration = compare(func(row *ExecutionRow) float64 { return row.Duration })
+	comparison.Resources = ResourceDeltas{
+		AvgCPU:    compare(func(row *ExecutionRow) float64 { return row.AvgCPU }),
+		MaxCPU:    compare(func(row *ExecutionRow) float64 { return row.MaxCPU }),
+		AvgMemory: compare(func(row *ExecutionRow) float64 { return row.AvgMemory }),
+		MaxMemory: compare(func(row *ExecutionRow) float64 { return row.MaxMemory }),
+	}
+	if len(previous) > 0 {
+		outputSize /= float64(len(previous))
+	}
+	comparison.OutputSize = newDelta(float64(len(execution.Output)), outputSize)
+
+	if percent := comparison.Duration.ChangePercent; percent != nil && *percent > threshold {
+		comparison.Regression = true
+	}
+	return comparison, nil
+}
+
+// newDelta compares a current value with a previous one
+func newDelta(current, previous float64) Delta {
+	delta := Delta{Current: current, Previous: previous, Change: current - previous}
+	if previous != 0 {
+		percent := delta.Change / previous * 100
+		delta.ChangePercent = &percent
+	}
+	return delta
+}
+
+// sameRun reports whether two executions are attempts of the same run
+func sameRun(a, b *types.JobExecution) bool {
+	run := func(execution *types.JobExecution) string {
+		if execution.ParentExecutionID != "" {
+			return execution.ParentExecutionID
+		}
+		return execution.ID
+	}
+	return run(a) == run(b)
+}
@@ -0,0 +1,57 @@
+package export
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestCompare(t *testing.T) {
+	store := storage.NewMemoryStore()
+	start := time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC)
+	executions := []*types.JobExecution{
+		{ID: "a", JobName: "backup", StartTime: start, Duration: 100, Status: types.StatusCompleted, Output: "done", Attempt: 1},
+		{ID: "b", JobName: "backup", StartTime: start.Add(time.Hour), Duration: 120, Status: types.StatusCompleted, Output: "done!!", Attempt: 1},
+		{ID: "c", JobName: "backup", StartTime: start.Add(2 * time.Hour), Duration: 10, Status: types.StatusFailed, ExitCode: 1, Attempt: 1},
+		{ID: "c-2", JobName: "backup", StartTime: start.Add(2*time.Hour + time.Minute), Duration: 150, Status: types.StatusCompleted,
+			Output: "done!!!!", Attempt: 2, ParentExecutionID: "c"},
+		{ID: "d", JobName: "backup", StartTime: start.Add(3 * time.Hour), Status: types.StatusRunning, Attempt: 1},
+	}
+	for _, execution := range executions {
+		if err := store.StoreJobExecution(execution); err != nil {
+			t.Fatalf("StoreJobExecution() error = %v", err)
+		}
+	}
+
+	comparison, err := Compare(executions[3], 10, 20, store, nil)
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+	if len(comparison.Previous) != 2 || comparison.Previous[0] != "b" {
+		t.Fatalf("previous runs = %v, want b and a without the first attempt of the run", comparison.Previous)
+	}
+	if duration := comparison.Duration; duration.Previous != 110 || duration.Change != 40 || *duration.ChangePercent <= 36 {
+		t.Errorf("duration = %+v, want 150 against an average of 110", duration)
+	}
+	if !comparison.Regression {
+		t.Error("a 36% slower run was not flagged with a 20% threshold")
+	}
+	if comparison.OutputSize.Previous != 5 || comparison.OutputSize.Current != 8 {
+		t.Errorf("output size = %+v, want 8 against 5", comparison.OutputSize)
+	}
+	if comparison.ExitCode == nil || comparison.ExitCode.Changed {
+		t.Errorf("exit code = %+v, want unchanged from b", comparison.ExitCode)
+	}
+
+	if comparison, err = Compare(executions[3], 1, 50, store, nil); err != nil || comparison.Regression || len(comparison.Previous) != 1 {
+		t.Errorf("Compare() = %+v, %v, want one run compared and no regression under 50%%", comparison, err)
+	}
+	if comparison, err = Compare(executions[0], 10, 20, store, nil); err != nil || comparison.Regression || comparison.Duration.ChangePercent != nil {
+		t.Errorf("Compare() of the first run = %+v, %v, want nothing compared", comparison, err)
+	}
+	if _, err := Compare(executions[4], 10, 20, store, nil); err == nil {
+		t.Error("Compare() accepted a running execution")
+	}
+}
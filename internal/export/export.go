@@ -0,0 +1,207 @@
+// Package export writes job execution history in formats analysts can load
+// into notebooks and spreadsheets.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// Export formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// ContentType returns the MIME type of an export format
+func ContentType(format string) string {
+	if format == FormatJSON {
+		return "application/json"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// CheckFormat returns an error for formats that cannot be exported
+func CheckFormat(format string) error {
+	switch format {
+	case FormatCSV, FormatJSON:
+		return nil
+	case "parquet":
+		return fmt.Errorf("parquet export is not supported, use %s or %s", FormatCSV, FormatJSON)
+	default:
+		return fmt.Errorf("unknown export format %q, use %s or %s", format, FormatCSV, FormatJSON)
+	}
+}
+
+// ExecutionRow is an exported execution with the system resource usage
+// measured while it ran
+type ExecutionRow struct {
+	ID                string    `json:"id"`
+	JobName           string    `json:"job_name"`
+	Attempt           int       `json:"attempt"`
+	ParentExecutionID string    `json:"parent_execution_id,omitempty"`
+	StartTime         time.Time `json:"start_time"`
+	EndTime           time.Time `json:"end_time"`
+	Duration          float64   `json:"duration"`
+	Status            string    `json:"status"`
+	ExitCode          int       `json:"exit_code"`
+	Error             string    `json:"error,omitempty"`
+	Samples           int       `json:"samples"`
+	AvgCPU            float64   `json:"avg_cpu"`
+	MaxCPU            float64   `json:"max_cpu"`
+	AvgMemory         float64   `json:"avg_memory"`
+	MaxMemory         float64   `json:"max_memory"`
+	FailureReason     string    `json:"failure_reason,omitempty"`
+}
+
+// csvHeader names the CSV columns in the order of csvRecord
+var csvHeader = []string{"id", "job_name", "attempt", "parent_execution_id", "start_time", "end_time",
+	"duration", "status", "exit_code", "error", "samples", "avg_cpu", "max_cpu", "avg_memory", "max_memory", "failure_reason"}
+
+// Executions streams the full execution history of a job to w, oldest
+// first. Resource usage is taken from the system metrics collected during
+// each run; metrics may be nil to leave it out.
+func Executions(w io.Writer, format, jobName string, executions storage.JobExecutionRepo, metrics storage.MetricsRepo) error {
+	return ExecutionsBetween(w, format, jobName, time.Time{}, time.Time{}, executions, metrics)
+}
+
+// errPastUntil stops reading executions once they start after the range
+var errPastUntil = errors.New("past the end of the export range")
+
+// ExecutionsBetween is Executions limited to the runs that started from
+// since to until; a zero time leaves that end open.
+func ExecutionsBetween(w io.Writer, format, jobName string, since, until time.Time, executions storage.JobExecutionRepo, metrics storage.MetricsRepo) error {
+	if err := CheckFormat(format); err != nil {
+		return err
+	}
+
+	var write func(row *ExecutionRow) error
+	var finish func() error
+
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(csvHeader); err != nil {
+			return err
+		}
+		write = func(row *ExecutionRow) error { return writer.Write(csvRecord(row)) }
+		finish = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	case FormatJSON:
+		// A JSON array written row by row
+		encoder := json.NewEncoder(w)
+		separator := "["
+		write = func(row *ExecutionRow) error {
+			if _, err := io.WriteString(w, separator); err != nil {
+				return err
+			}
+			separator = ","
+			return encoder.Encode(row)
+		}
+		finish = func() error {
+			if separator == "[" {
+				_, err := io.WriteString(w, "[]\n")
+				return err
+			}
+			_, err := io.WriteString(w, "]\n")
+			return err
+		}
+	}
+
+	err := executions.EachJobExecution(jobName, func(execution *types.JobExecution) error {
+		if execution.StartTime.Before(since) {
+			return nil
+		}
+		// Executions come oldest first, so none of the rest is in range
+		if !until.IsZero() && execution.StartTime.After(until) {
+			return errPastUntil
+		}
+		row, err := executionRow(execution, metrics)
+		if err != nil {
+			return err
+		}
+		return write(row)
+	})
+	if err != nil && err != errPastUntil {
+		return err
+	}
+	return finish()
+}
+
+// executionRow converts an execution and adds its resource usage
+func executionRow(execution *types.JobExecution, metrics storage.MetricsRepo) (*ExecutionRow, error) {
+	row := &ExecutionRow{
+		ID:                execution.ID,
+		JobName:           execution.JobName,
+		Attempt:           execution.Attempt,
+		ParentExecutionID: execution.ParentExecutionID,
+		StartTime:         execution.StartTime,
+		EndTime:           execution.EndTime,
+		Duration:          execution.Duration,
+		Status:            string(execution.Status),
+		ExitCode:          execution.ExitCode,
+		Error:             execution.Error,
+		FailureReason:     string(execution.FailureReason),
+	}
+	if metrics == nil || execution.StartTime.IsZero() || execution.EndTime.Before(execution.StartTime) {
+		return row, nil
+	}
+
+	samples, err := metrics.GetSystemMetrics(execution.StartTime, execution.EndTime, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, sample := range samples {
+		row.AvgCPU += sample.CPUUsage
+		row.AvgMemory += sample.MemoryUsage
+		row.MaxCPU = max(row.MaxCPU, sample.CPUUsage)
+		row.MaxMemory = max(row.MaxMemory, sample.MemoryUsage)
+	}
+	if row.Samples = len(samples); row.Samples > 0 {
+		row.AvgCPU /= float64(row.Samples)
+		row.AvgMemory /= float64(row.Samples)
+	}
+	return row, nil
+}
+
+// csvRecord formats a row as CSV fields in the order of csvHeader
+func csvRecord(row *ExecutionRow) []string {
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339Nano)
+	}
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	return []string{
+		row.ID,
+		row.JobName,
+		strconv.Itoa(row.Attempt),
+		row.ParentExecutionID,
+		formatTime(row.StartTime),
+		formatTime(row.EndTime),
+		formatFloat(row.Duration),
+		row.Status,
+		strconv.Itoa(row.ExitCode),
+		row.Error,
+		strconv.Itoa(row.Samples),
+		formatFloat(row.AvgCPU),
+		formatFloat(row.MaxCPU),
+		formatFloat(row.AvgMemory),
+		formatFloat(row.MaxMemory),
+		row.FailureReason,
+	}
+}
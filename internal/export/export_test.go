@@ -0,0 +1,129 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func newTestStore(t *testing.T) *storage.MemoryStore {
+	t.Helper()
+
+	store := storage.NewMemoryStore()
+	start := time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC)
+	executions := []*types.JobExecution{
+		{ID: "b", JobName: "backup", StartTime: start.Add(time.Hour), EndTime: start.Add(time.Hour + time.Minute),
+			Duration: 60, Status: types.StatusFailed, ExitCode: 1, Error: "disk full, \"quota\"", Attempt: 1},
+		{ID: "a", JobName: "backup", StartTime: start, EndTime: start.Add(2 * time.Minute),
+			Duration: 120, Status: types.StatusCompleted, Attempt: 1},
+		{ID: "c", JobName: "report", StartTime: start, Status: types.StatusCompleted, Attempt: 1},
+	}
+	for _, execution := range executions {
+		if err := store.StoreJobExecution(execution); err != nil {
+			t.Fatalf("StoreJobExecution() error = %v", err)
+		}
+	}
+	for i, cpu := range []float64{20, 40, 90} {
+		metrics := &types.SystemMetrics{Timestamp: start.Add(time.Duration(i) * time.Minute), CPUUsage: cpu, MemoryUsage: 50}
+		if err := store.StoreSystemMetrics(metrics); err != nil {
+			t.Fatalf("StoreSystemMetrics() error = %v", err)
+		}
+	}
+	return store
+}
+
+func TestExecutionsCSV(t *testing.T) {
+	store := newTestStore(t)
+
+	var out bytes.Buffer
+	if err := Executions(&out, FormatCSV, "backup", store, store); err != nil {
+		t.Fatalf("Executions() error = %v", err)
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if len(records) != 3 || records[0][0] != "id" {
+		t.Fatalf("export has %d records, want a header and 2 executions", len(records))
+	}
+	first, second := records[1], records[2]
+	if first[0] != "a" || second[0] != "b" {
+		t.Errorf("executions exported as %s, %s, want oldest first", first[0], second[0])
+	}
+	if first[10] != "3" || first[11] != "50" || first[12] != "90" {
+		t.Errorf("resource usage = samples %s, avg cpu %s, max cpu %s, want 3, 50, 90", first[10], first[11], first[12])
+	}
+	if second[9] != "disk full, \"quota\"" {
+		t.Errorf("error column = %q, want the error unchanged", second[9])
+	}
+}
+
+func TestExecutionsJSON(t *testing.T) {
+	store := newTestStore(t)
+
+	var out bytes.Buffer
+	if err := Executions(&out, FormatJSON, "backup", store, nil); err != nil {
+		t.Fatalf("Executions() error = %v", err)
+	}
+	var rows []ExecutionRow
+	if err := json.Unmarshal(out.Bytes(), &rows); err != nil {
+		t.Fatalf("export is not valid JSON: %v", err)
+	}
+	if len(rows) != 2 || rows[0].ID != "a" || rows[1].ExitCode != 1 || rows[0].Samples != 0 {
+		t.Errorf("rows = %+v, want both executions without resource usage", rows)
+	}
+
+	out.Reset()
+	if err := Executions(&out, FormatJSON, "unknown", store, nil); err != nil {
+		t.Fatalf("Executions() error = %v", err)
+	}
+	if err := json.Unmarshal(out.Bytes(), &rows); err != nil || len(rows) != 0 {
+		t.Errorf("export of a job without executions = %q, want an empty array", out.String())
+	}
+}
+
+func TestExecutionsBetween(t *testing.T) {
+	store := newTestStore(t)
+	start := time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC)
+
+	var rows []ExecutionRow
+	for _, tt := range []struct {
+		since, until time.Time
+		want         []string
+	}{
+		{since: start.Add(time.Minute), want: []string{"b"}},
+		{until: start.Add(time.Minute), want: []string{"a"}},
+		{since: start, until: start.Add(time.Hour), want: []string{"a", "b"}},
+		{since: start.Add(2 * time.Hour), want: nil},
+	} {
+		var out bytes.Buffer
+		if err := ExecutionsBetween(&out, FormatJSON, "backup", tt.since, tt.until, store, nil); err != nil {
+			t.Fatalf("ExecutionsBetween() error = %v", err)
+		}
+		if err := json.Unmarshal(out.Bytes(), &rows); err != nil {
+			t.Fatalf("export is not valid JSON: %v", err)
+		}
+		var ids []string
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("ExecutionsBetween(%v, %v) = %v, want %v", tt.since, tt.until, ids, tt.want)
+		}
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	for format, valid := range map[string]bool{"csv": true, "json": true, "parquet": false, "xml": false} {
+		if err := CheckFormat(format); (err == nil) != valid {
+			t.Errorf("CheckFormat(%q) = %v, want valid %v", format, err, valid)
+		}
+	}
+}
@@ -0,0 +1,484 @@
+// Package graphql executes GraphQL queries against a schema of Go
+// resolvers. It supports what dashboards need to fetch a view in one round
+// trip: queries with variables, nested selection sets with arguments and
+// aliases, fragments, the @include and @skip directives and __typename.
+// Mutations, subscriptions and introspection are not supported.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// MaxDepth is how deeply selection sets may be nested
+const MaxDepth = 10
+
+// Object is a type of the schema whose fields queries select
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field is a field of an object. A field without Resolve returns the value
+// of the same name in the JSON encoding of the object's value.
+type Field struct {
+	// Type is the object of the value, or of the elements of a list
+	// value; nil for scalars, which are returned as JSON
+	Type *Object
+	// Args are the names of the arguments the field accepts
+	Args    []string
+	Resolve func(p Params) (interface{}, error)
+}
+
+// Params are passed to resolvers: the value of the object the field is
+// selected on and the arguments of the field
+type Params struct {
+	Context context.Context
+	Source  interface{}
+	Args    map[string]interface{}
+}
+
+// JSONFields returns a scalar field for every JSON field of a struct, to
+// build Object.Fields from
+func JSONFields(sample interface{}) map[string]*Field {
+	fields := make(map[string]*Field)
+	t := reflect.TypeOf(sample)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = &Field{}
+	}
+	return fields
+}
+
+// Request is a GraphQL request as posted over HTTP
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the result of a request: the selected data and the errors
+// of fields that failed, whose values are null
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+// Error is an error of a request, with the path of the field it occurred
+// at
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Execute runs a request against the schema of which query is the root.
+// Errors of the request itself, e.g. a syntax error, are returned as an
+// error; errors of fields are part of the response.
+func Execute(ctx context.Context, query *Object, request Request) (*Response, error) {
+	doc, err := parse(request.Query)
+	if err != nil {
+		return nil, fmt.Errorf("syntax error: %v", err)
+	}
+
+	var op *operation
+	for _, candidate := range doc.operations {
+		if request.OperationName == "" || candidate.name == request.OperationName {
+			if op != nil {
+				return nil, fmt.Errorf("operationName is required for documents with several operations")
+			}
+			op = candidate
+		}
+	}
+	if op == nil {
+		return nil, fmt.Errorf("operation %s not found", request.OperationName)
+	}
+	if op.kind != "query" {
+		return nil, fmt.Errorf("%s operations are not supported", op.kind)
+	}
+
+	variables := make(map[string]interface{})
+	for _, definition := range op.variables {
+		value, given := request.Variables[definition.name]
+		if !given && definition.hasDefault {
+			value, given = definition.defaultValue, true
+		}
+		if definition.nonNull && (!given || value == nil) {
+			return nil, fmt.Errorf("variable $%s is required", definition.name)
+		}
+		if given {
+			variables[definition.name] = value
+		}
+	}
+
+	e := &executor{ctx: ctx, fragments: doc.fragments, variables: variables}
+	data, err := e.selectFields(query, nil, op.selections, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Data: data, Errors: e.errors}, nil
+}
+
+// executor runs the selections of an operation
+type executor struct {
+	ctx       context.Context
+	fragments map[string]*fragment
+	variables map[string]interface{}
+	errors    []*Error
+}
+
+// result holds the fields of an object in the order they were selected
+type result struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (r *result) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(r.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// selectFields resolves the selections on a value of an object. Errors
+// of the query, e.g. unknown fields, end the execution.
+func (e *executor) selectFields(object *Object, source interface{}, selections []selection, path []interface{}) (*result, error) {
+	depth := 0
+	for _, element := range path {
+		if _, isKey := element.(string); isKey {
+			depth++
+		}
+	}
+	if depth > MaxDepth {
+		return nil, fmt.Errorf("the query is nested more than %d levels deep", MaxDepth)
+	}
+
+	collected, err := e.collectFields(object, selections, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Fields selected more than once under the same key are resolved once
+	// with their selections merged
+	var fields []selection
+	index := make(map[string]int)
+	for _, sel := range collected {
+		key := sel.responseKey()
+		i, seen := index[key]
+		if !seen {
+			index[key] = len(fields)
+			fields = append(fields, sel)
+			continue
+		}
+		if fields[i].name != sel.name {
+			return nil, fmt.Errorf("fields %s and %s are both selected as %s", fields[i].name, sel.name, key)
+		}
+		if sel.selections != nil {
+			fields[i].selections = append(append([]selection{}, fields[i].selections...), sel.selections...)
+		}
+	}
+
+	out := &result{values: make(map[string]interface{})}
+	var encoded map[string]interface{}
+	for _, sel := range fields {
+		key := sel.responseKey()
+		out.keys = append(out.keys, key)
+		fieldPath := append(append([]interface{}{}, path...), key)
+
+		if sel.name == "__typename" {
+			out.values[key] = object.Name
+			continue
+		}
+		field, ok := object.Fields[sel.name]
+		if !ok {
+			return nil, fmt.Errorf("field %s is not defined on %s", sel.name, object.Name)
+		}
+		if field.Type == nil && sel.selections != nil {
+			return nil, fmt.Errorf("field %s of %s is a scalar and has no fields to select", sel.name, object.Name)
+		}
+		if field.Type != nil && sel.selections == nil {
+			return nil, fmt.Errorf("field %s of %s needs a selection of its fields", sel.name, object.Name)
+		}
+		args, err := e.arguments(field, sel)
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %s: %v", sel.name, object.Name, err)
+		}
+
+		var value interface{}
+		if field.Resolve != nil {
+			value, err = field.Resolve(Params{Context: e.ctx, Source: source, Args: args})
+		} else {
+			if encoded == nil {
+				encoded, err = encode(source)
+			}
+			value = encoded[sel.name]
+		}
+		if err != nil {
+			e.errors = append(e.errors, &Error{Message: err.Error(), Path: fieldPath})
+			out.values[key] = nil
+			continue
+		}
+
+		if field.Type != nil {
+			value, err = e.complete(field.Type, value, sel.selections, fieldPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+		out.values[key] = value
+	}
+	return out, nil
+}
+
+// complete selects the fields of a value of an object or of each element
+// of a list of them
+func (e *executor) complete(object *Object, value interface{}, selections []selection, path []interface{}) (interface{}, error) {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || ((v.Kind() == reflect.Pointer || v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.IsNil()) {
+		if v.Kind() == reflect.Slice {
+			return []interface{}{}, nil
+		}
+		return nil, nil
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return e.selectFields(object, value, selections, path)
+	}
+
+	list := make([]interface{}, v.Len())
+	for i := range list {
+		item, err := e.complete(object, v.Index(i).Interface(), selections, append(path, i))
+		if err != nil {
+			return nil, err
+		}
+		list[i] = item
+	}
+	return list, nil
+}
+
+// collectFields flattens fragments into the fields they select, leaving
+// out those excluded by directives
+func (e *executor) collectFields(object *Object, selections []selection, visited map[string]bool) ([]selection, error) {
+	var fields []selection
+	for _, sel := range selections {
+		included, err := e.included(sel.directives)
+		if err != nil {
+			return nil, err
+		}
+		if !included {
+			continue
+		}
+
+		switch {
+		case sel.fragment != "":
+			frag, ok := e.fragments[sel.fragment]
+			if !ok {
+				return nil, fmt.Errorf("fragment %s is not defined", sel.fragment)
+			}
+			if visited[sel.fragment] {
+				return nil, fmt.Errorf("fragment %s spreads itself", sel.fragment)
+			}
+			if frag.typeCondition != object.Name {
+				return nil, fmt.Errorf("fragment %s on %s cannot be spread on %s", sel.fragment, frag.typeCondition, object.Name)
+			}
+			nested := map[string]bool{sel.fragment: true}
+			for name := range visited {
+				nested[name] = true
+			}
+			spread, err := e.collectFields(object, frag.selections, nested)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, spread...)
+		case sel.inline:
+			if sel.typeCondition != "" && sel.typeCondition != object.Name {
+				return nil, fmt.Errorf("fragment on %s cannot be spread on %s", sel.typeCondition, object.Name)
+			}
+			inline, err := e.collectFields(object, sel.selections, visited)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, inline...)
+		default:
+			fields = append(fields, sel)
+		}
+	}
+	return fields, nil
+}
+
+// included evaluates the @include and @skip directives of a selection
+func (e *executor) included(directives []directive) (bool, error) {
+	for _, d := range directives {
+		if d.name != "include" && d.name != "skip" {
+			return false, fmt.Errorf("directive @%s is not supported", d.name)
+		}
+		value, err := e.resolveValue(d.arguments["if"])
+		if err != nil {
+			return false, err
+		}
+		condition, ok := value.(bool)
+		if !ok {
+			return false, fmt.Errorf("directive @%s needs a boolean if argument", d.name)
+		}
+		if condition == (d.name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// arguments resolves the variables in the arguments of a field and checks
+// that the field accepts them
+func (e *executor) arguments(field *Field, sel selection) (map[string]interface{}, error) {
+	args := make(map[string]interface{}, len(sel.arguments))
+	for name, value := range sel.arguments {
+		accepted := false
+		for _, arg := range field.Args {
+			accepted = accepted || arg == name
+		}
+		if !accepted {
+			return nil, fmt.Errorf("unknown argument %s", name)
+		}
+		resolved, err := e.resolveValue(value)
+		if err != nil {
+			return nil, err
+		}
+		if resolved != nil {
+			args[name] = resolved
+		}
+	}
+	return args, nil
+}
+
+// resolveValue replaces the variables in a value by their values
+func (e *executor) resolveValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case variable:
+		resolved, ok := e.variables[string(v)]
+		if !ok {
+			return nil, nil
+		}
+		return resolved, nil
+	case enumValue:
+		return string(v), nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := e.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = resolved
+		}
+		return list, nil
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			resolved, err := e.resolveValue(item)
+			if err != nil {
+				return nil, err
+			}
+			object[key] = resolved
+		}
+		return object, nil
+	}
+	return value, nil
+}
+
+// encode returns the fields of a value as encoded to JSON
+func encode(source interface{}) (map[string]interface{}, error) {
+	if fields, ok := source.(map[string]interface{}); ok {
+		return fields, nil
+	}
+	data, err := json.Marshal(source)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("value is not an object: %v", err)
+	}
+	return fields, nil
+}
+
+// String returns a string argument, or def if it is not given
+func (p Params) String(name, def string) (string, error) {
+	value, ok := p.Args[name]
+	if !ok {
+		return def, nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %s must be a string", name)
+	}
+	return s, nil
+}
+
+// Int returns an integer argument, or def if it is not given
+func (p Params) Int(name string, def int) (int, error) {
+	value, ok := p.Args[name]
+	if !ok {
+		return def, nil
+	}
+	switch n := value.(type) {
+	case int:
+		return n, nil
+	case float64:
+		// Variables decoded from JSON are floats
+		if n == float64(int(n)) {
+			return int(n), nil
+		}
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return int(i), nil
+		}
+	}
+	return 0, fmt.Errorf("argument %s must be an integer", name)
+}
+
+// Time returns an RFC 3339 time argument, or def if it is not given
+func (p Params) Time(name string, def time.Time) (time.Time, error) {
+	s, err := p.String(name, "")
+	if err != nil || s == "" {
+		return def, err
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return def, fmt.Errorf("argument %s must be an RFC 3339 time: %v", name, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,176 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testItem struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	Note  string `json:"-"`
+}
+
+func testSchema() *Object {
+	item := &Object{Name: "Item", Fields: JSONFields(testItem{})}
+	item.Fields["double"] = &Field{Resolve: func(p Params) (interface{}, error) {
+		return p.Source.(testItem).Count * 2, nil
+	}}
+	item.Fields["broken"] = &Field{Resolve: func(p Params) (interface{}, error) {
+		return nil, errors.New("broken field")
+	}}
+	item.Fields["children"] = &Field{Type: item, Args: []string{"limit"}, Resolve: func(p Params) (interface{}, error) {
+		limit, err := p.Int("limit", 2)
+		if err != nil {
+			return nil, err
+		}
+		var children []testItem
+		for i := 0; i < limit; i++ {
+			children = append(children, testItem{Name: p.Source.(testItem).Name + "-child", Count: i})
+		}
+		return children, nil
+	}}
+
+	return &Object{Name: "Query", Fields: map[string]*Field{
+		"items": {Type: item, Args: []string{"prefix"}, Resolve: func(p Params) (interface{}, error) {
+			prefix, err := p.String("prefix", "")
+			if err != nil {
+				return nil, err
+			}
+			var items []testItem
+			for _, item := range []testItem{{Name: "alpha", Count: 1}, {Name: "beta", Count: 2}} {
+				if strings.HasPrefix(item.Name, prefix) {
+					items = append(items, item)
+				}
+			}
+			return items, nil
+		}},
+		"item": {Type: item, Args: []string{"name"}, Resolve: func(p Params) (interface{}, error) {
+			name, err := p.String("name", "")
+			if err != nil || name == "missing" {
+				return nil, err
+			}
+			return testItem{Name: name, Count: 3}, nil
+		}},
+	}}
+}
+
+func execute(t *testing.T, request Request) (string, []*Error) {
+	t.Helper()
+	response, err := Execute(context.Background(), testSchema(), request)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	data, err := json.Marshal(response.Data)
+	if err != nil {
+		t.Fatalf("marshal data: %v", err)
+	}
+	return string(data), response.Errors
+}
+
+func TestExecuteSelections(t *testing.T) {
+	tests := []struct {
+		name    string
+		request Request
+		want    string
+	}{
+		{
+			name:    "nested fields in selection order",
+			request: Request{Query: `{ items { count name children(limit: 1) { name } } }`},
+			want:    `{"items":[{"count":1,"name":"alpha","children":[{"name":"alpha-child"}]},{"count":2,"name":"beta","children":[{"name":"beta-child"}]}]}`,
+		},
+		{
+			name:    "aliases and arguments",
+			request: Request{Query: `query { a: item(name: "x") { name } b: item(name: "y") { twice: double } }`},
+			want:    `{"a":{"name":"x"},"b":{"twice":6}}`,
+		},
+		{
+			name: "variables and defaults",
+			request: Request{
+				Query:     `query Items($prefix: String, $limit: Int = 3) { items(prefix: $prefix) { children(limit: $limit) { count } } }`,
+				Variables: map[string]interface{}{"prefix": "b"},
+			},
+			want: `{"items":[{"children":[{"count":0},{"count":1},{"count":2}]}]}`,
+		},
+		{
+			name:    "fragments and typename",
+			request: Request{Query: `{ item(name: "x") { ...parts ... on Item { double } } } fragment parts on Item { __typename name }`},
+			want:    `{"item":{"__typename":"Item","name":"x","double":6}}`,
+		},
+		{
+			name: "directives",
+			request: Request{
+				Query:     `query($full: Boolean!) { item(name: "x") { name count @include(if: $full) double @skip(if: true) } }`,
+				Variables: map[string]interface{}{"full": false},
+			},
+			want: `{"item":{"name":"x"}}`,
+		},
+		{
+			name:    "repeated fields merged",
+			request: Request{Query: `{ item(name: "x") { name } item(name: "x") { count } }`},
+			want:    `{"item":{"name":"x","count":3}}`,
+		},
+		{
+			name:    "null objects and empty lists",
+			request: Request{Query: `{ item(name: "missing") { name } items(prefix: "z") { name } }`},
+			want:    `{"item":null,"items":[]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, errs := execute(t, tt.request)
+			if len(errs) != 0 {
+				t.Fatalf("errors = %v", errs)
+			}
+			if data != tt.want {
+				t.Errorf("data = %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteFieldErrors(t *testing.T) {
+	data, errs := execute(t, Request{Query: `{ items { name broken } }`})
+	if want := `{"items":[{"name":"alpha","broken":null},{"name":"beta","broken":null}]}`; data != want {
+		t.Errorf("data = %s, want %s", data, want)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("errors = %v, want one per item", errs)
+	}
+	path, _ := json.Marshal(errs[1].Path)
+	if errs[1].Message != "broken field" || string(path) != `["items",1,"broken"]` {
+		t.Errorf("error = %q at %s, want broken field at [\"items\",1,\"broken\"]", errs[1].Message, path)
+	}
+}
+
+func TestExecuteRequestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		request Request
+		want    string
+	}{
+		{"syntax", Request{Query: `{ items { name }`}, "syntax error"},
+		{"unknown field", Request{Query: `{ items { size } }`}, "field size is not defined on Item"},
+		{"unknown argument", Request{Query: `{ items(limit: 1) { name } }`}, "unknown argument limit"},
+		{"missing selection", Request{Query: `{ items }`}, "needs a selection"},
+		{"selection on scalar", Request{Query: `{ items { name { length } } }`}, "is a scalar"},
+		{"required variable", Request{Query: `query($p: String!) { items(prefix: $p) { name } }`}, "variable $p is required"},
+		{"mutation", Request{Query: `mutation { items { name } }`}, "mutation operations are not supported"},
+		{"ambiguous operation", Request{Query: `query A { items { name } } query B { items { name } }`}, "operationName is required"},
+		{"unknown fragment", Request{Query: `{ items { ...missing } }`}, "fragment missing is not defined"},
+		{"too deep", Request{Query: `{ items` + strings.Repeat(` { children`, MaxDepth) + ` { name }` + strings.Repeat(` }`, MaxDepth) + ` }`}, "nested more than"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Execute(context.Background(), testSchema(), tt.request)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,631 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed query document
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+// operation is a query, mutation or subscription of a document
+type operation struct {
+	kind       string
+	name       string
+	variables  []variableDefinition
+	selections []selection
+}
+
+type variableDefinition struct {
+	name         string
+	nonNull      bool
+	defaultValue interface{}
+	hasDefault   bool
+}
+
+// fragment is a named fragment of a document
+type fragment struct {
+	typeCondition string
+	selections    []selection
+}
+
+// selection is a field, a fragment spread (fragment set) or an inline
+// fragment (inline set) of a selection set
+type selection struct {
+	alias      string
+	name       string
+	arguments  map[string]interface{}
+	directives []directive
+	selections []selection
+
+	fragment      string
+	inline        bool
+	typeCondition string
+}
+
+// responseKey is the key of a field in the result
+func (s selection) responseKey() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+type directive struct {
+	name      string
+	arguments map[string]interface{}
+}
+
+// variable is a reference to a variable in a value
+type variable string
+
+// enumValue is an enum literal, which resolves to its name
+type enumValue string
+
+// token kinds
+const (
+	tokenEOF = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  int
+	value string
+	pos   int
+}
+
+// parser is a recursive descent parser of GraphQL query documents
+type parser struct {
+	source string
+	pos    int
+	token  token
+}
+
+// parse parses a query document
+func parse(source string) (*document, error) {
+	p := &parser{source: source}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: make(map[string]*fragment)}
+	for p.token.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunctuator, "{"):
+			selections, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: selections})
+		case p.peek(tokenName, "fragment"):
+			name, frag, err := p.fragmentDefinition()
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := doc.fragments[name]; exists {
+				return nil, fmt.Errorf("fragment %s is defined more than once", name)
+			}
+			doc.fragments[name] = frag
+		case p.peek(tokenName, "query"), p.peek(tokenName, "mutation"), p.peek(tokenName, "subscription"):
+			op, err := p.operationDefinition()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("the document has no operation")
+	}
+	return doc, nil
+}
+
+func (p *parser) operationDefinition() (*operation, error) {
+	op := &operation{kind: p.token.value}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.token.kind == tokenName {
+		op.name = p.token.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek(tokenPunctuator, "(") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		for !p.peek(tokenPunctuator, ")") {
+			definition, err := p.variableDefinition()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = append(op.variables, definition)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = selections
+	return op, nil
+}
+
+func (p *parser) variableDefinition() (variableDefinition, error) {
+	var definition variableDefinition
+	if err := p.expect(tokenPunctuator, "$"); err != nil {
+		return definition, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return definition, err
+	}
+	definition.name = name
+	if err := p.expect(tokenPunctuator, ":"); err != nil {
+		return definition, err
+	}
+	if definition.nonNull, err = p.typeReference(); err != nil {
+		return definition, err
+	}
+
+	if p.peek(tokenPunctuator, "=") {
+		if err := p.next(); err != nil {
+			return definition, err
+		}
+		if definition.defaultValue, err = p.value(true); err != nil {
+			return definition, err
+		}
+		definition.hasDefault = true
+	}
+	_, err = p.directives()
+	return definition, err
+}
+
+// typeReference skips a type such as [String!]! and returns whether it is
+// non-null
+func (p *parser) typeReference() (bool, error) {
+	if p.peek(tokenPunctuator, "[") {
+		if err := p.next(); err != nil {
+			return false, err
+		}
+		if _, err := p.typeReference(); err != nil {
+			return false, err
+		}
+		if err := p.expect(tokenPunctuator, "]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.name(); err != nil {
+		return false, err
+	}
+
+	if p.peek(tokenPunctuator, "!") {
+		return true, p.next()
+	}
+	return false, nil
+}
+
+func (p *parser) fragmentDefinition() (string, *fragment, error) {
+	if err := p.next(); err != nil {
+		return "", nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return "", nil, err
+	}
+	if name == "on" {
+		return "", nil, fmt.Errorf("a fragment cannot be named on")
+	}
+	if err := p.expect(tokenName, "on"); err != nil {
+		return "", nil, err
+	}
+	typeCondition, err := p.name()
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return "", nil, err
+	}
+	selections, err := p.selectionSet()
+	if err != nil {
+		return "", nil, err
+	}
+	return name, &fragment{typeCondition: typeCondition, selections: selections}, nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect(tokenPunctuator, "{"); err != nil {
+		return nil, err
+	}
+	var selections []selection
+	for !p.peek(tokenPunctuator, "}") {
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, sel)
+	}
+	if len(selections) == 0 {
+		return nil, fmt.Errorf("empty selection set at offset %d", p.token.pos)
+	}
+	return selections, p.next()
+}
+
+func (p *parser) selection() (selection, error) {
+	var sel selection
+	var err error
+
+	if p.peek(tokenPunctuator, "...") {
+		if err := p.next(); err != nil {
+			return sel, err
+		}
+		if p.token.kind == tokenName && p.token.value != "on" {
+			sel.fragment = p.token.value
+			if err := p.next(); err != nil {
+				return sel, err
+			}
+			sel.directives, err = p.directives()
+			return sel, err
+		}
+
+		sel.inline = true
+		if p.peek(tokenName, "on") {
+			if err := p.next(); err != nil {
+				return sel, err
+			}
+			if sel.typeCondition, err = p.name(); err != nil {
+				return sel, err
+			}
+		}
+		if sel.directives, err = p.directives(); err != nil {
+			return sel, err
+		}
+		sel.selections, err = p.selectionSet()
+		return sel, err
+	}
+
+	if sel.name, err = p.name(); err != nil {
+		return sel, err
+	}
+	if p.peek(tokenPunctuator, ":") {
+		if err := p.next(); err != nil {
+			return sel, err
+		}
+		sel.alias = sel.name
+		if sel.name, err = p.name(); err != nil {
+			return sel, err
+		}
+	}
+	if sel.arguments, err = p.arguments(); err != nil {
+		return sel, err
+	}
+	if sel.directives, err = p.directives(); err != nil {
+		return sel, err
+	}
+	if p.peek(tokenPunctuator, "{") {
+		sel.selections, err = p.selectionSet()
+	}
+	return sel, err
+}
+
+func (p *parser) arguments() (map[string]interface{}, error) {
+	if !p.peek(tokenPunctuator, "(") {
+		return nil, nil
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	arguments := make(map[string]interface{})
+	for !p.peek(tokenPunctuator, ")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenPunctuator, ":"); err != nil {
+			return nil, err
+		}
+		value, err := p.value(false)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := arguments[name]; exists {
+			return nil, fmt.Errorf("argument %s is given more than once", name)
+		}
+		arguments[name] = value
+	}
+	return arguments, p.next()
+}
+
+func (p *parser) directives() ([]directive, error) {
+	var directives []directive
+	for p.peek(tokenPunctuator, "@") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		arguments, err := p.arguments()
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, directive{name: name, arguments: arguments})
+	}
+	return directives, nil
+}
+
+// value parses a value literal; constant values cannot hold variables
+func (p *parser) value(constant bool) (interface{}, error) {
+	tok := p.token
+	switch {
+	case tok.kind == tokenPunctuator && tok.value == "$" && !constant:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return variable(name), err
+	case tok.kind == tokenPunctuator && tok.value == "[":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.peek(tokenPunctuator, "]") {
+			item, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, p.next()
+	case tok.kind == tokenPunctuator && tok.value == "{":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		object := make(map[string]interface{})
+		for !p.peek(tokenPunctuator, "}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(tokenPunctuator, ":"); err != nil {
+				return nil, err
+			}
+			if object[name], err = p.value(constant); err != nil {
+				return nil, err
+			}
+		}
+		return object, p.next()
+	case tok.kind == tokenInt:
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %s", tok.value)
+		}
+		return int(n), p.next()
+	case tok.kind == tokenFloat:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %s", tok.value)
+		}
+		return f, p.next()
+	case tok.kind == tokenString:
+		return tok.value, p.next()
+	case tok.kind == tokenName:
+		var value interface{}
+		switch tok.value {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			value = enumValue(tok.value)
+		}
+		return value, p.next()
+	}
+	return nil, p.unexpected()
+}
+
+func (p *parser) name() (string, error) {
+	if p.token.kind != tokenName {
+		return "", p.unexpected()
+	}
+	name := p.token.value
+	return name, p.next()
+}
+
+// peek reports whether the current token is of a kind and value
+func (p *parser) peek(kind int, value string) bool {
+	return p.token.kind == kind && p.token.value == value
+}
+
+func (p *parser) expect(kind int, value string) error {
+	if !p.peek(kind, value) {
+		return p.unexpected()
+	}
+	return p.next()
+}
+
+func (p *parser) unexpected() error {
+	if p.token.kind == tokenEOF {
+		return fmt.Errorf("unexpected end of the document")
+	}
+	return fmt.Errorf("unexpected %q at offset %d", p.token.value, p.token.pos)
+}
+
+// next reads the next token, skipping whitespace, commas and comments
+func (p *parser) next() error {
+	for p.pos < len(p.source) {
+		c := p.source[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+		} else if strings.HasPrefix(p.source[p.pos:], "\uFEFF") {
+			p.pos += len("\uFEFF")
+		} else if c == '#' {
+			for p.pos < len(p.source) && p.source[p.pos] != '\n' && p.source[p.pos] != '\r' {
+				p.pos++
+			}
+		} else {
+			break
+		}
+	}
+
+	start := p.pos
+	if p.pos >= len(p.source) {
+		p.token = token{kind: tokenEOF, pos: start}
+		return nil
+	}
+
+	c := p.source[p.pos]
+	switch {
+	case strings.HasPrefix(p.source[p.pos:], "..."):
+		p.pos += 3
+		p.token = token{kind: tokenPunctuator, value: "...", pos: start}
+	case strings.ContainsRune("!$&()/:=@[]{|}", rune(c)):
+		p.pos++
+		p.token = token{kind: tokenPunctuator, value: string(c), pos: start}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.source) && (p.source[p.pos] == '_' || isLetter(p.source[p.pos]) || isDigit(p.source[p.pos])) {
+			p.pos++
+		}
+		p.token = token{kind: tokenName, value: p.source[start:p.pos], pos: start}
+	case c == '-' || isDigit(c):
+		return p.number()
+	case c == '"':
+		return p.string()
+	default:
+		r, _ := utf8.DecodeRuneInString(p.source[p.pos:])
+		return fmt.Errorf("unexpected character %q at offset %d", r, start)
+	}
+	return nil
+}
+
+func (p *parser) number() error {
+	start := p.pos
+	kind := tokenInt
+	if p.source[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() int {
+		from := p.pos
+		for p.pos < len(p.source) && isDigit(p.source[p.pos]) {
+			p.pos++
+		}
+		return p.pos - from
+	}
+	if digits() == 0 {
+		return fmt.Errorf("invalid number at offset %d", start)
+	}
+	if p.pos < len(p.source) && p.source[p.pos] == '.' {
+		p.pos++
+		kind = tokenFloat
+		if digits() == 0 {
+			return fmt.Errorf("invalid number at offset %d", start)
+		}
+	}
+	if p.pos < len(p.source) && (p.source[p.pos] == 'e' || p.source[p.pos] == 'E') {
+		p.pos++
+		kind = tokenFloat
+		if p.pos < len(p.source) && (p.source[p.pos] == '+' || p.source[p.pos] == '-') {
+			p.pos++
+		}
+		if digits() == 0 {
+			return fmt.Errorf("invalid number at offset %d", start)
+		}
+	}
+	p.token = token{kind: kind, value: p.source[start:p.pos], pos: start}
+	return nil
+}
+
+func (p *parser) string() error {
+	start := p.pos
+	if strings.HasPrefix(p.source[p.pos:], `"""`) {
+		end := strings.Index(p.source[p.pos+3:], `"""`)
+		if end < 0 {
+			return fmt.Errorf("unterminated block string at offset %d", start)
+		}
+		value := p.source[p.pos+3 : p.pos+3+end]
+		p.pos += 3 + end + 3
+		p.token = token{kind: tokenString, value: strings.TrimSpace(value), pos: start}
+		return nil
+	}
+
+	var value strings.Builder
+	p.pos++
+	for {
+		if p.pos >= len(p.source) || p.source[p.pos] == '\n' || p.source[p.pos] == '\r' {
+			return fmt.Errorf("unterminated string at offset %d", start)
+		}
+		c := p.source[p.pos]
+		if c == '"' {
+			p.pos++
+			break
+		}
+		if c != '\\' {
+			value.WriteByte(c)
+			p.pos++
+			continue
+		}
+
+		if p.pos+1 >= len(p.source) {
+			return fmt.Errorf("unterminated string at offset %d", start)
+		}
+		escaped := p.source[p.pos+1]
+		p.pos += 2
+		switch escaped {
+		case '"', '\\', '/':
+			value.WriteByte(escaped)
+		case 'b':
+			value.WriteByte('\b')
+		case 'f':
+			value.WriteByte('\f')
+		case 'n':
+			value.WriteByte('\n')
+		case 'r':
+			value.WriteByte('\r')
+		case 't':
+			value.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(p.source) {
+				return fmt.Errorf("invalid unicode escape at offset %d", p.pos-2)
+			}
+			code, err := strconv.ParseUint(p.source[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				return fmt.Errorf("invalid unicode escape at offset %d", p.pos-2)
+			}
+			value.WriteRune(rune(code))
+			p.pos += 4
+		default:
+			return fmt.Errorf("invalid escape \\%c at offset %d", escaped, p.pos-2)
+		}
+	}
+	p.token = token{kind: tokenString, value: value.String(), pos: start}
+	return nil
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// SetArtifacts makes the manager collect the artifacts of jobs after each
+// run, keeping their files as configured and recording them in repo
+func (m *Manager) SetArtifacts(cfg config.ArtifactsConfig, repo storage.ArtifactRepo) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.artifactRepo = repo
+	m.artifactFiles = storage.NewArtifactFiles(cfg)
+}
+
+// collectArtifacts keeps the files matching the artifact patterns of a job
+// that were written since the execution started. Files are named after
+// their base name; of several with the same name the first is kept.
+func (m *Manager) collectArtifacts(ctx context.Context, jobConfig config.JobConfig, execution *JobExecution) {
+	m.mutex.RLock()
+	repo, files := m.artifactRepo, m.artifactFiles
+	m.mutex.RUnlock()
+	if repo == nil || len(jobConfig.Artifacts) == 0 {
+		return
+	}
+
+	// Modification times may be truncated to the second
+	since := execution.StartTime.Truncate(time.Second)
+	seen := make(map[string]bool)
+	for _, pattern := range jobConfig.Artifacts {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			executionLog(execution).Warnf("Invalid artifact pattern %q of job %s: %v", pattern, jobConfig.Name, err)
+			continue
+		}
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() || info.ModTime().Before(since) {
+				continue
+			}
+			name := filepath.Base(path)
+			if seen[name] {
+				executionLog(execution).Warnf("Skipping artifact %s of job %s: an artifact named %s was already collected",
+					path, jobConfig.Name, name)
+				continue
+			}
+			seen[name] = true
+			if info.Size() > files.MaxSize() {
+				executionLog(execution).Warnf("Skipping artifact %s of job %s: %d bytes exceed the maximum of %d",
+					path, jobConfig.Name, info.Size(), files.MaxSize())
+				continue
+			}
+
+			location, err := files.Save(ctx, execution.ID, name, path)
+			if err != nil {
+				executionLog(execution).Errorf("Failed to collect artifact %s of job %s: %v", path, jobConfig.Name, err)
+				continue
+			}
+			artifact := &types.Artifact{
+				ExecutionID: execution.ID,
+				Name:        name,
+				Size:        info.Size(),
+				Location:    location,
+			}
+			if err := repo.StoreArtifact(artifact); err != nil {
+				executionLog(execution).Errorf("Failed to store artifact %s of job %s: %v", name, jobConfig.Name, err)
+			}
+		}
+	}
+}
+
+// Artifacts returns the artifacts collected from an execution
+func (m *Manager) Artifacts(executionID string) ([]*types.Artifact, error) {
+	m.mutex.RLock()
+	repo := m.artifactRepo
+	m.mutex.RUnlock()
+	if repo == nil {
+		return nil, nil
+	}
+	return repo.GetArtifacts(executionID)
+}
+
+// OpenArtifact opens the file of an artifact of an execution for reading.
+// It returns nil if the execution has no artifact of that name.
+func (m *Manager) OpenArtifact(ctx context.Context, executionID, name string) (*types.Artifact, io.ReadCloser, error) {
+	artifacts, err := m.Artifacts(executionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, artifact := range artifacts {
+		if artifact.Name != name {
+			continue
+		}
+		m.mutex.RLock()
+		files := m.artifactFiles
+		m.mutex.RUnlock()
+		file, err := files.Open(ctx, artifact)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open artifact: %v", err)
+		}
+		return artifact, file, nil
+	}
+	return nil, nil, nil
+}
@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrDraining is returned for runs requested while the manager drains
+var ErrDraining = errors.New("job manager is draining, no new runs are started")
+
+// beginRun registers a run, retries included, unless the manager drains.
+// The returned function ends it.
+func (m *Manager) beginRun() (func(), error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.draining {
+		return nil, ErrDraining
+	}
+	m.runs.Add(1)
+	return m.runs.Done, nil
+}
+
+// Drain stops the manager from starting runs and waits until the running
+// ones, including their retries, are finished or the context is done, e.g.
+// on shutdown so a pod is not killed mid-run. Retries waiting out their
+// backoff are cancelled rather than waited for. Runs still going when the
+// context is done are left to Stop.
+func (m *Manager) Drain(ctx context.Context) error {
+	m.mutex.Lock()
+	if !m.draining {
+		m.draining = true
+		close(m.drainStarted)
+	}
+	m.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.runs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Draining reports whether the manager stopped starting runs
+func (m *Manager) Draining() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.draining
+}
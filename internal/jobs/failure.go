@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// commandFailure classifies the result of a command that ran: the context
+// of the command, the context of the manager and the error of Wait. It
+// returns an empty reason and a nil error if the command succeeded.
+func commandFailure(ctx, managerCtx context.Context, timeout time.Duration, state *os.ProcessState, waitErr error) (types.FailureReason, error) {
+	switch {
+	case waitErr == nil:
+		return "", nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return types.FailureTimeout, fmt.Errorf("timed out after %s", timeout)
+	case managerCtx.Err() != nil:
+		return types.FailureCancelled, fmt.Errorf("cancelled: job manager stopped")
+	case state == nil:
+		// Wait failed before the process was reaped, e.g. copying output
+		return types.FailureStart, waitErr
+	}
+
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return types.FailureSignal, fmt.Errorf("killed by signal: %v", status.Signal())
+	}
+	return types.FailureExitCode, waitErr
+}
+
+// exitCode returns the exit code of a process, -1 if it did not exit
+// normally or never started
+func exitCode(state *os.ProcessState) int {
+	if state == nil {
+		return -1
+	}
+	return state.ExitCode()
+}
@@ -2,16 +2,21 @@ package jobs
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/logging"
 	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/tracing"
 	"github.com/makalin/arcron/internal/types"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Use types from the types package
@@ -27,24 +32,44 @@ type Job struct {
 // Use types from the types package
 type JobExecution = types.JobExecution
 
+// ExecutionListener is notified when a job execution finishes
+type ExecutionListener func(execution *JobExecution)
+
 // Manager manages job execution and tracking
 type Manager struct {
-	jobs   map[string]*Job
-	store  *storage.Storage
-	mutex  sync.RWMutex
-	ctx    context.Context
-	cancel context.CancelFunc
+	jobs           map[string]*Job
+	store          storage.JobExecutionRepo
+	policy         *Policy
+	listeners      []ExecutionListener
+	slots          map[string]chan struct{}
+	rates          *rateLimits // nil without rate limits
+	metrics        MetricsSource
+	draining       bool                     // no new runs are started
+	drainStarted   chan struct{}            // closed once draining starts
+	defaultTimeout time.Duration            // for jobs without a timeout
+	maxTimeout     time.Duration            // cap on job timeouts, zero for none
+	runs           sync.WaitGroup           // runs in progress, for draining
+	outputs        map[string]*outputBuffer // output of running executions
+	artifactRepo   storage.ArtifactRepo     // nil unless artifacts are collected
+	artifactFiles  *storage.ArtifactFiles
+	retryQueue     RetryQueue // nil to wait out retry backoffs in process
+	mutex          sync.RWMutex
+	ctx            context.Context
+	cancel         context.CancelFunc
 }
 
-// New creates a new Job Manager
-func New(jobConfigs []config.JobConfig, store *storage.Storage) (*Manager, error) {
+// New creates a new Job Manager recording executions in store, a
+// storage.Storage or, e.g. in tests, a storage.MemoryStore
+func New(jobConfigs []config.JobConfig, security config.SecurityConfig, store storage.JobExecutionRepo) (*Manager, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	manager := &Manager{
-		jobs:   make(map[string]*Job),
-		store:  store,
-		ctx:    ctx,
-		cancel: cancel,
+		jobs:         make(map[string]*Job),
+		store:        store,
+		policy:       NewPolicy(security),
+		drainStarted: make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
 	}
 
 	// Initialize jobs from config
@@ -54,6 +79,10 @@ func New(jobConfigs []config.JobConfig, store *storage.Storage) (*Manager, error
 			logrus.Errorf("Failed to create job %s: %v", jobConfig.Name, err)
 			continue
 		}
+		if err := manager.policy.CheckCommand(jobConfig.Command); err != nil {
+			logrus.Errorf("Rejected job %s: %v", jobConfig.Name, err)
+			continue
+		}
 		manager.jobs[jobConfig.Name] = job
 	}
 
@@ -76,111 +105,345 @@ func NewJob(jobConfig config.JobConfig) (*Job, error) {
 	}, nil
 }
 
-// ExecuteJob executes a job
-func (m *Manager) ExecuteJob(job *Job) error {
-	execution := &JobExecution{
-		ID:        generateExecutionID(),
-		JobName:   job.config.Name,
-		StartTime: time.Now(),
-		Status:    types.StatusRunning,
+// retryBackoff is the wait before the first retry of a failed job; each
+// further retry waits that much longer
+var retryBackoff = 30 * time.Second
+
+// ExecuteJob executes a job, retrying it as configured. The context
+// carries the trace of whatever triggered the execution.
+func (m *Manager) ExecuteJob(ctx context.Context, job *Job) error {
+	end, err := m.beginRun()
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	ctx = withCorrelation(ctx)
+	return m.executeWithRetries(ctx, job, newExecution(ctx, job))
+}
+
+// StartJob executes a job in the background and returns its execution
+// right away. The execution is stored before StartJob returns, so its
+// progress can be followed by ID.
+func (m *Manager) StartJob(ctx context.Context, job *Job) (*JobExecution, error) {
+	end, err := m.beginRun()
+	if err != nil {
+		return nil, err
 	}
 
+	ctx = withCorrelation(ctx)
+	execution := newExecution(ctx, job)
+	execution.Status = types.StatusPending
+	if err := m.storeExecution(ctx, execution); err != nil {
+		end()
+		return nil, err
+	}
+	started := *execution
+
+	go func() {
+		defer end()
+		if err := m.executeWithRetries(ctx, job, execution); err != nil {
+			executionLog(execution).Errorf("Failed to execute job %s: %v", job.GetName(), err)
+		}
+	}()
+
+	return &started, nil
+}
+
+// newExecution creates the execution record of the first attempt of a
+// run starting now, queued when the context says the run was due and
+// logged with the correlation ID of the context
+func newExecution(ctx context.Context, job *Job) *JobExecution {
+	return &JobExecution{
+		ID:            generateExecutionID(),
+		JobName:       job.GetName(),
+		Namespace:     job.GetNamespace(),
+		CorrelationID: logging.CorrelationID(ctx),
+		StartTime:     time.Now(),
+		QueuedAt:      queuedAt(ctx),
+		Status:        types.StatusRunning,
+		Attempt:       1,
+	}
+}
+
+// executeWithRetries runs the first attempt of a job and, while attempts
+// fail, up to the configured number of retries. Every attempt is an
+// execution of its own, linked to the first attempt by its parent
+// execution ID. It returns the error of the last attempt.
+func (m *Manager) executeWithRetries(ctx context.Context, job *Job, first *JobExecution) error {
+	// The schedule may change while the job runs, so every attempt of
+	// the run uses the configuration it started with
+	jobConfig := job.GetConfig()
+	execution := first
+	// A resumed retry is linked to the first attempt of its run
+	parentID := first.ParentExecutionID
+	if parentID == "" {
+		parentID = first.ID
+	}
+	for {
+		err := m.runExecution(ctx, job, jobConfig, execution)
+		if err == nil {
+			return nil
+		}
+		if execution.Attempt > jobConfig.Retries {
+			if jobConfig.Retries > 0 {
+				executionLog(execution).Warnf("Job %s failed permanently after %d attempts", jobConfig.Name, execution.Attempt)
+			}
+			return err
+		}
+
+		retry := newExecution(ctx, job)
+		retry.Attempt = execution.Attempt + 1
+		retry.RetryCount = execution.Attempt
+		retry.ParentExecutionID = parentID
+		retry.Status = types.StatusPending
+		backoff := time.Duration(retry.RetryCount) * retryBackoff
+		// Queued once its backoff is over
+		retry.QueuedAt = time.Now().Add(backoff)
+		job.setStatus(types.StatusRetrying)
+
+		// Stored before the backoff, so the run shows as being retried
+		// rather than as failed meanwhile
+		if err := m.storeExecution(ctx, retry); err != nil {
+			executionLog(retry).Errorf("Failed to store retry execution: %v", err)
+		}
+
+		// The queue starts the retry once its backoff is over, also after
+		// a restart
+		if queue := m.getRetryQueue(); queue != nil {
+			queueErr := queue(ctx, retry)
+			if queueErr == nil {
+				executionLog(retry).Infof("Queued retry of job %s in %s (attempt %d/%d)", jobConfig.Name, backoff, retry.Attempt, jobConfig.Retries+1)
+				return err
+			}
+			executionLog(retry).Errorf("Failed to queue retry, waiting for it instead: %v", queueErr)
+		}
+
+		executionLog(retry).Infof("Retrying job %s in %s (attempt %d/%d)", jobConfig.Name, backoff, retry.Attempt, jobConfig.Retries+1)
+		select {
+		case <-time.After(backoff):
+		case <-m.drainStarted:
+			m.cancelRetry(ctx, retry, "job manager draining before the retry")
+			return err
+		case <-m.ctx.Done():
+			m.cancelRetry(ctx, retry, "job manager stopped before the retry")
+			return err
+		}
+		execution = retry
+	}
+}
+
+// runExecution runs a single attempt of a job with the given
+// configuration and records its progress in the execution
+func (m *Manager) runExecution(ctx context.Context, job *Job, jobConfig config.JobConfig, execution *JobExecution) (err error) {
+	// Wait for the namespace to have room for one more running job and
+	// for the rate limits to allow one more start
+	release, err := m.acquireStart(ctx, jobConfig)
+	if err != nil {
+		execution.EndTime = time.Now()
+		execution.QueueWait = max(execution.EndTime.Sub(execution.QueuedAt).Seconds(), 0)
+		execution.Status = types.StatusFailed
+		execution.Error = err.Error()
+		execution.FailureReason = types.FailureCancelled
+		job.setStatus(types.StatusFailed)
+		if err := m.storeExecution(ctx, execution); err != nil {
+			executionLog(execution).Errorf("Failed to store job execution result: %v", err)
+		}
+		m.notifyListeners(execution)
+		return err
+	}
+	defer release()
+
+	startAttempt(execution)
+	execution.StartMetrics = m.snapshotMetrics()
+	execution.Status = types.StatusRunning
+
+	ctx, span := tracing.Start(ctx, "job.execute",
+		attribute.String("job.name", jobConfig.Name),
+		attribute.String("job.type", jobConfig.Type),
+		attribute.String("execution.id", execution.ID),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	// Update job status
 	job.setStatus(types.StatusRunning)
 
 	// Store execution start
-	if err := m.store.StoreJobExecution(execution); err != nil {
-		logrus.Errorf("Failed to store job execution start: %v", err)
+	if err := m.storeExecution(ctx, execution); err != nil {
+		executionLog(execution).Errorf("Failed to store job execution start: %v", err)
 	}
 
-	// Execute the command
-	output, exitCode, err := m.executeCommand(job.config)
+	// Execute the command, its output readable through Output meanwhile
+	buffer, untrack := m.trackOutput(execution.ID)
+	defer untrack()
+	output, exitCode, reason, err := m.executeCommand(ctx, jobConfig, buffer)
 
 	// Update execution details
 	execution.EndTime = time.Now()
+	execution.EndMetrics = m.snapshotMetrics()
 	execution.Duration = execution.EndTime.Sub(execution.StartTime).Seconds()
 	execution.Output = output
 	execution.ExitCode = exitCode
+	if reason != types.FailureStart && reason != types.FailurePolicy {
+		var problems map[string]error
+		execution.OutputMetrics, problems = extractOutputMetrics(jobConfig, output)
+		for name, problem := range problems {
+			executionLog(execution).Warnf("Output metric %s of job %s: %v", name, jobConfig.Name, problem)
+		}
+		m.collectArtifacts(ctx, jobConfig, execution)
+	}
 
 	if err != nil {
 		execution.Status = types.StatusFailed
 		execution.Error = err.Error()
+		execution.FailureReason = reason
 		job.setStatus(types.StatusFailed)
-		logrus.Errorf("Job %s failed: %v", job.config.Name, err)
+		executionLog(execution).Errorf("Job %s failed (%s): %v", jobConfig.Name, reason, err)
 	} else {
 		execution.Status = types.StatusCompleted
 		job.setStatus(types.StatusCompleted)
-		logrus.Infof("Job %s completed successfully in %.2f seconds", job.config.Name, execution.Duration)
+		executionLog(execution).Infof("Job %s completed successfully in %.2f seconds", jobConfig.Name, execution.Duration)
 	}
 
+	span.SetAttributes(
+		attribute.String("job.status", string(execution.Status)),
+		attribute.Int("job.exit_code", exitCode),
+	)
+
 	// Store execution result
-	if err := m.store.StoreJobExecution(execution); err != nil {
-		logrus.Errorf("Failed to store job execution result: %v", err)
+	if err := m.storeExecution(ctx, execution); err != nil {
+		executionLog(execution).Errorf("Failed to store job execution result: %v", err)
 	}
 
-	// Handle retries if needed
-	if execution.Status == types.StatusFailed && job.config.Retries > 0 {
-		m.handleRetry(job, execution)
-	}
+	m.notifyListeners(execution)
 
 	return err
 }
 
-// executeCommand executes the job command
-func (m *Manager) executeCommand(jobConfig config.JobConfig) (string, int, error) {
-	ctx, cancel := context.WithTimeout(m.ctx, jobConfig.Timeout)
+// storeExecution stores an execution record within a tracing span
+func (m *Manager) storeExecution(ctx context.Context, execution *JobExecution) (err error) {
+	_, span := tracing.Start(ctx, "storage.store_execution",
+		attribute.String("execution.id", execution.ID),
+		attribute.String("job.status", string(execution.Status)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return m.store.StoreJobExecution(execution)
+}
+
+// executeCommand executes the job command, writing its output to combined
+// as well. A failure comes with its reason: the command did not start,
+// exited with an error, timed out or was killed.
+func (m *Manager) executeCommand(traceCtx context.Context, jobConfig config.JobConfig, combined *outputBuffer) (output string, code int, reason types.FailureReason, err error) {
+	traceCtx, span := tracing.Start(traceCtx, "job.command",
+		attribute.String("job.name", jobConfig.Name),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	if err := m.policy.CheckCommand(jobConfig.Command); err != nil {
+		return "", -1, types.FailurePolicy, err
+	}
+
+	timeout := m.timeout(traceCtx, jobConfig)
+	ctx, cancel := context.WithTimeout(m.ctx, timeout)
 	defer cancel()
 
 	// Parse command and arguments
 	parts := strings.Fields(jobConfig.Command)
 	if len(parts) == 0 {
-		return "", -1, fmt.Errorf("empty command")
+		return "", -1, types.FailureStart, fmt.Errorf("empty command")
 	}
+	span.SetAttributes(attribute.String("process.executable.name", parts[0]))
 
 	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
 
-	// Set environment variables
-	if len(jobConfig.Environment) > 0 {
-		env := make([]string, 0, len(jobConfig.Environment))
-		for k, v := range jobConfig.Environment {
-			env = append(env, fmt.Sprintf("%s=%s", k, v))
-		}
-		cmd.Env = env
+	// Set environment variables permitted by the security policy
+	environment, dropped := m.policy.FilterEnvironment(jobConfig.Environment)
+	if len(dropped) > 0 {
+		logging.FromContext(traceCtx).WithField(logging.JobField, jobConfig.Name).Warnf(
+			"Security policy dropped environment variables for job %s: %s",
+			jobConfig.Name, strings.Join(dropped, ", "))
+	}
+	if len(jobConfig.Environment) == 0 {
+		// Jobs without an environment of their own inherit arcron's, as
+		// permitted by the same policy. Jobs whose variables were all
+		// dropped do not, so they never silently run with arcron's.
+		environment, _ = m.policy.FilterEnvironment(environMap(os.Environ()))
+	}
+	// Propagate the trace context so the command can continue the trace
+	for k, v := range tracing.EnvironmentFor(traceCtx) {
+		environment[k] = v
+	}
+	cmd.Env = make([]string, 0, len(environment))
+	for k, v := range environment {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
 	}
 
-	// Execute command
-	output, err := cmd.CombinedOutput()
-	exitCode := cmd.ProcessState.ExitCode()
+	// Execute command. ProcessState stays nil if it never started.
+	cmd.Stdout = combined
+	cmd.Stderr = combined
+	if err := cmd.Start(); err != nil {
+		return "", -1, types.FailureStart, fmt.Errorf("failed to start command: %v", err)
+	}
+	waitErr := cmd.Wait()
+	reason, err = commandFailure(ctx, m.ctx, timeout, cmd.ProcessState, waitErr)
 
-	return string(output), exitCode, err
+	return combined.String(), exitCode(cmd.ProcessState), reason, err
 }
 
-// handleRetry handles job retries
-func (m *Manager) handleRetry(job *Job, execution *JobExecution) {
-	if execution.RetryCount >= job.config.Retries {
-		logrus.Warnf("Job %s exceeded maximum retries (%d)", job.config.Name, job.config.Retries)
-		return
-	}
+// AddListener registers a listener notified after every finished execution
+func (m *Manager) AddListener(listener ExecutionListener) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
 
-	execution.RetryCount++
-	execution.Status = types.StatusRetrying
-	job.setStatus(types.StatusRetrying)
+// notifyListeners passes a finished execution to all listeners
+func (m *Manager) notifyListeners(execution *JobExecution) {
+	m.mutex.RLock()
+	listeners := make([]ExecutionListener, len(m.listeners))
+	copy(listeners, m.listeners)
+	m.mutex.RUnlock()
 
-	// Store retry attempt
-	if err := m.store.StoreJobExecution(execution); err != nil {
-		logrus.Errorf("Failed to store retry execution: %v", err)
+	for _, listener := range listeners {
+		listener(execution)
 	}
+}
 
-	logrus.Infof("Retrying job %s (attempt %d/%d)", job.config.Name, execution.RetryCount, job.config.Retries)
+// AddJob adds a job, or replaces the job of the same name, checking its
+// command against the security policy
+func (m *Manager) AddJob(jobConfig config.JobConfig) (*Job, error) {
+	if err := m.CheckJob(jobConfig); err != nil {
+		return nil, err
+	}
+	job, err := NewJob(jobConfig)
+	if err != nil {
+		return nil, err
+	}
 
-	// Wait before retry (exponential backoff)
-	backoff := time.Duration(execution.RetryCount) * 30 * time.Second
-	time.Sleep(backoff)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.jobs[jobConfig.Name] = job
+	return job, nil
+}
 
-	// Execute retry
-	if err := m.ExecuteJob(job); err != nil {
-		logrus.Errorf("Retry attempt %d for job %s failed: %v", execution.RetryCount, job.config.Name, err)
+// CheckJob checks that AddJob would accept a job: it has a name and a
+// command allowed by the security policy
+func (m *Manager) CheckJob(jobConfig config.JobConfig) error {
+	if _, err := NewJob(jobConfig); err != nil {
+		return err
+	}
+	if err := m.policy.CheckCommand(jobConfig.Command); err != nil {
+		return fmt.Errorf("rejected job %s: %v", jobConfig.Name, err)
 	}
+	return nil
+}
+
+// RemoveJob removes a job. Runs in progress finish normally.
+func (m *Manager) RemoveJob(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.jobs, name)
 }
 
 // GetJob returns a job by name
@@ -204,6 +467,11 @@ func (m *Manager) GetAllJobs() map[string]*Job {
 	return result
 }
 
+// GetExecution returns an execution by ID, or nil if there is none
+func (m *Manager) GetExecution(id string) (*JobExecution, error) {
+	return m.store.GetJobExecution(id)
+}
+
 // GetJobExecutions returns executions for a specific job
 func (m *Manager) GetJobExecutions(jobName string, limit int) ([]*JobExecution, error) {
 	return m.store.GetJobExecutions(jobName, limit)
@@ -230,6 +498,8 @@ func (j *Job) GetStatus() JobStatus {
 
 // GetConfig returns the job configuration
 func (j *Job) GetConfig() config.JobConfig {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
 	return j.config
 }
 
@@ -243,12 +513,36 @@ func (j *Job) GetType() string {
 	return j.config.Type
 }
 
+// GetNamespace returns the namespace of the job
+func (j *Job) GetNamespace() string {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	return j.config.GetNamespace()
+}
+
 // GetSchedule returns the job schedule
 func (j *Job) GetSchedule() string {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
 	return j.config.Schedule
 }
 
-// generateExecutionID generates a unique execution ID
+// SetSchedule changes the job schedule
+func (j *Job) SetSchedule(schedule string) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.config.Schedule = schedule
+}
+
+// generateExecutionID generates a unique execution ID, a random (version
+// 4) UUID
 func generateExecutionID() string {
-	return fmt.Sprintf("exec_%d", time.Now().UnixNano())
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		// The system's random source failing is unrecoverable
+		panic(fmt.Sprintf("failed to generate execution ID: %v", err))
+	}
+	id[6] = id[6]&0x0f | 0x40 // Version 4
+	id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
 }
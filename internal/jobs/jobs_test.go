@@ -0,0 +1,415 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func newTestManager(t *testing.T, jobConfigs ...config.JobConfig) *Manager {
+	t.Helper()
+
+	store := storage.NewMemoryStore()
+	manager, err := New(jobConfigs, config.SecurityConfig{}, store)
+	if err != nil {
+		t.Fatalf("Failed to create job manager: %v", err)
+	}
+	t.Cleanup(manager.Stop)
+	return manager
+}
+
+func TestStartJobTracksExecution(t *testing.T) {
+	manager := newTestManager(t, config.JobConfig{Name: "greet", Command: "echo hello", Timeout: time.Minute})
+	job, _ := manager.GetJob("greet")
+
+	started, err := manager.StartJob(context.Background(), job)
+	if err != nil {
+		t.Fatalf("StartJob() error = %v", err)
+	}
+	if started.ID == "" || started.Status != types.StatusPending {
+		t.Fatalf("StartJob() = %+v, want a pending execution with an ID", started)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		execution, err := manager.GetExecution(started.ID)
+		if err != nil {
+			t.Fatalf("GetExecution() error = %v", err)
+		}
+		if execution != nil && execution.Status == types.StatusCompleted {
+			if strings.TrimSpace(execution.Output) != "hello" {
+				t.Errorf("output = %q, want hello", execution.Output)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("execution not completed in time: %+v", execution)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if missing, err := manager.GetExecution("unknown"); err != nil || missing != nil {
+		t.Errorf("GetExecution(unknown) = %+v, %v, want nil", missing, err)
+	}
+}
+
+func TestGenerateExecutionIDUnique(t *testing.T) {
+	const count = 1000
+	ids := make(chan string, count)
+	for i := 0; i < count; i++ {
+		go func() { ids <- generateExecutionID() }()
+	}
+
+	seen := make(map[string]bool, count)
+	for i := 0; i < count; i++ {
+		id := <-ids
+		if len(id) != 36 || id[14] != '4' {
+			t.Fatalf("generateExecutionID() = %q, want a version 4 UUID", id)
+		}
+		if seen[id] {
+			t.Fatalf("generateExecutionID() returned %q twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestRetriesAreChildExecutions(t *testing.T) {
+	defer func(backoff time.Duration) { retryBackoff = backoff }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	// The marker appears after the first attempt, so the flaky job recovers
+	marker := filepath.Join(t.TempDir(), "ready")
+	manager := newTestManager(t,
+		config.JobConfig{Name: "broken", Command: "false", Timeout: time.Minute, Retries: 2},
+		config.JobConfig{Name: "flaky", Command: "test -e " + marker, Timeout: time.Minute, Retries: 2},
+	)
+	manager.AddListener(func(execution *JobExecution) {
+		if execution.JobName == "flaky" {
+			os.WriteFile(marker, nil, 0644)
+		}
+	})
+
+	broken, _ := manager.GetJob("broken")
+	if err := manager.ExecuteJob(context.Background(), broken); err == nil {
+		t.Fatal("ExecuteJob(broken) succeeded")
+	}
+	flaky, _ := manager.GetJob("flaky")
+	if err := manager.ExecuteJob(context.Background(), flaky); err != nil {
+		t.Fatalf("ExecuteJob(flaky) error = %v", err)
+	}
+
+	executions, err := manager.GetJobExecutions("broken", 0)
+	if err != nil {
+		t.Fatalf("GetJobExecutions() error = %v", err)
+	}
+	if len(executions) != 3 {
+		t.Fatalf("broken job has %d executions, want 3 attempts", len(executions))
+	}
+	var first *JobExecution
+	attempts := make(map[int]*JobExecution)
+	for _, execution := range executions {
+		attempts[execution.Attempt] = execution
+		if execution.ParentExecutionID == "" {
+			first = execution
+		}
+	}
+	if first == nil || first.Attempt != 1 || first.Status != types.StatusFailed {
+		t.Fatalf("first attempt = %+v, want a failed attempt 1 without parent", first)
+	}
+	for attempt := 2; attempt <= 3; attempt++ {
+		retry := attempts[attempt]
+		if retry == nil || retry.ParentExecutionID != first.ID || retry.Status != types.StatusFailed {
+			t.Errorf("attempt %d = %+v, want a failed retry of %s", attempt, retry, first.ID)
+		}
+		if retry != nil && (retry.CorrelationID == "" || retry.CorrelationID != first.CorrelationID) {
+			t.Errorf("attempt %d correlation ID = %q, want that of the first attempt %q", attempt, retry.CorrelationID, first.CorrelationID)
+		}
+	}
+
+	tests := []struct {
+		job                  string
+		recovered, permanent int64
+	}{
+		{"broken", 0, 1},
+		{"flaky", 1, 0},
+	}
+	for _, tt := range tests {
+		stats, err := manager.store.GetJobStatistics(storage.StatisticsFilter{JobName: tt.job})
+		if err != nil {
+			t.Fatalf("GetJobStatistics(%s) error = %v", tt.job, err)
+		}
+		if stats["runs"] != int64(1) || stats["recovered"] != tt.recovered || stats["failed_permanently"] != tt.permanent {
+			t.Errorf("GetJobStatistics(%s) = %v, want 1 run, %d recovered, %d failed permanently",
+				tt.job, stats, tt.recovered, tt.permanent)
+		}
+	}
+}
+
+func TestDrainWaitsForRunningJobs(t *testing.T) {
+	manager := newTestManager(t,
+		config.JobConfig{Name: "slow", Command: "sleep 0.3", Timeout: time.Minute},
+		config.JobConfig{Name: "quick", Command: "true", Timeout: time.Minute},
+	)
+	slow, _ := manager.GetJob("slow")
+	quick, _ := manager.GetJob("quick")
+
+	execution, err := manager.StartJob(context.Background(), slow)
+	if err != nil {
+		t.Fatalf("StartJob() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := manager.Drain(ctx); err == nil {
+		t.Error("Drain() returned while the job was running")
+	}
+	if !manager.Draining() {
+		t.Error("Draining() = false after Drain()")
+	}
+	if err := manager.ExecuteJob(context.Background(), quick); err != ErrDraining {
+		t.Errorf("ExecuteJob() error = %v, want ErrDraining", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := manager.Drain(ctx); err != nil {
+		t.Fatalf("Drain() error = %v, want the running job waited for", err)
+	}
+	if stored, _ := manager.GetExecution(execution.ID); stored == nil || stored.Status != types.StatusCompleted {
+		t.Errorf("execution = %+v, want it finished before Drain() returned", stored)
+	}
+}
+
+func TestDrainCancelsRetryBackoff(t *testing.T) {
+	defer func(backoff time.Duration) { retryBackoff = backoff }(retryBackoff)
+	retryBackoff = time.Hour
+
+	manager := newTestManager(t, config.JobConfig{Name: "broken", Command: "false", Timeout: time.Minute, Retries: 1})
+	broken, _ := manager.GetJob("broken")
+	if _, err := manager.StartJob(context.Background(), broken); err != nil {
+		t.Fatalf("StartJob() error = %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); broken.GetStatus() != types.StatusRetrying; {
+		if time.Now().After(deadline) {
+			t.Fatal("job not waiting to be retried")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := manager.Drain(ctx); err != nil {
+		t.Fatalf("Drain() error = %v, want the retry backoff ended", err)
+	}
+	executions, _ := manager.GetJobExecutions("broken", 0)
+	var retry *JobExecution
+	for _, execution := range executions {
+		if execution.Attempt == 2 {
+			retry = execution
+		}
+	}
+	if retry == nil || retry.Status != types.StatusFailed || retry.FailureReason != types.FailureCancelled {
+		t.Errorf("retry = %+v, want it failed as cancelled", retry)
+	}
+}
+
+// TestSetScheduleWhileRunning is meant for go test -race: the schedule
+// changes while the job runs
+func TestSetScheduleWhileRunning(t *testing.T) {
+	manager := newTestManager(t, config.JobConfig{Name: "busy", Schedule: "* * * * *", Command: "true", Timeout: time.Minute})
+	job, _ := manager.GetJob("busy")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			if err := manager.ExecuteJob(context.Background(), job); err != nil {
+				t.Errorf("ExecuteJob() error = %v", err)
+			}
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		job.SetSchedule(fmt.Sprintf("%d * * * *", i%60))
+	}
+	<-done
+}
+
+func TestTimeoutDefaultAndMaximum(t *testing.T) {
+	manager := newTestManager(t)
+	ctx := context.Background()
+
+	if got := manager.timeout(ctx, config.JobConfig{Name: "unset"}); got != fallbackTimeout {
+		t.Errorf("timeout without defaults = %v, want %v", got, fallbackTimeout)
+	}
+
+	manager.SetTimeouts(10*time.Minute, time.Hour)
+	tests := []struct {
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{0, 10 * time.Minute},
+		{30 * time.Second, 30 * time.Second},
+		{2 * time.Hour, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := manager.timeout(ctx, config.JobConfig{Name: "job", Timeout: tt.timeout}); got != tt.want {
+			t.Errorf("timeout(%v) = %v, want %v", tt.timeout, got, tt.want)
+		}
+	}
+}
+
+func TestJobWithoutTimeoutRuns(t *testing.T) {
+	manager := newTestManager(t, config.JobConfig{Name: "untimed", Command: "true"})
+	job, _ := manager.GetJob("untimed")
+	if err := manager.ExecuteJob(context.Background(), job); err != nil {
+		t.Errorf("ExecuteJob() error = %v, want a job without timeout to get the default", err)
+	}
+}
+
+func TestFailureReasons(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "killed.sh")
+	if err := os.WriteFile(script, []byte("kill -KILL $$\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		job  config.JobConfig
+		want types.FailureReason
+		code int
+	}{
+		{config.JobConfig{Name: "missing", Command: "/nonexistent/arcron-job", Timeout: time.Minute}, types.FailureStart, -1},
+		{config.JobConfig{Name: "exits", Command: "false", Timeout: time.Minute}, types.FailureExitCode, 1},
+		{config.JobConfig{Name: "slow", Command: "sleep 5", Timeout: 50 * time.Millisecond}, types.FailureTimeout, -1},
+		{config.JobConfig{Name: "killed", Command: "sh " + script, Timeout: time.Minute}, types.FailureSignal, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.job.Name, func(t *testing.T) {
+			manager := newTestManager(t, tt.job)
+			var finished *types.JobExecution
+			manager.AddListener(func(execution *types.JobExecution) { finished = execution })
+			job, _ := manager.GetJob(tt.job.Name)
+
+			if err := manager.ExecuteJob(context.Background(), job); err == nil {
+				t.Fatal("ExecuteJob() succeeded, want a failure")
+			}
+			if finished == nil || finished.FailureReason != tt.want || finished.ExitCode != tt.code {
+				t.Errorf("execution = %+v, want reason %s and exit code %d", finished, tt.want, tt.code)
+			}
+		})
+	}
+}
+
+func TestOutputWhileRunning(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "continue")
+	script := filepath.Join(dir, "progress.sh")
+	body := "echo first\nfor i in $(seq 100); do [ -e " + marker + " ] && break; sleep 0.05; done\necho second\n"
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	manager := newTestManager(t, config.JobConfig{Name: "progress", Command: "sh " + script, Timeout: time.Minute})
+	job, _ := manager.GetJob("progress")
+
+	execution, err := manager.StartJob(context.Background(), job)
+	if err != nil {
+		t.Fatalf("StartJob() error = %v", err)
+	}
+
+	// poll polls from offset until the output is want or the job finished
+	poll := func(offset int, want string) *OutputChunk {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			chunk, err := manager.Output(execution.ID, offset)
+			if err != nil {
+				t.Fatalf("Output() error = %v", err)
+			}
+			if chunk.Output == want || !chunk.Running || time.Now().After(deadline) {
+				return chunk
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	chunk := poll(0, "first\n")
+	if chunk.Output != "first\n" || !chunk.Running || chunk.NextOffset != len("first\n") {
+		t.Fatalf("Output(0) = %+v, want the first line of the running job", chunk)
+	}
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if chunk = poll(chunk.NextOffset, "second\n"); chunk.Output != "second\n" {
+		t.Errorf("Output(%d) = %+v, want the second line", chunk.Offset, chunk)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := manager.Drain(ctx); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if chunk, _ := manager.Output(execution.ID, 0); chunk.Output != "first\nsecond\n" || chunk.Running {
+		t.Errorf("Output(0) = %+v, want the stored output of the finished job", chunk)
+	}
+
+	if chunk, _ := manager.Output(execution.ID, 100); chunk.Output != "" || chunk.NextOffset != 100 {
+		t.Errorf("Output(100) = %+v, want nothing past the end", chunk)
+	}
+	if chunk, err := manager.Output("unknown", 0); chunk != nil || err != nil {
+		t.Errorf("Output(unknown) = %+v, %v, want nil", chunk, err)
+	}
+}
+
+func TestArtifactsCollected(t *testing.T) {
+	workDir := t.TempDir()
+	stale := filepath.Join(workDir, "stale.txt")
+	os.WriteFile(stale, []byte("old"), 0644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(stale, old, old)
+	script := filepath.Join(t.TempDir(), "report.sh")
+	os.WriteFile(script, []byte("echo done > "+workDir+"/report.csv\n"), 0755)
+
+	manager := newTestManager(t, config.JobConfig{
+		Name:      "report",
+		Command:   "sh " + script,
+		Timeout:   time.Minute,
+		Artifacts: []string{workDir + "/*.csv", workDir + "/*.txt"},
+	})
+	store := storage.NewMemoryStore()
+	manager.SetArtifacts(config.ArtifactsConfig{Directory: t.TempDir(), MaxSize: 1 << 20}, store)
+	var finished *types.JobExecution
+	manager.AddListener(func(execution *types.JobExecution) { finished = execution })
+	job, _ := manager.GetJob("report")
+
+	if err := manager.ExecuteJob(context.Background(), job); err != nil {
+		t.Fatalf("ExecuteJob() error = %v", err)
+	}
+
+	artifacts, err := manager.Artifacts(finished.ID)
+	if err != nil {
+		t.Fatalf("Artifacts() error = %v", err)
+	}
+	if len(artifacts) != 1 || artifacts[0].Name != "report.csv" || artifacts[0].Size != 5 {
+		t.Fatalf("Artifacts() = %+v, want the report written by the run only", artifacts)
+	}
+
+	artifact, file, err := manager.OpenArtifact(context.Background(), finished.ID, "report.csv")
+	if err != nil || artifact == nil {
+		t.Fatalf("OpenArtifact() = %+v, %v", artifact, err)
+	}
+	defer file.Close()
+	if data, _ := io.ReadAll(file); string(data) != "done\n" {
+		t.Errorf("OpenArtifact() read %q, want the report", data)
+	}
+	if artifact, _, _ := manager.OpenArtifact(context.Background(), finished.ID, "stale.txt"); artifact != nil {
+		t.Errorf("OpenArtifact(stale.txt) = %+v, want nil", artifact)
+	}
+}
@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/makalin/arcron/internal/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// withCorrelation returns a context carrying the correlation ID of the run
+// it triggers, keeping the ID of whatever triggered it, e.g. the scheduler
+func withCorrelation(ctx context.Context) context.Context {
+	if logging.CorrelationID(ctx) != "" {
+		return ctx
+	}
+	return logging.WithCorrelationID(ctx, logging.NewCorrelationID())
+}
+
+// executionLog returns a log entry for an execution, carrying its job,
+// ID and correlation ID
+func executionLog(execution *JobExecution) *logrus.Entry {
+	return logging.ForJob(execution.JobName, execution.CorrelationID).WithField(logging.ExecutionField, execution.ID)
+}
@@ -0,0 +1,34 @@
+package jobs
+
+import "github.com/makalin/arcron/internal/types"
+
+// MetricsSource provides the latest collected system metrics, see
+// monitoring.Monitor
+type MetricsSource interface {
+	GetLastMetrics() *types.SystemMetrics
+}
+
+// SetMetricsSource makes every execution record the system metrics when
+// its attempt starts and ends
+func (m *Manager) SetMetricsSource(source MetricsSource) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.metrics = source
+}
+
+// snapshotMetrics returns a snapshot of the latest collected metrics, or
+// nil if there are none
+func (m *Manager) snapshotMetrics() *types.MetricsSnapshot {
+	m.mutex.RLock()
+	source := m.metrics
+	m.mutex.RUnlock()
+	if source == nil {
+		return nil
+	}
+
+	metrics := source.GetLastMetrics()
+	if metrics == nil {
+		return nil
+	}
+	return types.NewMetricsSnapshot(metrics)
+}
@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/logging"
+)
+
+// SetNamespaces limits the number of jobs of each namespace that run at
+// the same time. Namespaces without a limit are unbounded.
+func (m *Manager) SetNamespaces(namespaces []config.NamespaceConfig) {
+	slots := make(map[string]chan struct{})
+	for _, namespace := range namespaces {
+		if namespace.MaxConcurrentJobs > 0 {
+			slots[namespace.Name] = make(chan struct{}, namespace.MaxConcurrentJobs)
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.slots = slots
+}
+
+// acquireSlot waits until a job of the namespace may run and returns the
+// function releasing its slot
+func (m *Manager) acquireSlot(ctx context.Context, namespace string) (func(), error) {
+	m.mutex.RLock()
+	slots := m.slots[namespace]
+	m.mutex.RUnlock()
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	default:
+	}
+
+	logging.FromContext(ctx).Infof("Namespace %s is at its concurrency limit of %d jobs, waiting", namespace, cap(slots))
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-m.ctx.Done():
+		return nil, fmt.Errorf("job manager stopped while waiting for a slot in namespace %s", namespace)
+	}
+}
@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+func TestNamespaceConcurrencyLimit(t *testing.T) {
+	// The script fails if another copy of it is running
+	dir := t.TempDir()
+	script := filepath.Join(dir, "exclusive.sh")
+	lock := filepath.Join(dir, "lock")
+	content := "mkdir " + lock + " || exit 1\nsleep 0.2\nrmdir " + lock + "\n"
+	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	manager := newTestManager(t,
+		config.JobConfig{Name: "first", Command: "sh " + script, Namespace: "team-a", Timeout: time.Minute},
+		config.JobConfig{Name: "second", Command: "sh " + script, Namespace: "team-a", Timeout: time.Minute},
+	)
+	manager.SetNamespaces([]config.NamespaceConfig{{Name: "team-a", MaxConcurrentJobs: 1}})
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, name := range []string{"first", "second"} {
+		job, _ := manager.GetJob(name)
+		wg.Add(1)
+		go func(i int, job *Job) {
+			defer wg.Done()
+			errs[i] = manager.ExecuteJob(context.Background(), job)
+		}(i, job)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("job %d failed, so it ran alongside the other: %v", i, err)
+		}
+	}
+}
+
+func TestNamespaceSlotWaitCancelled(t *testing.T) {
+	manager := newTestManager(t)
+	manager.SetNamespaces([]config.NamespaceConfig{{Name: "team-a", MaxConcurrentJobs: 1}})
+
+	release, err := manager.acquireSlot(context.Background(), "team-a")
+	if err != nil {
+		t.Fatalf("acquireSlot() error = %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := manager.acquireSlot(ctx, "team-a"); err == nil {
+		t.Error("acquireSlot() succeeded beyond the namespace limit")
+	}
+	if _, err := manager.acquireSlot(ctx, config.DefaultNamespace); err != nil {
+		t.Errorf("acquireSlot() of an unlimited namespace error = %v", err)
+	}
+}
+
+func TestQueueWaitIncludesSlotWait(t *testing.T) {
+	manager := newTestManager(t, config.JobConfig{Name: "queued", Command: "true", Namespace: "team-a", Timeout: time.Minute})
+	manager.SetNamespaces([]config.NamespaceConfig{{Name: "team-a", MaxConcurrentJobs: 1}})
+
+	var execution *JobExecution
+	manager.AddListener(func(e *JobExecution) { execution = e })
+
+	release, err := manager.acquireSlot(context.Background(), "team-a")
+	if err != nil {
+		t.Fatalf("acquireSlot() error = %v", err)
+	}
+	time.AfterFunc(100*time.Millisecond, release)
+
+	// Due a second ago, e.g. held back by the resource gate meanwhile
+	queuedAt := time.Now().Add(-time.Second)
+	job, _ := manager.GetJob("queued")
+	if err := manager.ExecuteJob(WithQueuedAt(context.Background(), queuedAt), job); err != nil {
+		t.Fatalf("ExecuteJob() error = %v", err)
+	}
+
+	if !execution.QueuedAt.Equal(queuedAt) {
+		t.Errorf("QueuedAt = %v, want %v", execution.QueuedAt, queuedAt)
+	}
+	if execution.QueueWait < 1.1 || execution.QueueWait != execution.StartTime.Sub(queuedAt).Seconds() {
+		t.Errorf("QueueWait = %v, want the second before and the slot wait, until %v", execution.QueueWait, execution.StartTime)
+	}
+}
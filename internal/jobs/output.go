@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// maxOutputChunk is the most output returned by one Output call, so
+// clients catching up on a long run page through it
+const maxOutputChunk = 1 << 20
+
+// OutputChunk is the part of the output of an execution from an offset.
+// Offsets count bytes of the combined stdout and stderr.
+type OutputChunk struct {
+	Output     string `json:"output"`
+	Offset     int    `json:"offset"`
+	NextOffset int    `json:"next_offset"`
+	// Running is true while the command may write more output; polling
+	// stops once it is false and the next offset reached the end
+	Running bool `json:"running"`
+}
+
+// outputBuffer collects the output of a running command and can be read
+// while it is written
+type outputBuffer struct {
+	mutex sync.Mutex
+	data  bytes.Buffer
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.data.Write(p)
+}
+
+// String returns all output so far
+func (b *outputBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.data.String()
+}
+
+// trackOutput makes the output of a running execution available to Output
+// until the returned function is called, once the execution is stored
+// with its output
+func (m *Manager) trackOutput(executionID string) (*outputBuffer, func()) {
+	buffer := &outputBuffer{}
+	m.mutex.Lock()
+	if m.outputs == nil {
+		m.outputs = make(map[string]*outputBuffer)
+	}
+	m.outputs[executionID] = buffer
+	m.mutex.Unlock()
+
+	return buffer, func() {
+		m.mutex.Lock()
+		delete(m.outputs, executionID)
+		m.mutex.Unlock()
+	}
+}
+
+// Output returns the output of an execution from offset: what a running
+// command wrote so far, or the stored output of a finished one. It
+// returns nil if there is no such execution.
+func (m *Manager) Output(executionID string, offset int) (*OutputChunk, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("offset cannot be negative")
+	}
+
+	m.mutex.RLock()
+	buffer := m.outputs[executionID]
+	m.mutex.RUnlock()
+
+	var output string
+	running := buffer != nil
+	if running {
+		output = buffer.String()
+	} else {
+		execution, err := m.store.GetJobExecution(executionID)
+		if err != nil || execution == nil {
+			return nil, err
+		}
+		output = execution.Output
+		// Attempts not started yet will still write output
+		running = execution.Status == types.StatusPending || execution.Status == types.StatusRunning
+	}
+
+	chunk := &OutputChunk{Offset: offset, NextOffset: offset, Running: running}
+	if offset < len(output) {
+		end := min(len(output), offset+maxOutputChunk)
+		chunk.Output = output[offset:end]
+		chunk.NextOffset = end
+	}
+	return chunk, nil
+}
@@ -0,0 +1,124 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/telemetry"
+)
+
+var (
+	outputValues = telemetry.NewGauge("arcron_job_output_value",
+		"Latest value of gauge output metrics of jobs", "job", "namespace", "metric")
+	outputTotals = telemetry.NewCounter("arcron_job_output_total",
+		"Sum over runs of counter output metrics of jobs", "job", "namespace", "metric")
+)
+
+// extractOutputMetrics applies the output metric rules of a job to the
+// output of a run and exports the values found. Rules that find no value
+// are left out; errors are returned by metric name.
+func extractOutputMetrics(jobConfig config.JobConfig, output string) (map[string]float64, map[string]error) {
+	if len(jobConfig.Metrics) == 0 {
+		return nil, nil
+	}
+
+	values := make(map[string]float64)
+	problems := make(map[string]error)
+	for _, metric := range jobConfig.Metrics {
+		var value float64
+		var found bool
+		var err error
+		if metric.Regex != "" {
+			value, found, err = matchOutputValue(metric.Regex, output)
+		} else {
+			value, found, err = jsonOutputValue(metric.JSONPath, output)
+		}
+		if err != nil {
+			problems[metric.Name] = err
+			continue
+		}
+		if !found {
+			continue
+		}
+
+		values[metric.Name] = value
+		if metric.Type == config.OutputCounter {
+			outputTotals.Add(value, jobConfig.Name, jobConfig.GetNamespace(), metric.Name)
+		} else {
+			outputValues.Set(value, jobConfig.Name, jobConfig.GetNamespace(), metric.Name)
+		}
+	}
+	if len(values) == 0 {
+		values = nil
+	}
+	return values, problems
+}
+
+// matchOutputValue returns the first group of the last match of a regex
+// in the output as a number
+func matchOutputValue(pattern, output string) (float64, bool, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return 0, false, err
+	}
+	matches := re.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 || len(matches[len(matches)-1]) < 2 {
+		return 0, false, nil
+	}
+	text := matches[len(matches)-1][1]
+	value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("matched %q, not a number", text)
+	}
+	return value, true, nil
+}
+
+// jsonOutputValue returns the value at a dotted path, e.g. $.stats.rows,
+// in the last line of the output that is a JSON object holding it
+func jsonOutputValue(path, output string) (float64, bool, error) {
+	keys := strings.Split(strings.TrimPrefix(strings.TrimPrefix(path, "$"), "."), ".")
+	lines := strings.Split(output, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var object interface{}
+		if err := json.Unmarshal([]byte(line), &object); err != nil {
+			continue
+		}
+		for _, key := range keys {
+			fields, ok := object.(map[string]interface{})
+			if !ok {
+				object = nil
+				break
+			}
+			object = fields[key]
+		}
+
+		switch value := object.(type) {
+		case nil:
+			continue
+		case float64:
+			return value, true, nil
+		case bool:
+			if value {
+				return 1, true, nil
+			}
+			return 0, true, nil
+		case string:
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return 0, false, fmt.Errorf("%s is %q, not a number", path, value)
+			}
+			return number, true, nil
+		default:
+			return 0, false, fmt.Errorf("%s is not a number", path)
+		}
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/telemetry"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestExtractOutputMetrics(t *testing.T) {
+	output := "starting\nrows_processed=10\nrows_processed=1234\n" +
+		`{"stats": {"bytes": 2048, "ok": true, "rate": "1.5", "tables": ["a"]}}` + "\ndone\n"
+	job := config.JobConfig{Name: "etl", Metrics: []config.OutputMetric{
+		{Name: "rows", Regex: `rows_processed=(\d+)`},
+		{Name: "bytes", JSONPath: "$.stats.bytes", Type: config.OutputCounter},
+		{Name: "ok", JSONPath: "stats.ok"},
+		{Name: "rate", JSONPath: "$.stats.rate"},
+		{Name: "missing", JSONPath: "$.stats.rows"},
+		{Name: "absent", Regex: `errors=(\d+)`},
+		{Name: "tables", JSONPath: "$.stats.tables"},
+		{Name: "word", Regex: `(starting)`},
+	}}
+
+	values, problems := extractOutputMetrics(job, output)
+	want := map[string]float64{"rows": 1234, "bytes": 2048, "ok": 1, "rate": 1.5}
+	if len(values) != len(want) {
+		t.Errorf("values = %v, want %v", values, want)
+	}
+	for name, value := range want {
+		if values[name] != value {
+			t.Errorf("%s = %v, want %v", name, values[name], value)
+		}
+	}
+	if len(problems) != 2 || problems["tables"] == nil || problems["word"] == nil {
+		t.Errorf("problems = %v, want the non-numeric tables and word", problems)
+	}
+
+	var buf bytes.Buffer
+	telemetry.WritePrometheus(&buf)
+	for _, line := range []string{
+		`arcron_job_output_value{job="etl",namespace="default",metric="rows"} 1234`,
+		`arcron_job_output_total{job="etl",namespace="default",metric="bytes"} 2048`,
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("metrics do not contain %s", line)
+		}
+	}
+}
+
+func TestExecutionOutputMetrics(t *testing.T) {
+	manager := newTestManager(t, config.JobConfig{
+		Name:    "count",
+		Command: "echo rows_processed=42",
+		Timeout: time.Minute,
+		Metrics: []config.OutputMetric{{Name: "rows", Regex: `rows_processed=(\d+)`}},
+	})
+	var finished *types.JobExecution
+	manager.AddListener(func(execution *types.JobExecution) { finished = execution })
+	job, _ := manager.GetJob("count")
+
+	if err := manager.ExecuteJob(context.Background(), job); err != nil {
+		t.Fatalf("ExecuteJob() error = %v", err)
+	}
+	stored, _ := manager.GetExecution(finished.ID)
+	if stored == nil || stored.OutputMetrics["rows"] != 42 {
+		t.Errorf("execution = %+v, want the rows metric stored", stored)
+	}
+}
@@ -0,0 +1,124 @@
+package jobs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+// shellBinaries lists interpreters rejected when shell mode is denied
+var shellBinaries = map[string]bool{
+	"sh":         true,
+	"bash":       true,
+	"dash":       true,
+	"zsh":        true,
+	"ksh":        true,
+	"csh":        true,
+	"tcsh":       true,
+	"fish":       true,
+	"cmd":        true,
+	"cmd.exe":    true,
+	"powershell": true,
+	"pwsh":       true,
+}
+
+// Policy restricts which commands jobs may execute and which
+// environment variables they receive
+type Policy struct {
+	config config.SecurityConfig
+}
+
+// NewPolicy creates a new Policy from the security configuration
+func NewPolicy(cfg config.SecurityConfig) *Policy {
+	return &Policy{config: cfg}
+}
+
+// CheckCommand verifies that a command line is permitted by the policy
+func (p *Policy) CheckCommand(command string) error {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return fmt.Errorf("empty command")
+	}
+
+	binary := parts[0]
+	base := filepath.Base(binary)
+
+	if p.config.DenyShell && shellBinaries[strings.ToLower(base)] {
+		return fmt.Errorf("shell execution is denied by security policy: %s", binary)
+	}
+
+	if len(p.config.AllowedCommands) == 0 && len(p.config.AllowedPathPrefixes) == 0 {
+		return nil
+	}
+
+	// Bare names only match bare binaries resolved via PATH, so that an
+	// allowed "rsync" does not permit "/tmp/rsync"
+	for _, allowed := range p.config.AllowedCommands {
+		if binary == allowed {
+			return nil
+		}
+	}
+
+	if filepath.IsAbs(binary) {
+		cleaned := filepath.Clean(binary)
+		for _, prefix := range p.config.AllowedPathPrefixes {
+			prefix = filepath.Clean(prefix)
+			if cleaned == prefix || strings.HasPrefix(cleaned, prefix+string(filepath.Separator)) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("command not permitted by security policy: %s", binary)
+}
+
+// FilterEnvironment returns the subset of env permitted by the policy
+// together with the names of the variables that were dropped
+func (p *Policy) FilterEnvironment(env map[string]string) (map[string]string, []string) {
+	filtered := make(map[string]string, len(env))
+	var dropped []string
+
+	for name, value := range env {
+		if matchesEnvPattern(name, p.config.DeniedEnv) {
+			dropped = append(dropped, name)
+			continue
+		}
+		if len(p.config.AllowedEnv) > 0 && !matchesEnvPattern(name, p.config.AllowedEnv) {
+			dropped = append(dropped, name)
+			continue
+		}
+		filtered[name] = value
+	}
+
+	return filtered, dropped
+}
+
+// environMap turns an environment in the "name=value" form of os.Environ
+// into a map
+func environMap(environ []string) map[string]string {
+	env := make(map[string]string, len(environ))
+	for _, entry := range environ {
+		// Windows keeps per-drive directories in variables named "=C:"
+		if name, value, ok := strings.Cut(entry, "="); ok && name != "" {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// matchesEnvPattern reports whether name matches any pattern. Patterns
+// ending in "*" match by prefix.
+func matchesEnvPattern(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package jobs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestPolicyCheckCommand(t *testing.T) {
+	policy := NewPolicy(config.SecurityConfig{
+		AllowedCommands:     []string{"rsync", "/usr/sbin/logrotate"},
+		AllowedPathPrefixes: []string{"/opt/arcron/bin"},
+		DenyShell:           true,
+	})
+
+	allowed := []string{
+		"rsync -av /data /backup",
+		"/usr/sbin/logrotate /etc/logrotate.conf",
+		"/opt/arcron/bin/cleanup --dry-run",
+	}
+	for _, command := range allowed {
+		if err := policy.CheckCommand(command); err != nil {
+			t.Errorf("Expected command %q to be allowed, got error: %v", command, err)
+		}
+	}
+
+	denied := []string{
+		"/tmp/rsync -av /data /backup",
+		"/opt/arcron/binary",
+		"/opt/arcron/bin/../../../bin/rm -rf /",
+		"bash -c 'rm -rf /'",
+		"curl http://example.com",
+		"",
+	}
+	for _, command := range denied {
+		if err := policy.CheckCommand(command); err == nil {
+			t.Errorf("Expected command %q to be denied", command)
+		}
+	}
+}
+
+func TestPolicyAllowsAnyCommandWhenUnrestricted(t *testing.T) {
+	policy := NewPolicy(config.SecurityConfig{})
+
+	if err := policy.CheckCommand("bash -c 'echo hello'"); err != nil {
+		t.Errorf("Expected unrestricted policy to allow command, got error: %v", err)
+	}
+}
+
+func TestPolicyFilterEnvironment(t *testing.T) {
+	policy := NewPolicy(config.SecurityConfig{
+		AllowedEnv: []string{"BACKUP_*", "PATH"},
+		DeniedEnv:  []string{"BACKUP_SECRET"},
+	})
+
+	filtered, dropped := policy.FilterEnvironment(map[string]string{
+		"BACKUP_PATH":   "/backup",
+		"BACKUP_SECRET": "hunter2",
+		"PATH":          "/usr/bin",
+		"LD_PRELOAD":    "/tmp/evil.so",
+	})
+
+	if len(filtered) != 2 {
+		t.Errorf("Expected 2 environment variables, got %d", len(filtered))
+	}
+	if filtered["BACKUP_PATH"] != "/backup" {
+		t.Errorf("Expected BACKUP_PATH to be kept, got '%s'", filtered["BACKUP_PATH"])
+	}
+	if _, ok := filtered["BACKUP_SECRET"]; ok {
+		t.Error("Expected BACKUP_SECRET to be dropped")
+	}
+	if len(dropped) != 2 {
+		t.Errorf("Expected 2 dropped variables, got %d", len(dropped))
+	}
+}
+
+func TestTracedJobEnvironmentFiltered(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+	t.Setenv("ARCRON_TEST_SECRET", "inherited")
+
+	manager, err := New([]config.JobConfig{
+		{Name: "inherits", Command: "env", Timeout: time.Minute},
+		{Name: "configured", Command: "env", Timeout: time.Minute, Environment: map[string]string{
+			"ARCRON_TEST_SECRET": "configured",
+			"BACKUP_DIR":         "/backup",
+		}},
+	}, config.SecurityConfig{DeniedEnv: []string{"ARCRON_TEST_*"}}, storage.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(manager.Stop)
+
+	for _, name := range []string{"inherits", "configured"} {
+		job, _ := manager.GetJob(name)
+		if err := manager.ExecuteJob(context.Background(), job); err != nil {
+			t.Fatalf("ExecuteJob(%s) error = %v", name, err)
+		}
+		executions, err := manager.GetJobExecutions(name, 1)
+		if err != nil || len(executions) != 1 {
+			t.Fatalf("GetJobExecutions(%s) = %v, %v", name, executions, err)
+		}
+		output := executions[0].Output
+		if strings.Contains(output, "ARCRON_TEST_SECRET") {
+			t.Errorf("%s received the denied variable:\n%s", name, output)
+		}
+		if !strings.Contains(output, "TRACEPARENT=") {
+			t.Errorf("%s received no trace context:\n%s", name, output)
+		}
+	}
+}
+
+func TestJobEnvironmentAllDenied(t *testing.T) {
+	t.Setenv("ARCRON_INHERITED", "inherited")
+
+	manager, err := New([]config.JobConfig{
+		{Name: "denied", Command: "env", Timeout: time.Minute, Environment: map[string]string{
+			"SECRET_TOKEN": "configured",
+		}},
+	}, config.SecurityConfig{DeniedEnv: []string{"SECRET_*"}}, storage.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(manager.Stop)
+
+	job, _ := manager.GetJob("denied")
+	if err := manager.ExecuteJob(context.Background(), job); err != nil {
+		t.Fatalf("ExecuteJob() error = %v", err)
+	}
+	executions, err := manager.GetJobExecutions("denied", 1)
+	if err != nil || len(executions) != 1 {
+		t.Fatalf("GetJobExecutions() = %v, %v", executions, err)
+	}
+	if output := executions[0].Output; strings.Contains(output, "SECRET_TOKEN") || strings.Contains(output, "ARCRON_INHERITED") {
+		t.Errorf("job with every variable denied ran with:\n%s", output)
+	}
+}
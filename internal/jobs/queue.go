@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/makalin/arcron/internal/telemetry"
+)
+
+var queueWait = telemetry.NewHistogram("arcron_job_queue_wait_seconds",
+	"Time executions waited between being queued and starting", "job", "namespace")
+
+type queuedAtKey struct{}
+
+// WithQueuedAt returns a context recording that a run was due at queuedAt,
+// so time spent before it reaches the manager, e.g. held back by a
+// resource gate, counts as queue wait
+func WithQueuedAt(ctx context.Context, queuedAt time.Time) context.Context {
+	return context.WithValue(ctx, queuedAtKey{}, queuedAt)
+}
+
+// queuedAt returns when the run of the context was due, or now if the
+// context does not say
+func queuedAt(ctx context.Context) time.Time {
+	if queuedAt, ok := ctx.Value(queuedAtKey{}).(time.Time); ok && !queuedAt.IsZero() {
+		return queuedAt
+	}
+	return time.Now()
+}
+
+// startAttempt marks an execution as started now and records how long it
+// was queued
+func startAttempt(execution *JobExecution) {
+	execution.StartTime = time.Now()
+	if execution.QueuedAt.IsZero() || execution.QueuedAt.After(execution.StartTime) {
+		execution.QueuedAt = execution.StartTime
+	}
+	execution.QueueWait = execution.StartTime.Sub(execution.QueuedAt).Seconds()
+	queueWait.Observe(execution.QueueWait, execution.JobName, execution.Namespace)
+}
@@ -0,0 +1,156 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/logging"
+	"github.com/makalin/arcron/internal/telemetry"
+)
+
+var deferredStarts = telemetry.NewCounter("arcron_job_starts_deferred_total",
+	"Job starts deferred by a rate limit", "limit")
+
+// startLimiter is a rate limit with the starts it allowed, oldest first.
+// Starts may be reserved ahead, for runs waiting their turn.
+type startLimiter struct {
+	config config.RateLimitConfig
+	starts []time.Time
+}
+
+// rateLimits are the rate limits of the manager
+type rateLimits struct {
+	mutex    sync.Mutex
+	limiters []*startLimiter
+}
+
+// SetRateLimits limits how many runs of the jobs each limit applies to
+// start within its window. A run over a limit waits until it may start,
+// after the runs that reached the limit before it.
+func (m *Manager) SetRateLimits(limits []config.RateLimitConfig) {
+	rates := &rateLimits{}
+	for _, limit := range limits {
+		if limit.MaxStarts > 0 && limit.Window > 0 {
+			rates.limiters = append(rates.limiters, &startLimiter{config: limit})
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.rates = rates
+}
+
+// reserve returns when a run of a job starting at now may start under
+// every rate limit it is subject to, and counts the start at that time,
+// along with the first limit that deferred it, if any
+func (r *rateLimits) reserve(jobConfig config.JobConfig, now time.Time) (time.Time, string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var matching []*startLimiter
+	for _, limiter := range r.limiters {
+		if limiter.config.Matches(jobConfig) {
+			limiter.prune(now)
+			matching = append(matching, limiter)
+		}
+	}
+
+	// The earliest time allowed by every limit, found by moving past the
+	// starts filling the window of each in turn until none is full
+	at, deferredBy := now, ""
+	for moved := true; moved; {
+		moved = false
+		for _, limiter := range matching {
+			if next := limiter.next(at); next.After(at) {
+				at, deferredBy, moved = next, limiter.config.Name, true
+			}
+		}
+	}
+	for _, limiter := range matching {
+		limiter.starts = append(limiter.starts, at)
+	}
+	return at, deferredBy
+}
+
+// release gives back a start reserved at a time that did not happen
+func (r *rateLimits) release(jobConfig config.JobConfig, at time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, limiter := range r.limiters {
+		if !limiter.config.Matches(jobConfig) {
+			continue
+		}
+		for i := len(limiter.starts) - 1; i >= 0; i-- {
+			if limiter.starts[i].Equal(at) {
+				limiter.starts = append(limiter.starts[:i], limiter.starts[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// next returns the earliest time from at that a start fits within the
+// limit. Starts are kept in order, so none is reserved before the last.
+func (l *startLimiter) next(at time.Time) time.Time {
+	n := len(l.starts)
+	if n > 0 && l.starts[n-1].After(at) {
+		at = l.starts[n-1]
+	}
+	if n >= l.config.MaxStarts {
+		if free := l.starts[n-l.config.MaxStarts].Add(l.config.Window); free.After(at) {
+			at = free
+		}
+	}
+	return at
+}
+
+// prune drops the starts that left the window by now
+func (l *startLimiter) prune(now time.Time) {
+	cutoff := now.Add(-l.config.Window)
+	for len(l.starts) > 0 && !l.starts[0].After(cutoff) {
+		l.starts = l.starts[1:]
+	}
+}
+
+// acquireStart waits until a run of a job may start: for a slot in its
+// namespace, then for its turn under the rate limits. It returns the
+// function releasing the slot.
+func (m *Manager) acquireStart(ctx context.Context, jobConfig config.JobConfig) (func(), error) {
+	release, err := m.acquireSlot(ctx, jobConfig.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	m.mutex.RLock()
+	rates := m.rates
+	m.mutex.RUnlock()
+	if rates == nil {
+		return release, nil
+	}
+	now := time.Now()
+	at, deferredBy := rates.reserve(jobConfig, now)
+	if deferredBy == "" {
+		return release, nil
+	}
+
+	deferredStarts.Inc(deferredBy)
+	logging.FromContext(ctx).Infof("Rate limit %s reached, starting job %s in %s",
+		deferredBy, jobConfig.Name, at.Sub(now).Round(time.Millisecond))
+	timer := time.NewTimer(at.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return release, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-m.ctx.Done():
+		err = fmt.Errorf("job manager stopped while waiting for rate limit %s", deferredBy)
+	}
+	rates.release(jobConfig, at)
+	release()
+	return nil, err
+}
@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+func TestRateLimitReserve(t *testing.T) {
+	manager := newTestManager(t)
+	manager.SetRateLimits([]config.RateLimitConfig{
+		{Name: "global", MaxStarts: 2, Window: time.Minute},
+		{Name: "billing-db", MaxStarts: 1, Window: time.Minute, Labels: map[string]string{"db": "billing"}},
+		{Name: "team-a", MaxStarts: 1, Window: time.Minute, Namespace: "team-a"},
+	})
+	billing := config.JobConfig{Name: "invoices", Labels: map[string]string{"db": "billing"}}
+	other := config.JobConfig{Name: "report"}
+
+	t0 := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		job        config.JobConfig
+		now        time.Time
+		want       time.Time
+		deferredBy string
+	}{
+		{billing, t0, t0, ""},
+		{other, t0, t0, ""},
+		// Over both limits, deferred until the first starts leave the window
+		{billing, t0, t0.Add(time.Minute), "global"},
+		// Starts wait in order behind the deferred one
+		{other, t0.Add(time.Second), t0.Add(time.Minute), "global"},
+		{other, t0.Add(2 * time.Second), t0.Add(2 * time.Minute), "global"},
+		// Not subject to the global limit alone: team-a is checked too, and
+		// an unused namespace limit does not defer
+		{config.JobConfig{Name: "sync", Namespace: "team-b"}, t0.Add(3 * time.Minute), t0.Add(3 * time.Minute), ""},
+	}
+	for i, tt := range tests {
+		at, deferredBy := manager.rates.reserve(tt.job, tt.now)
+		if !at.Equal(tt.want) || deferredBy != tt.deferredBy {
+			t.Errorf("start %d of %s at %s by %q, want %s by %q", i, tt.job.Name,
+				at.Format("15:04:05"), deferredBy, tt.want.Format("15:04:05"), tt.deferredBy)
+		}
+	}
+
+	// A released start makes room again
+	t1 := t0.Add(10 * time.Minute)
+	manager.rates.reserve(other, t1)
+	manager.rates.reserve(other, t1)
+	manager.rates.release(other, t1)
+	if at, _ := manager.rates.reserve(other, t1); !at.Equal(t1) {
+		t.Errorf("start after a release at %s, want the released time", at.Format("15:04:05"))
+	}
+}
+
+func TestRateLimitDefersStart(t *testing.T) {
+	manager := newTestManager(t, config.JobConfig{Name: "ping", Command: "true", Timeout: time.Minute})
+	manager.SetRateLimits([]config.RateLimitConfig{{Name: "global", MaxStarts: 1, Window: 200 * time.Millisecond}})
+	job, _ := manager.GetJob("ping")
+
+	var executions []*JobExecution
+	manager.AddListener(func(e *JobExecution) { executions = append(executions, e) })
+	for i := 0; i < 2; i++ {
+		if err := manager.ExecuteJob(context.Background(), job); err != nil {
+			t.Fatalf("ExecuteJob() error = %v", err)
+		}
+	}
+	if len(executions) != 2 {
+		t.Fatalf("%d executions finished, want 2", len(executions))
+	}
+	if gap := executions[1].StartTime.Sub(executions[0].StartTime); gap < 190*time.Millisecond {
+		t.Errorf("second start %s after the first, want it deferred by the rate limit", gap)
+	}
+	if executions[1].QueueWait <= 0 {
+		t.Error("deferred start recorded no queue wait")
+	}
+
+	// A start given up while deferred fails
+	time.Sleep(250 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := manager.acquireStart(ctx, job.GetConfig()); err != nil {
+		t.Fatalf("acquireStart() error = %v", err)
+	}
+	if _, err := manager.acquireStart(ctx, job.GetConfig()); err == nil {
+		t.Error("acquireStart() succeeded beyond the rate limit")
+	}
+}
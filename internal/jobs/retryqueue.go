@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/logging"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// RetryQueue takes over a retry once it is stored as pending, to start it
+// with ResumeRetry when its QueuedAt has passed. An error makes the
+// manager wait out the backoff itself.
+type RetryQueue func(ctx context.Context, retry *JobExecution) error
+
+// SetRetryQueue hands the retries of failed runs to a queue instead of
+// waiting out their backoff in process, so they survive restarts
+func (m *Manager) SetRetryQueue(queue RetryQueue) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.retryQueue = queue
+}
+
+// getRetryQueue returns the retry queue, or nil if there is none
+func (m *Manager) getRetryQueue() RetryQueue {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.retryQueue
+}
+
+// ResumeRetry starts a retry handed to the retry queue in the background,
+// with any further retries. A retry of a job no longer configured fails.
+func (m *Manager) ResumeRetry(ctx context.Context, jobName, executionID string) error {
+	execution, err := m.store.GetJobExecution(executionID)
+	if err != nil {
+		return err
+	}
+	if execution == nil || execution.Status != types.StatusPending {
+		return fmt.Errorf("no pending retry %s of job %s", executionID, jobName)
+	}
+
+	job, exists := m.GetJob(jobName)
+	if !exists {
+		m.cancelRetry(ctx, execution, "job removed before the retry")
+		return fmt.Errorf("job not found: %s", jobName)
+	}
+
+	end, err := m.beginRun()
+	if err != nil {
+		return err
+	}
+	if execution.CorrelationID != "" {
+		ctx = logging.WithCorrelationID(ctx, execution.CorrelationID)
+	}
+	ctx = withCorrelation(ctx)
+
+	go func() {
+		defer end()
+		if err := m.executeWithRetries(ctx, job, execution); err != nil {
+			executionLog(execution).Errorf("Failed to execute job %s: %v", jobName, err)
+		}
+	}()
+	return nil
+}
+
+// CancelRetry fails a retry taken off the retry queue before it started
+func (m *Manager) CancelRetry(executionID, reason string) error {
+	execution, err := m.store.GetJobExecution(executionID)
+	if err != nil {
+		return err
+	}
+	if execution == nil || execution.Status != types.StatusPending {
+		return nil
+	}
+	m.cancelRetry(context.Background(), execution, reason)
+	return nil
+}
+
+// cancelRetry records a pending retry as failed without running it
+func (m *Manager) cancelRetry(ctx context.Context, execution *JobExecution, reason string) {
+	execution.EndTime = time.Now()
+	execution.Status = types.StatusFailed
+	execution.Error = reason
+	execution.FailureReason = types.FailureCancelled
+	if err := m.storeExecution(ctx, execution); err != nil {
+		executionLog(execution).Errorf("Failed to store retry execution: %v", err)
+	}
+	m.notifyListeners(execution)
+}
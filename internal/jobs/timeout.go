@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/logging"
+)
+
+// fallbackTimeout bounds the runs of jobs without a timeout until
+// SetTimeouts is called
+const fallbackTimeout = time.Hour
+
+// SetTimeouts sets the timeout of jobs that set none and the longest
+// timeout a job may run with (zero for no maximum), see
+// config.AdvancedConfig
+func (m *Manager) SetTimeouts(defaultTimeout, maxTimeout time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.defaultTimeout = defaultTimeout
+	m.maxTimeout = maxTimeout
+}
+
+// timeout returns the timeout a run of the job gets: its own, or the
+// default if it sets none, capped at the maximum
+func (m *Manager) timeout(ctx context.Context, jobConfig config.JobConfig) time.Duration {
+	m.mutex.RLock()
+	defaultTimeout, maxTimeout := m.defaultTimeout, m.maxTimeout
+	m.mutex.RUnlock()
+
+	timeout := jobConfig.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+		if timeout <= 0 {
+			timeout = fallbackTimeout
+		}
+	}
+	if maxTimeout > 0 && timeout > maxTimeout {
+		logging.FromContext(ctx).WithField(logging.JobField, jobConfig.Name).Warnf(
+			"Timeout %s of job %s exceeds the maximum, running with %s", timeout, jobConfig.Name, maxTimeout)
+		timeout = maxTimeout
+	}
+	return timeout
+}
+
+// JobTimeout returns the timeout the runs of a job get, or zero if the job
+// is not configured
+func (m *Manager) JobTimeout(jobName string) time.Duration {
+	job, ok := m.GetJob(jobName)
+	if !ok {
+		return 0
+	}
+	return m.timeout(context.Background(), job.GetConfig())
+}
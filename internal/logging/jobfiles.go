@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// unsafeFileChars are the characters of job names replaced in log file
+// names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// JobFiles is a logrus hook copying every log line about a job, that is
+// with a job field, to a log file of its own in a directory
+type JobFiles struct {
+	dir   string
+	mutex sync.Mutex
+	files map[string]*os.File
+}
+
+// AddJobFiles creates the directory and starts copying the log lines of
+// the standard logger about jobs to <dir>/<job>.log
+func AddJobFiles(dir string) (*JobFiles, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create job log directory: %v", err)
+	}
+
+	hook := &JobFiles{dir: dir, files: make(map[string]*os.File)}
+	logrus.AddHook(hook)
+	return hook, nil
+}
+
+// Levels implements logrus.Hook
+func (h *JobFiles) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (h *JobFiles) Fire(entry *logrus.Entry) error {
+	job, ok := entry.Data[JobField].(string)
+	if !ok || job == "" {
+		return nil
+	}
+
+	line, err := entry.Bytes()
+	if err != nil {
+		return err
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.files == nil {
+		return nil // Closed
+	}
+	file, err := h.file(job)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(line)
+	return err
+}
+
+// file returns the open log file of a job. It must be called with the
+// lock held.
+func (h *JobFiles) file(job string) (*os.File, error) {
+	if file, ok := h.files[job]; ok {
+		return file, nil
+	}
+
+	path := filepath.Join(h.dir, unsafeFileChars.ReplaceAllString(job, "_")+".log")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job log file: %v", err)
+	}
+	h.files[job] = file
+	return file, nil
+}
+
+// Close closes the open log files; later log lines are no longer copied
+func (h *JobFiles) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var firstErr error
+	for _, file := range h.files {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	h.files = nil
+	return firstErr
+}
@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Fields every log line about a run carries, so the lines of one run can
+// be found across the scheduler, job manager, storage and alerts
+const (
+	CorrelationField = "correlation_id"
+	JobField         = "job"
+	ExecutionField   = "execution_id"
+)
+
+type correlationKey struct{}
+
+// NewCorrelationID returns a random ID for the log lines of one run
+func NewCorrelationID() string {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		// The system's random source failing is unrecoverable
+		panic(fmt.Sprintf("failed to generate correlation ID: %v", err))
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// WithCorrelationID returns a context carrying a correlation ID
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the correlation ID of a context, or an empty string
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// FromContext returns a log entry with the correlation ID of a context
+func FromContext(ctx context.Context) *logrus.Entry {
+	return WithCorrelation(CorrelationID(ctx))
+}
+
+// WithCorrelation returns a log entry with a correlation ID, or without
+// fields if the ID is empty
+func WithCorrelation(id string) *logrus.Entry {
+	if id == "" {
+		return logrus.NewEntry(logrus.StandardLogger())
+	}
+	return logrus.WithField(CorrelationField, id)
+}
+
+// ForJob returns a log entry for a job, with a correlation ID if there is
+// one
+func ForJob(job, correlationID string) *logrus.Entry {
+	return WithCorrelation(correlationID).WithField(JobField, job)
+}
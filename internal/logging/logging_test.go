@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCorrelationID(t *testing.T) {
+	if id := CorrelationID(context.Background()); id != "" {
+		t.Errorf("CorrelationID() without ID = %q, want empty", id)
+	}
+
+	id := NewCorrelationID()
+	if len(id) != 16 || id == NewCorrelationID() {
+		t.Errorf("NewCorrelationID() = %q, want 16 random hex characters", id)
+	}
+	ctx := WithCorrelationID(context.Background(), id)
+	if got := CorrelationID(ctx); got != id {
+		t.Errorf("CorrelationID() = %q, want %q", got, id)
+	}
+	if got := FromContext(ctx).Data[CorrelationField]; got != id {
+		t.Errorf("FromContext() correlation field = %v, want %q", got, id)
+	}
+	if _, ok := FromContext(context.Background()).Data[CorrelationField]; ok {
+		t.Error("FromContext() without ID has a correlation field")
+	}
+}
+
+func TestJobFiles(t *testing.T) {
+	logger := logrus.StandardLogger()
+	hooks, output := logger.Hooks, logger.Out
+	defer func() {
+		logger.ReplaceHooks(hooks)
+		logger.SetOutput(output)
+	}()
+	logger.ReplaceHooks(make(logrus.LevelHooks))
+	logger.SetOutput(&strings.Builder{})
+
+	dir := filepath.Join(t.TempDir(), "jobs")
+	jobFiles, err := AddJobFiles(dir)
+	if err != nil {
+		t.Fatalf("AddJobFiles() error = %v", err)
+	}
+
+	ForJob("backup/db", "abc").Info("first run")
+	ForJob("report", "").Info("report run")
+	logrus.Info("not about a job")
+	ForJob("backup/db", "def").Info("second run")
+	if err := jobFiles.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	ForJob("backup/db", "ghi").Info("after close")
+
+	data, err := os.ReadFile(filepath.Join(dir, "backup_db.log"))
+	if err != nil {
+		t.Fatalf("Failed to read job log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "correlation_id=abc") || !strings.Contains(lines[1], "second run") {
+		t.Errorf("backup/db log = %q, want its two runs with their correlation IDs", lines)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("job log directory has %d files, want one per job", len(entries))
+	}
+}
+
+func TestSetup(t *testing.T) {
+	logger := logrus.StandardLogger()
+	hooks, output, formatter, level := logger.Hooks, logger.Out, logger.Formatter, logger.GetLevel()
+	defer func() {
+		logger.ReplaceHooks(hooks)
+		logger.SetOutput(output)
+		logger.SetFormatter(formatter)
+		logger.SetLevel(level)
+	}()
+
+	path := filepath.Join(t.TempDir(), "arcron.log")
+	closeLogs, err := Setup(config.LoggingConfig{Level: "warn", Format: FormatJSON, OutputFile: path, MaxSizeMB: 1})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	logrus.Info("dropped")
+	logrus.Warn("kept")
+	if err := SetLevel("debug"); err != nil || Level() != "debug" {
+		t.Errorf("SetLevel(debug) = %v, level %s", err, Level())
+	}
+	if err := SetLevel("verbose"); err == nil {
+		t.Error("SetLevel(verbose) succeeded")
+	}
+	if err := closeLogs(); err != nil {
+		t.Fatalf("closing logs error = %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"msg":"kept"`) || strings.Contains(string(data), "dropped") {
+		t.Errorf("log file = %q, want the warning as JSON only", data)
+	}
+
+	if _, err := Setup(config.LoggingConfig{Format: "xml"}); err == nil {
+		t.Error("Setup() with an unknown format succeeded")
+	}
+	cfg := &config.Config{Logging: config.LoggingConfig{Level: "loud", Format: FormatText, MaxBackups: -1}}
+	if problems := CheckConfig(cfg); len(problems) != 2 {
+		t.Errorf("CheckConfig() = %v, want the level and the negative limit", problems)
+	}
+}
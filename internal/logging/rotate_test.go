@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "arcron.log")
+	file, err := OpenRotatingFile(path, 10, 0, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() error = %v", err)
+	}
+	defer file.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		time.Sleep(2 * time.Millisecond) // Distinct backup names
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "fourth\n" {
+		t.Errorf("log file = %q, %v, want only the last line", data, err)
+	}
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 2 {
+		t.Fatalf("backups = %v, want the newest 2", backups)
+	}
+	if data, _ := os.ReadFile(backups[0]); string(data) != "second\n" {
+		t.Errorf("oldest kept backup = %q, want the second line", data)
+	}
+}
+
+func TestRotatingFileRotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arcron.log")
+	if err := os.WriteFile(path, []byte("before restart\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := OpenRotatingFile(path, 0, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile() error = %v", err)
+	}
+	defer file.Close()
+
+	file.Write([]byte("appended\n"))
+	file.opened = time.Now().Add(-2 * time.Hour)
+	file.Write([]byte("rotated\n"))
+
+	data, _ := os.ReadFile(path)
+	backups, _ := filepath.Glob(path + ".*")
+	if string(data) != "rotated\n" || len(backups) != 1 {
+		t.Fatalf("log file = %q with backups %v, want a new file after an hour", data, backups)
+	}
+	if data, _ := os.ReadFile(backups[0]); !strings.HasPrefix(string(data), "before restart\nappended\n") {
+		t.Errorf("backup = %q, want the appended old file", data)
+	}
+}
@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Log formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Setup configures the standard logger: its level and format, the output
+// file, rotated by size and age, and the per-job log files. Without an
+// output file logs go to stderr. The returned function closes the files.
+func Setup(cfg config.LoggingConfig) (func() error, error) {
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	formatter, err := newFormatter(cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	var closers []io.Closer
+	closeAll := func() error {
+		if cfg.OutputFile != "" {
+			logrus.SetOutput(os.Stderr)
+		}
+		var firstErr error
+		for _, closer := range closers {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+
+	if cfg.OutputFile != "" {
+		file, err := OpenRotatingFile(cfg.OutputFile, int64(cfg.MaxSizeMB)<<20, cfg.MaxAge, cfg.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		closers = append(closers, file)
+		logrus.SetOutput(file)
+	}
+	if cfg.JobLogDir != "" {
+		jobFiles, err := AddJobFiles(cfg.JobLogDir)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		closers = append(closers, jobFiles)
+	}
+
+	logrus.SetLevel(level)
+	logrus.SetFormatter(formatter)
+	return closeAll, nil
+}
+
+// SetLevel changes the level of the standard logger at runtime
+func SetLevel(name string) error {
+	level, err := parseLevel(name)
+	if err != nil {
+		return err
+	}
+	logrus.SetLevel(level)
+	logrus.Infof("Log level set to %s", level)
+	return nil
+}
+
+// Level returns the level of the standard logger
+func Level() string {
+	return logrus.GetLevel().String()
+}
+
+// CheckConfig checks the logging settings of a configuration, see
+// config.Validate
+func CheckConfig(cfg *config.Config) []error {
+	var problems []error
+	if _, err := parseLevel(cfg.Logging.Level); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := newFormatter(cfg.Logging.Format); err != nil {
+		problems = append(problems, err)
+	}
+	if cfg.Logging.MaxSizeMB < 0 || cfg.Logging.MaxAge < 0 || cfg.Logging.MaxBackups < 0 {
+		problems = append(problems, fmt.Errorf("logging: max_size_mb, max_age and max_backups cannot be negative"))
+	}
+	return problems
+}
+
+// parseLevel parses a log level, info if empty
+func parseLevel(name string) (logrus.Level, error) {
+	if name == "" {
+		return logrus.InfoLevel, nil
+	}
+	level, err := logrus.ParseLevel(name)
+	if err != nil {
+		return 0, fmt.Errorf("unknown log level %q, use trace, debug, info, warn, error, fatal or panic", name)
+	}
+	return level, nil
+}
+
+// newFormatter returns the formatter of a log format, JSON if empty
+func newFormatter(format string) (logrus.Formatter, error) {
+	switch format {
+	case "", FormatJSON:
+		return &logrus.JSONFormatter{}, nil
+	case FormatText:
+		return &logrus.TextFormatter{FullTimestamp: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, use %s or %s", format, FormatJSON, FormatText)
+	}
+}
@@ -2,19 +2,22 @@ package metrics
 
 import (
 	"fmt"
+	"net"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/makalin/arcron/internal/config"
 	"github.com/makalin/arcron/internal/jobs"
 	"github.com/makalin/arcron/internal/monitoring"
 	"github.com/makalin/arcron/internal/scheduler"
+	"github.com/makalin/arcron/internal/telemetry"
 	"github.com/sirupsen/logrus"
 )
 
 // Exporter exports Prometheus metrics
 type Exporter struct {
-	config    *config.Config
+	config     *config.Config
 	jobManager *jobs.Manager
 	scheduler  *scheduler.Scheduler
 	monitor    *monitoring.Monitor
@@ -22,9 +25,9 @@ type Exporter struct {
 }
 
 // NewExporter creates a new Prometheus metrics exporter
-func NewExporter(cfg *config.Config, jobManager *jobs.Manager, 
+func NewExporter(cfg *config.Config, jobManager *jobs.Manager,
 	scheduler *scheduler.Scheduler, monitor *monitoring.Monitor) *Exporter {
-	
+
 	return &Exporter{
 		config:     cfg,
 		jobManager: jobManager,
@@ -52,8 +55,13 @@ func (e *Exporter) Start() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc(path, e.handleMetrics)
 
+	// Listen before returning so a port in use fails startup
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("failed to start Prometheus metrics server: %v", err)
+	}
+
 	e.server = &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
 		Handler:      mux,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
@@ -61,7 +69,7 @@ func (e *Exporter) Start() error {
 
 	go func() {
 		logrus.Infof("Starting Prometheus metrics server on :%d%s", port, path)
-		if err := e.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := e.server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			logrus.Errorf("Prometheus metrics server error: %v", err)
 		}
 	}()
@@ -95,24 +103,78 @@ func (e *Exporter) handleMetrics(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "# HELP arcron_load_average System load average\n")
 		fmt.Fprintf(w, "# TYPE arcron_load_average gauge\n")
 		fmt.Fprintf(w, "arcron_load_average %.2f\n", metrics.LoadAvg.Load1)
+
+		// Per-device I/O
+		fmt.Fprintf(w, "# HELP arcron_disk_read_bytes_total Bytes read per disk\n")
+		fmt.Fprintf(w, "# TYPE arcron_disk_read_bytes_total counter\n")
+		for device, io := range metrics.Disks {
+			fmt.Fprintf(w, "arcron_disk_read_bytes_total{device=\"%s\"} %d\n", device, io.ReadBytes)
+		}
+		fmt.Fprintf(w, "# HELP arcron_disk_written_bytes_total Bytes written per disk\n")
+		fmt.Fprintf(w, "# TYPE arcron_disk_written_bytes_total counter\n")
+		for device, io := range metrics.Disks {
+			fmt.Fprintf(w, "arcron_disk_written_bytes_total{device=\"%s\"} %d\n", device, io.WriteBytes)
+		}
+		fmt.Fprintf(w, "# HELP arcron_network_receive_bytes_total Bytes received per interface\n")
+		fmt.Fprintf(w, "# TYPE arcron_network_receive_bytes_total counter\n")
+		for iface, io := range metrics.Interfaces {
+			fmt.Fprintf(w, "arcron_network_receive_bytes_total{interface=\"%s\"} %d\n", iface, io.BytesRecv)
+		}
+		fmt.Fprintf(w, "# HELP arcron_network_transmit_bytes_total Bytes sent per interface\n")
+		fmt.Fprintf(w, "# TYPE arcron_network_transmit_bytes_total counter\n")
+		for iface, io := range metrics.Interfaces {
+			fmt.Fprintf(w, "arcron_network_transmit_bytes_total{interface=\"%s\"} %d\n", iface, io.BytesSent)
+		}
+
+		if len(metrics.GPUs) > 0 {
+			fmt.Fprintf(w, "# HELP arcron_gpu_utilization GPU utilization percentage\n")
+			fmt.Fprintf(w, "# TYPE arcron_gpu_utilization gauge\n")
+			for _, gpu := range metrics.GPUs {
+				fmt.Fprintf(w, "arcron_gpu_utilization{gpu=\"%d\",name=\"%s\"} %.2f\n", gpu.Index, gpu.Name, gpu.Utilization)
+			}
+			fmt.Fprintf(w, "# HELP arcron_gpu_memory_used_bytes GPU memory in use\n")
+			fmt.Fprintf(w, "# TYPE arcron_gpu_memory_used_bytes gauge\n")
+			for _, gpu := range metrics.GPUs {
+				fmt.Fprintf(w, "arcron_gpu_memory_used_bytes{gpu=\"%d\",name=\"%s\"} %d\n", gpu.Index, gpu.Name, gpu.MemoryUsed)
+			}
+		}
+
+		if metrics.Temperatures != nil {
+			fmt.Fprintf(w, "# HELP arcron_temperature_celsius Hottest sensor temperature\n")
+			fmt.Fprintf(w, "# TYPE arcron_temperature_celsius gauge\n")
+			fmt.Fprintf(w, "arcron_temperature_celsius{sensor=\"cpu\"} %.1f\n", metrics.Temperatures.CPU)
+			fmt.Fprintf(w, "arcron_temperature_celsius{sensor=\"disk\"} %.1f\n", metrics.Temperatures.Disk)
+		}
 	}
 
-	// Job metrics
+	// Job metrics, by namespace
 	allJobs := e.jobManager.GetAllJobs()
-	fmt.Fprintf(w, "# HELP arcron_jobs_total Total number of jobs\n")
-	fmt.Fprintf(w, "# TYPE arcron_jobs_total gauge\n")
-	fmt.Fprintf(w, "arcron_jobs_total %d\n", len(allJobs))
-
-	runningJobs := 0
+	total := make(map[string]int)
+	running := make(map[string]int)
 	for _, job := range allJobs {
+		namespace := job.GetNamespace()
+		total[namespace]++
 		if job.GetStatus() == "running" {
-			runningJobs++
+			running[namespace]++
 		}
 	}
+	namespaces := make([]string, 0, len(total))
+	for namespace := range total {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	fmt.Fprintf(w, "# HELP arcron_jobs_total Total number of jobs\n")
+	fmt.Fprintf(w, "# TYPE arcron_jobs_total gauge\n")
+	for _, namespace := range namespaces {
+		fmt.Fprintf(w, "arcron_jobs_total{namespace=\"%s\"} %d\n", namespace, total[namespace])
+	}
 
 	fmt.Fprintf(w, "# HELP arcron_jobs_running Number of running jobs\n")
 	fmt.Fprintf(w, "# TYPE arcron_jobs_running gauge\n")
-	fmt.Fprintf(w, "arcron_jobs_running %d\n", runningJobs)
+	for _, namespace := range namespaces {
+		fmt.Fprintf(w, "arcron_jobs_running{namespace=\"%s\"} %d\n", namespace, running[namespace])
+	}
 
 	// Scheduler metrics
 	schedulerStatus := e.scheduler.GetStatus()
@@ -128,10 +190,12 @@ func (e *Exporter) handleMetrics(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "# HELP arcron_job_status Job status (1=running, 0=not running)\n")
 		fmt.Fprintf(w, "# TYPE arcron_job_status gauge\n")
 		if status == "running" {
-			fmt.Fprintf(w, "arcron_job_status{job=\"%s\"} 1\n", name)
+			fmt.Fprintf(w, "arcron_job_status{job=\"%s\",namespace=\"%s\"} 1\n", name, job.GetNamespace())
 		} else {
-			fmt.Fprintf(w, "arcron_job_status{job=\"%s\"} 0\n", name)
+			fmt.Fprintf(w, "arcron_job_status{job=\"%s\",namespace=\"%s\"} 0\n", name, job.GetNamespace())
 		}
 	}
-}
 
+	// Arcron internal metrics
+	telemetry.WritePrometheus(w)
+}
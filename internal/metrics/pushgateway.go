@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/sirupsen/logrus"
+)
+
+// Pusher pushes job completion metrics to a Prometheus Pushgateway, for
+// hosts whose metrics port cannot be scraped
+type Pusher struct {
+	config     config.PushgatewayConfig
+	jobManager *jobs.Manager
+	client     *http.Client
+	pushes     sync.WaitGroup
+}
+
+// NewPusher creates a new Pushgateway pusher
+func NewPusher(cfg config.PushgatewayConfig, jobManager *jobs.Manager) *Pusher {
+	return &Pusher{
+		config:     cfg,
+		jobManager: jobManager,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}
+}
+
+// Start registers the pusher for finished executions
+func (p *Pusher) Start() error {
+	if !p.config.Enabled {
+		return nil
+	}
+
+	if p.config.URL == "" {
+		return fmt.Errorf("pushgateway URL not configured")
+	}
+
+	p.jobManager.AddListener(func(execution *jobs.JobExecution) {
+		// Copy the execution so a later retry cannot change it mid-push
+		finished := *execution
+		p.pushes.Add(1)
+		go func() {
+			defer p.pushes.Done()
+			if err := p.push(&finished); err != nil {
+				logrus.Errorf("Failed to push metrics for job %s: %v", finished.JobName, err)
+			}
+		}()
+	})
+
+	logrus.Infof("Pushing job metrics to Pushgateway at %s", p.config.URL)
+	return nil
+}
+
+// Stop waits for the pushes in flight, so that the executions of a
+// one-shot run are pushed before arcron exits
+func (p *Pusher) Stop() {
+	p.pushes.Wait()
+}
+
+// push replaces the metric group of the execution's job
+func (p *Pusher) push(execution *jobs.JobExecution) error {
+	success := 0
+	if execution.Status == "completed" {
+		success = 1
+	}
+
+	var body bytes.Buffer
+	writeGauge(&body, "arcron_job_last_duration_seconds", "Duration of the last job execution", execution.Duration)
+	writeGauge(&body, "arcron_job_last_exit_code", "Exit code of the last job execution", float64(execution.ExitCode))
+	writeGauge(&body, "arcron_job_last_success", "Whether the last job execution succeeded (1) or failed (0)", float64(success))
+	writeGauge(&body, "arcron_job_last_completion_timestamp_seconds", "Unix time the last job execution finished", float64(execution.EndTime.Unix()))
+
+	req, err := http.NewRequest(http.MethodPut, p.groupingURL(execution.JobName), &body)
+	if err != nil {
+		return fmt.Errorf("failed to create push request: %v", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	if p.config.Username != "" {
+		req.SetBasicAuth(p.config.Username, p.config.Password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("pushgateway returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// groupingURL builds the Pushgateway URL grouping metrics by job,
+// instance and arcron job name
+func (p *Pusher) groupingURL(jobName string) string {
+	return strings.TrimSuffix(p.config.URL, "/") + "/metrics" +
+		groupingLabel("job", p.config.Job) +
+		groupingLabel("instance", p.config.Instance) +
+		groupingLabel("arcron_job", jobName)
+}
+
+// groupingLabel renders a /name/value grouping path segment. Values
+// containing a slash use the base64 form understood by the Pushgateway.
+func groupingLabel(name, value string) string {
+	if value == "" {
+		return ""
+	}
+	if strings.Contains(value, "/") {
+		return fmt.Sprintf("/%s@base64/%s", name, base64.RawURLEncoding.EncodeToString([]byte(value)))
+	}
+	return fmt.Sprintf("/%s/%s", name, url.PathEscape(value))
+}
+
+// writeGauge writes a single gauge sample in the Prometheus text format
+func writeGauge(buf *bytes.Buffer, name, help string, value float64) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(buf, "%s %g\n", name, value)
+}
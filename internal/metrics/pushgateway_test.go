@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+// pushRecorder records the pushes a test Pushgateway receives
+type pushRecorder struct {
+	mutex  sync.Mutex
+	pushes []string
+}
+
+func (p *pushRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	p.mutex.Lock()
+	p.pushes = append(p.pushes, r.Method+" "+r.URL.EscapedPath()+"\n"+string(body))
+	p.mutex.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (p *pushRecorder) get() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]string(nil), p.pushes...)
+}
+
+func TestPusherPushesFinishedExecutions(t *testing.T) {
+	recorder := &pushRecorder{}
+	gateway := httptest.NewServer(recorder)
+	defer gateway.Close()
+
+	jobManager := newJobManager(t, config.JobConfig{Name: "backup", Command: "false", Schedule: "@daily"})
+	pusher := NewPusher(config.PushgatewayConfig{
+		Enabled:  true,
+		URL:      gateway.URL + "/",
+		Job:      "arcron",
+		Instance: "web-1",
+		Timeout:  5 * time.Second,
+	}, jobManager)
+	if err := pusher.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	runJob(t, jobManager, "backup")
+	// Stop waits for the push in flight
+	pusher.Stop()
+
+	pushes := recorder.get()
+	if len(pushes) != 1 {
+		t.Fatalf("pushes = %q, want one", pushes)
+	}
+	if !strings.HasPrefix(pushes[0], "PUT /metrics/job/arcron/instance/web-1/arcron_job/backup\n") {
+		t.Errorf("push = %q, want the job's group replaced", pushes[0])
+	}
+	if !strings.Contains(pushes[0], "\narcron_job_last_success 0\n") || !strings.Contains(pushes[0], "\narcron_job_last_exit_code 1\n") {
+		t.Errorf("push = %q, want the failure pushed", pushes[0])
+	}
+}
+
+func TestPusherDisabled(t *testing.T) {
+	recorder := &pushRecorder{}
+	gateway := httptest.NewServer(recorder)
+	defer gateway.Close()
+
+	jobManager := newJobManager(t, config.JobConfig{Name: "backup", Command: "true", Schedule: "@daily"})
+	pusher := NewPusher(config.PushgatewayConfig{URL: gateway.URL}, jobManager)
+	if err := pusher.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	runJob(t, jobManager, "backup")
+	pusher.Stop()
+
+	if pushes := recorder.get(); len(pushes) != 0 {
+		t.Errorf("pushes = %q, want none while disabled", pushes)
+	}
+	if err := NewPusher(config.PushgatewayConfig{Enabled: true}, jobManager).Start(); err == nil {
+		t.Error("Start() without a URL succeeded")
+	}
+}
+
+func TestGroupingLabel(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"web 1", "/instance/web%201"},
+		{"a/b", "/instance@base64/YS9i"},
+	}
+	for _, tt := range tests {
+		if got := groupingLabel("instance", tt.value); got != tt.want {
+			t.Errorf("groupingLabel(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/monitoring"
+	"github.com/sirupsen/logrus"
+)
+
+// StatsDEmitter pushes job and system metrics to a StatsD server using
+// DogStatsD tags
+type StatsDEmitter struct {
+	config     config.StatsDConfig
+	jobManager *jobs.Manager
+	monitor    *monitoring.Monitor
+	conn       net.Conn
+	stopChan   chan struct{}
+}
+
+// NewStatsDEmitter creates a new StatsD emitter
+func NewStatsDEmitter(cfg config.StatsDConfig, jobManager *jobs.Manager, monitor *monitoring.Monitor) *StatsDEmitter {
+	return &StatsDEmitter{
+		config:     cfg,
+		jobManager: jobManager,
+		monitor:    monitor,
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// Start connects to the StatsD server and starts emitting metrics
+func (e *StatsDEmitter) Start(ctx context.Context) error {
+	if !e.config.Enabled {
+		return nil
+	}
+
+	conn, err := net.Dial("udp", e.config.Address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to StatsD at %s: %v", e.config.Address, err)
+	}
+	e.conn = conn
+
+	e.jobManager.AddListener(e.emitExecution)
+	go e.emitSystemMetrics(ctx)
+
+	logrus.Infof("Emitting StatsD metrics to %s", e.config.Address)
+	return nil
+}
+
+// Stop stops emitting metrics
+func (e *StatsDEmitter) Stop() error {
+	if e.conn == nil {
+		return nil
+	}
+	close(e.stopChan)
+	return e.conn.Close()
+}
+
+// emitExecution emits duration and status metrics for a finished execution
+func (e *StatsDEmitter) emitExecution(execution *jobs.JobExecution) {
+	tags := []string{
+		"job:" + execution.JobName,
+		"status:" + string(execution.Status),
+	}
+
+	e.send("job.duration", fmt.Sprintf("%d", int64(execution.Duration*1000)), "ms", tags)
+	e.send("job.executions", "1", "c", tags)
+}
+
+// emitSystemMetrics periodically emits the latest system metrics as gauges
+func (e *StatsDEmitter) emitSystemMetrics(ctx context.Context) {
+	if e.config.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.stopChan:
+			return
+		case <-ticker.C:
+			metrics := e.monitor.GetLastMetrics()
+			if metrics == nil {
+				continue
+			}
+			e.send("system.cpu_usage", fmt.Sprintf("%.2f", metrics.CPUUsage), "g", nil)
+			e.send("system.memory_usage", fmt.Sprintf("%.2f", metrics.MemoryUsage), "g", nil)
+			e.send("system.load_average", fmt.Sprintf("%.2f", metrics.LoadAvg.Load1), "g", nil)
+
+			running := 0
+			for _, job := range e.jobManager.GetAllJobs() {
+				if job.GetStatus() == "running" {
+					running++
+				}
+			}
+			e.send("jobs.running", fmt.Sprintf("%d", running), "g", nil)
+		}
+	}
+}
+
+// send writes a single metric line in the DogStatsD format:
+// <prefix><name>:<value>|<type>|#tag1,tag2
+func (e *StatsDEmitter) send(name, value, metricType string, tags []string) {
+	line := fmt.Sprintf("%s%s:%s|%s", e.config.Prefix, name, value, metricType)
+
+	allTags := append(append([]string{}, e.config.Tags...), tags...)
+	if len(allTags) > 0 {
+		line += "|#" + strings.Join(allTags, ",")
+	}
+
+	if _, err := e.conn.Write([]byte(line)); err != nil {
+		logrus.Debugf("Failed to send StatsD metric %s: %v", name, err)
+	}
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/storage"
+)
+
+// newJobManager creates a job manager for the jobs on a temporary SQLite
+// database
+func newJobManager(t *testing.T, jobConfigs ...config.JobConfig) *jobs.Manager {
+	t.Helper()
+
+	store, err := storage.New(config.DatabaseConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "arcron.db"), MaxConns: 1})
+	if err != nil {
+		t.Fatalf("storage.New() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	jobManager, err := jobs.New(jobConfigs, config.SecurityConfig{}, store)
+	if err != nil {
+		t.Fatalf("jobs.New() error = %v", err)
+	}
+	t.Cleanup(jobManager.Stop)
+	return jobManager
+}
+
+// runJob executes a job of the manager by name
+func runJob(t *testing.T, jobManager *jobs.Manager, name string) {
+	t.Helper()
+
+	job, ok := jobManager.GetJob(name)
+	if !ok {
+		t.Fatalf("job %s not found", name)
+	}
+	jobManager.ExecuteJob(context.Background(), job)
+}
+
+func TestStatsDEmitsExecutions(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() error = %v", err)
+	}
+	defer server.Close()
+
+	jobManager := newJobManager(t, config.JobConfig{Name: "backup", Command: "true", Schedule: "@daily"})
+	emitter := NewStatsDEmitter(config.StatsDConfig{
+		Enabled: true,
+		Address: server.LocalAddr().String(),
+		Prefix:  "arcron.",
+		Tags:    []string{"env:test"},
+	}, jobManager, nil)
+	if err := emitter.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer emitter.Stop()
+
+	runJob(t, jobManager, "backup")
+
+	var lines []string
+	buf := make([]byte, 1024)
+	for len(lines) < 2 {
+		server.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := server.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("ReadFrom() error = %v, got %q", err, lines)
+		}
+		lines = append(lines, string(buf[:n]))
+	}
+
+	if !strings.HasPrefix(lines[0], "arcron.job.duration:") || !strings.HasSuffix(lines[0], "|ms|#env:test,job:backup,status:completed") {
+		t.Errorf("duration metric = %q", lines[0])
+	}
+	if lines[1] != "arcron.job.executions:1|c|#env:test,job:backup,status:completed" {
+		t.Errorf("executions metric = %q", lines[1])
+	}
+}
+
+func TestStatsDDisabled(t *testing.T) {
+	emitter := NewStatsDEmitter(config.StatsDConfig{Address: "127.0.0.1:1"}, newJobManager(t), nil)
+	if err := emitter.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if emitter.conn != nil {
+		t.Error("disabled emitter connected")
+	}
+	if err := emitter.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package ml
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// evaluationDelay gives the metrics collected around a prediction's
+	// optimal time a chance to be stored before it is evaluated
+	evaluationDelay = 5 * time.Minute
+
+	// evaluationInterval is how often pending predictions are evaluated
+	evaluationInterval = 5 * time.Minute
+
+	// loadSampleWindow is how far around the optimal time metrics are
+	// averaged into the actual load
+	loadSampleWindow = time.Minute
+
+	// executionWindow is how far from the optimal time a job execution
+	// still counts as the predicted run
+	executionWindow = 5 * time.Minute
+
+	// accuracyTolerance is the absolute load error within which a
+	// prediction counts as accurate
+	accuracyTolerance = 10.0
+
+	// maxEvaluationBatch bounds the predictions evaluated per pass
+	maxEvaluationBatch = 500
+)
+
+// PredictionStore persists predictions and their outcomes and looks up the
+// metrics and executions to evaluate them against, see storage.Storage and
+// storage.MemoryStore
+type PredictionStore interface {
+	storage.PredictionRepo
+	GetSystemMetrics(start, end time.Time, limit int) ([]*types.SystemMetrics, error)
+	GetExecutionNear(jobName string, at time.Time, window time.Duration) (*types.JobExecution, error)
+}
+
+// AccuracyTracker records predictions, evaluates them against the load and
+// job outcome observed at the predicted time, and decides which jobs the
+// model underperforms for
+type AccuracyTracker struct {
+	store    PredictionStore
+	config   config.MLConfig
+	mutex    sync.RWMutex
+	fallback map[string]bool
+}
+
+// NewAccuracyTracker creates a new accuracy tracker
+func NewAccuracyTracker(store PredictionStore, cfg config.MLConfig) *AccuracyTracker {
+	return &AccuracyTracker{
+		store:    store,
+		config:   cfg,
+		fallback: make(map[string]bool),
+	}
+}
+
+// Record stores a prediction for later evaluation
+func (at *AccuracyTracker) Record(prediction *Prediction) {
+	if err := at.store.StoreMLPrediction(prediction); err != nil {
+		logrus.Errorf("Failed to store prediction for job %s: %v", prediction.JobName, err)
+	}
+}
+
+// RecordAll stores a batch of predictions for later evaluation
+func (at *AccuracyTracker) RecordAll(predictions []*Prediction) {
+	if err := at.store.StoreMLPredictions(predictions); err != nil {
+		logrus.Errorf("Failed to store %d predictions: %v", len(predictions), err)
+	}
+}
+
+// RecordForecast stores a load forecast for later evaluation
+func (at *AccuracyTracker) RecordForecast(forecast *types.LoadForecast) {
+	if err := at.store.StoreForecast(forecast); err != nil {
+		logrus.Errorf("Failed to store load forecast: %v", err)
+	}
+}
+
+// Evaluate compares pending predictions whose optimal time has passed with
+// what actually happened and refreshes the jobs that fall back to the
+// heuristics
+func (at *AccuracyTracker) Evaluate(now time.Time) error {
+	pending, err := at.store.GetPendingPredictions(now.Add(-evaluationDelay), maxEvaluationBatch)
+	if err != nil {
+		return err
+	}
+
+	for _, prediction := range pending {
+		outcome, err := at.evaluate(prediction, now)
+		if err != nil {
+			logrus.Errorf("Failed to evaluate prediction %d: %v", prediction.ID, err)
+			continue
+		}
+		if err := at.store.StorePredictionOutcome(outcome); err != nil {
+			logrus.Errorf("Failed to store outcome of prediction %d: %v", prediction.ID, err)
+		}
+	}
+
+	points, err := at.store.GetPendingForecastPoints(now.Add(-evaluationDelay), maxEvaluationBatch)
+	if err != nil {
+		return err
+	}
+
+	for _, point := range points {
+		actual, err := at.actualLoad(point.Time)
+		if err != nil {
+			logrus.Errorf("Failed to evaluate forecast point %d: %v", point.ID, err)
+			continue
+		}
+		outcome := &types.ForecastOutcome{ForecastPoint: *point, ActualLoad: actual, EvaluatedAt: now}
+		if err := at.store.StoreForecastOutcome(outcome); err != nil {
+			logrus.Errorf("Failed to store outcome of forecast point %d: %v", point.ID, err)
+		}
+	}
+
+	accuracy, err := at.Accuracy(now)
+	if err != nil {
+		return err
+	}
+	at.refreshFallback(accuracy)
+	return nil
+}
+
+// actualLoad returns the average combined load measured around the given
+// time, or -1 if no samples were collected then
+func (at *AccuracyTracker) actualLoad(when time.Time) (float64, error) {
+	samples, err := at.store.GetSystemMetrics(when.Add(-loadSampleWindow), when.Add(loadSampleWindow), 0)
+	if err != nil {
+		return 0, err
+	}
+	if len(samples) == 0 {
+		return -1, nil
+	}
+
+	var total float64
+	for _, sample := range samples {
+		total += CombinedLoad(sample)
+	}
+	return total / float64(len(samples)), nil
+}
+
+// evaluate builds the outcome of a single prediction
+func (at *AccuracyTracker) evaluate(prediction *Prediction, now time.Time) (*types.PredictionOutcome, error) {
+	actual, err := at.actualLoad(prediction.OptimalTime)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without samples there is nothing to compare against; the prediction
+	// is still marked as evaluated so it is not retried, but negative
+	// values keep it out of the error statistics
+	outcome := &types.PredictionOutcome{
+		Prediction:  *prediction,
+		ActualLoad:  actual,
+		AbsError:    -1,
+		EvaluatedAt: now,
+	}
+	if actual >= 0 {
+		outcome.AbsError = math.Abs(actual - prediction.ExpectedLoad)
+	}
+
+	execution, err := at.store.GetExecutionNear(prediction.JobName, prediction.OptimalTime, executionWindow)
+	if err != nil {
+		return nil, err
+	}
+	if execution != nil {
+		outcome.JobStatus = string(execution.Status)
+	}
+
+	return outcome, nil
+}
+
+// Accuracy returns the rolling accuracy of every job and method over the
+// configured window
+func (at *AccuracyTracker) Accuracy(now time.Time) ([]*types.PredictionAccuracy, error) {
+	outcomes, err := at.store.GetPredictionOutcomes(now.Add(-at.config.AccuracyWindow))
+	if err != nil {
+		return nil, err
+	}
+	return summarizeOutcomes(outcomes), nil
+}
+
+// ForecastAccuracy returns the rolling accuracy of load forecasts over the
+// configured window
+func (at *AccuracyTracker) ForecastAccuracy(now time.Time) (*types.ForecastAccuracy, error) {
+	outcomes, err := at.store.GetForecastOutcomes(now.Add(-at.config.AccuracyWindow))
+	if err != nil {
+		return nil, err
+	}
+	return summarizeForecastOutcomes(outcomes), nil
+}
+
+// summarizeOutcomes computes accuracy statistics per job and method
+func summarizeOutcomes(outcomes []*types.PredictionOutcome) []*types.PredictionAccuracy {
+	type key struct{ job, method string }
+	type totals struct {
+		evaluated, accurate, runs, succeeded int
+		errorSum                             float64
+	}
+
+	byKey := make(map[key]*totals)
+	for _, outcome := range outcomes {
+		k := key{outcome.JobName, outcome.Method}
+		t, ok := byKey[k]
+		if !ok {
+			t = &totals{}
+			byKey[k] = t
+		}
+
+		if outcome.AbsError >= 0 {
+			t.evaluated++
+			t.errorSum += outcome.AbsError
+			if outcome.AbsError <= accuracyTolerance {
+				t.accurate++
+			}
+		}
+		if outcome.JobStatus != "" {
+			t.runs++
+			if outcome.JobStatus == string(types.StatusCompleted) {
+				t.succeeded++
+			}
+		}
+	}
+
+	accuracy := make([]*types.PredictionAccuracy, 0, len(byKey))
+	for k, t := range byKey {
+		a := &types.PredictionAccuracy{
+			JobName:   k.job,
+			Method:    k.method,
+			Evaluated: t.evaluated,
+		}
+		if t.evaluated > 0 {
+			a.MAE = t.errorSum / float64(t.evaluated)
+			a.Accuracy = float64(t.accurate) / float64(t.evaluated)
+		}
+		if t.runs > 0 {
+			a.SuccessRate = float64(t.succeeded) / float64(t.runs)
+		}
+		accuracy = append(accuracy, a)
+	}
+
+	sort.Slice(accuracy, func(i, j int) bool {
+		if accuracy[i].JobName != accuracy[j].JobName {
+			return accuracy[i].JobName < accuracy[j].JobName
+		}
+		return accuracy[i].Method < accuracy[j].Method
+	})
+	return accuracy
+}
+
+// refreshFallback marks jobs whose model predictions have a mean absolute
+// error above the configured limit. A job returns to the model once its
+// poor predictions have aged out of the accuracy window.
+func (at *AccuracyTracker) refreshFallback(accuracy []*types.PredictionAccuracy) {
+	fallback := make(map[string]bool)
+	for _, a := range accuracy {
+		if a.Method != MethodModel || a.Evaluated < at.config.FallbackMinSamples {
+			continue
+		}
+		if a.MAE > at.config.FallbackMAE {
+			fallback[a.JobName] = true
+		}
+	}
+
+	at.mutex.Lock()
+	defer at.mutex.Unlock()
+
+	for job := range fallback {
+		if !at.fallback[job] {
+			logrus.Warnf("ML model underperforming for job %s, falling back to heuristics", job)
+		}
+	}
+	for job := range at.fallback {
+		if !fallback[job] {
+			logrus.Infof("ML model accuracy recovered for job %s", job)
+		}
+	}
+	at.fallback = fallback
+}
+
+// ShouldFallback reports whether predictions for a job should use the
+// heuristics instead of the model
+func (at *AccuracyTracker) ShouldFallback(jobName string) bool {
+	at.mutex.RLock()
+	defer at.mutex.RUnlock()
+	return at.fallback[jobName]
+}
+
+// FallbackJobs returns the jobs currently using the heuristics
+func (at *AccuracyTracker) FallbackJobs() []string {
+	at.mutex.RLock()
+	defer at.mutex.RUnlock()
+
+	jobs := make([]string, 0, len(at.fallback))
+	for job := range at.fallback {
+		jobs = append(jobs, job)
+	}
+	sort.Strings(jobs)
+	return jobs
+}
+
+// run periodically evaluates pending predictions
+func (at *AccuracyTracker) run(ctx context.Context, stopChan <-chan struct{}) {
+	ticker := time.NewTicker(evaluationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopChan:
+			return
+		case <-ticker.C:
+			if err := at.Evaluate(time.Now()); err != nil {
+				logrus.Errorf("Failed to evaluate predictions: %v", err)
+			}
+		}
+	}
+}
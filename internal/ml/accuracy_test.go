@@ -0,0 +1,63 @@
+package ml
+
+import (
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func testOutcome(job, method string, absError float64, status string) *types.PredictionOutcome {
+	return &types.PredictionOutcome{
+		Prediction: types.Prediction{JobName: job, Method: method},
+		AbsError:   absError,
+		JobStatus:  status,
+	}
+}
+
+func TestSummarizeOutcomes(t *testing.T) {
+	accuracy := summarizeOutcomes([]*types.PredictionOutcome{
+		testOutcome("backup", MethodModel, 4, "completed"),
+		testOutcome("backup", MethodModel, 20, "failed"),
+		testOutcome("backup", MethodModel, -1, ""), // no load samples
+		testOutcome("backup", MethodHeuristics, 6, "completed"),
+	})
+
+	if len(accuracy) != 2 {
+		t.Fatalf("summarizeOutcomes() returned %d entries, want 2", len(accuracy))
+	}
+
+	heuristics, model := accuracy[0], accuracy[1]
+	if heuristics.Method != MethodHeuristics || heuristics.MAE != 6 || heuristics.Accuracy != 1 {
+		t.Errorf("heuristics accuracy = %+v", heuristics)
+	}
+	if model.Evaluated != 2 || model.MAE != 12 || model.Accuracy != 0.5 || model.SuccessRate != 0.5 {
+		t.Errorf("model accuracy = %+v, want 2 evaluated, MAE 12, accuracy 0.5, success rate 0.5", model)
+	}
+}
+
+func TestRefreshFallback(t *testing.T) {
+	at := NewAccuracyTracker(nil, config.MLConfig{FallbackMAE: 15, FallbackMinSamples: 10})
+
+	at.refreshFallback([]*types.PredictionAccuracy{
+		{JobName: "backup", Method: MethodModel, Evaluated: 12, MAE: 20},
+		{JobName: "cleanup", Method: MethodModel, Evaluated: 3, MAE: 40},
+		{JobName: "report", Method: MethodModel, Evaluated: 12, MAE: 5},
+		{JobName: "report", Method: MethodHeuristics, Evaluated: 12, MAE: 30},
+	})
+
+	if !at.ShouldFallback("backup") {
+		t.Error("backup with MAE 20 does not fall back")
+	}
+	if at.ShouldFallback("cleanup") {
+		t.Error("cleanup falls back with too few evaluations")
+	}
+	if at.ShouldFallback("report") {
+		t.Error("report falls back on heuristics error")
+	}
+
+	at.refreshFallback(nil)
+	if at.ShouldFallback("backup") {
+		t.Error("backup still falls back after its errors aged out")
+	}
+}
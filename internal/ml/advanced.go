@@ -3,20 +3,22 @@ package ml
 import (
 	"fmt"
 	"math"
+	"sync"
 	"time"
 
 	"github.com/makalin/arcron/internal/monitoring"
 	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
 	"github.com/sirupsen/logrus"
 )
 
 // SeasonalityDetector detects seasonal patterns in system metrics
 type SeasonalityDetector struct {
-	store *storage.Storage
+	store storage.MetricsStore
 }
 
 // NewSeasonalityDetector creates a new seasonality detector
-func NewSeasonalityDetector(store *storage.Storage) *SeasonalityDetector {
+func NewSeasonalityDetector(store storage.MetricsStore) *SeasonalityDetector {
 	return &SeasonalityDetector{
 		store: store,
 	}
@@ -30,6 +32,8 @@ type SeasonalPattern struct {
 	LowHours  []int   `json:"low_hours"`  // Hours when load is typically low
 	PeakDays  []int   `json:"peak_days"`  // Days of week (0=Sunday) when load is high
 	LowDays   []int   `json:"low_days"`   // Days of week when load is low
+	// HourlyLoad is the average load per hour of day
+	HourlyLoad map[int]float64 `json:"hourly_load"`
 }
 
 // DetectSeasonality detects seasonal patterns in historical metrics
@@ -132,6 +136,8 @@ func (sd *SeasonalityDetector) DetectSeasonality(jobName string, days int) (*Sea
 		LowHours:  lowHours,
 		PeakDays:  peakDays,
 		LowDays:   lowDays,
+
+		HourlyLoad: hourlyAvg,
 	}
 
 	// Determine if weekly pattern is stronger
@@ -152,71 +158,78 @@ func (sd *SeasonalityDetector) DetectSeasonality(jobName string, days int) (*Sea
 	return pattern, nil
 }
 
-// AnomalyDetector detects anomalies in system metrics
+// AnomalyDetector detects anomalies in system metrics. Every metric has
+// its own rolling baseline per hour of day, so that a busy afternoon is
+// not compared against a quiet night.
 type AnomalyDetector struct {
-	store        *storage.Storage
-	baselineMean float64
-	baselineStd  float64
-	threshold    float64 // Number of standard deviations
+	store     storage.MetricsStore
+	threshold float64 // Number of standard deviations
+
+	mutex     sync.Mutex
+	baselines map[baselineKey]*baseline
+	seeded    bool
+	previous  *monitoring.SystemMetrics
+	lastSaved time.Time
+
+	// recorder and alerts are used when the detector observes the
+	// monitor, see Attach
+	recorder  AnomalyStore
+	alerts    monitoring.AlertSender
+	lastAlert map[string]time.Time
 }
 
 // NewAnomalyDetector creates a new anomaly detector
-func NewAnomalyDetector(store *storage.Storage) *AnomalyDetector {
+func NewAnomalyDetector(store storage.MetricsStore) *AnomalyDetector {
 	return &AnomalyDetector{
 		store:     store,
 		threshold: 3.0, // 3-sigma rule
+		baselines: make(map[baselineKey]*baseline),
+		lastAlert: make(map[string]time.Time),
 	}
 }
 
 // Anomaly represents a detected anomaly
-type Anomaly struct {
-	Type        string    `json:"type"`     // "cpu", "memory", "disk", "network"
-	Severity    string    `json:"severity"` // "low", "medium", "high", "critical"
-	Value       float64   `json:"value"`
-	Expected    float64   `json:"expected"`
-	Deviation   float64   `json:"deviation"` // Number of standard deviations
-	Timestamp   time.Time `json:"timestamp"`
-	Description string    `json:"description"`
-}
+type Anomaly = types.Anomaly
 
-// DetectAnomalies detects anomalies in current metrics compared to baseline
+// DetectAnomalies detects anomalies in current metrics compared to the
+// baseline of each metric, then adds the metrics to the baselines
 func (ad *AnomalyDetector) DetectAnomalies(metrics *monitoring.SystemMetrics) ([]*Anomaly, error) {
-	// Update baseline if needed
-	if err := ad.updateBaseline(); err != nil {
-		logrus.Warnf("Failed to update baseline: %v", err)
-	}
+	ad.mutex.Lock()
+	defer ad.mutex.Unlock()
 
-	anomalies := []*Anomaly{}
-
-	// Check CPU usage
-	if anomaly := ad.checkMetric("cpu", metrics.CPUUsage, ad.baselineMean, ad.baselineStd); anomaly != nil {
-		anomalies = append(anomalies, anomaly)
+	if !ad.seeded {
+		if err := ad.seedBaselines(); err != nil {
+			logrus.Warnf("Failed to seed anomaly baselines: %v", err)
+		}
+		ad.seeded = true
 	}
 
-	// Check memory usage
-	if anomaly := ad.checkMetric("memory", metrics.MemoryUsage, ad.baselineMean, ad.baselineStd); anomaly != nil {
-		anomalies = append(anomalies, anomaly)
-	}
+	values := metricValues(ad.previous, metrics)
+	ad.previous = metrics
 
-	// Check disk I/O
-	diskIO := float64(metrics.DiskIO.ReadBytes+metrics.DiskIO.WriteBytes) / 1024 / 1024 // MB
-	if anomaly := ad.checkMetric("disk", diskIO, ad.baselineMean, ad.baselineStd); anomaly != nil {
-		anomalies = append(anomalies, anomaly)
-	}
+	hour := metrics.Timestamp.Hour()
+	anomalies := []*Anomaly{}
+	for _, metric := range anomalyMetrics {
+		value, ok := values[metric.name]
+		if !ok {
+			continue
+		}
 
-	// Check network I/O
-	netIO := float64(metrics.NetworkIO.BytesSent+metrics.NetworkIO.BytesRecv) / 1024 / 1024 // MB
-	if anomaly := ad.checkMetric("network", netIO, ad.baselineMean, ad.baselineStd); anomaly != nil {
-		anomalies = append(anomalies, anomaly)
+		if b := ad.baselineFor(metric.name, hour); b != nil {
+			if anomaly := ad.checkMetric(metric, value, b.Mean, b.std()); anomaly != nil {
+				anomalies = append(anomalies, anomaly)
+			}
+		}
+		ad.addSample(metric.name, hour, value)
 	}
 
 	return anomalies, nil
 }
 
 // checkMetric checks if a metric value is anomalous
-func (ad *AnomalyDetector) checkMetric(metricType string, value, mean, std float64) *Anomaly {
+func (ad *AnomalyDetector) checkMetric(metric anomalyMetric, value, mean, std float64) *Anomaly {
 	if std == 0 {
-		return nil // No baseline yet
+		return nil // No variation in the baseline
 	}
 
 	deviation := (value - mean) / std
@@ -236,15 +249,15 @@ func (ad *AnomalyDetector) checkMetric(metricType string, value, mean, std float
 
 	description := ""
 	if deviation > 0 {
-		description = fmt.Sprintf("%s usage is %.1f%% above normal (%.1f standard deviations)",
-			metricType, (value - mean), deviation)
+		description = fmt.Sprintf("%s is %.1f%s above normal (%.1f standard deviations)",
+			metric.label, (value - mean), metric.unit, deviation)
 	} else {
-		description = fmt.Sprintf("%s usage is %.1f%% below normal (%.1f standard deviations)",
-			metricType, (mean - value), math.Abs(deviation))
+		description = fmt.Sprintf("%s is %.1f%s below normal (%.1f standard deviations)",
+			metric.label, (mean - value), metric.unit, math.Abs(deviation))
 	}
 
 	return &Anomaly{
-		Type:        metricType,
+		Type:        metric.name,
 		Severity:    severity,
 		Value:       value,
 		Expected:    mean,
@@ -254,53 +267,61 @@ func (ad *AnomalyDetector) checkMetric(metricType string, value, mean, std float
 	}
 }
 
-// updateBaseline updates the baseline statistics from historical data
-func (ad *AnomalyDetector) updateBaseline() error {
-	end := time.Now()
-	start := end.Add(-7 * 24 * time.Hour) // Last 7 days
-
-	metrics, err := ad.store.GetSystemMetrics(start, end, 10000)
-	if err != nil {
-		return err
+// seedBaselines loads persisted baselines, or computes them from the last
+// 7 days of metrics history when none have been persisted yet
+func (ad *AnomalyDetector) seedBaselines() error {
+	if ad.recorder != nil {
+		persisted, err := ad.recorder.LoadAnomalyBaselines()
+		if err != nil {
+			return err
+		}
+		if len(persisted) > 0 {
+			for _, p := range persisted {
+				ad.baselines[baselineKey{p.Metric, p.Hour}] = &baseline{
+					Count:    p.Count,
+					Mean:     p.Mean,
+					Variance: p.Variance,
+				}
+			}
+			logrus.Infof("Loaded %d anomaly baselines", len(persisted))
+			return nil
+		}
 	}
 
-	if len(metrics) < 10 {
-		return nil // Not enough data
+	if ad.store == nil {
+		return nil
 	}
 
-	// Calculate mean and standard deviation of combined load
-	loads := make([]float64, len(metrics))
-	for i, m := range metrics {
-		loads[i] = (m.CPUUsage + m.MemoryUsage) / 2.0
-	}
+	end := time.Now()
+	start := end.Add(-7 * 24 * time.Hour) // Last 7 days
 
-	mean := 0.0
-	for _, load := range loads {
-		mean += load
+	history, err := ad.store.GetSystemMetrics(start, end, 10000)
+	if err != nil {
+		return err
 	}
-	mean /= float64(len(loads))
 
-	variance := 0.0
-	for _, load := range loads {
-		variance += math.Pow(load-mean, 2)
+	// History is returned newest first
+	var previous *monitoring.SystemMetrics
+	for i := len(history) - 1; i >= 0; i-- {
+		m := history[i]
+		hour := m.Timestamp.Hour()
+		for name, value := range metricValues(previous, m) {
+			ad.addSample(name, hour, value)
+		}
+		previous = m
 	}
-	variance /= float64(len(loads))
-	std := math.Sqrt(variance)
-
-	ad.baselineMean = mean
-	ad.baselineStd = std
 
 	return nil
 }
 
 // LSTMPredictor uses LSTM-like approach for time series prediction
 type LSTMPredictor struct {
-	store      *storage.Storage
+	store      storage.MetricsStore
 	windowSize int
 }
 
 // NewLSTMPredictor creates a new LSTM predictor
-func NewLSTMPredictor(store *storage.Storage) *LSTMPredictor {
+func NewLSTMPredictor(store storage.MetricsStore) *LSTMPredictor {
 	return &LSTMPredictor{
 		store:      store,
 		windowSize: 24, // 24 hours of data
@@ -309,53 +330,15 @@ func NewLSTMPredictor(store *storage.Storage) *LSTMPredictor {
 
 // PredictNextHour predicts the system load for the next hour
 func (lp *LSTMPredictor) PredictNextHour() (float64, error) {
-	end := time.Now()
-	start := end.Add(-time.Duration(lp.windowSize) * time.Hour)
-
-	metrics, err := lp.store.GetSystemMetrics(start, end, lp.windowSize*2)
+	forecast, err := lp.Forecast(time.Now(), time.Hour, time.Hour)
 	if err != nil {
 		return 0, err
 	}
-
-	if len(metrics) < 10 {
-		return 50.0, nil // Default prediction
-	}
-
-	// Simple moving average with exponential weighting
-	weights := make([]float64, len(metrics))
-	totalWeight := 0.0
-
-	for i := range metrics {
-		// Exponential weighting: more recent = higher weight
-		weight := math.Exp(float64(i) * 0.1)
-		weights[i] = weight
-		totalWeight += weight
-	}
-
-	prediction := 0.0
-	for i, m := range metrics {
-		load := (m.CPUUsage + m.MemoryUsage) / 2.0
-		prediction += load * (weights[i] / totalWeight)
-	}
-
-	// Add trend component
-	if len(metrics) >= 2 {
-		recent := (metrics[0].CPUUsage + metrics[0].MemoryUsage) / 2.0
-		older := (metrics[len(metrics)-1].CPUUsage + metrics[len(metrics)-1].MemoryUsage) / 2.0
-		trend := (recent - older) / float64(len(metrics))
-		prediction += trend
-	}
-
-	// Apply seasonal adjustment
-	hour := time.Now().Hour()
-	seasonalAdjustment := lp.getSeasonalAdjustment(hour)
-	prediction = prediction * seasonalAdjustment
-
-	return prediction, nil
+	return forecast.Points[0].Load, nil
 }
 
-// getSeasonalAdjustment returns seasonal adjustment factor for a given hour
-func (lp *LSTMPredictor) getSeasonalAdjustment(hour int) float64 {
+// seasonalAdjustment returns seasonal adjustment factor for a given hour
+func seasonalAdjustment(hour int) float64 {
 	// Simple sinusoidal pattern: lower load at night (0-6), higher during day (9-17)
 	if hour >= 0 && hour < 6 {
 		return 0.7 // 30% reduction
@@ -0,0 +1,136 @@
+package ml
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/monitoring"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// baselineSaveInterval is how often baselines are persisted so that
+	// restarts do not reset them
+	baselineSaveInterval = 10 * time.Minute
+
+	// anomalyAlertCooldown suppresses repeated alerts for a metric that
+	// stays anomalous across consecutive samples
+	anomalyAlertCooldown = 15 * time.Minute
+)
+
+// severityRank orders anomaly severities
+var severityRank = map[string]int{
+	"low":      0,
+	"medium":   1,
+	"high":     2,
+	"critical": 3,
+}
+
+// SeveritiesAtLeast returns the severities at or above min
+func SeveritiesAtLeast(min string) ([]string, error) {
+	rank, ok := severityRank[min]
+	if !ok {
+		return nil, fmt.Errorf("unknown severity: %s", min)
+	}
+
+	var severities []string
+	for severity, r := range severityRank {
+		if r >= rank {
+			severities = append(severities, severity)
+		}
+	}
+	return severities, nil
+}
+
+// AnomalyStore persists detected anomalies and baselines, see storage.Storage
+type AnomalyStore interface {
+	StoreAnomaly(anomaly *types.Anomaly) error
+	LoadAnomalyBaselines() ([]types.AnomalyBaseline, error)
+	SaveAnomalyBaselines(baselines []types.AnomalyBaseline) error
+}
+
+// Attach runs detection on every sample collected by the monitor.
+// Detected anomalies and baselines are persisted through recorder, and
+// medium and higher severities are sent through alerts. Either may be nil.
+func (ad *AnomalyDetector) Attach(monitor *monitoring.Monitor, recorder AnomalyStore, alerts monitoring.AlertSender) {
+	ad.recorder = recorder
+	ad.alerts = alerts
+	monitor.AddListener(ad.observe)
+}
+
+// observe detects, records and alerts on anomalies in a single sample
+func (ad *AnomalyDetector) observe(metrics *monitoring.SystemMetrics) {
+	anomalies, err := ad.DetectAnomalies(metrics)
+	if err != nil {
+		logrus.Errorf("Failed to detect anomalies: %v", err)
+		return
+	}
+
+	for _, anomaly := range anomalies {
+		anomaly.Timestamp = metrics.Timestamp
+		logrus.Infof("Detected %s %s anomaly: %s", anomaly.Severity, anomaly.Type, anomaly.Description)
+
+		if ad.recorder != nil {
+			if err := ad.recorder.StoreAnomaly(anomaly); err != nil {
+				logrus.Errorf("Failed to store anomaly: %v", err)
+			}
+		}
+
+		if ad.shouldAlert(anomaly) {
+			go ad.sendAlert(anomaly, metrics)
+		}
+	}
+
+	ad.saveBaselines()
+}
+
+// saveBaselines persists the baselines every baselineSaveInterval
+func (ad *AnomalyDetector) saveBaselines() {
+	if ad.recorder == nil {
+		return
+	}
+
+	ad.mutex.Lock()
+	if time.Since(ad.lastSaved) < baselineSaveInterval {
+		ad.mutex.Unlock()
+		return
+	}
+	ad.lastSaved = time.Now()
+	snapshot := ad.snapshotBaselines()
+	ad.mutex.Unlock()
+
+	if err := ad.recorder.SaveAnomalyBaselines(snapshot); err != nil {
+		logrus.Errorf("Failed to save anomaly baselines: %v", err)
+	}
+}
+
+// shouldAlert reports whether an anomaly is severe enough to alert on and
+// its metric has not been alerted on recently
+func (ad *AnomalyDetector) shouldAlert(anomaly *Anomaly) bool {
+	if ad.alerts == nil || severityRank[anomaly.Severity] < severityRank["medium"] {
+		return false
+	}
+
+	ad.mutex.Lock()
+	defer ad.mutex.Unlock()
+
+	if last, ok := ad.lastAlert[anomaly.Type]; ok && time.Since(last) < anomalyAlertCooldown {
+		return false
+	}
+	ad.lastAlert[anomaly.Type] = time.Now()
+	return true
+}
+
+// sendAlert sends an anomaly through the alert manager
+func (ad *AnomalyDetector) sendAlert(anomaly *Anomaly, metrics *monitoring.SystemMetrics) {
+	level := "warning"
+	if severityRank[anomaly.Severity] >= severityRank["high"] {
+		level = "critical"
+	}
+
+	title := fmt.Sprintf("Anomaly Detected: %s (%s)", anomaly.Type, anomaly.Severity)
+	if err := ad.alerts.SendSystemAlert(level, title, anomaly.Description, metrics); err != nil {
+		logrus.Errorf("Failed to send anomaly alert: %v", err)
+	}
+}
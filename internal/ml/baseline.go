@@ -0,0 +1,159 @@
+package ml
+
+import (
+	"math"
+	"time"
+
+	"github.com/makalin/arcron/internal/monitoring"
+	"github.com/makalin/arcron/internal/types"
+)
+
+const (
+	// allHours is the hour of the baseline covering every hour of day,
+	// used until the baseline of an hour has enough samples
+	allHours = -1
+
+	// minBaselineSamples is the number of samples a baseline needs
+	// before values are compared against it
+	minBaselineSamples = 30
+
+	// baselineWindow is the effective number of samples a baseline
+	// averages over; older samples fade out exponentially
+	baselineWindow = 2000
+)
+
+// anomalyMetric describes a metric checked for anomalies
+type anomalyMetric struct {
+	name  string
+	label string
+	unit  string
+}
+
+// anomalyMetrics lists the metrics checked for anomalies
+var anomalyMetrics = []anomalyMetric{
+	{"cpu", "CPU usage", "%"},
+	{"memory", "Memory usage", "%"},
+	{"disk", "Disk throughput", " MB/s"},
+	{"network", "Network throughput", " MB/s"},
+}
+
+// metricValues returns the value of every anomaly metric. Disk and
+// network counters are cumulative, so their throughput is derived from
+// the previous sample and is missing without one.
+func metricValues(previous, current *monitoring.SystemMetrics) map[string]float64 {
+	values := map[string]float64{
+		"cpu":    current.CPUUsage,
+		"memory": current.MemoryUsage,
+	}
+
+	if previous == nil {
+		return values
+	}
+	elapsed := current.Timestamp.Sub(previous.Timestamp).Seconds()
+	if elapsed <= 0 {
+		return values
+	}
+
+	diskNow := current.DiskIO.ReadBytes + current.DiskIO.WriteBytes
+	diskBefore := previous.DiskIO.ReadBytes + previous.DiskIO.WriteBytes
+	if diskNow >= diskBefore {
+		values["disk"] = float64(diskNow-diskBefore) / 1024 / 1024 / elapsed
+	}
+
+	netNow := current.NetworkIO.BytesSent + current.NetworkIO.BytesRecv
+	netBefore := previous.NetworkIO.BytesSent + previous.NetworkIO.BytesRecv
+	if netNow >= netBefore {
+		values["network"] = float64(netNow-netBefore) / 1024 / 1024 / elapsed
+	}
+
+	return values
+}
+
+// baselineKey identifies the baseline of a metric for an hour of day
+type baselineKey struct {
+	metric string
+	hour   int
+}
+
+// baseline holds exponentially weighted rolling statistics of a metric
+type baseline struct {
+	Count    int
+	Mean     float64
+	Variance float64
+}
+
+// add adds a value to the baseline. The first baselineWindow values are
+// weighted equally, later values with a fixed weight so that the baseline
+// follows gradual changes in the workload.
+func (b *baseline) add(value float64) {
+	b.Count++
+	if b.Count == 1 {
+		b.Mean = value
+		b.Variance = 0
+		return
+	}
+
+	alpha := 1 / float64(b.Count)
+	if b.Count > baselineWindow {
+		alpha = 1 / float64(baselineWindow)
+	}
+
+	diff := value - b.Mean
+	increment := alpha * diff
+	b.Mean += increment
+	b.Variance = (1 - alpha) * (b.Variance + diff*increment)
+}
+
+// std returns the standard deviation of the baseline
+func (b *baseline) std() float64 {
+	return math.Sqrt(b.Variance)
+}
+
+// addSample adds a value to the metric's baseline for the hour and to
+// its all-hours baseline
+func (ad *AnomalyDetector) addSample(metric string, hour int, value float64) {
+	for _, key := range []baselineKey{{metric, hour}, {metric, allHours}} {
+		b, ok := ad.baselines[key]
+		if !ok {
+			b = &baseline{}
+			ad.baselines[key] = b
+		}
+		b.add(value)
+	}
+}
+
+// baselineFor returns the baseline to compare a metric against at the
+// given hour, or nil if there is not enough history yet
+func (ad *AnomalyDetector) baselineFor(metric string, hour int) *baseline {
+	if b, ok := ad.baselines[baselineKey{metric, hour}]; ok && b.Count >= minBaselineSamples {
+		return b
+	}
+	if b, ok := ad.baselines[baselineKey{metric, allHours}]; ok && b.Count >= minBaselineSamples {
+		return b
+	}
+	return nil
+}
+
+// snapshotBaselines returns the baselines in their persisted form
+func (ad *AnomalyDetector) snapshotBaselines() []types.AnomalyBaseline {
+	now := time.Now()
+	snapshot := make([]types.AnomalyBaseline, 0, len(ad.baselines))
+	for key, b := range ad.baselines {
+		snapshot = append(snapshot, types.AnomalyBaseline{
+			Metric:    key.metric,
+			Hour:      key.hour,
+			Count:     b.Count,
+			Mean:      b.Mean,
+			Variance:  b.Variance,
+			UpdatedAt: now,
+		})
+	}
+	return snapshot
+}
+
+// Baselines returns the current baselines of every metric
+func (ad *AnomalyDetector) Baselines() []types.AnomalyBaseline {
+	ad.mutex.Lock()
+	defer ad.mutex.Unlock()
+	return ad.snapshotBaselines()
+}
@@ -0,0 +1,73 @@
+package ml
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/monitoring"
+)
+
+func TestBaselineStatistics(t *testing.T) {
+	var b baseline
+	for _, v := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		b.add(v)
+	}
+
+	if b.Mean != 5 {
+		t.Errorf("Mean = %v, want 5", b.Mean)
+	}
+	if math.Abs(b.std()-2) > 1e-9 {
+		t.Errorf("std() = %v, want 2", b.std())
+	}
+}
+
+func TestDetectAnomaliesPerMetricBaselines(t *testing.T) {
+	ad := NewAnomalyDetector(nil)
+	ad.seeded = true
+
+	// CPU hovers around 20% and memory around 80%; with a combined
+	// baseline both would look anomalous
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		jitter := float64(i%5) - 2
+		metrics := &monitoring.SystemMetrics{
+			Timestamp:   start.Add(time.Duration(i) * 5 * time.Second),
+			CPUUsage:    20 + jitter,
+			MemoryUsage: 80 + jitter,
+		}
+		anomalies, err := ad.DetectAnomalies(metrics)
+		if err != nil {
+			t.Fatalf("DetectAnomalies() error = %v", err)
+		}
+		if len(anomalies) > 0 {
+			t.Fatalf("sample %d: unexpected anomalies %+v", i, anomalies[0])
+		}
+	}
+
+	anomalies, _ := ad.DetectAnomalies(&monitoring.SystemMetrics{
+		Timestamp:   start.Add(time.Hour),
+		CPUUsage:    60,
+		MemoryUsage: 80,
+	})
+	if len(anomalies) != 1 || anomalies[0].Type != "cpu" {
+		t.Fatalf("DetectAnomalies() = %+v, want a single cpu anomaly", anomalies)
+	}
+}
+
+func TestBaselineForFallsBackToAllHours(t *testing.T) {
+	ad := NewAnomalyDetector(nil)
+	for i := 0; i < minBaselineSamples; i++ {
+		ad.addSample("cpu", 3, 10)
+	}
+
+	if b := ad.baselineFor("cpu", 3); b == nil || b.Count != minBaselineSamples {
+		t.Errorf("baselineFor(hour 3) = %+v, want hourly baseline", b)
+	}
+	if b := ad.baselineFor("cpu", 4); b == nil || b != ad.baselines[baselineKey{"cpu", allHours}] {
+		t.Errorf("baselineFor(hour 4) = %+v, want all-hours baseline", b)
+	}
+	if b := ad.baselineFor("memory", 3); b != nil {
+		t.Errorf("baselineFor(memory) = %+v, want nil", b)
+	}
+}
@@ -0,0 +1,82 @@
+package ml
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/monitoring"
+)
+
+// countingPredictor counts how often the backend is consulted
+type countingPredictor struct {
+	calls int
+}
+
+func (c *countingPredictor) Name() string { return "counting" }
+func (c *countingPredictor) Ready() bool  { return true }
+
+func (c *countingPredictor) Predict(features []float64) (PredictorResult, error) {
+	c.calls++
+	return PredictorResult{DelayMinutes: 10, Confidence: 0.8}, nil
+}
+
+func TestPredictOptimalTimesBatch(t *testing.T) {
+	engine, _ := New(config.MLConfig{})
+	predictor := &countingPredictor{}
+	engine.SetPredictor(predictor)
+
+	requests := []PredictionRequest{
+		{JobName: "backup", JobType: "resource-intensive"},
+		{JobName: "report", JobType: "light"},
+		{JobName: "cleanup", JobType: "resource-intensive"},
+	}
+	predictions, err := engine.PredictOptimalTimes(requests, monitoring.SystemMetrics{CPUUsage: 50})
+	if err != nil {
+		t.Fatalf("PredictOptimalTimes() error = %v", err)
+	}
+
+	if predictor.calls != 1 {
+		t.Errorf("backend consulted %d times for a batch, want once", predictor.calls)
+	}
+	if len(predictions) != len(requests) {
+		t.Fatalf("PredictOptimalTimes() = %d predictions, want %d", len(predictions), len(requests))
+	}
+	for i, prediction := range predictions {
+		if prediction.JobName != requests[i].JobName || prediction.Method != MethodModel {
+			t.Errorf("prediction %d = %s by %s, want %s by the model",
+				i, prediction.JobName, prediction.Method, requests[i].JobName)
+		}
+	}
+	if predictions[0].Explanation == predictions[1].Explanation {
+		t.Error("predictions share an explanation")
+	}
+}
+
+func BenchmarkPredictOptimalTimes(b *testing.B) {
+	engine, _ := New(config.MLConfig{})
+	engine.initializeHeuristics()
+	metrics := monitoring.SystemMetrics{CPUUsage: 50, MemoryUsage: 40}
+
+	requests := make([]PredictionRequest, 1000)
+	for i := range requests {
+		requests[i] = PredictionRequest{JobName: fmt.Sprintf("job-%d", i), JobType: "light"}
+	}
+
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := engine.PredictOptimalTimes(requests, metrics); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("single", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, request := range requests {
+				if _, err := engine.PredictOptimalTime(request.JobName, request.JobType, metrics); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
@@ -4,41 +4,64 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/makalin/arcron/internal/config"
 	"github.com/makalin/arcron/internal/monitoring"
+	"github.com/makalin/arcron/internal/telemetry"
+	"github.com/makalin/arcron/internal/types"
 
 	"github.com/sirupsen/logrus"
 )
 
+var predictionDuration = telemetry.NewHistogram("arcron_ml_prediction_duration_seconds",
+	"Latency of ML optimal time predictions", "method")
+
 // Prediction represents a job execution prediction
-type Prediction struct {
-	JobName       string    `json:"job_name"`
-	OptimalTime   time.Time `json:"optimal_time"`
-	Confidence    float64   `json:"confidence"`
-	Reasoning     string    `json:"reasoning"`
-	ExpectedLoad  float64   `json:"expected_load"`
-}
+type Prediction = types.Prediction
+
+// Prediction methods
+const (
+	MethodModel      = "model"
+	MethodHeuristics = "heuristics"
+)
 
 // FeatureVector represents the input features for ML prediction
 type FeatureVector struct {
-	CPUUsage    float64 `json:"cpu_usage"`
-	MemoryUsage float64 `json:"memory_usage"`
-	DiskIO      float64 `json:"disk_io"`
-	NetworkIO   float64 `json:"network_io"`
-	LoadAvg     float64 `json:"load_avg"`
-	HourOfDay   float64 `json:"hour_of_day"`
-	DayOfWeek   float64 `json:"day_of_week"`
+	CPUUsage       float64 `json:"cpu_usage"`
+	MemoryUsage    float64 `json:"memory_usage"`
+	DiskIO         float64 `json:"disk_io"`
+	NetworkIO      float64 `json:"network_io"`
+	LoadAvg        float64 `json:"load_avg"`
+	GPUUsage       float64 `json:"gpu_usage"`
+	CPUTemperature float64 `json:"cpu_temperature"`
+	HourOfDay      float64 `json:"hour_of_day"`
+	DayOfWeek      float64 `json:"day_of_week"`
 }
 
 // Engine represents the machine learning engine
 type Engine struct {
 	config       config.MLConfig
 	model        *SimpleMLModel
+	predictor    Predictor
 	stopChan     chan struct{}
-	isRunning    bool
+	isRunning    atomic.Bool
 	lastTraining time.Time
+	accuracy     *AccuracyTracker
+	saveMutex    sync.Mutex
+
+	forecaster    *LSTMPredictor
+	forecast      *types.LoadForecast
+	forecastMutex sync.Mutex
+
+	// Online learning state, see Attach
+	onlineMutex     sync.Mutex
+	history         []featureSample
+	durations       map[string]*durationBaseline
+	lastModelSave   time.Time
+	unsavedLearning bool
 }
 
 // SimpleMLModel represents a simplified ML model
@@ -47,79 +70,214 @@ type SimpleMLModel struct {
 	featureMean []float64
 	featureStd  []float64
 	trained     bool
+	samples     int // executions learned from
+	mutex       sync.RWMutex
 }
 
 // New creates a new ML Engine instance
 func New(cfg config.MLConfig) (*Engine, error) {
 	model := &SimpleMLModel{
-		weights:     make([]float64, 8), // 8 features
-		featureMean: make([]float64, 8),
-		featureStd:  make([]float64, 8),
+		weights:     make([]float64, 9), // 9 features
+		featureMean: make([]float64, 9),
+		featureStd:  make([]float64, 9),
 		trained:     false,
 	}
 
-	return &Engine{
+	engine := &Engine{
 		config:    cfg,
 		model:     model,
 		stopChan:  make(chan struct{}),
-	}, nil
+		durations: make(map[string]*durationBaseline),
+	}
+
+	predictor, err := engine.newPredictor()
+	if err != nil {
+		return nil, err
+	}
+	engine.predictor = predictor
+
+	return engine, nil
 }
 
 // Start starts the ML engine
 func (e *Engine) Start(ctx context.Context) error {
-	if e.isRunning {
+	if !e.isRunning.CompareAndSwap(false, true) {
 		return fmt.Errorf("ML engine is already running")
 	}
 
-	e.isRunning = true
 	logrus.Info("Starting ML engine...")
 
-	// Initialize with simple heuristics if no model exists
-	if !e.model.trained {
-		e.initializeHeuristics()
+	// Reload the saved model, or start from simple heuristics
+	if e.predictor == Predictor(e.model) && !e.model.trained {
+		e.restoreModel()
 	}
 
 	go e.periodicTraining(ctx)
+	if e.accuracy != nil {
+		go e.accuracy.run(ctx, e.stopChan)
+	}
 
 	return nil
 }
 
 // Stop stops the ML engine
 func (e *Engine) Stop() {
-	if !e.isRunning {
+	if !e.isRunning.CompareAndSwap(true, false) {
 		return
 	}
 
 	logrus.Info("Stopping ML engine...")
 	close(e.stopChan)
-	e.isRunning = false
+	e.saveLearnedModel()
 }
 
-// PredictOptimalTime predicts the optimal execution time for a job
+// PredictOptimalTime predicts the optimal execution time for a job. Jobs
+// for which the model has been underperforming use the heuristics instead.
 func (e *Engine) PredictOptimalTime(jobName, jobType string, currentMetrics monitoring.SystemMetrics) (*Prediction, error) {
-	if !e.model.trained {
-		return e.predictWithHeuristics(jobName, jobType, currentMetrics)
+	predictions, err := e.PredictOptimalTimes([]PredictionRequest{{JobName: jobName, JobType: jobType}}, currentMetrics)
+	if err != nil {
+		return nil, err
+	}
+	return predictions[0], nil
+}
+
+// PredictionRequest identifies a job to predict the optimal time for
+type PredictionRequest struct {
+	JobName string
+	JobType string
+}
+
+// PredictOptimalTimes predicts the optimal execution times of a batch of
+// jobs under the same conditions. The model is consulted once for the
+// whole batch and the heuristics once per job type, since neither depends
+// on anything else about a job, and the predictions are recorded in a
+// single write. Predictions are returned in request order.
+func (e *Engine) PredictOptimalTimes(requests []PredictionRequest, currentMetrics monitoring.SystemMetrics) ([]*Prediction, error) {
+	predictions, err := e.predictBatch(requests, currentMetrics, time.Now(), e.LatestForecast())
+	if err != nil {
+		return nil, err
 	}
 
-	features := e.extractFeatures(currentMetrics)
-	prediction := e.model.predict(features)
+	if e.accuracy != nil {
+		e.accuracy.RecordAll(predictions)
+	}
+	return predictions, nil
+}
+
+// PredictAt predicts the optimal execution times of a batch of jobs as if
+// it were the given time and the metrics were current, for simulations
+// replaying past metrics. The predictions are neither recorded nor
+// adjusted by the load forecast.
+func (e *Engine) PredictAt(requests []PredictionRequest, metrics monitoring.SystemMetrics, at time.Time) ([]*Prediction, error) {
+	return e.predictBatch(requests, metrics, at, nil)
+}
+
+// predictBatch predicts the optimal execution times of a batch of jobs at
+// now, looking ahead with forecast if not nil
+func (e *Engine) predictBatch(requests []PredictionRequest, currentMetrics monitoring.SystemMetrics, now time.Time, forecast *types.LoadForecast) ([]*Prediction, error) {
+	var model *Prediction
+	var modelErr error
+	modelTried := false
+	heuristics := make(map[string]*Prediction)
+
+	predictions := make([]*Prediction, len(requests))
+	for i, request := range requests {
+		var prediction *Prediction
+		var fallbackReason string
+		switch {
+		case !e.predictor.Ready():
+			fallbackReason = fmt.Sprintf("%s model not trained yet", e.predictor.Name())
+		case e.accuracy != nil && e.accuracy.ShouldFallback(request.JobName):
+			fallbackReason = "model underperforming for this job"
+		default:
+			if !modelTried {
+				modelTried = true
+				model, modelErr = e.predictWithModel(request.JobName, currentMetrics, now)
+				if modelErr != nil {
+					logrus.Warnf("%s ML backend failed, using heuristics: %v", e.predictor.Name(), modelErr)
+				}
+			}
+			if modelErr != nil {
+				fallbackReason = fmt.Sprintf("%s backend failed: %v", e.predictor.Name(), modelErr)
+			} else {
+				prediction = predictionFor(model, request.JobName)
+			}
+		}
+		if prediction == nil {
+			shared, ok := heuristics[request.JobType]
+			if !ok {
+				var err error
+				shared, err = e.predictWithHeuristics(request.JobName, request.JobType, currentMetrics, now)
+				if err != nil {
+					return nil, err
+				}
+				heuristics[request.JobType] = shared
+			}
+			prediction = predictionFor(shared, request.JobName)
+			prediction.Explanation.FallbackReason = fallbackReason
+		}
+
+		// Look ahead instead of relying on the current metrics alone
+		if forecast != nil {
+			applyForecast(prediction, forecast)
+		}
+		predictions[i] = prediction
+	}
+	return predictions, nil
+}
+
+// predictionFor copies a prediction shared by a batch for a single job.
+// The explanation is copied too, since it is completed per job.
+func predictionFor(shared *Prediction, jobName string) *Prediction {
+	prediction := *shared
+	prediction.JobName = jobName
+	if shared.Explanation != nil {
+		explanation := *shared.Explanation
+		prediction.Explanation = &explanation
+	}
+	return &prediction
+}
+
+// predictWithModel predicts using the trained model, as of now
+func (e *Engine) predictWithModel(jobName string, currentMetrics monitoring.SystemMetrics, now time.Time) (*Prediction, error) {
+	defer predictionDuration.ObserveSince(time.Now(), MethodModel)
+
+	features := featuresAt(&currentMetrics, now)
+	result, err := e.predictor.Predict(features)
+	if err != nil {
+		return nil, err
+	}
 
 	// Convert prediction to time
-	optimalTime := time.Now().Add(time.Duration(prediction) * time.Minute)
+	optimalTime := now.Add(time.Duration(result.DelayMinutes * float64(time.Minute)))
+
+	// Expect the current load to follow the typical daily curve
+	expectedLoad := CombinedLoad(&currentMetrics) *
+		seasonalAdjustment(optimalTime.Hour()) / seasonalAdjustment(now.Hour())
+
+	explanation := &types.Explanation{Backend: e.predictor.Name()}
+	if explainer, ok := e.predictor.(Explainer); ok {
+		explanation.Contributions = explainer.Explain(features)
+	}
 
 	return &Prediction{
 		JobName:      jobName,
+		PredictedAt:  now,
 		OptimalTime:  optimalTime,
-		Confidence:   0.7, // Placeholder confidence
-		Reasoning:    fmt.Sprintf("ML model prediction based on %d features", len(features)),
-		ExpectedLoad: prediction,
+		Confidence:   result.Confidence,
+		Reasoning:    modelReasoning(e.predictor.Name(), len(features), explanation.Contributions),
+		ExpectedLoad: expectedLoad,
+		Method:       MethodModel,
+		Explanation:  explanation,
 	}, nil
 }
 
-// predictWithHeuristics predicts using simple heuristics
-func (e *Engine) predictWithHeuristics(jobName, jobType string, metrics monitoring.SystemMetrics) (*Prediction, error) {
+// predictWithHeuristics predicts using simple heuristics, as of now
+func (e *Engine) predictWithHeuristics(jobName, jobType string, metrics monitoring.SystemMetrics, now time.Time) (*Prediction, error) {
+	defer predictionDuration.ObserveSince(time.Now(), MethodHeuristics)
+
 	var delay time.Duration
-	var reasoning string
+	var reasoning, branch string
 
 	switch jobType {
 	case "resource-intensive":
@@ -127,48 +285,73 @@ func (e *Engine) predictWithHeuristics(jobName, jobType string, metrics monitori
 		if metrics.CPUUsage > 80 || metrics.MemoryUsage > 80 {
 			delay = 30 * time.Minute
 			reasoning = "High system load detected, delaying resource-intensive job"
+			branch = "resource-intensive: CPU or memory above 80%"
 		} else if metrics.CPUUsage > 60 || metrics.MemoryUsage > 60 {
 			delay = 15 * time.Minute
 			reasoning = "Moderate system load, slight delay for resource-intensive job"
+			branch = "resource-intensive: CPU or memory above 60%"
 		} else {
 			delay = 5 * time.Minute
 			reasoning = "Low system load, minimal delay for resource-intensive job"
+			branch = "resource-intensive: CPU and memory at most 60%"
 		}
 	case "light":
 		// For light jobs, minimal delay
 		if metrics.CPUUsage > 90 || metrics.MemoryUsage > 90 {
 			delay = 10 * time.Minute
 			reasoning = "Very high system load, delaying light job"
+			branch = "light: CPU or memory above 90%"
 		} else {
 			delay = 1 * time.Minute
 			reasoning = "System load acceptable for light job"
+			branch = "light: CPU and memory at most 90%"
 		}
 	default:
 		delay = 5 * time.Minute
 		reasoning = "Unknown job type, using default delay"
+		branch = fmt.Sprintf("unknown job type %q", jobType)
 	}
 
-	optimalTime := time.Now().Add(delay)
+	optimalTime := now.Add(delay)
 
 	return &Prediction{
 		JobName:      jobName,
+		PredictedAt:  now,
 		OptimalTime:  optimalTime,
 		Confidence:   0.5, // Lower confidence for heuristics
 		Reasoning:    reasoning,
-		ExpectedLoad: float64(delay.Minutes()),
+		ExpectedLoad: CombinedLoad(&metrics), // Expect the current load to persist
+		Method:       MethodHeuristics,
+		Explanation: &types.Explanation{
+			Branch: branch,
+			Inputs: map[string]float64{
+				"cpu_usage":    metrics.CPUUsage,
+				"memory_usage": metrics.MemoryUsage,
+			},
+		},
 	}, nil
 }
 
 // extractFeatures extracts features from system metrics
 func (e *Engine) extractFeatures(metrics monitoring.SystemMetrics) []float64 {
-	now := time.Now()
-	
+	return featuresAt(&metrics, time.Now())
+}
+
+// featuresAt extracts features from system metrics collected at the given time
+func featuresAt(metrics *monitoring.SystemMetrics, now time.Time) []float64 {
+	var cpuTemperature float64
+	if metrics.Temperatures != nil {
+		cpuTemperature = metrics.Temperatures.CPU
+	}
+
 	features := []float64{
 		metrics.CPUUsage,
 		metrics.MemoryUsage,
-		float64(metrics.DiskIO.ReadBytes+metrics.DiskIO.WriteBytes) / 1024 / 1024, // MB
+		float64(metrics.DiskIO.ReadBytes+metrics.DiskIO.WriteBytes) / 1024 / 1024,      // MB
 		float64(metrics.NetworkIO.BytesSent+metrics.NetworkIO.BytesRecv) / 1024 / 1024, // MB
 		metrics.LoadAvg.Load1,
+		metrics.GPUUsage(),
+		cpuTemperature,
 		float64(now.Hour()),
 		float64(now.Weekday()),
 	}
@@ -178,6 +361,9 @@ func (e *Engine) extractFeatures(metrics monitoring.SystemMetrics) []float64 {
 
 // initializeHeuristics initializes the model with simple heuristics
 func (e *Engine) initializeHeuristics() {
+	e.model.mutex.Lock()
+	defer e.model.mutex.Unlock()
+
 	// Simple weights based on domain knowledge
 	e.model.weights = []float64{
 		-0.1,  // CPU usage (negative: prefer lower)
@@ -185,6 +371,8 @@ func (e *Engine) initializeHeuristics() {
 		-0.05, // Disk I/O (negative: prefer lower)
 		-0.05, // Network I/O (negative: prefer lower)
 		-0.1,  // Load average (negative: prefer lower)
+		-0.1,  // GPU usage (negative: prefer lower)
+		-0.05, // CPU temperature (negative: prefer lower)
 		0.0,   // Hour of day (neutral)
 		0.0,   // Day of week (neutral)
 	}
@@ -217,31 +405,125 @@ func (e *Engine) trainModel() error {
 	// This is a simplified training implementation
 	// In a real implementation, you'd use actual training data
 	logrus.Debug("Training ML model...")
-	
+
 	// For now, just update the last training time
 	e.lastTraining = time.Now()
-	
+
+	if err := e.saveModel(); err != nil {
+		return fmt.Errorf("failed to save model: %v", err)
+	}
+
 	return nil
 }
 
+// SetAccuracyTracker sets the tracker recording predictions and deciding
+// which jobs fall back to the heuristics. Pending predictions are evaluated
+// periodically once the engine is started.
+func (e *Engine) SetAccuracyTracker(tracker *AccuracyTracker) {
+	e.accuracy = tracker
+}
+
+// SetPredictor replaces the model backend, e.g. with a custom Predictor
+// when embedding the engine. It must be called before Start.
+func (e *Engine) SetPredictor(predictor Predictor) {
+	e.predictor = predictor
+}
+
+// SetForecaster sets the load forecaster consulted by predictions
+func (e *Engine) SetForecaster(forecaster *LSTMPredictor) {
+	e.forecaster = forecaster
+}
+
+// Forecaster returns the load forecaster, if any
+func (e *Engine) Forecaster() *LSTMPredictor {
+	return e.forecaster
+}
+
+// Forecast forecasts the load over the given horizon at the configured
+// interval. Forecasts are recorded for accuracy evaluation.
+func (e *Engine) Forecast(horizon time.Duration) (*types.LoadForecast, error) {
+	if e.forecaster == nil {
+		return nil, fmt.Errorf("load forecasting not configured")
+	}
+
+	forecast, err := e.forecaster.Forecast(time.Now(), horizon, e.config.ForecastInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.accuracy != nil {
+		e.accuracy.RecordForecast(forecast)
+	}
+	return forecast, nil
+}
+
+// LatestForecast returns a forecast over the configured horizon, refreshed
+// once per forecast interval, or nil if forecasting is not available
+func (e *Engine) LatestForecast() *types.LoadForecast {
+	if e.forecaster == nil {
+		return nil
+	}
+
+	e.forecastMutex.Lock()
+	defer e.forecastMutex.Unlock()
+
+	if e.forecast != nil && time.Since(e.forecast.GeneratedAt) < e.config.ForecastInterval {
+		return e.forecast
+	}
+
+	forecast, err := e.Forecast(e.config.ForecastHorizon)
+	if err != nil {
+		logrus.Errorf("Failed to forecast load: %v", err)
+		return e.forecast
+	}
+	e.forecast = forecast
+	return forecast
+}
+
+// AccuracyTracker returns the prediction accuracy tracker, if any
+func (e *Engine) AccuracyTracker() *AccuracyTracker {
+	return e.accuracy
+}
+
 // GetStatus returns the current status of the ML engine
 func (e *Engine) GetStatus() map[string]interface{} {
-	return map[string]interface{}{
-		"running":       e.isRunning,
-		"model_trained": e.model.trained,
+	status := map[string]interface{}{
+		"running":       e.isRunning.Load(),
+		"backend":       e.predictor.Name(),
+		"model_trained": e.predictor.Ready(),
 		"last_training": e.lastTraining,
-		"features":      len(e.model.weights),
+		"features":      len(featureNames),
+		"samples_seen":  e.model.samplesSeen(),
+		"model_path":    e.config.ModelPath,
+		"model_version": modelFormatVersion,
+	}
+	if e.accuracy != nil {
+		status["fallback_jobs"] = e.accuracy.FallbackJobs()
 	}
+	return status
+}
+
+// CombinedLoad is the load measure predictions are evaluated against: the
+// average of CPU and memory usage
+func CombinedLoad(metrics *monitoring.SystemMetrics) float64 {
+	return (metrics.CPUUsage + metrics.MemoryUsage) / 2.0
 }
 
 // predict makes a prediction using the trained model
 func (m *SimpleMLModel) predict(features []float64) float64 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.predictLocked(features)
+}
+
+// predictLocked makes a prediction with the model lock held
+func (m *SimpleMLModel) predictLocked(features []float64) float64 {
 	if !m.trained || len(features) != len(m.weights) {
 		return 0.0
 	}
 
 	var prediction float64
-	for i, feature := range features {
+	for i, feature := range m.normalize(features) {
 		prediction += feature * m.weights[i]
 	}
 
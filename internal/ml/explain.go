@@ -0,0 +1,69 @@
+package ml
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// maxReasoningFeatures is how many of the strongest contributions are
+// named in a prediction's reasoning
+const maxReasoningFeatures = 3
+
+// Explainer is implemented by predictors that can attribute a prediction
+// to its input features
+type Explainer interface {
+	// Explain returns the contribution of every feature to the prediction,
+	// strongest first
+	Explain(features []float64) []types.FeatureContribution
+}
+
+// Explain implements Explainer. The contribution of a feature is its
+// weight times its normalized value, i.e. its share of the activation.
+func (m *SimpleMLModel) Explain(features []float64) []types.FeatureContribution {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if len(features) != len(m.weights) || len(features) != len(featureNames) {
+		return nil
+	}
+
+	contributions := make([]types.FeatureContribution, len(features))
+	for i, x := range m.normalize(features) {
+		contributions[i] = types.FeatureContribution{
+			Feature:      featureNames[i],
+			Value:        features[i],
+			Weight:       m.weights[i],
+			Contribution: m.weights[i] * x,
+		}
+	}
+
+	sort.SliceStable(contributions, func(i, j int) bool {
+		return math.Abs(contributions[i].Contribution) > math.Abs(contributions[j].Contribution)
+	})
+	return contributions
+}
+
+// modelReasoning summarizes a model prediction, naming the features that
+// drove it most
+func modelReasoning(backend string, features int, contributions []types.FeatureContribution) string {
+	reasoning := fmt.Sprintf("ML model prediction (%s backend) based on %d features", backend, features)
+	if len(contributions) == 0 {
+		return reasoning
+	}
+
+	var drivers []string
+	for i, c := range contributions {
+		if i == maxReasoningFeatures || c.Contribution == 0 {
+			break
+		}
+		drivers = append(drivers, fmt.Sprintf("%s=%.1f (%+.2f)", c.Feature, c.Value, c.Contribution))
+	}
+	if len(drivers) == 0 {
+		return reasoning
+	}
+	return reasoning + ", driven by " + strings.Join(drivers, ", ")
+}
@@ -0,0 +1,52 @@
+package ml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/monitoring"
+)
+
+func TestExplainModelPrediction(t *testing.T) {
+	engine, _ := New(config.MLConfig{})
+	engine.initializeHeuristics()
+
+	metrics := monitoring.SystemMetrics{CPUUsage: 95, MemoryUsage: 10}
+	prediction, err := engine.PredictOptimalTime("backup", "resource-intensive", metrics)
+	if err != nil {
+		t.Fatalf("PredictOptimalTime() error = %v", err)
+	}
+
+	explanation := prediction.Explanation
+	if prediction.Method != MethodModel || explanation == nil || len(explanation.Contributions) != len(featureNames) {
+		t.Fatalf("prediction = %+v, want a model prediction with all feature contributions", prediction)
+	}
+	if top := explanation.Contributions[0]; top.Feature != "cpu_usage" || top.Contribution != -9.5 {
+		t.Errorf("strongest contribution = %+v, want cpu_usage at -9.5", top)
+	}
+	if !strings.Contains(prediction.Reasoning, "driven by cpu_usage=95.0") {
+		t.Errorf("reasoning %q does not name the driving feature", prediction.Reasoning)
+	}
+}
+
+func TestExplainHeuristicPrediction(t *testing.T) {
+	engine, _ := New(config.MLConfig{})
+
+	metrics := monitoring.SystemMetrics{CPUUsage: 85, MemoryUsage: 40}
+	prediction, err := engine.PredictOptimalTime("backup", "resource-intensive", metrics)
+	if err != nil {
+		t.Fatalf("PredictOptimalTime() error = %v", err)
+	}
+
+	explanation := prediction.Explanation
+	if prediction.Method != MethodHeuristics || explanation == nil {
+		t.Fatalf("prediction = %+v, want an explained heuristic prediction", prediction)
+	}
+	if explanation.Branch != "resource-intensive: CPU or memory above 80%" {
+		t.Errorf("branch = %q", explanation.Branch)
+	}
+	if explanation.Inputs["cpu_usage"] != 85 || explanation.FallbackReason == "" {
+		t.Errorf("explanation = %+v, want CPU input and a fallback reason", explanation)
+	}
+}
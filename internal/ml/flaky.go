@@ -0,0 +1,206 @@
+package ml
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// FlakinessStore reads the executions of jobs, see storage.Storage
+type FlakinessStore interface {
+	GetJobExecutions(jobName string, limit int) ([]*types.JobExecution, error)
+}
+
+// FlakyAlerter sends the alerts of flaky jobs, see alerts.Manager
+type FlakyAlerter interface {
+	SendFlakyAlert(jobName string, flakiness *types.Flakiness) error
+}
+
+// ScoreFlakiness scores the finished runs among executions, grouping
+// attempts into runs by their first attempt. Runs whose first attempt is
+// not among executions, or that are still running or retrying, are left
+// out.
+func ScoreFlakiness(executions []*types.JobExecution) *types.Flakiness {
+	type run struct {
+		first *types.JobExecution
+		last  *types.JobExecution
+	}
+	runs := make(map[string]*run)
+	for _, execution := range executions {
+		id := execution.ID
+		if execution.ParentExecutionID != "" {
+			id = execution.ParentExecutionID
+		}
+		r, ok := runs[id]
+		if !ok {
+			r = &run{}
+			runs[id] = r
+		}
+		if execution.Attempt <= 1 {
+			r.first = execution
+		}
+		if r.last == nil || execution.Attempt > r.last.Attempt {
+			r.last = execution
+		}
+	}
+
+	var finished []*run
+	for _, r := range runs {
+		if r.first == nil {
+			continue
+		}
+		// A failed attempt to be retried is followed by a pending one
+		if r.last.Status == types.StatusCompleted || r.last.Status == types.StatusFailed {
+			finished = append(finished, r)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].first.StartTime.Before(finished[j].first.StartTime)
+	})
+
+	flakiness := &types.Flakiness{Runs: len(finished)}
+	for i, r := range finished {
+		passed := r.last.Status == types.StatusCompleted
+		switch {
+		case passed && r.last.Attempt > 1:
+			flakiness.Recovered++
+		case !passed:
+			flakiness.Failed++
+		}
+		if i > 0 && passed != (finished[i-1].last.Status == types.StatusCompleted) {
+			flakiness.Flips++
+		}
+	}
+	if flakiness.Runs > 0 {
+		flakiness.Score = float64(flakiness.Recovered) / float64(flakiness.Runs) / 2
+	}
+	if flakiness.Runs > 1 {
+		flakiness.Score += float64(flakiness.Flips) / float64(flakiness.Runs-1) / 2
+	}
+	return flakiness
+}
+
+// FlakinessDetector scores a job after each of its runs finishes and
+// alerts when the score rises to the threshold
+type FlakinessDetector struct {
+	cfg     config.FlakinessConfig
+	store   FlakinessStore
+	alerts  FlakyAlerter
+	retries func(jobName string) int
+	mutex   sync.Mutex
+	flaky   map[string]bool // jobs last scored at or above the threshold
+}
+
+// NewFlakinessDetector creates a flakiness detector
+func NewFlakinessDetector(cfg config.FlakinessConfig, store FlakinessStore) *FlakinessDetector {
+	return &FlakinessDetector{
+		cfg:   cfg,
+		store: store,
+		flaky: make(map[string]bool),
+	}
+}
+
+// Attach scores a job after every finished run of the job manager and
+// alerts through alerts, unless alerting on flaky jobs is off or alerts is
+// nil
+func (fd *FlakinessDetector) Attach(jobManager *jobs.Manager, alerts FlakyAlerter) {
+	fd.retries = func(jobName string) int {
+		job, _ := jobManager.GetJob(jobName)
+		if job == nil {
+			return 0
+		}
+		return job.GetConfig().Retries
+	}
+	if !fd.cfg.Alert || alerts == nil {
+		return
+	}
+	fd.alerts = alerts
+	jobManager.AddListener(fd.observe)
+}
+
+// observe checks a job in the background once a run finished, after its
+// last attempt
+func (fd *FlakinessDetector) observe(execution *jobs.JobExecution) {
+	switch {
+	case execution.Status == types.StatusCompleted:
+	case execution.Status == types.StatusFailed && execution.Attempt > fd.retries(execution.JobName):
+	default:
+		return
+	}
+	jobName := execution.JobName
+	go func() {
+		if err := fd.Check(jobName); err != nil {
+			logrus.Errorf("Failed to score flakiness of job %s: %v", jobName, err)
+		}
+	}()
+}
+
+// Score scores the latest runs of a job
+func (fd *FlakinessDetector) Score(jobName string) (*types.Flakiness, error) {
+	retries := 0
+	if fd.retries != nil {
+		retries = fd.retries(jobName)
+	}
+	executions, err := fd.store.GetJobExecutions(jobName, fd.cfg.Window*(retries+1))
+	if err != nil {
+		return nil, err
+	}
+	flakiness := ScoreFlakiness(executions)
+	if flakiness.Runs > fd.cfg.Window {
+		// Attempts of more runs than the window were read when few retried
+		sort.Slice(executions, func(i, j int) bool {
+			return executions[i].StartTime.After(executions[j].StartTime)
+		})
+		flakiness = ScoreFlakiness(latestRuns(executions, fd.cfg.Window))
+	}
+	return flakiness, nil
+}
+
+// latestRuns returns the attempts of the latest runs among executions,
+// newest first
+func latestRuns(executions []*types.JobExecution, runs int) []*types.JobExecution {
+	seen := make(map[string]bool)
+	var latest []*types.JobExecution
+	for _, execution := range executions {
+		id := execution.ID
+		if execution.ParentExecutionID != "" {
+			id = execution.ParentExecutionID
+		}
+		if !seen[id] {
+			if len(seen) == runs {
+				continue
+			}
+			seen[id] = true
+		}
+		latest = append(latest, execution)
+	}
+	return latest
+}
+
+// Check scores a job and alerts if its score rose to the threshold since
+// it was last checked
+func (fd *FlakinessDetector) Check(jobName string) error {
+	flakiness, err := fd.Score(jobName)
+	if err != nil {
+		return err
+	}
+	flaky := flakiness.Runs >= fd.cfg.MinRuns && flakiness.Score >= fd.cfg.Threshold
+
+	fd.mutex.Lock()
+	rose := flaky && !fd.flaky[jobName]
+	fd.flaky[jobName] = flaky
+	fd.mutex.Unlock()
+	if !rose {
+		return nil
+	}
+
+	logrus.Warnf("Job %s is flaky: score %.2f over its last %d runs", jobName, flakiness.Score, flakiness.Runs)
+	if fd.alerts != nil {
+		return fd.alerts.SendFlakyAlert(jobName, flakiness)
+	}
+	return nil
+}
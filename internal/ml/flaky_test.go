@@ -0,0 +1,129 @@
+package ml
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// flakyAlerter records the flaky alerts sent
+type flakyAlerter struct {
+	alerts []*types.Flakiness
+}
+
+func (a *flakyAlerter) SendFlakyAlert(jobName string, flakiness *types.Flakiness) error {
+	a.alerts = append(a.alerts, flakiness)
+	return nil
+}
+
+// flakyRun builds the attempts of a run, each attempt but the last failing
+func flakyRun(id string, start time.Time, attempts int, last types.JobStatus) []*types.JobExecution {
+	var run []*types.JobExecution
+	for attempt := 1; attempt <= attempts; attempt++ {
+		execution := &types.JobExecution{ID: fmt.Sprintf("%s-%d", id, attempt), JobName: "sync", Status: types.StatusFailed,
+			StartTime: start.Add(time.Duration(attempt) * time.Minute), Attempt: attempt}
+		if attempt > 1 {
+			execution.ParentExecutionID = id + "-1"
+		}
+		if attempt == attempts {
+			execution.Status = last
+		}
+		run = append(run, execution)
+	}
+	return run
+}
+
+func TestScoreFlakiness(t *testing.T) {
+	start := time.Now().Add(-24 * time.Hour)
+	runs := func(outcomes ...string) []*types.JobExecution {
+		var executions []*types.JobExecution
+		for i, outcome := range outcomes {
+			at := start.Add(time.Duration(i) * time.Hour)
+			switch outcome {
+			case "pass":
+				executions = append(executions, flakyRun(fmt.Sprintf("run%d", i), at, 1, types.StatusCompleted)...)
+			case "fail":
+				executions = append(executions, flakyRun(fmt.Sprintf("run%d", i), at, 1, types.StatusFailed)...)
+			case "retried":
+				executions = append(executions, flakyRun(fmt.Sprintf("run%d", i), at, 2, types.StatusCompleted)...)
+			case "retrying":
+				executions = append(executions, flakyRun(fmt.Sprintf("run%d", i), at, 2, types.StatusPending)...)
+			}
+		}
+		return executions
+	}
+
+	cases := []struct {
+		name       string
+		executions []*types.JobExecution
+		want       types.Flakiness
+	}{
+		{"stable", runs("pass", "pass", "pass", "pass"), types.Flakiness{Runs: 4}},
+		{"always failing", runs("fail", "fail", "fail"), types.Flakiness{Runs: 3, Failed: 3}},
+		{"alternating", runs("pass", "fail", "pass", "fail", "pass"), types.Flakiness{Score: 0.5, Runs: 5, Failed: 2, Flips: 4}},
+		{"recovered by retries", runs("retried", "retried", "pass", "pass"), types.Flakiness{Score: 0.25, Runs: 4, Recovered: 2}},
+		{"retrying left out", runs("pass", "retrying"), types.Flakiness{Runs: 1}},
+		{"none", nil, types.Flakiness{}},
+	}
+	for _, c := range cases {
+		if got := ScoreFlakiness(c.executions); *got != c.want {
+			t.Errorf("%s: ScoreFlakiness() = %+v, want %+v", c.name, *got, c.want)
+		}
+	}
+
+	// A run whose first attempt is outside the executions is left out
+	retried := flakyRun("cut", start, 3, types.StatusCompleted)
+	if got := ScoreFlakiness(retried[1:]); got.Runs != 0 {
+		t.Errorf("ScoreFlakiness() of a run without its first attempt = %+v, want no runs", *got)
+	}
+}
+
+func TestFlakinessDetectorCheck(t *testing.T) {
+	store := storage.NewMemoryStore()
+	alerter := &flakyAlerter{}
+	detector := NewFlakinessDetector(config.FlakinessConfig{Window: 10, MinRuns: 4, Alert: true, Threshold: 0.25}, store)
+	detector.alerts = alerter
+
+	start := time.Now().Add(-24 * time.Hour)
+	storeRuns := func(runs ...[]*types.JobExecution) {
+		for _, run := range runs {
+			for _, execution := range run {
+				if err := store.StoreJobExecution(execution); err != nil {
+					t.Fatalf("StoreJobExecution() error = %v", err)
+				}
+			}
+		}
+	}
+	for i := 0; i < 4; i++ {
+		storeRuns(flakyRun(fmt.Sprintf("stable%d", i), start.Add(time.Duration(i)*time.Hour), 1, types.StatusCompleted))
+	}
+	if err := detector.Check("sync"); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(alerter.alerts) != 0 {
+		t.Fatalf("Check() alerted on a stable job: %+v", alerter.alerts)
+	}
+
+	for i := 4; i < 8; i++ {
+		outcome := types.StatusCompleted
+		if i%2 == 0 {
+			outcome = types.StatusFailed
+		}
+		storeRuns(flakyRun(fmt.Sprintf("flaky%d", i), start.Add(time.Duration(i)*time.Hour), 1, outcome))
+	}
+	for i := 0; i < 2; i++ {
+		if err := detector.Check("sync"); err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+	}
+	if len(alerter.alerts) != 1 {
+		t.Fatalf("Check() sent %d alerts, want one once the job turned flaky", len(alerter.alerts))
+	}
+	if alerter.alerts[0].Score < 0.25 || alerter.alerts[0].Runs != 8 {
+		t.Errorf("alert flakiness = %+v, want a score over the threshold over 8 runs", *alerter.alerts[0])
+	}
+}
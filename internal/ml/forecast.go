@@ -0,0 +1,177 @@
+package ml
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+const (
+	// forecastHistory is how much history the hour-of-day profile is
+	// built from
+	forecastHistory = 7 * 24 * time.Hour
+
+	// forecastPersistence is the time constant with which the current
+	// deviation from the daily profile decays in the forecast
+	forecastPersistence = time.Hour
+
+	// minHourSamples is how many samples an hour of day needs before its
+	// own profile is used instead of the overall average
+	minHourSamples = 3
+
+	// forecastZ is the z-score of the 95% confidence band
+	forecastZ = 1.96
+
+	// minForecastGain is how much quieter, in load points, a forecast
+	// interval must be for a prediction to be moved to it
+	minForecastGain = 5.0
+)
+
+// loadStats accumulates the mean and variance of load samples
+type loadStats struct {
+	count int
+	mean  float64
+	m2    float64
+}
+
+func (ls *loadStats) add(value float64) {
+	ls.count++
+	delta := value - ls.mean
+	ls.mean += delta / float64(ls.count)
+	ls.m2 += delta * (value - ls.mean)
+}
+
+func (ls *loadStats) std() float64 {
+	if ls.count < 2 {
+		return 0
+	}
+	return math.Sqrt(ls.m2 / float64(ls.count-1))
+}
+
+// Forecast predicts the load at every interval up to horizon from now. The
+// forecast follows the hour-of-day profile of the past week, starting from
+// the current deviation from that profile and decaying towards it.
+func (lp *LSTMPredictor) Forecast(now time.Time, horizon, interval time.Duration) (*types.LoadForecast, error) {
+	if interval <= 0 || horizon < interval {
+		return nil, fmt.Errorf("invalid forecast horizon %s at interval %s", horizon, interval)
+	}
+
+	history, err := lp.store.GetSystemMetrics(now.Add(-forecastHistory), now, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var overall loadStats
+	var hourly [24]loadStats
+	for _, m := range history {
+		load := CombinedLoad(m)
+		overall.add(load)
+		hourly[m.Timestamp.Hour()].add(load)
+	}
+
+	profile := func(hour int) (float64, float64) {
+		if hourly[hour].count >= minHourSamples {
+			return hourly[hour].mean, hourly[hour].std()
+		}
+		if overall.count > 0 {
+			return overall.mean, overall.std()
+		}
+		return 50.0, 50.0 / forecastZ // No history: anything is possible
+	}
+
+	// History is returned newest first
+	deviation := 0.0
+	if len(history) > 0 {
+		latest := history[0]
+		expected, _ := profile(latest.Timestamp.Hour())
+		deviation = CombinedLoad(latest) - expected
+	}
+
+	forecast := &types.LoadForecast{GeneratedAt: now}
+	for at := now.Add(interval); !at.After(now.Add(horizon)); at = at.Add(interval) {
+		mean, std := profile(at.Hour())
+		persistence := math.Exp(-float64(at.Sub(now)) / float64(forecastPersistence))
+		load := clampLoad(mean + deviation*persistence)
+
+		forecast.Points = append(forecast.Points, types.ForecastPoint{
+			Time:  at,
+			Load:  load,
+			Lower: clampLoad(load - forecastZ*std),
+			Upper: clampLoad(load + forecastZ*std),
+		})
+	}
+
+	return forecast, nil
+}
+
+// applyForecast moves a prediction to the quietest forecast interval
+// within the model's delay range when that interval is expected to be
+// noticeably quieter than the predicted time
+func applyForecast(prediction *Prediction, forecast *types.LoadForecast) {
+	latest := prediction.OptimalTime.Add(maxDelayMinutes * time.Minute)
+
+	var atOptimal, quietest *types.ForecastPoint
+	for i := range forecast.Points {
+		point := &forecast.Points[i]
+		if point.Time.Before(prediction.PredictedAt) || point.Time.After(latest) {
+			continue
+		}
+		if atOptimal == nil || point.Time.Sub(prediction.OptimalTime).Abs() < atOptimal.Time.Sub(prediction.OptimalTime).Abs() {
+			atOptimal = point
+		}
+		if quietest == nil || point.Load < quietest.Load {
+			quietest = point
+		}
+	}
+
+	if atOptimal == nil {
+		return
+	}
+
+	prediction.ExpectedLoad = atOptimal.Load
+	if quietest.Load < atOptimal.Load-minForecastGain {
+		prediction.Reasoning += fmt.Sprintf("; forecast load %.1f%% at %s instead of %.1f%% at %s",
+			quietest.Load, quietest.Time.Format("15:04"), atOptimal.Load, atOptimal.Time.Format("15:04"))
+		if prediction.Explanation != nil {
+			prediction.Explanation.Forecast = &types.ForecastAdjustment{
+				From:     prediction.OptimalTime,
+				To:       quietest.Time,
+				FromLoad: atOptimal.Load,
+				ToLoad:   quietest.Load,
+			}
+		}
+		prediction.OptimalTime = quietest.Time
+		prediction.ExpectedLoad = quietest.Load
+	}
+}
+
+// summarizeForecastOutcomes computes the error and band coverage of
+// evaluated forecast points
+func summarizeForecastOutcomes(outcomes []*types.ForecastOutcome) *types.ForecastAccuracy {
+	accuracy := &types.ForecastAccuracy{}
+
+	var errorSum float64
+	var covered int
+	for _, outcome := range outcomes {
+		if outcome.ActualLoad < 0 {
+			continue // No samples around the forecast time
+		}
+		accuracy.Evaluated++
+		errorSum += math.Abs(outcome.ActualLoad - outcome.Load)
+		if outcome.ActualLoad >= outcome.Lower && outcome.ActualLoad <= outcome.Upper {
+			covered++
+		}
+	}
+
+	if accuracy.Evaluated > 0 {
+		accuracy.MAE = errorSum / float64(accuracy.Evaluated)
+		accuracy.Coverage = float64(covered) / float64(accuracy.Evaluated)
+	}
+	return accuracy
+}
+
+func clampLoad(load float64) float64 {
+	return math.Max(0, math.Min(100, load))
+}
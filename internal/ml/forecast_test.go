@@ -0,0 +1,100 @@
+package ml
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// historyStore serves a fixed metrics history, newest first
+type historyStore struct {
+	metrics []*types.SystemMetrics
+}
+
+func (hs *historyStore) StoreSystemMetrics(metrics *types.SystemMetrics) error {
+	return nil
+}
+
+func (hs *historyStore) GetSystemMetrics(start, end time.Time, limit int) ([]*types.SystemMetrics, error) {
+	return hs.metrics, nil
+}
+
+func TestForecastFollowsDailyProfile(t *testing.T) {
+	now := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+
+	// A week of samples every 10 minutes: busy at 12:00, quiet otherwise
+	store := &historyStore{}
+	for at := now.Add(-forecastHistory); at.Before(now); at = at.Add(10 * time.Minute) {
+		load := 20.0
+		if at.Hour() == 12 {
+			load = 80.0
+		}
+		store.metrics = append([]*types.SystemMetrics{{Timestamp: at, CPUUsage: load, MemoryUsage: load}}, store.metrics...)
+	}
+
+	forecast, err := NewLSTMPredictor(store).Forecast(now, 6*time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Forecast() error = %v", err)
+	}
+	if len(forecast.Points) != 6 {
+		t.Fatalf("Forecast() returned %d points, want 6", len(forecast.Points))
+	}
+
+	for _, point := range forecast.Points {
+		want := 20.0
+		if point.Time.Hour() == 12 {
+			want = 80.0
+		}
+		if point.Load < want-1 || point.Load > want+1 {
+			t.Errorf("forecast at %s = %.1f, want about %.1f", point.Time.Format("15:04"), point.Load, want)
+		}
+		if point.Lower > point.Load || point.Upper < point.Load {
+			t.Errorf("forecast at %s has band [%.1f, %.1f] around %.1f", point.Time.Format("15:04"), point.Lower, point.Upper, point.Load)
+		}
+	}
+
+	if _, err := NewLSTMPredictor(store).Forecast(now, time.Minute, time.Hour); err == nil {
+		t.Error("Forecast() with a horizon shorter than the interval succeeded")
+	}
+}
+
+func TestApplyForecast(t *testing.T) {
+	now := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	forecast := &types.LoadForecast{GeneratedAt: now, Points: []types.ForecastPoint{
+		{Time: now.Add(15 * time.Minute), Load: 70},
+		{Time: now.Add(30 * time.Minute), Load: 60},
+		{Time: now.Add(45 * time.Minute), Load: 30},
+		{Time: now.Add(3 * time.Hour), Load: 5},
+	}}
+
+	prediction := &Prediction{PredictedAt: now, OptimalTime: now.Add(20 * time.Minute), Reasoning: "High CPU usage"}
+	applyForecast(prediction, forecast)
+
+	if !prediction.OptimalTime.Equal(now.Add(45*time.Minute)) || prediction.ExpectedLoad != 30 {
+		t.Errorf("prediction moved to %s at load %.1f, want 10:45 at 30", prediction.OptimalTime.Format("15:04"), prediction.ExpectedLoad)
+	}
+	if !strings.Contains(prediction.Reasoning, "forecast load 30.0%") {
+		t.Errorf("reasoning %q does not mention the forecast", prediction.Reasoning)
+	}
+
+	// Close enough to the forecast at the predicted time: stay put
+	prediction = &Prediction{PredictedAt: now, OptimalTime: now.Add(45 * time.Minute)}
+	applyForecast(prediction, forecast)
+	if !prediction.OptimalTime.Equal(now.Add(45 * time.Minute)) {
+		t.Errorf("prediction moved to %s, want 10:45", prediction.OptimalTime.Format("15:04"))
+	}
+}
+
+func TestSummarizeForecastOutcomes(t *testing.T) {
+	accuracy := summarizeForecastOutcomes([]*types.ForecastOutcome{
+		{ForecastPoint: types.ForecastPoint{Load: 50, Lower: 40, Upper: 60}, ActualLoad: 55},
+		{ForecastPoint: types.ForecastPoint{Load: 50, Lower: 40, Upper: 60}, ActualLoad: 65},
+		{ForecastPoint: types.ForecastPoint{Load: 50, Lower: 40, Upper: 60}, ActualLoad: -1},
+	})
+
+	if accuracy.Evaluated != 2 || accuracy.MAE != 10 || accuracy.Coverage != 0.5 {
+		t.Errorf("summarizeForecastOutcomes() = %+v, want 2 evaluated, MAE 10, coverage 0.5", accuracy)
+	}
+}
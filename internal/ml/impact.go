@@ -0,0 +1,169 @@
+package ml
+
+import (
+	"time"
+
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// impactSettleSamples is how many collection intervals after a run the
+// load is sampled as the baseline after it
+const impactSettleSamples = 2
+
+// ImpactStore persists impact scores and looks up the metrics they are
+// computed from, see storage.Storage
+type ImpactStore interface {
+	GetSystemMetrics(start, end time.Time, limit int) ([]*types.SystemMetrics, error)
+	StoreJobImpact(impact *types.JobImpact) error
+}
+
+// ImpactScorer scores the load each finished execution added to the
+// system: the load while it ran less the load before and after it
+type ImpactScorer struct {
+	store    ImpactStore
+	interval time.Duration
+}
+
+// NewImpactScorer creates an impact scorer for metrics collected every
+// interval
+func NewImpactScorer(store ImpactStore, interval time.Duration) *ImpactScorer {
+	return &ImpactScorer{store: store, interval: interval}
+}
+
+// Attach scores every finished execution once the metrics after it have
+// been collected
+func (is *ImpactScorer) Attach(jobManager *jobs.Manager) {
+	jobManager.AddListener(is.observe)
+}
+
+// observe schedules the scoring of a finished execution
+func (is *ImpactScorer) observe(execution *jobs.JobExecution) {
+	if execution.Status != types.StatusCompleted && execution.Status != types.StatusFailed {
+		return
+	}
+	finished := *execution
+	time.AfterFunc(is.window()+is.interval, func() {
+		if _, err := is.Score(&finished); err != nil {
+			logrus.Errorf("Failed to score impact of execution %s of job %s: %v", finished.ID, finished.JobName, err)
+		}
+	})
+}
+
+// window is how far before and after a run the baseline load is sampled
+func (is *ImpactScorer) window() time.Duration {
+	return impactSettleSamples * is.interval
+}
+
+// Score computes and stores the impact of a finished execution. It returns
+// nil if the load during or around the execution was not measured, e.g.
+// for runs shorter than the collection interval without a snapshot.
+func (is *ImpactScorer) Score(execution *types.JobExecution) (*types.JobImpact, error) {
+	during, err := is.store.GetSystemMetrics(execution.StartTime, execution.EndTime, 0)
+	if err != nil {
+		return nil, err
+	}
+	after, err := is.store.GetSystemMetrics(execution.EndTime.Add(time.Nanosecond), execution.EndTime.Add(is.window()), 0)
+	if err != nil {
+		return nil, err
+	}
+	var before []*types.SystemMetrics
+	if execution.StartMetrics != nil {
+		before = []*types.SystemMetrics{execution.StartMetrics.SystemMetrics()}
+	} else {
+		// The latest sample before the start
+		before, err = is.store.GetSystemMetrics(execution.StartTime.Add(-is.window()), execution.StartTime.Add(-time.Nanosecond), 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The end snapshot is a sample taken while the job still ran
+	if end := execution.EndMetrics; end != nil && !end.Timestamp.Before(execution.StartTime) &&
+		!sampledAt(during, end.Timestamp) {
+		during = append(during, end.SystemMetrics())
+	}
+
+	impact := scoreImpact(execution, before, during, after)
+	if impact == nil {
+		logrus.Debugf("Impact of execution %s of job %s not measurable", execution.ID, execution.JobName)
+		return nil, nil
+	}
+	if err := is.store.StoreJobImpact(impact); err != nil {
+		return nil, err
+	}
+	return impact, nil
+}
+
+// scoreImpact computes the impact of an execution from the samples taken
+// before, during and after it. The baseline averages the load before and
+// after the run, so a load trend across the run is not attributed to it.
+func scoreImpact(execution *types.JobExecution, before, during, after []*types.SystemMetrics) *types.JobImpact {
+	if len(during) == 0 {
+		return nil
+	}
+	var baselines []load
+	if len(before) > 0 {
+		baselines = append(baselines, averageLoad(before))
+	}
+	if len(after) > 0 {
+		baselines = append(baselines, averageLoad(after))
+	}
+	if len(baselines) == 0 {
+		return nil
+	}
+	var baseline load
+	for _, b := range baselines {
+		baseline.cpu += b.cpu / float64(len(baselines))
+		baseline.memory += b.memory / float64(len(baselines))
+		baseline.load1 += b.load1 / float64(len(baselines))
+	}
+
+	running := averageLoad(during)
+	impact := &types.JobImpact{
+		ExecutionID: execution.ID,
+		JobName:     execution.JobName,
+		Namespace:   execution.Namespace,
+		Timestamp:   execution.EndTime,
+		CPUDelta:    running.cpu - baseline.cpu,
+		MemoryDelta: running.memory - baseline.memory,
+		LoadDelta:   running.load1 - baseline.load1,
+		Samples:     len(during),
+	}
+	impact.Score = (impact.CPUDelta + impact.MemoryDelta) / 2
+	return impact
+}
+
+// load is the CPU and memory usage and 1-minute load average of samples
+type load struct {
+	cpu, memory, load1 float64
+}
+
+func averageLoad(samples []*types.SystemMetrics) load {
+	var average load
+	for _, sample := range samples {
+		average.cpu += sample.CPUUsage / float64(len(samples))
+		average.memory += sample.MemoryUsage / float64(len(samples))
+		average.load1 += sample.LoadAvg.Load1 / float64(len(samples))
+	}
+	return average
+}
+
+// sampledAt reports whether one of the samples was taken at a time
+func sampledAt(samples []*types.SystemMetrics, at time.Time) bool {
+	for _, sample := range samples {
+		if sample.Timestamp.Equal(at) {
+			return true
+		}
+	}
+	return false
+}
+
+// ImpactClass returns the job type an average impact score measures a job
+// to be: "resource-intensive" from the threshold on, "light" below it
+func ImpactClass(avgScore, threshold float64) string {
+	if avgScore >= threshold {
+		return "resource-intensive"
+	}
+	return "light"
+}
@@ -0,0 +1,78 @@
+package ml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// impactTestStore serves metrics from a memory store and keeps the stored
+// impacts
+type impactTestStore struct {
+	*storage.MemoryStore
+	impacts []*types.JobImpact
+}
+
+func (s *impactTestStore) StoreJobImpact(impact *types.JobImpact) error {
+	s.impacts = append(s.impacts, impact)
+	return nil
+}
+
+func TestImpactScorerScore(t *testing.T) {
+	store := &impactTestStore{MemoryStore: storage.NewMemoryStore()}
+	start := time.Date(2024, 6, 3, 2, 0, 0, 0, time.UTC)
+	interval := 10 * time.Second
+
+	// Idle at 10% CPU before, 90% while running, 30% after: the baseline
+	// averages 20% and the job adds 70 points of CPU
+	samples := []struct {
+		offset time.Duration
+		cpu    float64
+	}{
+		{-10 * time.Second, 10},
+		{10 * time.Second, 90},
+		{20 * time.Second, 90},
+		{40 * time.Second, 30},
+	}
+	for _, sample := range samples {
+		metrics := &types.SystemMetrics{Timestamp: start.Add(sample.offset), CPUUsage: sample.cpu, MemoryUsage: 50}
+		if err := store.StoreSystemMetrics(metrics); err != nil {
+			t.Fatalf("StoreSystemMetrics() error = %v", err)
+		}
+	}
+
+	scorer := NewImpactScorer(store, interval)
+	execution := &types.JobExecution{ID: "a", JobName: "backup", Namespace: "ops", Status: types.StatusCompleted,
+		StartTime: start, EndTime: start.Add(30 * time.Second)}
+	impact, err := scorer.Score(execution)
+	if err != nil {
+		t.Fatalf("Score() error = %v", err)
+	}
+	if impact == nil {
+		t.Fatal("Score() = nil, want an impact")
+	}
+	if impact.CPUDelta != 70 || impact.MemoryDelta != 0 || impact.Score != 35 || impact.Samples != 2 {
+		t.Errorf("Score() = %+v, want a CPU delta of 70 and a score of 35 over 2 samples", impact)
+	}
+	if len(store.impacts) != 1 || store.impacts[0].ExecutionID != "a" || store.impacts[0].Namespace != "ops" {
+		t.Errorf("stored impacts = %+v, want the impact of execution a", store.impacts)
+	}
+
+	// A run between two samples is not measurable
+	short := &types.JobExecution{ID: "b", JobName: "backup", Status: types.StatusCompleted,
+		StartTime: start.Add(time.Second), EndTime: start.Add(2 * time.Second)}
+	if impact, err := scorer.Score(short); err != nil || impact != nil {
+		t.Errorf("Score() of a run without samples = %+v, %v, want nil", impact, err)
+	}
+}
+
+func TestImpactClass(t *testing.T) {
+	if got := ImpactClass(12, 10); got != "resource-intensive" {
+		t.Errorf("ImpactClass(12, 10) = %s, want resource-intensive", got)
+	}
+	if got := ImpactClass(3, 10); got != "light" {
+		t.Errorf("ImpactClass(3, 10) = %s, want light", got)
+	}
+}
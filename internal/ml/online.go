@@ -0,0 +1,230 @@
+package ml
+
+import (
+	"math"
+	"time"
+
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/monitoring"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// featureHistory is how long feature vectors of collected samples are
+	// kept to look up the conditions a job started under
+	featureHistory = 24 * time.Hour
+
+	// maxFeatureAge is how much older than a job's start the closest
+	// sample may be for the job to be learned from
+	maxFeatureAge = 5 * time.Minute
+
+	// maxDelayMinutes is the largest delay the model predicts
+	maxDelayMinutes = 60.0
+
+	// modelSaveInterval is how often the model is persisted while it
+	// learns online; what it learned since is saved when the engine stops
+	modelSaveInterval = 10 * time.Minute
+)
+
+// featureSample is the feature vector of a collected metrics sample
+type featureSample struct {
+	timestamp time.Time
+	features  []float64
+}
+
+// durationBaseline tracks the typical duration of a job, against which the
+// impact of the conditions an execution ran under is measured
+type durationBaseline struct {
+	count int
+	mean  float64
+}
+
+// Attach feeds every finished execution into the built-in model, paired
+// with the system conditions at the time the job started: the metrics
+// snapshot of the execution, or the closest collected sample before it
+func (e *Engine) Attach(monitor *monitoring.Monitor, jobManager *jobs.Manager) {
+	monitor.AddListener(e.observeMetrics)
+	jobManager.AddListener(e.learnFromExecution)
+}
+
+// observeMetrics remembers the feature vector of a collected sample
+func (e *Engine) observeMetrics(metrics *monitoring.SystemMetrics) {
+	sample := featureSample{
+		timestamp: metrics.Timestamp,
+		features:  featuresAt(metrics, metrics.Timestamp),
+	}
+
+	e.onlineMutex.Lock()
+	defer e.onlineMutex.Unlock()
+
+	e.history = append(e.history, sample)
+	cutoff := sample.timestamp.Add(-featureHistory)
+	drop := 0
+	for drop < len(e.history) && e.history[drop].timestamp.Before(cutoff) {
+		drop++
+	}
+	e.history = e.history[drop:]
+}
+
+// featuresBefore returns the feature vector of the latest sample collected
+// at or before the given time, or nil if there is no recent enough sample
+func (e *Engine) featuresBefore(at time.Time) []float64 {
+	for i := len(e.history) - 1; i >= 0; i-- {
+		sample := e.history[i]
+		if sample.timestamp.After(at) {
+			continue
+		}
+		if at.Sub(sample.timestamp) > maxFeatureAge {
+			return nil
+		}
+		return sample.features
+	}
+	return nil
+}
+
+// learnFromExecution updates the model with the outcome of a finished
+// execution. Executions that took longer than usual or failed teach the
+// model to delay jobs under similar conditions.
+func (e *Engine) learnFromExecution(execution *jobs.JobExecution) {
+	cfg := e.config.OnlineLearning
+	if !cfg.Enabled || e.predictor != Predictor(e.model) {
+		return
+	}
+	if execution.Status != types.StatusCompleted && execution.Status != types.StatusFailed {
+		return
+	}
+
+	e.onlineMutex.Lock()
+	// The snapshot taken at the start is exact; collected samples are the
+	// fallback for executions without one
+	var features []float64
+	if snapshot := execution.StartMetrics; snapshot != nil {
+		features = featuresAt(snapshot.SystemMetrics(), execution.StartTime)
+	} else {
+		features = e.featuresBefore(execution.StartTime)
+	}
+	target, ok := e.impactTarget(execution)
+	e.onlineMutex.Unlock()
+
+	if features == nil || !ok {
+		return
+	}
+
+	e.model.learn(features, target, cfg.LearningRate, decayRate(cfg.HalfLife))
+	logrus.Debugf("ML model learned from execution of %s (target delay %.1f minutes)", execution.JobName, target)
+
+	e.onlineMutex.Lock()
+	e.unsavedLearning = true
+	due := time.Since(e.lastModelSave) >= modelSaveInterval
+	e.onlineMutex.Unlock()
+	if due {
+		e.saveLearnedModel()
+	}
+}
+
+// saveLearnedModel persists the model if it learned from executions since
+// it was last saved
+func (e *Engine) saveLearnedModel() {
+	e.onlineMutex.Lock()
+	if !e.unsavedLearning {
+		e.onlineMutex.Unlock()
+		return
+	}
+	e.unsavedLearning = false
+	e.lastModelSave = time.Now()
+	e.onlineMutex.Unlock()
+
+	if err := e.saveModel(); err != nil {
+		logrus.Errorf("Failed to save ML model: %v", err)
+	}
+}
+
+// impactTarget returns the delay in minutes the model should have
+// predicted for the conditions an execution ran under: none for a run of
+// typical duration, growing with how much longer it took, and the maximum
+// for a failure. It must be called with the online lock held.
+func (e *Engine) impactTarget(execution *jobs.JobExecution) (float64, bool) {
+	if execution.Status == types.StatusFailed {
+		return maxDelayMinutes, true
+	}
+
+	baseline, ok := e.durations[execution.JobName]
+	if !ok {
+		baseline = &durationBaseline{}
+		e.durations[execution.JobName] = baseline
+	}
+
+	var target float64
+	learned := baseline.count > 0 && baseline.mean > 0
+	if learned {
+		ratio := execution.Duration / baseline.mean
+		target = math.Max(0, math.Min(maxDelayMinutes, (ratio-1)*maxDelayMinutes))
+	}
+
+	baseline.count++
+	alpha := math.Max(1/float64(baseline.count), decayRate(e.config.OnlineLearning.HalfLife))
+	baseline.mean += alpha * (execution.Duration - baseline.mean)
+
+	return target, learned
+}
+
+// learn performs a single gradient step towards the target delay. Feature
+// statistics are updated with exponential decay, so earlier regimes fade.
+func (m *SimpleMLModel) learn(features []float64, target, rate, decay float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.trained || len(features) != len(m.weights) {
+		return
+	}
+
+	m.samples++
+	alpha := math.Max(1/float64(m.samples), decay)
+	for i, x := range features {
+		diff := x - m.featureMean[i]
+		m.featureMean[i] += alpha * diff
+		variance := (1 - alpha) * (m.featureStd[i]*m.featureStd[i] + alpha*diff*diff)
+		m.featureStd[i] = math.Sqrt(variance)
+	}
+
+	// Squared error on the sigmoid output, scaled to the unit interval
+	output := m.predictLocked(features) / maxDelayMinutes
+	gradient := (output - target/maxDelayMinutes) * output * (1 - output)
+	for i, x := range m.normalize(features) {
+		m.weights[i] -= rate * gradient * x
+	}
+}
+
+// normalize standardizes features with the model's running statistics.
+// Features without a spread yet are only centered.
+func (m *SimpleMLModel) normalize(features []float64) []float64 {
+	if len(m.featureMean) != len(features) || len(m.featureStd) != len(features) {
+		return features
+	}
+
+	normalized := make([]float64, len(features))
+	for i, x := range features {
+		normalized[i] = x - m.featureMean[i]
+		if m.featureStd[i] > 0 {
+			normalized[i] /= m.featureStd[i]
+		}
+	}
+	return normalized
+}
+
+// samplesSeen returns how many executions the model learned from
+func (m *SimpleMLModel) samplesSeen() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.samples
+}
+
+// decayRate converts a half-life in samples into the minimum weight of a
+// new sample in an exponentially weighted average
+func decayRate(halfLife int) float64 {
+	if halfLife <= 0 {
+		return 0
+	}
+	return 1 - math.Pow(0.5, 1/float64(halfLife))
+}
@@ -0,0 +1,108 @@
+package ml
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/monitoring"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestLearnFromExecution(t *testing.T) {
+	engine, _ := New(config.MLConfig{
+		OnlineLearning: config.OnlineLearningConfig{Enabled: true, LearningRate: 0.5, HalfLife: 50},
+	})
+	engine.initializeHeuristics()
+
+	start := time.Now()
+	engine.observeMetrics(&monitoring.SystemMetrics{Timestamp: start.Add(-time.Minute), CPUUsage: 90, MemoryUsage: 80})
+	features := engine.featuresBefore(start)
+	if features == nil {
+		t.Fatal("featuresBefore() found no sample")
+	}
+	if engine.featuresBefore(start.Add(-2*time.Minute)) != nil {
+		t.Error("featuresBefore() returned a sample collected after the time")
+	}
+
+	execution := func(duration float64, status types.JobStatus) *types.JobExecution {
+		return &types.JobExecution{JobName: "backup", StartTime: start, Duration: duration, Status: status}
+	}
+
+	// The first run only establishes the typical duration
+	engine.learnFromExecution(execution(100, types.StatusCompleted))
+	if got := engine.model.samplesSeen(); got != 0 {
+		t.Fatalf("samples seen after first run = %d, want 0", got)
+	}
+
+	before := engine.model.predict(features)
+	for i := 0; i < 20; i++ {
+		engine.learnFromExecution(execution(0, types.StatusFailed))
+	}
+	if got := engine.model.samplesSeen(); got != 20 {
+		t.Errorf("samples seen = %d, want 20", got)
+	}
+	if after := engine.model.predict(features); after <= before {
+		t.Errorf("predicted delay after failures = %.2f, want more than %.2f", after, before)
+	}
+}
+
+func TestImpactTarget(t *testing.T) {
+	engine, _ := New(config.MLConfig{OnlineLearning: config.OnlineLearningConfig{HalfLife: 200}})
+
+	run := func(duration float64) (float64, bool) {
+		return engine.impactTarget(&types.JobExecution{JobName: "report", Duration: duration, Status: types.StatusCompleted})
+	}
+
+	if _, ok := run(60); ok {
+		t.Error("impactTarget() learned from the first run")
+	}
+	if target, ok := run(60); !ok || target != 0 {
+		t.Errorf("impactTarget() for a typical run = %v, %v, want 0", target, ok)
+	}
+	if target, _ := run(90); target != 30 {
+		t.Errorf("impactTarget() for a 50%% slower run = %v, want 30", target)
+	}
+}
+
+func TestLearnedModelSavedPeriodically(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arcron_model")
+	engine, _ := New(config.MLConfig{
+		ModelPath:      path,
+		UpdateInterval: time.Hour,
+		OnlineLearning: config.OnlineLearningConfig{Enabled: true, LearningRate: 0.5, HalfLife: 50},
+	})
+	engine.initializeHeuristics()
+	if err := engine.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	start := time.Now()
+	engine.observeMetrics(&monitoring.SystemMetrics{Timestamp: start.Add(-time.Minute), CPUUsage: 90, MemoryUsage: 80})
+	learn := func(runs int) {
+		for i := 0; i < runs; i++ {
+			engine.learnFromExecution(&types.JobExecution{JobName: "backup", StartTime: start, Status: types.StatusFailed})
+		}
+	}
+	savedSamples := func() int {
+		loaded, _ := New(config.MLConfig{ModelPath: path})
+		if err := loaded.loadModel(); err != nil {
+			t.Fatalf("loadModel() error = %v", err)
+		}
+		return loaded.model.samplesSeen()
+	}
+
+	// The first failure is learned from and saved, the rest wait for the
+	// interval
+	learn(5)
+	if got := savedSamples(); got != 1 {
+		t.Errorf("saved samples = %d, want 1 until the save interval passed", got)
+	}
+
+	engine.Stop()
+	if got := savedSamples(); got != 5 {
+		t.Errorf("saved samples after Stop = %d, want 5", got)
+	}
+}
@@ -0,0 +1,164 @@
+package ml
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// modelFormatVersion is bumped whenever the model representation changes
+// in a way that makes previously saved models unusable
+const modelFormatVersion = 1
+
+// featureNames lists the features in the order extractFeatures produces
+// them. A saved model trained on a different feature set is retrained.
+var featureNames = []string{
+	"cpu_usage",
+	"memory_usage",
+	"disk_io",
+	"network_io",
+	"load_avg",
+	"gpu_usage",
+	"cpu_temperature",
+	"hour_of_day",
+	"day_of_week",
+}
+
+// modelState is the on-disk representation of a SimpleMLModel
+type modelState struct {
+	Version     int       `json:"version"`
+	Features    []string  `json:"features"`
+	Weights     []float64 `json:"weights"`
+	FeatureMean []float64 `json:"feature_mean"`
+	FeatureStd  []float64 `json:"feature_std"`
+	Samples     int       `json:"samples"`
+	TrainedAt   time.Time `json:"trained_at"`
+	SavedAt     time.Time `json:"saved_at"`
+}
+
+// errIncompatibleModel is returned for saved models from another format
+// version or feature set
+var errIncompatibleModel = errors.New("incompatible model")
+
+// saveModel writes the model state to the configured model path. The file
+// is replaced atomically so a crash never leaves a truncated model behind.
+func (e *Engine) saveModel() error {
+	if e.config.ModelPath == "" {
+		return nil
+	}
+
+	e.saveMutex.Lock()
+	defer e.saveMutex.Unlock()
+
+	e.model.mutex.RLock()
+	state := modelState{
+		Version:     modelFormatVersion,
+		Features:    featureNames,
+		Weights:     e.model.weights,
+		FeatureMean: e.model.featureMean,
+		FeatureStd:  e.model.featureStd,
+		Samples:     e.model.samples,
+		TrainedAt:   e.lastTraining,
+		SavedAt:     time.Now(),
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	e.model.mutex.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode model: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(e.config.ModelPath), 0755); err != nil {
+		return fmt.Errorf("failed to create model directory: %v", err)
+	}
+
+	tmp := e.config.ModelPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write model: %v", err)
+	}
+	if err := os.Rename(tmp, e.config.ModelPath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write model: %v", err)
+	}
+
+	return nil
+}
+
+// loadModel reads the model state from the configured model path. It
+// returns an error wrapping os.ErrNotExist if no model was saved yet and
+// errIncompatibleModel if the saved model cannot be used.
+func (e *Engine) loadModel() error {
+	data, err := os.ReadFile(e.config.ModelPath)
+	if err != nil {
+		return err
+	}
+
+	var state modelState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("%w: %v", errIncompatibleModel, err)
+	}
+
+	if state.Version != modelFormatVersion {
+		return fmt.Errorf("%w: format version %d, want %d", errIncompatibleModel, state.Version, modelFormatVersion)
+	}
+	if !sameFeatures(state.Features, featureNames) || len(state.Weights) != len(featureNames) ||
+		len(state.FeatureMean) != len(featureNames) || len(state.FeatureStd) != len(featureNames) {
+		return fmt.Errorf("%w: trained on features %v", errIncompatibleModel, state.Features)
+	}
+
+	e.model.mutex.Lock()
+	e.model.weights = state.Weights
+	e.model.featureMean = state.FeatureMean
+	e.model.featureStd = state.FeatureStd
+	e.model.samples = state.Samples
+	e.model.trained = true
+	e.model.mutex.Unlock()
+	e.lastTraining = state.TrainedAt
+
+	return nil
+}
+
+// restoreModel loads the saved model, retraining from scratch when there
+// is none or it is incompatible with this version
+func (e *Engine) restoreModel() {
+	if e.config.ModelPath == "" {
+		e.initializeHeuristics()
+		return
+	}
+
+	err := e.loadModel()
+	if err == nil {
+		logrus.Infof("Loaded ML model from %s (trained %s)", e.config.ModelPath, e.lastTraining.Format(time.RFC3339))
+		return
+	}
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		logrus.Info("No saved ML model found, training a new one")
+	case errors.Is(err, errIncompatibleModel):
+		logrus.Warnf("Saved ML model at %s is %v, retraining", e.config.ModelPath, err)
+	default:
+		logrus.Errorf("Failed to load ML model from %s: %v, retraining", e.config.ModelPath, err)
+	}
+
+	e.initializeHeuristics()
+	if err := e.trainModel(); err != nil {
+		logrus.Errorf("Failed to train model: %v", err)
+	}
+}
+
+func sameFeatures(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package ml
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+func TestModelPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models", "arcron_model")
+
+	saved, _ := New(config.MLConfig{ModelPath: path})
+	saved.initializeHeuristics()
+	if err := saved.trainModel(); err != nil {
+		t.Fatalf("trainModel() error = %v", err)
+	}
+
+	loaded, _ := New(config.MLConfig{ModelPath: path})
+	if err := loaded.loadModel(); err != nil {
+		t.Fatalf("loadModel() error = %v", err)
+	}
+	if !loaded.model.trained || !reflect.DeepEqual(loaded.model.weights, saved.model.weights) {
+		t.Errorf("loaded weights = %v, want %v", loaded.model.weights, saved.model.weights)
+	}
+	if !loaded.lastTraining.Equal(saved.lastTraining) {
+		t.Errorf("loaded training time = %v, want %v", loaded.lastTraining, saved.lastTraining)
+	}
+}
+
+func TestIncompatibleModelIsRetrained(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "arcron_model")
+	if err := os.WriteFile(path, []byte(`{"version": 0, "weights": [1, 2]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine, _ := New(config.MLConfig{ModelPath: path})
+	if err := engine.loadModel(); !errors.Is(err, errIncompatibleModel) {
+		t.Fatalf("loadModel() error = %v, want incompatible model", err)
+	}
+
+	engine.restoreModel()
+	if !engine.model.trained || len(engine.model.weights) != len(featureNames) {
+		t.Errorf("restored model has %d weights, want %d", len(engine.model.weights), len(featureNames))
+	}
+	if err := engine.loadModel(); err != nil {
+		t.Errorf("retrained model was not saved: %v", err)
+	}
+}
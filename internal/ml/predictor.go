@@ -0,0 +1,60 @@
+package ml
+
+import (
+	"fmt"
+)
+
+// Predictor is a model backend predicting how many minutes from now a job
+// should run. The built-in SimpleMLModel is the default; other backends let
+// teams bring their own models without changing the engine.
+type Predictor interface {
+	// Name identifies the backend in prediction reasoning
+	Name() string
+	// Ready reports whether the backend can serve predictions; the engine
+	// uses the heuristics until it is
+	Ready() bool
+	// Predict returns a prediction for the features, in the order listed
+	// by featureNames
+	Predict(features []float64) (PredictorResult, error)
+}
+
+// PredictorResult is the output of a Predictor
+type PredictorResult struct {
+	DelayMinutes float64 `json:"delay_minutes"`
+	Confidence   float64 `json:"confidence"`
+}
+
+// newPredictor creates the predictor selected by the configuration
+func (e *Engine) newPredictor() (Predictor, error) {
+	switch e.config.Backend {
+	case "", "builtin":
+		return e.model, nil
+	case "remote":
+		return NewRemotePredictor(e.config.Remote)
+	default:
+		return nil, fmt.Errorf("unknown ML backend: %s", e.config.Backend)
+	}
+}
+
+// Name implements Predictor
+func (m *SimpleMLModel) Name() string {
+	return "builtin"
+}
+
+// Ready implements Predictor
+func (m *SimpleMLModel) Ready() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.trained
+}
+
+// Predict implements Predictor
+func (m *SimpleMLModel) Predict(features []float64) (PredictorResult, error) {
+	if len(features) != len(featureNames) {
+		return PredictorResult{}, fmt.Errorf("model expects %d features, got %d", len(featureNames), len(features))
+	}
+	return PredictorResult{
+		DelayMinutes: m.predict(features),
+		Confidence:   0.7, // Placeholder confidence
+	}, nil
+}
@@ -0,0 +1,110 @@
+package ml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// minLoadImprovement is the load reduction, in percentage points, a new
+// time has to offer before a schedule change is recommended
+const minLoadImprovement = 10.0
+
+// ScheduleRecommendation suggests a better daily run time for a job based
+// on the seasonal load pattern, together with the data supporting it
+type ScheduleRecommendation struct {
+	JobName             string  `json:"job_name"`
+	CurrentSchedule     string  `json:"current_schedule"`
+	RecommendedSchedule string  `json:"recommended_schedule,omitempty"`
+	CurrentHour         int     `json:"current_hour"`
+	RecommendedHour     int     `json:"recommended_hour"`
+	CurrentLoad         float64 `json:"current_load"`
+	RecommendedLoad     float64 `json:"recommended_load"`
+	PatternType         string  `json:"pattern_type"`
+	PatternStrength     float64 `json:"pattern_strength"`
+	Reason              string  `json:"reason"`
+}
+
+// Actionable reports whether the recommendation proposes a new schedule
+func (r *ScheduleRecommendation) Actionable() bool {
+	return r.RecommendedSchedule != ""
+}
+
+// RecommendSchedule recommends moving a job that runs at a fixed hour to
+// the hour of day with the lowest typical load. Only cron expressions with
+// a single minute and hour are considered; more frequent jobs have no
+// better time of day.
+func RecommendSchedule(jobName, schedule string, pattern *SeasonalPattern) *ScheduleRecommendation {
+	rec := &ScheduleRecommendation{
+		JobName:         jobName,
+		CurrentSchedule: schedule,
+		CurrentHour:     -1,
+		RecommendedHour: -1,
+	}
+
+	if pattern == nil || len(pattern.HourlyLoad) == 0 {
+		rec.Reason = "not enough metrics history to detect a load pattern"
+		return rec
+	}
+	rec.PatternType = pattern.Type
+	rec.PatternStrength = pattern.Strength
+
+	fields := strings.Fields(schedule)
+	minuteIdx, hourIdx := 0, 1
+	switch len(fields) {
+	case 5:
+	case 6:
+		// Cron expressions with a leading seconds field
+		minuteIdx, hourIdx = 1, 2
+	default:
+		rec.Reason = "schedule is not a cron expression"
+		return rec
+	}
+
+	minute, err := strconv.Atoi(fields[minuteIdx])
+	if err != nil {
+		rec.Reason = "schedule does not run at a fixed minute"
+		return rec
+	}
+	hour, err := strconv.Atoi(fields[hourIdx])
+	if err != nil {
+		rec.Reason = "schedule does not run at a fixed hour"
+		return rec
+	}
+	rec.CurrentHour = hour
+
+	currentLoad, ok := pattern.HourlyLoad[hour]
+	if !ok {
+		rec.Reason = fmt.Sprintf("no load history for %02d:00", hour)
+		return rec
+	}
+	rec.CurrentLoad = currentLoad
+
+	bestHour := hour
+	for h, load := range pattern.HourlyLoad {
+		if load < pattern.HourlyLoad[bestHour] || (load == pattern.HourlyLoad[bestHour] && h < bestHour) {
+			bestHour = h
+		}
+	}
+	rec.RecommendedHour = bestHour
+	rec.RecommendedLoad = pattern.HourlyLoad[bestHour]
+
+	if currentLoad-rec.RecommendedLoad < minLoadImprovement {
+		rec.Reason = fmt.Sprintf("%02d:%02d is already within %.0f points of the quietest hour",
+			hour, minute, minLoadImprovement)
+		return rec
+	}
+
+	fields[hourIdx] = strconv.Itoa(bestHour)
+	rec.RecommendedSchedule = strings.Join(fields, " ")
+	rec.Reason = fmt.Sprintf("move %s to %02d:%02d, typical load %.1f%% instead of %.1f%% at %02d:%02d",
+		jobName, bestHour, minute, rec.RecommendedLoad, currentLoad, hour, minute)
+	for _, low := range pattern.LowHours {
+		if low == bestHour {
+			rec.Reason += " (typical low window)"
+			break
+		}
+	}
+
+	return rec
+}
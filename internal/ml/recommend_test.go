@@ -0,0 +1,34 @@
+package ml
+
+import "testing"
+
+func TestRecommendSchedule(t *testing.T) {
+	pattern := &SeasonalPattern{
+		Type:       "daily",
+		HourlyLoad: map[int]float64{2: 70, 3: 20, 4: 25, 14: 90},
+		LowHours:   []int{3},
+	}
+
+	rec := RecommendSchedule("backup", "30 2 * * *", pattern)
+	if !rec.Actionable() || rec.RecommendedSchedule != "30 3 * * *" {
+		t.Fatalf("RecommendSchedule() = %+v, want 30 3 * * *", rec)
+	}
+	if rec.CurrentLoad != 70 || rec.RecommendedLoad != 20 {
+		t.Errorf("loads = %v, %v, want 70, 20", rec.CurrentLoad, rec.RecommendedLoad)
+	}
+
+	rec = RecommendSchedule("backup", "0 30 2 * * *", pattern)
+	if rec.RecommendedSchedule != "0 30 3 * * *" {
+		t.Errorf("RecommendSchedule() with seconds = %q, want 0 30 3 * * *", rec.RecommendedSchedule)
+	}
+
+	if rec := RecommendSchedule("report", "0 4 * * *", pattern); rec.Actionable() {
+		t.Errorf("RecommendSchedule() near the quietest hour = %q, want none", rec.RecommendedSchedule)
+	}
+	if rec := RecommendSchedule("health", "*/5 * * * *", pattern); rec.Actionable() {
+		t.Errorf("RecommendSchedule() for a frequent job = %q, want none", rec.RecommendedSchedule)
+	}
+	if rec := RecommendSchedule("backup", "30 2 * * *", nil); rec.Actionable() {
+		t.Errorf("RecommendSchedule() without a pattern = %q, want none", rec.RecommendedSchedule)
+	}
+}
@@ -0,0 +1,220 @@
+package ml
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// RegressionStore reads the runs of jobs and persists the duration
+// regressions detected in them, see storage.Storage
+type RegressionStore interface {
+	GetPreviousJobExecutions(jobName string, before time.Time, limit int) ([]*types.JobExecution, error)
+	StoreDurationRegression(regression *types.DurationRegression) error
+}
+
+// RegressionAlerter sends the alerts of duration regressions, see
+// alerts.Manager
+type RegressionAlerter interface {
+	SendRegressionAlert(regression *types.DurationRegression) error
+}
+
+// RegressionDetector tests the durations of the latest completed runs of
+// a job for an upward trend after each of its runs, so a job slowing down
+// is reported before its runs time out
+type RegressionDetector struct {
+	cfg      config.DurationRegressionConfig
+	store    RegressionStore
+	alerts   RegressionAlerter
+	timeout  func(jobName string) time.Duration
+	mutex    sync.Mutex
+	reported map[string]time.Time // last regression of each job
+}
+
+// NewRegressionDetector creates a duration regression detector
+func NewRegressionDetector(cfg config.DurationRegressionConfig, store RegressionStore) *RegressionDetector {
+	return &RegressionDetector{
+		cfg:      cfg,
+		store:    store,
+		reported: make(map[string]time.Time),
+	}
+}
+
+// Attach tests every job after each completed run of the job manager,
+// alerting on regressions through alerts, which may be nil
+func (rd *RegressionDetector) Attach(jobManager *jobs.Manager, alerts RegressionAlerter) {
+	if !rd.cfg.Enabled {
+		return
+	}
+	rd.alerts = alerts
+	rd.timeout = jobManager.JobTimeout
+	jobManager.AddListener(rd.observe)
+}
+
+// observe checks a job in the background after a completed run
+func (rd *RegressionDetector) observe(execution *jobs.JobExecution) {
+	if execution.Status != types.StatusCompleted {
+		return
+	}
+	finished := *execution
+	go func() {
+		if _, err := rd.Check(&finished); err != nil {
+			logrus.Errorf("Failed to check job %s for a duration regression: %v", finished.JobName, err)
+		}
+	}()
+}
+
+// Check tests the completed runs of a job up to execution for a duration
+// regression, storing and alerting on it unless the job was reported
+// within the cooldown. The regression is returned, or nil if none was
+// reported.
+func (rd *RegressionDetector) Check(execution *types.JobExecution) (*types.DurationRegression, error) {
+	previous, err := rd.store.GetPreviousJobExecutions(execution.JobName, execution.StartTime, 2*rd.cfg.Window)
+	if err != nil {
+		return nil, err
+	}
+	// Newest first, the checked run included
+	durations := []float64{execution.Duration}
+	for _, run := range previous {
+		if len(durations) == rd.cfg.Window {
+			break
+		}
+		if run.Status == types.StatusCompleted && run.ID != execution.ID {
+			durations = append(durations, run.Duration)
+		}
+	}
+	if len(durations) < rd.cfg.MinRuns {
+		return nil, nil
+	}
+	for i, j := 0, len(durations)-1; i < j; i, j = i+1, j-1 {
+		durations[i], durations[j] = durations[j], durations[i]
+	}
+
+	regression := DetectDurationRegression(durations, rd.cfg.Confidence, rd.cfg.MinIncrease)
+	if regression == nil {
+		return nil, nil
+	}
+	now := time.Now()
+	rd.mutex.Lock()
+	if last, ok := rd.reported[execution.JobName]; ok && now.Sub(last) < rd.cfg.Cooldown {
+		rd.mutex.Unlock()
+		return nil, nil
+	}
+	rd.reported[execution.JobName] = now
+	rd.mutex.Unlock()
+
+	regression.JobName = execution.JobName
+	regression.ExecutionID = execution.ID
+	regression.DetectedAt = now
+	if rd.timeout != nil {
+		if timeout := rd.timeout(execution.JobName); timeout > 0 {
+			regression.Timeout = timeout.Seconds()
+			if remaining := regression.Timeout - execution.Duration; remaining > 0 {
+				regression.RunsToTimeout = int(math.Ceil(remaining / regression.Slope))
+			}
+		}
+	}
+	logrus.Warnf("Job %s has slowed down by %.0f%% over its last %d runs", execution.JobName, regression.IncreasePercent, regression.Runs)
+
+	if err := rd.store.StoreDurationRegression(regression); err != nil {
+		logrus.Errorf("Failed to store duration regression of job %s: %v", execution.JobName, err)
+	}
+	if rd.alerts != nil {
+		if err := rd.alerts.SendRegressionAlert(regression); err != nil {
+			logrus.Errorf("Failed to send duration regression alert for job %s: %v", execution.JobName, err)
+		}
+	}
+	return regression, nil
+}
+
+// DetectDurationRegression tests durations, oldest first, for an upward
+// trend with the one-sided Mann-Kendall test at confidence. A regression
+// is returned when the trend is significant and the median of the later
+// half of the durations exceeds that of the earlier half by at least
+// minIncrease percent; otherwise nil.
+func DetectDurationRegression(durations []float64, confidence, minIncrease float64) *types.DurationRegression {
+	n := len(durations)
+	if n < 3 {
+		return nil
+	}
+
+	var s float64
+	slopes := make([]float64, 0, n*(n-1)/2)
+	for i := 0; i < n-1; i++ {
+		for j := i + 1; j < n; j++ {
+			diff := durations[j] - durations[i]
+			switch {
+			case diff > 0:
+				s++
+			case diff < 0:
+				s--
+			}
+			slopes = append(slopes, diff/float64(j-i))
+		}
+	}
+	if s <= 0 {
+		return nil
+	}
+
+	// Variance of S, corrected for tied durations
+	variance := float64(n*(n-1)*(2*n+5)) / 18
+	ties := make(map[float64]int)
+	for _, duration := range durations {
+		ties[duration]++
+	}
+	for _, t := range ties {
+		if t > 1 {
+			variance -= float64(t*(t-1)*(2*t+5)) / 18
+		}
+	}
+	if variance <= 0 {
+		return nil
+	}
+	z := (s - 1) / math.Sqrt(variance)
+	pValue := 0.5 * math.Erfc(z/math.Sqrt2)
+	if pValue > 1-confidence {
+		return nil
+	}
+
+	half := n / 2
+	baseline := median(durations[:half])
+	recent := median(durations[n-half:])
+	if baseline <= 0 {
+		return nil
+	}
+	increase := (recent - baseline) / baseline * 100
+	slope := median(slopes)
+	if increase < minIncrease || slope <= 0 {
+		return nil
+	}
+
+	return &types.DurationRegression{
+		Runs:             n,
+		Tau:              s / float64(n*(n-1)/2),
+		PValue:           pValue,
+		Slope:            slope,
+		BaselineDuration: baseline,
+		RecentDuration:   recent,
+		IncreasePercent:  increase,
+	}
+}
+
+// median returns the median of values, without reordering them
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
@@ -0,0 +1,88 @@
+package ml
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// regressionStore records regressions next to the executions of a
+// memory store
+type regressionStore struct {
+	*storage.MemoryStore
+	regressions []*types.DurationRegression
+}
+
+func (s *regressionStore) StoreDurationRegression(regression *types.DurationRegression) error {
+	s.regressions = append(s.regressions, regression)
+	return nil
+}
+
+func TestDetectDurationRegression(t *testing.T) {
+	slowing := []float64{60, 62, 59, 61, 63, 66, 70, 72, 75, 80, 84, 90}
+	regression := DetectDurationRegression(slowing, 0.95, 20)
+	if regression == nil {
+		t.Fatal("DetectDurationRegression() found no regression in steadily slowing runs")
+	}
+	if regression.PValue >= 0.05 || regression.Slope <= 0 || regression.IncreasePercent < 20 || regression.Tau <= 0.5 {
+		t.Errorf("regression = %+v, want a significant upward trend", regression)
+	}
+
+	cases := map[string][]float64{
+		"steady":      {60, 61, 59, 60, 62, 58, 60, 61, 59, 60, 61, 60},
+		"speeding up": {90, 84, 80, 75, 72, 70, 66, 63, 61, 59, 62, 60},
+		"slight":      {60, 60.5, 61, 61.5, 62, 62.5, 63, 63.5, 64, 64.5, 65, 65.5},
+		"constant":    {60, 60, 60, 60, 60, 60, 60, 60, 60, 60},
+		"too few":     {10, 20},
+	}
+	for name, durations := range cases {
+		if regression := DetectDurationRegression(durations, 0.95, 20); regression != nil {
+			t.Errorf("%s: DetectDurationRegression() = %+v, want none", name, regression)
+		}
+	}
+}
+
+func TestRegressionDetectorCheck(t *testing.T) {
+	store := &regressionStore{MemoryStore: storage.NewMemoryStore()}
+	start := time.Now().Add(-24 * time.Hour)
+	var last *types.JobExecution
+	for i := 0; i < 12; i++ {
+		last = &types.JobExecution{ID: fmt.Sprintf("run-%d", i), JobName: "backup", Status: types.StatusCompleted,
+			StartTime: start.Add(time.Duration(i) * time.Hour), Duration: 60 + float64(i*i), Attempt: 1}
+		if err := store.StoreJobExecution(last); err != nil {
+			t.Fatalf("StoreJobExecution() error = %v", err)
+		}
+	}
+	failed := &types.JobExecution{ID: "failed", JobName: "backup", Status: types.StatusFailed,
+		StartTime: start.Add(-time.Hour), Duration: 1, Attempt: 1}
+	if err := store.StoreJobExecution(failed); err != nil {
+		t.Fatalf("StoreJobExecution() error = %v", err)
+	}
+
+	detector := NewRegressionDetector(config.DurationRegressionConfig{
+		Enabled: true, Window: 20, MinRuns: 10, Confidence: 0.95, MinIncrease: 20, Cooldown: time.Hour,
+	}, store)
+	detector.timeout = func(string) time.Duration { return 10 * time.Minute }
+
+	regression, err := detector.Check(last)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if regression == nil || regression.Runs != 12 || regression.ExecutionID != "run-11" {
+		t.Fatalf("Check() = %+v, want a regression over the 12 completed runs", regression)
+	}
+	if regression.Timeout != 600 || regression.RunsToTimeout <= 0 {
+		t.Errorf("timeout = %vs in %d runs, want the runs left before 600s", regression.Timeout, regression.RunsToTimeout)
+	}
+	if len(store.regressions) != 1 {
+		t.Errorf("stored regressions = %d, want 1", len(store.regressions))
+	}
+
+	if regression, err = detector.Check(last); err != nil || regression != nil {
+		t.Errorf("Check() within the cooldown = %+v, %v, want nothing reported", regression, err)
+	}
+}
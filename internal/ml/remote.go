@@ -0,0 +1,96 @@
+package ml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+// RemotePredictor delegates predictions to an external HTTP service, such
+// as a model server hosting an ONNX model. Features are POSTed as a JSON
+// object keyed by feature name and the service answers with a
+// PredictorResult.
+type RemotePredictor struct {
+	config config.RemotePredictorConfig
+	client *http.Client
+}
+
+// remoteRequest is the body sent to the prediction service
+type remoteRequest struct {
+	Features map[string]float64 `json:"features"`
+}
+
+// NewRemotePredictor creates a new remote predictor
+func NewRemotePredictor(cfg config.RemotePredictorConfig) (*RemotePredictor, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("remote ML backend URL not configured")
+	}
+
+	return &RemotePredictor{
+		config: cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}, nil
+}
+
+// Name implements Predictor
+func (rp *RemotePredictor) Name() string {
+	return "remote"
+}
+
+// Ready implements Predictor
+func (rp *RemotePredictor) Ready() bool {
+	return true
+}
+
+// Predict implements Predictor
+func (rp *RemotePredictor) Predict(features []float64) (PredictorResult, error) {
+	if len(features) != len(featureNames) {
+		return PredictorResult{}, fmt.Errorf("expected %d features, got %d", len(featureNames), len(features))
+	}
+
+	body := remoteRequest{Features: make(map[string]float64, len(features))}
+	for i, name := range featureNames {
+		body.Features[name] = features[i]
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return PredictorResult{}, fmt.Errorf("failed to encode prediction request: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, rp.config.URL, bytes.NewReader(data))
+	if err != nil {
+		return PredictorResult{}, fmt.Errorf("failed to create prediction request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range rp.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := rp.client.Do(req)
+	if err != nil {
+		return PredictorResult{}, fmt.Errorf("failed to call prediction service: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return PredictorResult{}, fmt.Errorf("prediction service returned status %d", resp.StatusCode)
+	}
+
+	var result PredictorResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return PredictorResult{}, fmt.Errorf("failed to decode prediction response: %v", err)
+	}
+
+	if math.IsNaN(result.DelayMinutes) || math.IsInf(result.DelayMinutes, 0) || result.DelayMinutes < 0 {
+		return PredictorResult{}, fmt.Errorf("prediction service returned invalid delay %v", result.DelayMinutes)
+	}
+
+	return result, nil
+}
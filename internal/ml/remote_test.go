@@ -0,0 +1,61 @@
+package ml
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+func TestRemotePredictor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var req remoteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(PredictorResult{
+			DelayMinutes: req.Features["cpu_usage"] / 2,
+			Confidence:   0.9,
+		})
+	}))
+	defer server.Close()
+
+	predictor, err := NewRemotePredictor(config.RemotePredictorConfig{
+		URL:     server.URL,
+		Timeout: time.Second,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	if err != nil {
+		t.Fatalf("NewRemotePredictor() error = %v", err)
+	}
+
+	features := make([]float64, len(featureNames))
+	features[0] = 40
+	result, err := predictor.Predict(features)
+	if err != nil {
+		t.Fatalf("Predict() error = %v", err)
+	}
+	if result.DelayMinutes != 20 || result.Confidence != 0.9 {
+		t.Errorf("Predict() = %+v, want 20 minutes at 0.9 confidence", result)
+	}
+
+	predictor.config.Headers = nil
+	if _, err := predictor.Predict(features); err == nil {
+		t.Error("Predict() with a rejected request succeeded")
+	}
+}
+
+func TestUnknownBackend(t *testing.T) {
+	if _, err := New(config.MLConfig{Backend: "tensorflow"}); err == nil {
+		t.Error("New() with an unknown backend succeeded")
+	}
+}
@@ -0,0 +1,213 @@
+package monitoring
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metrics sources selectable through the monitoring mode
+const (
+	ModeAuto   = "auto"
+	ModeHost   = "host"
+	ModeCgroup = "cgroup"
+)
+
+// defaultCgroupRoot is where the cgroup filesystem is mounted
+const defaultCgroupRoot = "/sys/fs/cgroup"
+
+// cgroupReader reads CPU and memory accounting of the cgroup arcron
+// runs in, so that usage is reported relative to the container's limits
+// rather than the host's capacity
+type cgroupReader struct {
+	root    string
+	version int
+
+	lastUsage  time.Duration
+	lastSample time.Time
+}
+
+// detectCgroup detects the cgroup version mounted at root
+func detectCgroup(root string) (*cgroupReader, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("cgroups are not supported on %s", runtime.GOOS)
+	}
+	return newCgroupReader(root)
+}
+
+func newCgroupReader(root string) (*cgroupReader, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		return &cgroupReader{root: root, version: 2}, nil
+	}
+	if _, err := os.Stat(filepath.Join(root, "memory", "memory.limit_in_bytes")); err == nil {
+		return &cgroupReader{root: root, version: 1}, nil
+	}
+	return nil, fmt.Errorf("no cgroup filesystem found at %s", root)
+}
+
+// cpuLimit returns the number of CPUs the cgroup may use, or 0 if the
+// cgroup has no CPU quota
+func (c *cgroupReader) cpuLimit() (float64, error) {
+	var quota, period float64
+
+	if c.version == 2 {
+		// cpu.max holds "<quota> <period>", where quota may be "max"
+		fields, err := c.readFields("cpu.max")
+		if err != nil {
+			return 0, err
+		}
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("unexpected cpu.max format: %q", strings.Join(fields, " "))
+		}
+		if fields[0] == "max" {
+			return 0, nil
+		}
+		if quota, err = strconv.ParseFloat(fields[0], 64); err != nil {
+			return 0, fmt.Errorf("invalid cpu quota: %v", err)
+		}
+		if period, err = strconv.ParseFloat(fields[1], 64); err != nil {
+			return 0, fmt.Errorf("invalid cpu period: %v", err)
+		}
+	} else {
+		q, err := c.readInt(filepath.Join("cpu", "cpu.cfs_quota_us"))
+		if err != nil {
+			return 0, err
+		}
+		if q <= 0 {
+			return 0, nil
+		}
+		p, err := c.readInt(filepath.Join("cpu", "cpu.cfs_period_us"))
+		if err != nil {
+			return 0, err
+		}
+		quota, period = float64(q), float64(p)
+	}
+
+	if period <= 0 {
+		return 0, fmt.Errorf("invalid cpu period: %v", period)
+	}
+	return quota / period, nil
+}
+
+// cpuUsage returns the total CPU time consumed by the cgroup
+func (c *cgroupReader) cpuUsage() (time.Duration, error) {
+	if c.version == 2 {
+		data, err := os.ReadFile(filepath.Join(c.root, "cpu.stat"))
+		if err != nil {
+			return 0, fmt.Errorf("failed to read cpu.stat: %v", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "usage_usec" {
+				usec, err := strconv.ParseInt(fields[1], 10, 64)
+				if err != nil {
+					return 0, fmt.Errorf("invalid usage_usec: %v", err)
+				}
+				return time.Duration(usec) * time.Microsecond, nil
+			}
+		}
+		return 0, fmt.Errorf("usage_usec not found in cpu.stat")
+	}
+
+	nsec, err := c.readInt(filepath.Join("cpuacct", "cpuacct.usage"))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(nsec), nil
+}
+
+// cpuPercent returns the CPU usage since the previous call as a
+// percentage of cpus. The first call only records a baseline and
+// reports ok=false.
+func (c *cgroupReader) cpuPercent(now time.Time, cpus float64) (percent float64, ok bool, err error) {
+	usage, err := c.cpuUsage()
+	if err != nil {
+		return 0, false, err
+	}
+
+	lastUsage, lastSample := c.lastUsage, c.lastSample
+	c.lastUsage, c.lastSample = usage, now
+
+	elapsed := now.Sub(lastSample)
+	if lastSample.IsZero() || elapsed <= 0 || usage < lastUsage || cpus <= 0 {
+		return 0, false, nil
+	}
+
+	percent = float64(usage-lastUsage) / (float64(elapsed) * cpus) * 100
+	if percent > 100 {
+		percent = 100
+	}
+	return percent, true, nil
+}
+
+// memoryUsage returns the memory in use by the cgroup, excluding
+// reclaimable page cache, and its limit. A limit of 0 means unlimited.
+func (c *cgroupReader) memoryUsage() (usage, limit uint64, err error) {
+	var usageFile, limitFile, statFile, inactiveKey string
+	if c.version == 2 {
+		usageFile, limitFile, statFile, inactiveKey = "memory.current", "memory.max", "memory.stat", "inactive_file"
+	} else {
+		usageFile = filepath.Join("memory", "memory.usage_in_bytes")
+		limitFile = filepath.Join("memory", "memory.limit_in_bytes")
+		statFile, inactiveKey = filepath.Join("memory", "memory.stat"), "total_inactive_file"
+	}
+
+	current, err := c.readInt(usageFile)
+	if err != nil {
+		return 0, 0, err
+	}
+	usage = uint64(current)
+
+	fields, err := c.readFields(limitFile)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(fields) > 0 && fields[0] != "max" {
+		if limit, err = strconv.ParseUint(fields[0], 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid memory limit: %v", err)
+		}
+	}
+
+	// Page cache is counted against the cgroup but reclaimed under
+	// pressure, so it does not reflect memory that is actually unavailable
+	if data, err := os.ReadFile(filepath.Join(c.root, statFile)); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == inactiveKey {
+				if inactive, err := strconv.ParseUint(fields[1], 10, 64); err == nil && inactive < usage {
+					usage -= inactive
+				}
+				break
+			}
+		}
+	}
+
+	return usage, limit, nil
+}
+
+func (c *cgroupReader) readFields(name string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(c.root, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	return strings.Fields(string(data)), nil
+}
+
+func (c *cgroupReader) readInt(name string) (int64, error) {
+	fields, err := c.readFields(name)
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("%s is empty", name)
+	}
+	value, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value in %s: %v", name, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,121 @@
+package monitoring
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFixtureFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestCgroupV2(t *testing.T) {
+	root := writeFixtureFiles(t, map[string]string{
+		"cgroup.controllers": "cpu memory",
+		"cpu.max":            "150000 100000\n",
+		"cpu.stat":           "usage_usec 1000000\nuser_usec 800000\n",
+		"memory.current":     "600\n",
+		"memory.max":         "1000\n",
+		"memory.stat":        "anon 400\ninactive_file 100\n",
+	})
+
+	cg, err := newCgroupReader(root)
+	if err != nil {
+		t.Fatalf("newCgroupReader() error = %v", err)
+	}
+	if cg.version != 2 {
+		t.Fatalf("version = %d, want 2", cg.version)
+	}
+
+	cpus, err := cg.cpuLimit()
+	if err != nil || cpus != 1.5 {
+		t.Errorf("cpuLimit() = %v, %v, want 1.5", cpus, err)
+	}
+
+	usage, limit, err := cg.memoryUsage()
+	if err != nil || usage != 500 || limit != 1000 {
+		t.Errorf("memoryUsage() = %d, %d, %v, want 500, 1000", usage, limit, err)
+	}
+
+	start := time.Now()
+	if _, ok, err := cg.cpuPercent(start, cpus); ok || err != nil {
+		t.Fatalf("first cpuPercent() ok = %v, err = %v, want baseline only", ok, err)
+	}
+
+	// 0.75s of CPU time over 1s with a 1.5 CPU quota is 50% of the quota
+	os.WriteFile(filepath.Join(root, "cpu.stat"), []byte("usage_usec 1750000\n"), 0644)
+	percent, ok, err := cg.cpuPercent(start.Add(time.Second), cpus)
+	if err != nil || !ok || percent != 50 {
+		t.Errorf("cpuPercent() = %v, %v, %v, want 50", percent, ok, err)
+	}
+}
+
+func TestCgroupV2Unlimited(t *testing.T) {
+	root := writeFixtureFiles(t, map[string]string{
+		"cgroup.controllers": "cpu memory",
+		"cpu.max":            "max 100000\n",
+		"memory.current":     "600\n",
+		"memory.max":         "max\n",
+	})
+
+	cg, err := newCgroupReader(root)
+	if err != nil {
+		t.Fatalf("newCgroupReader() error = %v", err)
+	}
+
+	if cpus, err := cg.cpuLimit(); err != nil || cpus != 0 {
+		t.Errorf("cpuLimit() = %v, %v, want 0", cpus, err)
+	}
+	if usage, limit, err := cg.memoryUsage(); err != nil || usage != 600 || limit != 0 {
+		t.Errorf("memoryUsage() = %d, %d, %v, want 600, 0", usage, limit, err)
+	}
+}
+
+func TestCgroupV1(t *testing.T) {
+	root := writeFixtureFiles(t, map[string]string{
+		"cpu/cpu.cfs_quota_us":         "200000\n",
+		"cpu/cpu.cfs_period_us":        "100000\n",
+		"cpuacct/cpuacct.usage":        "5000000000\n",
+		"memory/memory.usage_in_bytes": "2048\n",
+		"memory/memory.limit_in_bytes": "4096\n",
+		"memory/memory.stat":           "cache 1024\ntotal_inactive_file 1024\n",
+	})
+
+	cg, err := newCgroupReader(root)
+	if err != nil {
+		t.Fatalf("newCgroupReader() error = %v", err)
+	}
+	if cg.version != 1 {
+		t.Fatalf("version = %d, want 1", cg.version)
+	}
+
+	if cpus, err := cg.cpuLimit(); err != nil || cpus != 2 {
+		t.Errorf("cpuLimit() = %v, %v, want 2", cpus, err)
+	}
+	if usage, err := cg.cpuUsage(); err != nil || usage != 5*time.Second {
+		t.Errorf("cpuUsage() = %v, %v, want 5s", usage, err)
+	}
+	if usage, limit, err := cg.memoryUsage(); err != nil || usage != 1024 || limit != 4096 {
+		t.Errorf("memoryUsage() = %d, %d, %v, want 1024, 4096", usage, limit, err)
+	}
+}
+
+func TestCgroupMissing(t *testing.T) {
+	if _, err := newCgroupReader(t.TempDir()); err == nil {
+		t.Error("newCgroupReader() on an empty directory should fail")
+	}
+}
@@ -0,0 +1,77 @@
+//go:build linux && cgo
+
+package monitoring
+
+import (
+	"fmt"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// gpuCollector reads NVIDIA GPU utilization through NVML. The NVML
+// library is loaded at runtime, so arcron still runs on hosts without
+// the NVIDIA driver installed.
+type gpuCollector struct {
+	devices []nvml.Device
+}
+
+// newGPUCollector initializes NVML and enumerates the GPUs
+func newGPUCollector() (*gpuCollector, error) {
+	if ret := nvml.Init(); ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("failed to initialize NVML: %s", nvml.ErrorString(ret))
+	}
+
+	count, ret := nvml.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		nvml.Shutdown()
+		return nil, fmt.Errorf("failed to count GPUs: %s", nvml.ErrorString(ret))
+	}
+
+	collector := &gpuCollector{}
+	for i := 0; i < count; i++ {
+		device, ret := nvml.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			nvml.Shutdown()
+			return nil, fmt.Errorf("failed to open GPU %d: %s", i, nvml.ErrorString(ret))
+		}
+		collector.devices = append(collector.devices, device)
+	}
+
+	return collector, nil
+}
+
+// collect reads the current utilization of every GPU
+func (g *gpuCollector) collect() ([]types.GPUMetrics, error) {
+	gpus := make([]types.GPUMetrics, 0, len(g.devices))
+	for i, device := range g.devices {
+		gpu := types.GPUMetrics{Index: i}
+
+		utilization, ret := device.GetUtilizationRates()
+		if ret != nvml.SUCCESS {
+			return nil, fmt.Errorf("failed to read utilization of GPU %d: %s", i, nvml.ErrorString(ret))
+		}
+		gpu.Utilization = float64(utilization.Gpu)
+		gpu.MemoryUtilization = float64(utilization.Memory)
+
+		if name, ret := device.GetName(); ret == nvml.SUCCESS {
+			gpu.Name = name
+		}
+		if memory, ret := device.GetMemoryInfo(); ret == nvml.SUCCESS {
+			gpu.MemoryUsed = memory.Used
+			gpu.MemoryTotal = memory.Total
+		}
+		if temperature, ret := device.GetTemperature(nvml.TEMPERATURE_GPU); ret == nvml.SUCCESS {
+			gpu.Temperature = float64(temperature)
+		}
+
+		gpus = append(gpus, gpu)
+	}
+
+	return gpus, nil
+}
+
+// close releases NVML
+func (g *gpuCollector) close() {
+	nvml.Shutdown()
+}
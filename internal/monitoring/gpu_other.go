@@ -0,0 +1,24 @@
+//go:build !linux || !cgo
+
+package monitoring
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// gpuCollector is unavailable on this platform
+type gpuCollector struct{}
+
+// newGPUCollector reports that GPU metrics are unsupported
+func newGPUCollector() (*gpuCollector, error) {
+	return nil, fmt.Errorf("GPU metrics require NVML on linux with cgo, not %s", runtime.GOOS)
+}
+
+func (g *gpuCollector) collect() ([]types.GPUMetrics, error) {
+	return nil, nil
+}
+
+func (g *gpuCollector) close() {}
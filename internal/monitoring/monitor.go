@@ -3,12 +3,17 @@ package monitoring
 import (
 	"context"
 	"fmt"
+	"path"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/makalin/arcron/internal/config"
 	"github.com/makalin/arcron/internal/types"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/load"
 	"github.com/shirou/gopsutil/v3/mem"
 	"github.com/shirou/gopsutil/v3/net"
 	"github.com/sirupsen/logrus"
@@ -20,49 +25,118 @@ type DiskIO = types.DiskIO
 type NetworkIO = types.NetworkIO
 type LoadAvg = types.LoadAvg
 
+// MetricsListener is notified of every collected metrics sample
+type MetricsListener func(metrics *SystemMetrics)
+
 // Monitor represents the system monitoring component
 type Monitor struct {
-	config     *config.Config
-	metrics    chan SystemMetrics
-	stopChan   chan struct{}
-	interval   time.Duration
-	isRunning  bool
-	lastMetrics *SystemMetrics
+	config    *config.Config
+	stopChan  chan struct{}
+	interval  time.Duration
+	isRunning atomic.Bool
+
+	// lastMetrics holds the last sample, replaced whole on every
+	// collection and never modified, so it may be read concurrently
+	lastMetrics atomic.Pointer[SystemMetrics]
+
+	// cgroup is set when usage is reported relative to container limits
+	cgroup      *cgroupReader
+	forceCgroup bool
+
+	gpu *gpuCollector
+
+	// previous I/O counters, used to derive disk and network utilization
+	lastIO *ioSample
+
+	thresholds *thresholdEvaluator
+
+	// smoothed averages of the samples, which decisions are based on
+	smoothed *smoother
+	health   collectionHealth
+
+	listeners      []MetricsListener
+	listenersMutex sync.RWMutex
+
+	// alerts may be set while the monitor is running
+	alerts      AlertSender
+	alertsMutex sync.RWMutex
 }
 
 // New creates a new Monitor instance
 func New(cfg *config.Config) (*Monitor, error) {
-	return &Monitor{
-		config:   cfg,
-		metrics:  make(chan SystemMetrics, 100),
-		stopChan: make(chan struct{}),
-		interval: 5 * time.Second, // Default collection interval
-	}, nil
+	m := &Monitor{
+		config:     cfg,
+		stopChan:   make(chan struct{}),
+		interval:   5 * time.Second, // Default collection interval
+		thresholds: newThresholdEvaluator(cfg.Thresholds.Hysteresis),
+		smoothed:   newSmoother(),
+	}
+
+	switch cfg.Monitoring.Mode {
+	case ModeHost:
+	case ModeCgroup:
+		cgroup, err := detectCgroup(defaultCgroupRoot)
+		if err != nil {
+			return nil, fmt.Errorf("cgroup metrics mode is unavailable: %v", err)
+		}
+		m.cgroup = cgroup
+		m.forceCgroup = true
+	case ModeAuto, "":
+		if cgroup, err := detectCgroup(defaultCgroupRoot); err == nil {
+			m.cgroup = cgroup
+		}
+	default:
+		return nil, fmt.Errorf("unsupported monitoring mode: %s", cfg.Monitoring.Mode)
+	}
+
+	if cfg.Monitoring.GPU {
+		gpu, err := newGPUCollector()
+		if err != nil {
+			logrus.Warnf("GPU metrics are disabled: %v", err)
+		} else {
+			m.gpu = gpu
+			logrus.Info("Collecting GPU metrics through NVML")
+		}
+	}
+
+	if m.cgroup != nil {
+		// Record a CPU usage baseline so the first sample has a delta
+		m.cgroup.cpuPercent(time.Now(), 1)
+		logrus.Infof("Reading CPU and memory usage from cgroup v%d", m.cgroup.version)
+	}
+
+	return m, nil
 }
 
 // Start starts the monitoring
 func (m *Monitor) Start(ctx context.Context) error {
-	if m.isRunning {
+	if !m.isRunning.CompareAndSwap(false, true) {
 		return fmt.Errorf("monitor is already running")
 	}
 
-	m.isRunning = true
+	m.health.mutex.Lock()
+	m.health.startedAt = time.Now()
+	m.health.mutex.Unlock()
 	logrus.Info("Starting system monitoring...")
 
 	go m.collectMetrics(ctx)
+	go m.watchStaleness(ctx)
 
 	return nil
 }
 
 // Stop stops the monitoring
 func (m *Monitor) Stop() {
-	if !m.isRunning {
+	if !m.isRunning.CompareAndSwap(true, false) {
 		return
 	}
 
 	logrus.Info("Stopping system monitoring...")
 	close(m.stopChan)
-	m.isRunning = false
+
+	if m.gpu != nil {
+		m.gpu.close()
+	}
 }
 
 // collectMetrics continuously collects system metrics
@@ -78,20 +152,17 @@ func (m *Monitor) collectMetrics(ctx context.Context) {
 			return
 		case <-ticker.C:
 			metrics, err := m.collectCurrentMetrics()
+			m.health.recordCollection(time.Now(), err)
 			if err != nil {
 				logrus.Errorf("Failed to collect metrics: %v", err)
+				collectionErrors.Inc()
 				continue
 			}
 
-			m.lastMetrics = &metrics
-			
-			select {
-			case m.metrics <- metrics:
-				// Metrics sent successfully
-			default:
-				// Channel is full, skip this metric
-				logrus.Warn("Metrics channel is full, skipping metric collection")
-			}
+			m.lastMetrics.Store(metrics.Clone())
+			m.smoothed.add(&metrics)
+			m.evaluateThresholds(&metrics)
+			m.notifyListeners(&metrics)
 		}
 	}
 }
@@ -101,94 +172,306 @@ func (m *Monitor) collectCurrentMetrics() (SystemMetrics, error) {
 	metrics := SystemMetrics{
 		Timestamp: time.Now(),
 	}
+	sample := newIOSample(metrics.Timestamp)
 
-	// Collect CPU usage
+	// Collect CPU and memory usage. A sample without them would look like
+	// an idle machine, so the collection fails instead. gopsutil reports
+	// unreadable counters as empty rather than as errors.
 	cpuPercent, err := cpu.Percent(0, false)
-	if err == nil && len(cpuPercent) > 0 {
-		metrics.CPUUsage = cpuPercent[0]
+	if err == nil && len(cpuPercent) == 0 {
+		err = fmt.Errorf("no CPU times reported")
 	}
+	if err != nil {
+		return metrics, fmt.Errorf("failed to read CPU usage: %v", err)
+	}
+	metrics.CPUUsage = cpuPercent[0]
+	vmstat, err := mem.VirtualMemory()
+	if err == nil && vmstat.Total == 0 {
+		err = fmt.Errorf("no memory reported")
+	}
+	if err != nil {
+		return metrics, fmt.Errorf("failed to read memory usage: %v", err)
+	}
+	metrics.MemoryUsage = vmstat.UsedPercent
+	hostMemory := vmstat.Total
 
-	// Collect memory usage
-	if vmstat, err := mem.VirtualMemory(); err == nil {
-		metrics.MemoryUsage = vmstat.UsedPercent
+	if m.cgroup != nil {
+		m.applyCgroupUsage(&metrics, hostMemory)
 	}
 
-	// Collect disk I/O
+	// Collect per-disk I/O; the aggregate covers the selected disks only
 	if diskIO, err := disk.IOCounters(); err == nil {
-		var totalRead, totalWrite uint64
-		var totalReadCount, totalWriteCount uint64
-		
-		for _, io := range diskIO {
-			totalRead += io.ReadBytes
-			totalWrite += io.WriteBytes
-			totalReadCount += io.ReadCount
-			totalWriteCount += io.WriteCount
-		}
-		
-		metrics.DiskIO = DiskIO{
-			ReadBytes:  totalRead,
-			WriteBytes: totalWrite,
-			ReadCount:  totalReadCount,
-			WriteCount: totalWriteCount,
+		metrics.Disks = make(map[string]DiskIO)
+		for name, io := range diskIO {
+			if !deviceIncluded(m.config.Monitoring.Disks, name) {
+				continue
+			}
+
+			util := m.lastIO.diskUtilization(name, io.IoTime, metrics.Timestamp)
+			sample.diskBusy[name] = io.IoTime
+
+			metrics.Disks[name] = DiskIO{
+				ReadBytes:  io.ReadBytes,
+				WriteBytes: io.WriteBytes,
+				ReadCount:  io.ReadCount,
+				WriteCount: io.WriteCount,
+				IOUtil:     util,
+			}
+			metrics.DiskIO.ReadBytes += io.ReadBytes
+			metrics.DiskIO.WriteBytes += io.WriteBytes
+			metrics.DiskIO.ReadCount += io.ReadCount
+			metrics.DiskIO.WriteCount += io.WriteCount
+			// The aggregate reports the busiest disk
+			if util > metrics.DiskIO.IOUtil {
+				metrics.DiskIO.IOUtil = util
+			}
 		}
 	}
 
-	// Collect network I/O
-	if netIO, err := net.IOCounters(false); err == nil && len(netIO) > 0 {
-		io := netIO[0]
-		metrics.NetworkIO = NetworkIO{
-			BytesSent:   io.BytesSent,
-			BytesRecv:   io.BytesRecv,
-			PacketsSent: io.PacketsSent,
-			PacketsRecv: io.PacketsRecv,
+	// Collect per-interface network I/O; the aggregate covers the
+	// selected interfaces only
+	if netIO, err := net.IOCounters(true); err == nil {
+		metrics.Interfaces = make(map[string]NetworkIO)
+		for _, io := range netIO {
+			if !deviceIncluded(m.config.Monitoring.Interfaces, io.Name) {
+				continue
+			}
+
+			bytes := io.BytesSent + io.BytesRecv
+			util := m.lastIO.networkUtilization(io.Name, bytes, metrics.Timestamp)
+			sample.netBytes[io.Name] = bytes
+
+			metrics.Interfaces[io.Name] = NetworkIO{
+				BytesSent:   io.BytesSent,
+				BytesRecv:   io.BytesRecv,
+				PacketsSent: io.PacketsSent,
+				PacketsRecv: io.PacketsRecv,
+				Utilization: util,
+			}
+			metrics.NetworkIO.BytesSent += io.BytesSent
+			metrics.NetworkIO.BytesRecv += io.BytesRecv
+			metrics.NetworkIO.PacketsSent += io.PacketsSent
+			metrics.NetworkIO.PacketsRecv += io.PacketsRecv
+			// The aggregate reports the busiest interface
+			if util > metrics.NetworkIO.Utilization {
+				metrics.NetworkIO.Utilization = util
+			}
 		}
 	}
 
-	// Collect load average (Linux only)
+	m.lastIO = sample
+
+	// Collect load average
 	if load, err := getLoadAverage(); err == nil {
 		metrics.LoadAvg = load
+	} else {
+		logrus.Debugf("Failed to collect load average: %v", err)
+	}
+
+	// Collect optional GPU and temperature metrics
+	if m.gpu != nil {
+		if gpus, err := m.gpu.collect(); err == nil {
+			metrics.GPUs = gpus
+		} else {
+			logrus.Debugf("Failed to collect GPU metrics: %v", err)
+		}
+	}
+	if m.config.Monitoring.Temperatures {
+		if temperatures, err := collectTemperatures(); err == nil {
+			metrics.Temperatures = temperatures
+		} else {
+			logrus.Debugf("Failed to collect temperatures: %v", err)
+		}
 	}
 
 	return metrics, nil
 }
 
-// getLoadAverage gets system load average (Linux specific)
+// applyCgroupUsage replaces host CPU and memory usage with usage relative
+// to the cgroup's limits. In auto mode only limited resources are replaced;
+// in cgroup mode unlimited resources are measured against host capacity.
+func (m *Monitor) applyCgroupUsage(metrics *SystemMetrics, hostMemory uint64) {
+	cpus, err := m.cgroup.cpuLimit()
+	if err != nil {
+		logrus.Debugf("Failed to read cgroup CPU limit: %v", err)
+	} else if cpus > 0 || m.forceCgroup {
+		if cpus <= 0 {
+			cpus = float64(runtime.NumCPU())
+		}
+		if percent, ok, err := m.cgroup.cpuPercent(metrics.Timestamp, cpus); err != nil {
+			logrus.Debugf("Failed to read cgroup CPU usage: %v", err)
+		} else if ok {
+			metrics.CPUUsage = percent
+		}
+	}
+
+	usage, limit, err := m.cgroup.memoryUsage()
+	if err != nil {
+		logrus.Debugf("Failed to read cgroup memory usage: %v", err)
+		return
+	}
+
+	// cgroup v1 reports a huge number instead of "max" when unlimited
+	limited := limit > 0 && (hostMemory == 0 || limit < hostMemory)
+	if !limited {
+		limit = hostMemory
+	}
+	if (limited || m.forceCgroup) && limit > 0 {
+		metrics.MemoryUsage = float64(usage) / float64(limit) * 100
+	}
+}
+
+// deviceIncluded reports whether a device passes the include/exclude filter
+func deviceIncluded(filter config.DeviceFilter, name string) bool {
+	for _, pattern := range filter.Exclude {
+		if matched, _ := path.Match(pattern, name); matched {
+			return false
+		}
+	}
+
+	if len(filter.Include) == 0 {
+		return true
+	}
+	for _, pattern := range filter.Include {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// getLoadAverage gets the system load average. On Linux and macOS this is
+// the kernel load average; Windows has no load average, so gopsutil
+// derives one from exponentially smoothed samples of the processor queue
+// length, which reads zero until the first samples have been taken.
 func getLoadAverage() (LoadAvg, error) {
-	// This is a simplified implementation
-	// In a real implementation, you'd read from /proc/loadavg
+	avg, err := load.Avg()
+	if err != nil {
+		return LoadAvg{}, err
+	}
+
 	return LoadAvg{
-		Load1:  0.0,
-		Load5:  0.0,
-		Load15: 0.0,
+		Load1:  avg.Load1,
+		Load5:  avg.Load5,
+		Load15: avg.Load15,
 	}, nil
 }
 
-// GetMetrics returns the metrics channel
-func (m *Monitor) GetMetrics() <-chan SystemMetrics {
-	return m.metrics
+// GetLastMetrics returns a copy of the last collected metrics, or nil
+// before the first collection. The copy is the caller's own, safe to keep
+// and modify while collection goes on.
+func (m *Monitor) GetLastMetrics() *SystemMetrics {
+	last := m.lastMetrics.Load()
+	if last == nil {
+		return nil
+	}
+	return last.Clone()
 }
 
-// GetLastMetrics returns the last collected metrics
-func (m *Monitor) GetLastMetrics() *SystemMetrics {
-	return m.lastMetrics
+// GetSmoothedMetrics returns the exponentially smoothed metrics over one
+// of SmoothingWindows, so a decision is not swayed by a single spike. CPU,
+// memory, disk, network and GPU utilization are averaged; the other fields
+// are those of the last sample. A zero window, or one not kept, returns
+// the last sample.
+func (m *Monitor) GetSmoothedMetrics(window time.Duration) *SystemMetrics {
+	if smoothed := m.smoothed.get(window); smoothed != nil {
+		return smoothed
+	}
+	return m.GetLastMetrics()
 }
 
 // GetStatus returns the current status of the monitor
 func (m *Monitor) GetStatus() map[string]interface{} {
 	status := map[string]interface{}{
-		"running": m.isRunning,
+		"running":  m.isRunning.Load(),
 		"interval": m.interval.String(),
+		"source":   m.metricsSource(),
+	}
+
+	if last := m.lastMetrics.Load(); last != nil {
+		status["last_collection"] = last.Timestamp
+		status["cpu_usage"] = last.CPUUsage
+		status["memory_usage"] = last.MemoryUsage
+
+		smoothed := make(map[string]interface{})
+		for _, window := range SmoothingWindows {
+			if average := m.smoothed.get(window); average != nil {
+				smoothed[windowName(window)] = map[string]float64{
+					"cpu_usage":    average.CPUUsage,
+					"memory_usage": average.MemoryUsage,
+				}
+			}
+		}
+		status["smoothed"] = smoothed
 	}
-	
-	if m.lastMetrics != nil {
-		status["last_collection"] = m.lastMetrics.Timestamp
-		status["cpu_usage"] = m.lastMetrics.CPUUsage
-		status["memory_usage"] = m.lastMetrics.MemoryUsage
+	status["metrics_age"] = m.MetricsAge().Round(time.Second).String()
+	status["stale"] = m.MetricsStale()
+	if err := m.LastCollectionError(); err != nil {
+		status["last_error"] = err.Error()
 	}
-	
+	status["critical_thresholds"] = m.thresholds.critical()
+
 	return status
 }
 
+// metricsSource describes where CPU and memory usage is read from
+func (m *Monitor) metricsSource() string {
+	if m.cgroup == nil {
+		return ModeHost
+	}
+	return fmt.Sprintf("cgroup v%d", m.cgroup.version)
+}
+
+// AddListener registers a listener notified of every collected sample.
+// Listeners run on the collection loop and must not block.
+func (m *Monitor) AddListener(listener MetricsListener) {
+	m.listenersMutex.Lock()
+	defer m.listenersMutex.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+// notifyListeners passes a collected sample to all listeners
+func (m *Monitor) notifyListeners(metrics *SystemMetrics) {
+	m.listenersMutex.RLock()
+	listeners := make([]MetricsListener, len(m.listeners))
+	copy(listeners, m.listeners)
+	m.listenersMutex.RUnlock()
+
+	for _, listener := range listeners {
+		listener(metrics)
+	}
+}
+
+// SetAlertSender sets where threshold alerts are sent
+func (m *Monitor) SetAlertSender(alerts AlertSender) {
+	m.alertsMutex.Lock()
+	defer m.alertsMutex.Unlock()
+	m.alerts = alerts
+}
+
+// alertSender returns where alerts are sent, or nil if they are not
+func (m *Monitor) alertSender() AlertSender {
+	m.alertsMutex.RLock()
+	defer m.alertsMutex.RUnlock()
+	return m.alerts
+}
+
+// CriticalResources returns the resources currently above their
+// critical threshold
+func (m *Monitor) CriticalResources() []string {
+	return m.thresholds.critical()
+}
+
+// IsRunning reports whether the monitor is collecting metrics
+func (m *Monitor) IsRunning() bool {
+	return m.isRunning.Load()
+}
+
+// GetInterval returns the metrics collection interval
+func (m *Monitor) GetInterval() time.Duration {
+	return m.interval
+}
+
 // SetInterval sets the metrics collection interval
 func (m *Monitor) SetInterval(interval time.Duration) {
 	m.interval = interval
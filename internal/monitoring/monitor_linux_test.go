@@ -0,0 +1,91 @@
+package monitoring
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+func TestCollectDevices(t *testing.T) {
+	// gopsutil reads /proc from HOST_PROC; diskstats counts sectors of 512
+	// bytes
+	t.Setenv("HOST_PROC", writeFixtureFiles(t, map[string]string{
+		"stat":    "cpu  100 0 100 800 0 0 0 0 0 0\n",
+		"meminfo": "MemTotal: 1000 kB\nMemFree: 500 kB\nMemAvailable: 600 kB\n",
+		"diskstats": "" +
+			"   7       0 loop0 10 0 20 0 0 0 0 0 0 10 0\n" +
+			"   8       0 sda 100 0 2000 0 50 0 1000 0 0 500 0\n" +
+			"   8      16 sdb 10 0 200 0 5 0 100 0 0 100 0\n",
+		"net/dev": "" +
+			"Inter-|   Receive                                                |  Transmit\n" +
+			" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed\n" +
+			"    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0\n" +
+			"  eth0:    5000      50    0    0    0     0          0         0     3000      30    0    0    0     0       0          0\n" +
+			"  eth1:     700       7    0    0    0     0          0         0      300       3    0    0    0     0       0          0\n",
+	}))
+
+	m := &Monitor{config: &config.Config{Monitoring: config.MonitoringConfig{
+		Disks:      config.DeviceFilter{Exclude: []string{"loop*"}},
+		Interfaces: config.DeviceFilter{Include: []string{"eth*"}, Exclude: []string{"eth1"}},
+	}}}
+	metrics, err := m.collectCurrentMetrics()
+	if err != nil {
+		t.Fatalf("collectCurrentMetrics() error = %v", err)
+	}
+
+	if len(metrics.Disks) != 2 {
+		t.Fatalf("Disks = %v, want sda and sdb", metrics.Disks)
+	}
+	sda := metrics.Disks["sda"]
+	if sda.ReadBytes != 2000*512 || sda.WriteBytes != 1000*512 || sda.ReadCount != 100 || sda.WriteCount != 50 {
+		t.Errorf("Disks[sda] = %+v, want the diskstats counters", sda)
+	}
+	if metrics.DiskIO.ReadBytes != 2200*512 || metrics.DiskIO.ReadCount != 110 {
+		t.Errorf("DiskIO = %+v, want the total of sda and sdb", metrics.DiskIO)
+	}
+
+	if len(metrics.Interfaces) != 1 {
+		t.Fatalf("Interfaces = %v, want eth0 only", metrics.Interfaces)
+	}
+	eth0 := metrics.Interfaces["eth0"]
+	if eth0.BytesRecv != 5000 || eth0.BytesSent != 3000 || eth0.PacketsRecv != 50 || eth0.PacketsSent != 30 {
+		t.Errorf("Interfaces[eth0] = %+v, want the net/dev counters", eth0)
+	}
+	if metrics.NetworkIO.BytesRecv != 5000 || metrics.NetworkIO.BytesSent != 3000 {
+		t.Errorf("NetworkIO = %+v, want the counters of eth0 only", metrics.NetworkIO)
+	}
+}
+
+func TestCollectionErrorsReported(t *testing.T) {
+	// Without /proc/stat and /proc/meminfo CPU and memory usage cannot be
+	// read
+	t.Setenv("HOST_PROC", t.TempDir())
+
+	m, err := New(&config.Config{Monitoring: config.MonitoringConfig{Mode: ModeHost}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := m.collectCurrentMetrics(); err == nil {
+		t.Fatal("collectCurrentMetrics() succeeded without CPU and memory usage")
+	}
+
+	m.SetInterval(10 * time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer m.Stop()
+
+	for deadline := time.Now().Add(5 * time.Second); m.LastCollectionError() == nil; {
+		if time.Now().After(deadline) {
+			t.Fatal("LastCollectionError() = nil, want the failed collection reported")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if metrics := m.GetLastMetrics(); metrics != nil {
+		t.Errorf("GetLastMetrics() = %+v, want no sample from failed collections", metrics)
+	}
+}
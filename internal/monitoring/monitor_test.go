@@ -0,0 +1,122 @@
+package monitoring
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// TestLastMetricsConcurrentAccess is meant for go test -race: readers
+// modify the metrics they get while collection replaces them
+func TestLastMetricsConcurrentAccess(t *testing.T) {
+	m := &Monitor{config: &config.Config{}, thresholds: newThresholdEvaluator(0), smoothed: newSmoother()}
+	if m.GetLastMetrics() != nil {
+		t.Fatal("GetLastMetrics() before the first collection returned metrics")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			sample := SystemMetrics{
+				Timestamp: time.Now(),
+				CPUUsage:  float64(i % 100),
+				Disks:     map[string]DiskIO{"sda": {IOUtil: 10}},
+				GPUs:      []types.GPUMetrics{{Utilization: 50}},
+			}
+			m.lastMetrics.Store(sample.Clone())
+		}
+	}()
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if metrics := m.GetLastMetrics(); metrics != nil {
+					metrics.CPUUsage = -1
+					metrics.Disks["sda"] = DiskIO{}
+					metrics.GPUs[0].Utilization = 0
+				}
+				m.GetStatus()
+			}
+		}()
+	}
+	wg.Wait()
+
+	last := m.GetLastMetrics()
+	if last.CPUUsage < 0 || last.Disks["sda"].IOUtil != 10 || last.GPUs[0].Utilization != 50 {
+		t.Errorf("GetLastMetrics() = %+v, modified through an earlier copy", last)
+	}
+}
+
+// TestIsRunningConcurrentAccess is meant for go test -race: the status is
+// read while the monitor starts and stops
+func TestIsRunningConcurrentAccess(t *testing.T) {
+	m, err := New(&config.Config{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	m.SetInterval(time.Hour)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			m.IsRunning()
+			m.MetricsStale()
+		}
+	}()
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := m.Start(context.Background()); err == nil {
+		t.Error("second Start() succeeded")
+	}
+	m.Stop()
+	<-done
+
+	if m.IsRunning() {
+		t.Error("IsRunning() after Stop() = true")
+	}
+}
+
+// TestSetAlertSenderWhileRunning is meant for go test -race: the sender is
+// replaced while collection sends threshold alerts
+func TestSetAlertSenderWhileRunning(t *testing.T) {
+	cfg := &config.Config{}
+	// Any memory usage breaches the critical threshold
+	cfg.Thresholds.Memory = config.ThresholdLevels{Critical: 0.001}
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	m.SetInterval(time.Millisecond)
+	alerts := make(alertRecorder, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for i := 0; i < 20; i++ {
+		m.SetAlertSender(alerts)
+		if i == 0 {
+			if err := m.Start(ctx); err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			defer m.Stop()
+		}
+	}
+
+	select {
+	case alert := <-alerts:
+		if !strings.HasPrefix(alert, LevelCritical+": ") {
+			t.Errorf("alert = %q, want the critical memory alert", alert)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no alert sent")
+	}
+}
@@ -0,0 +1,77 @@
+package monitoring
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SmoothingWindows are the windows the monitor keeps smoothed metrics for
+var SmoothingWindows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// smoother keeps an exponentially weighted moving average of the usage
+// metrics over each smoothing window, like the load average does for the
+// run queue, so a single spike barely moves the average. Each sample is
+// weighted by the time since the previous one, so the averages do not
+// depend on the collection interval.
+type smoother struct {
+	mutex    sync.RWMutex
+	averages map[time.Duration]*SystemMetrics
+}
+
+func newSmoother() *smoother {
+	return &smoother{averages: make(map[time.Duration]*SystemMetrics)}
+}
+
+// add folds a sample into the average of every window
+func (s *smoother) add(sample *SystemMetrics) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, window := range SmoothingWindows {
+		previous := s.averages[window]
+		if previous != nil && !sample.Timestamp.After(previous.Timestamp) {
+			continue
+		}
+		average := sample.Clone()
+		if previous != nil {
+			alpha := 1 - math.Exp(-float64(sample.Timestamp.Sub(previous.Timestamp))/float64(window))
+			average.CPUUsage = ewma(previous.CPUUsage, sample.CPUUsage, alpha)
+			average.MemoryUsage = ewma(previous.MemoryUsage, sample.MemoryUsage, alpha)
+			average.DiskIO.IOUtil = ewma(previous.DiskIO.IOUtil, sample.DiskIO.IOUtil, alpha)
+			average.NetworkIO.Utilization = ewma(previous.NetworkIO.Utilization, sample.NetworkIO.Utilization, alpha)
+			// GPUs are matched by position, so averages restart if they change
+			if len(previous.GPUs) == len(average.GPUs) {
+				for i := range average.GPUs {
+					average.GPUs[i].Utilization = ewma(previous.GPUs[i].Utilization, sample.GPUs[i].Utilization, alpha)
+					average.GPUs[i].MemoryUtilization = ewma(previous.GPUs[i].MemoryUtilization, sample.GPUs[i].MemoryUtilization, alpha)
+				}
+			}
+		}
+		s.averages[window] = average
+	}
+}
+
+// get returns the average over a window, or nil before the first sample
+// or for a window that is not kept
+func (s *smoother) get(window time.Duration) *SystemMetrics {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	average := s.averages[window]
+	if average == nil {
+		return nil
+	}
+	return average.Clone()
+}
+
+// ewma moves an average towards a sample by alpha
+func ewma(average, sample, alpha float64) float64 {
+	return average + alpha*(sample-average)
+}
+
+// windowName names a smoothing window like the load average, e.g. "5m"
+func windowName(window time.Duration) string {
+	return strings.TrimSuffix(window.String(), "0s")
+}
@@ -0,0 +1,49 @@
+package monitoring
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSmoother(t *testing.T) {
+	s := newSmoother()
+	if s.get(time.Minute) != nil {
+		t.Fatal("get() before the first sample returned an average")
+	}
+
+	// Steady 20% CPU sampled every 5s, then a single 100% spike
+	start := time.Now()
+	for i := 0; i < 60; i++ {
+		s.add(&SystemMetrics{Timestamp: start.Add(time.Duration(i) * 5 * time.Second), CPUUsage: 20, MemoryUsage: 40})
+	}
+	spike := start.Add(60 * 5 * time.Second)
+	s.add(&SystemMetrics{Timestamp: spike, CPUUsage: 100, MemoryUsage: 40})
+
+	// The spike moves each average by 1 - e^(-5s/window) of the jump
+	for _, window := range SmoothingWindows {
+		average := s.get(window)
+		alpha := 1 - math.Exp(-float64(5*time.Second)/float64(window))
+		if want := 20 + alpha*80; math.Abs(average.CPUUsage-want) > 0.01 {
+			t.Errorf("%s CPU average = %.2f, want %.2f", windowName(window), average.CPUUsage, want)
+		}
+		if average.MemoryUsage != 40 {
+			t.Errorf("%s memory average = %.2f, want 40", windowName(window), average.MemoryUsage)
+		}
+		if !average.Timestamp.Equal(spike) {
+			t.Errorf("%s average timestamp = %s, want the last sample", windowName(window), average.Timestamp)
+		}
+	}
+	if one, fifteen := s.get(time.Minute).CPUUsage, s.get(15*time.Minute).CPUUsage; one <= fifteen {
+		t.Errorf("1m average %.2f not above 15m average %.2f after a spike", one, fifteen)
+	}
+
+	// Samples out of order leave the averages alone
+	s.add(&SystemMetrics{Timestamp: start, CPUUsage: 0})
+	if average := s.get(time.Minute); !average.Timestamp.Equal(spike) {
+		t.Errorf("average after an older sample = %+v, want it unchanged", average)
+	}
+	if s.get(2*time.Minute) != nil {
+		t.Error("get() of a window not kept returned an average")
+	}
+}
@@ -0,0 +1,51 @@
+package monitoring
+
+import (
+	"strings"
+
+	"github.com/makalin/arcron/internal/types"
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+// cpuSensorPrefixes identify CPU package and core sensors by their
+// hwmon driver name
+var cpuSensorPrefixes = []string{"coretemp", "k10temp", "zenpower", "cpu_thermal", "cpu"}
+
+// diskSensorPrefixes identify disk sensors by their hwmon driver name
+var diskSensorPrefixes = []string{"nvme", "drivetemp"}
+
+// collectTemperatures returns the hottest CPU and disk sensor readings
+func collectTemperatures() (*types.Temperatures, error) {
+	sensors, err := host.SensorsTemperatures()
+	if len(sensors) == 0 {
+		// Errors are also returned alongside partial readings when
+		// individual sensors cannot be read, so only fail without any
+		return nil, err
+	}
+
+	temperatures := &types.Temperatures{}
+	for _, sensor := range sensors {
+		key := strings.ToLower(sensor.SensorKey)
+		switch {
+		case hasAnyPrefix(key, cpuSensorPrefixes):
+			if sensor.Temperature > temperatures.CPU {
+				temperatures.CPU = sensor.Temperature
+			}
+		case hasAnyPrefix(key, diskSensorPrefixes):
+			if sensor.Temperature > temperatures.Disk {
+				temperatures.Disk = sensor.Temperature
+			}
+		}
+	}
+
+	return temperatures, nil
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
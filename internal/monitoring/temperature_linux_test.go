@@ -0,0 +1,35 @@
+package monitoring
+
+import "testing"
+
+func TestCollectTemperatures(t *testing.T) {
+	// gopsutil reads /sys from HOST_SYS; readings are in millidegrees
+	t.Setenv("HOST_SYS", writeFixtureFiles(t, map[string]string{
+		"class/hwmon/hwmon0/name":        "coretemp\n",
+		"class/hwmon/hwmon0/temp1_label": "Package id 0\n",
+		"class/hwmon/hwmon0/temp1_input": "55000\n",
+		"class/hwmon/hwmon0/temp2_label": "Core 0\n",
+		"class/hwmon/hwmon0/temp2_input": "61000\n",
+		"class/hwmon/hwmon1/name":        "nvme\n",
+		"class/hwmon/hwmon1/temp1_input": "40000\n",
+		// Neither a CPU nor a disk sensor
+		"class/hwmon/hwmon2/name":        "acpitz\n",
+		"class/hwmon/hwmon2/temp1_input": "90000\n",
+	}))
+
+	temperatures, err := collectTemperatures()
+	if err != nil {
+		t.Fatalf("collectTemperatures() error = %v", err)
+	}
+	if temperatures.CPU != 61 || temperatures.Disk != 40 {
+		t.Errorf("collectTemperatures() = %+v, want CPU 61 and disk 40", temperatures)
+	}
+}
+
+func TestCollectTemperaturesWithoutSensors(t *testing.T) {
+	t.Setenv("HOST_SYS", t.TempDir())
+
+	if temperatures, _ := collectTemperatures(); temperatures != nil {
+		t.Errorf("collectTemperatures() = %+v, want nil without sensors", temperatures)
+	}
+}
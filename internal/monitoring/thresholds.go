@@ -0,0 +1,208 @@
+package monitoring
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Threshold levels, ordered by severity
+const (
+	LevelOK       = "ok"
+	LevelWarning  = "warning"
+	LevelCritical = "critical"
+)
+
+var levelSeverity = map[string]int{
+	LevelOK:       0,
+	LevelWarning:  1,
+	LevelCritical: 2,
+}
+
+// AlertSender delivers system alerts, see alerts.Manager
+type AlertSender interface {
+	SendSystemAlert(level, title, message string, metrics interface{}) error
+}
+
+// thresholdCrossing describes a resource moving between threshold levels
+type thresholdCrossing struct {
+	Resource  string
+	Previous  string
+	Level     string
+	Value     float64
+	Threshold float64
+}
+
+// thresholdEvaluator tracks the threshold level of every resource.
+// Levels escalate as soon as a threshold is reached but only clear once
+// the value drops below the threshold by the hysteresis margin, so a
+// value hovering around a threshold does not cause an alert storm.
+type thresholdEvaluator struct {
+	hysteresis float64
+	mutex      sync.RWMutex
+	levels     map[string]string
+}
+
+func newThresholdEvaluator(hysteresis float64) *thresholdEvaluator {
+	return &thresholdEvaluator{
+		hysteresis: hysteresis,
+		levels:     make(map[string]string),
+	}
+}
+
+// evaluate updates the level of a resource and returns the crossing, if any
+func (e *thresholdEvaluator) evaluate(resource string, value float64, thresholds config.ThresholdLevels) *thresholdCrossing {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	previous, ok := e.levels[resource]
+	if !ok {
+		previous = LevelOK
+	}
+
+	level := levelFor(value, thresholds, 0)
+	if levelSeverity[level] < levelSeverity[previous] {
+		// Only de-escalate once the value has cleared the hysteresis band
+		level = levelFor(value, thresholds, e.hysteresis)
+		if levelSeverity[level] > levelSeverity[previous] {
+			level = previous
+		}
+	}
+
+	e.levels[resource] = level
+	if level == previous {
+		return nil
+	}
+
+	crossing := &thresholdCrossing{
+		Resource: resource,
+		Previous: previous,
+		Level:    level,
+		Value:    value,
+	}
+	switch level {
+	case LevelCritical:
+		crossing.Threshold = thresholds.Critical
+	case LevelWarning:
+		crossing.Threshold = thresholds.Warning
+	default:
+		crossing.Threshold = thresholds.Warning
+		if thresholds.Warning <= 0 {
+			crossing.Threshold = thresholds.Critical
+		}
+	}
+	return crossing
+}
+
+// levelFor returns the level of value with both thresholds lowered by margin.
+// A threshold of zero is disabled.
+func levelFor(value float64, thresholds config.ThresholdLevels, margin float64) string {
+	switch {
+	case thresholds.Critical > 0 && value >= thresholds.Critical-margin:
+		return LevelCritical
+	case thresholds.Warning > 0 && value >= thresholds.Warning-margin:
+		return LevelWarning
+	default:
+		return LevelOK
+	}
+}
+
+// critical returns the resources currently at the critical level
+func (e *thresholdEvaluator) critical() []string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	var resources []string
+	for resource, level := range e.levels {
+		if level == LevelCritical {
+			resources = append(resources, resource)
+		}
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// thresholdResources lists the evaluated resources with display names
+var thresholdResources = []struct {
+	name    string
+	display string
+	unit    string
+}{
+	{"cpu", "CPU usage", "%"},
+	{"memory", "Memory usage", "%"},
+	{"disk", "Disk utilization", "%"},
+	{"network", "Network utilization", "%"},
+	{"gpu", "GPU usage", "%"},
+	{"cpu_temperature", "CPU temperature", "°C"},
+	{"disk_temperature", "Disk temperature", "°C"},
+}
+
+// thresholdValue is the current value of a resource and its thresholds
+type thresholdValue struct {
+	value      float64
+	thresholds config.ThresholdLevels
+}
+
+// thresholdValues returns the value of every resource that has been collected
+func thresholdValues(metrics *SystemMetrics, cfg config.ThresholdsConfig) map[string]thresholdValue {
+	values := map[string]thresholdValue{
+		"cpu":     {metrics.CPUUsage, cfg.CPU},
+		"memory":  {metrics.MemoryUsage, cfg.Memory},
+		"disk":    {metrics.DiskIO.IOUtil, cfg.Disk},
+		"network": {metrics.NetworkIO.Utilization, cfg.Network},
+	}
+	if len(metrics.GPUs) > 0 {
+		values["gpu"] = thresholdValue{metrics.GPUUsage(), cfg.GPU}
+	}
+	if metrics.Temperatures != nil {
+		values["cpu_temperature"] = thresholdValue{metrics.Temperatures.CPU, cfg.CPUTemperature}
+		values["disk_temperature"] = thresholdValue{metrics.Temperatures.Disk, cfg.DiskTemperature}
+	}
+	return values
+}
+
+// evaluateThresholds checks metrics against the configured thresholds and
+// sends an alert for every level change
+func (m *Monitor) evaluateThresholds(metrics *SystemMetrics) {
+	values := thresholdValues(metrics, m.config.Thresholds)
+
+	for _, resource := range thresholdResources {
+		v, ok := values[resource.name]
+		if !ok {
+			continue
+		}
+
+		crossing := m.thresholds.evaluate(resource.name, v.value, v.thresholds)
+		if crossing == nil {
+			continue
+		}
+
+		level, title, message := describeCrossing(crossing, resource.display, resource.unit)
+		logrus.Warnf("%s: %s", title, message)
+
+		alerts := m.alertSender()
+		if alerts == nil {
+			continue
+		}
+		go func() {
+			if err := alerts.SendSystemAlert(level, title, message, metrics); err != nil {
+				logrus.Errorf("Failed to send threshold alert: %v", err)
+			}
+		}()
+	}
+}
+
+// describeCrossing returns the alert level, title and message for a crossing
+func describeCrossing(c *thresholdCrossing, display, unit string) (level, title, message string) {
+	if c.Level == LevelOK {
+		return "info", fmt.Sprintf("%s recovered", display),
+			fmt.Sprintf("%s is %.1f%s, back below the alert threshold of %.1f%s",
+				display, c.Value, unit, c.Threshold, unit)
+	}
+
+	return c.Level, fmt.Sprintf("%s %s", display, c.Level),
+		fmt.Sprintf("%s is %.1f%s, %s threshold is %.1f%s", display, c.Value, unit, c.Level, c.Threshold, unit)
+}
@@ -0,0 +1,60 @@
+package monitoring
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+func TestThresholdHysteresis(t *testing.T) {
+	e := newThresholdEvaluator(5)
+	levels := config.ThresholdLevels{Warning: 70, Critical: 90}
+
+	steps := []struct {
+		value float64
+		want  string // expected new level, "" for no crossing
+	}{
+		{50, ""},
+		{72, LevelWarning},
+		{68, ""}, // within the hysteresis band
+		{91, LevelCritical},
+		{86, ""}, // still within the band below critical
+		{84, LevelWarning},
+		{64, LevelOK},
+		{95, LevelCritical},
+		{40, LevelOK},
+	}
+
+	for i, step := range steps {
+		crossing := e.evaluate("cpu", step.value, levels)
+		got := ""
+		if crossing != nil {
+			got = crossing.Level
+		}
+		if got != step.want {
+			t.Errorf("step %d: evaluate(%v) crossing = %q, want %q", i, step.value, got, step.want)
+		}
+	}
+}
+
+func TestThresholdCritical(t *testing.T) {
+	e := newThresholdEvaluator(5)
+	levels := config.ThresholdLevels{Warning: 70, Critical: 90}
+
+	e.evaluate("memory", 96, levels)
+	e.evaluate("cpu", 92, levels)
+	e.evaluate("disk", 75, levels)
+
+	if got, want := e.critical(), []string{"cpu", "memory"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("critical() = %v, want %v", got, want)
+	}
+}
+
+func TestThresholdDisabled(t *testing.T) {
+	e := newThresholdEvaluator(5)
+
+	if crossing := e.evaluate("gpu", 100, config.ThresholdLevels{}); crossing != nil {
+		t.Errorf("evaluate() with no thresholds = %+v, want nil", crossing)
+	}
+}
@@ -0,0 +1,84 @@
+package monitoring
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sysClassNet is where Linux exposes network interface link speeds
+var sysClassNet = "/sys/class/net"
+
+// ioSample holds the cumulative I/O counters of the previous collection,
+// from which utilization over the collection interval is derived
+type ioSample struct {
+	timestamp time.Time
+	diskBusy  map[string]uint64 // milliseconds spent doing I/O
+	netBytes  map[string]uint64 // bytes sent and received
+}
+
+func newIOSample(timestamp time.Time) *ioSample {
+	return &ioSample{
+		timestamp: timestamp,
+		diskBusy:  make(map[string]uint64),
+		netBytes:  make(map[string]uint64),
+	}
+}
+
+// diskUtilization returns the percentage of time a disk was busy since the
+// previous sample, like %util in iostat
+func (prev *ioSample) diskUtilization(name string, busy uint64, now time.Time) float64 {
+	if prev == nil {
+		return 0
+	}
+	last, ok := prev.diskBusy[name]
+	elapsed := now.Sub(prev.timestamp).Milliseconds()
+	if !ok || busy < last || elapsed <= 0 {
+		return 0
+	}
+	return clampPercent(float64(busy-last) / float64(elapsed) * 100)
+}
+
+// networkUtilization returns the throughput of an interface since the
+// previous sample as a percentage of its link speed. Interfaces with an
+// unknown link speed report zero.
+func (prev *ioSample) networkUtilization(name string, bytes uint64, now time.Time) float64 {
+	if prev == nil {
+		return 0
+	}
+	last, ok := prev.netBytes[name]
+	elapsed := now.Sub(prev.timestamp).Seconds()
+	if !ok || bytes < last || elapsed <= 0 {
+		return 0
+	}
+
+	speed := linkSpeed(name)
+	if speed <= 0 {
+		return 0
+	}
+	bitsPerSecond := float64(bytes-last) * 8 / elapsed
+	return clampPercent(bitsPerSecond / (speed * 1e6) * 100)
+}
+
+// linkSpeed returns the link speed of an interface in Mbit/s, or zero
+// when it is unknown (virtual interfaces, non-Linux platforms)
+func linkSpeed(name string) float64 {
+	data, err := os.ReadFile(filepath.Join(sysClassNet, name, "speed"))
+	if err != nil {
+		return 0
+	}
+	speed, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+	if err != nil || speed <= 0 {
+		return 0
+	}
+	return speed
+}
+
+func clampPercent(value float64) float64 {
+	if value > 100 {
+		return 100
+	}
+	return value
+}
@@ -0,0 +1,137 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/makalin/arcron/internal/telemetry"
+	"github.com/sirupsen/logrus"
+)
+
+var collectionErrors = telemetry.NewCounter("arcron_monitor_collection_errors_total",
+	"Failed system metrics collections")
+
+// collectionHealth tracks when metrics were last collected and why
+// collection has been failing since, if it has
+type collectionHealth struct {
+	mutex     sync.RWMutex
+	startedAt time.Time
+	collected time.Time
+	lastError error
+	stale     bool
+}
+
+// recordCollection records the outcome of a collection at now
+func (h *collectionHealth) recordCollection(now time.Time, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if err != nil {
+		h.lastError = err
+		return
+	}
+	h.collected = now
+	h.lastError = nil
+}
+
+// age returns how long ago metrics were last collected at now, or how
+// long ago the monitor started if they never were
+func (h *collectionHealth) age(now time.Time) time.Duration {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if h.collected.IsZero() {
+		return now.Sub(h.startedAt)
+	}
+	return now.Sub(h.collected)
+}
+
+// MetricsAge returns how long ago metrics were last collected, or how long
+// ago the monitor started if none were yet
+func (m *Monitor) MetricsAge() time.Duration {
+	return m.health.age(time.Now())
+}
+
+// StaleAfter returns the age past which metrics are stale: the configured
+// monitoring.stale_after, or three collection intervals
+func (m *Monitor) StaleAfter() time.Duration {
+	if m.config.Monitoring.StaleAfter > 0 {
+		return m.config.Monitoring.StaleAfter
+	}
+	return 3 * m.interval
+}
+
+// MetricsStale reports whether the metrics are too old to base decisions
+// on, because the monitor is stopped or collection stalled
+func (m *Monitor) MetricsStale() bool {
+	return !m.isRunning.Load() || m.MetricsAge() > m.StaleAfter()
+}
+
+// LastCollectionError returns why collection failed since the last
+// collected sample, if it did
+func (m *Monitor) LastCollectionError() error {
+	m.health.mutex.RLock()
+	defer m.health.mutex.RUnlock()
+	return m.health.lastError
+}
+
+// watchStaleness checks the age of the metrics every collection interval
+// until the monitor stops, and alerts when collection stalls and when it
+// recovers. It runs apart from the collection loop, which a hung
+// collection blocks.
+func (m *Monitor) watchStaleness(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.stopChan:
+			return
+		case <-ticker.C:
+			m.checkStaleness(time.Now())
+		}
+	}
+}
+
+// checkStaleness alerts if the metrics became stale or fresh again at now
+func (m *Monitor) checkStaleness(now time.Time) {
+	age := m.health.age(now)
+	stale := age > m.StaleAfter()
+
+	m.health.mutex.Lock()
+	changed := stale != m.health.stale
+	m.health.stale = stale
+	lastError := m.health.lastError
+	m.health.mutex.Unlock()
+	if !changed {
+		return
+	}
+
+	level, title := "info", "Metrics collection recovered"
+	message := "System metrics are collected again, scheduling decisions use them again"
+	if stale {
+		level, title = LevelWarning, "Metrics collection stalled"
+		message = fmt.Sprintf("No system metrics collected for %s, jobs run on their cron schedules until collection recovers",
+			age.Round(time.Second))
+		if lastError != nil {
+			message += fmt.Sprintf(" (last error: %v)", lastError)
+		}
+		logrus.Warnf("%s: %s", title, message)
+	} else {
+		logrus.Infof("%s: %s", title, message)
+	}
+
+	alerts := m.alertSender()
+	if alerts == nil {
+		return
+	}
+	go func() {
+		if err := alerts.SendSystemAlert(level, title, message, nil); err != nil {
+			logrus.Errorf("Failed to send metrics staleness alert: %v", err)
+		}
+	}()
+}
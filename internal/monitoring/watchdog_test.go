@@ -0,0 +1,74 @@
+package monitoring
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+// alertRecorder passes the system alerts it is sent to a channel
+type alertRecorder chan string
+
+func (a alertRecorder) SendSystemAlert(level, title, message string, metrics interface{}) error {
+	a <- level + ": " + title + ": " + message
+	return nil
+}
+
+func TestStalenessWatchdog(t *testing.T) {
+	alerts := make(alertRecorder, 4)
+	m := &Monitor{config: &config.Config{}, interval: 5 * time.Second, alerts: alerts}
+	m.isRunning.Store(true)
+	start := time.Now()
+	m.health.startedAt = start
+
+	if m.StaleAfter() != 15*time.Second {
+		t.Errorf("StaleAfter() = %s, want three intervals", m.StaleAfter())
+	}
+	m.config.Monitoring.StaleAfter = time.Minute
+	if m.StaleAfter() != time.Minute {
+		t.Errorf("StaleAfter() = %s, want the configured minute", m.StaleAfter())
+	}
+
+	// Fresh while collecting, stale once collection fails for too long
+	m.health.recordCollection(start, nil)
+	m.checkStaleness(start.Add(30 * time.Second))
+	m.health.recordCollection(start.Add(40*time.Second), errors.New("gopsutil failed"))
+	m.checkStaleness(start.Add(2 * time.Minute))
+	select {
+	case alert := <-alerts:
+		if !strings.HasPrefix(alert, LevelWarning+": Metrics collection stalled") || !strings.Contains(alert, "gopsutil failed") {
+			t.Errorf("stale alert = %q, want a warning with the last error", alert)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no alert for stale metrics")
+	}
+
+	// Still stale, so no second alert, until collection recovers
+	m.checkStaleness(start.Add(3 * time.Minute))
+	m.health.recordCollection(start.Add(4*time.Minute), nil)
+	if err := m.LastCollectionError(); err != nil {
+		t.Errorf("LastCollectionError() after a collection = %v, want nil", err)
+	}
+	m.checkStaleness(start.Add(4 * time.Minute))
+	select {
+	case alert := <-alerts:
+		if !strings.HasPrefix(alert, "info: Metrics collection recovered") {
+			t.Errorf("alert after recovery = %q, want the recovery", alert)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no alert for recovered metrics")
+	}
+	select {
+	case alert := <-alerts:
+		t.Errorf("unexpected alert %q", alert)
+	default:
+	}
+
+	m.isRunning.Store(false)
+	if !m.MetricsStale() {
+		t.Error("metrics of a stopped monitor not stale")
+	}
+}
@@ -0,0 +1,255 @@
+// Package runner runs the jobs of a configuration in the foreground, without
+// the API, WebSocket and metrics servers, for containers and CI where a
+// long-running server is unwanted (arcron run).
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/debug"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/logging"
+	"github.com/makalin/arcron/internal/metrics"
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/monitoring"
+	"github.com/makalin/arcron/internal/scheduler"
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/tracing"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultWindow is how far back a scheduled run still counts as due in a
+// single pass: the interval of a cron or CI schedule invoking arcron run
+// --once every minute
+const DefaultWindow = time.Minute
+
+// Options configures a foreground run
+type Options struct {
+	// Once runs the jobs due now and returns, instead of scheduling the
+	// jobs until the context is done
+	Once bool
+	// Window is how far back a run still counts as due with Once,
+	// DefaultWindow if zero
+	Window time.Duration
+	// Jobs limits the run to the named jobs. With Once they run whether
+	// due or not.
+	Jobs []string
+}
+
+// Result reports the executions of a single pass
+type Result struct {
+	// Ran lists the jobs run, whatever their result
+	Ran []string
+	// Failed holds the errors of the jobs that failed
+	Failed map[string]error
+}
+
+// Err returns an error naming the failed jobs, nil if every job succeeded
+func (r *Result) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(r.Failed))
+	for name := range r.Failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("%d of %d jobs failed: %s", len(r.Failed), len(r.Ran), strings.Join(names, ", "))
+}
+
+// Run runs the jobs of a configuration in the foreground. With Once, the
+// due jobs run concurrently, within their namespace limits, and Run
+// returns once they finished, with an error if any failed. Otherwise the
+// jobs are scheduled until the context is done.
+func Run(ctx context.Context, cfg *config.Config, opts Options) (*Result, error) {
+	closeLogs, err := logging.Setup(cfg.Logging)
+	if err != nil {
+		return nil, err
+	}
+	defer closeLogs()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// Exports the spans still buffered, even once ctx is done
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			logrus.Warnf("Failed to shut down tracing: %v", err)
+		}
+	}()
+
+	if len(opts.Jobs) > 0 {
+		selected, err := selectJobs(cfg.Jobs, opts.Jobs)
+		if err != nil {
+			return nil, err
+		}
+		filtered := *cfg
+		filtered.Jobs = selected
+		cfg = &filtered
+	}
+
+	store, err := storage.New(cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %v", err)
+	}
+	defer store.Close()
+
+	jobManager, err := jobs.New(cfg.Jobs, cfg.Security, store)
+	if err != nil {
+		return nil, err
+	}
+	defer jobManager.Stop()
+	jobManager.SetNamespaces(cfg.Namespaces)
+	jobManager.SetRateLimits(cfg.RateLimits)
+	jobManager.SetTimeouts(cfg.Advanced.DefaultTimeout, cfg.Advanced.MaxTimeout)
+	jobManager.SetArtifacts(cfg.Artifacts, store)
+	// Pushed even for one-shot runs, which the Pushgateway is meant for
+	pusher := metrics.NewPusher(cfg.Metrics.Pushgateway, jobManager)
+	if err := pusher.Start(); err != nil {
+		logrus.Warnf("Failed to start Pushgateway pusher: %v", err)
+	}
+	defer pusher.Stop()
+
+	if opts.Once {
+		due := cfg.Jobs
+		if len(opts.Jobs) == 0 {
+			window := opts.Window
+			if window <= 0 {
+				window = DefaultWindow
+			}
+			due, err = scheduler.DueJobs(cfg.Jobs, time.Now(), window, func(name string) bool {
+				executions, err := store.GetJobExecutions(name, 1)
+				return err != nil || len(executions) > 0
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		result := runOnce(ctx, jobManager, due)
+		return result, result.Err()
+	}
+
+	return nil, schedule(ctx, cfg, jobManager, store)
+}
+
+// runOnce executes the given jobs concurrently and waits for them
+func runOnce(ctx context.Context, jobManager *jobs.Manager, due []config.JobConfig) *Result {
+	result := &Result{Failed: make(map[string]error)}
+	if len(due) == 0 {
+		logrus.Info("No jobs are due")
+		return result
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+	)
+	for _, jobConfig := range due {
+		result.Ran = append(result.Ran, jobConfig.Name)
+		job, ok := jobManager.GetJob(jobConfig.Name)
+		if !ok {
+			// Rejected by the command policy or invalid, as logged
+			result.Failed[jobConfig.Name] = fmt.Errorf("job %s cannot run", jobConfig.Name)
+			continue
+		}
+
+		wg.Add(1)
+		go func(job *jobs.Job) {
+			defer wg.Done()
+			if err := jobManager.ExecuteJob(ctx, job); err != nil {
+				mutex.Lock()
+				result.Failed[job.GetName()] = err
+				mutex.Unlock()
+			}
+		}(job)
+	}
+	wg.Wait()
+
+	logrus.Infof("Ran %d jobs, %d failed", len(result.Ran), len(result.Failed))
+	return result
+}
+
+// schedule runs the scheduler with the monitor and ML engine it relies
+// on until the context is done, then drains the running jobs
+func schedule(ctx context.Context, cfg *config.Config, jobManager *jobs.Manager, store *storage.Storage) error {
+	monitor, err := monitoring.New(cfg)
+	if err != nil {
+		return err
+	}
+	mlEngine, err := ml.New(cfg.ML)
+	if err != nil {
+		return err
+	}
+	sched, err := scheduler.New(cfg, jobManager, mlEngine, monitor)
+	if err != nil {
+		return err
+	}
+	sched.SetAdjustmentStore(store)
+	sched.SetMaintenanceStore(store)
+	jobManager.SetMetricsSource(monitor)
+	monitor.AddListener(store.MetricsListener())
+	mlEngine.Attach(monitor, jobManager)
+
+	if err := monitor.Start(ctx); err != nil {
+		return err
+	}
+	defer monitor.Stop()
+	// Stored samples are rolled up and old records cleaned up as by the
+	// server
+	go store.RunRollups(ctx)
+	go store.RunCleanup(ctx)
+	debugServer := debug.New(cfg.Advanced.Debug)
+	if err := debugServer.Start(); err != nil {
+		return err
+	}
+	defer debugServer.Stop()
+	statsd := metrics.NewStatsDEmitter(cfg.Metrics.StatsD, jobManager, monitor)
+	if err := statsd.Start(ctx); err != nil {
+		logrus.Warnf("Failed to start StatsD emitter: %v", err)
+	}
+	defer statsd.Stop()
+	if err := mlEngine.Start(ctx); err != nil {
+		return err
+	}
+	defer mlEngine.Stop()
+	if err := sched.Start(ctx); err != nil {
+		return err
+	}
+	defer sched.Stop()
+
+	<-ctx.Done()
+	// Start no more jobs while the running ones finish
+	sched.Stop()
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.DrainTimeout)
+	defer cancel()
+	if err := jobManager.Drain(drainCtx); err != nil {
+		logrus.Warnf("Jobs still running after %s, stopping anyway", cfg.Server.DrainTimeout)
+	}
+	return nil
+}
+
+// selectJobs returns the named jobs of a configuration
+func selectJobs(all []config.JobConfig, names []string) ([]config.JobConfig, error) {
+	byName := make(map[string]config.JobConfig, len(all))
+	for _, job := range all {
+		byName[job.Name] = job
+	}
+	selected := make([]config.JobConfig, 0, len(names))
+	for _, name := range names {
+		job, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("job not found: %s", name)
+		}
+		selected = append(selected, job)
+	}
+	return selected, nil
+}
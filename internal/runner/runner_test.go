@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+func testConfig(t *testing.T, jobs ...config.JobConfig) *config.Config {
+	return &config.Config{
+		Database: config.DatabaseConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "arcron.db"), MaxConns: 1},
+		Jobs:     jobs,
+	}
+}
+
+func TestRunOnceRunsDueJobs(t *testing.T) {
+	cfg := testConfig(t,
+		config.JobConfig{Name: "startup", Command: "true", Schedule: "@reboot", Timeout: time.Minute},
+		config.JobConfig{Name: "broken", Command: "false", Schedule: "@reboot", Timeout: time.Minute},
+		config.JobConfig{Name: "later", Command: "true", Schedule: "@once 2099-01-01T00:00:00Z", Timeout: time.Minute},
+	)
+
+	result, err := Run(context.Background(), cfg, Options{Once: true})
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Run() error = %v, want the failed job named", err)
+	}
+	if result == nil || len(result.Ran) != 2 || len(result.Failed) != 1 {
+		t.Fatalf("result = %+v, want the two @reboot jobs run and one failed", result)
+	}
+	if len(cfg.Jobs) != 3 {
+		t.Errorf("configuration jobs = %d, want the configuration left alone", len(cfg.Jobs))
+	}
+}
+
+func TestRunOnceNamedJobs(t *testing.T) {
+	cfg := testConfig(t,
+		config.JobConfig{Name: "later", Command: "true", Schedule: "@once 2099-01-01T00:00:00Z", Timeout: time.Minute},
+		config.JobConfig{Name: "other", Command: "true", Schedule: "@reboot", Timeout: time.Minute},
+	)
+
+	result, err := Run(context.Background(), cfg, Options{Once: true, Jobs: []string{"later"}})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(result.Ran) != 1 || result.Ran[0] != "later" {
+		t.Errorf("ran = %v, want only the named job, due or not", result.Ran)
+	}
+
+	if _, err := Run(context.Background(), cfg, Options{Once: true, Jobs: []string{"missing"}}); err == nil {
+		t.Error("Run() succeeded, want unknown jobs rejected")
+	}
+}
+
+func TestRunOnceAppliesRateLimits(t *testing.T) {
+	cfg := testConfig(t,
+		config.JobConfig{Name: "first", Command: "true", Schedule: "@reboot", Timeout: time.Minute},
+		config.JobConfig{Name: "second", Command: "true", Schedule: "@reboot", Timeout: time.Minute},
+	)
+	cfg.RateLimits = []config.RateLimitConfig{{Name: "all", MaxStarts: 1, Window: 300 * time.Millisecond}}
+
+	started := time.Now()
+	result, err := Run(context.Background(), cfg, Options{Once: true})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(result.Ran) != 2 {
+		t.Fatalf("ran = %v, want both jobs", result.Ran)
+	}
+	if elapsed := time.Since(started); elapsed < 300*time.Millisecond {
+		t.Errorf("both jobs ran within %s, want the second deferred by the rate limit", elapsed)
+	}
+}
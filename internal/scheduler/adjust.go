@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// pendingAdjustment is a one-shot run replacing the next cron run of a job.
+// Whichever of the cron firing and the adjusted run comes second clears it.
+type pendingAdjustment struct {
+	occurrence time.Time // cron run being replaced
+	runAt      time.Time
+	timer      *time.Timer
+	adjustment *Adjustment
+	replaced   bool // the cron firing was skipped
+	ran        bool // the adjusted run started
+}
+
+// adjustmentLimits returns the adjustment limits that apply to a job: its
+// own where set, the global ones otherwise
+func (s *Scheduler) adjustmentLimits(jobConfig config.JobConfig) config.AdjustmentLimits {
+	limits := s.config.Advanced.Adjustment
+	if jobConfig.Adjustment.MaxDelta > 0 {
+		limits.MaxDelta = jobConfig.Adjustment.MaxDelta
+	}
+	if jobConfig.Adjustment.MaxPerDay > 0 {
+		limits.MaxPerDay = jobConfig.Adjustment.MaxPerDay
+	}
+	return limits
+}
+
+// adjustmentBlocked returns why moving the cron run at occurrence to runAt
+// is not allowed, or an empty string if it is. It must be called with the
+// scheduler lock held.
+func (s *Scheduler) adjustmentBlocked(scheduledJob *ScheduledJob, occurrence, runAt, now time.Time) string {
+	jobConfig := scheduledJob.Job.GetConfig()
+	if !jobConfig.IsAdaptive() {
+		return "adaptive scheduling disabled"
+	}
+
+	// Each cron run is moved, or advised to be moved, at most once
+	if scheduledJob.pending != nil {
+		return "next run already adjusted"
+	}
+	if scheduledJob.advised.Equal(occurrence) {
+		return "next run already advised"
+	}
+
+	if !runAt.After(now) {
+		return "optimal time already passed"
+	}
+
+	limits := s.adjustmentLimits(jobConfig)
+	if delta := runAt.Sub(occurrence).Abs(); limits.MaxDelta > 0 && delta > limits.MaxDelta {
+		return fmt.Sprintf("move of %s exceeds maximum of %s", delta.Round(time.Minute), limits.MaxDelta)
+	}
+
+	// Never move a run past the one after it
+	if following := scheduledJob.schedule.Next(occurrence); !following.IsZero() && !runAt.Before(following) {
+		return "optimal time is after the following run"
+	}
+
+	cutoff := now.Add(-24 * time.Hour)
+	recent := scheduledJob.adjustedAt[:0]
+	for _, at := range scheduledJob.adjustedAt {
+		if at.After(cutoff) {
+			recent = append(recent, at)
+		}
+	}
+	scheduledJob.adjustedAt = recent
+	if limits.MaxPerDay > 0 && len(recent) >= limits.MaxPerDay {
+		return fmt.Sprintf("already adjusted %d times in the last 24 hours", len(recent))
+	}
+
+	return ""
+}
+
+// fireScheduled runs a job from its cron entry unless that run was moved
+func (s *Scheduler) fireScheduled(scheduledJob *ScheduledJob) {
+	s.mutex.Lock()
+	scheduledJob.anchor = time.Now()
+	pending := scheduledJob.pending
+	if pending != nil && !pending.replaced && !pending.occurrence.After(time.Now().Add(time.Second)) {
+		pending.replaced = true
+		if pending.ran {
+			scheduledJob.pending = nil
+		}
+		s.jobs.setNextRun(scheduledJob, s.nextRun(scheduledJob))
+		s.mutex.Unlock()
+
+		logrus.Infof("Skipping scheduled run of job %s, moved to %s",
+			scheduledJob.Job.GetName(), pending.runAt.Format("15:04:05"))
+		return
+	}
+	s.mutex.Unlock()
+
+	s.executeJob(scheduledJob)
+}
+
+// runAdjusted runs a job at the time an adjustment moved it to
+func (s *Scheduler) runAdjusted(scheduledJob *ScheduledJob, pending *pendingAdjustment) {
+	s.mutex.Lock()
+	if scheduledJob.pending != pending {
+		// Cancelled or superseded by a schedule change
+		s.mutex.Unlock()
+		return
+	}
+	pending.ran = true
+	if pending.replaced {
+		scheduledJob.pending = nil
+	}
+	s.mutex.Unlock()
+
+	outcome := types.AdjustmentCompleted
+	switch err := s.executeJob(scheduledJob); {
+	case errors.Is(err, errStopped), errors.Is(err, errPaused),
+		errors.Is(err, errGateCritical), errors.Is(err, errGateCollapsed):
+		outcome = types.AdjustmentCancelled
+	case err != nil:
+		outcome = types.AdjustmentFailed
+	}
+	s.resolveAdjustment(pending.adjustment, outcome)
+}
+
+// cancelAdjustment drops a pending adjustment and returns it if its run
+// will now never happen, for the caller to record once the lock is
+// released. It must be called with the scheduler lock held.
+func (s *Scheduler) cancelAdjustment(scheduledJob *ScheduledJob) *Adjustment {
+	pending := scheduledJob.pending
+	if pending == nil {
+		return nil
+	}
+	scheduledJob.pending = nil
+	if !pending.timer.Stop() {
+		return nil
+	}
+	return pending.adjustment
+}
+
+// revertAdjustments cancels the pending adjustments of all jobs, which
+// then run when their cron schedules say. Runs delayed past a cron firing
+// that was already skipped keep their adjusted time, so they still run.
+func (s *Scheduler) revertAdjustments() {
+	var cancelled []*Adjustment
+	s.mutex.Lock()
+	for _, scheduledJob := range s.jobs.byName {
+		if pending := scheduledJob.pending; pending == nil || pending.replaced {
+			continue
+		}
+		if adjustment := s.cancelAdjustment(scheduledJob); adjustment != nil {
+			cancelled = append(cancelled, adjustment)
+		}
+		s.jobs.setNextRun(scheduledJob, s.nextRun(scheduledJob))
+	}
+	s.mutex.Unlock()
+	for _, adjustment := range cancelled {
+		logrus.Infof("Reverted adjustment of job %s to its cron schedule", adjustment.JobName)
+		s.resolveAdjustment(adjustment, types.AdjustmentCancelled)
+	}
+}
+
+// nextRun returns when a job runs next, taking a pending adjustment into
+// account. It must be called with the scheduler lock held.
+func (s *Scheduler) nextRun(scheduledJob *ScheduledJob) time.Time {
+	now := time.Now()
+	pending := scheduledJob.pending
+	if pending == nil {
+		return scheduledJob.nextOccurrence(now)
+	}
+	if !pending.ran {
+		return pending.runAt
+	}
+	// The adjusted run is done but the cron run it replaced is still ahead
+	if pending.occurrence.After(now) {
+		return scheduledJob.schedule.Next(pending.occurrence)
+	}
+	return scheduledJob.nextOccurrence(now)
+}
@@ -0,0 +1,237 @@
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/robfig/cron/v3"
+)
+
+func newAdjustTestJob(t *testing.T, s *Scheduler, jobConfig config.JobConfig) *ScheduledJob {
+	t.Helper()
+
+	jobConfig.Command = "true"
+	job, err := jobs.NewJob(jobConfig)
+	if err != nil {
+		t.Fatalf("NewJob() error = %v", err)
+	}
+	schedule, err := parseSchedule(jobConfig.Schedule)
+	if err != nil {
+		t.Fatalf("parseSchedule() error = %v", err)
+	}
+	now := time.Now()
+	entryID := s.cron.Schedule(schedule, cron.FuncJob(func() {}))
+	return &ScheduledJob{Job: job, EntryID: entryID, schedule: schedule, anchor: now, NextRun: schedule.Next(now)}
+}
+
+func TestAdjustmentBlocked(t *testing.T) {
+	s := &Scheduler{config: &config.Config{}, cron: cron.New(cron.WithSeconds()), jobs: newRegistry()}
+	s.config.Advanced.Adjustment = config.AdjustmentLimits{MaxDelta: 2 * time.Hour, MaxPerDay: 2}
+	s.cron.Start()
+	defer s.cron.Stop()
+
+	// Daily at midnight, so the following run is a day after the next one
+	scheduledJob := newAdjustTestJob(t, s, config.JobConfig{Name: "backup", Schedule: "0 0 0 * * *"})
+	occurrence := scheduledJob.schedule.Next(time.Now())
+	now := occurrence.Add(-3 * time.Hour)
+
+	if reason := s.adjustmentBlocked(scheduledJob, occurrence, occurrence.Add(time.Hour), now); reason != "" {
+		t.Errorf("move within limits blocked: %s", reason)
+	}
+	if reason := s.adjustmentBlocked(scheduledJob, occurrence, occurrence.Add(3*time.Hour), now); reason == "" {
+		t.Error("move beyond max delta not blocked")
+	}
+	if reason := s.adjustmentBlocked(scheduledJob, occurrence, now.Add(-time.Minute), now); reason == "" {
+		t.Error("move into the past not blocked")
+	}
+
+	scheduledJob.adjustedAt = []time.Time{now.Add(-25 * time.Hour), now.Add(-time.Hour), now.Add(-time.Minute)}
+	if reason := s.adjustmentBlocked(scheduledJob, occurrence, occurrence.Add(time.Hour), now); reason == "" {
+		t.Error("move beyond max per day not blocked")
+	}
+	if len(scheduledJob.adjustedAt) != 2 {
+		t.Errorf("adjustments older than a day kept: %v", scheduledJob.adjustedAt)
+	}
+	scheduledJob.adjustedAt = nil
+
+	scheduledJob.pending = &pendingAdjustment{occurrence: occurrence, runAt: occurrence.Add(time.Hour)}
+	if reason := s.adjustmentBlocked(scheduledJob, occurrence, occurrence.Add(time.Hour), now); reason == "" {
+		t.Error("second move of the same run not blocked")
+	}
+
+	adaptive := false
+	optOut := newAdjustTestJob(t, s, config.JobConfig{Name: "update", Schedule: "0 0 0 * * *", Adaptive: &adaptive})
+	if reason := s.adjustmentBlocked(optOut, occurrence, occurrence.Add(time.Hour), now); reason == "" {
+		t.Error("move of job with adaptive: false not blocked")
+	}
+
+	// Per-job limits override the global ones
+	tight := newAdjustTestJob(t, s, config.JobConfig{
+		Name:       "cleanup",
+		Schedule:   "0 0 0 * * *",
+		Adjustment: config.AdjustmentLimits{MaxDelta: 30 * time.Minute},
+	})
+	if reason := s.adjustmentBlocked(tight, occurrence, occurrence.Add(time.Hour), now); reason == "" {
+		t.Error("move beyond per-job max delta not blocked")
+	}
+}
+
+func TestAdjustmentSkipsReplacedRunOnly(t *testing.T) {
+	s := &Scheduler{config: &config.Config{}, cron: cron.New(cron.WithSeconds()), jobs: newRegistry()}
+	scheduledJob := newAdjustTestJob(t, s, config.JobConfig{Name: "backup", Schedule: "0 0 0 * * *"})
+
+	now := time.Now()
+	pending := &pendingAdjustment{occurrence: now, runAt: now.Add(time.Hour), ran: true}
+	pending.timer = time.AfterFunc(time.Hour, func() {})
+	defer pending.timer.Stop()
+	scheduledJob.pending = pending
+
+	// The replaced cron run is skipped and the adjustment is done with
+	s.fireScheduled(scheduledJob)
+	if !pending.replaced {
+		t.Error("replaced cron run not skipped")
+	}
+	if scheduledJob.pending != nil {
+		t.Error("pending adjustment kept after both runs")
+	}
+
+	// A cancelled adjustment must not run
+	scheduledJob.pending = nil
+	s.runAdjusted(scheduledJob, &pendingAdjustment{})
+	if scheduledJob.Status != "" {
+		t.Errorf("cancelled adjustment ran, status %q", scheduledJob.Status)
+	}
+}
+
+func TestRevertAdjustments(t *testing.T) {
+	s := &Scheduler{config: &config.Config{}, cron: cron.New(cron.WithSeconds()), jobs: newRegistry()}
+	now := time.Now()
+
+	// An early run not yet due is reverted to the cron run
+	early := newAdjustTestJob(t, s, config.JobConfig{Name: "backup", Schedule: "0 0 0 * * *"})
+	earlyPending := &pendingAdjustment{occurrence: early.NextRun, runAt: now.Add(time.Hour), adjustment: &Adjustment{JobName: "backup"}}
+	earlyPending.timer = time.AfterFunc(time.Hour, func() {})
+	defer earlyPending.timer.Stop()
+	early.pending = earlyPending
+	s.jobs.add(early)
+
+	// A delayed run whose cron firing was skipped keeps its time
+	delayed := newAdjustTestJob(t, s, config.JobConfig{Name: "report", Schedule: "0 0 0 * * *"})
+	delayedPending := &pendingAdjustment{occurrence: now.Add(-time.Minute), runAt: now.Add(time.Hour), replaced: true}
+	delayedPending.timer = time.AfterFunc(time.Hour, func() {})
+	defer delayedPending.timer.Stop()
+	delayed.pending = delayedPending
+	s.jobs.add(delayed)
+
+	s.revertAdjustments()
+	if early.pending != nil || early.NextRun != early.schedule.Next(now) {
+		t.Errorf("early run still adjusted to %s, want the cron run", early.NextRun)
+	}
+	if delayed.pending != delayedPending {
+		t.Error("delayed run reverted after its cron firing was skipped")
+	}
+}
+
+func TestDryRunOnlyAdvises(t *testing.T) {
+	s := &Scheduler{config: &config.Config{}, cron: cron.New(cron.WithSeconds()), jobs: newRegistry()}
+	s.config.Advanced.DryRun = true
+	s.cron.Start()
+	defer s.cron.Stop()
+	scheduledJob := newAdjustTestJob(t, s, config.JobConfig{Name: "backup", Schedule: "0 0 0 * * *"})
+	occurrence := scheduledJob.schedule.Next(time.Now())
+	if !scheduledJob.Job.GetConfig().IsDryRun(s.config.Advanced.DryRun) {
+		t.Fatal("job not in dry-run mode with global dry run")
+	}
+
+	prediction := &ml.Prediction{JobName: "backup", OptimalTime: occurrence.Add(30 * time.Minute), Confidence: 0.9}
+	s.adviseJobSchedule(scheduledJob, prediction)
+
+	if scheduledJob.pending != nil || scheduledJob.Status != "" {
+		t.Error("dry run moved the run")
+	}
+	advisories := s.Advisories("backup")
+	if len(advisories) != 1 || !advisories[0].DryRun || !advisories[0].NewRun.Equal(prediction.OptimalTime) {
+		t.Errorf("Advisories() = %+v, want the advised adjustment", advisories)
+	}
+	if len(s.Advisories("other")) != 0 {
+		t.Error("Advisories() of another job not empty")
+	}
+	if reason := s.adjustmentBlocked(scheduledJob, occurrence, prediction.OptimalTime, time.Now()); reason == "" {
+		t.Error("second advice for the same run not blocked")
+	}
+
+	live := false
+	if (config.JobConfig{DryRun: &live}).IsDryRun(true) {
+		t.Error("job opting out of dry run is in dry-run mode")
+	}
+}
+
+// fakeAdjustmentStore records stored adjustments and their outcomes
+type fakeAdjustmentStore struct {
+	mutex    sync.Mutex
+	stored   []*types.ScheduleAdjustment
+	outcomes map[uint]string
+}
+
+func (f *fakeAdjustmentStore) StoreScheduleAdjustment(adjustment *types.ScheduleAdjustment) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.stored = append(f.stored, adjustment)
+	adjustment.ID = uint(len(f.stored))
+	return nil
+}
+
+func (f *fakeAdjustmentStore) ResolveScheduleAdjustment(id uint, outcome string, resolvedAt time.Time) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.outcomes[id] = outcome
+	return nil
+}
+
+func TestAdjustmentRecordedAndCancelled(t *testing.T) {
+	store := &fakeAdjustmentStore{outcomes: make(map[uint]string)}
+	s := &Scheduler{
+		config: &config.Config{},
+		cron:   cron.New(cron.WithSeconds()),
+		jobs:   newRegistry(),
+	}
+	s.config.Advanced.AdjustmentThreshold = 5
+	s.SetAdjustmentStore(store)
+	jobManager, err := jobs.New(nil, config.SecurityConfig{}, nil)
+	if err != nil {
+		t.Fatalf("jobs.New() error = %v", err)
+	}
+	s.jobManager = jobManager
+	s.cron.Start()
+	defer s.cron.Stop()
+
+	scheduledJob := newAdjustTestJob(t, s, config.JobConfig{Name: "backup", Schedule: "0 0 0 * * *"})
+	s.jobs.add(scheduledJob)
+	occurrence := scheduledJob.schedule.Next(time.Now())
+
+	prediction := &ml.Prediction{JobName: "backup", OptimalTime: occurrence.Add(30 * time.Minute), Confidence: 0.9}
+	adjustment := s.applyPrediction(scheduledJob, prediction)
+	if adjustment == nil {
+		t.Fatal("applyPrediction() made no adjustment")
+	}
+	s.recordAdjustment(adjustment)
+
+	if len(store.stored) != 1 || store.stored[0].Outcome != types.AdjustmentPending {
+		t.Fatalf("stored adjustments = %+v, want one pending", store.stored)
+	}
+	if adjustment.ID != 1 {
+		t.Errorf("adjustment ID = %d, want the stored ID", adjustment.ID)
+	}
+
+	if err := s.UpdateSchedule("backup", "0 0 1 * * *", "admin"); err != nil {
+		t.Fatalf("UpdateSchedule() error = %v", err)
+	}
+	if store.outcomes[1] != types.AdjustmentCancelled {
+		t.Errorf("outcome after schedule change = %q, want %q", store.outcomes[1], types.AdjustmentCancelled)
+	}
+}
@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/sirupsen/logrus"
+)
+
+// maxAdvisories bounds the dry-run adjustments kept for inspection
+const maxAdvisories = 1000
+
+// adviseJobSchedule records the adjustment the scheduler would make for a
+// job without moving its run. Advised adjustments count towards the daily
+// limit so the advice matches what adaptive scheduling would have done. It
+// must be called with the scheduler lock held.
+func (s *Scheduler) adviseJobSchedule(scheduledJob *ScheduledJob, prediction *ml.Prediction) *Adjustment {
+	now := time.Now()
+	occurrence := scheduledJob.nextOccurrence(now)
+
+	advisory := &Adjustment{
+		JobName:     scheduledJob.Job.GetName(),
+		Time:        now,
+		PreviousRun: occurrence,
+		NewRun:      prediction.OptimalTime,
+		Prediction:  prediction,
+		DryRun:      true,
+	}
+	scheduledJob.LastAdjustment = advisory
+	scheduledJob.advised = occurrence
+	scheduledJob.adjustedAt = append(scheduledJob.adjustedAt, now)
+
+	s.advisories = append(s.advisories, advisory)
+	if len(s.advisories) > maxAdvisories {
+		s.advisories = s.advisories[len(s.advisories)-maxAdvisories:]
+	}
+	scheduleAdvisories.Inc(scheduledJob.Job.GetName(), scheduledJob.Job.GetNamespace())
+
+	logrus.Infof("Dry run: would adjust schedule for job %s: run at %s moved to %s (reason: %s)",
+		scheduledJob.Job.GetName(), occurrence.Format("15:04:05"),
+		prediction.OptimalTime.Format("15:04:05"), prediction.Reasoning)
+	return advisory
+}
+
+// Advisories returns the most recent adjustments advised in dry-run mode,
+// newest first, optionally only those of one job
+func (s *Scheduler) Advisories(jobName string) []*Adjustment {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	advisories := make([]*Adjustment, 0, len(s.advisories))
+	for i := len(s.advisories) - 1; i >= 0; i-- {
+		if jobName == "" || s.advisories[i].JobName == jobName {
+			advisory := *s.advisories[i]
+			advisories = append(advisories, &advisory)
+		}
+	}
+	return advisories
+}
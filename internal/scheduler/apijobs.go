@@ -0,0 +1,226 @@
+package scheduler
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// JobDefinitionStore persists the jobs synced through the API, as given,
+// before secrets are resolved
+type JobDefinitionStore interface {
+	GetJobDefinitions() ([]config.JobConfig, error)
+	SaveJobDefinitions(jobs []config.JobConfig) error
+}
+
+// Actions of a job sync
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+)
+
+// JobSyncChange is the change a sync makes to one job, with the keys
+// changed by an update
+type JobSyncChange struct {
+	Action string              `json:"action"`
+	Job    string              `json:"job"`
+	Diff   []types.FieldChange `json:"diff,omitempty"`
+}
+
+// JobSyncPlan lists the changes a sync makes, in job name order, and
+// whether they were applied or only planned
+type JobSyncPlan struct {
+	Changes   []JobSyncChange `json:"changes"`
+	Unchanged []string        `json:"unchanged"`
+	Applied   bool            `json:"applied"`
+}
+
+// isAPIJob reports whether a job is synced through the API
+func isAPIJob(job config.JobConfig) bool {
+	return job.Source == config.SourceAPI
+}
+
+// SetJobDefinitionStore makes the jobs synced through the API persist
+// across restarts and adds those saved before. Saved jobs clashing with a
+// job of the configuration files are left out. It must be called before
+// Start.
+func (s *Scheduler) SetJobDefinitionStore(store JobDefinitionStore) {
+	s.definitions = store
+
+	saved, err := store.GetJobDefinitions()
+	if err != nil {
+		logrus.Errorf("Failed to load jobs synced through the API: %v", err)
+		return
+	}
+	if err := config.ResolveJobSecrets(s.config.Secrets, saved); err != nil {
+		logrus.Errorf("Failed to load jobs synced through the API: %v", err)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, job := range saved {
+		job.Source = config.SourceAPI
+		merged, err := config.MergeJobs(s.Jobs(), []config.JobConfig{job})
+		if err != nil {
+			logrus.Errorf("Skipping job synced through the API: %v", err)
+			continue
+		}
+		if _, err := s.jobManager.AddJob(job); err != nil {
+			logrus.Errorf("Skipping job synced through the API: %v", err)
+			continue
+		}
+		s.setJobs(merged)
+	}
+}
+
+// SyncJobDefinitions makes jobs the full set of jobs managed through the
+// API: jobs not synced before are created, changed ones updated and those
+// left out deleted. Jobs of the configuration files cannot be synced. The
+// plan of the changes is returned, applied unless dryRun; applied changes
+// are recorded as revisions by author. Problems with the jobs are returned
+// as a *config.ValidationError.
+func (s *Scheduler) SyncJobDefinitions(jobs []config.JobConfig, dryRun bool, author string) (*JobSyncPlan, error) {
+	if s.definitions == nil {
+		return nil, fmt.Errorf("jobs cannot be synced without a database")
+	}
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	return s.syncJobDefinitions(jobs, dryRun, author, 0)
+}
+
+// syncJobDefinitions syncs jobs with syncMutex held. The revisions
+// recorded are marked as a rollback to rollbackOf if set.
+func (s *Scheduler) syncJobDefinitions(jobs []config.JobConfig, dryRun bool, author string, rollbackOf int) (*JobSyncPlan, error) {
+	for i := range jobs {
+		jobs[i].Source = config.SourceAPI
+	}
+	resolved, err := s.checkJobDefinitions(jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := s.definitions.GetJobDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	plan, err := planJobSync(saved, jobs)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return plan, nil
+	}
+	if len(plan.Changes) == 0 {
+		plan.Applied = true
+		return plan, nil
+	}
+
+	if err := s.definitions.SaveJobDefinitions(jobs); err != nil {
+		return nil, err
+	}
+	if _, _, err := s.replaceJobs(isAPIJob, resolved); err != nil {
+		return nil, err
+	}
+	plan.Applied = true
+
+	synced := make(map[string]config.JobConfig, len(jobs))
+	for _, job := range jobs {
+		synced[job.Name] = job
+	}
+	for _, change := range plan.Changes {
+		logrus.Infof("Job %s: %s through the API", change.Job, change.Action)
+		if change.Action == SyncDelete {
+			s.recordRevision(change.Job, nil, author, rollbackOf)
+			continue
+		}
+		job := synced[change.Job]
+		s.recordRevision(change.Job, &job, author, rollbackOf)
+	}
+	return plan, nil
+}
+
+// checkJobDefinitions checks jobs to sync as a configuration file would be
+// checked, returning them with their secrets resolved
+func (s *Scheduler) checkJobDefinitions(jobs []config.JobConfig) ([]config.JobConfig, error) {
+	problems := config.CheckJobs(jobs, s.config.Advanced)
+	for _, job := range jobs {
+		if _, err := parseSchedule(job.Schedule); err != nil {
+			problems = append(problems, fmt.Errorf("job %s: invalid schedule %q: %v", job.Name, job.Schedule, err))
+		}
+		if err := s.jobManager.CheckJob(job); err != nil {
+			problems = append(problems, fmt.Errorf("job %s: %v", job.Name, err))
+		}
+	}
+
+	s.mutex.RLock()
+	defined := make(map[string]string)
+	for _, job := range s.Jobs() {
+		if !isAPIJob(job) {
+			defined[job.Name] = jobSource(job)
+		}
+	}
+	s.mutex.RUnlock()
+	for _, job := range jobs {
+		if source, ok := defined[job.Name]; ok {
+			problems = append(problems, fmt.Errorf("job %s: already defined in %s", job.Name, source))
+		}
+	}
+
+	resolved := append([]config.JobConfig{}, jobs...)
+	if err := config.ResolveJobSecrets(s.config.Secrets, resolved); err != nil {
+		problems = append(problems, err)
+	}
+	if len(problems) > 0 {
+		return nil, &config.ValidationError{Path: "the synced jobs", Errors: problems}
+	}
+	return resolved, nil
+}
+
+// planJobSync compares the jobs saved by the last sync with those of a
+// new one
+func planJobSync(saved, jobs []config.JobConfig) (*JobSyncPlan, error) {
+	previous := make(map[string]config.JobConfig, len(saved))
+	for _, job := range saved {
+		job.Source = config.SourceAPI
+		previous[job.Name] = job
+	}
+
+	plan := &JobSyncPlan{Changes: []JobSyncChange{}, Unchanged: []string{}}
+	for _, job := range jobs {
+		before, ok := previous[job.Name]
+		delete(previous, job.Name)
+		switch {
+		case !ok:
+			plan.Changes = append(plan.Changes, JobSyncChange{Action: SyncCreate, Job: job.Name})
+		case reflect.DeepEqual(before, job):
+			plan.Unchanged = append(plan.Unchanged, job.Name)
+		default:
+			diff, err := config.DiffJobs(before, job)
+			if err != nil {
+				return nil, err
+			}
+			if len(diff) == 0 {
+				// Equal as configured, e.g. an empty map against none
+				plan.Unchanged = append(plan.Unchanged, job.Name)
+				continue
+			}
+			plan.Changes = append(plan.Changes, JobSyncChange{Action: SyncUpdate, Job: job.Name, Diff: diff})
+		}
+	}
+	for name := range previous {
+		plan.Changes = append(plan.Changes, JobSyncChange{Action: SyncDelete, Job: name})
+	}
+
+	sort.Slice(plan.Changes, func(i, j int) bool {
+		return plan.Changes[i].Job < plan.Changes[j].Job
+	})
+	sort.Strings(plan.Unchanged)
+	return plan, nil
+}
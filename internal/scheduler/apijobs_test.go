@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+)
+
+// memoryDefinitions keeps synced jobs in memory
+type memoryDefinitions struct {
+	jobs []config.JobConfig
+}
+
+func (m *memoryDefinitions) GetJobDefinitions() ([]config.JobConfig, error) {
+	return append([]config.JobConfig{}, m.jobs...), nil
+}
+
+func (m *memoryDefinitions) SaveJobDefinitions(jobs []config.JobConfig) error {
+	m.jobs = append([]config.JobConfig{}, jobs...)
+	return nil
+}
+
+func TestSyncJobDefinitions(t *testing.T) {
+	jobManager, err := jobs.New(nil, config.SecurityConfig{}, nil)
+	if err != nil {
+		t.Fatalf("jobs.New() error = %v", err)
+	}
+	cfg := &config.Config{Jobs: []config.JobConfig{
+		{Name: "main", Command: "true", Schedule: "0 0 1 * * *"},
+		{Name: "report", Command: "true", Schedule: "0 0 2 * * *", Source: "jobs.d/team.yaml"},
+	}}
+	s, err := New(cfg, jobManager, nil, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	store := &memoryDefinitions{jobs: []config.JobConfig{{Name: "saved", Command: "true", Schedule: "@daily"}}}
+	s.SetJobDefinitionStore(store)
+	for _, jobConfig := range s.Jobs() {
+		if err := s.scheduleJob(jobConfig); err != nil {
+			t.Fatalf("scheduleJob() error = %v", err)
+		}
+	}
+	if _, ok := s.GetJobStatus("saved"); !ok || len(s.Jobs()) != 3 {
+		t.Fatalf("jobs = %d, want the saved job loaded", len(s.Jobs()))
+	}
+	// The shared configuration is read without the scheduler's lock
+	if len(cfg.Jobs) != 2 {
+		t.Errorf("configured jobs = %d, want the configuration left unchanged", len(cfg.Jobs))
+	}
+
+	desired := []config.JobConfig{
+		{Name: "saved", Command: "true", Schedule: "@hourly"},
+		{Name: "export", Command: "true", Schedule: "0 0 4 * * *"},
+	}
+	plan, err := s.SyncJobDefinitions(desired, true, "admin")
+	if err != nil {
+		t.Fatalf("SyncJobDefinitions() dry run error = %v", err)
+	}
+	if plan.Applied || len(plan.Changes) != 2 || plan.Changes[0].Action != SyncCreate || plan.Changes[1].Action != SyncUpdate {
+		t.Fatalf("plan = %+v, want export created and saved updated, not applied", plan)
+	}
+	if diff := plan.Changes[1].Diff; len(diff) != 1 || diff[0].Field != "schedule" || diff[0].After != "@hourly" {
+		t.Errorf("diff = %+v, want the schedule changed to @hourly", diff)
+	}
+	if _, ok := s.GetJobStatus("export"); ok {
+		t.Error("dry run scheduled a job")
+	}
+
+	if plan, err = s.SyncJobDefinitions(desired, false, "admin"); err != nil || !plan.Applied {
+		t.Fatalf("SyncJobDefinitions() = %+v, %v, want it applied", plan, err)
+	}
+	if saved, ok := s.GetJobStatus("saved"); !ok || saved.Job.GetSchedule() != "@hourly" {
+		t.Error("updated job was not rescheduled")
+	}
+	if _, ok := jobManager.GetJob("export"); !ok {
+		t.Error("created job was not added to the job manager")
+	}
+	if len(store.jobs) != 2 {
+		t.Errorf("saved jobs = %d, want 2", len(store.jobs))
+	}
+
+	plan, err = s.SyncJobDefinitions([]config.JobConfig{{Name: "export", Command: "true", Schedule: "0 0 4 * * *"}}, false, "admin")
+	if err != nil || len(plan.Changes) != 1 || plan.Changes[0].Action != SyncDelete || len(plan.Unchanged) != 1 {
+		t.Fatalf("SyncJobDefinitions() = %+v, %v, want saved deleted and export unchanged", plan, err)
+	}
+	if _, ok := s.GetJobStatus("saved"); ok {
+		t.Error("deleted job is still scheduled")
+	}
+	if _, ok := s.GetJobStatus("report"); !ok {
+		t.Error("job of the jobs directory was removed by a sync")
+	}
+
+	_, err = s.SyncJobDefinitions([]config.JobConfig{
+		{Name: "main", Command: "true", Schedule: "@daily"},
+		{Name: "broken", Command: "true", Schedule: "every now and then"},
+	}, true, "admin")
+	var invalid *config.ValidationError
+	if !errors.As(err, &invalid) || len(invalid.Errors) != 2 {
+		t.Errorf("SyncJobDefinitions() error = %v, want the clash with the main file and the schedule reported", err)
+	}
+}
@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+// DueJobs returns the jobs due at now, for a single pass that runs them
+// and exits: @reboot jobs, and jobs with a run in the window before now.
+// An @once job whose time passed earlier is due as well if ran reports it
+// never ran, so a missed one-shot is caught up. Interval schedules such as
+// "@every 15m" are due when the window spans their interval.
+func DueJobs(jobs []config.JobConfig, now time.Time, window time.Duration, ran func(name string) bool) ([]config.JobConfig, error) {
+	var due []config.JobConfig
+	for _, job := range jobs {
+		schedule, err := parseSchedule(job.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: invalid schedule %q: %v", job.Name, job.Schedule, err)
+		}
+
+		switch s := schedule.(type) {
+		case rebootSchedule:
+			due = append(due, job)
+		case onceSchedule:
+			if !s.at.After(now) && (s.at.After(now.Add(-window)) || (ran != nil && !ran(job.Name))) {
+				due = append(due, job)
+			}
+		default:
+			if next := schedule.Next(now.Add(-window)); !next.IsZero() && !next.After(now) {
+				due = append(due, job)
+			}
+		}
+	}
+	return due, nil
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+func TestDueJobs(t *testing.T) {
+	now := time.Date(2024, 3, 1, 2, 0, 30, 0, time.UTC)
+	jobs := []config.JobConfig{
+		{Name: "nightly", Schedule: "0 0 2 * * *"},
+		{Name: "hourly", Schedule: "0 0 * * * *"},
+		{Name: "startup", Schedule: "@reboot"},
+		{Name: "frequent", Schedule: "@every 30s"},
+		{Name: "rare", Schedule: "@every 1h"},
+		{Name: "missed", Schedule: "@once 2024-02-01T00:00:00Z"},
+		{Name: "done", Schedule: "@once 2024-02-01T00:00:00Z"},
+		{Name: "later", Schedule: "@once 2024-03-02T00:00:00Z"},
+	}
+	ran := func(name string) bool { return name == "done" }
+
+	due, err := DueJobs(jobs, now, time.Minute, ran)
+	if err != nil {
+		t.Fatalf("DueJobs() error = %v", err)
+	}
+	var names []string
+	for _, job := range due {
+		names = append(names, job.Name)
+	}
+	want := []string{"nightly", "hourly", "startup", "frequent", "missed"}
+	if len(names) != len(want) {
+		t.Fatalf("due jobs = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("due jobs = %v, want %v", names, want)
+		}
+	}
+
+	if due, _ := DueJobs(jobs[5:6], now, time.Minute, nil); len(due) != 0 {
+		t.Errorf("due jobs = %+v, want a passed one-shot left out without run history", due)
+	}
+	if _, err := DueJobs([]config.JobConfig{{Name: "bad", Schedule: "not a schedule"}}, now, time.Minute, nil); err == nil {
+		t.Error("DueJobs() succeeded, want invalid schedules rejected")
+	}
+}
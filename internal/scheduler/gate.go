@@ -0,0 +1,180 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/monitoring"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// errGateCritical is returned for gated runs skipped because critical
+	// thresholds were still breached after their maximum delay
+	errGateCritical = errors.New("critical thresholds still breached after the maximum delay")
+	// errGateCollapsed is returned for gated runs dropped because another
+	// run of the job was already deferred
+	errGateCollapsed = errors.New("another run of the job is already deferred")
+)
+
+// gateLimits returns the resource limits that apply to a job, or false if
+// the job is not gated. Resource-intensive jobs are always held back while
+// a critical threshold is breached and are subject to the global limits
+// when the gate is enabled; a job with its own limits is gated regardless
+// of its type.
+func (s *Scheduler) gateLimits(jobConfig config.JobConfig) (config.ResourceLimits, bool) {
+	gate := s.config.Advanced.ResourceGate
+	own := jobConfig.Gate
+	hasOwn := own.MaxCPU > 0 || own.MaxMemory > 0 || own.MaxLoad > 0
+	intensive := jobConfig.Type == "resource-intensive"
+
+	if !hasOwn && !intensive {
+		return config.ResourceLimits{}, false
+	}
+
+	limits := config.ResourceLimits{MaxDelay: gate.Limits.MaxDelay}
+	if gate.Enabled && intensive {
+		limits = gate.Limits
+	}
+	if own.MaxCPU > 0 {
+		limits.MaxCPU = own.MaxCPU
+	}
+	if own.MaxMemory > 0 {
+		limits.MaxMemory = own.MaxMemory
+	}
+	if own.MaxLoad > 0 {
+		limits.MaxLoad = own.MaxLoad
+	}
+	if own.MaxDelay > 0 {
+		limits.MaxDelay = own.MaxDelay
+	}
+	return limits, true
+}
+
+// systemHot returns why the system is too busy to start a job under the
+// given limits, or an empty string if the job may start
+func systemHot(metrics *monitoring.SystemMetrics, critical []string, limits config.ResourceLimits) string {
+	var reasons []string
+	if len(critical) > 0 {
+		reasons = append(reasons, fmt.Sprintf("critical thresholds breached for %s", strings.Join(critical, ", ")))
+	}
+	if metrics != nil {
+		if limits.MaxCPU > 0 && metrics.CPUUsage > limits.MaxCPU {
+			reasons = append(reasons, fmt.Sprintf("CPU usage %.1f%% above %.1f%%", metrics.CPUUsage, limits.MaxCPU))
+		}
+		if limits.MaxMemory > 0 && metrics.MemoryUsage > limits.MaxMemory {
+			reasons = append(reasons, fmt.Sprintf("memory usage %.1f%% above %.1f%%", metrics.MemoryUsage, limits.MaxMemory))
+		}
+		if limits.MaxLoad > 0 && metrics.LoadAvg.Load1 > limits.MaxLoad {
+			reasons = append(reasons, fmt.Sprintf("load average %.2f above %.2f", metrics.LoadAvg.Load1, limits.MaxLoad))
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// decisionMetrics returns the metrics smoothed over a window, or nil while
+// they are stale, so that decisions fall back to the cron schedules
+func (s *Scheduler) decisionMetrics(window time.Duration) *monitoring.SystemMetrics {
+	if s.monitor.MetricsStale() {
+		return nil
+	}
+	return s.monitor.GetSmoothedMetrics(window)
+}
+
+// waitForResources defers a gated job until the system has capacity or
+// the job's maximum delay has passed, whichever comes first. Usage is
+// smoothed over the gate window, so a passing spike does not defer a job,
+// and stale metrics defer no job. Once the maximum delay has passed the
+// job starts anyway, unless a critical threshold is still breached, in
+// which case the run is skipped with errGateCritical. Only one run of a
+// job is deferred at a time; further runs are dropped with
+// errGateCollapsed. It waits on the goroutine the run was started on,
+// never on the cron dispatch loop, and returns errStopped if the
+// scheduler stopped while waiting.
+func (s *Scheduler) waitForResources(scheduledJob *ScheduledJob, log *logrus.Entry) error {
+	limits, gated := s.gateLimits(scheduledJob.Job.GetConfig())
+	if !gated {
+		return nil
+	}
+
+	name := scheduledJob.Job.GetName()
+	deadline := time.Now().Add(limits.MaxDelay)
+	deferred := false
+	defer func() {
+		if deferred {
+			s.endDeferral(scheduledJob)
+		}
+	}()
+
+	for {
+		var reason string
+		var critical []string
+		if metrics := s.decisionMetrics(s.config.Monitoring.Smoothing.GateWindow); metrics != nil {
+			critical = s.monitor.CriticalResources()
+			reason = systemHot(metrics, critical, limits)
+		}
+		if reason == "" {
+			if deferred {
+				log.Infof("Resources available again, starting deferred job %s", name)
+			}
+			return nil
+		}
+
+		if !time.Now().Before(deadline) {
+			gateTimeouts.Inc(name, scheduledJob.Job.GetNamespace())
+			if len(critical) > 0 {
+				log.Warnf("Skipping job %s after the maximum delay of %s: %s",
+					name, limits.MaxDelay, reason)
+				return errGateCritical
+			}
+			log.Warnf("Starting job %s after the maximum delay of %s although %s",
+				name, limits.MaxDelay, reason)
+			return nil
+		}
+
+		if !deferred {
+			if !s.beginDeferral(scheduledJob) {
+				log.Infof("Dropping run of job %s: a deferred run is already waiting", name)
+				return errGateCollapsed
+			}
+			log.Infof("Deferring job %s: %s", name, reason)
+			gateDeferrals.Inc(name, scheduledJob.Job.GetNamespace())
+			deferred = true
+		}
+
+		wait := s.config.Advanced.ResourceGate.CheckInterval
+		if remaining := time.Until(deadline); remaining < wait {
+			wait = remaining
+		}
+
+		select {
+		case <-s.stopChan:
+			return errStopped
+		case <-time.After(wait):
+		}
+	}
+}
+
+// beginDeferral marks a job as deferred by the resource gate, or returns
+// false if a run of it is already deferred
+func (s *Scheduler) beginDeferral(scheduledJob *ScheduledJob) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if scheduledJob.deferred {
+		return false
+	}
+	scheduledJob.deferred = true
+	scheduledJob.Status = "deferred"
+	return true
+}
+
+// endDeferral clears the deferral of a job once its run starts or is
+// skipped
+func (s *Scheduler) endDeferral(scheduledJob *ScheduledJob) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	scheduledJob.deferred = false
+}
@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/monitoring"
+	"github.com/robfig/cron/v3"
+)
+
+func TestGateLimits(t *testing.T) {
+	s := &Scheduler{config: &config.Config{}}
+	s.config.Advanced.ResourceGate = config.ResourceGateConfig{
+		Enabled: true,
+		Limits:  config.ResourceLimits{MaxCPU: 80, MaxMemory: 85, MaxDelay: 30 * time.Minute},
+	}
+
+	if _, gated := s.gateLimits(config.JobConfig{Type: "light"}); gated {
+		t.Error("light job without own limits is gated")
+	}
+
+	limits, gated := s.gateLimits(config.JobConfig{Type: "resource-intensive"})
+	if !gated || limits.MaxCPU != 80 || limits.MaxMemory != 85 {
+		t.Errorf("resource-intensive job limits = %+v, %v, want global limits", limits, gated)
+	}
+
+	limits, gated = s.gateLimits(config.JobConfig{
+		Type: "light",
+		Gate: config.ResourceLimits{MaxCPU: 50, MaxDelay: time.Hour},
+	})
+	if !gated || limits.MaxCPU != 50 || limits.MaxMemory != 0 || limits.MaxDelay != time.Hour {
+		t.Errorf("light job with own limits = %+v, %v, want own limits only", limits, gated)
+	}
+
+	s.config.Advanced.ResourceGate.Enabled = false
+	limits, gated = s.gateLimits(config.JobConfig{Type: "resource-intensive"})
+	if !gated || limits.MaxCPU != 0 {
+		t.Errorf("disabled gate limits = %+v, %v, want critical thresholds only", limits, gated)
+	}
+}
+
+func TestSystemHot(t *testing.T) {
+	limits := config.ResourceLimits{MaxCPU: 80, MaxMemory: 85}
+	metrics := &monitoring.SystemMetrics{CPUUsage: 50, MemoryUsage: 60}
+
+	if reason := systemHot(metrics, nil, limits); reason != "" {
+		t.Errorf("systemHot() = %q, want empty", reason)
+	}
+	if reason := systemHot(metrics, []string{"disk"}, limits); reason == "" {
+		t.Error("systemHot() with a critical resource is empty")
+	}
+
+	metrics.CPUUsage = 90
+	if reason := systemHot(metrics, nil, limits); reason == "" {
+		t.Error("systemHot() above the CPU limit is empty")
+	}
+}
+
+func TestGateSkipsRunsWhileCritical(t *testing.T) {
+	cfg := &config.Config{}
+	// Any memory usage breaches the critical threshold
+	cfg.Thresholds.Memory = config.ThresholdLevels{Critical: 0.001}
+	cfg.Advanced.ResourceGate = config.ResourceGateConfig{
+		CheckInterval: 10 * time.Millisecond,
+		Limits:        config.ResourceLimits{MaxDelay: 100 * time.Millisecond},
+	}
+	monitor, err := monitoring.New(cfg)
+	if err != nil {
+		t.Fatalf("monitoring.New() error = %v", err)
+	}
+	monitor.SetInterval(10 * time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := monitor.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer monitor.Stop()
+	for deadline := time.Now().Add(5 * time.Second); len(monitor.CriticalResources()) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("memory threshold not breached")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s := &Scheduler{config: cfg, cron: cron.New(cron.WithSeconds()), jobs: newRegistry(), monitor: monitor, stopChan: make(chan struct{})}
+	scheduledJob := newAdjustTestJob(t, s, config.JobConfig{Name: "reindex", Type: "resource-intensive", Schedule: "0 0 2 * * *"})
+	s.jobs.add(scheduledJob)
+
+	// A second run firing while the first is deferred is dropped
+	results := make(chan error, 2)
+	go func() { results <- s.executeJob(scheduledJob) }()
+	for deadline := time.Now().Add(time.Second); !s.isDeferred(scheduledJob); {
+		if time.Now().After(deadline) {
+			t.Fatal("run not deferred")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.executeJob(scheduledJob); err != errGateCollapsed {
+		t.Errorf("executeJob() while deferred error = %v, want errGateCollapsed", err)
+	}
+
+	// Still critical after the maximum delay, so the job never started
+	if err := <-results; err != errGateCritical {
+		t.Errorf("executeJob() error = %v, want errGateCritical", err)
+	}
+	if scheduledJob.RunCount != 0 || scheduledJob.Status != "scheduled" || s.isDeferred(scheduledJob) {
+		t.Errorf("skipped job = status %s, run count %d, deferred %v", scheduledJob.Status, scheduledJob.RunCount, scheduledJob.deferred)
+	}
+}
+
+// isDeferred reports under the lock whether a run of a job is deferred
+func (s *Scheduler) isDeferred(scheduledJob *ScheduledJob) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return scheduledJob.deferred
+}
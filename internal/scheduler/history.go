@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// AdjustmentStore persists schedule adjustments, see storage.Storage
+type AdjustmentStore interface {
+	StoreScheduleAdjustment(adjustment *types.ScheduleAdjustment) error
+	ResolveScheduleAdjustment(id uint, outcome string, resolvedAt time.Time) error
+}
+
+// SetAdjustmentStore makes the scheduler persist every adjustment it makes
+// or advises, together with the outcome of the moved run. It must be
+// called before Start.
+func (s *Scheduler) SetAdjustmentStore(store AdjustmentStore) {
+	s.store = store
+}
+
+// recordAdjustment stores an adjustment and sets its ID. The store is
+// written without holding the scheduler lock.
+func (s *Scheduler) recordAdjustment(adjustment *Adjustment) {
+	if s.store == nil {
+		return
+	}
+
+	record := &types.ScheduleAdjustment{
+		JobName:      adjustment.JobName,
+		AdjustedAt:   adjustment.Time,
+		OriginalTime: adjustment.PreviousRun,
+		NewTime:      adjustment.NewRun,
+		DryRun:       adjustment.DryRun,
+		Outcome:      types.AdjustmentPending,
+	}
+	switch {
+	case adjustment.Skipped != "":
+		record.Outcome = types.AdjustmentSkipped
+		record.SkipReason = adjustment.Skipped
+	case adjustment.DryRun:
+		record.Outcome = types.AdjustmentAdvised
+	}
+	if prediction := adjustment.Prediction; prediction != nil {
+		record.PredictionID = prediction.ID
+		record.Method = prediction.Method
+		record.Confidence = prediction.Confidence
+		record.ExpectedLoad = prediction.ExpectedLoad
+		record.Reasoning = prediction.Reasoning
+		if prediction.Explanation != nil && prediction.Explanation.Collision != nil {
+			record.StaggeredAfter = prediction.Explanation.Collision.With
+		}
+	}
+
+	if err := s.store.StoreScheduleAdjustment(record); err != nil {
+		logrus.Errorf("Failed to store schedule adjustment for job %s: %v", adjustment.JobName, err)
+		return
+	}
+
+	s.mutex.Lock()
+	adjustment.ID = record.ID
+	s.mutex.Unlock()
+}
+
+// resolveAdjustment records the outcome of an adjusted run. It must be
+// called without the scheduler lock held.
+func (s *Scheduler) resolveAdjustment(adjustment *Adjustment, outcome string) {
+	if s.store == nil || adjustment == nil {
+		return
+	}
+
+	s.mutex.RLock()
+	id := adjustment.ID
+	s.mutex.RUnlock()
+	if id == 0 {
+		return // Never stored
+	}
+
+	if err := s.store.ResolveScheduleAdjustment(id, outcome, time.Now()); err != nil {
+		logrus.Errorf("Failed to record outcome of schedule adjustment %d: %v", id, err)
+	}
+}
@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// reloadJobsDir applies the jobs read from the jobs directory after it
+// changed, with their secrets resolved afresh, keeping the current jobs if
+// it could not be read
+func (s *Scheduler) reloadJobsDir(dirJobs []config.JobConfig, err error) {
+	if err == nil {
+		err = config.ResolveJobSecrets(s.config.Secrets, dirJobs)
+	}
+	if err != nil {
+		logrus.Errorf("Keeping current jobs: %v", err)
+		return
+	}
+	if err := s.SyncJobs(dirJobs); err != nil {
+		logrus.Errorf("Keeping current jobs: %v", err)
+	}
+}
+
+// SyncJobs replaces the jobs defined in the jobs directory with dirJobs.
+// New jobs are scheduled, removed ones unscheduled, and changed ones
+// scheduled afresh; jobs of the main configuration file, jobs synced
+// through the API and unchanged jobs are left alone. Runs in progress
+// finish normally. Revisions are recorded for the jobs changed.
+func (s *Scheduler) SyncJobs(dirJobs []config.JobConfig) error {
+	changed, removed, err := s.replaceJobs(isDirJob, dirJobs)
+	if err != nil {
+		return err
+	}
+	for _, job := range changed {
+		s.recordRevision(job.Name, &job, RevisionAuthorConfig, 0)
+	}
+	for _, name := range removed {
+		s.recordRevision(name, nil, RevisionAuthorConfig, 0)
+	}
+	return nil
+}
+
+// isDirJob reports whether a job is defined in the jobs directory
+func isDirJob(job config.JobConfig) bool {
+	return job.Source != "" && job.Source != config.SourceAPI
+}
+
+// replaceJobs replaces the jobs owned by a source with jobs, scheduling
+// new jobs, unscheduling removed ones and scheduling changed ones afresh.
+// The jobs added or changed and the names of those removed are returned.
+func (s *Scheduler) replaceJobs(owned func(job config.JobConfig) bool, jobs []config.JobConfig) ([]config.JobConfig, []string, error) {
+	var cancelled []*Adjustment
+	defer func() {
+		for _, adjustment := range cancelled {
+			s.resolveAdjustment(adjustment, types.AdjustmentCancelled)
+		}
+	}()
+
+	s.mutex.Lock()
+	var otherJobs []config.JobConfig
+	current := make(map[string]config.JobConfig)
+	for _, job := range s.Jobs() {
+		if owned(job) {
+			current[job.Name] = job
+		} else {
+			otherJobs = append(otherJobs, job)
+		}
+	}
+	merged, err := config.MergeJobs(otherJobs, jobs)
+	if err != nil {
+		s.mutex.Unlock()
+		return nil, nil, err
+	}
+
+	wanted := make(map[string]config.JobConfig, len(jobs))
+	var added []config.JobConfig
+	var removed []string
+	for _, job := range jobs {
+		wanted[job.Name] = job
+		if previous, ok := current[job.Name]; !ok || !reflect.DeepEqual(previous, job) {
+			added = append(added, job)
+		}
+	}
+	for name, job := range current {
+		next, kept := wanted[name]
+		if kept && reflect.DeepEqual(job, next) {
+			continue
+		}
+		if scheduledJob, ok := s.jobs.get(name); ok {
+			s.cron.Remove(scheduledJob.EntryID)
+			if adjustment := s.cancelAdjustment(scheduledJob); adjustment != nil {
+				cancelled = append(cancelled, adjustment)
+			}
+			s.jobs.remove(scheduledJob)
+		}
+		s.jobManager.RemoveJob(name)
+		if !kept {
+			removed = append(removed, name)
+			logrus.Infof("Removed job %s, no longer defined in %s", name, job.Source)
+		}
+	}
+	s.setJobs(merged)
+	s.mutex.Unlock()
+
+	for _, job := range added {
+		if _, err := s.jobManager.AddJob(job); err != nil {
+			logrus.Errorf("Failed to add job %s from %s: %v", job.Name, job.Source, err)
+			continue
+		}
+		if err := s.scheduleJob(job); err != nil {
+			logrus.Errorf("Failed to schedule job %s from %s: %v", job.Name, job.Source, err)
+		}
+	}
+	sort.Strings(removed)
+	return added, removed, nil
+}
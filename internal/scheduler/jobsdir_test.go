@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+)
+
+func TestSyncJobs(t *testing.T) {
+	jobManager, err := jobs.New(nil, config.SecurityConfig{}, nil)
+	if err != nil {
+		t.Fatalf("jobs.New() error = %v", err)
+	}
+	cfg := &config.Config{Jobs: []config.JobConfig{
+		{Name: "main", Command: "true", Schedule: "0 0 1 * * *"},
+		{Name: "report", Command: "true", Schedule: "0 0 2 * * *", Source: "jobs.d/team.yaml"},
+		{Name: "cleanup", Command: "true", Schedule: "0 0 3 * * *", Source: "jobs.d/team.yaml"},
+	}}
+	s, err := New(cfg, jobManager, nil, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for _, jobConfig := range cfg.Jobs {
+		if err := s.scheduleJob(jobConfig); err != nil {
+			t.Fatalf("scheduleJob() error = %v", err)
+		}
+	}
+	cleanup, _ := s.jobs.get("cleanup")
+
+	err = s.SyncJobs([]config.JobConfig{
+		{Name: "cleanup", Command: "true", Schedule: "0 0 3 * * *", Source: "jobs.d/team.yaml"},
+		{Name: "report", Command: "true", Schedule: "0 30 2 * * *", Source: "jobs.d/team.yaml"},
+		{Name: "export", Command: "true", Schedule: "0 0 4 * * *", Source: "jobs.d/other.yaml"},
+	})
+	if err != nil {
+		t.Fatalf("SyncJobs() error = %v", err)
+	}
+
+	if report, ok := s.GetJobStatus("report"); !ok || report.Job.GetSchedule() != "0 30 2 * * *" {
+		t.Error("changed job was not rescheduled")
+	}
+	if current, _ := s.jobs.get("cleanup"); current != cleanup {
+		t.Error("unchanged job was rescheduled")
+	}
+	if _, ok := s.GetJobStatus("export"); !ok {
+		t.Error("new job was not scheduled")
+	}
+	if _, ok := jobManager.GetJob("export"); !ok {
+		t.Error("new job was not added to the job manager")
+	}
+	if _, ok := s.GetJobStatus("main"); !ok || len(s.Jobs()) != 4 {
+		t.Errorf("jobs = %d, want the main file's job kept", len(s.Jobs()))
+	}
+
+	if err := s.SyncJobs(nil); err != nil {
+		t.Fatalf("SyncJobs() error = %v", err)
+	}
+	if _, ok := s.GetJobStatus("report"); ok {
+		t.Error("job removed from the directory is still scheduled")
+	}
+	if _, ok := jobManager.GetJob("report"); ok {
+		t.Error("job removed from the directory is still in the job manager")
+	}
+
+	err = s.SyncJobs([]config.JobConfig{{Name: "main", Command: "true", Schedule: "@daily", Source: "jobs.d/team.yaml"}})
+	if err == nil {
+		t.Error("SyncJobs() accepted a job redefining one of the main file")
+	}
+}
+
+// TestJobsReadWhileSyncing is meant for go test -race: the jobs are read
+// without the scheduler's lock, as alerts and the API do, while the jobs
+// directory is reloaded
+func TestJobsReadWhileSyncing(t *testing.T) {
+	jobManager, err := jobs.New(nil, config.SecurityConfig{}, nil)
+	if err != nil {
+		t.Fatalf("jobs.New() error = %v", err)
+	}
+	cfg := &config.Config{Jobs: []config.JobConfig{{Name: "main", Command: "true", Schedule: "@daily"}}}
+	s, err := New(cfg, jobManager, nil, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			dirJobs := []config.JobConfig{{Name: "report", Command: "true", Schedule: fmt.Sprintf("0 %d * * * *", i), Source: "jobs.d/team.yaml"}}
+			if err := s.SyncJobs(dirJobs); err != nil {
+				t.Errorf("SyncJobs() error = %v", err)
+			}
+		}
+	}()
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+		}
+		for _, job := range s.Jobs() {
+			_ = job.Schedule
+		}
+	}
+
+	if len(s.Jobs()) != 2 || len(cfg.Jobs) != 1 {
+		t.Errorf("jobs = %d, configured = %d, want the directory's job added to the scheduler's jobs only", len(s.Jobs()), len(cfg.Jobs))
+	}
+}
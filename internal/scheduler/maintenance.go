@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// errPaused is returned for runs skipped because the scheduler is paused
+var errPaused = errors.New("scheduler paused for maintenance")
+
+// MaintenanceStore persists the maintenance state, see storage.Storage
+type MaintenanceStore interface {
+	GetMaintenanceState() (*types.MaintenanceState, error)
+	SaveMaintenanceState(state *types.MaintenanceState) error
+}
+
+// SetMaintenanceStore makes pauses and resumes persist across restarts and
+// restores a pause saved before the last shutdown. A pause set in the
+// configuration applies whatever was saved. It must be called before
+// Start.
+func (s *Scheduler) SetMaintenanceStore(store MaintenanceStore) {
+	s.maintenanceStore = store
+
+	state, err := store.GetMaintenanceState()
+	if err != nil {
+		logrus.Errorf("Failed to load maintenance state: %v", err)
+		return
+	}
+	if state == nil || !state.Paused {
+		return
+	}
+
+	s.mutex.Lock()
+	s.maintenance = *state
+	s.mutex.Unlock()
+	logrus.Warnf("Scheduler paused for maintenance since %s: %s", state.Since.Format(time.RFC3339), state.Reason)
+}
+
+// Pause stops the scheduler from starting jobs until Resume is called.
+// Runs falling due meanwhile are skipped; running jobs finish. Pausing an
+// already paused scheduler keeps the original pause.
+func (s *Scheduler) Pause(reason, by string) (types.MaintenanceState, error) {
+	s.mutex.Lock()
+	if s.maintenance.Paused {
+		state := s.maintenance
+		s.mutex.Unlock()
+		return state, nil
+	}
+	s.maintenance = types.MaintenanceState{Paused: true, Reason: reason, PausedBy: by, Since: time.Now()}
+	state := s.maintenance
+	s.mutex.Unlock()
+
+	logrus.Warnf("Scheduler paused for maintenance by %s: %s", by, reason)
+	return state, s.saveMaintenance(state)
+}
+
+// Resume lets the scheduler start jobs again after a pause. Skipped runs
+// are not made up for; jobs continue with their next run. One-shot jobs
+// skipped meanwhile run now, as they have no next run.
+func (s *Scheduler) Resume(by string) (types.MaintenanceState, error) {
+	s.mutex.Lock()
+	if !s.maintenance.Paused {
+		state := s.maintenance
+		s.mutex.Unlock()
+		return state, nil
+	}
+	paused := s.maintenance.Since
+	s.maintenance = types.MaintenanceState{}
+	state := s.maintenance
+	var missed []*ScheduledJob
+	for _, scheduledJob := range s.jobs.byName {
+		if scheduledJob.Status == "missed" {
+			missed = append(missed, scheduledJob)
+		}
+	}
+	s.mutex.Unlock()
+
+	logrus.Infof("Scheduler resumed by %s after %s in maintenance", by, time.Since(paused).Round(time.Second))
+	if s.IsRunning() {
+		for _, scheduledJob := range missed {
+			go s.executeJob(scheduledJob)
+		}
+	}
+	return state, s.saveMaintenance(state)
+}
+
+// Maintenance returns the current maintenance state
+func (s *Scheduler) Maintenance() types.MaintenanceState {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.maintenance
+}
+
+// IsPaused reports whether the scheduler is paused for maintenance
+func (s *Scheduler) IsPaused() bool {
+	return s.Maintenance().Paused
+}
+
+// saveMaintenance persists the maintenance state. It must be called
+// without the scheduler lock held.
+func (s *Scheduler) saveMaintenance(state types.MaintenanceState) error {
+	if s.maintenanceStore == nil {
+		return nil
+	}
+	if err := s.maintenanceStore.SaveMaintenanceState(&state); err != nil {
+		return fmt.Errorf("maintenance state changed but not persisted: %v", err)
+	}
+	return nil
+}
+
+// skipIfPaused drops a run of a job while the scheduler is paused and
+// reports whether it did
+func (s *Scheduler) skipIfPaused(scheduledJob *ScheduledJob) bool {
+	if !s.IsPaused() {
+		return false
+	}
+
+	logrus.Infof("Skipping run of job %s: scheduler paused for maintenance", scheduledJob.Job.GetName())
+	maintenanceSkips.Inc(scheduledJob.Job.GetName(), scheduledJob.Job.GetNamespace())
+	s.rescheduleJob(scheduledJob, errPaused)
+	return true
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/robfig/cron/v3"
+)
+
+type fakeMaintenanceStore struct {
+	state *types.MaintenanceState
+	saves int
+}
+
+func (f *fakeMaintenanceStore) GetMaintenanceState() (*types.MaintenanceState, error) {
+	return f.state, nil
+}
+
+func (f *fakeMaintenanceStore) SaveMaintenanceState(state *types.MaintenanceState) error {
+	saved := *state
+	f.state = &saved
+	f.saves++
+	return nil
+}
+
+func TestPauseSkipsRuns(t *testing.T) {
+	s := &Scheduler{config: &config.Config{}, cron: cron.New(cron.WithSeconds()), jobs: newRegistry()}
+	store := &fakeMaintenanceStore{}
+	s.SetMaintenanceStore(store)
+
+	scheduledJob := newAdjustTestJob(t, s, config.JobConfig{Name: "backup", Schedule: "0 0 2 * * *"})
+	s.jobs.add(scheduledJob)
+
+	state, err := s.Pause("deploy", "ops")
+	if err != nil || !state.Paused || state.Reason != "deploy" || state.PausedBy != "ops" {
+		t.Fatalf("Pause() = %+v, %v", state, err)
+	}
+	if store.state == nil || !store.state.Paused {
+		t.Error("pause not persisted")
+	}
+
+	// Pausing again keeps the original pause
+	if again, _ := s.Pause("incident", "oncall"); again.Reason != "deploy" {
+		t.Errorf("second Pause() reason = %q, want the original", again.Reason)
+	}
+
+	// The job manager is never reached while paused
+	if err := s.executeJob(scheduledJob); err != errPaused {
+		t.Errorf("executeJob() while paused error = %v, want errPaused", err)
+	}
+	if scheduledJob.RunCount != 0 || scheduledJob.Status != "scheduled" || scheduledJob.NextRun.IsZero() {
+		t.Errorf("skipped job = status %s, run count %d, next run %v", scheduledJob.Status, scheduledJob.RunCount, scheduledJob.NextRun)
+	}
+
+	if state, err := s.Resume("ops"); err != nil || state.Paused {
+		t.Fatalf("Resume() = %+v, %v", state, err)
+	}
+	if store.state.Paused || store.saves != 2 {
+		t.Errorf("resume persisted as %+v after %d saves, want unpaused after 2", store.state, store.saves)
+	}
+}
+
+func TestPauseRestoredOnStartup(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+	store := &fakeMaintenanceStore{state: &types.MaintenanceState{Paused: true, Reason: "incident", Since: since}}
+
+	s := &Scheduler{config: &config.Config{}, jobs: newRegistry()}
+	s.SetMaintenanceStore(store)
+	if state := s.Maintenance(); !state.Paused || state.Reason != "incident" || !state.Since.Equal(since) {
+		t.Errorf("Maintenance() after restart = %+v, want the saved pause", state)
+	}
+
+	cfg := &config.Config{}
+	cfg.Advanced.Paused = true
+	configured, err := New(cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	configured.SetMaintenanceStore(&fakeMaintenanceStore{})
+	if !configured.IsPaused() {
+		t.Error("scheduler not paused by configuration")
+	}
+}
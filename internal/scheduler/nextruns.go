@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// PlannedRun is an upcoming run of a job. Time is when the job will run,
+// Scheduled the cron time it stands for; they differ for adjusted runs.
+type PlannedRun struct {
+	Time      time.Time `json:"time"`
+	Scheduled time.Time `json:"scheduled"`
+	Adjusted  bool      `json:"adjusted,omitempty"`
+}
+
+// NextRuns returns the next count runs of a job as the scheduler will make
+// them: cron times in the schedule's time zone (set with a CRON_TZ= prefix),
+// with the run moved by a pending adjustment at its new time
+func (s *Scheduler) NextRuns(jobName string, count int) ([]PlannedRun, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	scheduledJob, exists := s.jobs.get(jobName)
+	if !exists {
+		return nil, false
+	}
+
+	pending := scheduledJob.pending
+	runs := make([]PlannedRun, 0, count)
+	for at := time.Now(); len(runs) < count; {
+		next := scheduledJob.nextOccurrence(at)
+		if next.IsZero() {
+			break // The schedule never fires again
+		}
+		at = next
+
+		if pending != nil && !pending.replaced && next.Equal(pending.occurrence) {
+			if !pending.ran {
+				runs = append(runs, PlannedRun{Time: pending.runAt, Scheduled: next, Adjusted: true})
+			}
+			continue
+		}
+		runs = append(runs, PlannedRun{Time: next, Scheduled: next})
+	}
+
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Time.Before(runs[j].Time) })
+	return runs, true
+}
+
+// Timezone returns the time zone a job's schedule is evaluated in
+func (s *Scheduler) Timezone(jobName string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	scheduledJob, exists := s.jobs.get(jobName)
+	if !exists {
+		return "", false
+	}
+	if spec, ok := scheduledJob.schedule.(*cron.SpecSchedule); ok && spec.Location != nil {
+		return spec.Location.String(), true
+	}
+	return time.Local.String(), true
+}
@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/robfig/cron/v3"
+)
+
+func TestNextRunsIncludeAdjustment(t *testing.T) {
+	s := &Scheduler{config: &config.Config{}, cron: cron.New(cron.WithSeconds()), jobs: newRegistry()}
+	scheduledJob := newAdjustTestJob(t, s, config.JobConfig{Name: "backup", Schedule: "0 0 * * * *"})
+	s.jobs.add(scheduledJob)
+
+	runs, ok := s.NextRuns("backup", 3)
+	if !ok || len(runs) != 3 {
+		t.Fatalf("NextRuns() = %v, %v, want 3 runs", runs, ok)
+	}
+	for i := 1; i < len(runs); i++ {
+		if runs[i].Time.Sub(runs[i-1].Time) != time.Hour || runs[i].Adjusted {
+			t.Errorf("run %d at %s, want hourly cron runs", i, runs[i].Time)
+		}
+	}
+
+	// Move the first run by 20 minutes
+	occurrence := runs[0].Scheduled
+	scheduledJob.pending = &pendingAdjustment{occurrence: occurrence, runAt: occurrence.Add(20 * time.Minute)}
+	runs, _ = s.NextRuns("backup", 3)
+	if !runs[0].Adjusted || !runs[0].Time.Equal(occurrence.Add(20*time.Minute)) || !runs[0].Scheduled.Equal(occurrence) {
+		t.Errorf("first run = %+v, want the adjusted run", runs[0])
+	}
+
+	// Once the adjusted run happened, the replaced cron run is not shown
+	scheduledJob.pending.ran = true
+	runs, _ = s.NextRuns("backup", 3)
+	if runs[0].Adjusted || !runs[0].Time.Equal(occurrence.Add(time.Hour)) {
+		t.Errorf("first run after adjusted run = %+v, want the following cron run", runs[0])
+	}
+
+	if _, ok := s.NextRuns("missing", 3); ok {
+		t.Error("NextRuns() of a missing job succeeded")
+	}
+}
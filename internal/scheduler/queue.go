@@ -0,0 +1,266 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/logging"
+	"github.com/makalin/arcron/internal/tracing"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// queuePollInterval is the longest the queue worker waits before looking
+// at the queue again
+const queuePollInterval = 30 * time.Second
+
+var (
+	// ErrQueuedRunNotFound is returned for runs no longer in the queue
+	ErrQueuedRunNotFound = errors.New("queued run not found")
+	// ErrNoQueue is returned for queued runs without an execution queue
+	ErrNoQueue = errors.New("no execution queue configured")
+)
+
+// QueueStore persists the execution queue, see storage.Storage
+type QueueStore interface {
+	EnqueueRun(run *types.QueuedRun) error
+	GetQueuedRuns() ([]*types.QueuedRun, error)
+	DeleteQueuedRun(id uint) (bool, error)
+	ReorderQueuedRuns(ids []uint) error
+}
+
+// SetQueueStore puts a durable execution queue between the scheduler and
+// the job manager: manual runs queued through QueueRun, smart runs and the
+// retries of failed runs wait in it rather than in memory, so they survive
+// restarts, and a worker started with the scheduler starts them in queue
+// order once due. It must be called before Start.
+func (s *Scheduler) SetQueueStore(store QueueStore) {
+	s.queue = store
+	s.queueWake = make(chan struct{}, 1)
+	if s.jobManager != nil {
+		s.jobManager.SetRetryQueue(s.queueRetry)
+	}
+}
+
+// QueueRun queues a manual run of a job to start as soon as the runs ahead
+// of it in the queue have
+func (s *Scheduler) QueueRun(jobName, correlationID string) (*types.QueuedRun, error) {
+	if s.queue == nil {
+		return nil, ErrNoQueue
+	}
+	if _, exists := s.jobManager.GetJob(jobName); !exists {
+		return nil, fmt.Errorf("job not found: %s", jobName)
+	}
+	run := &types.QueuedRun{
+		JobName:       jobName,
+		Trigger:       types.TriggerManual,
+		NotBefore:     time.Now(),
+		CorrelationID: correlationID,
+	}
+	if err := s.enqueue(run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// QueuedRuns returns the runs in the execution queue, in queue order
+func (s *Scheduler) QueuedRuns() ([]*types.QueuedRun, error) {
+	if s.queue == nil {
+		return []*types.QueuedRun{}, nil
+	}
+	return s.queue.GetQueuedRuns()
+}
+
+// MoveQueuedRun moves a queued run to a position in the queue, counted
+// from 1; positions past the end move it last
+func (s *Scheduler) MoveQueuedRun(id uint, position int) ([]*types.QueuedRun, error) {
+	if s.queue == nil {
+		return nil, ErrNoQueue
+	}
+	s.queueMutex.Lock()
+	defer s.queueMutex.Unlock()
+
+	runs, err := s.queue.GetQueuedRuns()
+	if err != nil {
+		return nil, err
+	}
+	from := -1
+	for i, run := range runs {
+		if run.ID == id {
+			from = i
+			break
+		}
+	}
+	if from < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrQueuedRunNotFound, id)
+	}
+
+	moved := runs[from]
+	runs = append(runs[:from], runs[from+1:]...)
+	to := min(max(position, 1), len(runs)+1) - 1
+	runs = append(runs[:to], append([]*types.QueuedRun{moved}, runs[to:]...)...)
+	ids := make([]uint, len(runs))
+	for i, run := range runs {
+		ids[i] = run.ID
+		run.Position = i + 1
+	}
+	if err := s.queue.ReorderQueuedRuns(ids); err != nil {
+		return nil, err
+	}
+	s.wakeQueue()
+	return runs, nil
+}
+
+// CancelQueuedRun takes a run off the queue before it starts. A cancelled
+// retry is recorded as failed.
+func (s *Scheduler) CancelQueuedRun(id uint) (*types.QueuedRun, error) {
+	if s.queue == nil {
+		return nil, ErrNoQueue
+	}
+	runs, err := s.queue.GetQueuedRuns()
+	if err != nil {
+		return nil, err
+	}
+	for _, run := range runs {
+		if run.ID != id {
+			continue
+		}
+		deleted, err := s.queue.DeleteQueuedRun(id)
+		if err != nil {
+			return nil, err
+		}
+		if !deleted {
+			break
+		}
+		if run.ExecutionID != "" {
+			if err := s.jobManager.CancelRetry(run.ExecutionID, "retry cancelled in the queue"); err != nil {
+				logrus.Errorf("Failed to cancel retry %s of job %s: %v", run.ExecutionID, run.JobName, err)
+			}
+		}
+		logrus.Infof("Cancelled queued %s run of job %s", run.Trigger, run.JobName)
+		return run, nil
+	}
+	return nil, fmt.Errorf("%w: %d", ErrQueuedRunNotFound, id)
+}
+
+// enqueue adds a run to the end of the queue and wakes the worker
+func (s *Scheduler) enqueue(run *types.QueuedRun) error {
+	s.queueMutex.Lock()
+	err := s.queue.EnqueueRun(run)
+	s.queueMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	s.wakeQueue()
+	logrus.Infof("Queued %s run of job %s for %s", run.Trigger, run.JobName, run.NotBefore.Format("15:04:05"))
+	return nil
+}
+
+// queueRetry queues a retry handed over by the job manager
+func (s *Scheduler) queueRetry(ctx context.Context, retry *jobs.JobExecution) error {
+	return s.enqueue(&types.QueuedRun{
+		JobName:       retry.JobName,
+		Trigger:       types.TriggerRetry,
+		NotBefore:     retry.QueuedAt,
+		Reason:        fmt.Sprintf("attempt %d", retry.Attempt),
+		ExecutionID:   retry.ID,
+		CorrelationID: retry.CorrelationID,
+	})
+}
+
+// wakeQueue makes the worker look at the queue again
+func (s *Scheduler) wakeQueue() {
+	select {
+	case s.queueWake <- struct{}{}:
+	default:
+	}
+}
+
+// queueLoop starts the queued runs as they fall due until the scheduler
+// stops
+func (s *Scheduler) queueLoop(ctx context.Context) {
+	for {
+		timer := time.NewTimer(s.dispatchQueue(time.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.stopChan:
+			timer.Stop()
+			return
+		case <-s.queueWake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// dispatchQueue starts the queued runs due at now, in queue order, and
+// returns how long to wait for the next one. Runs stay queued while the
+// job manager drains, to start after the restart.
+func (s *Scheduler) dispatchQueue(now time.Time) time.Duration {
+	if s.jobManager.Draining() {
+		return queuePollInterval
+	}
+	s.queueMutex.Lock()
+	defer s.queueMutex.Unlock()
+
+	runs, err := s.queue.GetQueuedRuns()
+	if err != nil {
+		logrus.Errorf("Failed to read the execution queue: %v", err)
+		return queuePollInterval
+	}
+	wait := queuePollInterval
+	for _, run := range runs {
+		if run.NotBefore.After(now) {
+			wait = min(wait, run.NotBefore.Sub(now))
+			continue
+		}
+		// Taken off the queue first, so a run starts at most once
+		deleted, err := s.queue.DeleteQueuedRun(run.ID)
+		if err != nil {
+			logrus.Errorf("Failed to take run of job %s off the queue: %v", run.JobName, err)
+			continue
+		}
+		if deleted {
+			s.startQueued(run)
+		}
+	}
+	return wait
+}
+
+// startQueued starts a run taken off the queue in the background
+func (s *Scheduler) startQueued(run *types.QueuedRun) {
+	ctx := context.Background()
+	if run.CorrelationID != "" {
+		ctx = logging.WithCorrelationID(ctx, run.CorrelationID)
+	}
+
+	if run.Trigger == types.TriggerRetry {
+		if err := s.jobManager.ResumeRetry(ctx, run.JobName, run.ExecutionID); err != nil {
+			logrus.Errorf("Failed to resume retry %s of job %s: %v", run.ExecutionID, run.JobName, err)
+		}
+		return
+	}
+
+	job, exists := s.jobManager.GetJob(run.JobName)
+	if !exists {
+		logrus.Warnf("Dropped queued %s run of job %s: job not found", run.Trigger, run.JobName)
+		return
+	}
+	go func() {
+		ctx, span := tracing.Start(jobs.WithQueuedAt(ctx, run.NotBefore), "scheduler.queued_run",
+			attribute.String("job.name", run.JobName),
+			attribute.String("trigger", run.Trigger),
+		)
+		err := s.jobManager.ExecuteJob(ctx, job)
+		tracing.End(span, err)
+		if err != nil {
+			logrus.Errorf("Failed to execute queued %s run of job %s: %v", run.Trigger, run.JobName, err)
+		}
+	}()
+}
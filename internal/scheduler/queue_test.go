@@ -0,0 +1,182 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// fakeQueueStore keeps the execution queue in memory
+type fakeQueueStore struct {
+	mutex sync.Mutex
+	runs  []*types.QueuedRun
+	next  uint
+}
+
+func (f *fakeQueueStore) EnqueueRun(run *types.QueuedRun) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.next++
+	run.ID = f.next
+	run.Position = len(f.runs) + 1
+	run.EnqueuedAt = time.Now()
+	stored := *run
+	f.runs = append(f.runs, &stored)
+	return nil
+}
+
+func (f *fakeQueueStore) GetQueuedRuns() ([]*types.QueuedRun, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	runs := make([]*types.QueuedRun, len(f.runs))
+	for i, run := range f.runs {
+		copied := *run
+		runs[i] = &copied
+	}
+	sort.SliceStable(runs, func(a, b int) bool { return runs[a].Position < runs[b].Position })
+	return runs, nil
+}
+
+func (f *fakeQueueStore) DeleteQueuedRun(id uint) (bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for i, run := range f.runs {
+		if run.ID == id {
+			f.runs = append(f.runs[:i], f.runs[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeQueueStore) ReorderQueuedRuns(ids []uint) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for position, id := range ids {
+		for _, run := range f.runs {
+			if run.ID == id {
+				run.Position = position + 1
+			}
+		}
+	}
+	return nil
+}
+
+func newQueueScheduler(t *testing.T, jobConfigs []config.JobConfig) (*Scheduler, *fakeQueueStore, *storage.MemoryStore) {
+	t.Helper()
+
+	executions := storage.NewMemoryStore()
+	jobManager, err := jobs.New(jobConfigs, config.SecurityConfig{}, executions)
+	if err != nil {
+		t.Fatalf("jobs.New() error = %v", err)
+	}
+	t.Cleanup(jobManager.Stop)
+	s := &Scheduler{config: &config.Config{}, jobManager: jobManager, jobs: newRegistry()}
+	queue := &fakeQueueStore{}
+	s.SetQueueStore(queue)
+	return s, queue, executions
+}
+
+func TestExecutionQueue(t *testing.T) {
+	s, queue, _ := newQueueScheduler(t, []config.JobConfig{
+		{Name: "backup", Command: "true", Schedule: "@daily"},
+		{Name: "report", Command: "true", Schedule: "@daily"},
+	})
+
+	backup, err := s.QueueRun("backup", "")
+	if err != nil {
+		t.Fatalf("QueueRun() error = %v", err)
+	}
+	if _, err := s.QueueRun("missing", ""); err == nil {
+		t.Error("QueueRun() of an unknown job succeeded")
+	}
+	later := &types.QueuedRun{JobName: "report", Trigger: types.TriggerSmart, NotBefore: time.Now().Add(time.Hour)}
+	if err := s.enqueue(later); err != nil {
+		t.Fatalf("enqueue() error = %v", err)
+	}
+	report, err := s.QueueRun("report", "")
+	if err != nil {
+		t.Fatalf("QueueRun() error = %v", err)
+	}
+
+	// The last run moved first
+	runs, err := s.MoveQueuedRun(report.ID, 1)
+	if err != nil {
+		t.Fatalf("MoveQueuedRun() error = %v", err)
+	}
+	if len(runs) != 3 || runs[0].ID != report.ID || runs[1].ID != backup.ID || runs[2].ID != later.ID {
+		t.Fatalf("MoveQueuedRun() = %+v, want report, backup, then the later run", runs)
+	}
+	if _, err := s.MoveQueuedRun(99, 1); !errors.Is(err, ErrQueuedRunNotFound) {
+		t.Errorf("MoveQueuedRun() of an unknown run error = %v, want ErrQueuedRunNotFound", err)
+	}
+
+	if _, err := s.CancelQueuedRun(backup.ID); err != nil {
+		t.Fatalf("CancelQueuedRun() error = %v", err)
+	}
+	if _, err := s.CancelQueuedRun(backup.ID); !errors.Is(err, ErrQueuedRunNotFound) {
+		t.Errorf("CancelQueuedRun() twice error = %v, want ErrQueuedRunNotFound", err)
+	}
+
+	// Due runs start, the later one stays queued until it is due
+	wait := s.dispatchQueue(time.Now())
+	if wait != queuePollInterval {
+		t.Errorf("dispatchQueue() wait = %s, want the poll interval as the next run is an hour away", wait)
+	}
+	remaining, _ := queue.GetQueuedRuns()
+	if len(remaining) != 1 || remaining[0].ID != later.ID {
+		t.Errorf("queue after dispatch = %+v, want only the later run", remaining)
+	}
+	if wait := s.dispatchQueue(later.NotBefore.Add(-time.Second)); wait != time.Second {
+		t.Errorf("dispatchQueue() wait = %s, want 1s until the later run", wait)
+	}
+}
+
+func TestQueuedRetry(t *testing.T) {
+	s, queue, executions := newQueueScheduler(t, []config.JobConfig{
+		{Name: "flaky", Command: "false", Schedule: "@daily", Retries: 1},
+	})
+	job, _ := s.jobManager.GetJob("flaky")
+
+	// The failed first attempt hands its retry to the queue
+	if err := s.jobManager.ExecuteJob(context.Background(), job); err == nil {
+		t.Fatal("ExecuteJob() of a failing job succeeded")
+	}
+	runs, _ := queue.GetQueuedRuns()
+	if len(runs) != 1 || runs[0].Trigger != types.TriggerRetry || runs[0].ExecutionID == "" {
+		t.Fatalf("queued runs = %+v, want the retry", runs)
+	}
+	retry := runs[0]
+	pending, _ := executions.GetJobExecution(retry.ExecutionID)
+	if pending == nil || pending.Status != types.StatusPending || pending.Attempt != 2 {
+		t.Fatalf("retry execution = %+v, want the pending second attempt", pending)
+	}
+
+	// Due after its backoff, the retry runs as the last attempt
+	s.dispatchQueue(retry.NotBefore)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		execution, _ := executions.GetJobExecution(retry.ExecutionID)
+		if execution.Status == types.StatusFailed {
+			if execution.ParentExecutionID != pending.ParentExecutionID {
+				t.Errorf("retry parent = %s, want %s", execution.ParentExecutionID, pending.ParentExecutionID)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("retry execution still %s", execution.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if runs, _ := queue.GetQueuedRuns(); len(runs) != 0 {
+		t.Errorf("queued runs after the last attempt = %+v, want none", runs)
+	}
+}
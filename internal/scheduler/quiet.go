@@ -0,0 +1,179 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/sirupsen/logrus"
+)
+
+// quietWindow is a parsed quiet hours window
+type quietWindow struct {
+	name      string
+	start     time.Duration // time of day
+	end       time.Duration
+	weekdays  map[time.Weekday]bool
+	monthDays []int
+	location  *time.Location
+}
+
+// weekdayNames maps lower-case weekday names and abbreviations to weekdays
+var weekdayNames = func() map[string]time.Weekday {
+	names := make(map[string]time.Weekday)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		names[name] = day
+		names[name[:3]] = day
+	}
+	return names
+}()
+
+// parseQuietHours parses the quiet hours windows of the configuration
+func parseQuietHours(windows []config.QuietHoursConfig) ([]quietWindow, error) {
+	parsed := make([]quietWindow, 0, len(windows))
+	for i, cfg := range windows {
+		name := cfg.Name
+		if name == "" {
+			name = fmt.Sprintf("advanced.quiet_hours[%d]", i)
+		}
+		window, err := parseQuietWindow(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("quiet hours %s: %v", name, err)
+		}
+		window.name = name
+		parsed = append(parsed, window)
+	}
+	return parsed, nil
+}
+
+func parseQuietWindow(cfg config.QuietHoursConfig) (quietWindow, error) {
+	window := quietWindow{location: time.Local, end: 24 * time.Hour}
+
+	if cfg.Start != "" || cfg.End != "" {
+		start, err := parseTimeOfDay(cfg.Start)
+		if err != nil {
+			return window, fmt.Errorf("invalid start: %v", err)
+		}
+		end, err := parseTimeOfDay(cfg.End)
+		if err != nil {
+			return window, fmt.Errorf("invalid end: %v", err)
+		}
+		if start == end {
+			return window, fmt.Errorf("start and end are both %s", cfg.Start)
+		}
+		window.start, window.end = start, end
+	}
+
+	if len(cfg.Weekdays) > 0 {
+		window.weekdays = make(map[time.Weekday]bool)
+		for _, name := range cfg.Weekdays {
+			day, ok := weekdayNames[strings.ToLower(name)]
+			if !ok {
+				return window, fmt.Errorf("invalid weekday %q", name)
+			}
+			window.weekdays[day] = true
+		}
+	}
+
+	for _, day := range cfg.MonthDays {
+		if day == 0 || day < -31 || day > 31 {
+			return window, fmt.Errorf("invalid day of the month %d", day)
+		}
+	}
+	window.monthDays = cfg.MonthDays
+
+	if cfg.Timezone != "" {
+		location, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return window, fmt.Errorf("invalid timezone %q: %v", cfg.Timezone, err)
+		}
+		window.location = location
+	}
+	return window, nil
+}
+
+// parseTimeOfDay parses a "15:04" time of day
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day like 15:04", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// contains reports whether a time falls within the window
+func (w quietWindow) contains(t time.Time) bool {
+	t = t.In(w.location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, w.location)
+	offset := t.Sub(midnight)
+
+	if w.start < w.end {
+		return offset >= w.start && offset < w.end && w.startsOn(midnight)
+	}
+	// The window runs past midnight: its early part belongs to the day before
+	if offset >= w.start {
+		return w.startsOn(midnight)
+	}
+	return offset < w.end && w.startsOn(midnight.AddDate(0, 0, -1))
+}
+
+// startsOn reports whether the window starts on the day of a time
+func (w quietWindow) startsOn(day time.Time) bool {
+	if w.weekdays != nil && !w.weekdays[day.Weekday()] {
+		return false
+	}
+	if len(w.monthDays) == 0 {
+		return true
+	}
+	last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+	for _, monthDay := range w.monthDays {
+		if monthDay == day.Day() || (monthDay < 0 && last+monthDay+1 == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// quietHoursAt returns the name of the quiet hours window any of the given
+// times falls within, or an empty string
+func (s *Scheduler) quietHoursAt(times ...time.Time) string {
+	for _, window := range s.quietHours {
+		for _, t := range times {
+			if window.contains(t) {
+				return window.name
+			}
+		}
+	}
+	return ""
+}
+
+// skipQuietHours records that quiet hours kept the scheduler from moving
+// the next run of a job, once per cron run. It must be called with the
+// scheduler lock held.
+func (s *Scheduler) skipQuietHours(scheduledJob *ScheduledJob, prediction *ml.Prediction, window string) *Adjustment {
+	now := time.Now()
+	occurrence := scheduledJob.nextOccurrence(now)
+	if scheduledJob.quietSkipped.Equal(occurrence) {
+		return nil
+	}
+	scheduledJob.quietSkipped = occurrence
+
+	skipped := &Adjustment{
+		JobName:     scheduledJob.Job.GetName(),
+		Time:        now,
+		PreviousRun: occurrence,
+		NewRun:      prediction.OptimalTime,
+		Prediction:  prediction,
+		DryRun:      scheduledJob.Job.GetConfig().IsDryRun(s.config.Advanced.DryRun),
+		Skipped:     "quiet hours " + window,
+	}
+	quietHoursSkips.Inc(scheduledJob.Job.GetName(), scheduledJob.Job.GetNamespace())
+
+	logrus.Infof("Not adjusting schedule for job %s during quiet hours %s: run at %s stays, optimal time was %s",
+		scheduledJob.Job.GetName(), window, occurrence.Format("15:04:05"),
+		prediction.OptimalTime.Format("15:04:05"))
+	return skipped
+}
@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/robfig/cron/v3"
+)
+
+func TestQuietWindowContains(t *testing.T) {
+	windows, err := parseQuietHours([]config.QuietHoursConfig{
+		{Name: "month-end", MonthDays: []int{-1, 1}, Timezone: "UTC"},
+		{Name: "nightly", Start: "22:00", End: "02:00", Weekdays: []string{"fri"}, Timezone: "UTC"},
+	})
+	if err != nil {
+		t.Fatalf("parseQuietHours() error = %v", err)
+	}
+	monthEnd, nightly := windows[0], windows[1]
+
+	at := func(value string) time.Time {
+		t.Helper()
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	tests := []struct {
+		window quietWindow
+		time   string
+		want   bool
+	}{
+		{monthEnd, "2024-02-29T12:00:00Z", true}, // last day of a leap February
+		{monthEnd, "2024-02-28T12:00:00Z", false},
+		{monthEnd, "2024-03-01T00:00:00Z", true},
+		{monthEnd, "2024-03-02T00:00:00Z", false},
+		{nightly, "2024-03-01T23:00:00Z", true},  // Friday night
+		{nightly, "2024-03-02T01:59:00Z", true},  // past midnight into Saturday
+		{nightly, "2024-03-02T02:00:00Z", false}, // end is exclusive
+		{nightly, "2024-03-02T23:00:00Z", false}, // Saturday night
+		{nightly, "2024-03-01T01:00:00Z", false}, // Thursday's night
+	}
+	for _, tt := range tests {
+		if got := tt.window.contains(at(tt.time)); got != tt.want {
+			t.Errorf("%s contains %s = %v, want %v", tt.window.name, tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestParseQuietHoursErrors(t *testing.T) {
+	invalid := []config.QuietHoursConfig{
+		{Name: "bad-start", Start: "25:00", End: "02:00"},
+		{Name: "no-end", Start: "22:00"},
+		{Name: "empty", Start: "22:00", End: "22:00"},
+		{Name: "bad-weekday", Weekdays: []string{"someday"}},
+		{Name: "bad-day", MonthDays: []int{0}},
+		{Name: "bad-zone", Timezone: "Nowhere/City"},
+	}
+	for _, window := range invalid {
+		if _, err := parseQuietHours([]config.QuietHoursConfig{window}); err == nil {
+			t.Errorf("parseQuietHours(%s) succeeded, want an error", window.Name)
+		}
+	}
+}
+
+func TestQuietHoursSkipAdjustment(t *testing.T) {
+	store := &fakeAdjustmentStore{outcomes: make(map[uint]string)}
+	s := &Scheduler{
+		config: &config.Config{},
+		cron:   cron.New(cron.WithSeconds()),
+		jobs:   newRegistry(),
+	}
+	s.config.Advanced.AdjustmentThreshold = 5
+	s.SetAdjustmentStore(store)
+	// A window covering every day
+	quietHours, err := parseQuietHours([]config.QuietHoursConfig{{Name: "freeze"}})
+	if err != nil {
+		t.Fatalf("parseQuietHours() error = %v", err)
+	}
+	s.quietHours = quietHours
+	s.cron.Start()
+	defer s.cron.Stop()
+
+	scheduledJob := newAdjustTestJob(t, s, config.JobConfig{Name: "backup", Schedule: "0 0 0 * * *"})
+	s.jobs.add(scheduledJob)
+	occurrence := scheduledJob.schedule.Next(time.Now())
+
+	prediction := &ml.Prediction{JobName: "backup", OptimalTime: occurrence.Add(30 * time.Minute), Confidence: 0.9}
+	skipped := s.applyPrediction(scheduledJob, prediction)
+	if skipped == nil || skipped.Skipped != "quiet hours freeze" {
+		t.Fatalf("applyPrediction() = %+v, want an adjustment skipped for quiet hours", skipped)
+	}
+	if scheduledJob.pending != nil {
+		t.Error("run moved during quiet hours")
+	}
+	s.recordAdjustment(skipped)
+	if len(store.stored) != 1 || store.stored[0].Outcome != types.AdjustmentSkipped || store.stored[0].SkipReason != "quiet hours freeze" {
+		t.Fatalf("stored adjustments = %+v, want one skipped", store.stored)
+	}
+
+	if again := s.applyPrediction(scheduledJob, prediction); again != nil {
+		t.Errorf("applyPrediction() for the same run = %+v, want it recorded once", again)
+	}
+}
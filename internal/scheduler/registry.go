@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"container/heap"
+	"time"
+)
+
+// registry indexes scheduled jobs by name and by next run, so the
+// scheduling loop finds the jobs due soon without scanning every job. Jobs
+// that never run again, such as finished one-shots, are only indexed by
+// name. It is guarded by the scheduler lock.
+type registry struct {
+	byName    map[string]*ScheduledJob
+	byNextRun jobHeap
+}
+
+func newRegistry() *registry {
+	return &registry{byName: make(map[string]*ScheduledJob)}
+}
+
+// add registers a job, replacing any job of the same name
+func (r *registry) add(scheduledJob *ScheduledJob) {
+	name := scheduledJob.Job.GetName()
+	if existing, ok := r.byName[name]; ok {
+		r.retire(existing)
+	}
+	r.byName[name] = scheduledJob
+	scheduledJob.index = -1
+	if !scheduledJob.NextRun.IsZero() {
+		heap.Push(&r.byNextRun, scheduledJob)
+	}
+}
+
+// remove unregisters a job
+func (r *registry) remove(scheduledJob *ScheduledJob) {
+	r.retire(scheduledJob)
+	if r.byName[scheduledJob.Job.GetName()] == scheduledJob {
+		delete(r.byName, scheduledJob.Job.GetName())
+	}
+}
+
+// get returns the job with the given name
+func (r *registry) get(name string) (*ScheduledJob, bool) {
+	scheduledJob, ok := r.byName[name]
+	return scheduledJob, ok
+}
+
+// len returns the number of registered jobs
+func (r *registry) len() int {
+	return len(r.byName)
+}
+
+// setNextRun updates when a job runs next and reindexes it. A zero time
+// means the job never runs again.
+func (r *registry) setNextRun(scheduledJob *ScheduledJob, next time.Time) {
+	scheduledJob.NextRun = next
+	switch {
+	case next.IsZero():
+		r.retire(scheduledJob)
+	case r.indexed(scheduledJob):
+		heap.Fix(&r.byNextRun, scheduledJob.index)
+	case scheduledJob.index < 0:
+		heap.Push(&r.byNextRun, scheduledJob)
+	}
+}
+
+// retire drops a job from the next run index
+func (r *registry) retire(scheduledJob *ScheduledJob) {
+	if r.indexed(scheduledJob) {
+		heap.Remove(&r.byNextRun, scheduledJob.index)
+	}
+}
+
+// indexed reports whether a job is in the next run index
+func (r *registry) indexed(scheduledJob *ScheduledJob) bool {
+	return scheduledJob.index >= 0 && scheduledJob.index < len(r.byNextRun) &&
+		r.byNextRun[scheduledJob.index] == scheduledJob
+}
+
+// dueBefore returns the jobs whose next run is before the given time, in
+// no particular order. Subtrees of the heap starting at or after it are
+// skipped, so the cost grows with the number of jobs returned.
+func (r *registry) dueBefore(before time.Time) []*ScheduledJob {
+	var due []*ScheduledJob
+	var walk func(i int)
+	walk = func(i int) {
+		if i >= len(r.byNextRun) || !r.byNextRun[i].NextRun.Before(before) {
+			return
+		}
+		due = append(due, r.byNextRun[i])
+		walk(2*i + 1)
+		walk(2*i + 2)
+	}
+	walk(0)
+	return due
+}
+
+// jobHeap is a min-heap of jobs ordered by next run
+type jobHeap []*ScheduledJob
+
+func (h jobHeap) Len() int           { return len(h) }
+func (h jobHeap) Less(i, j int) bool { return h[i].NextRun.Before(h[j].NextRun) }
+
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *jobHeap) Push(x interface{}) {
+	scheduledJob := x.(*ScheduledJob)
+	scheduledJob.index = len(*h)
+	*h = append(*h, scheduledJob)
+}
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	scheduledJob := old[n-1]
+	old[n-1] = nil
+	scheduledJob.index = -1
+	*h = old[:n-1]
+	return scheduledJob
+}
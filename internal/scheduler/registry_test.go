@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+)
+
+func newRegistryTestJobs(tb testing.TB, count int, start time.Time) []*ScheduledJob {
+	tb.Helper()
+
+	scheduledJobs := make([]*ScheduledJob, count)
+	for i := range scheduledJobs {
+		job, err := jobs.NewJob(config.JobConfig{Name: fmt.Sprintf("job-%d", i), Command: "true"})
+		if err != nil {
+			tb.Fatalf("NewJob() error = %v", err)
+		}
+		scheduledJobs[i] = &ScheduledJob{Job: job, NextRun: start.Add(time.Duration(i) * time.Minute)}
+	}
+	return scheduledJobs
+}
+
+func TestRegistryDueBefore(t *testing.T) {
+	now := time.Now()
+	r := newRegistry()
+	scheduledJobs := newRegistryTestJobs(t, 100, now)
+	for i := len(scheduledJobs) - 1; i >= 0; i-- {
+		r.add(scheduledJobs[i])
+	}
+
+	if due := r.dueBefore(now.Add(10 * time.Minute)); len(due) != 10 {
+		t.Errorf("dueBefore(+10m) = %d jobs, want 10", len(due))
+	}
+
+	// Moving a job later takes it out of the window
+	r.setNextRun(scheduledJobs[0], now.Add(time.Hour))
+	due := r.dueBefore(now.Add(10 * time.Minute))
+	if len(due) != 9 {
+		t.Errorf("dueBefore(+10m) after move = %d jobs, want 9", len(due))
+	}
+	for _, scheduledJob := range due {
+		if scheduledJob == scheduledJobs[0] {
+			t.Error("moved job still due")
+		}
+	}
+
+	// Replacing a job keeps a single entry for its name
+	r.add(scheduledJobs[5])
+	if r.len() != 100 || len(r.byNextRun) != 100 {
+		t.Errorf("registry size after replace = %d/%d, want 100", r.len(), len(r.byNextRun))
+	}
+	if got, ok := r.get("job-5"); !ok || got != scheduledJobs[5] {
+		t.Error("get() after replace did not return the job")
+	}
+}
+
+func BenchmarkRegistryDueBefore(b *testing.B) {
+	for _, count := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("jobs=%d", count), func(b *testing.B) {
+			now := time.Now()
+			r := newRegistry()
+			for _, scheduledJob := range newRegistryTestJobs(b, count, now) {
+				r.add(scheduledJob)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.dueBefore(now.Add(10 * time.Minute))
+			}
+		})
+	}
+}
+
+func BenchmarkRegistrySetNextRun(b *testing.B) {
+	now := time.Now()
+	r := newRegistry()
+	scheduledJobs := newRegistryTestJobs(b, 1000, now)
+	for _, scheduledJob := range scheduledJobs {
+		r.add(scheduledJob)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scheduledJob := scheduledJobs[i%len(scheduledJobs)]
+		r.setNextRun(scheduledJob, scheduledJob.NextRun.Add(24*time.Hour))
+	}
+}
@@ -0,0 +1,226 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// RevisionAuthorConfig is the author of revisions made by editing the
+// configuration files
+const RevisionAuthorConfig = "config"
+
+// ErrRevisionNotFound is returned when rolling back to a revision that was
+// not recorded
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// ErrRollbackUnsupported is returned when rolling back a job not managed
+// through the API, or to a revision that cannot be restored
+var ErrRollbackUnsupported = errors.New("rollback not supported")
+
+// RevisionStore persists the revisions of jobs, see storage.Storage
+type RevisionStore interface {
+	StoreJobRevision(revision *types.JobRevision) error
+	GetJobRevision(jobName string, revision int) (*types.JobRevision, error)
+	GetLatestJobRevision(jobName string) (*types.JobRevision, error)
+	GetLatestJobRevisions() ([]*types.JobRevision, error)
+}
+
+// SetRevisionStore makes the scheduler record a revision whenever a job is
+// created, changed or deleted. It must be called before Start.
+func (s *Scheduler) SetRevisionStore(store RevisionStore) {
+	s.revisions = store
+}
+
+// recordConfiguredRevisions records revisions for the jobs of the
+// configuration files created or changed while the scheduler was stopped,
+// and for those no longer defined
+func (s *Scheduler) recordConfiguredRevisions() {
+	if s.revisions == nil {
+		return
+	}
+
+	s.mutex.RLock()
+	configured := append([]config.JobConfig{}, s.Jobs()...)
+	s.mutex.RUnlock()
+	defined := make(map[string]bool, len(configured))
+	for _, job := range configured {
+		defined[job.Name] = true
+		if !isAPIJob(job) {
+			s.recordRevision(job.Name, &job, RevisionAuthorConfig, 0)
+		}
+	}
+
+	latest, err := s.revisions.GetLatestJobRevisions()
+	if err != nil {
+		logrus.Errorf("Failed to record revisions of removed jobs: %v", err)
+		return
+	}
+	for _, revision := range latest {
+		if revision.Source != config.SourceAPI && !defined[revision.JobName] {
+			s.recordRevision(revision.JobName, nil, RevisionAuthorConfig, 0)
+		}
+	}
+}
+
+// recordRevision records the definition of a job, or its deletion when job
+// is nil, unless it matches the latest revision. Jobs not synced through
+// the API are recorded with their secrets redacted. Failures are logged.
+func (s *Scheduler) recordRevision(name string, job *config.JobConfig, author string, rollbackOf int) {
+	if s.revisions == nil {
+		return
+	}
+	s.revisionMutex.Lock()
+	defer s.revisionMutex.Unlock()
+
+	revision, err := s.nextRevision(name, job)
+	if err != nil {
+		logrus.Errorf("Failed to record revision of job %s: %v", name, err)
+		return
+	}
+	if revision == nil {
+		return
+	}
+	revision.Author = author
+	revision.RollbackOf = rollbackOf
+	if err := s.revisions.StoreJobRevision(revision); err != nil {
+		logrus.Errorf("Failed to record revision of job %s: %v", name, err)
+	}
+}
+
+// nextRevision compares a job with its latest revision, returning the
+// revision to record or nil if the job is unchanged
+func (s *Scheduler) nextRevision(name string, job *config.JobConfig) (*types.JobRevision, error) {
+	latest, err := s.revisions.GetLatestJobRevision(name)
+	if err != nil {
+		return nil, err
+	}
+	deleted := latest == nil || latest.Action == types.RevisionDelete
+
+	if job == nil {
+		if deleted {
+			return nil, nil
+		}
+		return &types.JobRevision{JobName: name, Action: types.RevisionDelete, Source: latest.Source}, nil
+	}
+
+	settings, err := config.JobSettings(*job)
+	if err != nil {
+		return nil, err
+	}
+	if !isAPIJob(*job) {
+		config.RedactJob(settings)
+	}
+	revision := &types.JobRevision{JobName: name, Action: types.RevisionCreate, Source: job.Source, Definition: settings}
+	if deleted {
+		return revision, nil
+	}
+
+	// Compared as recorded, so redacted secrets do not count as changes
+	current, err := config.DecodeJob(settings)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := config.DecodeJob(latest.Definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode revision %d: %v", latest.Revision, err)
+	}
+	diff, err := config.DiffJobs(previous, current)
+	if err != nil {
+		return nil, err
+	}
+	if len(diff) == 0 && latest.Source == job.Source {
+		return nil, nil
+	}
+	revision.Action = types.RevisionUpdate
+	revision.Diff = diff
+	return revision, nil
+}
+
+// recordScheduleChange records a job whose schedule was updated. The
+// saved definition of a job synced through the API is updated too, so the
+// schedule survives a restart.
+func (s *Scheduler) recordScheduleChange(job config.JobConfig, author string) {
+	if isAPIJob(job) && s.definitions != nil {
+		s.syncMutex.Lock()
+		saved, err := s.definitions.GetJobDefinitions()
+		if err == nil {
+			for i := range saved {
+				if saved[i].Name == job.Name {
+					saved[i].Schedule = job.Schedule
+					saved[i].Source = config.SourceAPI
+					job = saved[i]
+				}
+			}
+			err = s.definitions.SaveJobDefinitions(saved)
+		}
+		s.syncMutex.Unlock()
+		if err != nil {
+			logrus.Errorf("Failed to save the schedule of job %s: %v", job.Name, err)
+		}
+	}
+	s.recordRevision(job.Name, &job, author, 0)
+}
+
+// RollbackJob restores the definition a job synced through the API had at
+// a revision, as a sync of that job would, recording a new revision.
+// Revisions recorded while the job was defined in a configuration file
+// cannot be restored, nor can jobs currently defined in one.
+func (s *Scheduler) RollbackJob(name string, revision int, author string) (*JobSyncPlan, error) {
+	if s.revisions == nil || s.definitions == nil {
+		return nil, fmt.Errorf("jobs cannot be rolled back without a database")
+	}
+	target, err := s.revisions.GetJobRevision(name, revision)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, fmt.Errorf("%w: job %s has no revision %d", ErrRevisionNotFound, name, revision)
+	}
+	if target.Action == types.RevisionDelete {
+		return nil, fmt.Errorf("%w: revision %d deleted job %s", ErrRollbackUnsupported, revision, name)
+	}
+	if target.Source != config.SourceAPI {
+		return nil, fmt.Errorf("%w: revision %d of job %s was not synced through the API", ErrRollbackUnsupported, revision, name)
+	}
+	job, err := config.DecodeJob(target.Definition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode revision %d: %v", revision, err)
+	}
+	job.Name = name
+
+	s.mutex.RLock()
+	for _, current := range s.Jobs() {
+		if current.Name == name && !isAPIJob(current) {
+			s.mutex.RUnlock()
+			return nil, fmt.Errorf("%w: job %s is defined in %s", ErrRollbackUnsupported, name, jobSource(current))
+		}
+	}
+	s.mutex.RUnlock()
+
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+	saved, err := s.definitions.GetJobDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]config.JobConfig, 0, len(saved)+1)
+	for _, current := range saved {
+		if current.Name != name {
+			jobs = append(jobs, current)
+		}
+	}
+	jobs = append(jobs, job)
+	return s.syncJobDefinitions(jobs, false, author, revision)
+}
+
+// jobSource describes where a job is defined
+func jobSource(job config.JobConfig) string {
+	if job.Source == "" {
+		return "the configuration file"
+	}
+	return job.Source
+}
@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// memoryRevisions keeps job revisions in memory
+type memoryRevisions struct {
+	revisions []*types.JobRevision
+}
+
+func (m *memoryRevisions) StoreJobRevision(revision *types.JobRevision) error {
+	revision.Revision = 1
+	if latest, _ := m.GetLatestJobRevision(revision.JobName); latest != nil {
+		revision.Revision = latest.Revision + 1
+	}
+	m.revisions = append(m.revisions, revision)
+	return nil
+}
+
+func (m *memoryRevisions) GetJobRevision(jobName string, number int) (*types.JobRevision, error) {
+	for _, revision := range m.revisions {
+		if revision.JobName == jobName && revision.Revision == number {
+			return revision, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryRevisions) GetLatestJobRevision(jobName string) (*types.JobRevision, error) {
+	var latest *types.JobRevision
+	for _, revision := range m.revisions {
+		if revision.JobName == jobName {
+			latest = revision
+		}
+	}
+	return latest, nil
+}
+
+func (m *memoryRevisions) GetLatestJobRevisions() ([]*types.JobRevision, error) {
+	latest := make(map[string]*types.JobRevision)
+	var names []string
+	for _, revision := range m.revisions {
+		if latest[revision.JobName] == nil {
+			names = append(names, revision.JobName)
+		}
+		latest[revision.JobName] = revision
+	}
+	revisions := make([]*types.JobRevision, len(names))
+	for i, name := range names {
+		revisions[i] = latest[name]
+	}
+	return revisions, nil
+}
+
+func TestJobRevisions(t *testing.T) {
+	jobManager, err := jobs.New(nil, config.SecurityConfig{}, nil)
+	if err != nil {
+		t.Fatalf("jobs.New() error = %v", err)
+	}
+	cfg := &config.Config{Jobs: []config.JobConfig{
+		{Name: "main", Command: "true", Schedule: "0 0 1 * * *", Environment: map[string]string{"API_TOKEN": "hunter2"}},
+		{Name: "report", Command: "true", Schedule: "0 0 2 * * *", Source: "jobs.d/team.yaml"},
+	}}
+	s, err := New(cfg, jobManager, nil, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	revisions := &memoryRevisions{}
+	s.SetRevisionStore(revisions)
+	s.SetJobDefinitionStore(&memoryDefinitions{})
+	for _, jobConfig := range cfg.Jobs {
+		if err := s.scheduleJob(jobConfig); err != nil {
+			t.Fatalf("scheduleJob() error = %v", err)
+		}
+	}
+
+	s.recordConfiguredRevisions()
+	s.recordConfiguredRevisions()
+	if len(revisions.revisions) != 2 || revisions.revisions[0].Action != types.RevisionCreate {
+		t.Fatalf("revisions = %+v, want one creation per configured job", revisions.revisions)
+	}
+	if env := revisions.revisions[0].Definition["environment"].(map[string]interface{}); env["API_TOKEN"] == "hunter2" {
+		t.Error("secret of a configured job was recorded")
+	}
+
+	if err := s.SyncJobs(nil); err != nil {
+		t.Fatalf("SyncJobs() error = %v", err)
+	}
+	if latest, _ := revisions.GetLatestJobRevision("report"); latest.Action != types.RevisionDelete || latest.Author != RevisionAuthorConfig {
+		t.Errorf("latest revision of report = %+v, want its deletion", latest)
+	}
+
+	for _, schedule := range []string{"@daily", "@hourly"} {
+		if _, err := s.SyncJobDefinitions([]config.JobConfig{{Name: "export", Command: "true", Schedule: schedule}}, false, "alice"); err != nil {
+			t.Fatalf("SyncJobDefinitions() error = %v", err)
+		}
+	}
+	latest, _ := revisions.GetLatestJobRevision("export")
+	if latest.Revision != 2 || latest.Author != "alice" || len(latest.Diff) != 1 || latest.Diff[0].Field != "schedule" {
+		t.Fatalf("latest revision of export = %+v, want the schedule change by alice", latest)
+	}
+
+	plan, err := s.RollbackJob("export", 1, "bob")
+	if err != nil || len(plan.Changes) != 1 || plan.Changes[0].Action != SyncUpdate {
+		t.Fatalf("RollbackJob() = %+v, %v, want export updated", plan, err)
+	}
+	if status, ok := s.GetJobStatus("export"); !ok || status.Job.GetSchedule() != "@daily" {
+		t.Error("rolled back job was not rescheduled")
+	}
+	if latest, _ = revisions.GetLatestJobRevision("export"); latest.Revision != 3 || latest.RollbackOf != 1 || latest.Author != "bob" {
+		t.Errorf("latest revision of export = %+v, want a rollback to revision 1 by bob", latest)
+	}
+
+	if _, err := s.RollbackJob("export", 9, "bob"); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("RollbackJob() to a missing revision error = %v, want ErrRevisionNotFound", err)
+	}
+	if _, err := s.RollbackJob("main", 1, "bob"); !errors.Is(err, ErrRollbackUnsupported) {
+		t.Errorf("RollbackJob() of a configured job error = %v, want ErrRollbackUnsupported", err)
+	}
+
+	if err := s.UpdateSchedule("main", "0 0 3 * * *", "carol"); err != nil {
+		t.Fatalf("UpdateSchedule() error = %v", err)
+	}
+	if latest, _ = revisions.GetLatestJobRevision("main"); latest.Action != types.RevisionUpdate || latest.Author != "carol" {
+		t.Errorf("latest revision of main = %+v, want the schedule change by carol", latest)
+	}
+}
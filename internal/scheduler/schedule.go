@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule kinds besides cron expressions and descriptors such as @daily
+// or "@every 15m"
+const (
+	onceDescriptor   = "@once"
+	rebootDescriptor = "@reboot"
+)
+
+// onceSchedule fires a single time
+type onceSchedule struct {
+	at time.Time
+}
+
+// Next returns the time of the run if it is still ahead, the zero time
+// otherwise
+func (o onceSchedule) Next(t time.Time) time.Time {
+	if t.Before(o.at) {
+		return o.at
+	}
+	return time.Time{}
+}
+
+// rebootSchedule runs once when the scheduler starts. It never fires from
+// cron; the scheduler runs it itself.
+type rebootSchedule struct{}
+
+// Next always returns the zero time
+func (rebootSchedule) Next(time.Time) time.Time {
+	return time.Time{}
+}
+
+// parseSchedule parses a job schedule: a cron expression with a leading
+// seconds field, a descriptor such as @daily or "@every 15m", a single run
+// at "@once <RFC 3339 timestamp>", or "@reboot" for a run at startup
+func parseSchedule(expression string) (cron.Schedule, error) {
+	spec := strings.TrimSpace(expression)
+	switch {
+	case spec == rebootDescriptor:
+		return rebootSchedule{}, nil
+	case spec == onceDescriptor || strings.HasPrefix(spec, onceDescriptor+" "):
+		value := strings.TrimSpace(strings.TrimPrefix(spec, onceDescriptor))
+		if value == "" {
+			return nil, fmt.Errorf("%s requires a timestamp, e.g. \"%s 2024-06-01T02:00:00Z\"", onceDescriptor, onceDescriptor)
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s timestamp %q: expected RFC 3339, e.g. 2024-06-01T02:00:00Z", onceDescriptor, value)
+		}
+		return onceSchedule{at: at}, nil
+	}
+	return scheduleParser.Parse(expression)
+}
+
+// isOneShot reports whether a schedule runs at most once
+func isOneShot(schedule cron.Schedule) bool {
+	switch schedule.(type) {
+	case onceSchedule, rebootSchedule:
+		return true
+	}
+	return false
+}
+
+// nextOccurrence returns when the cron entry of a job fires next after t.
+// Interval schedules count from the entry's last firing rather than from
+// t, so they are stepped forward from there.
+func (sj *ScheduledJob) nextOccurrence(t time.Time) time.Time {
+	if _, ok := sj.schedule.(cron.ConstantDelaySchedule); !ok || sj.anchor.IsZero() {
+		return sj.schedule.Next(t)
+	}
+	at := sj.schedule.Next(sj.anchor)
+	for !at.IsZero() && !at.After(t) {
+		at = sj.schedule.Next(at)
+	}
+	return at
+}
+
+// finishJob unschedules a one-shot job after its run. It must be called
+// with the scheduler lock held.
+func (s *Scheduler) finishJob(scheduledJob *ScheduledJob) {
+	s.cron.Remove(scheduledJob.EntryID)
+	scheduledJob.pending = nil
+	scheduledJob.Status = "finished"
+	s.jobs.setNextRun(scheduledJob, time.Time{})
+}
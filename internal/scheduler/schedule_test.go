@@ -0,0 +1,187 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/robfig/cron/v3"
+)
+
+func TestParseSchedule(t *testing.T) {
+	valid := []string{"0 0 2 * * *", "@daily", "@every 15m", "@once 2030-01-01T02:00:00Z", "@reboot"}
+	for _, expression := range valid {
+		if _, err := parseSchedule(expression); err != nil {
+			t.Errorf("parseSchedule(%q) error = %v", expression, err)
+		}
+	}
+
+	invalid := []string{"@once", "@once tomorrow", "@every", "@reboot now"}
+	for _, expression := range invalid {
+		if _, err := parseSchedule(expression); err == nil {
+			t.Errorf("parseSchedule(%q) succeeded, want error", expression)
+		}
+	}
+
+	at := time.Date(2030, 1, 1, 2, 0, 0, 0, time.UTC)
+	once, _ := parseSchedule("@once 2030-01-01T02:00:00Z")
+	if next := once.Next(at.Add(-time.Hour)); !next.Equal(at) {
+		t.Errorf("Next() before the run = %v, want %v", next, at)
+	}
+	if next := once.Next(at); !next.IsZero() {
+		t.Errorf("Next() after the run = %v, want zero", next)
+	}
+}
+
+func TestIntervalOccurrenceFollowsLastFiring(t *testing.T) {
+	schedule, _ := parseSchedule("@every 15m")
+	now := time.Now().Truncate(time.Second)
+	scheduledJob := &ScheduledJob{schedule: schedule, anchor: now.Add(-10 * time.Minute)}
+
+	if next, want := scheduledJob.nextOccurrence(now), now.Add(5*time.Minute); !next.Equal(want) {
+		t.Errorf("nextOccurrence() = %v, want %v", next, want)
+	}
+	if next, want := scheduledJob.nextOccurrence(now.Add(time.Hour)), now.Add(65*time.Minute); !next.Equal(want) {
+		t.Errorf("nextOccurrence() an hour later = %v, want %v", next, want)
+	}
+}
+
+func TestOneShotFinishesAfterRun(t *testing.T) {
+	s := &Scheduler{config: &config.Config{}, cron: cron.New(cron.WithSeconds()), jobs: newRegistry()}
+	s.cron.Start()
+	defer s.cron.Stop()
+
+	at := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	scheduledJob := newAdjustTestJob(t, s, config.JobConfig{Name: "migrate", Schedule: "@once " + at})
+	s.jobs.add(scheduledJob)
+	if len(s.jobs.dueBefore(time.Now().Add(2*time.Hour))) != 1 {
+		t.Fatal("one-shot job not due")
+	}
+
+	s.rescheduleJob(scheduledJob, nil)
+
+	if scheduledJob.Status != "finished" || !scheduledJob.NextRun.IsZero() {
+		t.Errorf("after run status = %s, next run = %v, want finished and none", scheduledJob.Status, scheduledJob.NextRun)
+	}
+	if scheduledJob.RunCount != 1 {
+		t.Errorf("RunCount = %d, want 1", scheduledJob.RunCount)
+	}
+	if len(s.cron.Entries()) != 0 {
+		t.Errorf("cron entries after run = %d, want 0", len(s.cron.Entries()))
+	}
+	if len(s.jobs.dueBefore(time.Now().Add(2*time.Hour))) != 0 {
+		t.Error("finished job still due")
+	}
+	if _, ok := s.jobs.get("migrate"); !ok {
+		t.Error("finished job no longer registered")
+	}
+}
+
+// newOneShotScheduler creates a scheduler for a one-shot job, on a job
+// manager recording executions in memory
+func newOneShotScheduler(t *testing.T, jobConfig config.JobConfig) (*Scheduler, *storage.MemoryStore) {
+	t.Helper()
+
+	store := storage.NewMemoryStore()
+	jobManager, err := jobs.New([]config.JobConfig{jobConfig}, config.SecurityConfig{}, store)
+	if err != nil {
+		t.Fatalf("jobs.New() error = %v", err)
+	}
+	t.Cleanup(jobManager.Stop)
+	s, err := New(&config.Config{Jobs: []config.JobConfig{jobConfig}}, jobManager, nil, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return s, store
+}
+
+func TestPassedOneShotNotScheduled(t *testing.T) {
+	at := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	jobConfig := config.JobConfig{Name: "migrate", Command: "true", Schedule: "@once " + at}
+	s, store := newOneShotScheduler(t, jobConfig)
+	if err := store.StoreJobExecution(&types.JobExecution{ID: "ran", JobName: "migrate", Status: types.StatusCompleted}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.scheduleJob(jobConfig); err != nil {
+		t.Fatalf("scheduleJob() error = %v", err)
+	}
+
+	scheduledJob, _ := s.jobs.get("migrate")
+	if scheduledJob.Status != "finished" {
+		t.Errorf("status = %s, want finished", scheduledJob.Status)
+	}
+	if len(s.cron.Entries()) != 0 {
+		t.Errorf("cron entries = %d, want 0", len(s.cron.Entries()))
+	}
+}
+
+// waitForRuns waits until a job has the given number of recorded runs
+func waitForRuns(t *testing.T, store *storage.MemoryStore, name string, runs int) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		executions, _ := store.GetJobExecutions(name, 0)
+		if len(executions) == runs {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s has %d runs, want %d", name, len(executions), runs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPassedOneShotWithoutRunCatchesUp(t *testing.T) {
+	at := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	jobConfig := config.JobConfig{Name: "migrate", Command: "true", Schedule: "@once " + at}
+	s, store := newOneShotScheduler(t, jobConfig)
+	if err := s.scheduleJob(jobConfig); err != nil {
+		t.Fatalf("scheduleJob() error = %v", err)
+	}
+
+	waitForRuns(t, store, "migrate", 1)
+}
+
+func TestOneShotSkippedWhilePausedRunsOnResume(t *testing.T) {
+	at := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	jobConfig := config.JobConfig{Name: "migrate", Command: "true", Schedule: "@once " + at}
+	s, store := newOneShotScheduler(t, jobConfig)
+	if err := s.scheduleJob(jobConfig); err != nil {
+		t.Fatalf("scheduleJob() error = %v", err)
+	}
+	s.isRunning.Store(true)
+	scheduledJob, _ := s.jobs.get("migrate")
+
+	if _, err := s.Pause("upgrade", "test"); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+	if err := s.executeJob(scheduledJob); err != errPaused {
+		t.Fatalf("executeJob() error = %v, want errPaused", err)
+	}
+	s.mutex.RLock()
+	status := scheduledJob.Status
+	s.mutex.RUnlock()
+	if status != "missed" {
+		t.Errorf("status after a paused run = %s, want missed", status)
+	}
+
+	if _, err := s.Resume("test"); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	waitForRuns(t, store, "migrate", 1)
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		s.mutex.RLock()
+		status = scheduledJob.Status
+		s.mutex.RUnlock()
+		if status == "finished" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status after resume = %s, want finished", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
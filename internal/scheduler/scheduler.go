@@ -2,64 +2,167 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/makalin/arcron/internal/config"
 	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/logging"
 	"github.com/makalin/arcron/internal/ml"
 	"github.com/makalin/arcron/internal/monitoring"
+	"github.com/makalin/arcron/internal/telemetry"
+	"github.com/makalin/arcron/internal/tracing"
+	"github.com/makalin/arcron/internal/types"
 	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var (
+	loopDuration = telemetry.NewHistogram("arcron_scheduler_loop_duration_seconds",
+		"Duration of intelligent scheduling loop iterations")
+	scheduleAdjustments = telemetry.NewCounter("arcron_schedule_adjustments_total",
+		"Number of schedule adjustments made by the intelligent scheduler", "job", "namespace")
+	scheduleAdvisories = telemetry.NewCounter("arcron_schedule_advisories_total",
+		"Number of schedule adjustments advised but not made in dry-run mode", "job", "namespace")
+	gateDeferrals = telemetry.NewCounter("arcron_resource_gate_deferrals_total",
+		"Number of job starts deferred by the resource gate", "job", "namespace")
+	gateTimeouts = telemetry.NewCounter("arcron_resource_gate_timeouts_total",
+		"Number of deferred jobs that reached their maximum delay", "job", "namespace")
+	maintenanceSkips = telemetry.NewCounter("arcron_maintenance_skipped_runs_total",
+		"Number of scheduled runs skipped while the scheduler was paused", "job", "namespace")
+	quietHoursSkips = telemetry.NewCounter("arcron_schedule_adjustments_skipped_total",
+		"Number of schedule adjustments not made during quiet hours", "job", "namespace")
+)
+
+// scheduleParser parses job schedules, with a leading seconds field
+var scheduleParser = cron.NewParser(
+	cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// errStopped is returned for runs that never started because the scheduler
+// stopped
+var errStopped = errors.New("scheduler stopped")
+
 // ScheduledJob represents a job with its scheduling information
 type ScheduledJob struct {
-	Job         *jobs.Job
-	EntryID     cron.EntryID
-	NextRun     time.Time
-	LastRun     time.Time
-	RunCount    int
-	Status      string
-	Prediction  *ml.Prediction
+	Job            *jobs.Job
+	EntryID        cron.EntryID
+	NextRun        time.Time
+	LastRun        time.Time
+	RunCount       int
+	Status         string
+	Prediction     *ml.Prediction
+	LastAdjustment *Adjustment
+
+	schedule     cron.Schedule      // parsed cron schedule
+	anchor       time.Time          // when the cron entry was added or last fired
+	index        int                // position in the registry's next run index
+	pending      *pendingAdjustment // next cron run moved by an adjustment
+	advised      time.Time          // cron run last advised in dry-run mode
+	quietSkipped time.Time          // cron run last kept in place by quiet hours
+	deferred     bool               // a run is held back by the resource gate
+	adjustedAt   []time.Time        // when runs were moved, for the daily limit
+}
+
+// Adjustment records a schedule change made by the intelligent scheduler
+// together with the prediction that drove it. In dry-run mode the change
+// is only advised.
+type Adjustment struct {
+	ID          uint           `json:"id,omitempty"`
+	JobName     string         `json:"job_name"`
+	Time        time.Time      `json:"time"`
+	PreviousRun time.Time      `json:"previous_run"`
+	NewRun      time.Time      `json:"new_run"`
+	Prediction  *ml.Prediction `json:"prediction"`
+	DryRun      bool           `json:"dry_run,omitempty"`
+	// Skipped is why the change was not made, e.g. quiet hours
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// JobExplanation explains the scheduling decisions for a job
+type JobExplanation struct {
+	JobName        string         `json:"job_name"`
+	Status         string         `json:"status"`
+	NextRun        time.Time      `json:"next_run"`
+	LastAdjustment *Adjustment    `json:"last_adjustment,omitempty"`
+	Prediction     *ml.Prediction `json:"prediction,omitempty"`
 }
 
 // Scheduler represents the intelligent job scheduler
 type Scheduler struct {
-	config      *config.Config
-	jobManager  *jobs.Manager
-	mlEngine    *ml.Engine
-	monitor     *monitoring.Monitor
-	cron        *cron.Cron
-	jobs        map[string]*ScheduledJob
-	mutex       sync.RWMutex
-	stopChan    chan struct{}
-	isRunning   bool
+	config           *config.Config
+	jobManager       *jobs.Manager
+	mlEngine         *ml.Engine
+	monitor          *monitoring.Monitor
+	cron             *cron.Cron
+	jobs             *registry
+	mutex            sync.RWMutex
+	stopChan         chan struct{}
+	isRunning        atomic.Bool
+	advisories       []*Adjustment // most recent dry-run adjustments
+	store            AdjustmentStore
+	maintenance      types.MaintenanceState
+	maintenanceStore MaintenanceStore
+	smartRuns        map[*time.Timer]struct{} // queued manual runs in smart mode
+	quietHours       []quietWindow            // windows in which no run is moved
+	definitions      JobDefinitionStore
+	syncMutex        sync.Mutex // serializes job syncs through the API
+	revisions        RevisionStore
+	revisionMutex    sync.Mutex // serializes the recording of job revisions
+	impacts          ImpactStore
+	queue            QueueStore
+	queueWake        chan struct{} // wakes the queue worker
+	queueMutex       sync.Mutex    // serializes changes to the queue order
+
+	// The configured jobs, replaced as a whole under mutex so they can be
+	// read without it
+	jobConfigs atomic.Pointer[[]config.JobConfig]
 }
 
 // New creates a new Scheduler instance
 func New(cfg *config.Config, jobManager *jobs.Manager, mlEngine *ml.Engine, monitor *monitoring.Monitor) (*Scheduler, error) {
-	c := cron.New(cron.WithSeconds())
+	c := cron.New(cron.WithParser(scheduleParser))
 
-	return &Scheduler{
+	quietHours, err := parseQuietHours(cfg.Advanced.QuietHours)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scheduler{
 		config:     cfg,
 		jobManager: jobManager,
 		mlEngine:   mlEngine,
 		monitor:    monitor,
 		cron:       c,
-		jobs:       make(map[string]*ScheduledJob),
+		jobs:       newRegistry(),
 		stopChan:   make(chan struct{}),
-	}, nil
+		quietHours: quietHours,
+	}
+	s.setJobs(cfg.Jobs)
+	if cfg.Advanced.Paused {
+		s.maintenance = types.MaintenanceState{Paused: true, Reason: "paused in configuration", Since: time.Now()}
+	}
+
+	telemetry.NewGaugeFunc("arcron_scheduler_paused", "Whether the scheduler is paused for maintenance (1) or not (0)", func() float64 {
+		if s.IsPaused() {
+			return 1
+		}
+		return 0
+	})
+
+	return s, nil
 }
 
 // Start starts the scheduler
 func (s *Scheduler) Start(ctx context.Context) error {
-	if s.isRunning {
+	if !s.isRunning.CompareAndSwap(false, true) {
 		return fmt.Errorf("scheduler is already running")
 	}
 
-	s.isRunning = true
 	logrus.Info("Starting intelligent scheduler...")
 
 	// Start the cron scheduler
@@ -69,35 +172,58 @@ func (s *Scheduler) Start(ctx context.Context) error {
 	if err := s.scheduleJobs(); err != nil {
 		return fmt.Errorf("failed to schedule jobs: %v", err)
 	}
+	s.recordConfiguredRevisions()
 
 	// Start the intelligent scheduling loop
 	go s.intelligentSchedulingLoop(ctx)
+	if s.queue != nil {
+		go s.queueLoop(ctx)
+	}
+
+	if s.config.JobsDir != "" {
+		if err := config.WatchJobsDir(ctx, s.config.JobsDir, s.config.JobTemplates, s.reloadJobsDir); err != nil {
+			logrus.Errorf("Jobs directory changes will not be picked up: %v", err)
+		}
+	}
 
 	return nil
 }
 
 // Stop stops the scheduler
 func (s *Scheduler) Stop() {
-	if !s.isRunning {
+	if !s.isRunning.CompareAndSwap(true, false) {
 		return
 	}
 
 	logrus.Info("Stopping scheduler...")
 	s.cron.Stop()
+
+	var cancelled []*Adjustment
+	s.mutex.Lock()
+	for _, scheduledJob := range s.jobs.byName {
+		if adjustment := s.cancelAdjustment(scheduledJob); adjustment != nil {
+			cancelled = append(cancelled, adjustment)
+		}
+	}
+	s.cancelSmartRuns()
+	s.mutex.Unlock()
+	for _, adjustment := range cancelled {
+		s.resolveAdjustment(adjustment, types.AdjustmentCancelled)
+	}
 	close(s.stopChan)
-	s.isRunning = false
 }
 
 // scheduleJobs schedules all configured jobs
 func (s *Scheduler) scheduleJobs() error {
-	for _, jobConfig := range s.config.Jobs {
+	jobConfigs := s.Jobs()
+	for _, jobConfig := range jobConfigs {
 		if err := s.scheduleJob(jobConfig); err != nil {
 			logrus.Errorf("Failed to schedule job %s: %v", jobConfig.Name, err)
 			continue
 		}
 	}
 
-	logrus.Infof("Scheduled %d jobs", len(s.config.Jobs))
+	logrus.Infof("Scheduled %d jobs", len(jobConfigs))
 	return nil
 }
 
@@ -108,25 +234,53 @@ func (s *Scheduler) scheduleJob(jobConfig config.JobConfig) error {
 		return fmt.Errorf("failed to create job: %v", err)
 	}
 
+	schedule, err := parseSchedule(jobConfig.Schedule)
+	if err != nil {
+		return fmt.Errorf("failed to add job to cron: %v", err)
+	}
+
 	// Create scheduled job entry
+	now := time.Now()
 	scheduledJob := &ScheduledJob{
 		Job:      job,
-		NextRun:  time.Now(),
+		NextRun:  schedule.Next(now),
 		Status:   "scheduled",
 		RunCount: 0,
+		schedule: schedule,
+		anchor:   now,
+	}
+	_, reboot := schedule.(rebootSchedule)
+	if reboot {
+		scheduledJob.NextRun = now
 	}
 
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	// Add to cron scheduler with initial schedule
-	entryID, err := s.cron.AddFunc(jobConfig.Schedule, func() {
-		s.executeJob(scheduledJob)
-	})
-	if err != nil {
-		return fmt.Errorf("failed to add job to cron: %v", err)
+	scheduledJob.EntryID = s.cron.Schedule(schedule, cron.FuncJob(func() {
+		s.fireScheduled(scheduledJob)
+	}))
+	s.jobs.add(scheduledJob)
+
+	switch {
+	case reboot:
+		go s.executeJob(scheduledJob)
+	case scheduledJob.NextRun.IsZero() && s.ranBefore(jobConfig.Name):
+		// A one-shot whose time passed, e.g. before a restart
+		s.finishJob(scheduledJob)
+		logrus.Warnf("Job %s was scheduled for %s, which has passed; not running it",
+			jobConfig.Name, jobConfig.Schedule)
+		return nil
+	case scheduledJob.NextRun.IsZero():
+		// A one-shot that was skipped, e.g. by a shutdown, catches up
+		scheduledJob.Status = "missed"
+		logrus.Infof("Job %s was scheduled for %s, which passed without a run; running it now",
+			jobConfig.Name, jobConfig.Schedule)
+		go s.executeJob(scheduledJob)
+		return nil
 	}
 
-	scheduledJob.EntryID = entryID
-	s.jobs[jobConfig.Name] = scheduledJob
-
 	logrus.Infof("Scheduled job: %s with schedule: %s", jobConfig.Name, jobConfig.Schedule)
 	return nil
 }
@@ -148,38 +302,97 @@ func (s *Scheduler) intelligentSchedulingLoop(ctx context.Context) {
 	}
 }
 
-// adjustSchedules adjusts job schedules based on ML predictions
+// adjustSchedules adjusts job schedules based on ML predictions. Jobs due
+// within the adjustment window are snapshotted, predictions are made
+// without holding the scheduler lock, and each decision is applied under
+// the lock on its own, so status reads and job completions never wait for
+// the ML engine or the database.
 func (s *Scheduler) adjustSchedules() {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	defer loopDuration.ObserveSince(time.Now())
+
+	// Nothing runs while paused, so there is nothing to adjust
+	if s.IsPaused() {
+		return
+	}
 
-	currentMetrics := s.monitor.GetLastMetrics()
+	// Without fresh metrics, jobs fall back to their cron schedules
+	if s.monitor.MetricsStale() {
+		logrus.Warnf("Metrics last collected %s ago, running jobs on their cron schedules",
+			s.monitor.MetricsAge().Round(time.Second))
+		s.revertAdjustments()
+		return
+	}
+
+	currentMetrics := s.monitor.GetSmoothedMetrics(s.config.Monitoring.Smoothing.PredictionWindow)
 	if currentMetrics == nil {
 		logrus.Debug("No metrics available for schedule adjustment")
 		return
 	}
 
-	for _, scheduledJob := range s.jobs {
-		// Get ML prediction for optimal execution time
-		prediction, err := s.mlEngine.PredictOptimalTime(
-			scheduledJob.Job.GetName(),
-			scheduledJob.Job.GetType(),
-			*currentMetrics,
-		)
-		if err != nil {
-			logrus.Errorf("Failed to get prediction for job %s: %v", scheduledJob.Job.GetName(), err)
-			continue
-		}
+	// Only jobs running soon can be moved, so only they are predicted for
+	s.mutex.RLock()
+	scheduledJobs := s.jobs.dueBefore(time.Now().Add(s.config.Advanced.AdjustmentWindow))
+	s.mutex.RUnlock()
+	if len(scheduledJobs) == 0 {
+		return
+	}
 
-		scheduledJob.Prediction = prediction
+	// Get ML predictions for optimal execution times in one batch
+	requests := make([]ml.PredictionRequest, len(scheduledJobs))
+	for i, scheduledJob := range scheduledJobs {
+		requests[i] = ml.PredictionRequest{
+			JobName: scheduledJob.Job.GetName(),
+			JobType: scheduledJob.Job.GetType(),
+		}
+	}
+	predictions, err := s.mlEngine.PredictOptimalTimes(requests, *currentMetrics)
+	if err != nil {
+		logrus.Errorf("Failed to get predictions for %d jobs: %v", len(requests), err)
+		return
+	}
+	// Heavy jobs are planned together rather than each on its own
+	if s.config.Advanced.LoadShaping.Enabled || s.config.Advanced.Stagger.Enabled {
+		now := time.Now()
+		impacts := s.jobImpacts(now)
+		if s.config.Advanced.LoadShaping.Enabled {
+			s.shapeLoad(scheduledJobs, predictions, s.mlEngine.LatestForecast(), impacts, now)
+		}
+		if s.config.Advanced.Stagger.Enabled {
+			s.staggerJobs(scheduledJobs, predictions, impacts, s.jobDurations(scheduledJobs, impacts), now)
+		}
+	}
 
-		// Check if we should adjust the schedule
-		if s.shouldAdjustSchedule(scheduledJob, prediction) {
-			s.adjustJobSchedule(scheduledJob, prediction)
+	for i, scheduledJob := range scheduledJobs {
+		if adjustment := s.applyPrediction(scheduledJob, predictions[i]); adjustment != nil {
+			s.recordAdjustment(adjustment)
 		}
 	}
 }
 
+// applyPrediction stores the latest prediction of a job and moves its next
+// run, or advises moving it in dry-run mode, if the prediction warrants it.
+// It returns the adjustment made, if any.
+func (s *Scheduler) applyPrediction(scheduledJob *ScheduledJob, prediction *ml.Prediction) *Adjustment {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	scheduledJob.Prediction = prediction
+
+	// Check if we should adjust the schedule
+	if !s.shouldAdjustSchedule(scheduledJob, prediction) {
+		return nil
+	}
+	// Nothing is moved during quiet hours, nor into or out of them
+	occurrence := scheduledJob.nextOccurrence(time.Now())
+	if window := s.quietHoursAt(time.Now(), occurrence, prediction.OptimalTime); window != "" {
+		return s.skipQuietHours(scheduledJob, prediction, window)
+	}
+	if scheduledJob.Job.GetConfig().IsDryRun(s.config.Advanced.DryRun) {
+		return s.adviseJobSchedule(scheduledJob, prediction)
+	}
+	return s.adjustJobSchedule(scheduledJob, prediction)
+}
+
 // shouldAdjustSchedule determines if a job schedule should be adjusted
 func (s *Scheduler) shouldAdjustSchedule(scheduledJob *ScheduledJob, prediction *ml.Prediction) bool {
 	// Don't adjust if the job is currently running
@@ -187,83 +400,255 @@ func (s *Scheduler) shouldAdjustSchedule(scheduledJob *ScheduledJob, prediction
 		return false
 	}
 
+	occurrence := scheduledJob.nextOccurrence(time.Now())
+	if occurrence.IsZero() {
+		return false
+	}
+
+	adjust, reason := s.decideAdjustment(scheduledJob, prediction, occurrence, time.Now())
+	if reason != "" {
+		logrus.Debugf("Not adjusting job %s: %s", scheduledJob.Job.GetName(), reason)
+	}
+	return adjust
+}
+
+// decideAdjustment reports whether the cron run of a job at occurrence
+// should be moved to the predicted optimal time at now, and if the
+// prediction called for a move that is not made, why not. It must be
+// called with the scheduler lock held.
+func (s *Scheduler) decideAdjustment(scheduledJob *ScheduledJob, prediction *ml.Prediction, occurrence, now time.Time) (bool, string) {
 	// Don't adjust if the prediction confidence is too low
 	if prediction.Confidence < 0.3 {
-		return false
+		return false, fmt.Sprintf("prediction confidence %.2f below 0.3", prediction.Confidence)
 	}
 
 	// Adjust if the predicted optimal time is significantly different from the next run
-	timeDiff := prediction.OptimalTime.Sub(scheduledJob.NextRun)
-	return timeDiff.Abs() > 5*time.Minute
-}
+	threshold := time.Duration(s.config.Advanced.AdjustmentThreshold) * time.Minute
+	if prediction.OptimalTime.Sub(occurrence).Abs() <= threshold {
+		return false, ""
+	}
 
-// adjustJobSchedule adjusts a job's schedule based on ML prediction
-func (s *Scheduler) adjustJobSchedule(scheduledJob *ScheduledJob, prediction *ml.Prediction) {
-	// Remove the current cron entry
-	s.cron.Remove(scheduledJob.EntryID)
+	if reason := s.adjustmentBlocked(scheduledJob, occurrence, prediction.OptimalTime, now); reason != "" {
+		return false, reason
+	}
+	return true, ""
+}
 
-	// Calculate new delay
-	delay := time.Until(prediction.OptimalTime)
-	if delay < 0 {
-		delay = 1 * time.Minute // Minimum delay
+// adjustJobSchedule moves the next run of a job to the predicted optimal
+// time. The move is one-shot: the cron entry stays in place, its next
+// firing is skipped, and later runs follow the configured schedule. It
+// must be called with the scheduler lock held.
+func (s *Scheduler) adjustJobSchedule(scheduledJob *ScheduledJob, prediction *ml.Prediction) *Adjustment {
+	now := time.Now()
+	occurrence := scheduledJob.nextOccurrence(now)
+
+	adjustment := &Adjustment{
+		JobName:     scheduledJob.Job.GetName(),
+		Time:        now,
+		PreviousRun: occurrence,
+		NewRun:      prediction.OptimalTime,
+		Prediction:  prediction,
 	}
 
-	// Create new cron entry with adjusted timing
-	entryID, err := s.cron.AddFunc(fmt.Sprintf("@every %s", delay.String()), func() {
-		s.executeJob(scheduledJob)
+	pending := &pendingAdjustment{occurrence: occurrence, runAt: prediction.OptimalTime, adjustment: adjustment}
+	pending.timer = time.AfterFunc(prediction.OptimalTime.Sub(now), func() {
+		s.runAdjusted(scheduledJob, pending)
 	})
-	if err != nil {
-		logrus.Errorf("Failed to adjust schedule for job %s: %v", scheduledJob.Job.GetName(), err)
-		return
-	}
 
 	// Update the scheduled job
-	scheduledJob.EntryID = entryID
-	scheduledJob.NextRun = prediction.OptimalTime
+	scheduledJob.LastAdjustment = adjustment
+	scheduledJob.pending = pending
+	scheduledJob.adjustedAt = append(scheduledJob.adjustedAt, now)
+	s.jobs.setNextRun(scheduledJob, prediction.OptimalTime)
 	scheduledJob.Status = "adjusted"
+	scheduleAdjustments.Inc(scheduledJob.Job.GetName(), scheduledJob.Job.GetNamespace())
 
-	logrus.Infof("Adjusted schedule for job %s: new run time %s (reason: %s)",
-		scheduledJob.Job.GetName(), prediction.OptimalTime.Format("15:04:05"), prediction.Reasoning)
+	logrus.Infof("Adjusted schedule for job %s: run at %s moved to %s (reason: %s)",
+		scheduledJob.Job.GetName(), occurrence.Format("15:04:05"),
+		prediction.OptimalTime.Format("15:04:05"), prediction.Reasoning)
+	return adjustment
 }
 
-// executeJob executes a scheduled job
-func (s *Scheduler) executeJob(scheduledJob *ScheduledJob) {
+// executeJob executes a scheduled job. It returns errStopped if the
+// scheduler stopped before the job started, errPaused if the run was
+// skipped for maintenance and errGateCritical or errGateCollapsed if the
+// resource gate skipped it.
+func (s *Scheduler) executeJob(scheduledJob *ScheduledJob) error {
+	// Time held back by the resource gate counts as queue wait, and the
+	// log lines of the run share a correlation ID from here on
+	queuedAt := time.Now()
+	correlationID := logging.NewCorrelationID()
+	log := logging.ForJob(scheduledJob.Job.GetName(), correlationID)
+	if s.skipIfPaused(scheduledJob) {
+		return errPaused
+	}
+
+	// Hold back gated jobs while the system is busy
+	switch err := s.waitForResources(scheduledJob, log); err {
+	case nil:
+	case errGateCritical:
+		s.rescheduleJob(scheduledJob, err)
+		return err
+	default:
+		// Stopped, or collapsed into the run already deferred
+		return err
+	}
+	if s.skipIfPaused(scheduledJob) {
+		return errPaused
+	}
+
 	s.mutex.Lock()
 	scheduledJob.Status = "running"
 	scheduledJob.LastRun = time.Now()
 	s.mutex.Unlock()
 
-	logrus.Infof("Executing job: %s", scheduledJob.Job.GetName())
+	log.Infof("Executing job: %s", scheduledJob.Job.GetName())
+
+	ctx := logging.WithCorrelationID(jobs.WithQueuedAt(context.Background(), queuedAt), correlationID)
+	ctx, span := tracing.Start(ctx, "scheduler.fire",
+		attribute.String("job.name", scheduledJob.Job.GetName()),
+		attribute.String("job.schedule", scheduledJob.Job.GetSchedule()),
+	)
 
 	// Execute the job
-	if err := s.jobManager.ExecuteJob(scheduledJob.Job); err != nil {
-		logrus.Errorf("Failed to execute job %s: %v", scheduledJob.Job.GetName(), err)
-		scheduledJob.Status = "failed"
-	} else {
-		scheduledJob.Status = "completed"
-		scheduledJob.RunCount++
+	err := s.jobManager.ExecuteJob(ctx, scheduledJob.Job)
+	tracing.End(span, err)
+	switch {
+	case errors.Is(err, jobs.ErrDraining):
+		log.Infof("Not running job %s: shutting down", scheduledJob.Job.GetName())
+	case err != nil:
+		log.Errorf("Failed to execute job %s: %v", scheduledJob.Job.GetName(), err)
 	}
 
 	// Reschedule the job for next run
-	s.rescheduleJob(scheduledJob)
+	s.rescheduleJob(scheduledJob, err)
+	return err
 }
 
-// rescheduleJob reschedules a job after execution
-func (s *Scheduler) rescheduleJob(scheduledJob *ScheduledJob) {
-	// Remove the current entry
-	s.cron.Remove(scheduledJob.EntryID)
+// rescheduleJob records the result of a run and returns the job to its
+// configured schedule, or to its pending adjustment. One-shot jobs are
+// finished once they ran, whatever the result; a one-shot run skipped for
+// maintenance, critical usage or a shutdown is kept missed, to run on
+// resume or at the next start.
+func (s *Scheduler) rescheduleJob(scheduledJob *ScheduledJob, runErr error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	// Add the job back with its original schedule
-	entryID, err := s.cron.AddFunc(scheduledJob.Job.GetSchedule(), func() {
-		s.executeJob(scheduledJob)
-	})
-	if err != nil {
-		logrus.Errorf("Failed to reschedule job %s: %v", scheduledJob.Job.GetName(), err)
+	if current, _ := s.jobs.get(scheduledJob.Job.GetName()); current != scheduledJob {
+		return // Removed or replaced while it ran
+	}
+	if runErr == nil {
+		scheduledJob.RunCount++
+	}
+	if isOneShot(scheduledJob.schedule) {
+		if skippedRun(runErr) {
+			scheduledJob.Status = "missed"
+			s.jobs.setNextRun(scheduledJob, time.Time{})
+			return
+		}
+		s.finishJob(scheduledJob)
 		return
 	}
+	s.jobs.setNextRun(scheduledJob, s.nextRun(scheduledJob))
+	if scheduledJob.pending != nil && !scheduledJob.pending.ran {
+		scheduledJob.Status = "adjusted"
+	} else {
+		scheduledJob.Status = "scheduled"
+	}
+}
+
+// skippedRun reports whether a run never started because the scheduler
+// was paused, the resource gate skipped it or the job manager was draining
+func skippedRun(err error) bool {
+	return errors.Is(err, errPaused) || errors.Is(err, errGateCritical) || errors.Is(err, jobs.ErrDraining)
+}
+
+// ranBefore reports whether a job has a recorded run. When that cannot be
+// told it assumes so, so a one-shot never runs twice.
+func (s *Scheduler) ranBefore(name string) bool {
+	executions, err := s.jobManager.GetJobExecutions(name, 1)
+	return err != nil || len(executions) > 0
+}
+
+// UpdateSchedule replaces the schedule of a job. The new schedule takes
+// effect from the next run and replaces any pending adjustment. Finished
+// one-shot jobs are scheduled again; a job changed to @reboot runs the
+// next time the scheduler starts. The change is recorded as a revision by
+// author.
+func (s *Scheduler) UpdateSchedule(jobName, schedule, author string) error {
+	// Deferred first so the cancellation and revision are stored after the
+	// lock is released
+	var cancelled *Adjustment
+	defer func() { s.resolveAdjustment(cancelled, types.AdjustmentCancelled) }()
+	var updated *config.JobConfig
+	defer func() {
+		if updated != nil {
+			s.recordScheduleChange(*updated, author)
+		}
+	}()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	scheduledJob, exists := s.jobs.get(jobName)
+	if !exists {
+		return fmt.Errorf("job not found: %s", jobName)
+	}
+
+	now := time.Now()
+	parsed, err := parseSchedule(schedule)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q: %v", schedule, err)
+	}
+	if _, once := parsed.(onceSchedule); once && parsed.Next(now).IsZero() {
+		return fmt.Errorf("invalid schedule %q: time has passed", schedule)
+	}
+	entryID := s.cron.Schedule(parsed, cron.FuncJob(func() {
+		s.fireScheduled(scheduledJob)
+	}))
+	s.cron.Remove(scheduledJob.EntryID)
+	cancelled = s.cancelAdjustment(scheduledJob)
 
 	scheduledJob.EntryID = entryID
+	scheduledJob.schedule = parsed
+	scheduledJob.anchor = now
 	scheduledJob.Status = "scheduled"
+	s.jobs.setNextRun(scheduledJob, parsed.Next(now))
+	scheduledJob.Job.SetSchedule(schedule)
+	if job, ok := s.jobManager.GetJob(jobName); ok {
+		job.SetSchedule(schedule)
+	}
+	// Replace the jobs rather than changing them in place, as they are
+	// read without the scheduler's lock
+	jobConfigs := append([]config.JobConfig(nil), s.Jobs()...)
+	for i := range jobConfigs {
+		if jobConfigs[i].Name == jobName {
+			jobConfigs[i].Schedule = schedule
+			job := jobConfigs[i]
+			updated = &job
+		}
+	}
+	s.setJobs(jobConfigs)
+
+	logrus.Infof("Updated schedule for job %s: %s", jobName, schedule)
+	return nil
+}
+
+// Jobs returns the configured jobs: those of the configuration file and
+// jobs directory and those synced through the API. The jobs are shared
+// and must not be changed.
+func (s *Scheduler) Jobs() []config.JobConfig {
+	if jobs := s.jobConfigs.Load(); jobs != nil {
+		return *jobs
+	}
+	// Not made by New
+	return s.config.Jobs
+}
+
+// setJobs replaces the configured jobs. The caller holds s.mutex, so that
+// changes made from the jobs read before are not lost.
+func (s *Scheduler) setJobs(jobs []config.JobConfig) {
+	s.jobConfigs.Store(&jobs)
 }
 
 // GetStatus returns the current status of the scheduler
@@ -272,27 +657,64 @@ func (s *Scheduler) GetStatus() map[string]interface{} {
 	defer s.mutex.RUnlock()
 
 	jobStatuses := make(map[string]interface{})
-	for name, job := range s.jobs {
+	for name, job := range s.jobs.byName {
 		jobStatuses[name] = map[string]interface{}{
 			"status":    job.Status,
 			"next_run":  job.NextRun,
 			"last_run":  job.LastRun,
 			"run_count": job.RunCount,
+			"dry_run":   job.Job.GetConfig().IsDryRun(s.config.Advanced.DryRun),
 		}
 	}
 
 	return map[string]interface{}{
-		"running":    s.isRunning,
-		"jobs_count": len(s.jobs),
-		"jobs":       jobStatuses,
+		"running":     s.isRunning.Load(),
+		"paused":      s.maintenance.Paused,
+		"maintenance": s.maintenance,
+		"dry_run":     s.config.Advanced.DryRun,
+		"jobs_count":  s.jobs.len(),
+		"jobs":        jobStatuses,
 	}
 }
 
-// GetJobStatus returns the status of a specific job
+// IsRunning reports whether the scheduler has been started
+func (s *Scheduler) IsRunning() bool {
+	return s.isRunning.Load()
+}
+
+// GetJobStatus returns a snapshot of the status of a specific job
 func (s *Scheduler) GetJobStatus(jobName string) (*ScheduledJob, bool) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	job, exists := s.jobs[jobName]
-	return job, exists
+	job, exists := s.jobs.get(jobName)
+	if !exists {
+		return nil, false
+	}
+	snapshot := *job
+	return &snapshot, true
+}
+
+// Explain returns the latest prediction for a job and the last schedule
+// adjustment it caused
+func (s *Scheduler) Explain(jobName string) (*JobExplanation, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	scheduledJob, exists := s.jobs.get(jobName)
+	if !exists {
+		return nil, false
+	}
+
+	explanation := &JobExplanation{
+		JobName:    jobName,
+		Status:     scheduledJob.Status,
+		NextRun:    scheduledJob.NextRun,
+		Prediction: scheduledJob.Prediction,
+	}
+	if scheduledJob.LastAdjustment != nil {
+		adjustment := *scheduledJob.LastAdjustment
+		explanation.LastAdjustment = &adjustment
+	}
+	return explanation, true
 }
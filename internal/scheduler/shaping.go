@@ -0,0 +1,190 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// impactHistory is how far back the impact of jobs is averaged for load
+// shaping
+const impactHistory = 7 * 24 * time.Hour
+
+// ImpactStore reads the measured impact of jobs, see storage.Storage
+type ImpactStore interface {
+	GetImpactSummaries(since time.Time) ([]types.ImpactSummary, error)
+}
+
+// SetImpactStore makes the scheduler plan heavy jobs with their measured
+// impact when shaping load, rather than the configured default impact. It
+// must be called before Start.
+func (s *Scheduler) SetImpactStore(store ImpactStore) {
+	s.impacts = store
+}
+
+// jobImpacts returns the average impact of the jobs scored recently, or
+// nil if it is not known
+func (s *Scheduler) jobImpacts(now time.Time) map[string]float64 {
+	if s.impacts == nil {
+		return nil
+	}
+	summaries, err := s.impacts.GetImpactSummaries(now.Add(-impactHistory))
+	if err != nil {
+		logrus.Errorf("Failed to read job impacts for load shaping: %v", err)
+		return nil
+	}
+	impacts := make(map[string]float64, len(summaries))
+	for _, summary := range summaries {
+		impacts[summary.JobName] = summary.AvgScore
+	}
+	return impacts
+}
+
+// loadPlan is the forecast load per interval with the load of the heavy
+// jobs planned so far added to it
+type loadPlan struct {
+	points   []types.ForecastPoint
+	planned  []float64
+	interval time.Duration
+}
+
+// index returns the interval a time falls within, or -1 if the forecast
+// does not cover it
+func (p *loadPlan) index(t time.Time) int {
+	i := sort.Search(len(p.points), func(i int) bool { return p.points[i].Time.After(t) }) - 1
+	if i < 0 || !t.Before(p.points[i].Time.Add(p.interval)) {
+		return -1
+	}
+	return i
+}
+
+// load returns the planned load at a time, or -1 if it is not forecast
+func (p *loadPlan) load(t time.Time) float64 {
+	if i := p.index(t); i >= 0 {
+		return p.points[i].Load + p.planned[i]
+	}
+	return -1
+}
+
+// add plans the load of a job starting at a time
+func (p *loadPlan) add(t time.Time, impact float64) {
+	if i := p.index(t); i >= 0 {
+		p.planned[i] += impact
+	}
+}
+
+// shapeLoad spaces out the heavy jobs among scheduledJobs so the forecast
+// load plus their impact stays under the target utilization. Each job, in
+// the order of its next run, is planned where it would run; when that
+// would take the load over the target, the optimal time of its prediction
+// is moved to the forecast interval closest to it that stays under the
+// target, or else to the quietest one, within the adjustment limits.
+// Jobs whose runs cannot be moved only add their load to the plan.
+// impacts are the measured impacts of jobs, see jobImpacts.
+func (s *Scheduler) shapeLoad(scheduledJobs []*ScheduledJob, predictions []*ml.Prediction, forecast *types.LoadForecast, impacts map[string]float64, now time.Time) {
+	if forecast == nil || len(forecast.Points) == 0 {
+		logrus.Debug("No load forecast available for load shaping")
+		return
+	}
+	shaping := s.config.Advanced.LoadShaping
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	plan := &loadPlan{
+		points:   forecast.Points,
+		planned:  make([]float64, len(forecast.Points)),
+		interval: s.config.ML.ForecastInterval,
+	}
+	order := make([]int, len(scheduledJobs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scheduledJobs[order[a]].NextRun.Before(scheduledJobs[order[b]].NextRun)
+	})
+
+	for _, i := range order {
+		scheduledJob, prediction := scheduledJobs[i], predictions[i]
+		impact, heavy := s.heavyJobImpact(scheduledJob.Job.GetConfig(), impacts)
+		if !heavy {
+			continue
+		}
+
+		occurrence := scheduledJob.nextOccurrence(now)
+		if scheduledJob.pending != nil || scheduledJob.Status == "running" || occurrence.IsZero() {
+			plan.add(scheduledJob.NextRun, impact)
+			continue
+		}
+		runAt := s.shapedRun(scheduledJob, prediction, occurrence, now)
+		load := plan.load(runAt)
+		if load < 0 || load+impact <= shaping.TargetUtilization {
+			plan.add(runAt, impact)
+			continue
+		}
+
+		// The cron time, or any forecast interval the run may be moved to
+		candidates := []time.Time{occurrence}
+		for _, point := range plan.points {
+			if s.shapedRun(scheduledJob, &ml.Prediction{Confidence: prediction.Confidence, OptimalTime: point.Time}, occurrence, now).Equal(point.Time) {
+				candidates = append(candidates, point.Time)
+			}
+		}
+		best := runAt
+		for _, candidate := range candidates {
+			candidateLoad := plan.load(candidate)
+			if candidateLoad < 0 {
+				continue
+			}
+			bestLoad := plan.load(best)
+			under, bestUnder := candidateLoad+impact <= shaping.TargetUtilization, bestLoad+impact <= shaping.TargetUtilization
+			switch {
+			case under && !bestUnder,
+				under && candidate.Sub(runAt).Abs() < best.Sub(runAt).Abs(),
+				!under && !bestUnder && candidateLoad < bestLoad:
+				best = candidate
+			}
+		}
+
+		plan.add(best, impact)
+		if best.Equal(runAt) {
+			continue
+		}
+		prediction.Reasoning += fmt.Sprintf("; load shaping moved it from %s to %s to keep the forecast load under %.0f%%",
+			runAt.Format("15:04"), best.Format("15:04"), shaping.TargetUtilization)
+		prediction.OptimalTime = best
+		prediction.ExpectedLoad = plan.load(best)
+		logrus.Debugf("Load shaping planned job %s at %s instead of %s", scheduledJob.Job.GetName(),
+			best.Format("15:04:05"), runAt.Format("15:04:05"))
+	}
+}
+
+// heavyJobImpact returns the load planned for a job and whether it is
+// heavy enough to be shaped: configured as resource-intensive or measured
+// at or above the impact threshold
+func (s *Scheduler) heavyJobImpact(jobConfig config.JobConfig, impacts map[string]float64) (float64, bool) {
+	impact, measured := impacts[jobConfig.Name]
+	heavy := jobConfig.Type == "resource-intensive" || (measured && impact >= s.config.ML.ImpactThreshold)
+	switch {
+	case !measured:
+		impact = s.config.Advanced.LoadShaping.DefaultImpact
+	case impact < 0:
+		impact = 0
+	}
+	return impact, heavy
+}
+
+// shapedRun returns when a job will run if its next run, at occurrence,
+// is decided on with a prediction. It must be called with the scheduler
+// lock held.
+func (s *Scheduler) shapedRun(scheduledJob *ScheduledJob, prediction *ml.Prediction, occurrence, now time.Time) time.Time {
+	if adjust, _ := s.decideAdjustment(scheduledJob, prediction, occurrence, now); adjust {
+		return prediction.OptimalTime
+	}
+	return occurrence
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/robfig/cron/v3"
+)
+
+// fixedImpacts serves the same impact summaries whatever the range
+type fixedImpacts []types.ImpactSummary
+
+func (f fixedImpacts) GetImpactSummaries(since time.Time) ([]types.ImpactSummary, error) {
+	return f, nil
+}
+
+func TestShapeLoad(t *testing.T) {
+	s := &Scheduler{config: &config.Config{}, cron: cron.New(cron.WithSeconds()), jobs: newRegistry()}
+	s.config.Advanced.AdjustmentThreshold = 5
+	s.config.Advanced.Adjustment = config.AdjustmentLimits{MaxDelta: 2 * time.Hour}
+	s.config.Advanced.LoadShaping = config.LoadShapingConfig{Enabled: true, TargetUtilization: 70, DefaultImpact: 10}
+	s.config.ML.ForecastInterval = 15 * time.Minute
+	s.config.ML.ImpactThreshold = 10
+	s.SetImpactStore(fixedImpacts{{JobName: "etl", AvgScore: 30}, {JobName: "backup", AvgScore: 30}, {JobName: "ping", AvgScore: 5}})
+
+	// Hourly, and all predicted for the same quiet interval half past
+	var scheduledJobs []*ScheduledJob
+	for _, jobConfig := range []config.JobConfig{
+		{Name: "etl", Schedule: "0 0 * * * *", Type: "resource-intensive"},
+		{Name: "backup", Schedule: "0 0 * * * *"},
+		{Name: "reindex", Schedule: "0 0 * * * *", Type: "resource-intensive"},
+		{Name: "ping", Schedule: "0 0 * * * *", Type: "light"},
+	} {
+		scheduledJobs = append(scheduledJobs, newAdjustTestJob(t, s, jobConfig))
+	}
+	now := time.Now()
+	occurrence := scheduledJobs[0].nextOccurrence(now)
+	quiet := occurrence.Add(30 * time.Minute)
+	predictions := make([]*ml.Prediction, len(scheduledJobs))
+	for i := range predictions {
+		predictions[i] = &ml.Prediction{OptimalTime: quiet, Confidence: 0.7, PredictedAt: now}
+	}
+
+	forecast := &types.LoadForecast{GeneratedAt: now}
+	for at := now.Truncate(15 * time.Minute); at.Before(now.Add(3 * time.Hour)); at = at.Add(15 * time.Minute) {
+		load := 40.0
+		if at.Equal(quiet) {
+			load = 20
+		}
+		forecast.Points = append(forecast.Points, types.ForecastPoint{Time: at, Load: load})
+	}
+
+	s.shapeLoad(scheduledJobs, predictions, forecast, s.jobImpacts(now), now)
+
+	// etl fits the quiet interval: 20 + 30
+	if !predictions[0].OptimalTime.Equal(quiet) {
+		t.Errorf("etl planned at %s, want the quiet interval %s", predictions[0].OptimalTime, quiet)
+	}
+	// backup, heavy by its measured impact, would take it to 80 and moves
+	// to the closest interval staying at 70
+	if want := occurrence.Add(15 * time.Minute); !predictions[1].OptimalTime.Equal(want) {
+		t.Errorf("backup planned at %s, want %s", predictions[1].OptimalTime, want)
+	}
+	if predictions[1].ExpectedLoad != 70 {
+		t.Errorf("backup expected load = %.1f, want 70", predictions[1].ExpectedLoad)
+	}
+	// reindex has no measured impact, so its default of 10 still fits
+	if !predictions[2].OptimalTime.Equal(quiet) {
+		t.Errorf("reindex planned at %s, want the quiet interval %s", predictions[2].OptimalTime, quiet)
+	}
+	// ping is light and left alone
+	if !predictions[3].OptimalTime.Equal(quiet) {
+		t.Errorf("ping planned at %s, want its prediction kept", predictions[3].OptimalTime)
+	}
+
+	// Without a forecast nothing is planned
+	unshaped := &ml.Prediction{OptimalTime: quiet, Confidence: 0.7}
+	s.shapeLoad(scheduledJobs[1:2], []*ml.Prediction{unshaped}, nil, nil, now)
+	if !unshaped.OptimalTime.Equal(quiet) {
+		t.Errorf("shapeLoad() without a forecast moved the prediction to %s", unshaped.OptimalTime)
+	}
+}
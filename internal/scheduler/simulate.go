@@ -0,0 +1,387 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// Bounds on the size of a simulation, so a long range of a frequent job
+// cannot tie up the server
+const (
+	maxSimulatedRuns  = 10000
+	maxSimulatedLoads = 10000
+)
+
+// ErrInvalidSimulation is returned for simulations that cannot be made as
+// requested, e.g. over a range without metrics
+var ErrInvalidSimulation = errors.New("invalid simulation")
+
+// simulationProfileRuns is how many of the latest executions of a job its
+// duration is averaged over
+const simulationProfileRuns = 50
+
+// SimulationStore reads the history a simulation replays, see
+// storage.Storage
+type SimulationStore interface {
+	GetSystemMetrics(start, end time.Time, limit int) ([]*types.SystemMetrics, error)
+	GetJobExecutions(jobName string, limit int) ([]*types.JobExecution, error)
+	GetImpactSummaries(since time.Time) ([]types.ImpactSummary, error)
+}
+
+// SimulationRequest describes a simulation: the range of stored metrics
+// to replay, the width of the intervals of the load profile, and the jobs
+// to schedule, the configured jobs if nil
+type SimulationRequest struct {
+	Start    time.Time
+	End      time.Time
+	Interval time.Duration
+	Jobs     []config.JobConfig
+}
+
+// JobProfile is what the history of a job tells about its runs: the
+// average duration of its completed runs in seconds and the average load
+// it added, in percentage points. Jobs that never ran have neither.
+type JobProfile struct {
+	Duration float64 `json:"duration"`
+	Impact   float64 `json:"impact"`
+}
+
+// SimulatedRun is a run a simulation made. Time is when the job would
+// have started, Scheduled the cron time it stands for; they differ for
+// adjusted runs and runs deferred by the resource gate. Reason tells why a
+// move the prediction called for was not made.
+type SimulatedRun struct {
+	JobName    string         `json:"job_name"`
+	Scheduled  time.Time      `json:"scheduled"`
+	Time       time.Time      `json:"time"`
+	End        time.Time      `json:"end"`
+	Adjusted   bool           `json:"adjusted,omitempty"`
+	Advised    bool           `json:"advised,omitempty"`
+	Deferred   float64        `json:"deferred,omitempty"` // seconds held back by the resource gate
+	Reason     string         `json:"reason,omitempty"`
+	Load       float64        `json:"load"` // measured when it started
+	Prediction *ml.Prediction `json:"prediction"`
+}
+
+// LoadPoint is the load over an interval of a simulation: the load
+// measured at the time and the load projected with the simulated runs
+// added on top, weighted by how much of the interval they ran for. The
+// measured load includes whatever actually ran then.
+type LoadPoint struct {
+	Time          time.Time `json:"time"`
+	MeasuredLoad  float64   `json:"measured_load"`
+	ProjectedLoad float64   `json:"projected_load"`
+	Runs          int       `json:"runs"`
+}
+
+// SimulationReport is the outcome of a simulation
+type SimulationReport struct {
+	Start    time.Time             `json:"start"`
+	End      time.Time             `json:"end"`
+	Interval string                `json:"interval"`
+	Samples  int                   `json:"samples"`
+	Profiles map[string]JobProfile `json:"profiles"`
+	Runs     []SimulatedRun        `json:"runs"`
+	Load     []LoadPoint           `json:"load"`
+	Adjusted int                   `json:"adjusted"`
+	Advised  int                   `json:"advised"`
+	Deferred int                   `json:"deferred"`
+	PeakLoad float64               `json:"peak_load"`
+	PeakTime time.Time             `json:"peak_time"`
+}
+
+// Simulate replays the metrics stored between the start and end of a
+// request through the scheduling and ML logic, reporting when the jobs
+// would have run and the load they would have made. Each cron run is
+// decided once, as the scheduling loop first sees it, adjustment window
+// ahead of it: the ML engine predicts from the latest sample at that time,
+// and the adjustment limits, quiet hours, dry-run mode and resource gate
+// apply as configured. Critical thresholds, maintenance pauses, load
+// shaping, which plans on forecasts, and @reboot jobs are not replayed. Nothing is run, recorded or changed.
+func (s *Scheduler) Simulate(store SimulationStore, request SimulationRequest) (*SimulationReport, error) {
+	if !request.Start.Before(request.End) {
+		return nil, fmt.Errorf("%w: start %s is not before end %s", ErrInvalidSimulation,
+			request.Start.Format(time.RFC3339), request.End.Format(time.RFC3339))
+	}
+	if request.Interval <= 0 {
+		return nil, fmt.Errorf("%w: interval %s is not positive", ErrInvalidSimulation, request.Interval)
+	}
+	if points := request.End.Sub(request.Start) / request.Interval; points > maxSimulatedLoads {
+		return nil, fmt.Errorf("%w: %s at %s intervals exceeds %d intervals", ErrInvalidSimulation,
+			request.End.Sub(request.Start), request.Interval, maxSimulatedLoads)
+	}
+
+	jobConfigs := request.Jobs
+	if jobConfigs == nil {
+		s.mutex.RLock()
+		jobConfigs = append([]config.JobConfig{}, s.Jobs()...)
+		s.mutex.RUnlock()
+	} else {
+		problems := config.CheckJobs(jobConfigs, s.config.Advanced)
+		for _, job := range jobConfigs {
+			if _, err := parseSchedule(job.Schedule); err != nil {
+				problems = append(problems, fmt.Errorf("job %s: invalid schedule %q: %v", job.Name, job.Schedule, err))
+			}
+		}
+		if len(problems) > 0 {
+			return nil, &config.ValidationError{Path: "the simulated jobs", Errors: problems}
+		}
+	}
+
+	samples, err := store.GetSystemMetrics(request.Start, request.End, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%w: no metrics recorded between %s and %s", ErrInvalidSimulation,
+			request.Start.Format(time.RFC3339), request.End.Format(time.RFC3339))
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Timestamp.Before(samples[j].Timestamp) })
+
+	profiles, err := jobProfiles(store, jobConfigs)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SimulationReport{
+		Start:    request.Start,
+		End:      request.End,
+		Interval: request.Interval.String(),
+		Samples:  len(samples),
+		Profiles: profiles,
+		Runs:     []SimulatedRun{},
+	}
+	for _, jobConfig := range jobConfigs {
+		runs, err := s.simulateJob(jobConfig, samples, profiles[jobConfig.Name], request.Start, request.End, maxSimulatedRuns-len(report.Runs))
+		if err != nil {
+			return nil, err
+		}
+		report.Runs = append(report.Runs, runs...)
+	}
+	sort.SliceStable(report.Runs, func(i, j int) bool { return report.Runs[i].Time.Before(report.Runs[j].Time) })
+
+	for _, run := range report.Runs {
+		switch {
+		case run.Adjusted:
+			report.Adjusted++
+		case run.Advised:
+			report.Advised++
+		}
+		if run.Deferred > 0 {
+			report.Deferred++
+		}
+	}
+	report.Load = projectLoad(samples, report.Runs, profiles, request.Start, request.End, request.Interval)
+	for _, point := range report.Load {
+		if point.ProjectedLoad > report.PeakLoad {
+			report.PeakLoad = point.ProjectedLoad
+			report.PeakTime = point.Time
+		}
+	}
+	return report, nil
+}
+
+// simulateJob makes the runs of a job between start and end, at most limit
+func (s *Scheduler) simulateJob(jobConfig config.JobConfig, samples []*types.SystemMetrics, profile JobProfile, start, end time.Time, limit int) ([]SimulatedRun, error) {
+	schedule, err := parseSchedule(jobConfig.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("job %s: invalid schedule %q: %v", jobConfig.Name, jobConfig.Schedule, err)
+	}
+	if _, reboot := schedule.(rebootSchedule); reboot {
+		return nil, nil
+	}
+	job, err := jobs.NewJob(jobConfig)
+	if err != nil {
+		return nil, fmt.Errorf("job %s: %v", jobConfig.Name, err)
+	}
+	scheduledJob := &ScheduledJob{Job: job, schedule: schedule, anchor: start}
+	request := []ml.PredictionRequest{{JobName: jobConfig.Name, JobType: jobConfig.Type}}
+
+	var runs []SimulatedRun
+	for occurrence := schedule.Next(start); !occurrence.IsZero() && occurrence.Before(end); occurrence = schedule.Next(occurrence) {
+		if len(runs) == limit {
+			return nil, fmt.Errorf("%w: more than %d runs", ErrInvalidSimulation, maxSimulatedRuns)
+		}
+
+		// The scheduling loop first sees a run adjustment window ahead of it
+		decidedAt := occurrence.Add(-s.config.Advanced.AdjustmentWindow)
+		if decidedAt.Before(start) {
+			decidedAt = start
+		}
+		if pending := scheduledJob.pending; pending != nil && !decidedAt.Before(pending.runAt) && !decidedAt.Before(pending.occurrence) {
+			scheduledJob.pending = nil
+		}
+
+		predictions, err := s.mlEngine.PredictAt(request, *sampleAt(samples, decidedAt), decidedAt)
+		if err != nil {
+			return nil, err
+		}
+		prediction := predictions[0]
+		run := SimulatedRun{JobName: jobConfig.Name, Scheduled: occurrence, Time: occurrence, Prediction: prediction}
+
+		adjust, reason := s.decideAdjustment(scheduledJob, prediction, occurrence, decidedAt)
+		if adjust {
+			window := s.quietHoursAt(decidedAt, occurrence, prediction.OptimalTime)
+			switch {
+			case window != "":
+				run.Reason = "quiet hours " + window
+			case jobConfig.IsDryRun(s.config.Advanced.DryRun):
+				run.Advised = true
+				run.Reason = "dry run"
+				scheduledJob.advised = occurrence
+			default:
+				run.Adjusted = true
+				run.Time = prediction.OptimalTime
+				scheduledJob.pending = &pendingAdjustment{occurrence: occurrence, runAt: prediction.OptimalTime}
+				scheduledJob.adjustedAt = append(scheduledJob.adjustedAt, decidedAt)
+			}
+		} else {
+			run.Reason = reason
+		}
+
+		due := run.Time
+		run.Time = s.simulateGate(jobConfig, samples, due)
+		run.Deferred = run.Time.Sub(due).Seconds()
+		run.End = run.Time.Add(time.Duration(profile.Duration * float64(time.Second)))
+		run.Load = ml.CombinedLoad(sampleAt(samples, run.Time))
+		runs = append(runs, run)
+
+		if isOneShot(schedule) {
+			break
+		}
+	}
+	return runs, nil
+}
+
+// simulateGate returns when the resource gate would have let a job start
+// that was due at the given time, checking the samples as the gate checks
+// the current metrics
+func (s *Scheduler) simulateGate(jobConfig config.JobConfig, samples []*types.SystemMetrics, at time.Time) time.Time {
+	limits, gated := s.gateLimits(jobConfig)
+	if !gated {
+		return at
+	}
+	interval := s.config.Advanced.ResourceGate.CheckInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	deadline := at.Add(limits.MaxDelay)
+	for t := at; ; {
+		if systemHot(sampleAt(samples, t), nil, limits) == "" || !t.Before(deadline) {
+			return t
+		}
+		t = minTime(t.Add(interval), deadline)
+	}
+}
+
+// sampleAt returns the latest of samples, oldest first, taken at or before
+// a time, or the first sample if all were taken after it
+func sampleAt(samples []*types.SystemMetrics, at time.Time) *types.SystemMetrics {
+	i := sort.Search(len(samples), func(i int) bool { return samples[i].Timestamp.After(at) })
+	if i == 0 {
+		return samples[0]
+	}
+	return samples[i-1]
+}
+
+// jobProfiles measures the average duration and impact of jobs from their
+// history
+func jobProfiles(store SimulationStore, jobConfigs []config.JobConfig) (map[string]JobProfile, error) {
+	summaries, err := store.GetImpactSummaries(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	impacts := make(map[string]float64, len(summaries))
+	for _, summary := range summaries {
+		impacts[summary.JobName] = summary.AvgScore
+	}
+
+	profiles := make(map[string]JobProfile, len(jobConfigs))
+	for _, jobConfig := range jobConfigs {
+		executions, err := store.GetJobExecutions(jobConfig.Name, simulationProfileRuns)
+		if err != nil {
+			return nil, err
+		}
+		var profile JobProfile
+		completed := 0
+		for _, execution := range executions {
+			if execution.Status == types.StatusCompleted {
+				profile.Duration += execution.Duration
+				completed++
+			}
+		}
+		if completed > 0 {
+			profile.Duration /= float64(completed)
+		}
+		// A job running while the system quietened down added no load
+		if impact := impacts[jobConfig.Name]; impact > 0 {
+			profile.Impact = impact
+		}
+		profiles[jobConfig.Name] = profile
+	}
+	return profiles, nil
+}
+
+// projectLoad builds the load profile of a simulation, one point per
+// interval from start to end
+func projectLoad(samples []*types.SystemMetrics, runs []SimulatedRun, profiles map[string]JobProfile, start, end time.Time, interval time.Duration) []LoadPoint {
+	var points []LoadPoint
+	for from := start; from.Before(end); from = from.Add(interval) {
+		to := from.Add(interval)
+		point := LoadPoint{Time: from}
+
+		var measured float64
+		count := 0
+		for _, sample := range samples {
+			if !sample.Timestamp.Before(from) && sample.Timestamp.Before(to) {
+				measured += ml.CombinedLoad(sample)
+				count++
+			}
+		}
+		if count > 0 {
+			point.MeasuredLoad = measured / float64(count)
+		} else {
+			point.MeasuredLoad = ml.CombinedLoad(sampleAt(samples, from))
+		}
+
+		point.ProjectedLoad = point.MeasuredLoad
+		for _, run := range runs {
+			if run.End.Equal(run.Time) {
+				// Runs of unknown duration count where they start
+				if !run.Time.Before(from) && run.Time.Before(to) {
+					point.Runs++
+				}
+				continue
+			}
+			overlap := minTime(run.End, to).Sub(maxTime(run.Time, from))
+			if overlap <= 0 {
+				continue
+			}
+			point.Runs++
+			point.ProjectedLoad += profiles[run.JobName].Impact * float64(overlap) / float64(interval)
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
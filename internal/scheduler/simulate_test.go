@@ -0,0 +1,135 @@
+package scheduler
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// simulationStore adds impact summaries to a memory store
+type simulationStore struct {
+	*storage.MemoryStore
+	impacts []types.ImpactSummary
+}
+
+func (s *simulationStore) GetImpactSummaries(since time.Time) ([]types.ImpactSummary, error) {
+	return s.impacts, nil
+}
+
+func newSimulationScheduler(t *testing.T) *Scheduler {
+	t.Helper()
+
+	engine, err := ml.New(config.MLConfig{})
+	if err != nil {
+		t.Fatalf("ml.New() error = %v", err)
+	}
+	cfg := &config.Config{}
+	cfg.Advanced.AdjustmentThreshold = 10
+	cfg.Advanced.AdjustmentWindow = 2 * time.Hour
+	cfg.Advanced.ResourceGate.CheckInterval = 10 * time.Minute
+	return &Scheduler{config: cfg, mlEngine: engine, jobs: newRegistry()}
+}
+
+func TestSimulate(t *testing.T) {
+	s := newSimulationScheduler(t)
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(6 * time.Hour)
+
+	// Busy for the first two hours, quiet after
+	store := &simulationStore{MemoryStore: storage.NewMemoryStore()}
+	for at := start; at.Before(end); at = at.Add(5 * time.Minute) {
+		sample := &types.SystemMetrics{Timestamp: at, CPUUsage: 20, MemoryUsage: 20}
+		if at.Before(start.Add(2 * time.Hour)) {
+			sample.CPUUsage = 90
+		}
+		if err := store.StoreSystemMetrics(sample); err != nil {
+			t.Fatalf("StoreSystemMetrics() error = %v", err)
+		}
+	}
+	err := store.StoreJobExecution(&types.JobExecution{ID: "report-1", JobName: "report", Status: types.StatusCompleted,
+		StartTime: start.Add(-24 * time.Hour), Duration: 1800, Attempt: 1})
+	if err != nil {
+		t.Fatalf("StoreJobExecution() error = %v", err)
+	}
+	store.impacts = []types.ImpactSummary{{JobName: "report", Executions: 1, AvgScore: 10}}
+
+	adaptive, dryRun := false, true
+	request := SimulationRequest{Start: start, End: end, Interval: time.Hour, Jobs: []config.JobConfig{
+		{Name: "report", Command: "true", Schedule: "0 0 1 * * *", Type: "resource-intensive"},
+		{Name: "cleanup", Command: "true", Schedule: "0 0 4 * * *", Type: "light", DryRun: &dryRun},
+		{Name: "gated", Command: "true", Schedule: "0 30 0 * * *", Adaptive: &adaptive,
+			Gate: config.ResourceLimits{MaxCPU: 50, MaxDelay: 2 * time.Hour}},
+	}}
+	report, err := s.Simulate(store, request)
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+
+	runs := make(map[string]SimulatedRun)
+	for _, run := range report.Runs {
+		runs[run.JobName] = run
+	}
+	if len(report.Runs) != 3 {
+		t.Fatalf("Simulate() made %d runs, want 3: %+v", len(report.Runs), report.Runs)
+	}
+
+	// Seen at the start, with high CPU: the heuristics delay it 30 minutes
+	if run := runs["report"]; !run.Adjusted || !run.Time.Equal(start.Add(30*time.Minute)) || run.End.Sub(run.Time) != 30*time.Minute {
+		t.Errorf("report run = %+v, want moved to 00:30 for its 30 minute duration", run)
+	}
+	// Seen at 02:00 and only advised
+	if run := runs["cleanup"]; !run.Advised || run.Adjusted || !run.Time.Equal(start.Add(4*time.Hour)) {
+		t.Errorf("cleanup run = %+v, want advised and kept at 04:00", run)
+	}
+	// Not adaptive, and held back by the gate until CPU dropped at 02:00
+	run := runs["gated"]
+	if run.Adjusted || run.Reason != "adaptive scheduling disabled" {
+		t.Errorf("gated run = %+v, want not adjusted as it is not adaptive", run)
+	}
+	if !run.Time.Equal(start.Add(2*time.Hour)) || run.Deferred != 90*60 {
+		t.Errorf("gated run started at %s after %.0fs, want 02:00 after 5400s", run.Time.Format("15:04"), run.Deferred)
+	}
+	if report.Adjusted != 1 || report.Advised != 1 || report.Deferred != 1 {
+		t.Errorf("report counts adjusted=%d advised=%d deferred=%d, want 1 each", report.Adjusted, report.Advised, report.Deferred)
+	}
+
+	if len(report.Load) != 6 {
+		t.Fatalf("Simulate() load has %d points, want 6", len(report.Load))
+	}
+	first := report.Load[0]
+	// Half of the first hour runs the report, adding half of its impact
+	if first.MeasuredLoad != 55 || math.Abs(first.ProjectedLoad-60) > 1e-9 || first.Runs != 1 {
+		t.Errorf("first load point = %+v, want measured 55, projected 60 with 1 run", first)
+	}
+	if report.PeakLoad != first.ProjectedLoad || !report.PeakTime.Equal(start) {
+		t.Errorf("peak %.1f at %s, want the first hour", report.PeakLoad, report.PeakTime)
+	}
+}
+
+func TestSimulateInvalid(t *testing.T) {
+	s := newSimulationScheduler(t)
+	store := &simulationStore{MemoryStore: storage.NewMemoryStore()}
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := s.Simulate(store, SimulationRequest{Start: start, End: start.Add(time.Hour), Interval: time.Minute})
+	if !errors.Is(err, ErrInvalidSimulation) {
+		t.Errorf("Simulate() without metrics error = %v, want ErrInvalidSimulation", err)
+	}
+	_, err = s.Simulate(store, SimulationRequest{Start: start, End: start.Add(time.Hour), Interval: time.Second})
+	if !errors.Is(err, ErrInvalidSimulation) {
+		t.Errorf("Simulate() with too many intervals error = %v, want ErrInvalidSimulation", err)
+	}
+
+	var invalid *config.ValidationError
+	_, err = s.Simulate(store, SimulationRequest{Start: start, End: start.Add(time.Hour), Interval: time.Minute,
+		Jobs: []config.JobConfig{{Name: "broken", Command: "true", Schedule: "every day"}}})
+	if !errors.As(err, &invalid) {
+		t.Errorf("Simulate() of an invalid job error = %v, want a validation error", err)
+	}
+}
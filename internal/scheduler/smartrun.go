@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/tracing"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SmartRun is a manual run queued for the time the ML engine predicts to
+// be best within the caller's maximum wait
+type SmartRun struct {
+	JobName      string         `json:"job_name"`
+	RequestedAt  time.Time      `json:"requested_at"`
+	PlannedStart time.Time      `json:"planned_start"`
+	MaxWait      string         `json:"max_wait"`
+	Reason       string         `json:"reason"`
+	Prediction   *ml.Prediction `json:"prediction,omitempty"`
+	// QueuedRunID is the run in the execution queue, if there is one
+	QueuedRunID uint `json:"queued_run_id,omitempty"`
+}
+
+// RunSmart queues a manual run of a job at its predicted optimal time,
+// waiting at most maxWait, which is capped at advanced.smart_run_max_wait.
+// Without metrics or a prediction the job starts right away. Smart runs
+// start while the scheduler is paused, like other manual runs. With an
+// execution queue the run waits in it, otherwise in memory.
+func (s *Scheduler) RunSmart(jobName string, maxWait time.Duration) (*SmartRun, error) {
+	job, exists := s.jobManager.GetJob(jobName)
+	if !exists {
+		return nil, fmt.Errorf("job not found: %s", jobName)
+	}
+	if limit := s.config.Advanced.SmartRunMaxWait; maxWait <= 0 || maxWait > limit {
+		maxWait = limit
+	}
+
+	now := time.Now()
+	run := &SmartRun{JobName: jobName, RequestedAt: now, MaxWait: maxWait.String()}
+
+	var prediction *ml.Prediction
+	if metrics := s.decisionMetrics(s.config.Monitoring.Smoothing.PredictionWindow); metrics != nil {
+		var err error
+		prediction, err = s.mlEngine.PredictOptimalTime(jobName, job.GetType(), *metrics)
+		if err != nil {
+			logrus.Errorf("Failed to get prediction for smart run of job %s: %v", jobName, err)
+			prediction = nil
+		}
+	}
+	run.Prediction = prediction
+	run.PlannedStart, run.Reason = planSmartRun(now, maxWait, prediction)
+
+	if s.queue != nil {
+		queued := &types.QueuedRun{JobName: jobName, Trigger: types.TriggerSmart, NotBefore: run.PlannedStart, Reason: run.Reason}
+		if err := s.enqueue(queued); err != nil {
+			return nil, err
+		}
+		run.QueuedRunID = queued.ID
+		return run, nil
+	}
+
+	var timer *time.Timer
+	s.mutex.Lock()
+	timer = time.AfterFunc(run.PlannedStart.Sub(now), func() {
+		s.mutex.Lock()
+		delete(s.smartRuns, timer)
+		s.mutex.Unlock()
+
+		ctx, span := tracing.Start(context.Background(), "scheduler.smart_run",
+			attribute.String("job.name", jobName),
+		)
+		err := s.jobManager.ExecuteJob(ctx, job)
+		tracing.End(span, err)
+		if err != nil {
+			logrus.Errorf("Failed to execute smart run of job %s: %v", jobName, err)
+		}
+	})
+	if s.smartRuns == nil {
+		s.smartRuns = make(map[*time.Timer]struct{})
+	}
+	s.smartRuns[timer] = struct{}{}
+	s.mutex.Unlock()
+
+	logrus.Infof("Queued smart run of job %s for %s (%s)", jobName, run.PlannedStart.Format("15:04:05"), run.Reason)
+	return run, nil
+}
+
+// cancelSmartRuns drops the smart runs that have not started yet. It must
+// be called with the scheduler lock held.
+func (s *Scheduler) cancelSmartRuns() {
+	for timer := range s.smartRuns {
+		timer.Stop()
+	}
+	if len(s.smartRuns) > 0 {
+		logrus.Warnf("Cancelled %d queued smart runs", len(s.smartRuns))
+	}
+	s.smartRuns = nil
+}
+
+// planSmartRun returns when a smart run requested at now starts and why:
+// at the predicted optimal time, but no later than maxWait from now
+func planSmartRun(now time.Time, maxWait time.Duration, prediction *ml.Prediction) (time.Time, string) {
+	if prediction == nil {
+		return now, "no prediction available, starting now"
+	}
+
+	latest := now.Add(maxWait)
+	switch {
+	case prediction.OptimalTime.After(latest):
+		return latest, fmt.Sprintf("%s; capped at the maximum wait of %s", prediction.Reasoning, maxWait)
+	case prediction.OptimalTime.After(now):
+		return prediction.OptimalTime, prediction.Reasoning
+	default:
+		return now, prediction.Reasoning
+	}
+}
@@ -0,0 +1,32 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/ml"
+)
+
+func TestPlanSmartRun(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		prediction *ml.Prediction
+		want       time.Time
+	}{
+		{"no prediction", nil, now},
+		{"optimal within max wait", &ml.Prediction{OptimalTime: now.Add(20 * time.Minute), Reasoning: "low load"}, now.Add(20 * time.Minute)},
+		{"optimal beyond max wait", &ml.Prediction{OptimalTime: now.Add(3 * time.Hour), Reasoning: "low load"}, now.Add(time.Hour)},
+		{"optimal already passed", &ml.Prediction{OptimalTime: now.Add(-time.Minute), Reasoning: "low load"}, now},
+	}
+
+	for _, tt := range tests {
+		start, reason := planSmartRun(now, time.Hour, tt.prediction)
+		if !start.Equal(tt.want) {
+			t.Errorf("%s: planned start = %v, want %v", tt.name, start, tt.want)
+		}
+		if reason == "" {
+			t.Errorf("%s: no reason given", tt.name)
+		}
+	}
+}
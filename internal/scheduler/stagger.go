@@ -0,0 +1,140 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+)
+
+// staggerRuns is how many recent executions of a heavy job are averaged for
+// its expected duration when staggering
+const staggerRuns = 20
+
+// plannedRun is when a heavy job is planned to run, up to the end of its
+// expected duration and the gap left after it
+type plannedRun struct {
+	name       string
+	start, end time.Time
+}
+
+// jobDurations returns the average duration of the recently completed runs
+// of the heavy jobs among scheduledJobs. Jobs that never completed are left
+// out and only take the gap when staggered.
+func (s *Scheduler) jobDurations(scheduledJobs []*ScheduledJob, impacts map[string]float64) map[string]time.Duration {
+	durations := make(map[string]time.Duration)
+	if s.jobManager == nil {
+		return durations
+	}
+	for _, scheduledJob := range scheduledJobs {
+		jobConfig := scheduledJob.Job.GetConfig()
+		if _, heavy := s.heavyJobImpact(jobConfig, impacts); !heavy {
+			continue
+		}
+		executions, err := s.jobManager.GetJobExecutions(jobConfig.Name, staggerRuns)
+		if err != nil {
+			logrus.Errorf("Failed to read executions of job %s for staggering: %v", jobConfig.Name, err)
+			continue
+		}
+		var total float64
+		completed := 0
+		for _, execution := range executions {
+			if execution.Status == types.StatusCompleted {
+				total += execution.Duration
+				completed++
+			}
+		}
+		if completed > 0 {
+			durations[jobConfig.Name] = time.Duration(total / float64(completed) * float64(time.Second))
+		}
+	}
+	return durations
+}
+
+// staggerJobs keeps the heavy jobs among scheduledJobs from running at the
+// same time. Runs that cannot be moved are placed first, then the others in
+// the order of their priority, lowest value first and unset last, then of
+// their name. A run overlapping one placed before it has the optimal time
+// of its prediction moved to the end of that run, and the collision it
+// avoided recorded in the prediction, if the adjustment limits allow it;
+// otherwise it keeps its time. impacts are the measured impacts of jobs,
+// see jobImpacts, and durations their expected durations, see jobDurations.
+func (s *Scheduler) staggerJobs(scheduledJobs []*ScheduledJob, predictions []*ml.Prediction, impacts map[string]float64, durations map[string]time.Duration, now time.Time) {
+	gap := s.config.Advanced.Stagger.Gap
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var placed []plannedRun
+	var movable []int
+	for i, scheduledJob := range scheduledJobs {
+		jobConfig := scheduledJob.Job.GetConfig()
+		if _, heavy := s.heavyJobImpact(jobConfig, impacts); !heavy {
+			continue
+		}
+		if scheduledJob.pending != nil || scheduledJob.Status == "running" || scheduledJob.nextOccurrence(now).IsZero() {
+			placed = append(placed, plannedRun{
+				name:  jobConfig.Name,
+				start: scheduledJob.NextRun,
+				end:   scheduledJob.NextRun.Add(durations[jobConfig.Name] + gap),
+			})
+			continue
+		}
+		movable = append(movable, i)
+	}
+	sort.SliceStable(movable, func(a, b int) bool {
+		first, second := scheduledJobs[movable[a]].Job.GetConfig(), scheduledJobs[movable[b]].Job.GetConfig()
+		if rankA, rankB := priorityRank(first.Priority), priorityRank(second.Priority); rankA != rankB {
+			return rankA < rankB
+		}
+		return first.Name < second.Name
+	})
+
+	for _, i := range movable {
+		scheduledJob, prediction := scheduledJobs[i], predictions[i]
+		name := scheduledJob.Job.GetName()
+		span := durations[name] + gap
+		occurrence := scheduledJob.nextOccurrence(now)
+		runAt := s.shapedRun(scheduledJob, prediction, occurrence, now)
+
+		start, with := runAt, ""
+		for moved := true; moved; {
+			moved = false
+			for _, run := range placed {
+				if start.Before(run.end) && run.start.Before(start.Add(span)) {
+					start, with, moved = run.end, run.name, true
+				}
+			}
+		}
+		if with != "" {
+			staggered := &ml.Prediction{Confidence: prediction.Confidence, OptimalTime: start}
+			if s.shapedRun(scheduledJob, staggered, occurrence, now).Equal(start) {
+				prediction.Reasoning += fmt.Sprintf("; staggered after %s to avoid overlapping it", with)
+				prediction.OptimalTime = start
+				if prediction.Explanation == nil {
+					prediction.Explanation = &types.Explanation{}
+				}
+				prediction.Explanation.Collision = &types.CollisionAvoidance{With: with, From: runAt, To: start}
+				logrus.Debugf("Staggered job %s after %s, at %s instead of %s", name, with,
+					start.Format("15:04:05"), runAt.Format("15:04:05"))
+			} else {
+				logrus.Debugf("Job %s overlaps %s but cannot be moved to %s", name, with, start.Format("15:04:05"))
+				start = runAt
+			}
+		}
+		placed = append(placed, plannedRun{name: name, start: start, end: start.Add(span)})
+	}
+}
+
+// priorityRank orders job priorities, the lowest value first and unset
+// priorities last
+func priorityRank(priority int) int {
+	if priority <= 0 {
+		return math.MaxInt
+	}
+	return priority
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/jobs"
+	"github.com/makalin/arcron/internal/ml"
+	"github.com/makalin/arcron/internal/storage"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/robfig/cron/v3"
+)
+
+func TestStaggerJobs(t *testing.T) {
+	s := &Scheduler{config: &config.Config{}, cron: cron.New(cron.WithSeconds()), jobs: newRegistry()}
+	s.config.Advanced.AdjustmentThreshold = 5
+	s.config.Advanced.Adjustment = config.AdjustmentLimits{MaxDelta: 2 * time.Hour}
+	s.config.Advanced.Stagger = config.StaggerConfig{Enabled: true, Gap: time.Minute}
+	s.config.ML.ImpactThreshold = 10
+
+	store := storage.NewMemoryStore()
+	for name, duration := range map[string]float64{"etl": 1200, "backup": 600} {
+		err := store.StoreJobExecution(&types.JobExecution{ID: name + "-1", JobName: name, Status: types.StatusCompleted,
+			StartTime: time.Now().Add(-24 * time.Hour), Duration: duration, Attempt: 1})
+		if err != nil {
+			t.Fatalf("StoreJobExecution() error = %v", err)
+		}
+	}
+	jobManager, err := jobs.New(nil, config.SecurityConfig{}, store)
+	if err != nil {
+		t.Fatalf("jobs.New() error = %v", err)
+	}
+	s.jobManager = jobManager
+
+	// Hourly, and all predicted for the same time half past
+	var scheduledJobs []*ScheduledJob
+	for _, jobConfig := range []config.JobConfig{
+		{Name: "etl", Schedule: "0 0 * * * *", Type: "resource-intensive", Priority: 2},
+		{Name: "backup", Schedule: "0 0 * * * *", Type: "resource-intensive", Priority: 1},
+		{Name: "reindex", Schedule: "0 0 * * * *", Type: "resource-intensive"},
+		{Name: "ping", Schedule: "0 0 * * * *", Type: "light"},
+	} {
+		scheduledJobs = append(scheduledJobs, newAdjustTestJob(t, s, jobConfig))
+	}
+	now := time.Now()
+	occurrence := scheduledJobs[0].nextOccurrence(now)
+	predicted := occurrence.Add(30 * time.Minute)
+	predictions := make([]*ml.Prediction, len(scheduledJobs))
+	for i := range predictions {
+		predictions[i] = &ml.Prediction{OptimalTime: predicted, Confidence: 0.7, PredictedAt: now}
+	}
+
+	durations := s.jobDurations(scheduledJobs, nil)
+	if durations["etl"] != 20*time.Minute || durations["backup"] != 10*time.Minute {
+		t.Fatalf("jobDurations() = %v, want etl 20m and backup 10m", durations)
+	}
+	s.staggerJobs(scheduledJobs, predictions, nil, durations, now)
+
+	// backup has the highest priority and keeps its time
+	if !predictions[1].OptimalTime.Equal(predicted) || predictions[1].Explanation != nil {
+		t.Errorf("backup planned at %s with %+v, want its prediction kept", predictions[1].OptimalTime, predictions[1].Explanation)
+	}
+	// etl runs after backup and its gap
+	want := predicted.Add(11 * time.Minute)
+	if !predictions[0].OptimalTime.Equal(want) {
+		t.Errorf("etl planned at %s, want %s", predictions[0].OptimalTime, want)
+	}
+	collision := predictions[0].Explanation
+	if collision == nil || collision.Collision == nil {
+		t.Fatal("etl prediction records no collision")
+	}
+	if c := collision.Collision; c.With != "backup" || !c.From.Equal(predicted) || !c.To.Equal(want) {
+		t.Errorf("etl collision = %+v, want moved after backup from %s to %s", c, predicted, want)
+	}
+	// reindex, without a priority, would have to run after etl, past its
+	// following run, so it keeps its time
+	if !predictions[2].OptimalTime.Equal(predicted) || predictions[2].Explanation != nil {
+		t.Errorf("reindex planned at %s, want its prediction kept", predictions[2].OptimalTime)
+	}
+	// ping is light and left alone
+	if !predictions[3].OptimalTime.Equal(predicted) {
+		t.Errorf("ping planned at %s, want its prediction kept", predictions[3].OptimalTime)
+	}
+}
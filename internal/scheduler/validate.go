@@ -0,0 +1,295 @@
+package scheduler
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+// scheduleFields names the fields of a schedule expression in order
+var scheduleFields = []string{"second", "minute", "hour", "day-of-month", "month", "day-of-week"}
+
+// descriptorExpressions expands the predefined schedules into fields
+var descriptorExpressions = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+var (
+	monthNames = []string{"", "January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December"}
+	dayNames = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+
+	fieldPattern = regexp.MustCompile(`\S+`)
+)
+
+// ScheduleError is a problem with a schedule expression. Position is the
+// byte offset of the offending part of the expression.
+type ScheduleError struct {
+	Field    string `json:"field,omitempty"`
+	Position int    `json:"position"`
+	Message  string `json:"message"`
+}
+
+// ScheduleValidation is the result of validating a schedule expression
+type ScheduleValidation struct {
+	Expression  string          `json:"expression"`
+	Valid       bool            `json:"valid"`
+	Errors      []ScheduleError `json:"errors,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Timezone    string          `json:"timezone,omitempty"`
+	NextRuns    []time.Time     `json:"next_runs,omitempty"`
+}
+
+// ValidateSchedule parses a schedule expression as the scheduler would and
+// describes it. Invalid expressions get an error per offending field;
+// valid ones a human-readable description and their next count runs.
+func ValidateSchedule(expression string, now time.Time, count int) *ScheduleValidation {
+	validation := &ScheduleValidation{Expression: expression}
+
+	// An optional time zone prefix, e.g. "CRON_TZ=Europe/Berlin 0 0 2 * * *"
+	spec, offset := expression, 0
+	for strings.HasPrefix(spec, " ") {
+		spec, offset = spec[1:], offset+1
+	}
+	if strings.HasPrefix(spec, "TZ=") || strings.HasPrefix(spec, "CRON_TZ=") {
+		end := strings.Index(spec, " ")
+		if end < 0 {
+			end = len(spec)
+		}
+		name := spec[strings.Index(spec, "=")+1 : end]
+		if _, err := time.LoadLocation(name); err != nil {
+			validation.Errors = append(validation.Errors, ScheduleError{
+				Field:    "timezone",
+				Position: offset + strings.Index(spec, "=") + 1,
+				Message:  fmt.Sprintf("unknown time zone %q", name),
+			})
+			return validation
+		}
+		validation.Timezone = name
+		spec, offset = spec[end:], offset+end
+	}
+
+	schedule, err := parseSchedule(expression)
+	if err != nil {
+		validation.Errors = locateScheduleErrors(spec, offset, err)
+		return validation
+	}
+
+	validation.Valid = true
+	validation.Description = describeSchedule(strings.TrimSpace(spec))
+	if validation.Timezone != "" {
+		validation.Description += " (" + validation.Timezone + ")"
+	}
+	for at := now; len(validation.NextRuns) < count; {
+		at = schedule.Next(at)
+		if at.IsZero() {
+			break
+		}
+		validation.NextRuns = append(validation.NextRuns, at)
+	}
+	return validation
+}
+
+// locateScheduleErrors finds the fields of an expression the parser
+// rejected, by parsing each field on its own
+func locateScheduleErrors(spec string, offset int, parseErr error) []ScheduleError {
+	trimmed := strings.TrimSpace(spec)
+	if trimmed == "" {
+		return []ScheduleError{{Position: offset, Message: "empty schedule"}}
+	}
+	if strings.HasPrefix(trimmed, "@") {
+		return []ScheduleError{{
+			Position: offset + strings.Index(spec, "@"),
+			Message:  parseErr.Error(),
+		}}
+	}
+
+	locations := fieldPattern.FindAllStringIndex(spec, -1)
+	if len(locations) != len(scheduleFields) {
+		message := fmt.Sprintf("expected %d fields (%s), found %d",
+			len(scheduleFields), strings.Join(scheduleFields, " "), len(locations))
+		if len(locations) == len(scheduleFields)-1 {
+			message += "; prepend a seconds field, e.g. \"0 " + trimmed + "\""
+		}
+		return []ScheduleError{{Position: offset + locations[0][0], Message: message}}
+	}
+
+	var errors []ScheduleError
+	for i, location := range locations {
+		fields := []string{"*", "*", "*", "*", "*", "*"}
+		fields[i] = spec[location[0]:location[1]]
+		if _, err := scheduleParser.Parse(strings.Join(fields, " ")); err != nil {
+			errors = append(errors, ScheduleError{
+				Field:    scheduleFields[i],
+				Position: offset + location[0],
+				Message:  err.Error(),
+			})
+		}
+	}
+	if len(errors) == 0 {
+		errors = append(errors, ScheduleError{Position: offset + locations[0][0], Message: parseErr.Error()})
+	}
+	return errors
+}
+
+// describeSchedule returns a human-readable description of a valid
+// expression without time zone prefix, e.g. "at 02:00 every day"
+func describeSchedule(spec string) string {
+	if spec == rebootDescriptor {
+		return "once when the scheduler starts"
+	}
+	if strings.HasPrefix(spec, onceDescriptor+" ") {
+		return "once at " + strings.TrimSpace(strings.TrimPrefix(spec, onceDescriptor))
+	}
+	if strings.HasPrefix(spec, "@every ") {
+		return "every " + strings.TrimSpace(strings.TrimPrefix(spec, "@every "))
+	}
+	if expanded, ok := descriptorExpressions[spec]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	second, minute, hour := fields[0], fields[1], fields[2]
+	dom, month, dow := fields[3], fields[4], fields[5]
+
+	var parts []string
+	if isNumber(second) && isNumber(minute) && isNumber(hour) {
+		h, _ := strconv.Atoi(hour)
+		m, _ := strconv.Atoi(minute)
+		at := fmt.Sprintf("at %02d:%02d", h, m)
+		if s, _ := strconv.Atoi(second); s != 0 {
+			at += fmt.Sprintf(":%02d", s)
+		}
+		parts = append(parts, at)
+	} else {
+		parts = append(parts, describeTime(second, minute, hour)...)
+	}
+
+	switch {
+	case isAny(dom) && isAny(dow) && isAny(month):
+		if isNumber(hour) {
+			parts = append(parts, "every day")
+		}
+	default:
+		if !isAny(dow) {
+			parts = append(parts, "on "+describeValues(dow, dayNames))
+		}
+		if !isAny(dom) {
+			if isNumber(dom) {
+				parts = append(parts, "on day "+dom+" of the month")
+			} else {
+				parts = append(parts, "on days "+dom+" of the month")
+			}
+		}
+		if !isAny(month) {
+			parts = append(parts, "in "+describeValues(month, monthNames))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// describeTime describes the second, minute and hour fields when they do
+// not name a single time of day
+func describeTime(second, minute, hour string) []string {
+	var parts []string
+
+	switch {
+	case isAny(second):
+		parts = append(parts, "every second")
+	case isStep(second):
+		parts = append(parts, "every "+stepOf(second)+" seconds")
+	case second != "0":
+		parts = append(parts, "at second "+second)
+	}
+
+	switch {
+	case isAny(minute):
+		if len(parts) == 0 {
+			parts = append(parts, "every minute")
+		}
+	case isStep(minute):
+		parts = append(parts, "every "+stepOf(minute)+" minutes")
+	case isNumber(minute):
+		parts = append(parts, "at minute "+minute)
+	default:
+		parts = append(parts, "at minutes "+minute)
+	}
+
+	switch {
+	case isAny(hour):
+		if isNumber(minute) {
+			parts = append(parts, "of every hour")
+		}
+	case isStep(hour):
+		parts = append(parts, "every "+stepOf(hour)+" hours")
+	case isNumber(hour):
+		parts = append(parts, "during hour "+hour)
+	default:
+		parts = append(parts, "during hours "+hour)
+	}
+
+	return parts
+}
+
+// describeValues spells out a list or range of months or weekdays
+func describeValues(field string, names []string) string {
+	name := func(value string) string {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 && n < len(names) {
+			return names[n]
+		}
+		return value
+	}
+
+	items := strings.Split(field, ",")
+	for i, item := range items {
+		if bounds := strings.SplitN(item, "-", 2); len(bounds) == 2 {
+			items[i] = name(bounds[0]) + " through " + name(bounds[1])
+		} else {
+			items[i] = name(item)
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+func isAny(field string) bool {
+	return field == "*" || field == "?"
+}
+
+func isNumber(field string) bool {
+	_, err := strconv.Atoi(field)
+	return err == nil
+}
+
+func isStep(field string) bool {
+	return strings.HasPrefix(field, "*/")
+}
+
+func stepOf(field string) string {
+	return strings.TrimPrefix(field, "*/")
+}
+
+// CheckSchedules reports the jobs of a configuration whose schedule the
+// scheduler would reject, and invalid quiet hours, for config.Validate
+func CheckSchedules(cfg *config.Config) []error {
+	var problems []error
+	for _, job := range cfg.Jobs {
+		if _, err := parseSchedule(job.Schedule); err != nil {
+			problems = append(problems, fmt.Errorf("job %s: invalid schedule %q: %v", job.Name, job.Schedule, err))
+		}
+	}
+	if _, err := parseQuietHours(cfg.Advanced.QuietHours); err != nil {
+		problems = append(problems, err)
+	}
+	return problems
+}
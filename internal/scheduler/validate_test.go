@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+func TestValidateScheduleDescribes(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expression  string
+		description string
+	}{
+		{"0 0 2 * * *", "at 02:00 every day"},
+		{"30 15 8 * * 1-5", "at 08:15:30 on Monday through Friday"},
+		{"0 */5 * * * *", "every 5 minutes"},
+		{"0 0 * * * *", "at minute 0 of every hour"},
+		{"0 0 3 1 * *", "at 03:00 on day 1 of the month"},
+		{"0 0 0 1 1 *", "at 00:00 on day 1 of the month in January"},
+		{"@daily", "at 00:00 every day"},
+		{"@every 90m", "every 90m"},
+		{"0 30 9-17 * * *", "at minute 30 during hours 9-17"},
+	}
+
+	for _, tt := range tests {
+		validation := ValidateSchedule(tt.expression, now, 5)
+		if !validation.Valid {
+			t.Errorf("ValidateSchedule(%q) invalid: %+v", tt.expression, validation.Errors)
+			continue
+		}
+		if validation.Description != tt.description {
+			t.Errorf("ValidateSchedule(%q) description = %q, want %q", tt.expression, validation.Description, tt.description)
+		}
+		if len(validation.NextRuns) != 5 || !validation.NextRuns[0].After(now) {
+			t.Errorf("ValidateSchedule(%q) next runs = %v, want 5 after now", tt.expression, validation.NextRuns)
+		}
+	}
+}
+
+func TestValidateScheduleOneShots(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	once := ValidateSchedule("@once 2024-03-02T02:00:00Z", now, 5)
+	if !once.Valid || once.Description != "once at 2024-03-02T02:00:00Z" || len(once.NextRuns) != 1 {
+		t.Errorf("ValidateSchedule(@once) = %+v, want a single run", once)
+	}
+
+	reboot := ValidateSchedule("@reboot", now, 5)
+	if !reboot.Valid || reboot.Description != "once when the scheduler starts" || len(reboot.NextRuns) != 0 {
+		t.Errorf("ValidateSchedule(@reboot) = %+v, want no timed runs", reboot)
+	}
+
+	if invalid := ValidateSchedule("@once next week", now, 5); invalid.Valid || len(invalid.Errors) != 1 {
+		t.Errorf("ValidateSchedule(@once next week) = %+v, want one error", invalid)
+	}
+}
+
+func TestValidateScheduleTimezone(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	validation := ValidateSchedule("CRON_TZ=America/New_York 0 0 2 * * *", now, 1)
+	if !validation.Valid || validation.Timezone != "America/New_York" {
+		t.Fatalf("ValidateSchedule() = %+v, want valid in America/New_York", validation)
+	}
+	if hour := validation.NextRuns[0].UTC().Hour(); hour != 7 {
+		t.Errorf("next run at %02d:00 UTC, want 07:00", hour)
+	}
+
+	validation = ValidateSchedule("CRON_TZ=Mars/Olympus 0 0 2 * * *", now, 1)
+	if validation.Valid || len(validation.Errors) != 1 || validation.Errors[0].Position != 8 {
+		t.Errorf("unknown time zone errors = %+v, want one at position 8", validation.Errors)
+	}
+}
+
+func TestValidateScheduleLocatesErrors(t *testing.T) {
+	now := time.Now()
+
+	validation := ValidateSchedule("0 61 2 * * *", now, 5)
+	if validation.Valid || len(validation.Errors) != 1 {
+		t.Fatalf("ValidateSchedule() errors = %+v, want one", validation.Errors)
+	}
+	if err := validation.Errors[0]; err.Field != "minute" || err.Position != 2 {
+		t.Errorf("error = %+v, want minute at position 2", err)
+	}
+
+	validation = ValidateSchedule("0 0 25 * * 9", now, 5)
+	if len(validation.Errors) != 2 || validation.Errors[0].Field != "hour" || validation.Errors[1].Field != "day-of-week" ||
+		validation.Errors[1].Position != 11 {
+		t.Errorf("errors = %+v, want hour and day-of-week", validation.Errors)
+	}
+
+	validation = ValidateSchedule("0 2 * * *", now, 5)
+	if validation.Valid || len(validation.Errors) != 1 || validation.Errors[0].Field != "" {
+		t.Errorf("five-field errors = %+v, want a field count error", validation.Errors)
+	}
+	if validation.NextRuns != nil || validation.Description != "" {
+		t.Error("invalid schedule described")
+	}
+}
+
+func TestCheckSchedules(t *testing.T) {
+	cfg := &config.Config{Jobs: []config.JobConfig{
+		{Name: "good", Schedule: "0 0 2 * * *"},
+		{Name: "bad", Schedule: "0 61 2 * * *"},
+	}}
+
+	problems := CheckSchedules(cfg)
+	if len(problems) != 1 || !strings.Contains(problems[0].Error(), "job bad") {
+		t.Errorf("CheckSchedules() = %v, want one problem for job bad", problems)
+	}
+}
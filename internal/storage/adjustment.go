@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// AdjustmentRecord represents a schedule adjustment in the database
+type AdjustmentRecord struct {
+	ID             uint      `gorm:"primaryKey"`
+	JobName        string    `gorm:"index;not null"`
+	AdjustedAt     time.Time `gorm:"index;not null"`
+	OriginalTime   time.Time `gorm:"not null"`
+	NewTime        time.Time `gorm:"not null"`
+	PredictionID   uint
+	Method         string
+	Confidence     float64
+	ExpectedLoad   float64
+	Reasoning      string `gorm:"type:text"`
+	DryRun         bool   `gorm:"index"`
+	Outcome        string `gorm:"index;not null"`
+	SkipReason     string
+	StaggeredAfter string
+	ResolvedAt     *time.Time
+	CreatedAt      time.Time
+}
+
+// AdjustmentFilter narrows down schedule adjustment queries
+type AdjustmentFilter struct {
+	JobName string
+	Outcome string
+	DryRun  *bool
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+// StoreScheduleAdjustment stores a schedule adjustment and sets its ID
+func (s *Storage) StoreScheduleAdjustment(adjustment *types.ScheduleAdjustment) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_schedule_adjustment")
+
+	record := &AdjustmentRecord{
+		JobName:        adjustment.JobName,
+		AdjustedAt:     adjustment.AdjustedAt,
+		OriginalTime:   adjustment.OriginalTime,
+		NewTime:        adjustment.NewTime,
+		PredictionID:   adjustment.PredictionID,
+		Method:         adjustment.Method,
+		Confidence:     adjustment.Confidence,
+		ExpectedLoad:   adjustment.ExpectedLoad,
+		Reasoning:      adjustment.Reasoning,
+		DryRun:         adjustment.DryRun,
+		Outcome:        adjustment.Outcome,
+		SkipReason:     adjustment.SkipReason,
+		StaggeredAfter: adjustment.StaggeredAfter,
+		ResolvedAt:     adjustment.ResolvedAt,
+	}
+
+	if err := s.db.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to store schedule adjustment: %v", err)
+	}
+
+	adjustment.ID = record.ID
+	return nil
+}
+
+// ResolveScheduleAdjustment records the outcome of an adjusted run
+func (s *Storage) ResolveScheduleAdjustment(id uint, outcome string, resolvedAt time.Time) error {
+	defer queryDuration.ObserveSince(time.Now(), "resolve_schedule_adjustment")
+
+	result := s.db.Model(&AdjustmentRecord{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"outcome":     outcome,
+		"resolved_at": &resolvedAt,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to resolve schedule adjustment: %v", result.Error)
+	}
+
+	return nil
+}
+
+// GetScheduleAdjustments retrieves schedule adjustments matching the given
+// filter, newest first
+func (s *Storage) GetScheduleAdjustments(filter AdjustmentFilter) ([]*types.ScheduleAdjustment, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_schedule_adjustments")
+
+	var records []AdjustmentRecord
+
+	query := s.db.Order("adjusted_at DESC")
+	if filter.JobName != "" {
+		query = query.Where("job_name = ?", filter.JobName)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.DryRun != nil {
+		query = query.Where("dry_run = ?", *filter.DryRun)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("adjusted_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("adjusted_at <= ?", filter.Until)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve schedule adjustments: %v", err)
+	}
+
+	adjustments := make([]*types.ScheduleAdjustment, len(records))
+	for i, record := range records {
+		adjustments[i] = &types.ScheduleAdjustment{
+			ID:             record.ID,
+			JobName:        record.JobName,
+			AdjustedAt:     record.AdjustedAt,
+			OriginalTime:   record.OriginalTime,
+			NewTime:        record.NewTime,
+			PredictionID:   record.PredictionID,
+			Method:         record.Method,
+			Confidence:     record.Confidence,
+			ExpectedLoad:   record.ExpectedLoad,
+			Reasoning:      record.Reasoning,
+			DryRun:         record.DryRun,
+			Outcome:        record.Outcome,
+			SkipReason:     record.SkipReason,
+			StaggeredAfter: record.StaggeredAfter,
+			ResolvedAt:     record.ResolvedAt,
+		}
+	}
+
+	return adjustments, nil
+}
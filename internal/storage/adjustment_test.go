@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestScheduleAdjustmentHistory(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now()
+
+	moved := &types.ScheduleAdjustment{
+		JobName:      "backup",
+		AdjustedAt:   now.Add(-time.Hour),
+		OriginalTime: now,
+		NewTime:      now.Add(30 * time.Minute),
+		Confidence:   0.8,
+		Outcome:      types.AdjustmentPending,
+	}
+	advised := &types.ScheduleAdjustment{
+		JobName:      "cleanup",
+		AdjustedAt:   now,
+		OriginalTime: now,
+		NewTime:      now.Add(time.Hour),
+		DryRun:       true,
+		Outcome:      types.AdjustmentAdvised,
+	}
+	for _, adjustment := range []*types.ScheduleAdjustment{moved, advised} {
+		if err := store.StoreScheduleAdjustment(adjustment); err != nil {
+			t.Fatalf("StoreScheduleAdjustment() error = %v", err)
+		}
+	}
+	if moved.ID == 0 {
+		t.Fatal("StoreScheduleAdjustment() did not set the ID")
+	}
+
+	if err := store.ResolveScheduleAdjustment(moved.ID, types.AdjustmentCompleted, now); err != nil {
+		t.Fatalf("ResolveScheduleAdjustment() error = %v", err)
+	}
+
+	all, err := store.GetScheduleAdjustments(AdjustmentFilter{})
+	if err != nil {
+		t.Fatalf("GetScheduleAdjustments() error = %v", err)
+	}
+	if len(all) != 2 || all[0].JobName != "cleanup" {
+		t.Fatalf("GetScheduleAdjustments() = %d adjustments, want 2 newest first", len(all))
+	}
+
+	backup, err := store.GetScheduleAdjustments(AdjustmentFilter{JobName: "backup"})
+	if err != nil {
+		t.Fatalf("GetScheduleAdjustments() error = %v", err)
+	}
+	if len(backup) != 1 || backup[0].Outcome != types.AdjustmentCompleted || backup[0].ResolvedAt == nil {
+		t.Errorf("backup adjustments = %+v, want one resolved as completed", backup)
+	}
+
+	dryRun := true
+	advisedOnly, err := store.GetScheduleAdjustments(AdjustmentFilter{DryRun: &dryRun})
+	if err != nil {
+		t.Fatalf("GetScheduleAdjustments() error = %v", err)
+	}
+	if len(advisedOnly) != 1 || advisedOnly[0].JobName != "cleanup" {
+		t.Errorf("dry-run adjustments = %+v, want the advised one only", advisedOnly)
+	}
+}
@@ -0,0 +1,483 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// AlertRecord represents an alert in the alert history. Labels and
+// channels are stored as JSON.
+type AlertRecord struct {
+	ID             uint      `gorm:"primaryKey"`
+	Timestamp      time.Time `gorm:"index;not null"`
+	Level          string    `gorm:"index;not null"`
+	Title          string    `gorm:"not null"`
+	Message        string    `gorm:"type:text"`
+	JobName        string    `gorm:"index"`
+	Namespace      string    `gorm:"index"`
+	ExecutionID    string    `gorm:"index"`
+	Labels         string    `gorm:"type:text"`
+	Status         string    `gorm:"index;not null"`
+	Channels       string    `gorm:"type:text"`
+	SilenceID      uint
+	Error          string `gorm:"type:text"`
+	Event          string `gorm:"index"`
+	ResolvesID     uint
+	ResolvedAt     *time.Time
+	AcknowledgedAt *time.Time
+	AcknowledgedBy string
+	CreatedAt      time.Time
+}
+
+// SilenceRecord represents a silence in the database. Labels are stored as
+// JSON.
+type SilenceRecord struct {
+	ID        uint `gorm:"primaryKey"`
+	JobName   string
+	Level     string
+	Labels    string `gorm:"type:text"`
+	Comment   string `gorm:"type:text"`
+	CreatedBy string
+	StartsAt  time.Time `gorm:"not null"`
+	EndsAt    time.Time `gorm:"index;not null"`
+	CreatedAt time.Time
+}
+
+// DeadLetterRecord represents an alert delivery given up after its
+// retries. The alert is stored as JSON.
+type DeadLetterRecord struct {
+	ID           uint   `gorm:"primaryKey"`
+	AlertID      uint   `gorm:"index"`
+	Channel      string `gorm:"not null"`
+	SlackChannel string
+	Alert        string `gorm:"type:text;not null"`
+	Attempts     int
+	LastError    string     `gorm:"type:text"`
+	CreatedAt    time.Time  `gorm:"index"`
+	RedrivenAt   *time.Time `gorm:"index"`
+}
+
+// AlertFilter narrows down alert history queries
+type AlertFilter struct {
+	JobName   string
+	Namespace string
+	Level     string
+	Status    string
+	Event     string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// StoreAlert stores an alert in the alert history
+func (s *Storage) StoreAlert(entry *types.AlertEntry) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_alert")
+
+	labels, err := marshalOptional(entry.Labels)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert labels: %v", err)
+	}
+	channels, err := marshalOptional(entry.Channels)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert channels: %v", err)
+	}
+
+	record := &AlertRecord{
+		Timestamp:   entry.Timestamp,
+		Level:       entry.Level,
+		Title:       entry.Title,
+		Message:     entry.Message,
+		JobName:     entry.JobName,
+		Namespace:   entry.Namespace,
+		ExecutionID: entry.ExecutionID,
+		Labels:      labels,
+		Status:      entry.Status,
+		Channels:    channels,
+		SilenceID:   entry.SilenceID,
+		Error:       entry.Error,
+		Event:       entry.Event,
+		ResolvesID:  entry.ResolvesID,
+		ResolvedAt:  entry.ResolvedAt,
+	}
+
+	if err := s.db.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to store alert: %v", err)
+	}
+
+	entry.ID = record.ID
+	return nil
+}
+
+// UpdateAlert updates the status, delivered channels and error of an alert
+// in the alert history
+func (s *Storage) UpdateAlert(entry *types.AlertEntry) error {
+	defer queryDuration.ObserveSince(time.Now(), "update_alert")
+
+	channels, err := marshalOptional(entry.Channels)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert channels: %v", err)
+	}
+
+	record := &AlertRecord{ID: entry.ID, Status: entry.Status, Channels: channels, Error: entry.Error}
+	if err := s.db.Model(record).Select("status", "channels", "error").Updates(record).Error; err != nil {
+		return fmt.Errorf("failed to update alert: %v", err)
+	}
+	return nil
+}
+
+// GetAlerts retrieves alerts matching the given filter, newest first
+func (s *Storage) GetAlerts(filter AlertFilter) ([]*types.AlertEntry, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_alerts")
+
+	var records []AlertRecord
+
+	query := s.db.Order("timestamp DESC, id DESC")
+	if filter.JobName != "" {
+		query = query.Where("job_name = ?", filter.JobName)
+	}
+	if filter.Namespace != "" {
+		query = query.Where("namespace = ?", filter.Namespace)
+	}
+	if filter.Level != "" {
+		query = query.Where("level = ?", filter.Level)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Event != "" {
+		query = query.Where("event = ?", filter.Event)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("timestamp >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("timestamp <= ?", filter.Until)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve alerts: %v", err)
+	}
+
+	return alertsFromRecords(records)
+}
+
+// GetAlert retrieves an alert by ID, or nil if there is none
+func (s *Storage) GetAlert(id uint) (*types.AlertEntry, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_alert")
+
+	var records []AlertRecord
+	if err := s.db.Limit(1).Find(&records, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve alert: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	entries, err := alertsFromRecords(records)
+	if err != nil {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+// GetUnresolvedAlerts retrieves the alerts of a job for an event that no
+// successful run resolved yet, newest first
+func (s *Storage) GetUnresolvedAlerts(jobName, event string) ([]*types.AlertEntry, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_unresolved_alerts")
+
+	var records []AlertRecord
+	err := s.db.Where("job_name = ? AND event = ? AND resolved_at IS NULL", jobName, event).
+		Order("timestamp DESC, id DESC").Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve unresolved alerts: %v", err)
+	}
+
+	return alertsFromRecords(records)
+}
+
+// ResolveAlert marks an alert resolved at the given time
+func (s *Storage) ResolveAlert(id uint, resolvedAt time.Time) error {
+	defer queryDuration.ObserveSince(time.Now(), "resolve_alert")
+
+	if err := s.db.Model(&AlertRecord{}).Where("id = ?", id).Update("resolved_at", resolvedAt).Error; err != nil {
+		return fmt.Errorf("failed to resolve alert: %v", err)
+	}
+	return nil
+}
+
+// AcknowledgeAlert records that a principal acknowledged an alert, unless
+// it already is. It returns the alert, or nil if there is none with the ID.
+func (s *Storage) AcknowledgeAlert(id uint, principal string, now time.Time) (*types.AlertEntry, error) {
+	defer queryDuration.ObserveSince(time.Now(), "acknowledge_alert")
+
+	var record AlertRecord
+	result := s.db.Limit(1).Find(&record, id)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve alert: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	if record.AcknowledgedAt == nil {
+		record.AcknowledgedAt = &now
+		record.AcknowledgedBy = principal
+		if err := s.db.Model(&record).Select("acknowledged_at", "acknowledged_by").Updates(&record).Error; err != nil {
+			return nil, fmt.Errorf("failed to acknowledge alert: %v", err)
+		}
+	}
+
+	entries, err := alertsFromRecords([]AlertRecord{record})
+	if err != nil {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+func alertsFromRecords(records []AlertRecord) ([]*types.AlertEntry, error) {
+	entries := make([]*types.AlertEntry, len(records))
+	for i, record := range records {
+		entry := &types.AlertEntry{
+			ID:             record.ID,
+			Timestamp:      record.Timestamp,
+			Level:          record.Level,
+			Title:          record.Title,
+			Message:        record.Message,
+			JobName:        record.JobName,
+			Namespace:      record.Namespace,
+			ExecutionID:    record.ExecutionID,
+			Status:         record.Status,
+			SilenceID:      record.SilenceID,
+			Error:          record.Error,
+			Event:          record.Event,
+			ResolvesID:     record.ResolvesID,
+			ResolvedAt:     record.ResolvedAt,
+			AcknowledgedAt: record.AcknowledgedAt,
+			AcknowledgedBy: record.AcknowledgedBy,
+		}
+		if err := unmarshalOptional(record.Labels, &entry.Labels); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal alert labels: %v", err)
+		}
+		if err := unmarshalOptional(record.Channels, &entry.Channels); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal alert channels: %v", err)
+		}
+		entries[i] = entry
+	}
+
+	return entries, nil
+}
+
+// CreateSilence stores a new silence
+func (s *Storage) CreateSilence(silence *types.Silence) error {
+	defer queryDuration.ObserveSince(time.Now(), "create_silence")
+
+	labels, err := marshalOptional(silence.Labels)
+	if err != nil {
+		return fmt.Errorf("failed to marshal silence labels: %v", err)
+	}
+
+	record := &SilenceRecord{
+		JobName:   silence.JobName,
+		Level:     silence.Level,
+		Labels:    labels,
+		Comment:   silence.Comment,
+		CreatedBy: silence.CreatedBy,
+		StartsAt:  silence.StartsAt,
+		EndsAt:    silence.EndsAt,
+	}
+
+	if err := s.db.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to create silence: %v", err)
+	}
+
+	silence.ID = record.ID
+	silence.CreatedAt = record.CreatedAt
+	return nil
+}
+
+// GetSilences retrieves the silences that have not ended before the given
+// time, or all of them if it is zero, newest first
+func (s *Storage) GetSilences(endedAfter time.Time) ([]*types.Silence, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_silences")
+
+	var records []SilenceRecord
+
+	query := s.db.Order("created_at DESC, id DESC")
+	if !endedAfter.IsZero() {
+		query = query.Where("ends_at > ?", endedAfter)
+	}
+
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve silences: %v", err)
+	}
+
+	silences := make([]*types.Silence, len(records))
+	for i, record := range records {
+		silence, err := silenceFromRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		silences[i] = silence
+	}
+
+	return silences, nil
+}
+
+// ExpireSilence ends a silence at the given time, unless it already ended.
+// It returns the silence, or nil if there is none with the ID.
+func (s *Storage) ExpireSilence(id uint, now time.Time) (*types.Silence, error) {
+	defer queryDuration.ObserveSince(time.Now(), "expire_silence")
+
+	var record SilenceRecord
+	result := s.db.Limit(1).Find(&record, id)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve silence: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	if record.EndsAt.After(now) {
+		record.EndsAt = now
+		if record.StartsAt.After(now) {
+			record.StartsAt = now
+		}
+		if err := s.db.Model(&record).Select("starts_at", "ends_at").Updates(&record).Error; err != nil {
+			return nil, fmt.Errorf("failed to expire silence: %v", err)
+		}
+	}
+
+	return silenceFromRecord(record)
+}
+
+// StoreDeadLetter stores an alert delivery given up after its retries
+func (s *Storage) StoreDeadLetter(letter *types.DeadLetter) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_dead_letter")
+
+	record := &DeadLetterRecord{
+		AlertID:      letter.AlertID,
+		Channel:      letter.Channel,
+		SlackChannel: letter.SlackChannel,
+		Alert:        letter.Alert,
+		Attempts:     letter.Attempts,
+		LastError:    letter.LastError,
+	}
+
+	if err := s.db.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to store dead letter: %v", err)
+	}
+
+	letter.ID = record.ID
+	letter.CreatedAt = record.CreatedAt
+	return nil
+}
+
+// GetDeadLetters retrieves the dead letters not redriven yet, or all of
+// them if includeRedriven is set, newest first
+func (s *Storage) GetDeadLetters(includeRedriven bool, limit int) ([]*types.DeadLetter, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_dead_letters")
+
+	var records []DeadLetterRecord
+
+	query := s.db.Order("created_at DESC, id DESC")
+	if !includeRedriven {
+		query = query.Where("redriven_at IS NULL")
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve dead letters: %v", err)
+	}
+
+	letters := make([]*types.DeadLetter, len(records))
+	for i, record := range records {
+		letters[i] = deadLetterFromRecord(record)
+	}
+
+	return letters, nil
+}
+
+// GetDeadLetter retrieves a dead letter by ID, or nil if there is none
+func (s *Storage) GetDeadLetter(id uint) (*types.DeadLetter, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_dead_letter")
+
+	var record DeadLetterRecord
+	result := s.db.Limit(1).Find(&record, id)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve dead letter: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return deadLetterFromRecord(record), nil
+}
+
+// UpdateDeadLetter updates the attempts, last error and redrive time of a
+// dead letter
+func (s *Storage) UpdateDeadLetter(letter *types.DeadLetter) error {
+	defer queryDuration.ObserveSince(time.Now(), "update_dead_letter")
+
+	record := &DeadLetterRecord{ID: letter.ID, Attempts: letter.Attempts, LastError: letter.LastError, RedrivenAt: letter.RedrivenAt}
+	if err := s.db.Model(record).Select("attempts", "last_error", "redriven_at").Updates(record).Error; err != nil {
+		return fmt.Errorf("failed to update dead letter: %v", err)
+	}
+	return nil
+}
+
+func deadLetterFromRecord(record DeadLetterRecord) *types.DeadLetter {
+	return &types.DeadLetter{
+		ID:           record.ID,
+		AlertID:      record.AlertID,
+		Channel:      record.Channel,
+		SlackChannel: record.SlackChannel,
+		Alert:        record.Alert,
+		Attempts:     record.Attempts,
+		LastError:    record.LastError,
+		CreatedAt:    record.CreatedAt,
+		RedrivenAt:   record.RedrivenAt,
+	}
+}
+
+func silenceFromRecord(record SilenceRecord) (*types.Silence, error) {
+	silence := &types.Silence{
+		ID:        record.ID,
+		JobName:   record.JobName,
+		Level:     record.Level,
+		Comment:   record.Comment,
+		CreatedBy: record.CreatedBy,
+		StartsAt:  record.StartsAt,
+		EndsAt:    record.EndsAt,
+		CreatedAt: record.CreatedAt,
+	}
+	if err := unmarshalOptional(record.Labels, &silence.Labels); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal silence labels: %v", err)
+	}
+	return silence, nil
+}
+
+// marshalOptional encodes a value as JSON, leaving empty maps and slices
+// empty
+func marshalOptional(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil || string(data) == "null" || string(data) == "{}" || string(data) == "[]" {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// unmarshalOptional decodes JSON written by marshalOptional
+func unmarshalOptional(data string, value interface{}) error {
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), value)
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestAlertHistory(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now()
+
+	entries := []*types.AlertEntry{
+		{Timestamp: now.Add(-time.Hour), Level: "error", Title: "Job Failed: backup", JobName: "backup",
+			Labels: map[string]string{"team": "ops"}, Status: types.AlertStatusSent, Channels: []string{"slack", "webhook"}},
+		{Timestamp: now, Level: "error", Title: "Job Failed: backup", JobName: "backup", Status: types.AlertStatusSilenced, SilenceID: 3},
+	}
+	for _, entry := range entries {
+		if err := store.StoreAlert(entry); err != nil {
+			t.Fatalf("StoreAlert() error = %v", err)
+		}
+	}
+
+	got, err := store.GetAlerts(AlertFilter{JobName: "backup"})
+	if err != nil {
+		t.Fatalf("GetAlerts() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Status != types.AlertStatusSilenced || got[0].SilenceID != 3 {
+		t.Fatalf("GetAlerts() = %+v, want the silenced alert first", got)
+	}
+	if sent := got[1]; len(sent.Channels) != 2 || sent.Labels["team"] != "ops" {
+		t.Errorf("sent alert = %+v, want its channels and labels", sent)
+	}
+
+	sent, err := store.GetAlerts(AlertFilter{Status: types.AlertStatusSent})
+	if err != nil || len(sent) != 1 {
+		t.Errorf("GetAlerts(sent) = %d alerts, %v, want 1", len(sent), err)
+	}
+}
+
+func TestSilenceLifecycle(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now()
+
+	active := &types.Silence{JobName: "backup", Labels: map[string]string{"team": "ops"}, CreatedBy: "admin",
+		StartsAt: now.Add(-time.Minute), EndsAt: now.Add(time.Hour)}
+	pending := &types.Silence{Level: "warning", CreatedBy: "admin", StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour)}
+	expired := &types.Silence{JobName: "report", CreatedBy: "admin", StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)}
+	for _, silence := range []*types.Silence{active, pending, expired} {
+		if err := store.CreateSilence(silence); err != nil {
+			t.Fatalf("CreateSilence() error = %v", err)
+		}
+	}
+
+	current, err := store.GetSilences(now)
+	if err != nil {
+		t.Fatalf("GetSilences() error = %v", err)
+	}
+	if len(current) != 2 {
+		t.Fatalf("GetSilences(now) returned %d silences, want the active and pending ones", len(current))
+	}
+
+	silence, err := store.ExpireSilence(active.ID, now)
+	if err != nil {
+		t.Fatalf("ExpireSilence() error = %v", err)
+	}
+	if silence.State(now) != types.SilenceStateExpired || silence.Labels["team"] != "ops" {
+		t.Errorf("ExpireSilence() = %+v, want it expired now", silence)
+	}
+
+	if silence, err := store.ExpireSilence(pending.ID, now); err != nil || !silence.EndsAt.Equal(silence.StartsAt) {
+		t.Errorf("ExpireSilence(pending) = %+v, %v, want it to end before it started", silence, err)
+	}
+
+	if silence, err := store.ExpireSilence(999, now); silence != nil || err != nil {
+		t.Errorf("ExpireSilence(unknown) = %v, %v, want nil, nil", silence, err)
+	}
+
+	all, err := store.GetSilences(time.Time{})
+	if err != nil || len(all) != 3 {
+		t.Errorf("GetSilences(all) returned %d silences, %v, want 3", len(all), err)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	store := newTestStorage(t)
+
+	entry := &types.AlertEntry{Timestamp: time.Now(), Level: "error", Title: "Job Failed: backup", Status: types.AlertStatusRetrying}
+	if err := store.StoreAlert(entry); err != nil {
+		t.Fatalf("StoreAlert() error = %v", err)
+	}
+	entry.Status, entry.Channels, entry.Error = types.AlertStatusFailed, []string{"slack"}, "webhook: timeout"
+	if err := store.UpdateAlert(entry); err != nil {
+		t.Fatalf("UpdateAlert() error = %v", err)
+	}
+	alerts, err := store.GetAlerts(AlertFilter{})
+	if err != nil || len(alerts) != 1 || alerts[0].Status != types.AlertStatusFailed || alerts[0].Error != "webhook: timeout" {
+		t.Fatalf("GetAlerts() = %+v, %v, want the updated alert", alerts, err)
+	}
+
+	letter := &types.DeadLetter{AlertID: entry.ID, Channel: "webhook", Alert: `{"title":"Job Failed: backup"}`,
+		Attempts: 5, LastError: "timeout"}
+	if err := store.StoreDeadLetter(letter); err != nil {
+		t.Fatalf("StoreDeadLetter() error = %v", err)
+	}
+
+	got, err := store.GetDeadLetter(letter.ID)
+	if err != nil || got == nil || got.Alert != letter.Alert || got.Attempts != 5 {
+		t.Fatalf("GetDeadLetter() = %+v, %v, want the stored letter", got, err)
+	}
+	if missing, err := store.GetDeadLetter(letter.ID + 1); missing != nil || err != nil {
+		t.Errorf("GetDeadLetter(missing) = %+v, %v, want nil", missing, err)
+	}
+
+	now := time.Now()
+	got.Attempts, got.LastError, got.RedrivenAt = 6, "", &now
+	if err := store.UpdateDeadLetter(got); err != nil {
+		t.Fatalf("UpdateDeadLetter() error = %v", err)
+	}
+
+	pending, err := store.GetDeadLetters(false, 0)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("GetDeadLetters(false) = %+v, %v, want none after the redrive", pending, err)
+	}
+	all, err := store.GetDeadLetters(true, 0)
+	if err != nil || len(all) != 1 || all[0].Attempts != 6 || all[0].RedrivenAt == nil {
+		t.Errorf("GetDeadLetters(true) = %+v, %v, want the redriven letter", all, err)
+	}
+}
+
+func TestResolveAlerts(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now()
+
+	for i, event := range []string{"failure", "failure", "sla"} {
+		entry := &types.AlertEntry{Timestamp: now.Add(time.Duration(i) * time.Minute), Level: "error", Title: "Job Failed: backup",
+			JobName: "backup", Status: types.AlertStatusSent, Event: event}
+		if err := store.StoreAlert(entry); err != nil {
+			t.Fatalf("StoreAlert() error = %v", err)
+		}
+	}
+
+	unresolved, err := store.GetUnresolvedAlerts("backup", "failure")
+	if err != nil || len(unresolved) != 2 || unresolved[0].ID != 2 {
+		t.Fatalf("GetUnresolvedAlerts() = %+v, %v, want both failures newest first", unresolved, err)
+	}
+
+	if err := store.ResolveAlert(unresolved[0].ID, now); err != nil {
+		t.Fatalf("ResolveAlert() error = %v", err)
+	}
+	unresolved, err = store.GetUnresolvedAlerts("backup", "failure")
+	if err != nil || len(unresolved) != 1 || unresolved[0].ID != 1 {
+		t.Errorf("GetUnresolvedAlerts() = %+v, %v, want the older failure", unresolved, err)
+	}
+
+	resolved, err := store.GetAlerts(AlertFilter{Event: "failure"})
+	if err != nil || len(resolved) != 2 || resolved[0].ResolvedAt == nil {
+		t.Errorf("GetAlerts(failure) = %+v, %v, want the newest failure resolved", resolved, err)
+	}
+}
+
+func TestAcknowledgeAlert(t *testing.T) {
+	store := newTestStorage(t)
+
+	entry := &types.AlertEntry{Timestamp: time.Now(), Level: "error", Title: "Job Failed: backup", JobName: "backup",
+		Status: types.AlertStatusSent, Event: "failure"}
+	if err := store.StoreAlert(entry); err != nil {
+		t.Fatalf("StoreAlert() error = %v", err)
+	}
+
+	acked, err := store.AcknowledgeAlert(entry.ID, "alice", time.Now())
+	if err != nil || acked == nil || acked.AcknowledgedBy != "alice" || acked.AcknowledgedAt == nil {
+		t.Fatalf("AcknowledgeAlert() = %+v, %v, want it acknowledged by alice", acked, err)
+	}
+
+	// A second acknowledgement keeps the first
+	again, err := store.AcknowledgeAlert(entry.ID, "bob", time.Now())
+	if err != nil || again.AcknowledgedBy != "alice" {
+		t.Errorf("AcknowledgeAlert() again = %+v, %v, want the first acknowledgement kept", again, err)
+	}
+
+	if missing, err := store.AcknowledgeAlert(entry.ID+1, "alice", time.Now()); missing != nil || err != nil {
+		t.Errorf("AcknowledgeAlert(missing) = %+v, %v, want nil", missing, err)
+	}
+
+	unresolved, err := store.GetUnresolvedAlerts("backup", "failure")
+	if err != nil || len(unresolved) != 1 || unresolved[0].AcknowledgedBy != "alice" {
+		t.Errorf("GetUnresolvedAlerts() = %+v, %v, want the acknowledgement", unresolved, err)
+	}
+}
+
+func TestAlertNamespaces(t *testing.T) {
+	store := newTestStorage(t)
+
+	for _, namespace := range []string{"team-a", "team-b"} {
+		entry := &types.AlertEntry{Timestamp: time.Now(), Level: "error", Title: "Job Failed", JobName: namespace + "-job",
+			Namespace: namespace, Status: types.AlertStatusSent}
+		if err := store.StoreAlert(entry); err != nil {
+			t.Fatalf("StoreAlert() error = %v", err)
+		}
+	}
+
+	got, err := store.GetAlerts(AlertFilter{Namespace: "team-b"})
+	if err != nil {
+		t.Fatalf("GetAlerts() error = %v", err)
+	}
+	if len(got) != 1 || got[0].JobName != "team-b-job" || got[0].Namespace != "team-b" {
+		t.Fatalf("GetAlerts(team-b) = %+v, want the alert of team-b", got)
+	}
+
+	entry, err := store.GetAlert(got[0].ID)
+	if err != nil || entry == nil || entry.Namespace != "team-b" {
+		t.Errorf("GetAlert(%d) = %+v, %v, want the alert of team-b", got[0].ID, entry, err)
+	}
+	if missing, err := store.GetAlert(999); err != nil || missing != nil {
+		t.Errorf("GetAlert(999) = %+v, %v, want nil", missing, err)
+	}
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+	"gorm.io/gorm/clause"
+)
+
+// AnomalyRecord represents a detected metrics anomaly in the database
+type AnomalyRecord struct {
+	ID          uint      `gorm:"primaryKey"`
+	Timestamp   time.Time `gorm:"index;not null"`
+	Type        string    `gorm:"index;not null"`
+	Severity    string    `gorm:"index;not null"`
+	Value       float64
+	Expected    float64
+	Deviation   float64
+	Description string `gorm:"type:text"`
+	CreatedAt   time.Time
+}
+
+// AnomalyBaselineRecord represents the rolling statistics of a metric
+// used for anomaly detection
+type AnomalyBaselineRecord struct {
+	ID        uint   `gorm:"primaryKey"`
+	Metric    string `gorm:"uniqueIndex:idx_anomaly_baseline;not null"`
+	Hour      int    `gorm:"uniqueIndex:idx_anomaly_baseline;not null"`
+	Count     int
+	Mean      float64
+	Variance  float64
+	UpdatedAt time.Time
+}
+
+// AnomalyFilter narrows down anomaly queries
+type AnomalyFilter struct {
+	Type       string
+	Severities []string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+// StoreAnomaly stores a detected anomaly
+func (s *Storage) StoreAnomaly(anomaly *types.Anomaly) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_anomaly")
+
+	record := &AnomalyRecord{
+		Timestamp:   anomaly.Timestamp,
+		Type:        anomaly.Type,
+		Severity:    anomaly.Severity,
+		Value:       anomaly.Value,
+		Expected:    anomaly.Expected,
+		Deviation:   anomaly.Deviation,
+		Description: anomaly.Description,
+	}
+
+	if err := s.db.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to store anomaly: %v", err)
+	}
+
+	anomaly.ID = record.ID
+	return nil
+}
+
+// GetAnomalies retrieves anomalies matching the given filter, newest first
+func (s *Storage) GetAnomalies(filter AnomalyFilter) ([]*types.Anomaly, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_anomalies")
+
+	var records []AnomalyRecord
+
+	query := s.db.Order("timestamp DESC")
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if len(filter.Severities) > 0 {
+		query = query.Where("severity IN ?", filter.Severities)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("timestamp >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("timestamp <= ?", filter.Until)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve anomalies: %v", err)
+	}
+
+	anomalies := make([]*types.Anomaly, len(records))
+	for i, record := range records {
+		anomalies[i] = &types.Anomaly{
+			ID:          record.ID,
+			Timestamp:   record.Timestamp,
+			Type:        record.Type,
+			Severity:    record.Severity,
+			Value:       record.Value,
+			Expected:    record.Expected,
+			Deviation:   record.Deviation,
+			Description: record.Description,
+		}
+	}
+
+	return anomalies, nil
+}
+
+// SaveAnomalyBaselines creates or updates anomaly detection baselines
+func (s *Storage) SaveAnomalyBaselines(baselines []types.AnomalyBaseline) error {
+	defer queryDuration.ObserveSince(time.Now(), "save_anomaly_baselines")
+
+	if len(baselines) == 0 {
+		return nil
+	}
+
+	records := make([]AnomalyBaselineRecord, len(baselines))
+	for i, b := range baselines {
+		records[i] = AnomalyBaselineRecord{
+			Metric:    b.Metric,
+			Hour:      b.Hour,
+			Count:     b.Count,
+			Mean:      b.Mean,
+			Variance:  b.Variance,
+			UpdatedAt: b.UpdatedAt,
+		}
+	}
+
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "metric"}, {Name: "hour"}},
+		DoUpdates: clause.AssignmentColumns([]string{"count", "mean", "variance", "updated_at"}),
+	}).CreateInBatches(records, 100).Error
+	if err != nil {
+		return fmt.Errorf("failed to save anomaly baselines: %v", err)
+	}
+
+	return nil
+}
+
+// LoadAnomalyBaselines retrieves all anomaly detection baselines
+func (s *Storage) LoadAnomalyBaselines() ([]types.AnomalyBaseline, error) {
+	defer queryDuration.ObserveSince(time.Now(), "load_anomaly_baselines")
+
+	var records []AnomalyBaselineRecord
+	if err := s.db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to load anomaly baselines: %v", err)
+	}
+
+	baselines := make([]types.AnomalyBaseline, len(records))
+	for i, record := range records {
+		baselines[i] = types.AnomalyBaseline{
+			Metric:    record.Metric,
+			Hour:      record.Hour,
+			Count:     record.Count,
+			Mean:      record.Mean,
+			Variance:  record.Variance,
+			UpdatedAt: record.UpdatedAt,
+		}
+	}
+
+	return baselines, nil
+}
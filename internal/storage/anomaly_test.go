@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestAnomalyRoundTrip(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now().Truncate(time.Second)
+
+	anomalies := []*types.Anomaly{
+		{Timestamp: now.Add(-2 * time.Hour), Type: "cpu", Severity: "low", Value: 70, Expected: 40, Deviation: 3.1, Description: "CPU usage 70.0% (expected 40.0%)"},
+		{Timestamp: now.Add(-time.Hour), Type: "memory", Severity: "high", Value: 95, Expected: 50, Deviation: 5.2, Description: "memory"},
+		{Timestamp: now, Type: "cpu", Severity: "critical", Value: 99, Expected: 40, Deviation: 8.4, Description: "cpu"},
+	}
+	for _, anomaly := range anomalies {
+		if err := store.StoreAnomaly(anomaly); err != nil {
+			t.Fatalf("StoreAnomaly() error = %v", err)
+		}
+		if anomaly.ID == 0 {
+			t.Error("StoreAnomaly() did not set the ID")
+		}
+	}
+
+	got, err := store.GetAnomalies(AnomalyFilter{})
+	if err != nil {
+		t.Fatalf("GetAnomalies() error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("GetAnomalies() = %d anomalies, want 3", len(got))
+	}
+	// Newest first, with every field kept
+	first, want := got[2], anomalies[0]
+	if first.ID != want.ID || !first.Timestamp.Equal(want.Timestamp) || first.Type != want.Type ||
+		first.Severity != want.Severity || first.Value != want.Value || first.Expected != want.Expected ||
+		first.Deviation != want.Deviation || first.Description != want.Description {
+		t.Errorf("oldest anomaly = %+v, want %+v", first, want)
+	}
+	if got[0].ID != anomalies[2].ID {
+		t.Errorf("GetAnomalies()[0] = %+v, want the newest anomaly", got[0])
+	}
+
+	tests := []struct {
+		name   string
+		filter AnomalyFilter
+		want   []uint
+	}{
+		{"type", AnomalyFilter{Type: "cpu"}, []uint{anomalies[2].ID, anomalies[0].ID}},
+		{"severities", AnomalyFilter{Severities: []string{"high", "critical"}}, []uint{anomalies[2].ID, anomalies[1].ID}},
+		{"since", AnomalyFilter{Since: now.Add(-90 * time.Minute)}, []uint{anomalies[2].ID, anomalies[1].ID}},
+		{"until", AnomalyFilter{Until: now.Add(-90 * time.Minute)}, []uint{anomalies[0].ID}},
+		{"limit", AnomalyFilter{Limit: 1}, []uint{anomalies[2].ID}},
+		{"combined", AnomalyFilter{Type: "cpu", Severities: []string{"low"}}, []uint{anomalies[0].ID}},
+	}
+	for _, tt := range tests {
+		got, err := store.GetAnomalies(tt.filter)
+		if err != nil {
+			t.Fatalf("GetAnomalies(%s) error = %v", tt.name, err)
+		}
+		var ids []uint
+		for _, anomaly := range got {
+			ids = append(ids, anomaly.ID)
+		}
+		if len(ids) != len(tt.want) {
+			t.Errorf("GetAnomalies(%s) = %v, want %v", tt.name, ids, tt.want)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.want[i] {
+				t.Errorf("GetAnomalies(%s) = %v, want %v", tt.name, ids, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestAnomalyBaselinesRoundTrip(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now().Truncate(time.Second)
+
+	baselines := []types.AnomalyBaseline{
+		{Metric: "cpu", Hour: -1, Count: 10, Mean: 40, Variance: 4, UpdatedAt: now},
+		{Metric: "cpu", Hour: 9, Count: 3, Mean: 55, Variance: 9, UpdatedAt: now},
+	}
+	if err := store.SaveAnomalyBaselines(baselines); err != nil {
+		t.Fatalf("SaveAnomalyBaselines() error = %v", err)
+	}
+
+	// Saving again updates the baselines in place
+	baselines[1].Count, baselines[1].Mean = 4, 60
+	if err := store.SaveAnomalyBaselines(baselines[1:]); err != nil {
+		t.Fatalf("SaveAnomalyBaselines() error = %v", err)
+	}
+
+	got, err := store.LoadAnomalyBaselines()
+	if err != nil {
+		t.Fatalf("LoadAnomalyBaselines() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("LoadAnomalyBaselines() = %+v, want 2 baselines", got)
+	}
+	for _, baseline := range got {
+		want := baselines[0]
+		if baseline.Hour == 9 {
+			want = baselines[1]
+		}
+		if baseline.Metric != want.Metric || baseline.Count != want.Count || baseline.Mean != want.Mean ||
+			baseline.Variance != want.Variance || !baseline.UpdatedAt.Equal(want.UpdatedAt) {
+			t.Errorf("baseline = %+v, want %+v", baseline, want)
+		}
+	}
+}
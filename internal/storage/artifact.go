@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// ArtifactRecord represents an artifact of an execution in the database
+type ArtifactRecord struct {
+	ID          uint   `gorm:"primaryKey"`
+	ExecutionID string `gorm:"uniqueIndex:idx_artifact_name;not null"`
+	Name        string `gorm:"uniqueIndex:idx_artifact_name;not null"`
+	Size        int64
+	Location    string `gorm:"not null"`
+	CreatedAt   time.Time
+}
+
+// StoreArtifact stores an artifact of an execution and sets its ID
+func (s *Storage) StoreArtifact(artifact *types.Artifact) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_artifact")
+
+	record := &ArtifactRecord{
+		ExecutionID: artifact.ExecutionID,
+		Name:        artifact.Name,
+		Size:        artifact.Size,
+		Location:    artifact.Location,
+		CreatedAt:   artifact.CreatedAt,
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to store artifact: %v", err)
+	}
+
+	artifact.ID = record.ID
+	artifact.CreatedAt = record.CreatedAt
+	return nil
+}
+
+// GetArtifacts retrieves the artifacts of an execution ordered by name
+func (s *Storage) GetArtifacts(executionID string) ([]*types.Artifact, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_artifacts")
+
+	var records []ArtifactRecord
+	if err := s.db.Where("execution_id = ?", executionID).Order("name").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve artifacts: %v", err)
+	}
+
+	artifacts := make([]*types.Artifact, len(records))
+	for i, record := range records {
+		artifacts[i] = &types.Artifact{
+			ID:          record.ID,
+			ExecutionID: record.ExecutionID,
+			Name:        record.Name,
+			Size:        record.Size,
+			Location:    record.Location,
+			CreatedAt:   record.CreatedAt,
+		}
+	}
+	return artifacts, nil
+}
+
+// s3Scheme prefixes the locations of artifacts kept in S3
+const s3Scheme = "s3://"
+
+// ArtifactFiles keeps the files of artifacts under a directory, one
+// subdirectory per execution, or in an S3 bucket if one is configured
+type ArtifactFiles struct {
+	cfg config.ArtifactsConfig
+}
+
+// NewArtifactFiles creates the file store of artifacts
+func NewArtifactFiles(cfg config.ArtifactsConfig) *ArtifactFiles {
+	return &ArtifactFiles{cfg: cfg}
+}
+
+// MaxSize returns the size of the largest file kept, in bytes
+func (f *ArtifactFiles) MaxSize() int64 {
+	return f.cfg.MaxSize
+}
+
+// Save copies the file at path as the artifact name of an execution and
+// returns where it is kept
+func (f *ArtifactFiles) Save(ctx context.Context, executionID, name, path string) (string, error) {
+	if f.cfg.S3.Bucket != "" {
+		key := strings.TrimSuffix(f.cfg.S3.Prefix, "/")
+		if key != "" {
+			key += "/"
+		}
+		key += executionID + "/" + name
+		if err := uploadS3(ctx, f.cfg.S3, key, path); err != nil {
+			return "", fmt.Errorf("failed to upload artifact to S3: %v", err)
+		}
+		return s3Scheme + f.cfg.S3.Bucket + "/" + key, nil
+	}
+
+	source, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+
+	dir := filepath.Join(f.cfg.Directory, executionID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create artifact directory: %v", err)
+	}
+	location := filepath.Join(dir, name)
+	target, err := os.Create(location)
+	if err != nil {
+		return "", fmt.Errorf("failed to create artifact: %v", err)
+	}
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		os.Remove(location)
+		return "", fmt.Errorf("failed to copy artifact: %v", err)
+	}
+	if err := target.Close(); err != nil {
+		os.Remove(location)
+		return "", fmt.Errorf("failed to copy artifact: %v", err)
+	}
+	return location, nil
+}
+
+// Open opens the file of an artifact for reading
+func (f *ArtifactFiles) Open(ctx context.Context, artifact *types.Artifact) (io.ReadCloser, error) {
+	if object, ok := strings.CutPrefix(artifact.Location, s3Scheme); ok {
+		bucket, key, _ := strings.Cut(object, "/")
+		cfg := f.cfg.S3
+		cfg.Bucket = bucket
+		return downloadS3(ctx, cfg, key)
+	}
+	return os.Open(artifact.Location)
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestStoreArtifacts(t *testing.T) {
+	for name, repo := range map[string]ArtifactRepo{"storage": newTestStorage(t), "memory": NewMemoryStore()} {
+		t.Run(name, func(t *testing.T) {
+			for _, artifactName := range []string{"report.csv", "data.json"} {
+				artifact := &types.Artifact{ExecutionID: "run-1", Name: artifactName, Size: 3, Location: "/tmp/" + artifactName}
+				if err := repo.StoreArtifact(artifact); err != nil {
+					t.Fatalf("StoreArtifact() error = %v", err)
+				}
+				if artifact.ID == 0 {
+					t.Error("StoreArtifact() did not set the ID")
+				}
+			}
+			if err := repo.StoreArtifact(&types.Artifact{ExecutionID: "run-1", Name: "data.json", Location: "/tmp/x"}); err == nil {
+				t.Error("StoreArtifact() stored a second artifact of the same name")
+			}
+
+			artifacts, err := repo.GetArtifacts("run-1")
+			if err != nil {
+				t.Fatalf("GetArtifacts() error = %v", err)
+			}
+			if len(artifacts) != 2 || artifacts[0].Name != "data.json" || artifacts[1].Location != "/tmp/report.csv" {
+				t.Errorf("GetArtifacts() = %+v, want both artifacts by name", artifacts)
+			}
+			if artifacts, _ := repo.GetArtifacts("run-2"); len(artifacts) != 0 {
+				t.Errorf("GetArtifacts(run-2) = %+v, want none", artifacts)
+			}
+		})
+	}
+}
+
+func TestArtifactFilesLocal(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "report.csv")
+	os.WriteFile(source, []byte("a,b\n"), 0644)
+	files := NewArtifactFiles(config.ArtifactsConfig{Directory: t.TempDir()})
+
+	location, err := files.Save(context.Background(), "run-1", "report.csv", source)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if filepath.Base(filepath.Dir(location)) != "run-1" {
+		t.Errorf("Save() = %s, want the file under the execution", location)
+	}
+
+	file, err := files.Open(context.Background(), &types.Artifact{Location: location})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer file.Close()
+	if data, _ := io.ReadAll(file); string(data) != "a,b\n" {
+		t.Errorf("Open() read %q, want the saved file", data)
+	}
+}
+
+func TestArtifactFilesS3(t *testing.T) {
+	objects := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		}
+	}))
+	defer server.Close()
+
+	source := filepath.Join(t.TempDir(), "report.csv")
+	os.WriteFile(source, []byte("a,b\n"), 0644)
+	files := NewArtifactFiles(config.ArtifactsConfig{
+		S3: config.S3Config{
+			Endpoint:        server.URL,
+			Region:          "us-east-1",
+			Bucket:          "arcron",
+			Prefix:          "artifacts/",
+			AccessKeyID:     "key",
+			SecretAccessKey: "secret",
+			Timeout:         time.Minute,
+		},
+	})
+
+	location, err := files.Save(context.Background(), "run-1", "report.csv", source)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if location != "s3://arcron/artifacts/run-1/report.csv" {
+		t.Errorf("Save() = %s, want the object under the prefix", location)
+	}
+
+	file, err := files.Open(context.Background(), &types.Artifact{Location: location})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer file.Close()
+	if data, _ := io.ReadAll(file); string(data) != "a,b\n" {
+		t.Errorf("Open() read %q, want the uploaded file", data)
+	}
+
+	if _, err := files.Open(context.Background(), &types.Artifact{Location: "s3://arcron/missing"}); err == nil {
+		t.Error("Open() of a missing object succeeded")
+	}
+}
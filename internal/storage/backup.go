@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/makalin/arcron/internal/telemetry"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// sqliteHeader starts every SQLite database file
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+var backupsTotal = telemetry.NewCounter("arcron_storage_backups_total",
+	"Scheduled database backups by result", "result")
+
+// BackupExtension returns the file extension of backups of the database
+func (s *Storage) BackupExtension() string {
+	return ".db"
+}
+
+// Backup writes a consistent snapshot of the database to w as a SQLite
+// database file
+func (s *Storage) Backup(w io.Writer) error {
+	defer queryDuration.ObserveSince(time.Now(), "backup")
+
+	switch dialect := s.db.Dialector.Name(); dialect {
+	case "sqlite":
+		return s.backupSQLite(w)
+	default:
+		return fmt.Errorf("backups are not supported for %s databases", dialect)
+	}
+}
+
+// backupSQLite snapshots the database with VACUUM INTO, which reads it in
+// a single transaction while jobs keep writing
+func (s *Storage) backupSQLite(w io.Writer) error {
+	dir, err := os.MkdirTemp("", "arcron-backup-")
+	if err != nil {
+		return fmt.Errorf("failed to create backup directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "arcron.db")
+	if err := s.db.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return fmt.Errorf("failed to snapshot database: %v", err)
+	}
+
+	snapshot, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open database snapshot: %v", err)
+	}
+	defer snapshot.Close()
+
+	if _, err := io.Copy(w, snapshot); err != nil {
+		return fmt.Errorf("failed to write database snapshot: %v", err)
+	}
+	return nil
+}
+
+// Restore replaces the contents of the database with a backup read from r,
+// as written by Backup. Components holding state loaded from the database,
+// such as the maintenance state, pick up the restored data on restart.
+func (s *Storage) Restore(r io.Reader) error {
+	defer queryDuration.ObserveSince(time.Now(), "restore")
+
+	switch dialect := s.db.Dialector.Name(); dialect {
+	case "sqlite":
+		return s.restoreSQLite(r)
+	default:
+		return fmt.Errorf("restores are not supported for %s databases", dialect)
+	}
+}
+
+// restoreSQLite attaches the backup and copies every table over in one
+// transaction, so the open database is never left half restored. Columns
+// missing from an older backup keep their defaults.
+func (s *Storage) restoreSQLite(r io.Reader) error {
+	dir, err := os.MkdirTemp("", "arcron-restore-")
+	if err != nil {
+		return fmt.Errorf("failed to create restore directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "arcron.db")
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to store backup: %v", err)
+	}
+	_, err = io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store backup: %v", err)
+	}
+	if err := checkSQLiteFile(path); err != nil {
+		return err
+	}
+
+	// ATTACH applies to a single connection, so pin one
+	return s.db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("ATTACH DATABASE ? AS backup", path).Error; err != nil {
+			return fmt.Errorf("failed to open backup: %v", err)
+		}
+		defer conn.Exec("DETACH DATABASE backup")
+
+		return conn.Transaction(func(tx *gorm.DB) error {
+			for _, model := range models() {
+				if err := restoreTable(tx, model); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// restoreTable replaces the rows of a table with those of the attached
+// backup
+func restoreTable(tx *gorm.DB, model interface{}) error {
+	statement := &gorm.Statement{DB: tx}
+	if err := statement.Parse(model); err != nil {
+		return fmt.Errorf("failed to parse model: %v", err)
+	}
+	table := statement.Schema.Table
+
+	var backupColumns []string
+	if err := tx.Raw("SELECT name FROM pragma_table_info(?, 'backup')", table).Scan(&backupColumns).Error; err != nil {
+		return fmt.Errorf("failed to read backup table %s: %v", table, err)
+	}
+	inBackup := make(map[string]bool, len(backupColumns))
+	for _, column := range backupColumns {
+		inBackup[column] = true
+	}
+
+	if err := tx.Exec(fmt.Sprintf("DELETE FROM main.%q", table)).Error; err != nil {
+		return fmt.Errorf("failed to clear table %s: %v", table, err)
+	}
+	if len(backupColumns) == 0 {
+		return nil // The table is newer than the backup
+	}
+
+	var columns []string
+	for _, column := range statement.Schema.DBNames {
+		if inBackup[column] {
+			columns = append(columns, fmt.Sprintf("%q", column))
+		}
+	}
+	list := strings.Join(columns, ", ")
+	if err := tx.Exec(fmt.Sprintf("INSERT INTO main.%q (%s) SELECT %s FROM backup.%q", table, list, list, table)).Error; err != nil {
+		return fmt.Errorf("failed to restore table %s: %v", table, err)
+	}
+	return nil
+}
+
+// checkSQLiteFile verifies that a file is a SQLite database
+func checkSQLiteFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %v", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, len(sqliteHeader))
+	if _, err := io.ReadFull(file, header); err != nil || !bytes.Equal(header, sqliteHeader) {
+		return fmt.Errorf("backup is not a SQLite database")
+	}
+	return nil
+}
+
+// RunBackups periodically backs the database up to the backup directory
+// and, if configured, to S3
+func (s *Storage) RunBackups(ctx context.Context) {
+	if s.backup.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.backup.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			path, err := s.CreateBackup(ctx, time.Now())
+			if err != nil {
+				backupsTotal.Inc("failure")
+				logrus.Errorf("Failed to back up database: %v", err)
+				continue
+			}
+			backupsTotal.Inc("success")
+			logrus.Infof("Backed up database to %s", path)
+		}
+	}
+}
+
+// CreateBackup writes a backup to the backup directory, removes backups
+// beyond the number to keep and uploads the new one to S3 if a bucket is
+// configured. It returns the path of the backup.
+func (s *Storage) CreateBackup(ctx context.Context, now time.Time) (string, error) {
+	if err := os.MkdirAll(s.backup.Directory, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	name := "arcron-" + now.UTC().Format("20060102T150405Z") + s.BackupExtension()
+	path := filepath.Join(s.backup.Directory, name)
+
+	// Write to a temporary file first so a failed backup never looks
+	// like a complete one
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup file: %v", err)
+	}
+	err = s.Backup(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		os.Remove(path + ".tmp")
+		return "", err
+	}
+
+	if err := s.pruneBackups(); err != nil {
+		logrus.Warnf("Failed to remove old backups: %v", err)
+	}
+
+	if s.backup.S3.Bucket != "" {
+		key := strings.TrimSuffix(s.backup.S3.Prefix, "/")
+		if key != "" {
+			key += "/"
+		}
+		if err := uploadS3(ctx, s.backup.S3, key+name, path); err != nil {
+			return path, fmt.Errorf("failed to upload backup to S3: %v", err)
+		}
+	}
+
+	return path, nil
+}
+
+// pruneBackups removes the oldest backups beyond the number to keep
+func (s *Storage) pruneBackups() error {
+	if s.backup.Keep <= 0 {
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(s.backup.Directory, "arcron-*"+s.BackupExtension()))
+	if err != nil {
+		return err
+	}
+	// Names sort by the time of the backup
+	sort.Strings(paths)
+	for len(paths) > s.backup.Keep {
+		if err := os.Remove(paths[0]); err != nil {
+			return err
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
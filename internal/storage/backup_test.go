@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now()
+
+	if err := store.StoreJobExecution(&types.JobExecution{ID: "a", JobName: "backup", StartTime: now}); err != nil {
+		t.Fatalf("StoreJobExecution() error = %v", err)
+	}
+
+	var backup bytes.Buffer
+	if err := store.Backup(&backup); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	if !bytes.HasPrefix(backup.Bytes(), sqliteHeader) {
+		t.Fatal("Backup() did not write a SQLite database")
+	}
+
+	if err := store.StoreJobExecution(&types.JobExecution{ID: "b", JobName: "backup", StartTime: now}); err != nil {
+		t.Fatalf("StoreJobExecution() error = %v", err)
+	}
+
+	if err := store.Restore(bytes.NewReader(backup.Bytes())); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if execution, _ := store.GetJobExecution("a"); execution == nil {
+		t.Error("Restore() lost an execution in the backup")
+	}
+	if execution, _ := store.GetJobExecution("b"); execution != nil {
+		t.Error("Restore() kept an execution stored after the backup")
+	}
+
+	if err := store.Restore(strings.NewReader("not a database")); err == nil {
+		t.Error("Restore() accepted a file that is not a SQLite database")
+	}
+	if execution, _ := store.GetJobExecution("a"); execution == nil {
+		t.Error("failed Restore() changed the database")
+	}
+}
+
+func TestCreateBackupPrunesAndUploads(t *testing.T) {
+	var uploads []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPut || !bytes.HasPrefix(body, sqliteHeader) ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		uploads = append(uploads, r.URL.Path)
+	}))
+	defer server.Close()
+
+	store := newTestStorage(t)
+	store.backup = config.BackupConfig{
+		Directory: t.TempDir(),
+		Keep:      2,
+		S3: config.S3Config{
+			Endpoint:        server.URL,
+			Region:          "us-east-1",
+			Bucket:          "arcron",
+			Prefix:          "nightly/",
+			AccessKeyID:     "key",
+			SecretAccessKey: "secret",
+			Timeout:         time.Minute,
+		},
+	}
+
+	start := time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if _, err := store.CreateBackup(context.Background(), start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatalf("CreateBackup() error = %v", err)
+		}
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(store.backup.Directory, "*"))
+	if len(paths) != 2 || filepath.Base(paths[0]) != "arcron-20240601T030000Z.db" {
+		t.Errorf("backup directory = %v, want the 2 newest backups", paths)
+	}
+	if _, err := os.Stat(paths[0] + ".tmp"); err == nil {
+		t.Error("CreateBackup() left a temporary file")
+	}
+	if len(uploads) != 3 || uploads[2] != "/arcron/nightly/arcron-20240601T040000Z.db" {
+		t.Errorf("uploads = %v, want every backup under the prefix", uploads)
+	}
+}
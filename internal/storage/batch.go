@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/telemetry"
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var droppedSamples = telemetry.NewCounter("arcron_storage_metrics_dropped_total",
+	"System metrics samples dropped before being written by reason", "reason")
+
+// MetricsListener returns a listener storing every sample a monitor
+// collects, to register with monitoring.Monitor.AddListener. Samples go
+// through the batch writer when batching is enabled, so the listener does
+// not hold up collection; Close writes the ones still queued.
+func (s *Storage) MetricsListener() func(metrics *types.SystemMetrics) {
+	return func(metrics *types.SystemMetrics) {
+		if err := s.StoreSystemMetrics(metrics); err != nil {
+			logrus.Warnf("Failed to store system metrics: %v", err)
+		}
+	}
+}
+
+// metricsWriter buffers system metrics samples and writes them in batches,
+// so frequent sampling neither contends for the SQLite write lock nor waits
+// on a time-series backend with every sample
+type metricsWriter struct {
+	write    func(batch []*SystemMetricsRecord) error
+	size     int
+	interval time.Duration
+	queue    chan *SystemMetricsRecord
+	done     chan struct{}
+
+	mutex  sync.RWMutex
+	closed bool
+}
+
+// newMetricsWriter starts writing queued samples in batches with write
+func newMetricsWriter(write func(batch []*SystemMetricsRecord) error, cfg config.BatchConfig) *metricsWriter {
+	writer := &metricsWriter{
+		write:    write,
+		size:     cfg.Size,
+		interval: cfg.FlushInterval,
+		queue:    make(chan *SystemMetricsRecord, cfg.QueueSize),
+		done:     make(chan struct{}),
+	}
+
+	telemetry.NewGaugeFunc("arcron_storage_metrics_queue_length",
+		"System metrics samples waiting to be written", func() float64 {
+			return float64(len(writer.queue))
+		})
+
+	go writer.run()
+	return writer
+}
+
+// enqueue queues a sample for the next batch. It never blocks: a sample
+// arriving while the queue is full is dropped.
+func (w *metricsWriter) enqueue(record *SystemMetricsRecord) error {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	if w.closed {
+		droppedSamples.Inc("closed")
+		return fmt.Errorf("failed to store system metrics: storage closed")
+	}
+
+	select {
+	case w.queue <- record:
+		return nil
+	default:
+		droppedSamples.Inc("queue_full")
+		return fmt.Errorf("failed to store system metrics: write queue full")
+	}
+}
+
+// run writes a batch whenever it is full or the flush interval passed,
+// until the queue is closed and drained
+func (w *metricsWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	batch := make([]*SystemMetricsRecord, 0, w.size)
+	for {
+		select {
+		case record, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			if batch = append(batch, record); len(batch) >= w.size {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush writes a batch
+func (w *metricsWriter) flush(batch []*SystemMetricsRecord) {
+	if len(batch) == 0 {
+		return
+	}
+	defer queryDuration.ObserveSince(time.Now(), "flush_system_metrics")
+
+	if err := w.write(batch); err != nil {
+		droppedSamples.Add(float64(len(batch)), "write_error")
+		logrus.Errorf("Failed to write %d system metrics samples: %v", len(batch), err)
+	}
+}
+
+// close stops accepting samples and waits until the queued ones are
+// written
+func (w *metricsWriter) close() {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.queue)
+	w.mutex.Unlock()
+
+	<-w.done
+}
+
+// writeMetricsBatch writes a batch of samples to the database in a single
+// transaction
+func (s *Storage) writeMetricsBatch(batch []*SystemMetricsRecord) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(batch, len(batch)).Error
+	})
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestBatchedMetricsWrites(t *testing.T) {
+	cfg := config.DatabaseConfig{
+		Driver:    "sqlite",
+		DSN:       filepath.Join(t.TempDir(), "arcron.db"),
+		MaxConns:  2,
+		Retention: config.RetentionConfig{Raw: 24 * time.Hour},
+		Batch:     config.BatchConfig{Size: 3, FlushInterval: time.Hour, QueueSize: 10},
+	}
+	store, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	now := time.Now()
+	count := func(store *Storage) int64 {
+		var n int64
+		store.db.Model(&SystemMetricsRecord{}).Count(&n)
+		return n
+	}
+	for i := 0; i < 4; i++ {
+		if err := store.StoreSystemMetrics(&types.SystemMetrics{Timestamp: now.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatalf("StoreSystemMetrics() error = %v", err)
+		}
+	}
+
+	// A full batch is written right away, the rest waits for the interval
+	deadline := time.Now().Add(5 * time.Second)
+	for count(store) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := count(store); n != 3 {
+		t.Fatalf("stored %d samples before the flush interval, want the full batch of 3", n)
+	}
+
+	// Closing writes what is still queued
+	store.Close()
+	if err := store.StoreSystemMetrics(&types.SystemMetrics{Timestamp: now}); err == nil {
+		t.Error("StoreSystemMetrics() accepted a sample after Close()")
+	}
+
+	reopened, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer reopened.Close()
+	if n := count(reopened); n != 4 {
+		t.Errorf("stored %d samples after Close(), want 4", n)
+	}
+}
+
+func TestMetricsWriterDropsWhenFull(t *testing.T) {
+	writer := &metricsWriter{queue: make(chan *SystemMetricsRecord, 1)}
+
+	if err := writer.enqueue(&SystemMetricsRecord{}); err != nil {
+		t.Fatalf("enqueue() error = %v", err)
+	}
+	if err := writer.enqueue(&SystemMetricsRecord{}); err == nil {
+		t.Error("enqueue() blocked or accepted a sample with the queue full")
+	}
+}
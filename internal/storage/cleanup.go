@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/telemetry"
+	"github.com/sirupsen/logrus"
+)
+
+var cleanupDeleted = telemetry.NewCounter("arcron_storage_cleanup_deleted_total",
+	"Records deleted by the scheduled cleanup by table", "table")
+
+// cleanupTable is a table old records are deleted from
+type cleanupTable struct {
+	name      string
+	model     interface{}
+	retention time.Duration
+}
+
+// RunCleanup periodically deletes records older than the retention of
+// their table and vacuums the database afterwards if configured
+func (s *Storage) RunCleanup(ctx context.Context) {
+	if s.cleanup.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.cleanup.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Cleanup(time.Now()); err != nil {
+				logrus.Errorf("Failed to clean up old records: %v", err)
+			}
+		}
+	}
+}
+
+// Cleanup deletes records older than the configured retention of their
+// table and, if enabled, vacuums the database
+func (s *Storage) Cleanup(now time.Time) error {
+	if err := s.deleteExpired(now, s.cleanup); err != nil {
+		return err
+	}
+	if !s.cleanup.Vacuum {
+		return nil
+	}
+	return s.Vacuum()
+}
+
+// deleteExpired deletes records older than the given retentions. Tables with no
+// retention keep their records. System metrics samples and their rollup
+// tiers are not listed: RunRollups owns them, deleting each tier past its
+// database.retention as it rolls it up.
+func (s *Storage) deleteExpired(now time.Time, retention config.CleanupConfig) error {
+	defer queryDuration.ObserveSince(time.Now(), "cleanup_old_records")
+
+	tables := []cleanupTable{
+		{"job_executions", &JobExecutionRecord{}, retention.Executions},
+		{"job_impacts", &ImpactRecord{}, retention.Executions},
+		{"ml_predictions", &MLPredictionRecord{}, retention.Predictions},
+		{"load_forecasts", &ForecastRecord{}, retention.Forecasts},
+		{"schedule_adjustments", &AdjustmentRecord{}, retention.Adjustments},
+		{"anomalies", &AnomalyRecord{}, retention.Anomalies},
+		{"alerts", &AlertRecord{}, retention.Alerts},
+		{"alert_dead_letters", &DeadLetterRecord{}, retention.Alerts},
+		{"audit_entries", &AuditRecord{}, retention.Audit},
+	}
+
+	for _, table := range tables {
+		if table.retention <= 0 {
+			continue
+		}
+		result := s.db.Where("created_at < ?", now.Add(-table.retention)).Delete(table.model)
+		if result.Error != nil {
+			return fmt.Errorf("failed to clean up old %s: %v", table.name, result.Error)
+		}
+		if result.RowsAffected > 0 {
+			cleanupDeleted.Add(float64(result.RowsAffected), table.name)
+			logrus.Infof("Cleaned up %d %s older than %v", result.RowsAffected, table.name, table.retention)
+		}
+	}
+
+	return nil
+}
+
+// Vacuum reclaims the space of deleted records by rebuilding the SQLite
+// database file
+func (s *Storage) Vacuum() error {
+	defer queryDuration.ObserveSince(time.Now(), "vacuum")
+
+	switch dialect := s.db.Dialector.Name(); dialect {
+	case "sqlite":
+		if err := s.db.Exec("VACUUM").Error; err != nil {
+			return fmt.Errorf("failed to vacuum database: %v", err)
+		}
+	default:
+		logrus.Debugf("Vacuum not supported for %s databases", dialect)
+	}
+	return nil
+}
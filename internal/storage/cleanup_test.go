@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestCleanupPerTableRetention(t *testing.T) {
+	store := newTestStorage(t)
+	store.cleanup = config.CleanupConfig{
+		Executions: 24 * time.Hour,
+		Audit:      30 * 24 * time.Hour,
+		Vacuum:     true,
+	}
+	now := time.Now()
+
+	for _, id := range []string{"old", "new"} {
+		if err := store.StoreJobExecution(&types.JobExecution{ID: id, JobName: "backup", StartTime: now}); err != nil {
+			t.Fatalf("StoreJobExecution() error = %v", err)
+		}
+	}
+	if err := store.StoreAuditEntry(&types.AuditEntry{Action: "job.execute", Target: "backup"}); err != nil {
+		t.Fatalf("StoreAuditEntry() error = %v", err)
+	}
+	if err := store.StoreMLPrediction(&types.Prediction{JobName: "backup", OptimalTime: now}); err != nil {
+		t.Fatalf("StoreMLPrediction() error = %v", err)
+	}
+
+	// Age every record by two days
+	for _, model := range []interface{}{&JobExecutionRecord{}, &AuditRecord{}, &MLPredictionRecord{}} {
+		if err := store.db.Model(model).Where("1 = 1").Update("created_at", now.Add(-48*time.Hour)).Error; err != nil {
+			t.Fatalf("Failed to age records: %v", err)
+		}
+	}
+	if err := store.db.Model(&JobExecutionRecord{}).Where("id = ?", "new").Update("created_at", now).Error; err != nil {
+		t.Fatalf("Failed to age records: %v", err)
+	}
+
+	if err := store.Cleanup(now); err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+
+	if execution, _ := store.GetJobExecution("old"); execution != nil {
+		t.Error("Cleanup() kept an execution older than its retention")
+	}
+	if execution, _ := store.GetJobExecution("new"); execution == nil {
+		t.Error("Cleanup() deleted an execution within its retention")
+	}
+	if entries, _ := store.GetAuditEntries(AuditFilter{}); len(entries) != 1 {
+		t.Errorf("Cleanup() left %d audit entries, want 1 within its retention", len(entries))
+	}
+	if pending, _ := store.GetPendingPredictions(now.Add(time.Minute), 0); len(pending) != 1 {
+		t.Errorf("Cleanup() left %d predictions, want 1 as predictions have no retention", len(pending))
+	}
+}
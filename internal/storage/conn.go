@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	// queryTimeoutKey overrides the query timeout of a database session
+	queryTimeoutKey = "arcron:query_timeout"
+	// queryCancelKey holds the cancel function of a query's timeout
+	queryCancelKey = "arcron:query_cancel"
+)
+
+// openDatabase opens a connection pool whose queries time out after
+// timeout unless their context has an earlier deadline
+func openDatabase(driver, dsn string, maxConns int, timeout time.Duration) (*gorm.DB, error) {
+	var db *gorm.DB
+	var err error
+
+	switch driver {
+	case "sqlite":
+		db, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open SQLite database: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+
+	// Configure connection pool
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get underlying database: %v", err)
+	}
+
+	sqlDB.SetMaxOpenConns(maxConns)
+	sqlDB.SetMaxIdleConns(maxConns / 2)
+
+	if err := registerQueryTimeout(db, timeout); err != nil {
+		return nil, fmt.Errorf("failed to configure query timeout: %v", err)
+	}
+	return db, nil
+}
+
+// registerQueryTimeout bounds every query, insert, update, delete and raw
+// statement by a timeout. Row queries are left out: their rows are read
+// after the callbacks return.
+func registerQueryTimeout(db *gorm.DB, timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+
+	start := func(tx *gorm.DB) {
+		limit := timeout
+		if value, ok := tx.Get(queryTimeoutKey); ok {
+			limit = value.(time.Duration)
+		}
+		ctx := tx.Statement.Context
+		if _, ok := ctx.Deadline(); ok || limit <= 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(ctx, limit)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(queryCancelKey, cancel)
+	}
+	finish := func(tx *gorm.DB) {
+		if cancel, ok := tx.InstanceGet(queryCancelKey); ok {
+			cancel.(context.CancelFunc)()
+		}
+	}
+
+	callbacks := db.Callback()
+	registrations := []error{
+		callbacks.Query().Before("gorm:query").Register("arcron:timeout_start", start),
+		callbacks.Query().After("gorm:query").Register("arcron:timeout_finish", finish),
+		callbacks.Create().Before("gorm:create").Register("arcron:timeout_start", start),
+		callbacks.Create().After("gorm:create").Register("arcron:timeout_finish", finish),
+		callbacks.Update().Before("gorm:update").Register("arcron:timeout_start", start),
+		callbacks.Update().After("gorm:update").Register("arcron:timeout_finish", finish),
+		callbacks.Delete().Before("gorm:delete").Register("arcron:timeout_start", start),
+		callbacks.Delete().After("gorm:delete").Register("arcron:timeout_finish", finish),
+		callbacks.Raw().Before("gorm:raw").Register("arcron:timeout_start", start),
+		callbacks.Raw().After("gorm:raw").Register("arcron:timeout_finish", finish),
+	}
+	for _, err := range registrations {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithContext returns a view of the storage whose queries are bound to
+// ctx, e.g. the context of an API request, so they stop when it is done
+func (s *Storage) WithContext(ctx context.Context) *Storage {
+	bound := *s
+	bound.db = s.db.WithContext(ctx)
+	bound.readers = make([]*gorm.DB, len(s.readers))
+	for i, reader := range s.readers {
+		bound.readers[i] = reader.WithContext(ctx)
+	}
+	return &bound
+}
+
+// reader returns the database to run an analytical query on, taking the
+// read replicas in turn
+func (s *Storage) reader() *gorm.DB {
+	return s.readers[int(s.next.Add(1)%uint64(len(s.readers)))]
+}
+
+// CheckConnection reports the databases of a configuration that cannot
+// be reached, for config.Validate. Nothing is created or migrated: a
+// SQLite database only needs its directory to exist.
+func CheckConnection(cfg *config.Config) []error {
+	database := cfg.Database
+	var problems []error
+	for i, dsn := range append([]string{database.DSN}, database.ReadReplicas...) {
+		name := "database.dsn"
+		if i > 0 {
+			name = fmt.Sprintf("database.read_replicas[%d]", i-1)
+		}
+		if err := checkDSN(database.Driver, dsn); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %v", name, err))
+		}
+	}
+	return problems
+}
+
+// checkDSN pings an existing database, or checks that a new SQLite
+// database can be created
+func checkDSN(driver, dsn string) error {
+	if driver == "sqlite" {
+		path, _, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+		if path == "" || path == ":memory:" || strings.Contains(dsn, "mode=memory") {
+			return nil
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			info, err := os.Stat(filepath.Dir(path))
+			if err != nil || !info.IsDir() {
+				return fmt.Errorf("directory of %s does not exist", path)
+			}
+			return nil
+		}
+	}
+
+	db, err := openDatabase(driver, dsn, 1, 0)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	return nil
+}
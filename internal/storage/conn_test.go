@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestQueryTimeout(t *testing.T) {
+	db, err := openDatabase("sqlite", filepath.Join(t.TempDir(), "arcron.db"), 2, time.Nanosecond)
+	if err != nil {
+		t.Fatalf("openDatabase() error = %v", err)
+	}
+	defer func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}()
+
+	var records []JobExecutionRecord
+	if err := db.Raw("SELECT 1").Scan(&records).Error; err == nil {
+		t.Error("query ran past its timeout")
+	}
+
+	// A deadline set by the caller takes precedence
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var one int
+	if err := db.WithContext(ctx).Raw("SELECT 1").Scan(&one).Error; err != nil || one != 1 {
+		t.Errorf("query with caller deadline = %d, %v, want 1", one, err)
+	}
+}
+
+func TestWithContextCancelsQueries(t *testing.T) {
+	store := newTestStorage(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.WithContext(ctx).GetJobExecutions("backup", 10); err == nil {
+		t.Error("GetJobExecutions() ran with a cancelled context")
+	}
+	if _, err := store.GetJobExecutions("backup", 10); err != nil {
+		t.Errorf("GetJobExecutions() on the unbound storage error = %v", err)
+	}
+}
+
+func TestAnalyticsReadFromReplica(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.DatabaseConfig{
+		Driver:    "sqlite",
+		DSN:       filepath.Join(dir, "replica.db"),
+		MaxConns:  2,
+		Retention: config.RetentionConfig{Raw: 24 * time.Hour, Minute: 7 * 24 * time.Hour},
+	}
+	replica, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create replica: %v", err)
+	}
+	now := time.Now()
+	if err := replica.StoreSystemMetrics(&types.SystemMetrics{Timestamp: now, CPUUsage: 42}); err != nil {
+		t.Fatalf("StoreSystemMetrics() error = %v", err)
+	}
+	replica.Close()
+
+	cfg.ReadReplicas = []string{cfg.DSN}
+	cfg.DSN = filepath.Join(dir, "primary.db")
+	store, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	metrics, err := store.GetSystemMetrics(now.Add(-time.Minute), now.Add(time.Minute), 0)
+	if err != nil {
+		t.Fatalf("GetSystemMetrics() error = %v", err)
+	}
+	if len(metrics) != 1 || metrics[0].CPUUsage != 42 {
+		t.Errorf("GetSystemMetrics() = %v, want the sample of the replica", metrics)
+	}
+	if executions, err := store.GetJobExecutions("backup", 10); err != nil || len(executions) != 0 {
+		t.Errorf("GetJobExecutions() = %v, %v, want the primary's empty history", executions, err)
+	}
+}
+
+func TestCheckConnection(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Driver:       "sqlite",
+		DSN:          filepath.Join(dir, "arcron.db"),
+		ReadReplicas: []string{filepath.Join(dir, "missing", "replica.db")},
+	}}
+
+	problems := CheckConnection(cfg)
+	if len(problems) != 1 || !strings.Contains(problems[0].Error(), "database.read_replicas[0]") {
+		t.Errorf("CheckConnection() = %v, want the missing replica directory", problems)
+	}
+
+	cfg.Database.ReadReplicas = nil
+	if problems := CheckConnection(cfg); len(problems) != 0 {
+		t.Errorf("CheckConnection() = %v, want none for a new database", problems)
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestJobExecutionAttempts(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now()
+
+	first := &types.JobExecution{ID: "a", JobName: "backup", StartTime: now, Status: types.StatusRunning}
+	if err := store.StoreJobExecution(first); err != nil {
+		t.Fatalf("StoreJobExecution() error = %v", err)
+	}
+	first.Status = types.StatusFailed
+	if err := store.StoreJobExecution(first); err != nil {
+		t.Fatalf("StoreJobExecution() update error = %v", err)
+	}
+
+	retry := &types.JobExecution{ID: "b", JobName: "backup", StartTime: now, Status: types.StatusCompleted,
+		Attempt: 2, RetryCount: 1, ParentExecutionID: "a"}
+	if err := store.StoreJobExecution(retry); err != nil {
+		t.Fatalf("StoreJobExecution() retry error = %v", err)
+	}
+
+	// A second record for the same attempt is rejected
+	duplicate := &types.JobExecution{ID: "c", JobName: "backup", StartTime: now, Status: types.StatusFailed,
+		Attempt: 2, RetryCount: 1, ParentExecutionID: "a"}
+	if err := store.StoreJobExecution(duplicate); err == nil {
+		t.Error("StoreJobExecution() stored a duplicate attempt")
+	}
+
+	stored, err := store.GetJobExecution("a")
+	if err != nil || stored == nil || stored.Status != types.StatusFailed {
+		t.Fatalf("GetJobExecution(a) = %+v, %v, want the updated first attempt", stored, err)
+	}
+	stored, err = store.GetJobExecution("b")
+	if err != nil || stored == nil || stored.ParentExecutionID != "a" || stored.Attempt != 2 {
+		t.Errorf("GetJobExecution(b) = %+v, %v, want the retry of a", stored, err)
+	}
+}
+
+func TestEachJobExecution(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now()
+
+	for i, id := range []string{"c", "a", "b"} {
+		execution := &types.JobExecution{ID: id, JobName: "backup", StartTime: now.Add(time.Duration(i) * time.Minute)}
+		if err := store.StoreJobExecution(execution); err != nil {
+			t.Fatalf("StoreJobExecution() error = %v", err)
+		}
+	}
+
+	var ids []string
+	err := store.EachJobExecution("backup", func(execution *types.JobExecution) error {
+		ids = append(ids, execution.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachJobExecution() error = %v", err)
+	}
+	if strings.Join(ids, ",") != "c,a,b" {
+		t.Errorf("EachJobExecution() visited %v, want oldest first", ids)
+	}
+}
+
+func TestJobExecutionMetricsSnapshots(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	execution := &types.JobExecution{ID: "a", JobName: "backup", StartTime: now, Status: types.StatusRunning,
+		StartMetrics: &types.MetricsSnapshot{Timestamp: now, CPUUsage: 92, MemoryUsage: 40, DiskIOBytes: 1 << 20, Load1: 3.5}}
+	if err := store.StoreJobExecution(execution); err != nil {
+		t.Fatalf("StoreJobExecution() error = %v", err)
+	}
+
+	stored, err := store.GetJobExecution("a")
+	if err != nil || stored == nil || stored.StartMetrics == nil || stored.EndMetrics != nil {
+		t.Fatalf("GetJobExecution() = %+v, %v, want start metrics only", stored, err)
+	}
+	if got := *stored.StartMetrics; !got.Timestamp.Equal(now) || got.CPUUsage != 92 || got.DiskIOBytes != 1<<20 || got.Load1 != 3.5 {
+		t.Errorf("start metrics = %+v, want the stored snapshot", got)
+	}
+
+	// The end snapshot is added when the execution finishes
+	execution.Status = types.StatusCompleted
+	execution.EndMetrics = &types.MetricsSnapshot{Timestamp: now.Add(time.Minute), CPUUsage: 35}
+	if err := store.StoreJobExecution(execution); err != nil {
+		t.Fatalf("StoreJobExecution() update error = %v", err)
+	}
+	stored, err = store.GetJobExecution("a")
+	if err != nil || stored.EndMetrics == nil || stored.EndMetrics.CPUUsage != 35 || stored.StartMetrics.CPUUsage != 92 {
+		t.Errorf("GetJobExecution() after update = %+v, %v, want start and end metrics", stored, err)
+	}
+}
+
+func TestJobExecutionOutputMetrics(t *testing.T) {
+	store := newTestStorage(t)
+	execution := &types.JobExecution{ID: "a", JobName: "etl", StartTime: time.Now(), Status: types.StatusRunning}
+	if err := store.StoreJobExecution(execution); err != nil {
+		t.Fatalf("StoreJobExecution() error = %v", err)
+	}
+	if stored, _ := store.GetJobExecution("a"); stored.OutputMetrics != nil {
+		t.Errorf("output metrics = %v, want none before the run finished", stored.OutputMetrics)
+	}
+
+	execution.Status = types.StatusCompleted
+	execution.OutputMetrics = map[string]float64{"rows_processed": 1234, "errors": 0}
+	if err := store.StoreJobExecution(execution); err != nil {
+		t.Fatalf("StoreJobExecution() update error = %v", err)
+	}
+	stored, err := store.GetJobExecution("a")
+	if err != nil || len(stored.OutputMetrics) != 2 || stored.OutputMetrics["rows_processed"] != 1234 {
+		t.Errorf("GetJobExecution() = %+v, %v, want the output metrics", stored, err)
+	}
+}
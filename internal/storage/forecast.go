@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// ForecastRecord represents a single point of a load forecast in the database
+type ForecastRecord struct {
+	ID          uint      `gorm:"primaryKey"`
+	GeneratedAt time.Time `gorm:"index;not null"`
+	TargetTime  time.Time `gorm:"index;not null"`
+	Load        float64
+	Lower       float64
+	Upper       float64
+	ActualLoad  float64
+	EvaluatedAt *time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
+
+// StoreForecast stores the points of a load forecast and sets their IDs
+func (s *Storage) StoreForecast(forecast *types.LoadForecast) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_forecast")
+
+	if len(forecast.Points) == 0 {
+		return nil
+	}
+
+	records := make([]ForecastRecord, len(forecast.Points))
+	for i, point := range forecast.Points {
+		records[i] = ForecastRecord{
+			GeneratedAt: forecast.GeneratedAt,
+			TargetTime:  point.Time,
+			Load:        point.Load,
+			Lower:       point.Lower,
+			Upper:       point.Upper,
+		}
+	}
+
+	if err := s.db.Create(&records).Error; err != nil {
+		return fmt.Errorf("failed to store forecast: %v", err)
+	}
+
+	for i := range records {
+		forecast.Points[i].ID = records[i].ID
+	}
+	return nil
+}
+
+// GetPendingForecastPoints retrieves forecast points whose time is before
+// the given time and that have not been evaluated yet, oldest first
+func (s *Storage) GetPendingForecastPoints(before time.Time, limit int) ([]*types.ForecastPoint, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_pending_forecast_points")
+
+	var records []ForecastRecord
+
+	query := s.db.Where("evaluated_at IS NULL AND target_time < ?", before).Order("target_time ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve pending forecast points: %v", err)
+	}
+
+	points := make([]*types.ForecastPoint, len(records))
+	for i, record := range records {
+		points[i] = forecastPointFromRecord(record)
+	}
+
+	return points, nil
+}
+
+// StoreForecastOutcome records the evaluation of a forecast point
+func (s *Storage) StoreForecastOutcome(outcome *types.ForecastOutcome) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_forecast_outcome")
+
+	evaluatedAt := outcome.EvaluatedAt
+	result := s.db.Model(&ForecastRecord{}).Where("id = ?", outcome.ID).Updates(map[string]interface{}{
+		"actual_load":  outcome.ActualLoad,
+		"evaluated_at": &evaluatedAt,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to store forecast outcome: %v", result.Error)
+	}
+
+	return nil
+}
+
+// GetForecastOutcomes retrieves forecast points evaluated since the given time
+func (s *Storage) GetForecastOutcomes(since time.Time) ([]*types.ForecastOutcome, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_forecast_outcomes")
+
+	var records []ForecastRecord
+	if err := s.reader().Where("evaluated_at IS NOT NULL AND target_time >= ?", since).
+		Order("target_time ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve forecast outcomes: %v", err)
+	}
+
+	outcomes := make([]*types.ForecastOutcome, len(records))
+	for i, record := range records {
+		outcomes[i] = &types.ForecastOutcome{
+			ForecastPoint: *forecastPointFromRecord(record),
+			ActualLoad:    record.ActualLoad,
+			EvaluatedAt:   *record.EvaluatedAt,
+		}
+	}
+
+	return outcomes, nil
+}
+
+func forecastPointFromRecord(record ForecastRecord) *types.ForecastPoint {
+	return &types.ForecastPoint{
+		ID:    record.ID,
+		Time:  record.TargetTime,
+		Load:  record.Load,
+		Lower: record.Lower,
+		Upper: record.Upper,
+	}
+}
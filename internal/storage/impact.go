@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// ImpactRecord represents the impact score of an execution in the database
+type ImpactRecord struct {
+	ID          uint      `gorm:"primaryKey"`
+	ExecutionID string    `gorm:"uniqueIndex;not null"`
+	JobName     string    `gorm:"index;not null"`
+	Namespace   string    `gorm:"index"`
+	Timestamp   time.Time `gorm:"index;not null"`
+	CPUDelta    float64
+	MemoryDelta float64
+	LoadDelta   float64
+	Score       float64
+	Samples     int
+	CreatedAt   time.Time
+}
+
+// StoreJobImpact stores the impact score of an execution and sets its ID
+func (s *Storage) StoreJobImpact(impact *types.JobImpact) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_job_impact")
+
+	record := &ImpactRecord{
+		ExecutionID: impact.ExecutionID,
+		JobName:     impact.JobName,
+		Namespace:   impact.Namespace,
+		Timestamp:   impact.Timestamp,
+		CPUDelta:    impact.CPUDelta,
+		MemoryDelta: impact.MemoryDelta,
+		LoadDelta:   impact.LoadDelta,
+		Score:       impact.Score,
+		Samples:     impact.Samples,
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to store job impact: %v", err)
+	}
+
+	impact.ID = record.ID
+	return nil
+}
+
+// GetJobImpacts retrieves the latest impact scores of a job, newest first
+func (s *Storage) GetJobImpacts(jobName string, limit int) ([]*types.JobImpact, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_job_impacts")
+
+	var records []ImpactRecord
+	query := s.reader().Where("job_name = ?", jobName).Order("timestamp DESC, id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve job impacts: %v", err)
+	}
+
+	impacts := make([]*types.JobImpact, len(records))
+	for i, record := range records {
+		impacts[i] = &types.JobImpact{
+			ID:          record.ID,
+			ExecutionID: record.ExecutionID,
+			JobName:     record.JobName,
+			Namespace:   record.Namespace,
+			Timestamp:   record.Timestamp,
+			CPUDelta:    record.CPUDelta,
+			MemoryDelta: record.MemoryDelta,
+			LoadDelta:   record.LoadDelta,
+			Score:       record.Score,
+			Samples:     record.Samples,
+		}
+	}
+	return impacts, nil
+}
+
+// GetImpactSummaries averages the impact scores of each job since the
+// given time, ordered by job name
+func (s *Storage) GetImpactSummaries(since time.Time) ([]types.ImpactSummary, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_impact_summaries")
+
+	summaries := []types.ImpactSummary{}
+	err := s.reader().Model(&ImpactRecord{}).Where("timestamp >= ?", since).
+		Select("job_name, COUNT(*) AS executions, AVG(score) AS avg_score, MAX(score) AS max_score, " +
+			"AVG(cpu_delta) AS avg_cpu_delta, AVG(memory_delta) AS avg_memory_delta, " +
+			"AVG(load_delta) AS avg_load_delta").
+		Group("job_name").Order("job_name").Scan(&summaries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize job impacts: %v", err)
+	}
+	return summaries, nil
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestJobImpacts(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now()
+
+	impacts := []*types.JobImpact{
+		{ExecutionID: "a", JobName: "backup", Timestamp: now.Add(-2 * time.Hour), CPUDelta: 60, Score: 30, Samples: 4},
+		{ExecutionID: "b", JobName: "backup", Timestamp: now.Add(-time.Hour), CPUDelta: 20, Score: 10, Samples: 2},
+		{ExecutionID: "c", JobName: "logrotate", Timestamp: now, Score: 1, Samples: 1},
+		{ExecutionID: "d", JobName: "logrotate", Timestamp: now.Add(-30 * 24 * time.Hour), Score: 50, Samples: 1},
+	}
+	for _, impact := range impacts {
+		if err := store.StoreJobImpact(impact); err != nil {
+			t.Fatalf("StoreJobImpact() error = %v", err)
+		}
+	}
+	if impacts[0].ID == 0 {
+		t.Error("StoreJobImpact() did not set the ID")
+	}
+
+	recent, err := store.GetJobImpacts("backup", 1)
+	if err != nil || len(recent) != 1 || recent[0].ExecutionID != "b" {
+		t.Fatalf("GetJobImpacts(backup, 1) = %+v, %v, want the latest impact", recent, err)
+	}
+
+	summaries, err := store.GetImpactSummaries(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("GetImpactSummaries() error = %v", err)
+	}
+	want := []types.ImpactSummary{
+		{JobName: "backup", Executions: 2, AvgScore: 20, MaxScore: 30, AvgCPUDelta: 40},
+		{JobName: "logrotate", Executions: 1, AvgScore: 1, MaxScore: 1},
+	}
+	if len(summaries) != len(want) || summaries[0] != want[0] || summaries[1] != want[1] {
+		t.Errorf("GetImpactSummaries() = %+v, want %+v", summaries, want)
+	}
+}
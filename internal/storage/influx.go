@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// influxMeasurement is the measurement system metrics are written to
+const influxMeasurement = "system_metrics"
+
+// defaultInfluxTimeout bounds InfluxDB requests when no timeout is
+// configured
+const defaultInfluxTimeout = 10 * time.Second
+
+// MetricsStore stores and retrieves system metrics time series. External
+// time-series backends implement it in place of the database.
+type MetricsStore = MetricsRepo
+
+// InfluxStore stores system metrics in InfluxDB 2.x using the HTTP API.
+// Downsampling for long ranges is done at query time with aggregateWindow.
+type InfluxStore struct {
+	config config.TimeSeriesConfig
+	host   string
+	client *http.Client
+}
+
+// NewInfluxStore creates a new InfluxDB metrics store
+func NewInfluxStore(cfg config.TimeSeriesConfig) (*InfluxStore, error) {
+	if cfg.URL == "" || cfg.Bucket == "" || cfg.Org == "" {
+		return nil, fmt.Errorf("influxdb url, org and bucket must be configured")
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultInfluxTimeout
+	}
+
+	host, _ := os.Hostname()
+	return &InfluxStore{
+		config: cfg,
+		host:   host,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}, nil
+}
+
+// StoreSystemMetrics writes a sample using the line protocol
+func (s *InfluxStore) StoreSystemMetrics(metrics *types.SystemMetrics) error {
+	if err := s.writeBatch([]*SystemMetricsRecord{systemMetricsRecord(metrics)}); err != nil {
+		return fmt.Errorf("failed to store system metrics: %v", err)
+	}
+	return nil
+}
+
+// writeBatch writes samples in a single request, one line protocol line
+// per sample
+func (s *InfluxStore) writeBatch(batch []*SystemMetricsRecord) error {
+	var body strings.Builder
+	for _, record := range batch {
+		fmt.Fprintf(&body, "%s,host=%s cpu_usage=%g,memory_usage=%g,disk_io=%g,network_io=%g,load_avg=%g %d\n",
+			influxMeasurement,
+			escapeTagValue(s.host),
+			record.CPUUsage,
+			record.MemoryUsage,
+			record.DiskIO,
+			record.NetworkIO,
+			record.LoadAvg,
+			record.Timestamp.UnixNano(),
+		)
+	}
+
+	params := url.Values{}
+	params.Set("org", s.config.Org)
+	params.Set("bucket", s.config.Bucket)
+	params.Set("precision", "ns")
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+	defer cancel()
+
+	resp, err := s.do(ctx, "/api/v2/write?"+params.Encode(), "text/plain; charset=utf-8", body.String())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// GetSystemMetrics retrieves system metrics within a time range, averaged
+// into 1-minute or 1-hour windows for longer ranges
+func (s *InfluxStore) GetSystemMetrics(start, end time.Time, limit int) ([]*types.SystemMetrics, error) {
+	var query strings.Builder
+	fmt.Fprintf(&query, "from(bucket: %q)\n", s.config.Bucket)
+	fmt.Fprintf(&query, "  |> range(start: %s, stop: %s)\n", start.UTC().Format(time.RFC3339Nano), end.UTC().Format(time.RFC3339Nano))
+	fmt.Fprintf(&query, "  |> filter(fn: (r) => r._measurement == %q and r.host == %q)\n", influxMeasurement, s.host)
+	if window := influxWindow(end.Sub(start)); window != "" {
+		fmt.Fprintf(&query, "  |> aggregateWindow(every: %s, fn: mean, createEmpty: false)\n", window)
+	}
+	query.WriteString("  |> pivot(rowKey: [\"_time\"], columnKey: [\"_field\"], valueColumn: \"_value\")\n")
+	query.WriteString("  |> sort(columns: [\"_time\"], desc: true)\n")
+	if limit > 0 {
+		fmt.Fprintf(&query, "  |> limit(n: %d)\n", limit)
+	}
+
+	params := url.Values{}
+	params.Set("org", s.config.Org)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+	defer cancel()
+
+	resp, err := s.do(ctx, "/api/v2/query?"+params.Encode(), "application/vnd.flux", query.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve system metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	return parseInfluxCSV(resp.Body)
+}
+
+// do sends an authenticated request to the InfluxDB API
+func (s *InfluxStore) do(ctx context.Context, path, contentType, body string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(s.config.URL, "/")+path, bytes.NewBufferString(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/csv")
+	if s.config.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.config.Token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("influxdb returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
+
+// influxWindow returns the aggregation window for a query range, matching
+// the tiers used for SQL storage
+func influxWindow(span time.Duration) string {
+	switch {
+	case span <= 6*time.Hour:
+		return ""
+	case span <= 7*24*time.Hour:
+		return "1m"
+	default:
+		return "1h"
+	}
+}
+
+// parseInfluxCSV parses pivoted annotated CSV query results. Each table
+// in the response starts with its own header row.
+func parseInfluxCSV(r io.Reader) ([]*types.SystemMetrics, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	var columns map[string]int
+	var metrics []*types.SystemMetrics
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse influxdb response: %v", err)
+		}
+
+		if isInfluxHeader(row) {
+			columns = make(map[string]int, len(row))
+			for i, name := range row {
+				columns[name] = i
+			}
+			continue
+		}
+		if columns == nil {
+			continue
+		}
+
+		field := func(name string) float64 {
+			i, ok := columns[name]
+			if !ok || i >= len(row) {
+				return 0
+			}
+			value, _ := strconv.ParseFloat(row[i], 64)
+			return value
+		}
+
+		timestamp, err := time.Parse(time.RFC3339Nano, row[columns["_time"]])
+		if err != nil {
+			continue
+		}
+
+		metrics = append(metrics, &types.SystemMetrics{
+			Timestamp:   timestamp,
+			CPUUsage:    field("cpu_usage"),
+			MemoryUsage: field("memory_usage"),
+			DiskIO: types.DiskIO{
+				ReadBytes: uint64(field("disk_io") * 1024 * 1024),
+			},
+			NetworkIO: types.NetworkIO{
+				BytesSent: uint64(field("network_io") * 1024 * 1024),
+			},
+			LoadAvg: types.LoadAvg{
+				Load1: field("load_avg"),
+			},
+		})
+	}
+
+	return metrics, nil
+}
+
+// isInfluxHeader reports whether a CSV row is a table header row
+func isInfluxHeader(row []string) bool {
+	for _, column := range row {
+		if column == "_time" {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeTagValue escapes a tag value for the line protocol
+func escapeTagValue(value string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(value)
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+// influxRequest is a request received by the fake InfluxDB server
+type influxRequest struct {
+	path          string
+	query         string
+	authorization string
+	body          string
+}
+
+// newInfluxServer starts a fake InfluxDB server answering every request
+// with status and recording what it received
+func newInfluxServer(t *testing.T, status int) (*httptest.Server, func() []influxRequest) {
+	t.Helper()
+
+	var mutex sync.Mutex
+	var requests []influxRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		requests = append(requests, influxRequest{
+			path:          r.URL.Path,
+			query:         r.URL.RawQuery,
+			authorization: r.Header.Get("Authorization"),
+			body:          string(body),
+		})
+		mutex.Unlock()
+
+		w.WriteHeader(status)
+		if status >= 300 {
+			io.WriteString(w, `{"code":"unauthorized","message":"unauthorized access"}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []influxRequest {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]influxRequest(nil), requests...)
+	}
+}
+
+func newTestInfluxStore(t *testing.T, url string) *InfluxStore {
+	t.Helper()
+
+	store, err := NewInfluxStore(config.TimeSeriesConfig{
+		Backend: "influxdb",
+		URL:     url,
+		Token:   "secret",
+		Org:     "arcron",
+		Bucket:  "metrics",
+	})
+	if err != nil {
+		t.Fatalf("NewInfluxStore() error = %v", err)
+	}
+	store.host = "web 1"
+	return store
+}
+
+func TestInfluxStoreSystemMetrics(t *testing.T) {
+	server, requests := newInfluxServer(t, http.StatusNoContent)
+	store := newTestInfluxStore(t, server.URL)
+
+	timestamp := time.Unix(1700000000, 5)
+	err := store.StoreSystemMetrics(&types.SystemMetrics{
+		Timestamp:   timestamp,
+		CPUUsage:    42.5,
+		MemoryUsage: 60,
+		DiskIO:      types.DiskIO{ReadBytes: 1024 * 1024, WriteBytes: 1024 * 1024},
+		NetworkIO:   types.NetworkIO{BytesSent: 512 * 1024, BytesRecv: 512 * 1024},
+		LoadAvg:     types.LoadAvg{Load1: 1.5},
+	})
+	if err != nil {
+		t.Fatalf("StoreSystemMetrics() error = %v", err)
+	}
+
+	received := requests()
+	if len(received) != 1 {
+		t.Fatalf("received %d requests, want 1", len(received))
+	}
+	request := received[0]
+	if request.path != "/api/v2/write" {
+		t.Errorf("path = %q, want /api/v2/write", request.path)
+	}
+	for _, param := range []string{"org=arcron", "bucket=metrics", "precision=ns"} {
+		if !strings.Contains(request.query, param) {
+			t.Errorf("query %q is missing %s", request.query, param)
+		}
+	}
+	if request.authorization != "Token secret" {
+		t.Errorf("Authorization = %q, want %q", request.authorization, "Token secret")
+	}
+
+	want := `system_metrics,host=web\ 1 cpu_usage=42.5,memory_usage=60,disk_io=2,network_io=1,load_avg=1.5 1700000000000000005` + "\n"
+	if request.body != want {
+		t.Errorf("body = %q, want %q", request.body, want)
+	}
+}
+
+func TestInfluxStoreErrorStatus(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusBadRequest, http.StatusInternalServerError} {
+		server, _ := newInfluxServer(t, status)
+		store := newTestInfluxStore(t, server.URL)
+
+		err := store.StoreSystemMetrics(&types.SystemMetrics{Timestamp: time.Now()})
+		if err == nil {
+			t.Errorf("StoreSystemMetrics() with status %d returned no error", status)
+			continue
+		}
+		if !strings.Contains(err.Error(), fmt.Sprintf("status %d", status)) {
+			t.Errorf("StoreSystemMetrics() error = %v, want the status", err)
+		}
+
+		if _, err := store.GetSystemMetrics(time.Now().Add(-time.Hour), time.Now(), 10); err == nil {
+			t.Errorf("GetSystemMetrics() with status %d returned no error", status)
+		}
+	}
+}
+
+func TestInfluxStoreTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	store := newTestInfluxStore(t, server.URL)
+	store.config.Timeout = 50 * time.Millisecond
+
+	started := time.Now()
+	if err := store.StoreSystemMetrics(&types.SystemMetrics{Timestamp: time.Now()}); err == nil {
+		t.Error("StoreSystemMetrics() returned no error from a server that never answers")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("StoreSystemMetrics() took %v, want it bounded by the timeout", elapsed)
+	}
+}
+
+func TestInfluxWritesBatched(t *testing.T) {
+	server, requests := newInfluxServer(t, http.StatusNoContent)
+	cfg := config.DatabaseConfig{
+		Driver:   "sqlite",
+		DSN:      filepath.Join(t.TempDir(), "arcron.db"),
+		MaxConns: 2,
+		TimeSeries: config.TimeSeriesConfig{
+			Backend: "influxdb",
+			URL:     server.URL,
+			Org:     "arcron",
+			Bucket:  "metrics",
+		},
+		Batch: config.BatchConfig{Size: 3, FlushInterval: time.Hour, QueueSize: 10},
+	}
+	store, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := store.StoreSystemMetrics(&types.SystemMetrics{Timestamp: now.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatalf("StoreSystemMetrics() error = %v", err)
+		}
+	}
+
+	// A full batch goes out in one request, the rest is written on Close
+	deadline := time.Now().Add(5 * time.Second)
+	for len(requests()) < 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	store.Close()
+
+	received := requests()
+	if len(received) != 2 {
+		t.Fatalf("received %d requests, want 2", len(received))
+	}
+	for i, want := range []int{3, 1} {
+		if lines := strings.Count(received[i].body, "\n"); lines != want {
+			t.Errorf("request %d wrote %d lines, want %d", i, lines, want)
+		}
+	}
+}
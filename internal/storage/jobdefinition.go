@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"gorm.io/gorm"
+)
+
+// JobDefinitionRecord represents a job synced through the API in the
+// database, as its settings in JSON
+type JobDefinitionRecord struct {
+	ID         uint   `gorm:"primaryKey"`
+	Name       string `gorm:"uniqueIndex;not null"`
+	Definition string `gorm:"type:text;not null"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// SaveJobDefinitions replaces the jobs synced through the API
+func (s *Storage) SaveJobDefinitions(jobs []config.JobConfig) error {
+	defer queryDuration.ObserveSince(time.Now(), "save_job_definitions")
+
+	records := make([]JobDefinitionRecord, 0, len(jobs))
+	for _, job := range jobs {
+		settings, err := config.JobSettings(job)
+		if err != nil {
+			return fmt.Errorf("failed to encode job %s: %v", job.Name, err)
+		}
+		data, err := json.Marshal(settings)
+		if err != nil {
+			return fmt.Errorf("failed to encode job %s: %v", job.Name, err)
+		}
+		records = append(records, JobDefinitionRecord{Name: job.Name, Definition: string(data)})
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&JobDefinitionRecord{}).Error; err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save job definitions: %v", err)
+	}
+
+	return nil
+}
+
+// GetJobDefinitions retrieves the jobs synced through the API, in name
+// order
+func (s *Storage) GetJobDefinitions() ([]config.JobConfig, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_job_definitions")
+
+	var records []JobDefinitionRecord
+	if err := s.db.Order("name").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get job definitions: %v", err)
+	}
+
+	jobs := make([]config.JobConfig, 0, len(records))
+	for _, record := range records {
+		var settings map[string]interface{}
+		if err := json.Unmarshal([]byte(record.Definition), &settings); err != nil {
+			return nil, fmt.Errorf("failed to decode job %s: %v", record.Name, err)
+		}
+		job, err := config.DecodeJob(settings)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode job %s: %v", record.Name, err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+func TestJobDefinitions(t *testing.T) {
+	store := newTestStorage(t)
+
+	jobs := []config.JobConfig{
+		{Name: "report", Command: "report.sh", Schedule: "@hourly", Timeout: 10 * time.Minute},
+		{Name: "backup", Command: "backup.sh", Schedule: "@daily", Labels: map[string]string{"team": "ops"}},
+	}
+	if err := store.SaveJobDefinitions(jobs); err != nil {
+		t.Fatalf("SaveJobDefinitions() error = %v", err)
+	}
+	saved, err := store.GetJobDefinitions()
+	if err != nil {
+		t.Fatalf("GetJobDefinitions() error = %v", err)
+	}
+	if len(saved) != 2 || saved[0].Name != "backup" || saved[0].Labels["team"] != "ops" || saved[1].Timeout != 10*time.Minute {
+		t.Errorf("GetJobDefinitions() = %+v, want both jobs in name order", saved)
+	}
+
+	if err := store.SaveJobDefinitions(jobs[:1]); err != nil {
+		t.Fatalf("SaveJobDefinitions() error = %v", err)
+	}
+	if saved, err = store.GetJobDefinitions(); err != nil || len(saved) != 1 || saved[0].Name != "report" {
+		t.Errorf("GetJobDefinitions() = %+v, %v, want only the jobs of the last save", saved, err)
+	}
+}
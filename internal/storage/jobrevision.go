@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+	"gorm.io/gorm"
+)
+
+// JobRevisionRecord represents a revision of a job in the database. The
+// definition and diff are stored as JSON.
+type JobRevisionRecord struct {
+	ID         uint   `gorm:"primaryKey"`
+	JobName    string `gorm:"uniqueIndex:idx_job_revision;not null"`
+	Revision   int    `gorm:"uniqueIndex:idx_job_revision;not null"`
+	Action     string `gorm:"not null"`
+	Author     string
+	Source     string
+	Definition string `gorm:"type:text"`
+	Diff       string `gorm:"type:text"`
+	RollbackOf int
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+// StoreJobRevision stores a revision of a job, numbered after the latest
+// revision of the job, and sets its ID and number
+func (s *Storage) StoreJobRevision(revision *types.JobRevision) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_job_revision")
+
+	record := &JobRevisionRecord{
+		JobName:    revision.JobName,
+		Action:     revision.Action,
+		Author:     revision.Author,
+		Source:     revision.Source,
+		RollbackOf: revision.RollbackOf,
+		CreatedAt:  revision.CreatedAt,
+	}
+	if revision.Definition != nil {
+		data, err := json.Marshal(revision.Definition)
+		if err != nil {
+			return fmt.Errorf("failed to encode job revision: %v", err)
+		}
+		record.Definition = string(data)
+	}
+	if len(revision.Diff) > 0 {
+		data, err := json.Marshal(revision.Diff)
+		if err != nil {
+			return fmt.Errorf("failed to encode job revision: %v", err)
+		}
+		record.Diff = string(data)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		err := tx.Model(&JobRevisionRecord{}).Where("job_name = ?", revision.JobName).
+			Select("COALESCE(MAX(revision), 0)").Scan(&latest).Error
+		if err != nil {
+			return err
+		}
+		record.Revision = latest + 1
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store job revision: %v", err)
+	}
+
+	revision.ID = record.ID
+	revision.Revision = record.Revision
+	revision.CreatedAt = record.CreatedAt
+	return nil
+}
+
+// GetJobRevisions retrieves the revisions of a job, newest first, at most
+// limit if positive
+func (s *Storage) GetJobRevisions(jobName string, limit int) ([]*types.JobRevision, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_job_revisions")
+
+	query := s.db.Where("job_name = ?", jobName).Order("revision DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var records []JobRevisionRecord
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve job revisions: %v", err)
+	}
+	return jobRevisions(records)
+}
+
+// GetJobRevision retrieves a revision of a job, or nil if it was not
+// recorded
+func (s *Storage) GetJobRevision(jobName string, revision int) (*types.JobRevision, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_job_revision")
+
+	var records []JobRevisionRecord
+	if err := s.db.Where("job_name = ? AND revision = ?", jobName, revision).Limit(1).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve job revision: %v", err)
+	}
+	revisions, err := jobRevisions(records)
+	if err != nil || len(revisions) == 0 {
+		return nil, err
+	}
+	return revisions[0], nil
+}
+
+// GetLatestJobRevision retrieves the latest revision of a job, or nil if
+// none was recorded
+func (s *Storage) GetLatestJobRevision(jobName string) (*types.JobRevision, error) {
+	revisions, err := s.GetJobRevisions(jobName, 1)
+	if err != nil || len(revisions) == 0 {
+		return nil, err
+	}
+	return revisions[0], nil
+}
+
+// GetLatestJobRevisions retrieves the latest revision of every job with
+// revisions, in job name order
+func (s *Storage) GetLatestJobRevisions() ([]*types.JobRevision, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_latest_job_revisions")
+
+	latest := s.db.Model(&JobRevisionRecord{}).Select("job_name, MAX(revision) AS revision").Group("job_name")
+	var records []JobRevisionRecord
+	err := s.db.Joins("JOIN (?) AS latest ON latest.job_name = job_revision_records.job_name AND latest.revision = job_revision_records.revision", latest).
+		Order("job_revision_records.job_name").Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve job revisions: %v", err)
+	}
+	return jobRevisions(records)
+}
+
+// jobRevisions converts revision records, decoding their JSON columns
+func jobRevisions(records []JobRevisionRecord) ([]*types.JobRevision, error) {
+	revisions := make([]*types.JobRevision, len(records))
+	for i, record := range records {
+		revision := &types.JobRevision{
+			ID:         record.ID,
+			JobName:    record.JobName,
+			Revision:   record.Revision,
+			Action:     record.Action,
+			Author:     record.Author,
+			Source:     record.Source,
+			RollbackOf: record.RollbackOf,
+			CreatedAt:  record.CreatedAt,
+		}
+		if record.Definition != "" {
+			if err := json.Unmarshal([]byte(record.Definition), &revision.Definition); err != nil {
+				return nil, fmt.Errorf("failed to decode revision %d of job %s: %v", record.Revision, record.JobName, err)
+			}
+		}
+		if record.Diff != "" {
+			if err := json.Unmarshal([]byte(record.Diff), &revision.Diff); err != nil {
+				return nil, fmt.Errorf("failed to decode revision %d of job %s: %v", record.Revision, record.JobName, err)
+			}
+		}
+		revisions[i] = revision
+	}
+	return revisions, nil
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestJobRevisions(t *testing.T) {
+	store := newTestStorage(t)
+
+	revisions := []*types.JobRevision{
+		{JobName: "backup", Action: types.RevisionCreate, Author: "config", Definition: map[string]interface{}{"schedule": "@daily"}},
+		{JobName: "report", Action: types.RevisionCreate, Author: "admin", Source: "api"},
+		{JobName: "backup", Action: types.RevisionUpdate, Author: "config", Definition: map[string]interface{}{"schedule": "@hourly"},
+			Diff: []types.FieldChange{{Field: "schedule", Before: "@daily", After: "@hourly"}}},
+		{JobName: "backup", Action: types.RevisionDelete, Author: "config"},
+	}
+	for _, revision := range revisions {
+		if err := store.StoreJobRevision(revision); err != nil {
+			t.Fatalf("StoreJobRevision() error = %v", err)
+		}
+	}
+	if revisions[3].Revision != 3 || revisions[1].Revision != 1 {
+		t.Errorf("revisions numbered %d and %d, want 3 and 1 as numbered per job", revisions[3].Revision, revisions[1].Revision)
+	}
+
+	stored, err := store.GetJobRevisions("backup", 2)
+	if err != nil {
+		t.Fatalf("GetJobRevisions() error = %v", err)
+	}
+	if len(stored) != 2 || stored[0].Action != types.RevisionDelete || stored[1].Diff[0].After != "@hourly" {
+		t.Errorf("GetJobRevisions() = %+v, want the latest two, newest first", stored)
+	}
+
+	revision, err := store.GetJobRevision("backup", 1)
+	if err != nil || revision == nil || revision.Definition["schedule"] != "@daily" {
+		t.Errorf("GetJobRevision() = %+v, %v, want the first definition", revision, err)
+	}
+	if revision, err = store.GetJobRevision("backup", 9); err != nil || revision != nil {
+		t.Errorf("GetJobRevision() = %+v, %v, want nil for a missing revision", revision, err)
+	}
+
+	latest, err := store.GetLatestJobRevisions()
+	if err != nil {
+		t.Fatalf("GetLatestJobRevisions() error = %v", err)
+	}
+	if len(latest) != 2 || latest[0].Revision != 3 || latest[1].JobName != "report" {
+		t.Errorf("GetLatestJobRevisions() = %+v, want the latest of each job", latest)
+	}
+}
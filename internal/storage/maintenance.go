@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// maintenanceRecordID is the ID of the single maintenance state row
+const maintenanceRecordID = 1
+
+// MaintenanceRecord represents the scheduler's maintenance state in the
+// database
+type MaintenanceRecord struct {
+	ID        uint `gorm:"primaryKey"`
+	Paused    bool `gorm:"not null"`
+	Reason    string
+	PausedBy  string
+	Since     time.Time
+	UpdatedAt time.Time
+}
+
+// SaveMaintenanceState stores the maintenance state, replacing the
+// previous one
+func (s *Storage) SaveMaintenanceState(state *types.MaintenanceState) error {
+	defer queryDuration.ObserveSince(time.Now(), "save_maintenance_state")
+
+	record := &MaintenanceRecord{
+		ID:       maintenanceRecordID,
+		Paused:   state.Paused,
+		Reason:   state.Reason,
+		PausedBy: state.PausedBy,
+		Since:    state.Since,
+	}
+	if err := s.db.Save(record).Error; err != nil {
+		return fmt.Errorf("failed to save maintenance state: %v", err)
+	}
+
+	return nil
+}
+
+// GetMaintenanceState retrieves the maintenance state, or nil if it was
+// never saved
+func (s *Storage) GetMaintenanceState() (*types.MaintenanceState, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_maintenance_state")
+
+	var record MaintenanceRecord
+	result := s.db.Limit(1).Find(&record, maintenanceRecordID)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get maintenance state: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &types.MaintenanceState{
+		Paused:   record.Paused,
+		Reason:   record.Reason,
+		PausedBy: record.PausedBy,
+		Since:    record.Since,
+	}, nil
+}
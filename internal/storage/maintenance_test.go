@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestMaintenanceState(t *testing.T) {
+	store := newTestStorage(t)
+
+	state, err := store.GetMaintenanceState()
+	if err != nil || state != nil {
+		t.Fatalf("GetMaintenanceState() before save = %+v, %v, want nil", state, err)
+	}
+
+	paused := &types.MaintenanceState{Paused: true, Reason: "deploy", PausedBy: "ops", Since: time.Now().UTC()}
+	if err := store.SaveMaintenanceState(paused); err != nil {
+		t.Fatalf("SaveMaintenanceState() error = %v", err)
+	}
+	if err := store.SaveMaintenanceState(&types.MaintenanceState{Paused: true, Reason: "incident", Since: paused.Since}); err != nil {
+		t.Fatalf("SaveMaintenanceState() error = %v", err)
+	}
+
+	state, err = store.GetMaintenanceState()
+	if err != nil {
+		t.Fatalf("GetMaintenanceState() error = %v", err)
+	}
+	if !state.Paused || state.Reason != "incident" || !state.Since.Equal(paused.Since) {
+		t.Errorf("GetMaintenanceState() = %+v, want the latest state", state)
+	}
+}
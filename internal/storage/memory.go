@@ -0,0 +1,592 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// MemoryStore keeps job executions, metrics, predictions and artifacts in
+// memory. It implements the same repositories as Storage and is meant for
+// tests and short-lived setups that need no database.
+type MemoryStore struct {
+	mutex       sync.RWMutex
+	executions  []*types.JobExecution
+	metrics     []*types.SystemMetrics
+	predictions []*types.PredictionOutcome
+	forecasts   []*types.ForecastOutcome
+	artifacts   []*types.Artifact
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// StoreJobExecution stores a job execution, replacing the stored execution
+// with the same ID
+func (m *MemoryStore) StoreJobExecution(execution *types.JobExecution) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stored := *execution
+	if stored.Attempt == 0 {
+		stored.Attempt = 1
+	}
+	for i, existing := range m.executions {
+		if existing.ID == stored.ID {
+			// As in the database, a run's identity is fixed on insert
+			stored.JobName = existing.JobName
+			stored.Namespace = existing.Namespace
+			stored.StartTime = existing.StartTime
+			stored.Attempt = existing.Attempt
+			stored.ParentExecutionID = existing.ParentExecutionID
+			m.executions[i] = &stored
+			return nil
+		}
+	}
+	for _, existing := range m.executions {
+		if stored.ParentExecutionID != "" && existing.ParentExecutionID == stored.ParentExecutionID &&
+			existing.Attempt == stored.Attempt {
+			return fmt.Errorf("failed to store job execution: attempt %d of %s already stored",
+				stored.Attempt, stored.ParentExecutionID)
+		}
+	}
+	m.executions = append(m.executions, &stored)
+	return nil
+}
+
+// GetJobExecution retrieves a job execution by ID, or nil if there is none
+func (m *MemoryStore) GetJobExecution(id string) (*types.JobExecution, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, execution := range m.executions {
+		if execution.ID == id {
+			found := *execution
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetJobExecutions retrieves the executions of a job, newest first
+func (m *MemoryStore) GetJobExecutions(jobName string, limit int) ([]*types.JobExecution, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	executions := m.jobExecutions(jobName)
+	sort.SliceStable(executions, func(i, j int) bool {
+		return executions[i].StartTime.After(executions[j].StartTime)
+	})
+	if limit > 0 && len(executions) > limit {
+		executions = executions[:limit]
+	}
+	return executions, nil
+}
+
+// GetPreviousJobExecutions retrieves the finished executions of a job that
+// started before a time, newest first
+func (m *MemoryStore) GetPreviousJobExecutions(jobName string, before time.Time, limit int) ([]*types.JobExecution, error) {
+	executions, err := m.GetJobExecutions(jobName, 0)
+	if err != nil {
+		return nil, err
+	}
+	var previous []*types.JobExecution
+	for _, execution := range executions {
+		if limit > 0 && len(previous) == limit {
+			break
+		}
+		finished := execution.Status == types.StatusCompleted || execution.Status == types.StatusFailed
+		if finished && execution.StartTime.Before(before) {
+			previous = append(previous, execution)
+		}
+	}
+	return previous, nil
+}
+
+// EachJobExecution calls fn with every execution of a job, oldest first.
+// It stops at the first error fn returns.
+func (m *MemoryStore) EachJobExecution(jobName string, fn func(*types.JobExecution) error) error {
+	m.mutex.RLock()
+	executions := m.jobExecutions(jobName)
+	m.mutex.RUnlock()
+
+	sort.SliceStable(executions, func(i, j int) bool {
+		return executions[i].StartTime.Before(executions[j].StartTime)
+	})
+	for _, execution := range executions {
+		if err := fn(execution); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetExecutionNear returns the first execution of a job that started
+// within window of the given time, or nil if there is none
+func (m *MemoryStore) GetExecutionNear(jobName string, at time.Time, window time.Duration) (*types.JobExecution, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var first *types.JobExecution
+	for _, execution := range m.jobExecutions(jobName) {
+		if execution.StartTime.Before(at.Add(-window)) || execution.StartTime.After(at.Add(window)) {
+			continue
+		}
+		if first == nil || execution.StartTime.Before(first.StartTime) {
+			first = execution
+		}
+	}
+	return first, nil
+}
+
+// GetJobStatistics computes the same statistics for a job as Storage
+func (m *MemoryStore) GetJobStatistics(filter StatisticsFilter) (map[string]interface{}, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var executions []*types.JobExecution
+	for _, execution := range m.jobExecutions(filter.JobName) {
+		if (filter.Since.IsZero() || !execution.StartTime.Before(filter.Since)) &&
+			(filter.Until.IsZero() || !execution.StartTime.After(filter.Until)) {
+			executions = append(executions, execution)
+		}
+	}
+	sort.SliceStable(executions, func(i, j int) bool {
+		if executions[i].StartTime.Equal(executions[j].StartTime) {
+			return executions[i].ID < executions[j].ID
+		}
+		return executions[i].StartTime.Before(executions[j].StartTime)
+	})
+
+	var successCount, failureCount, runCount int64
+	var durations []float64
+	recovered := make(map[string]bool)
+	retried := make(map[string]bool)
+	for _, execution := range m.executions {
+		// Retries count wherever they fall, as in the database subquery
+		if execution.ParentExecutionID != "" && (execution.Status == types.StatusCompleted ||
+			execution.Status == types.StatusPending || execution.Status == types.StatusRunning) {
+			retried[execution.ParentExecutionID] = true
+		}
+	}
+	for _, execution := range executions {
+		switch execution.Status {
+		case types.StatusCompleted:
+			successCount++
+			durations = append(durations, execution.Duration)
+		case types.StatusFailed:
+			failureCount++
+		}
+
+		if execution.ParentExecutionID == "" {
+			runCount++
+		} else if execution.Status == types.StatusCompleted {
+			recovered[execution.ParentExecutionID] = true
+		}
+	}
+
+	var permanentCount int64
+	for _, execution := range executions {
+		if execution.ParentExecutionID == "" && execution.Status == types.StatusFailed && !retried[execution.ID] {
+			permanentCount++
+		}
+	}
+
+	totalCount := int64(len(executions))
+	successRate, avgDuration, runSuccessRate := 0.0, 0.0, 0.0
+	if totalCount > 0 {
+		successRate = float64(successCount) / float64(totalCount) * 100
+	}
+	if len(durations) > 0 {
+		for _, duration := range durations {
+			avgDuration += duration
+		}
+		avgDuration /= float64(len(durations))
+	}
+	if runCount > 0 {
+		runSuccessRate = float64(runCount-permanentCount) / float64(runCount) * 100
+	}
+
+	return map[string]interface{}{
+		"total_executions":     totalCount,
+		"successful":           successCount,
+		"failed":               failureCount,
+		"success_rate":         successRate,
+		"avg_duration":         avgDuration,
+		"runs":                 runCount,
+		"recovered":            int64(len(recovered)),
+		"failed_permanently":   permanentCount,
+		"run_success_rate":     runSuccessRate,
+		"duration_percentiles": memoryPercentiles(durations),
+		"duration_trend":       memoryTrend(executions),
+		"failure_streaks":      memoryStreaks(executions),
+		"success_heatmap":      memoryHeatmap(executions),
+		"start_conditions":     memoryStartConditions(executions),
+		"queue_wait":           memoryQueueWait(executions),
+	}, nil
+}
+
+// memoryStartConditions averages the start metrics of completed and
+// failed executions
+func memoryStartConditions(executions []*types.JobExecution) []types.StartConditions {
+	byStatus := make(map[types.JobStatus]*types.StartConditions)
+	for _, execution := range executions {
+		snapshot := execution.StartMetrics
+		if snapshot == nil || (execution.Status != types.StatusCompleted && execution.Status != types.StatusFailed) {
+			continue
+		}
+		conditions, ok := byStatus[execution.Status]
+		if !ok {
+			conditions = &types.StartConditions{Status: string(execution.Status)}
+			byStatus[execution.Status] = conditions
+		}
+		n := float64(conditions.Executions)
+		conditions.AvgCPUUsage = (conditions.AvgCPUUsage*n + snapshot.CPUUsage) / (n + 1)
+		conditions.AvgMemoryUsage = (conditions.AvgMemoryUsage*n + snapshot.MemoryUsage) / (n + 1)
+		conditions.AvgLoad1 = (conditions.AvgLoad1*n + snapshot.Load1) / (n + 1)
+		conditions.MaxCPUUsage = max(conditions.MaxCPUUsage, snapshot.CPUUsage)
+		conditions.Executions++
+	}
+
+	result := []types.StartConditions{}
+	for _, status := range []types.JobStatus{types.StatusCompleted, types.StatusFailed} {
+		if conditions, ok := byStatus[status]; ok {
+			result = append(result, *conditions)
+		}
+	}
+	return result
+}
+
+// memoryQueueWait summarizes the queue waits of executions that are no
+// longer pending
+func memoryQueueWait(executions []*types.JobExecution) types.QueueWaitStatistics {
+	var waits []float64
+	for _, execution := range executions {
+		if !execution.QueuedAt.IsZero() && execution.Status != types.StatusPending {
+			waits = append(waits, execution.QueueWait)
+		}
+	}
+	sort.Float64s(waits)
+
+	wait := types.QueueWaitStatistics{Executions: int64(len(waits))}
+	if len(waits) == 0 {
+		return wait
+	}
+	for _, value := range waits {
+		wait.Avg += value
+	}
+	wait.Avg /= float64(len(waits))
+	wait.P95 = memoryPercentiles(waits).P95
+	wait.Max = waits[len(waits)-1]
+	return wait
+}
+
+// memoryPercentiles picks nearest-rank percentiles of durations
+func memoryPercentiles(durations []float64) types.DurationPercentiles {
+	sorted := append([]float64(nil), durations...)
+	sort.Float64s(sorted)
+
+	percentile := func(p int) float64 {
+		for rank := 1; rank <= len(sorted); rank++ {
+			if rank*100 >= len(sorted)*p {
+				return sorted[rank-1]
+			}
+		}
+		return 0
+	}
+	return types.DurationPercentiles{P50: percentile(50), P95: percentile(95), P99: percentile(99)}
+}
+
+// memoryTrend summarizes completed executions, sorted by start time, per
+// day
+func memoryTrend(executions []*types.JobExecution) []types.DurationTrendPoint {
+	trend := []types.DurationTrendPoint{}
+	for _, execution := range executions {
+		if execution.Status != types.StatusCompleted {
+			continue
+		}
+		day := execution.StartTime.UTC().Format("2006-01-02")
+		if len(trend) == 0 || trend[len(trend)-1].Day != day {
+			trend = append(trend, types.DurationTrendPoint{Day: day})
+		}
+		point := &trend[len(trend)-1]
+		point.AvgDuration = (point.AvgDuration*float64(point.Executions) + execution.Duration) / float64(point.Executions+1)
+		point.MaxDuration = max(point.MaxDuration, execution.Duration)
+		point.Executions++
+	}
+	return trend
+}
+
+// memoryStreaks counts consecutive failures among finished executions
+// sorted by start time
+func memoryStreaks(executions []*types.JobExecution) types.FailureStreaks {
+	var streaks types.FailureStreaks
+	for _, execution := range executions {
+		switch execution.Status {
+		case types.StatusFailed:
+			streaks.Current++
+			streaks.Longest = max(streaks.Longest, streaks.Current)
+		case types.StatusCompleted:
+			streaks.Current = 0
+		}
+	}
+	return streaks
+}
+
+// memoryHeatmap counts executions and successes per weekday and hour
+func memoryHeatmap(executions []*types.JobExecution) []types.HeatmapCell {
+	cells := make(map[[2]int]*types.HeatmapCell)
+	for _, execution := range executions {
+		start := execution.StartTime.UTC()
+		key := [2]int{int(start.Weekday()), start.Hour()}
+		cell, ok := cells[key]
+		if !ok {
+			cell = &types.HeatmapCell{Weekday: key[0], Hour: key[1]}
+			cells[key] = cell
+		}
+		cell.Executions++
+		if execution.Status == types.StatusCompleted {
+			cell.Successful++
+		}
+	}
+
+	heatmap := []types.HeatmapCell{}
+	for _, cell := range cells {
+		cell.SuccessRate = 100 * float64(cell.Successful) / float64(cell.Executions)
+		heatmap = append(heatmap, *cell)
+	}
+	sort.Slice(heatmap, func(i, j int) bool {
+		if heatmap[i].Weekday != heatmap[j].Weekday {
+			return heatmap[i].Weekday < heatmap[j].Weekday
+		}
+		return heatmap[i].Hour < heatmap[j].Hour
+	})
+	return heatmap
+}
+
+// jobExecutions returns copies of the executions of a job. It must be
+// called with the lock held.
+func (m *MemoryStore) jobExecutions(jobName string) []*types.JobExecution {
+	var executions []*types.JobExecution
+	for _, execution := range m.executions {
+		if execution.JobName == jobName {
+			found := *execution
+			executions = append(executions, &found)
+		}
+	}
+	return executions
+}
+
+// StoreSystemMetrics stores system metrics
+func (m *MemoryStore) StoreSystemMetrics(metrics *types.SystemMetrics) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stored := *metrics
+	m.metrics = append(m.metrics, &stored)
+	return nil
+}
+
+// GetSystemMetrics retrieves system metrics within a time range, newest
+// first
+func (m *MemoryStore) GetSystemMetrics(start, end time.Time, limit int) ([]*types.SystemMetrics, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var metrics []*types.SystemMetrics
+	for _, sample := range m.metrics {
+		if sample.Timestamp.Before(start) || sample.Timestamp.After(end) {
+			continue
+		}
+		found := *sample
+		metrics = append(metrics, &found)
+	}
+	sort.SliceStable(metrics, func(i, j int) bool {
+		return metrics[i].Timestamp.After(metrics[j].Timestamp)
+	})
+	if limit > 0 && len(metrics) > limit {
+		metrics = metrics[:limit]
+	}
+	return metrics, nil
+}
+
+// StoreMLPrediction stores an ML prediction and sets its ID
+func (m *MemoryStore) StoreMLPrediction(prediction *types.Prediction) error {
+	return m.StoreMLPredictions([]*types.Prediction{prediction})
+}
+
+// StoreMLPredictions stores a batch of ML predictions and sets their IDs
+func (m *MemoryStore) StoreMLPredictions(predictions []*types.Prediction) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, prediction := range predictions {
+		prediction.ID = uint(len(m.predictions) + 1)
+		m.predictions = append(m.predictions, &types.PredictionOutcome{Prediction: *prediction})
+	}
+	return nil
+}
+
+// GetPendingPredictions retrieves predictions whose optimal time is before
+// the given time and that have not been evaluated yet, oldest first
+func (m *MemoryStore) GetPendingPredictions(before time.Time, limit int) ([]*types.Prediction, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var predictions []*types.Prediction
+	for _, stored := range m.predictions {
+		if stored.EvaluatedAt.IsZero() && stored.OptimalTime.Before(before) {
+			prediction := stored.Prediction
+			predictions = append(predictions, &prediction)
+		}
+	}
+	sort.SliceStable(predictions, func(i, j int) bool {
+		return predictions[i].OptimalTime.Before(predictions[j].OptimalTime)
+	})
+	if limit > 0 && len(predictions) > limit {
+		predictions = predictions[:limit]
+	}
+	return predictions, nil
+}
+
+// StorePredictionOutcome records the evaluation of a prediction
+func (m *MemoryStore) StorePredictionOutcome(outcome *types.PredictionOutcome) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, stored := range m.predictions {
+		if stored.ID == outcome.ID {
+			stored.ActualLoad = outcome.ActualLoad
+			stored.AbsError = outcome.AbsError
+			stored.JobStatus = outcome.JobStatus
+			stored.EvaluatedAt = outcome.EvaluatedAt
+		}
+	}
+	return nil
+}
+
+// GetPredictionOutcomes retrieves predictions evaluated since the given time
+func (m *MemoryStore) GetPredictionOutcomes(since time.Time) ([]*types.PredictionOutcome, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var outcomes []*types.PredictionOutcome
+	for _, stored := range m.predictions {
+		if !stored.EvaluatedAt.IsZero() && !stored.OptimalTime.Before(since) {
+			outcome := *stored
+			outcomes = append(outcomes, &outcome)
+		}
+	}
+	sort.SliceStable(outcomes, func(i, j int) bool {
+		return outcomes[i].OptimalTime.Before(outcomes[j].OptimalTime)
+	})
+	return outcomes, nil
+}
+
+// StoreForecast stores the points of a load forecast and sets their IDs
+func (m *MemoryStore) StoreForecast(forecast *types.LoadForecast) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i := range forecast.Points {
+		forecast.Points[i].ID = uint(len(m.forecasts) + 1)
+		m.forecasts = append(m.forecasts, &types.ForecastOutcome{ForecastPoint: forecast.Points[i]})
+	}
+	return nil
+}
+
+// GetPendingForecastPoints retrieves forecast points whose time is before
+// the given time and that have not been evaluated yet, oldest first
+func (m *MemoryStore) GetPendingForecastPoints(before time.Time, limit int) ([]*types.ForecastPoint, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var points []*types.ForecastPoint
+	for _, stored := range m.forecasts {
+		if stored.EvaluatedAt.IsZero() && stored.Time.Before(before) {
+			point := stored.ForecastPoint
+			points = append(points, &point)
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Time.Before(points[j].Time)
+	})
+	if limit > 0 && len(points) > limit {
+		points = points[:limit]
+	}
+	return points, nil
+}
+
+// StoreForecastOutcome records the evaluation of a forecast point
+func (m *MemoryStore) StoreForecastOutcome(outcome *types.ForecastOutcome) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, stored := range m.forecasts {
+		if stored.ID == outcome.ID {
+			stored.ActualLoad = outcome.ActualLoad
+			stored.EvaluatedAt = outcome.EvaluatedAt
+		}
+	}
+	return nil
+}
+
+// GetForecastOutcomes retrieves forecast points evaluated since the given time
+func (m *MemoryStore) GetForecastOutcomes(since time.Time) ([]*types.ForecastOutcome, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var outcomes []*types.ForecastOutcome
+	for _, stored := range m.forecasts {
+		if !stored.EvaluatedAt.IsZero() && !stored.Time.Before(since) {
+			outcome := *stored
+			outcomes = append(outcomes, &outcome)
+		}
+	}
+	sort.SliceStable(outcomes, func(i, j int) bool {
+		return outcomes[i].Time.Before(outcomes[j].Time)
+	})
+	return outcomes, nil
+}
+
+// StoreArtifact stores an artifact of an execution and sets its ID
+func (m *MemoryStore) StoreArtifact(artifact *types.Artifact) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, stored := range m.artifacts {
+		if stored.ExecutionID == artifact.ExecutionID && stored.Name == artifact.Name {
+			return fmt.Errorf("failed to store artifact: %s of %s already stored", artifact.Name, artifact.ExecutionID)
+		}
+	}
+	artifact.ID = uint(len(m.artifacts) + 1)
+	if artifact.CreatedAt.IsZero() {
+		artifact.CreatedAt = time.Now()
+	}
+	stored := *artifact
+	m.artifacts = append(m.artifacts, &stored)
+	return nil
+}
+
+// GetArtifacts retrieves the artifacts of an execution ordered by name
+func (m *MemoryStore) GetArtifacts(executionID string) ([]*types.Artifact, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var artifacts []*types.Artifact
+	for _, stored := range m.artifacts {
+		if stored.ExecutionID == executionID {
+			artifact := *stored
+			artifacts = append(artifacts, &artifact)
+		}
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].Name < artifacts[j].Name
+	})
+	return artifacts, nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestMemoryStoreMatchesStorage(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	executions := []*types.JobExecution{
+		{ID: "a", JobName: "backup", StartTime: now.Add(-3 * time.Hour), Status: types.StatusCompleted, Duration: 10, Attempt: 1},
+		{ID: "b", JobName: "backup", StartTime: now.Add(-2 * time.Hour), Status: types.StatusFailed, Attempt: 1},
+		{ID: "c", JobName: "backup", StartTime: now.Add(-2 * time.Hour), Status: types.StatusCompleted, Duration: 20,
+			Attempt: 2, RetryCount: 1, ParentExecutionID: "b"},
+		{ID: "d", JobName: "backup", StartTime: now.Add(-time.Hour), Status: types.StatusFailed, Attempt: 1},
+		{ID: "e", JobName: "report", StartTime: now, Status: types.StatusCompleted, Attempt: 1},
+	}
+
+	repos := map[string]JobExecutionRepo{"storage": newTestStorage(t), "memory": NewMemoryStore()}
+	results := make(map[string][]interface{})
+	for name, repo := range repos {
+		for _, execution := range executions {
+			if err := repo.StoreJobExecution(execution); err != nil {
+				t.Fatalf("%s: StoreJobExecution(%s) error = %v", name, execution.ID, err)
+			}
+		}
+		duplicate := &types.JobExecution{ID: "f", JobName: "backup", StartTime: now, Status: types.StatusFailed,
+			Attempt: 2, ParentExecutionID: "b"}
+		if err := repo.StoreJobExecution(duplicate); err == nil {
+			t.Errorf("%s: StoreJobExecution() stored a duplicate attempt", name)
+		}
+
+		statistics, err := repo.GetJobStatistics(StatisticsFilter{JobName: "backup"})
+		if err != nil {
+			t.Fatalf("%s: GetJobStatistics() error = %v", name, err)
+		}
+		recent, err := repo.GetJobExecutions("backup", 2)
+		if err != nil {
+			t.Fatalf("%s: GetJobExecutions() error = %v", name, err)
+		}
+		near, err := repo.GetExecutionNear("backup", now.Add(-110*time.Minute), 15*time.Minute)
+		if err != nil || near == nil {
+			t.Fatalf("%s: GetExecutionNear() = %v, %v", name, near, err)
+		}
+		previous, err := repo.GetPreviousJobExecutions("backup", now.Add(-90*time.Minute), 3)
+		if err != nil || len(previous) != 3 {
+			t.Fatalf("%s: GetPreviousJobExecutions() = %v, %v", name, previous, err)
+		}
+		results[name] = []interface{}{statistics, len(recent), recent[0].ID, near.ID, previous[2].ID}
+	}
+
+	if !reflect.DeepEqual(results["memory"], results["storage"]) {
+		t.Errorf("memory store = %v, storage = %v", results["memory"], results["storage"])
+	}
+}
+
+func TestMemoryStorePredictions(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+
+	predictions := []*types.Prediction{
+		{JobName: "backup", OptimalTime: now.Add(-time.Minute)},
+		{JobName: "backup", OptimalTime: now.Add(-time.Hour)},
+		{JobName: "backup", OptimalTime: now.Add(time.Hour)},
+	}
+	if err := store.StoreMLPredictions(predictions); err != nil {
+		t.Fatalf("StoreMLPredictions() error = %v", err)
+	}
+
+	pending, _ := store.GetPendingPredictions(now, 0)
+	if len(pending) != 2 || pending[0].ID != predictions[1].ID {
+		t.Fatalf("GetPendingPredictions() = %+v, want the two past predictions oldest first", pending)
+	}
+
+	outcome := &types.PredictionOutcome{Prediction: *pending[0], ActualLoad: 40, EvaluatedAt: now}
+	if err := store.StorePredictionOutcome(outcome); err != nil {
+		t.Fatalf("StorePredictionOutcome() error = %v", err)
+	}
+	if pending, _ = store.GetPendingPredictions(now, 0); len(pending) != 1 {
+		t.Errorf("GetPendingPredictions() after evaluation = %d predictions, want 1", len(pending))
+	}
+	outcomes, _ := store.GetPredictionOutcomes(now.Add(-2 * time.Hour))
+	if len(outcomes) != 1 || outcomes[0].ActualLoad != 40 {
+		t.Errorf("GetPredictionOutcomes() = %+v, want the evaluated prediction", outcomes)
+	}
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// GetPendingPredictions retrieves predictions whose optimal time is before
+// the given time and that have not been evaluated yet, oldest first
+func (s *Storage) GetPendingPredictions(before time.Time, limit int) ([]*types.Prediction, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_pending_predictions")
+
+	var records []MLPredictionRecord
+
+	query := s.db.Where("evaluated_at IS NULL AND optimal_time < ?", before).Order("optimal_time ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve pending predictions: %v", err)
+	}
+
+	predictions := make([]*types.Prediction, len(records))
+	for i, record := range records {
+		predictions[i] = predictionFromRecord(record)
+	}
+
+	return predictions, nil
+}
+
+// StorePredictionOutcome records the evaluation of a prediction
+func (s *Storage) StorePredictionOutcome(outcome *types.PredictionOutcome) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_prediction_outcome")
+
+	evaluatedAt := outcome.EvaluatedAt
+	result := s.db.Model(&MLPredictionRecord{}).Where("id = ?", outcome.ID).Updates(map[string]interface{}{
+		"actual_load":  outcome.ActualLoad,
+		"abs_error":    outcome.AbsError,
+		"job_status":   outcome.JobStatus,
+		"evaluated_at": &evaluatedAt,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to store prediction outcome: %v", result.Error)
+	}
+
+	return nil
+}
+
+// GetPredictionOutcomes retrieves predictions evaluated since the given time
+func (s *Storage) GetPredictionOutcomes(since time.Time) ([]*types.PredictionOutcome, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_prediction_outcomes")
+
+	var records []MLPredictionRecord
+	if err := s.reader().Where("evaluated_at IS NOT NULL AND optimal_time >= ?", since).
+		Order("optimal_time ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve prediction outcomes: %v", err)
+	}
+
+	outcomes := make([]*types.PredictionOutcome, len(records))
+	for i, record := range records {
+		outcomes[i] = &types.PredictionOutcome{
+			Prediction:  *predictionFromRecord(record),
+			ActualLoad:  record.ActualLoad,
+			AbsError:    record.AbsError,
+			JobStatus:   record.JobStatus,
+			EvaluatedAt: *record.EvaluatedAt,
+		}
+	}
+
+	return outcomes, nil
+}
+
+// GetExecutionNear returns the first execution of a job that started
+// within window of the given time, or nil if there is none
+func (s *Storage) GetExecutionNear(jobName string, at time.Time, window time.Duration) (*types.JobExecution, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_execution_near")
+
+	var records []JobExecutionRecord
+	if err := s.db.Where("job_name = ? AND start_time BETWEEN ? AND ?", jobName, at.Add(-window), at.Add(window)).
+		Order("start_time ASC").Limit(1).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve job execution: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	record := records[0]
+	return &types.JobExecution{
+		ID:        record.ID,
+		JobName:   record.JobName,
+		StartTime: record.StartTime,
+		EndTime:   record.EndTime,
+		Duration:  record.Duration,
+		Status:    types.JobStatus(record.Status),
+		ExitCode:  record.ExitCode,
+	}, nil
+}
+
+func predictionFromRecord(record MLPredictionRecord) *types.Prediction {
+	var explanation *types.Explanation
+	if record.Explanation != "" {
+		explanation = &types.Explanation{}
+		if err := json.Unmarshal([]byte(record.Explanation), explanation); err != nil {
+			explanation = nil
+		}
+	}
+
+	return &types.Prediction{
+		ID:           record.ID,
+		JobName:      record.JobName,
+		PredictedAt:  record.PredictedAt,
+		OptimalTime:  record.OptimalTime,
+		Confidence:   record.Confidence,
+		Reasoning:    record.Reasoning,
+		ExpectedLoad: record.ExpectedLoad,
+		Method:       record.Method,
+		Explanation:  explanation,
+	}
+}
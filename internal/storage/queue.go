@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+	"gorm.io/gorm"
+)
+
+// QueuedRunRecord represents a run waiting in the execution queue in the
+// database
+type QueuedRunRecord struct {
+	ID            uint      `gorm:"primaryKey"`
+	JobName       string    `gorm:"index;not null"`
+	Trigger       string    `gorm:"not null"`
+	Position      int       `gorm:"index;not null"`
+	NotBefore     time.Time `gorm:"index;not null"`
+	Reason        string
+	ExecutionID   string
+	CorrelationID string
+	CreatedAt     time.Time
+}
+
+// EnqueueRun adds a run to the end of the execution queue, setting its ID,
+// position and enqueue time
+func (s *Storage) EnqueueRun(run *types.QueuedRun) error {
+	defer queryDuration.ObserveSince(time.Now(), "enqueue_run")
+
+	record := &QueuedRunRecord{
+		JobName:       run.JobName,
+		Trigger:       run.Trigger,
+		NotBefore:     run.NotBefore,
+		Reason:        run.Reason,
+		ExecutionID:   run.ExecutionID,
+		CorrelationID: run.CorrelationID,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var last struct{ Position int }
+		if err := tx.Model(&QueuedRunRecord{}).Select("COALESCE(MAX(position), 0) AS position").Scan(&last).Error; err != nil {
+			return err
+		}
+		record.Position = last.Position + 1
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue run of job %s: %v", run.JobName, err)
+	}
+
+	run.ID = record.ID
+	run.Position = record.Position
+	run.EnqueuedAt = record.CreatedAt
+	return nil
+}
+
+// GetQueuedRuns retrieves the runs in the execution queue, in queue order
+func (s *Storage) GetQueuedRuns() ([]*types.QueuedRun, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_queued_runs")
+
+	var records []QueuedRunRecord
+	if err := s.db.Order("position, id").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get queued runs: %v", err)
+	}
+
+	runs := make([]*types.QueuedRun, len(records))
+	for i, record := range records {
+		runs[i] = &types.QueuedRun{
+			ID:            record.ID,
+			JobName:       record.JobName,
+			Trigger:       record.Trigger,
+			Position:      record.Position,
+			NotBefore:     record.NotBefore,
+			EnqueuedAt:    record.CreatedAt,
+			Reason:        record.Reason,
+			ExecutionID:   record.ExecutionID,
+			CorrelationID: record.CorrelationID,
+		}
+	}
+	return runs, nil
+}
+
+// DeleteQueuedRun removes a run from the execution queue and reports
+// whether it was still queued
+func (s *Storage) DeleteQueuedRun(id uint) (bool, error) {
+	defer queryDuration.ObserveSince(time.Now(), "delete_queued_run")
+
+	result := s.db.Delete(&QueuedRunRecord{}, id)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to delete queued run %d: %v", id, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ReorderQueuedRuns numbers the positions of queued runs in the order of
+// ids, from 1
+func (s *Storage) ReorderQueuedRuns(ids []uint) error {
+	defer queryDuration.ObserveSince(time.Now(), "reorder_queued_runs")
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for i, id := range ids {
+			if err := tx.Model(&QueuedRunRecord{}).Where("id = ?", id).Update("position", i+1).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reorder queued runs: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestExecutionQueue(t *testing.T) {
+	store := newTestStorage(t)
+
+	now := time.Now().UTC()
+	runs := []*types.QueuedRun{
+		{JobName: "backup", Trigger: types.TriggerManual, NotBefore: now},
+		{JobName: "report", Trigger: types.TriggerSmart, NotBefore: now.Add(time.Hour), Reason: "low load"},
+		{JobName: "etl", Trigger: types.TriggerRetry, NotBefore: now.Add(time.Minute), ExecutionID: "exec-2", CorrelationID: "corr"},
+	}
+	for _, run := range runs {
+		if err := store.EnqueueRun(run); err != nil {
+			t.Fatalf("EnqueueRun() error = %v", err)
+		}
+	}
+	if runs[0].Position != 1 || runs[2].Position != 3 || runs[2].ID == 0 {
+		t.Errorf("runs enqueued at positions %d and %d with ID %d, want 1 and 3 with an ID", runs[0].Position, runs[2].Position, runs[2].ID)
+	}
+
+	if err := store.ReorderQueuedRuns([]uint{runs[2].ID, runs[0].ID, runs[1].ID}); err != nil {
+		t.Fatalf("ReorderQueuedRuns() error = %v", err)
+	}
+	deleted, err := store.DeleteQueuedRun(runs[0].ID)
+	if err != nil || !deleted {
+		t.Fatalf("DeleteQueuedRun() = %v, %v, want deleted", deleted, err)
+	}
+	if deleted, _ := store.DeleteQueuedRun(runs[0].ID); deleted {
+		t.Error("DeleteQueuedRun() deleted a run twice")
+	}
+
+	queued, err := store.GetQueuedRuns()
+	if err != nil {
+		t.Fatalf("GetQueuedRuns() error = %v", err)
+	}
+	if len(queued) != 2 || queued[0].JobName != "etl" || queued[1].JobName != "report" {
+		t.Fatalf("GetQueuedRuns() = %+v, want etl then report", queued)
+	}
+	if retry := queued[0]; retry.ExecutionID != "exec-2" || retry.CorrelationID != "corr" || !retry.NotBefore.Equal(runs[2].NotBefore) {
+		t.Errorf("queued retry = %+v, want it as enqueued", retry)
+	}
+
+	// New runs go after the last one
+	run := &types.QueuedRun{JobName: "backup", Trigger: types.TriggerManual, NotBefore: now}
+	if err := store.EnqueueRun(run); err != nil {
+		t.Fatalf("EnqueueRun() error = %v", err)
+	}
+	if run.Position != 4 {
+		t.Errorf("run enqueued at position %d, want 4", run.Position)
+	}
+}
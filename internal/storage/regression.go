@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// DurationRegressionRecord represents a detected duration regression of a
+// job in the database
+type DurationRegressionRecord struct {
+	ID               uint      `gorm:"primaryKey"`
+	JobName          string    `gorm:"index;not null"`
+	ExecutionID      string    `gorm:"not null"`
+	DetectedAt       time.Time `gorm:"index;not null"`
+	Runs             int
+	Tau              float64
+	PValue           float64
+	Slope            float64
+	BaselineDuration float64
+	RecentDuration   float64
+	IncreasePercent  float64
+	Timeout          float64
+	RunsToTimeout    int
+	CreatedAt        time.Time
+}
+
+// StoreDurationRegression stores a detected duration regression and sets
+// its ID
+func (s *Storage) StoreDurationRegression(regression *types.DurationRegression) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_duration_regression")
+
+	record := &DurationRegressionRecord{
+		JobName:          regression.JobName,
+		ExecutionID:      regression.ExecutionID,
+		DetectedAt:       regression.DetectedAt,
+		Runs:             regression.Runs,
+		Tau:              regression.Tau,
+		PValue:           regression.PValue,
+		Slope:            regression.Slope,
+		BaselineDuration: regression.BaselineDuration,
+		RecentDuration:   regression.RecentDuration,
+		IncreasePercent:  regression.IncreasePercent,
+		Timeout:          regression.Timeout,
+		RunsToTimeout:    regression.RunsToTimeout,
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return fmt.Errorf("failed to store duration regression: %v", err)
+	}
+
+	regression.ID = record.ID
+	return nil
+}
+
+// GetDurationRegressions retrieves the duration regressions of a job
+// detected in a time range, newest first. Zero times leave the range open.
+func (s *Storage) GetDurationRegressions(jobName string, since, until time.Time) ([]*types.DurationRegression, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_duration_regressions")
+
+	query := s.reader().Where("job_name = ?", jobName).Order("detected_at DESC")
+	if !since.IsZero() {
+		query = query.Where("detected_at >= ?", since)
+	}
+	if !until.IsZero() {
+		query = query.Where("detected_at <= ?", until)
+	}
+	var records []DurationRegressionRecord
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve duration regressions: %v", err)
+	}
+
+	regressions := make([]*types.DurationRegression, len(records))
+	for i, record := range records {
+		regressions[i] = &types.DurationRegression{
+			ID:               record.ID,
+			JobName:          record.JobName,
+			ExecutionID:      record.ExecutionID,
+			DetectedAt:       record.DetectedAt,
+			Runs:             record.Runs,
+			Tau:              record.Tau,
+			PValue:           record.PValue,
+			Slope:            record.Slope,
+			BaselineDuration: record.BaselineDuration,
+			RecentDuration:   record.RecentDuration,
+			IncreasePercent:  record.IncreasePercent,
+			Timeout:          record.Timeout,
+			RunsToTimeout:    record.RunsToTimeout,
+		}
+	}
+	return regressions, nil
+}
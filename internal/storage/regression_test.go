@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestDurationRegressions(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now()
+
+	for i, job := range []string{"backup", "backup", "report"} {
+		regression := &types.DurationRegression{JobName: job, ExecutionID: "run", DetectedAt: now.Add(-time.Duration(i) * time.Hour),
+			Runs: 20, Slope: 1.5, IncreasePercent: 40, RunsToTimeout: 12}
+		if err := store.StoreDurationRegression(regression); err != nil {
+			t.Fatalf("StoreDurationRegression() error = %v", err)
+		}
+		if regression.ID == 0 {
+			t.Error("StoreDurationRegression() did not set the ID")
+		}
+	}
+
+	regressions, err := store.GetDurationRegressions("backup", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("GetDurationRegressions() error = %v", err)
+	}
+	if len(regressions) != 2 || regressions[0].DetectedAt.Before(regressions[1].DetectedAt) || regressions[0].RunsToTimeout != 12 {
+		t.Errorf("GetDurationRegressions() = %+v, want both of backup, newest first", regressions)
+	}
+	if regressions, err = store.GetDurationRegressions("backup", now.Add(-30*time.Minute), time.Time{}); err != nil || len(regressions) != 1 {
+		t.Errorf("GetDurationRegressions() since = %v, %v, want the latest only", regressions, err)
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// JobExecutionRepo stores job executions and their statistics
+type JobExecutionRepo interface {
+	StoreJobExecution(execution *types.JobExecution) error
+	GetJobExecution(id string) (*types.JobExecution, error)
+	GetJobExecutions(jobName string, limit int) ([]*types.JobExecution, error)
+	GetPreviousJobExecutions(jobName string, before time.Time, limit int) ([]*types.JobExecution, error)
+	EachJobExecution(jobName string, fn func(*types.JobExecution) error) error
+	GetExecutionNear(jobName string, at time.Time, window time.Duration) (*types.JobExecution, error)
+	GetJobStatistics(filter StatisticsFilter) (map[string]interface{}, error)
+}
+
+// MetricsRepo stores collected system metrics
+type MetricsRepo interface {
+	StoreSystemMetrics(metrics *types.SystemMetrics) error
+	GetSystemMetrics(start, end time.Time, limit int) ([]*types.SystemMetrics, error)
+}
+
+// PredictionRepo stores ML predictions and load forecasts together with
+// their evaluated outcomes
+type PredictionRepo interface {
+	StoreMLPrediction(prediction *types.Prediction) error
+	StoreMLPredictions(predictions []*types.Prediction) error
+	GetPendingPredictions(before time.Time, limit int) ([]*types.Prediction, error)
+	StorePredictionOutcome(outcome *types.PredictionOutcome) error
+	GetPredictionOutcomes(since time.Time) ([]*types.PredictionOutcome, error)
+	StoreForecast(forecast *types.LoadForecast) error
+	GetPendingForecastPoints(before time.Time, limit int) ([]*types.ForecastPoint, error)
+	StoreForecastOutcome(outcome *types.ForecastOutcome) error
+	GetForecastOutcomes(since time.Time) ([]*types.ForecastOutcome, error)
+}
+
+// ArtifactRepo stores the artifacts collected from executions
+type ArtifactRepo interface {
+	StoreArtifact(artifact *types.Artifact) error
+	GetArtifacts(executionID string) ([]*types.Artifact, error)
+}
+
+var (
+	_ JobExecutionRepo = (*Storage)(nil)
+	_ MetricsRepo      = (*Storage)(nil)
+	_ PredictionRepo   = (*Storage)(nil)
+	_ ArtifactRepo     = (*Storage)(nil)
+
+	_ JobExecutionRepo = (*MemoryStore)(nil)
+	_ MetricsRepo      = (*MemoryStore)(nil)
+	_ PredictionRepo   = (*MemoryStore)(nil)
+	_ ArtifactRepo     = (*MemoryStore)(nil)
+)
@@ -0,0 +1,309 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Rollup resolutions for system metrics
+const (
+	ResolutionRaw    = "raw"
+	ResolutionMinute = "1m"
+	ResolutionHour   = "1h"
+)
+
+// maxRollupWindow bounds how much data a single rollup pass aggregates,
+// so catching up after downtime happens in several small passes
+const maxRollupWindow = 24 * time.Hour
+
+// SystemMetricsRollupRecord represents aggregated system metrics for a
+// time bucket at a given resolution
+type SystemMetricsRollupRecord struct {
+	ID          uint      `gorm:"primaryKey"`
+	Resolution  string    `gorm:"uniqueIndex:idx_rollup_bucket;not null"`
+	Timestamp   time.Time `gorm:"uniqueIndex:idx_rollup_bucket;not null"`
+	CPUUsage    float64
+	CPUMax      float64
+	MemoryUsage float64
+	MemoryMax   float64
+	DiskIO      float64
+	NetworkIO   float64
+	LoadAvg     float64
+	SampleCount int
+	CreatedAt   time.Time
+}
+
+// rollupSample is a single input to a rollup bucket
+type rollupSample struct {
+	timestamp   time.Time
+	cpuUsage    float64
+	cpuMax      float64
+	memoryUsage float64
+	memoryMax   float64
+	diskIO      float64
+	networkIO   float64
+	loadAvg     float64
+	weight      int
+}
+
+// RunRollups periodically aggregates raw samples into the 1-minute and
+// 1-hour tiers and enforces the retention of every tier
+func (s *Storage) RunRollups(ctx context.Context) {
+	if s.timeseries != nil {
+		// External time-series backends downsample at query time
+		return
+	}
+	if s.retention.RollupInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.retention.RollupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Rollup(time.Now()); err != nil {
+				logrus.Errorf("Failed to roll up system metrics: %v", err)
+			}
+			if err := s.EnforceRetention(time.Now()); err != nil {
+				logrus.Errorf("Failed to enforce metrics retention: %v", err)
+			}
+		}
+	}
+}
+
+// Rollup aggregates all complete buckets up to now into the rollup tiers
+func (s *Storage) Rollup(now time.Time) error {
+	defer queryDuration.ObserveSince(time.Now(), "rollup")
+
+	if err := s.rollupMinutes(now); err != nil {
+		return err
+	}
+	return s.rollupHours(now)
+}
+
+// rollupMinutes aggregates raw samples into 1-minute buckets
+func (s *Storage) rollupMinutes(now time.Time) error {
+	start, err := s.nextBucket(ResolutionMinute, time.Minute, &SystemMetricsRecord{})
+	if err != nil || start.IsZero() {
+		return err
+	}
+	end := minTime(now.Truncate(time.Minute), start.Add(maxRollupWindow))
+
+	var records []SystemMetricsRecord
+	if err := s.db.Where("timestamp >= ? AND timestamp < ?", start, end).Find(&records).Error; err != nil {
+		return fmt.Errorf("failed to read raw metrics for rollup: %v", err)
+	}
+
+	samples := make([]rollupSample, len(records))
+	for i, r := range records {
+		samples[i] = rollupSample{
+			timestamp:   r.Timestamp,
+			cpuUsage:    r.CPUUsage,
+			cpuMax:      r.CPUUsage,
+			memoryUsage: r.MemoryUsage,
+			memoryMax:   r.MemoryUsage,
+			diskIO:      r.DiskIO,
+			networkIO:   r.NetworkIO,
+			loadAvg:     r.LoadAvg,
+			weight:      1,
+		}
+	}
+
+	return s.storeRollups(ResolutionMinute, time.Minute, samples)
+}
+
+// rollupHours aggregates 1-minute buckets into 1-hour buckets
+func (s *Storage) rollupHours(now time.Time) error {
+	start, err := s.nextBucket(ResolutionHour, time.Hour, &SystemMetricsRollupRecord{})
+	if err != nil || start.IsZero() {
+		return err
+	}
+	end := minTime(now.Truncate(time.Hour), start.Add(maxRollupWindow))
+
+	var records []SystemMetricsRollupRecord
+	if err := s.db.Where("resolution = ? AND timestamp >= ? AND timestamp < ?", ResolutionMinute, start, end).
+		Find(&records).Error; err != nil {
+		return fmt.Errorf("failed to read minute rollups: %v", err)
+	}
+
+	samples := make([]rollupSample, len(records))
+	for i, r := range records {
+		samples[i] = rollupSample{
+			timestamp:   r.Timestamp,
+			cpuUsage:    r.CPUUsage,
+			cpuMax:      r.CPUMax,
+			memoryUsage: r.MemoryUsage,
+			memoryMax:   r.MemoryMax,
+			diskIO:      r.DiskIO,
+			networkIO:   r.NetworkIO,
+			loadAvg:     r.LoadAvg,
+			weight:      r.SampleCount,
+		}
+	}
+
+	return s.storeRollups(ResolutionHour, time.Hour, samples)
+}
+
+// nextBucket returns the start of the first bucket not yet rolled up at
+// the given resolution that has source data, or the zero time if there
+// is nothing left to roll up
+func (s *Storage) nextBucket(resolution string, size time.Duration, source interface{}) (time.Time, error) {
+	var from time.Time
+	var last SystemMetricsRollupRecord
+	err := s.db.Where("resolution = ?", resolution).Order("timestamp DESC").First(&last).Error
+	switch {
+	case err == nil:
+		from = last.Timestamp.Add(size)
+	case err != gorm.ErrRecordNotFound:
+		return time.Time{}, fmt.Errorf("failed to find last %s rollup: %v", resolution, err)
+	}
+
+	// Skip over gaps in the source data, e.g. while arcron was down
+	query := s.db.Model(source).Where("timestamp >= ?", from)
+	if _, ok := source.(*SystemMetricsRollupRecord); ok {
+		query = query.Where("resolution = ?", ResolutionMinute)
+	}
+
+	var first struct{ Timestamp time.Time }
+	result := query.Select("timestamp").Order("timestamp ASC").Limit(1).Scan(&first)
+	if result.Error != nil {
+		return time.Time{}, fmt.Errorf("failed to find next sample for %s rollup: %v", resolution, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return time.Time{}, nil
+	}
+	return first.Timestamp.Truncate(size), nil
+}
+
+// storeRollups groups samples into buckets and stores one record per bucket
+func (s *Storage) storeRollups(resolution string, size time.Duration, samples []rollupSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	buckets := make(map[time.Time]*SystemMetricsRollupRecord)
+	for _, sample := range samples {
+		bucket := sample.timestamp.Truncate(size)
+		record, ok := buckets[bucket]
+		if !ok {
+			record = &SystemMetricsRollupRecord{Resolution: resolution, Timestamp: bucket}
+			buckets[bucket] = record
+		}
+
+		w := float64(sample.weight)
+		record.CPUUsage += sample.cpuUsage * w
+		record.MemoryUsage += sample.memoryUsage * w
+		record.DiskIO += sample.diskIO * w
+		record.NetworkIO += sample.networkIO * w
+		record.LoadAvg += sample.loadAvg * w
+		record.CPUMax = math.Max(record.CPUMax, sample.cpuMax)
+		record.MemoryMax = math.Max(record.MemoryMax, sample.memoryMax)
+		record.SampleCount += sample.weight
+	}
+
+	records := make([]*SystemMetricsRollupRecord, 0, len(buckets))
+	for _, record := range buckets {
+		n := float64(record.SampleCount)
+		if n > 0 {
+			record.CPUUsage /= n
+			record.MemoryUsage /= n
+			record.DiskIO /= n
+			record.NetworkIO /= n
+			record.LoadAvg /= n
+		}
+		records = append(records, record)
+	}
+
+	if err := s.db.CreateInBatches(records, 500).Error; err != nil {
+		return fmt.Errorf("failed to store %s rollups: %v", resolution, err)
+	}
+	return nil
+}
+
+// EnforceRetention deletes samples older than the retention of their tier
+func (s *Storage) EnforceRetention(now time.Time) error {
+	defer queryDuration.ObserveSince(time.Now(), "enforce_retention")
+
+	if err := s.db.Where("timestamp < ?", now.Add(-s.retention.Raw)).Delete(&SystemMetricsRecord{}).Error; err != nil {
+		return fmt.Errorf("failed to delete expired raw metrics: %v", err)
+	}
+
+	tiers := map[string]time.Duration{
+		ResolutionMinute: s.retention.Minute,
+		ResolutionHour:   s.retention.Hour,
+	}
+	for resolution, retention := range tiers {
+		if err := s.db.Where("resolution = ? AND timestamp < ?", resolution, now.Add(-retention)).
+			Delete(&SystemMetricsRollupRecord{}).Error; err != nil {
+			return fmt.Errorf("failed to delete expired %s rollups: %v", resolution, err)
+		}
+	}
+
+	return nil
+}
+
+// resolutionFor picks the finest tier that still covers the requested range
+func (s *Storage) resolutionFor(start, end time.Time) string {
+	span := end.Sub(start)
+	age := time.Since(start)
+
+	switch {
+	case span <= 6*time.Hour && age <= s.retention.Raw:
+		return ResolutionRaw
+	case span <= 7*24*time.Hour && age <= s.retention.Minute:
+		return ResolutionMinute
+	default:
+		return ResolutionHour
+	}
+}
+
+// getRollupMetrics retrieves rolled up system metrics within a time range
+func (s *Storage) getRollupMetrics(resolution string, start, end time.Time, limit int) ([]*types.SystemMetrics, error) {
+	var records []SystemMetricsRollupRecord
+
+	query := s.reader().Where("resolution = ? AND timestamp BETWEEN ? AND ?", resolution, start, end).Order("timestamp DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve %s system metrics: %v", resolution, err)
+	}
+
+	metrics := make([]*types.SystemMetrics, len(records))
+	for i, record := range records {
+		metrics[i] = &types.SystemMetrics{
+			Timestamp:   record.Timestamp,
+			CPUUsage:    record.CPUUsage,
+			MemoryUsage: record.MemoryUsage,
+			DiskIO: types.DiskIO{
+				ReadBytes: uint64(record.DiskIO * 1024 * 1024),
+			},
+			NetworkIO: types.NetworkIO{
+				BytesSent: uint64(record.NetworkIO * 1024 * 1024),
+			},
+			LoadAvg: types.LoadAvg{
+				Load1: record.LoadAvg,
+			},
+		}
+	}
+
+	return metrics, nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
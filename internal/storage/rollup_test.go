@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/types"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	store, err := New(config.DatabaseConfig{
+		Driver:   "sqlite",
+		DSN:      filepath.Join(t.TempDir(), "arcron.db"),
+		MaxConns: 2,
+		Retention: config.RetentionConfig{
+			RollupInterval: time.Minute,
+			Raw:            24 * time.Hour,
+			Minute:         7 * 24 * time.Hour,
+			Hour:           365 * 24 * time.Hour,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestRollupAggregatesMinutesAndHours(t *testing.T) {
+	store := newTestStorage(t)
+	base := time.Now().Truncate(time.Hour).Add(-2 * time.Hour)
+
+	// Two samples per minute for the first two minutes
+	samples := []struct {
+		offset time.Duration
+		cpu    float64
+	}{
+		{0, 10}, {30 * time.Second, 30},
+		{time.Minute, 50}, {time.Minute + 30*time.Second, 70},
+	}
+	for _, sample := range samples {
+		if err := store.StoreSystemMetrics(&types.SystemMetrics{
+			Timestamp: base.Add(sample.offset),
+			CPUUsage:  sample.cpu,
+		}); err != nil {
+			t.Fatalf("Failed to store metrics: %v", err)
+		}
+	}
+
+	if err := store.Rollup(time.Now()); err != nil {
+		t.Fatalf("Rollup failed: %v", err)
+	}
+
+	var minutes []SystemMetricsRollupRecord
+	store.db.Where("resolution = ?", ResolutionMinute).Order("timestamp ASC").Find(&minutes)
+	if len(minutes) != 2 {
+		t.Fatalf("Expected 2 minute rollups, got %d", len(minutes))
+	}
+	if minutes[0].CPUUsage != 20 || minutes[0].CPUMax != 30 || minutes[0].SampleCount != 2 {
+		t.Errorf("Unexpected first minute rollup: %+v", minutes[0])
+	}
+
+	var hours []SystemMetricsRollupRecord
+	store.db.Where("resolution = ?", ResolutionHour).Find(&hours)
+	if len(hours) != 1 {
+		t.Fatalf("Expected 1 hour rollup, got %d", len(hours))
+	}
+	if hours[0].CPUUsage != 40 || hours[0].CPUMax != 70 || hours[0].SampleCount != 4 {
+		t.Errorf("Unexpected hour rollup: %+v", hours[0])
+	}
+
+	// A second pass must not duplicate buckets
+	if err := store.Rollup(time.Now()); err != nil {
+		t.Fatalf("Second rollup failed: %v", err)
+	}
+	var count int64
+	store.db.Model(&SystemMetricsRollupRecord{}).Count(&count)
+	if count != 3 {
+		t.Errorf("Expected 3 rollup records after second pass, got %d", count)
+	}
+}
+
+func TestResolutionFor(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now()
+
+	tests := []struct {
+		start    time.Time
+		expected string
+	}{
+		{now.Add(-1 * time.Hour), ResolutionRaw},
+		{now.Add(-24 * time.Hour), ResolutionMinute},
+		{now.Add(-30 * 24 * time.Hour), ResolutionHour},
+	}
+	for _, tt := range tests {
+		if got := store.resolutionFor(tt.start, now); got != tt.expected {
+			t.Errorf("Expected resolution %s for range starting %s ago, got %s", tt.expected, now.Sub(tt.start), got)
+		}
+	}
+}
+
+func TestEnforceRetentionExpiresRawSamples(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now()
+	for _, age := range []time.Duration{48 * time.Hour, time.Hour} {
+		if err := store.StoreSystemMetrics(&types.SystemMetrics{Timestamp: now.Add(-age), CPUUsage: 10}); err != nil {
+			t.Fatalf("Failed to store metrics: %v", err)
+		}
+	}
+
+	// The cleanup leaves system metrics to the rollup pass
+	if err := store.Cleanup(now); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	var count int64
+	store.db.Model(&SystemMetricsRecord{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("raw samples after cleanup = %d, want 2", count)
+	}
+
+	if err := store.EnforceRetention(now); err != nil {
+		t.Fatalf("EnforceRetention failed: %v", err)
+	}
+	store.db.Model(&SystemMetricsRecord{}).Count(&count)
+	if count != 1 {
+		t.Errorf("raw samples after retention = %d, want only the one within 24h", count)
+	}
+}
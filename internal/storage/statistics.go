@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+	"gorm.io/gorm"
+)
+
+// StatisticsFilter selects the executions job statistics are computed over.
+// Zero times leave the range open.
+type StatisticsFilter struct {
+	JobName string
+	Since   time.Time
+	Until   time.Time
+}
+
+// GetJobStatistics retrieves statistics for a specific job, from a read
+// replica if there is one: counts per attempt and per run, duration
+// percentiles and daily trend, failure streaks and a weekday by hour
+// success heatmap
+func (s *Storage) GetJobStatistics(filter StatisticsFilter) (map[string]interface{}, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_job_statistics")
+
+	db := s.reader()
+	executions := func() *gorm.DB {
+		query := db.Model(&JobExecutionRecord{}).Where("job_name = ?", filter.JobName)
+		if !filter.Since.IsZero() {
+			query = query.Where("start_time >= ?", filter.Since)
+		}
+		if !filter.Until.IsZero() {
+			query = query.Where("start_time <= ?", filter.Until)
+		}
+		return query
+	}
+
+	var totalCount int64
+	var successCount int64
+	var failureCount int64
+	var avgDuration float64
+
+	// Get total executions
+	if err := executions().Count(&totalCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count total executions: %v", err)
+	}
+
+	// Get successful executions
+	if err := executions().Where("status = ?", "completed").Count(&successCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count successful executions: %v", err)
+	}
+
+	// Get failed executions
+	if err := executions().Where("status = ?", "failed").Count(&failureCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count failed executions: %v", err)
+	}
+
+	// Get average duration
+	if err := executions().Where("status = ?", "completed").Select("COALESCE(AVG(duration), 0)").Scan(&avgDuration).Error; err != nil {
+		return nil, fmt.Errorf("failed to get average duration: %v", err)
+	}
+
+	successRate := 0.0
+	if totalCount > 0 {
+		successRate = float64(successCount) / float64(totalCount) * 100
+	}
+
+	// Runs are counted by their first attempt; a run whose first attempt
+	// failed recovered if a retry completed, and failed permanently if no
+	// retry completed or is still to come
+	var runCount, recoveredCount, permanentCount int64
+	if err := executions().Where("parent_execution_id IS NULL").Count(&runCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count runs: %v", err)
+	}
+	if err := executions().Where("parent_execution_id IS NOT NULL AND status = ?", "completed").
+		Distinct("parent_execution_id").Count(&recoveredCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count recovered runs: %v", err)
+	}
+	retried := db.Model(&JobExecutionRecord{}).Select("parent_execution_id").
+		Where("parent_execution_id IS NOT NULL AND status IN ?", []string{"completed", "pending", "running"})
+	if err := executions().
+		Where("parent_execution_id IS NULL AND status = ? AND id NOT IN (?)", "failed", retried).
+		Count(&permanentCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count permanently failed runs: %v", err)
+	}
+
+	runSuccessRate := 0.0
+	if runCount > 0 {
+		runSuccessRate = float64(runCount-permanentCount) / float64(runCount) * 100
+	}
+
+	percentiles, err := durationPercentiles(db, executions())
+	if err != nil {
+		return nil, err
+	}
+	trend, err := durationTrend(executions())
+	if err != nil {
+		return nil, err
+	}
+	streaks, err := failureStreaks(db, executions())
+	if err != nil {
+		return nil, err
+	}
+	heatmap, err := successHeatmap(executions())
+	if err != nil {
+		return nil, err
+	}
+	conditions, err := startConditions(executions())
+	if err != nil {
+		return nil, err
+	}
+	wait, err := queueWaitStatistics(db, executions())
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"total_executions":     totalCount,
+		"successful":           successCount,
+		"failed":               failureCount,
+		"success_rate":         successRate,
+		"avg_duration":         avgDuration,
+		"runs":                 runCount,
+		"recovered":            recoveredCount,
+		"failed_permanently":   permanentCount,
+		"run_success_rate":     runSuccessRate,
+		"duration_percentiles": percentiles,
+		"duration_trend":       trend,
+		"failure_streaks":      streaks,
+		"success_heatmap":      heatmap,
+		"start_conditions":     conditions,
+		"queue_wait":           wait,
+	}, nil
+}
+
+// durationPercentiles ranks the durations of completed executions and
+// picks the nearest-rank percentiles: the smallest duration whose rank
+// reaches p percent of the executions
+func durationPercentiles(db, executions *gorm.DB) (types.DurationPercentiles, error) {
+	ranked := executions.Where("status = ?", "completed").
+		Select("duration, ROW_NUMBER() OVER (ORDER BY duration) AS position, COUNT(*) OVER () AS total")
+
+	var percentiles types.DurationPercentiles
+	err := db.Table("(?) AS ranked", ranked).Select(
+		"COALESCE(MIN(CASE WHEN position * 100 >= total * 50 THEN duration END), 0) AS p50, " +
+			"COALESCE(MIN(CASE WHEN position * 100 >= total * 95 THEN duration END), 0) AS p95, " +
+			"COALESCE(MIN(CASE WHEN position * 100 >= total * 99 THEN duration END), 0) AS p99").
+		Scan(&percentiles).Error
+	if err != nil {
+		return percentiles, fmt.Errorf("failed to compute duration percentiles: %v", err)
+	}
+	return percentiles, nil
+}
+
+// durationTrend summarizes completed executions per day
+func durationTrend(executions *gorm.DB) ([]types.DurationTrendPoint, error) {
+	trend := []types.DurationTrendPoint{}
+	err := executions.Where("status = ?", "completed").
+		Select("strftime('%Y-%m-%d', start_time) AS day, COUNT(*) AS executions, " +
+			"AVG(duration) AS avg_duration, MAX(duration) AS max_duration").
+		Group("day").Order("day").Scan(&trend).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute duration trend: %v", err)
+	}
+	return trend, nil
+}
+
+// failureStreaks finds runs of consecutive failures among finished
+// executions. Each streak is an island of equal status: the difference of
+// an execution's position overall and among executions of its status
+// stays the same while the status does not change.
+func failureStreaks(db, executions *gorm.DB) (types.FailureStreaks, error) {
+	islands := executions.Where("status IN ?", []string{"completed", "failed"}).
+		Select("status, start_time, " +
+			"ROW_NUMBER() OVER (ORDER BY start_time, id) - " +
+			"ROW_NUMBER() OVER (PARTITION BY status ORDER BY start_time, id) AS island")
+
+	var streaks []struct {
+		Length int64
+		Last   bool
+	}
+	err := db.Table("(?) AS islands", islands).
+		Select("COUNT(*) AS length, MAX(start_time) = (SELECT MAX(start_time) FROM (?)) AS last", islands).
+		Where("status = ?", "failed").Group("island").Scan(&streaks).Error
+	if err != nil {
+		return types.FailureStreaks{}, fmt.Errorf("failed to compute failure streaks: %v", err)
+	}
+
+	var result types.FailureStreaks
+	for _, streak := range streaks {
+		result.Longest = max(result.Longest, streak.Length)
+		if streak.Last {
+			result.Current = streak.Length
+		}
+	}
+	return result, nil
+}
+
+// successHeatmap counts executions and successes per weekday and hour
+func successHeatmap(executions *gorm.DB) ([]types.HeatmapCell, error) {
+	heatmap := []types.HeatmapCell{}
+	err := executions.Select("CAST(strftime('%w', start_time) AS INTEGER) AS weekday, " +
+		"CAST(strftime('%H', start_time) AS INTEGER) AS hour, COUNT(*) AS executions, " +
+		"SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) AS successful, " +
+		"100.0 * SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) / COUNT(*) AS success_rate").
+		Group("weekday, hour").Order("weekday, hour").Scan(&heatmap).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute success heatmap: %v", err)
+	}
+	return heatmap, nil
+}
+
+// startConditions averages the system metrics at the start of completed
+// and failed executions, relating the outcomes of a job to the load it
+// started under
+func startConditions(executions *gorm.DB) ([]types.StartConditions, error) {
+	conditions := []types.StartConditions{}
+	err := executions.Where("start_metrics_at IS NOT NULL AND status IN ?", []string{"completed", "failed"}).
+		Select("status, COUNT(*) AS executions, AVG(start_cpu_usage) AS avg_cpu_usage, " +
+			"MAX(start_cpu_usage) AS max_cpu_usage, AVG(start_memory_usage) AS avg_memory_usage, " +
+			"AVG(start_load1) AS avg_load1").
+		Group("status").Order("status").Scan(&conditions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute start conditions: %v", err)
+	}
+	return conditions, nil
+}
+
+// queueWaitStatistics summarizes the queue waits of executions that are
+// no longer pending, with the nearest-rank 95th percentile
+func queueWaitStatistics(db, executions *gorm.DB) (types.QueueWaitStatistics, error) {
+	ranked := executions.Where("queued_at IS NOT NULL AND status <> ?", "pending").
+		Select("queue_wait, ROW_NUMBER() OVER (ORDER BY queue_wait) AS position, COUNT(*) OVER () AS total")
+
+	var wait types.QueueWaitStatistics
+	err := db.Table("(?) AS ranked", ranked).Select(
+		"COUNT(*) AS executions, COALESCE(AVG(queue_wait), 0) AS avg, " +
+			"COALESCE(MIN(CASE WHEN position * 100 >= total * 95 THEN queue_wait END), 0) AS p95, " +
+			"COALESCE(MAX(queue_wait), 0) AS max").
+		Scan(&wait).Error
+	if err != nil {
+		return wait, fmt.Errorf("failed to compute queue wait statistics: %v", err)
+	}
+	return wait, nil
+}
+
+// GetFleetStatistics summarizes the executions of all jobs started since
+// the given time and ranks the top jobs by average duration and failures
+func (s *Storage) GetFleetStatistics(since time.Time, top int) (*types.FleetStatistics, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_fleet_statistics")
+
+	var jobs []types.JobRanking
+	err := s.reader().Model(&JobExecutionRecord{}).Where("start_time >= ?", since).
+		Select("job_name, COUNT(*) AS executions, " +
+			"SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END) AS failures, " +
+			"COALESCE(AVG(CASE WHEN status = 'completed' THEN duration END), 0) AS avg_duration").
+		Group("job_name").Order("job_name").Scan(&jobs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute fleet statistics: %v", err)
+	}
+
+	fleet := &types.FleetStatistics{Since: since, Slowest: []types.JobRanking{}, MostFailing: []types.JobRanking{}}
+	for i := range jobs {
+		jobs[i].FailureRate = float64(jobs[i].Failures) / float64(jobs[i].Executions) * 100
+		fleet.Executions += jobs[i].Executions
+		fleet.Failed += jobs[i].Failures
+	}
+	if fleet.Executions > 0 {
+		fleet.FailureRate = float64(fleet.Failed) / float64(fleet.Executions) * 100
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].AvgDuration > jobs[j].AvgDuration })
+	for _, job := range jobs {
+		if len(fleet.Slowest) < top && job.AvgDuration > 0 {
+			fleet.Slowest = append(fleet.Slowest, job)
+		}
+	}
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].Failures > jobs[j].Failures })
+	for _, job := range jobs {
+		if len(fleet.MostFailing) < top && job.Failures > 0 {
+			fleet.MostFailing = append(fleet.MostFailing, job)
+		}
+	}
+
+	return fleet, nil
+}
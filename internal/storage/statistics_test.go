@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+func TestJobStatisticsAggregates(t *testing.T) {
+	// Sunday 2024-06-02 00:00 UTC; one execution an hour
+	start := time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)
+	statuses := "CCCCCCCCCCCCCCCCCCCCFFCFFFCFF"
+
+	for name, repo := range map[string]JobExecutionRepo{"storage": newTestStorage(t), "memory": NewMemoryStore()} {
+		for i, status := range statuses {
+			execution := &types.JobExecution{
+				ID:        fmt.Sprintf("%02d", i),
+				JobName:   "backup",
+				StartTime: start.Add(time.Duration(i) * time.Hour),
+				Duration:  float64(i + 1),
+				Status:    types.StatusCompleted,
+				Attempt:   1,
+			}
+			if status == 'F' {
+				execution.Status = types.StatusFailed
+			}
+			// Failures started under load; the first run has no metrics and
+			// no recorded queue wait, later runs waited a second longer each
+			if i > 0 {
+				execution.QueuedAt = execution.StartTime.Add(-time.Duration(i) * time.Second)
+				execution.QueueWait = float64(i)
+				cpu := 20.0
+				if status == 'F' {
+					cpu = 90
+				}
+				execution.StartMetrics = &types.MetricsSnapshot{Timestamp: execution.StartTime, CPUUsage: cpu, Load1: 1.5}
+			}
+			if err := repo.StoreJobExecution(execution); err != nil {
+				t.Fatalf("%s: StoreJobExecution() error = %v", name, err)
+			}
+		}
+
+		statistics, err := repo.GetJobStatistics(StatisticsFilter{JobName: "backup"})
+		if err != nil {
+			t.Fatalf("%s: GetJobStatistics() error = %v", name, err)
+		}
+
+		// Completed durations are 1-20, 23 and 27
+		want := types.DurationPercentiles{P50: 11, P95: 23, P99: 27}
+		if got := statistics["duration_percentiles"]; got != want {
+			t.Errorf("%s: duration_percentiles = %+v, want %+v", name, got, want)
+		}
+		if got := statistics["failure_streaks"]; got != (types.FailureStreaks{Current: 2, Longest: 3}) {
+			t.Errorf("%s: failure_streaks = %+v, want current 2, longest 3", name, got)
+		}
+
+		trend := statistics["duration_trend"].([]types.DurationTrendPoint)
+		if len(trend) != 2 || trend[0].Day != "2024-06-02" || trend[0].Executions != 21 || trend[1].MaxDuration != 27 {
+			t.Errorf("%s: duration_trend = %+v, want 21 runs on June 2 and the last on June 3", name, trend)
+		}
+
+		heatmap := statistics["success_heatmap"].([]types.HeatmapCell)
+		if len(heatmap) != len(statuses) || heatmap[0] != (types.HeatmapCell{Weekday: 0, Hour: 0, Executions: 1, Successful: 1, SuccessRate: 100}) {
+			t.Errorf("%s: success_heatmap starts with %+v, want Sunday 00:00 with one success", name, heatmap[0])
+		}
+		if last := heatmap[len(heatmap)-1]; last.Weekday != 1 || last.Hour != 4 || last.SuccessRate != 0 {
+			t.Errorf("%s: success_heatmap ends with %+v, want Monday 04:00 failed", name, last)
+		}
+
+		conditions := statistics["start_conditions"].([]types.StartConditions)
+		wantConditions := []types.StartConditions{
+			{Status: "completed", Executions: 21, AvgCPUUsage: 20, MaxCPUUsage: 20, AvgLoad1: 1.5},
+			{Status: "failed", Executions: 7, AvgCPUUsage: 90, MaxCPUUsage: 90, AvgLoad1: 1.5},
+		}
+		if !reflect.DeepEqual(conditions, wantConditions) {
+			t.Errorf("%s: start_conditions = %+v, want %+v", name, conditions, wantConditions)
+		}
+		wantWait := types.QueueWaitStatistics{Executions: 28, Avg: 14.5, P95: 27, Max: 28}
+		if got := statistics["queue_wait"]; got != wantWait {
+			t.Errorf("%s: queue_wait = %+v, want %+v", name, got, wantWait)
+		}
+
+		// Only the failures of the last hours
+		statistics, err = repo.GetJobStatistics(StatisticsFilter{
+			JobName: "backup",
+			Since:   start.Add(23 * time.Hour),
+			Until:   start.Add(25 * time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("%s: GetJobStatistics() in range error = %v", name, err)
+		}
+		if statistics["total_executions"] != int64(3) || statistics["failure_streaks"] != (types.FailureStreaks{Current: 3, Longest: 3}) {
+			t.Errorf("%s: statistics in range = %v, want 3 failed executions", name, statistics)
+		}
+	}
+}
+
+func TestFleetStatistics(t *testing.T) {
+	store := newTestStorage(t)
+	now := time.Now()
+
+	executions := []*types.JobExecution{
+		{ID: "1", JobName: "backup", Duration: 300, Status: types.StatusCompleted},
+		{ID: "2", JobName: "backup", Status: types.StatusFailed},
+		{ID: "3", JobName: "report", Duration: 20, Status: types.StatusCompleted},
+		{ID: "4", JobName: "sync", Status: types.StatusFailed},
+		{ID: "5", JobName: "sync", Status: types.StatusFailed},
+		{ID: "6", JobName: "cleanup", Duration: 900, Status: types.StatusCompleted, StartTime: now.Add(-48 * time.Hour)},
+	}
+	for _, execution := range executions {
+		if execution.StartTime.IsZero() {
+			execution.StartTime = now.Add(-time.Hour)
+		}
+		if err := store.StoreJobExecution(execution); err != nil {
+			t.Fatalf("StoreJobExecution() error = %v", err)
+		}
+	}
+
+	fleet, err := store.GetFleetStatistics(now.Add(-24*time.Hour), 5)
+	if err != nil {
+		t.Fatalf("GetFleetStatistics() error = %v", err)
+	}
+	if fleet.Executions != 5 || fleet.Failed != 3 || fleet.FailureRate != 60 {
+		t.Errorf("fleet = %d executions, %d failed, %.0f%%, want 5, 3, 60%%", fleet.Executions, fleet.Failed, fleet.FailureRate)
+	}
+	if len(fleet.Slowest) != 2 || fleet.Slowest[0].JobName != "backup" || fleet.Slowest[1].JobName != "report" {
+		t.Errorf("slowest = %+v, want backup then report", fleet.Slowest)
+	}
+	if len(fleet.MostFailing) != 2 || fleet.MostFailing[0].JobName != "sync" || fleet.MostFailing[0].FailureRate != 100 {
+		t.Errorf("most failing = %+v, want sync then backup", fleet.MostFailing)
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	store := newTestStorage(t)
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	executions := []*types.JobExecution{
+		{ID: "a", JobName: "backup", StartTime: start, EndTime: start.Add(10 * time.Minute), Status: types.StatusCompleted, Attempt: 1},
+		{ID: "b", JobName: "report", StartTime: start.Add(5 * time.Minute), EndTime: start.Add(15 * time.Minute), Status: types.StatusFailed, Attempt: 1},
+		{ID: "c", JobName: "sync", StartTime: start.Add(8 * time.Minute), Status: types.StatusRunning, Attempt: 1},
+		// Touches b without overlapping it
+		{ID: "d", JobName: "report", StartTime: start.Add(15 * time.Minute), EndTime: start.Add(20 * time.Minute), Status: types.StatusCompleted, Attempt: 1},
+		// Ended before the range
+		{ID: "e", JobName: "backup", StartTime: start.Add(-3 * time.Hour), EndTime: start.Add(-2 * time.Hour), Status: types.StatusCompleted, Attempt: 1},
+	}
+	for _, execution := range executions {
+		if err := store.StoreJobExecution(execution); err != nil {
+			t.Fatalf("StoreJobExecution() error = %v", err)
+		}
+	}
+
+	timeline, err := store.GetTimeline(start, start.Add(time.Hour), 10)
+	if err != nil {
+		t.Fatalf("GetTimeline() error = %v", err)
+	}
+	if len(timeline.Intervals) != 4 || timeline.Truncated {
+		t.Fatalf("GetTimeline() returned %d intervals, truncated %v, want 4", len(timeline.Intervals), timeline.Truncated)
+	}
+	if timeline.MaxConcurrency != 3 {
+		t.Errorf("MaxConcurrency = %d, want 3", timeline.MaxConcurrency)
+	}
+
+	overlaps := make(map[string]int)
+	for _, interval := range timeline.Intervals {
+		overlaps[interval.ExecutionID] = len(interval.Overlaps)
+	}
+	if want := map[string]int{"a": 2, "b": 2, "c": 3, "d": 1}; fmt.Sprint(overlaps) != fmt.Sprint(want) {
+		t.Errorf("overlaps = %v, want %v", overlaps, want)
+	}
+	if running := timeline.Intervals[2]; !running.Running || running.End.Before(start.Add(time.Hour)) {
+		t.Errorf("running interval = %+v, want it to end now", running)
+	}
+
+	truncated, err := store.GetTimeline(start, start.Add(time.Hour), 2)
+	if err != nil {
+		t.Fatalf("GetTimeline() error = %v", err)
+	}
+	if len(truncated.Intervals) != 2 || !truncated.Truncated {
+		t.Errorf("GetTimeline() with limit 2 returned %d intervals, truncated %v", len(truncated.Intervals), truncated.Truncated)
+	}
+}
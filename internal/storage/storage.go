@@ -1,73 +1,213 @@
 package storage
 
 import (
+	"encoding/json"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/makalin/arcron/internal/config"
+	"github.com/makalin/arcron/internal/telemetry"
 	"github.com/makalin/arcron/internal/types"
-	"gorm.io/gorm"
-	"gorm.io/driver/sqlite"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var queryDuration = telemetry.NewHistogram("arcron_storage_query_duration_seconds",
+	"Latency of storage queries by operation", "operation")
+
 // Storage represents the data storage layer
 type Storage struct {
-	db *gorm.DB
+	db        *gorm.DB
+	readers   []*gorm.DB
+	next      *atomic.Uint64
+	retention config.RetentionConfig
+	cleanup   config.CleanupConfig
+	backup    config.BackupConfig
+
+	metricsWriter *metricsWriter
+	timeseries    MetricsStore
 }
 
 // New creates a new Storage instance
 func New(cfg config.DatabaseConfig) (*Storage, error) {
-	var db *gorm.DB
-	var err error
+	db, err := openDatabase(cfg.Driver, cfg.DSN, cfg.MaxConns, cfg.QueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// Auto-migrate database schema
+	if err := db.AutoMigrate(models()...); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %v", err)
+	}
+
+	store := &Storage{
+		db:        db,
+		next:      new(atomic.Uint64),
+		retention: cfg.Retention,
+		cleanup:   cfg.Cleanup,
+		backup:    cfg.Backup,
+	}
+
+	// Analytical queries go to the read replicas, or to the main database
+	// with their own timeout if there are none
+	for _, dsn := range cfg.ReadReplicas {
+		replica, err := openDatabase(cfg.Driver, dsn, cfg.MaxConns, cfg.AnalyticsTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open read replica: %v", err)
+		}
+		store.readers = append(store.readers, replica)
+	}
+	if len(store.readers) == 0 {
+		store.readers = []*gorm.DB{db.Set(queryTimeoutKey, cfg.AnalyticsTimeout).Session(&gorm.Session{})}
+	}
 
-	switch cfg.Driver {
-	case "sqlite":
-		db, err = gorm.Open(sqlite.Open(cfg.DSN), &gorm.Config{})
+	switch cfg.TimeSeries.Backend {
+	case "", "database":
+		// System metrics stay in the main database with rollup tiers
+	case "influxdb":
+		influx, err := NewInfluxStore(cfg.TimeSeries)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open SQLite database: %v", err)
+			return nil, fmt.Errorf("failed to configure InfluxDB metrics store: %v", err)
+		}
+		store.timeseries = influx
+
+		// Samples are always written in the background, so a slow
+		// InfluxDB does not hold up the monitor's collection loop
+		batch := cfg.Batch
+		if batch.Size < 1 {
+			batch.Size = 1
 		}
+		store.metricsWriter = newMetricsWriter(influx.writeBatch, batch)
 	default:
-		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
+		return nil, fmt.Errorf("unsupported time-series backend: %s", cfg.TimeSeries.Backend)
 	}
 
-	// Configure connection pool
-	sqlDB, err := db.DB()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get underlying database: %v", err)
+	if store.metricsWriter == nil && cfg.Batch.Size > 1 {
+		store.metricsWriter = newMetricsWriter(store.writeMetricsBatch, cfg.Batch)
 	}
 
-	sqlDB.SetMaxOpenConns(cfg.MaxConns)
-	sqlDB.SetMaxIdleConns(cfg.MaxConns / 2)
+	logrus.Info("Storage initialized successfully")
+	return store, nil
+}
 
-	// Auto-migrate database schema
-	if err := db.AutoMigrate(
+// models returns the records of every table in the database
+func models() []interface{} {
+	return []interface{}{
 		&JobExecutionRecord{},
 		&SystemMetricsRecord{},
+		&SystemMetricsRollupRecord{},
 		&MLPredictionRecord{},
-	); err != nil {
-		return nil, fmt.Errorf("failed to migrate database: %v", err)
+		&ForecastRecord{},
+		&AdjustmentRecord{},
+		&MaintenanceRecord{},
+		&AuditRecord{},
+		&AnomalyRecord{},
+		&AnomalyBaselineRecord{},
+		&AlertRecord{},
+		&SilenceRecord{},
+		&DeadLetterRecord{},
+		&ImpactRecord{},
+		&ArtifactRecord{},
+		&JobDefinitionRecord{},
+		&JobRevisionRecord{},
+		&DurationRegressionRecord{},
+		&QueuedRunRecord{},
 	}
-
-	logrus.Info("Storage initialized successfully")
-	return &Storage{db: db}, nil
 }
 
 // JobExecutionRecord represents a job execution record in the database
 type JobExecutionRecord struct {
-	ID          string    `gorm:"primaryKey"`
-	JobName     string    `gorm:"index;not null"`
-	StartTime   time.Time `gorm:"not null"`
-	EndTime     time.Time
-	Duration    float64
-	Status      string `gorm:"not null"`
-	ExitCode    int
-	Output      string `gorm:"type:text"`
-	Error       string `gorm:"type:text"`
-	RetryCount  int
-	Environment string `gorm:"type:text"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID            string    `gorm:"primaryKey"`
+	JobName       string    `gorm:"index;not null"`
+	Namespace     string    `gorm:"index"`
+	StartTime     time.Time `gorm:"not null"`
+	EndTime       time.Time
+	Duration      float64
+	Status        string `gorm:"not null"`
+	ExitCode      int
+	Output        string `gorm:"type:text"`
+	Error         string `gorm:"type:text"`
+	FailureReason string `gorm:"index"`
+	RetryCount    int
+	Attempt       int    `gorm:"uniqueIndex:idx_execution_attempt;not null;default:1"`
+	Environment   string `gorm:"type:text"`
+	// ParentExecutionID is null for first attempts, so only retries of the
+	// same execution are held to one record per attempt
+	ParentExecutionID *string `gorm:"uniqueIndex:idx_execution_attempt"`
+	CorrelationID     string  `gorm:"index"`
+	// QueuedAt is null for executions stored before queue waits were
+	// recorded
+	QueuedAt     *time.Time
+	QueueWait    float64
+	StartMetrics MetricsColumns `gorm:"embedded;embeddedPrefix:start_"`
+	EndMetrics   MetricsColumns `gorm:"embedded;embeddedPrefix:end_"`
+	// OutputMetrics holds the extracted output metrics as JSON
+	OutputMetrics string `gorm:"type:text"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// MetricsColumns hold a metrics snapshot of an execution; MetricsAt is
+// null without one
+type MetricsColumns struct {
+	MetricsAt      *time.Time
+	CPUUsage       float64
+	MemoryUsage    float64
+	DiskIOBytes    uint64
+	NetworkIOBytes uint64
+	Load1          float64
+	GPUUsage       float64
+	CPUTemperature float64
+}
+
+// metricsColumnNames lists the start and end metrics columns of an
+// execution record
+func metricsColumnNames() []string {
+	var names []string
+	for _, prefix := range []string{"start_", "end_"} {
+		for _, column := range []string{"metrics_at", "cpu_usage", "memory_usage", "disk_io_bytes",
+			"network_io_bytes", "load1", "gpu_usage", "cpu_temperature"} {
+			names = append(names, prefix+column)
+		}
+	}
+	return names
+}
+
+// metricsColumns returns the columns of a snapshot
+func metricsColumns(snapshot *types.MetricsSnapshot) MetricsColumns {
+	if snapshot == nil {
+		return MetricsColumns{}
+	}
+	at := snapshot.Timestamp
+	return MetricsColumns{
+		MetricsAt:      &at,
+		CPUUsage:       snapshot.CPUUsage,
+		MemoryUsage:    snapshot.MemoryUsage,
+		DiskIOBytes:    snapshot.DiskIOBytes,
+		NetworkIOBytes: snapshot.NetworkIOBytes,
+		Load1:          snapshot.Load1,
+		GPUUsage:       snapshot.GPUUsage,
+		CPUTemperature: snapshot.CPUTemperature,
+	}
+}
+
+// snapshot returns the snapshot held in the columns, or nil
+func (c MetricsColumns) snapshot() *types.MetricsSnapshot {
+	if c.MetricsAt == nil {
+		return nil
+	}
+	return &types.MetricsSnapshot{
+		Timestamp:      *c.MetricsAt,
+		CPUUsage:       c.CPUUsage,
+		MemoryUsage:    c.MemoryUsage,
+		DiskIOBytes:    c.DiskIOBytes,
+		NetworkIOBytes: c.NetworkIOBytes,
+		Load1:          c.Load1,
+		GPUUsage:       c.GPUUsage,
+		CPUTemperature: c.CPUTemperature,
+	}
 }
 
 // SystemMetricsRecord represents system metrics in the database
@@ -91,26 +231,80 @@ type MLPredictionRecord struct {
 	Confidence   float64
 	Reasoning    string `gorm:"type:text"`
 	ExpectedLoad float64
+	Method       string
+	ActualLoad   float64
+	AbsError     float64
+	JobStatus    string
+	EvaluatedAt  *time.Time `gorm:"index"`
+	Explanation  string     `gorm:"type:text"`
 	CreatedAt    time.Time
 }
 
-// StoreJobExecution stores a job execution record
+// AuditRecord represents an audited mutating action in the database
+type AuditRecord struct {
+	ID        uint      `gorm:"primaryKey"`
+	Timestamp time.Time `gorm:"index;not null"`
+	Principal string    `gorm:"index"`
+	SourceIP  string
+	Action    string `gorm:"index;not null"`
+	Target    string `gorm:"index"`
+	Payload   string `gorm:"type:text"`
+	CreatedAt time.Time
+}
+
+// AuditFilter narrows down audit record queries
+type AuditFilter struct {
+	Action    string
+	Principal string
+	Target    string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// StoreJobExecution stores a job execution record, updating the stored
+// record of the same execution as it progresses
 func (s *Storage) StoreJobExecution(execution *types.JobExecution) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_job_execution")
+
 	record := &JobExecutionRecord{
-		ID:          execution.ID,
-		JobName:     execution.JobName,
-		StartTime:   execution.StartTime,
-		EndTime:     execution.EndTime,
-		Duration:    execution.Duration,
-		Status:      string(execution.Status),
-		ExitCode:    execution.ExitCode,
-		Output:      execution.Output,
-		Error:       execution.Error,
-		RetryCount:  execution.RetryCount,
-		Environment: execution.Environment,
+		ID:            execution.ID,
+		JobName:       execution.JobName,
+		Namespace:     execution.Namespace,
+		StartTime:     execution.StartTime,
+		EndTime:       execution.EndTime,
+		Duration:      execution.Duration,
+		Status:        string(execution.Status),
+		ExitCode:      execution.ExitCode,
+		Output:        execution.Output,
+		Error:         execution.Error,
+		FailureReason: string(execution.FailureReason),
+		RetryCount:    execution.RetryCount,
+		Attempt:       execution.Attempt,
+		Environment:   execution.Environment,
+		StartMetrics:  metricsColumns(execution.StartMetrics),
+		EndMetrics:    metricsColumns(execution.EndMetrics),
+		QueueWait:     execution.QueueWait,
+		CorrelationID: execution.CorrelationID,
 	}
-
-	result := s.db.Create(record)
+	if execution.ParentExecutionID != "" {
+		record.ParentExecutionID = &execution.ParentExecutionID
+	}
+	if !execution.QueuedAt.IsZero() {
+		record.QueuedAt = &execution.QueuedAt
+	}
+	outputMetrics, err := marshalOptional(execution.OutputMetrics)
+	if err != nil {
+		return fmt.Errorf("failed to marshal output metrics: %v", err)
+	}
+	record.OutputMetrics = outputMetrics
+
+	result := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns(append([]string{"start_time", "end_time", "duration", "status",
+			"exit_code", "output", "error", "failure_reason", "retry_count", "environment", "queued_at", "queue_wait", "output_metrics", "updated_at"},
+			metricsColumnNames()...)),
+	}).Create(record)
 	if result.Error != nil {
 		return fmt.Errorf("failed to store job execution: %v", result.Error)
 	}
@@ -118,8 +312,25 @@ func (s *Storage) StoreJobExecution(execution *types.JobExecution) error {
 	return nil
 }
 
+// GetJobExecution retrieves a job execution by ID, or nil if there is none
+func (s *Storage) GetJobExecution(id string) (*types.JobExecution, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_job_execution")
+
+	var records []JobExecutionRecord
+	if err := s.db.Where("id = ?", id).Limit(1).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve job execution: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	return executionFromRecord(records[0]), nil
+}
+
 // GetJobExecutions retrieves job executions for a specific job
 func (s *Storage) GetJobExecutions(jobName string, limit int) ([]*types.JobExecution, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_job_executions")
+
 	var records []JobExecutionRecord
 
 	query := s.db.Where("job_name = ?", jobName).Order("start_time DESC")
@@ -133,33 +344,108 @@ func (s *Storage) GetJobExecutions(jobName string, limit int) ([]*types.JobExecu
 
 	executions := make([]*types.JobExecution, len(records))
 	for i, record := range records {
-		executions[i] = &types.JobExecution{
-			ID:          record.ID,
-			JobName:     record.JobName,
-			StartTime:   record.StartTime,
-			EndTime:     record.EndTime,
-			Duration:    record.Duration,
-			Status:      types.JobStatus(record.Status),
-			ExitCode:    record.ExitCode,
-			Output:      record.Output,
-			Error:       record.Error,
-			RetryCount:  record.RetryCount,
-			Environment: record.Environment,
-		}
+		executions[i] = executionFromRecord(record)
 	}
 
 	return executions, nil
 }
 
+// GetPreviousJobExecutions retrieves the finished executions of a job that
+// started before a time, newest first
+func (s *Storage) GetPreviousJobExecutions(jobName string, before time.Time, limit int) ([]*types.JobExecution, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_previous_job_executions")
+
+	var records []JobExecutionRecord
+
+	query := s.db.Where("job_name = ? AND start_time < ? AND status IN ?", jobName, before,
+		[]string{string(types.StatusCompleted), string(types.StatusFailed)}).Order("start_time DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve job executions: %v", err)
+	}
+
+	executions := make([]*types.JobExecution, len(records))
+	for i, record := range records {
+		executions[i] = executionFromRecord(record)
+	}
+
+	return executions, nil
+}
+
+// exportBatchSize is how many executions EachJobExecution reads at a time
+const exportBatchSize = 500
+
+// EachJobExecution calls fn with every execution of a job, oldest first,
+// reading them in batches so the full history is never held in memory. It
+// stops at the first error fn returns.
+func (s *Storage) EachJobExecution(jobName string, fn func(*types.JobExecution) error) error {
+	defer queryDuration.ObserveSince(time.Now(), "each_job_execution")
+
+	// Paged by offset: FindInBatches pages by primary key, which does not
+	// follow start time
+	for offset := 0; ; offset += exportBatchSize {
+		var records []JobExecutionRecord
+		if err := s.reader().Where("job_name = ?", jobName).Order("start_time ASC, id ASC").
+			Offset(offset).Limit(exportBatchSize).Find(&records).Error; err != nil {
+			return fmt.Errorf("failed to read job executions: %v", err)
+		}
+		for _, record := range records {
+			if err := fn(executionFromRecord(record)); err != nil {
+				return err
+			}
+		}
+		if len(records) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+func executionFromRecord(record JobExecutionRecord) *types.JobExecution {
+	execution := &types.JobExecution{
+		ID:            record.ID,
+		JobName:       record.JobName,
+		Namespace:     record.Namespace,
+		StartTime:     record.StartTime,
+		EndTime:       record.EndTime,
+		Duration:      record.Duration,
+		Status:        types.JobStatus(record.Status),
+		ExitCode:      record.ExitCode,
+		Output:        record.Output,
+		Error:         record.Error,
+		FailureReason: types.FailureReason(record.FailureReason),
+		RetryCount:    record.RetryCount,
+		Attempt:       record.Attempt,
+		Environment:   record.Environment,
+		StartMetrics:  record.StartMetrics.snapshot(),
+		EndMetrics:    record.EndMetrics.snapshot(),
+		QueueWait:     record.QueueWait,
+		CorrelationID: record.CorrelationID,
+	}
+	if record.ParentExecutionID != nil {
+		execution.ParentExecutionID = *record.ParentExecutionID
+	}
+	if record.QueuedAt != nil {
+		execution.QueuedAt = *record.QueuedAt
+	}
+	if err := unmarshalOptional(record.OutputMetrics, &execution.OutputMetrics); err != nil {
+		logrus.Warnf("Failed to unmarshal output metrics of execution %s: %v", record.ID, err)
+	}
+	return execution
+}
+
 // StoreSystemMetrics stores system metrics
 func (s *Storage) StoreSystemMetrics(metrics *types.SystemMetrics) error {
-	record := &SystemMetricsRecord{
-		Timestamp:   metrics.Timestamp,
-		CPUUsage:    metrics.CPUUsage,
-		MemoryUsage: metrics.MemoryUsage,
-		DiskIO:      float64(metrics.DiskIO.ReadBytes+metrics.DiskIO.WriteBytes) / 1024 / 1024,
-		NetworkIO:   float64(metrics.NetworkIO.BytesSent+metrics.NetworkIO.BytesRecv) / 1024 / 1024,
-		LoadAvg:     metrics.LoadAvg.Load1,
+	defer queryDuration.ObserveSince(time.Now(), "store_system_metrics")
+
+	record := systemMetricsRecord(metrics)
+	if s.metricsWriter != nil {
+		return s.metricsWriter.enqueue(record)
+	}
+	if s.timeseries != nil {
+		return s.timeseries.StoreSystemMetrics(metrics)
 	}
 
 	result := s.db.Create(record)
@@ -170,11 +456,41 @@ func (s *Storage) StoreSystemMetrics(metrics *types.SystemMetrics) error {
 	return nil
 }
 
-// GetSystemMetrics retrieves system metrics within a time range
+// systemMetricsRecord converts system metrics into their database record
+func systemMetricsRecord(metrics *types.SystemMetrics) *SystemMetricsRecord {
+	return &SystemMetricsRecord{
+		Timestamp:   metrics.Timestamp,
+		CPUUsage:    metrics.CPUUsage,
+		MemoryUsage: metrics.MemoryUsage,
+		DiskIO:      float64(metrics.DiskIO.ReadBytes+metrics.DiskIO.WriteBytes) / 1024 / 1024,
+		NetworkIO:   float64(metrics.NetworkIO.BytesSent+metrics.NetworkIO.BytesRecv) / 1024 / 1024,
+		LoadAvg:     metrics.LoadAvg.Load1,
+	}
+}
+
+// GetSystemMetrics retrieves system metrics within a time range. Short,
+// recent ranges are served from raw samples; longer or older ranges from
+// the 1-minute or 1-hour rollup tiers. It reads from a read replica if
+// there is one.
 func (s *Storage) GetSystemMetrics(start, end time.Time, limit int) ([]*types.SystemMetrics, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_system_metrics")
+
+	if s.timeseries != nil {
+		return s.timeseries.GetSystemMetrics(start, end, limit)
+	}
+
+	if resolution := s.resolutionFor(start, end); resolution != ResolutionRaw {
+		metrics, err := s.getRollupMetrics(resolution, start, end, limit)
+		if err != nil || len(metrics) > 0 {
+			return metrics, err
+		}
+		// Rollups have not caught up yet (e.g. right after startup),
+		// fall back to whatever raw samples are still retained
+	}
+
 	var records []SystemMetricsRecord
 
-	query := s.db.Where("timestamp BETWEEN ? AND ?", start, end).Order("timestamp DESC")
+	query := s.reader().Where("timestamp BETWEEN ? AND ?", start, end).Order("timestamp DESC")
 	if limit > 0 {
 		query = query.Limit(limit)
 	}
@@ -207,16 +523,12 @@ func (s *Storage) GetSystemMetrics(start, end time.Time, limit int) ([]*types.Sy
 }
 
 // StoreMLPrediction stores an ML prediction
-func (s *Storage) StoreMLPrediction(prediction *types.SystemMetrics) error {
-	// This is a placeholder - in a real implementation, you'd store actual ML predictions
-	// For now, we'll just store the metrics that led to the prediction
-	record := &MLPredictionRecord{
-		PredictedAt:  time.Now(),
-		JobName:      "system_prediction",
-		OptimalTime:  time.Now().Add(5 * time.Minute),
-		Confidence:   0.7,
-		Reasoning:    "System metrics analysis",
-		ExpectedLoad: prediction.CPUUsage,
+func (s *Storage) StoreMLPrediction(prediction *types.Prediction) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_ml_prediction")
+
+	record, err := predictionRecord(prediction)
+	if err != nil {
+		return err
 	}
 
 	result := s.db.Create(record)
@@ -224,75 +536,166 @@ func (s *Storage) StoreMLPrediction(prediction *types.SystemMetrics) error {
 		return fmt.Errorf("failed to store ML prediction: %v", result.Error)
 	}
 
+	prediction.ID = record.ID
 	return nil
 }
 
-// GetJobStatistics retrieves statistics for a specific job
-func (s *Storage) GetJobStatistics(jobName string) (map[string]interface{}, error) {
-	var totalCount int64
-	var successCount int64
-	var failureCount int64
-	var avgDuration float64
+// StoreMLPredictions stores a batch of ML predictions in a single insert
+// and sets their IDs
+func (s *Storage) StoreMLPredictions(predictions []*types.Prediction) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_ml_predictions")
 
-	// Get total executions
-	if err := s.db.Model(&JobExecutionRecord{}).Where("job_name = ?", jobName).Count(&totalCount).Error; err != nil {
-		return nil, fmt.Errorf("failed to count total executions: %v", err)
+	if len(predictions) == 0 {
+		return nil
 	}
 
-	// Get successful executions
-	if err := s.db.Model(&JobExecutionRecord{}).Where("job_name = ? AND status = ?", jobName, "completed").Count(&successCount).Error; err != nil {
-		return nil, fmt.Errorf("failed to count successful executions: %v", err)
+	records := make([]*MLPredictionRecord, len(predictions))
+	for i, prediction := range predictions {
+		record, err := predictionRecord(prediction)
+		if err != nil {
+			return err
+		}
+		records[i] = record
 	}
 
-	// Get failed executions
-	if err := s.db.Model(&JobExecutionRecord{}).Where("job_name = ? AND status = ?", jobName, "failed").Count(&failureCount).Error; err != nil {
-		return nil, fmt.Errorf("failed to count failed executions: %v", err)
+	if err := s.db.Create(&records).Error; err != nil {
+		return fmt.Errorf("failed to store ML predictions: %v", err)
 	}
 
-	// Get average duration
-	if err := s.db.Model(&JobExecutionRecord{}).Where("job_name = ? AND status = ?", jobName, "completed").Select("AVG(duration)").Scan(&avgDuration).Error; err != nil {
-		return nil, fmt.Errorf("failed to get average duration: %v", err)
+	for i, record := range records {
+		predictions[i].ID = record.ID
 	}
+	return nil
+}
 
-	successRate := 0.0
-	if totalCount > 0 {
-		successRate = float64(successCount) / float64(totalCount) * 100
+// predictionRecord converts a prediction into its database record
+func predictionRecord(prediction *types.Prediction) (*MLPredictionRecord, error) {
+	var explanation string
+	if prediction.Explanation != nil {
+		data, err := json.Marshal(prediction.Explanation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode prediction explanation: %v", err)
+		}
+		explanation = string(data)
 	}
 
-	return map[string]interface{}{
-		"total_executions": totalCount,
-		"successful":       successCount,
-		"failed":           failureCount,
-		"success_rate":     successRate,
-		"avg_duration":     avgDuration,
+	return &MLPredictionRecord{
+		JobName:      prediction.JobName,
+		PredictedAt:  prediction.PredictedAt,
+		OptimalTime:  prediction.OptimalTime,
+		Confidence:   prediction.Confidence,
+		Reasoning:    prediction.Reasoning,
+		ExpectedLoad: prediction.ExpectedLoad,
+		Method:       prediction.Method,
+		Explanation:  explanation,
 	}, nil
 }
 
-// CleanupOldRecords removes old records to prevent database bloat
-func (s *Storage) CleanupOldRecords(olderThan time.Duration) error {
-	cutoff := time.Now().Add(-olderThan)
+// StoreAuditEntry stores an audit entry
+func (s *Storage) StoreAuditEntry(entry *types.AuditEntry) error {
+	defer queryDuration.ObserveSince(time.Now(), "store_audit_entry")
+
+	record := &AuditRecord{
+		Timestamp: entry.Timestamp,
+		Principal: entry.Principal,
+		SourceIP:  entry.SourceIP,
+		Action:    entry.Action,
+		Target:    entry.Target,
+		Payload:   entry.Payload,
+	}
 
-	// Clean up old job executions
-	if err := s.db.Where("created_at < ?", cutoff).Delete(&JobExecutionRecord{}).Error; err != nil {
-		return fmt.Errorf("failed to cleanup old job executions: %v", err)
+	result := s.db.Create(record)
+	if result.Error != nil {
+		return fmt.Errorf("failed to store audit entry: %v", result.Error)
 	}
 
-	// Clean up old system metrics
-	if err := s.db.Where("created_at < ?", cutoff).Delete(&SystemMetricsRecord{}).Error; err != nil {
-		return fmt.Errorf("failed to cleanup old system metrics: %v", err)
+	entry.ID = record.ID
+	return nil
+}
+
+// GetAuditEntries retrieves audit entries matching the given filter
+func (s *Storage) GetAuditEntries(filter AuditFilter) ([]*types.AuditEntry, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_audit_entries")
+
+	var records []AuditRecord
+
+	query := s.db.Order("timestamp DESC")
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Principal != "" {
+		query = query.Where("principal = ?", filter.Principal)
+	}
+	if filter.Target != "" {
+		query = query.Where("target = ?", filter.Target)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("timestamp >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("timestamp <= ?", filter.Until)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
 	}
 
-	// Clean up old ML predictions
-	if err := s.db.Where("created_at < ?", cutoff).Delete(&MLPredictionRecord{}).Error; err != nil {
-		return fmt.Errorf("failed to cleanup old ML predictions: %v", err)
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve audit entries: %v", err)
 	}
 
-	logrus.Infof("Cleaned up records older than %v", olderThan)
-	return nil
+	entries := make([]*types.AuditEntry, len(records))
+	for i, record := range records {
+		entries[i] = &types.AuditEntry{
+			ID:        record.ID,
+			Timestamp: record.Timestamp,
+			Principal: record.Principal,
+			SourceIP:  record.SourceIP,
+			Action:    record.Action,
+			Target:    record.Target,
+			Payload:   record.Payload,
+		}
+	}
+
+	return entries, nil
+}
+
+// CleanupOldRecords removes records older than olderThan. The audit log is
+// kept and system metrics are governed by the retention tiers instead.
+func (s *Storage) CleanupOldRecords(olderThan time.Duration) error {
+	return s.deleteExpired(time.Now(), config.CleanupConfig{
+		Executions:  olderThan,
+		Predictions: olderThan,
+		Forecasts:   olderThan,
+		Adjustments: olderThan,
+		Anomalies:   olderThan,
+	})
 }
 
-// Close closes the database connection
+// Ping verifies that the database is reachable
+func (s *Storage) Ping() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Ping()
+}
+
+// Close writes the buffered system metrics and closes the database
+// connection
 func (s *Storage) Close() error {
+	if s.metricsWriter != nil {
+		s.metricsWriter.close()
+	}
+
+	for _, reader := range s.readers {
+		if reader.Config == s.db.Config {
+			continue // The main database
+		}
+		if sqlDB, err := reader.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
+
 	sqlDB, err := s.db.DB()
 	if err != nil {
 		return err
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/makalin/arcron/internal/types"
+)
+
+// GetTimeline retrieves the executions of all jobs that ran, or were
+// waiting to run, during [start, end], oldest first and at most limit of
+// them. An execution is queued when its record is first stored, so retries
+// wait through their backoff.
+func (s *Storage) GetTimeline(start, end time.Time, limit int) (*types.Timeline, error) {
+	defer queryDuration.ObserveSince(time.Now(), "get_timeline")
+
+	var records []JobExecutionRecord
+	err := s.reader().Where("start_time <= ? AND (end_time >= ? OR status IN ?)",
+		end, start, []string{string(types.StatusPending), string(types.StatusRunning)}).
+		Order("start_time ASC, id ASC").Limit(limit + 1).Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timeline: %v", err)
+	}
+
+	timeline := &types.Timeline{Start: start, End: end, Intervals: []types.TimelineInterval{}}
+	if len(records) > limit {
+		records = records[:limit]
+		timeline.Truncated = true
+	}
+
+	now := time.Now()
+	for _, record := range records {
+		// Executions stored before queue waits were recorded count as
+		// queued when they were first stored
+		queuedAt := record.CreatedAt
+		if record.QueuedAt != nil {
+			queuedAt = *record.QueuedAt
+		}
+		interval := types.TimelineInterval{
+			ExecutionID: record.ID,
+			JobName:     record.JobName,
+			Attempt:     record.Attempt,
+			Status:      types.JobStatus(record.Status),
+			QueuedAt:    queuedAt,
+			Overlaps:    []string{},
+		}
+		switch interval.Status {
+		case types.StatusPending:
+			interval.QueueWait = max(now.Sub(queuedAt).Seconds(), 0)
+		case types.StatusRunning:
+			interval.Start, interval.End, interval.Running = record.StartTime, now, true
+		default:
+			interval.Start, interval.End = record.StartTime, record.EndTime
+		}
+		if !interval.Start.IsZero() && record.StartTime.After(queuedAt) {
+			interval.QueueWait = record.StartTime.Sub(queuedAt).Seconds()
+		}
+		timeline.Intervals = append(timeline.Intervals, interval)
+	}
+
+	timeline.MaxConcurrency = markOverlaps(timeline.Intervals)
+	return timeline, nil
+}
+
+// markOverlaps records which started intervals run at the same time and
+// returns the highest number running at once. Intervals that only touch
+// do not overlap.
+func markOverlaps(intervals []types.TimelineInterval) int {
+	var started []int
+	for i := range intervals {
+		if !intervals[i].Start.IsZero() {
+			started = append(started, i)
+		}
+	}
+	sort.SliceStable(started, func(a, b int) bool {
+		return intervals[started[a]].Start.Before(intervals[started[b]].Start)
+	})
+
+	maxConcurrency := 0
+	for a, i := range started {
+		// Intervals running when this one starts, itself included
+		concurrency := 1
+		for _, j := range started[:a] {
+			if intervals[j].End.After(intervals[i].Start) {
+				concurrency++
+			}
+		}
+		maxConcurrency = max(maxConcurrency, concurrency)
+
+		for _, j := range started[a+1:] {
+			if !intervals[j].Start.Before(intervals[i].End) {
+				break
+			}
+			intervals[i].Overlaps = append(intervals[i].Overlaps, intervals[j].ExecutionID)
+			intervals[j].Overlaps = append(intervals[j].Overlaps, intervals[i].ExecutionID)
+		}
+	}
+	return maxConcurrency
+}
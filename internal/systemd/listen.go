@@ -0,0 +1,58 @@
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd socket
+// activation
+const listenFDsStart = 3
+
+// Listener returns the listening socket passed by systemd socket
+// activation (a .socket unit): the only one, or the one named name in
+// FileDescriptorName= if there are several. It returns nil if arcron was
+// not socket activated, so the caller listens itself. The environment is
+// cleared so child processes do not take the sockets for theirs.
+func Listener(name string) (net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+
+	// A single socket is used whatever its name
+	index := 0
+	if count > 1 {
+		index = -1
+		names := os.Getenv("LISTEN_FDNAMES")
+		for i, fdName := range strings.Split(names, ":") {
+			if fdName == name && i < count {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("systemd passed %d sockets but none named %s (got %q)", count, name, names)
+		}
+	}
+
+	file := os.NewFile(uintptr(listenFDsStart+index), name)
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use the socket passed by systemd: %v", err)
+	}
+	return listener, nil
+}
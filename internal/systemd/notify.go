@@ -0,0 +1,88 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Notify sends a state to the service manager, e.g. READY=1, if arcron
+// runs as a Type=notify systemd service, and does nothing otherwise
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// Sockets in the abstract namespace are given with a leading @
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to the systemd notify socket: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to notify systemd: %v", err)
+	}
+	return nil
+}
+
+// Ready tells systemd that arcron finished starting up
+func Ready() error {
+	return Notify("READY=1")
+}
+
+// Stopping tells systemd that arcron is shutting down
+func Stopping() error {
+	return Notify("STOPPING=1")
+}
+
+// WatchdogInterval returns how often systemd expects a watchdog ping, or
+// zero if the service has no watchdog (WatchdogSec=) or it is meant for
+// another process
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// RunWatchdog pings the systemd watchdog twice per watchdog interval while
+// check passes, until the context is done. A failing or hanging check
+// stops the pings, so systemd restarts arcron (Restart=on-watchdog or
+// on-failure). Without a watchdog it returns right away.
+func RunWatchdog(ctx context.Context, check func() error) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := check(); err != nil {
+				logrus.Warnf("Withholding systemd watchdog ping: %v", err)
+				continue
+			}
+			if err := Notify("WATCHDOG=1"); err != nil {
+				logrus.Errorf("Failed to ping systemd watchdog: %v", err)
+			}
+		}
+	}
+}
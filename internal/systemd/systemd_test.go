@@ -0,0 +1,104 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// listenNotify listens on a notify socket set in NOTIFY_SOCKET
+func listenNotify(t *testing.T) *net.UnixConn {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+func receive(t *testing.T, conn *net.UnixConn, timeout time.Duration) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return ""
+	}
+	return string(buf[:n])
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Ready(); err != nil {
+		t.Errorf("Ready() outside systemd error = %v", err)
+	}
+
+	conn := listenNotify(t)
+	if err := Ready(); err != nil {
+		t.Fatalf("Ready() error = %v", err)
+	}
+	if got := receive(t, conn, time.Second); got != "READY=1" {
+		t.Errorf("notified %q, want READY=1", got)
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "")
+	if interval := WatchdogInterval(); interval != 0 {
+		t.Errorf("WatchdogInterval() without watchdog = %v", interval)
+	}
+	t.Setenv("WATCHDOG_USEC", "40000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if interval := WatchdogInterval(); interval != 0 {
+		t.Errorf("WatchdogInterval() for another process = %v", interval)
+	}
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	if interval := WatchdogInterval(); interval != 40*time.Millisecond {
+		t.Fatalf("WatchdogInterval() = %v, want 40ms", interval)
+	}
+
+	conn := listenNotify(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	healthy := make(chan bool, 1)
+	healthy <- false
+	go RunWatchdog(ctx, func() error {
+		select {
+		case ok := <-healthy:
+			if !ok {
+				return errors.New("storage: database unreachable")
+			}
+		default:
+		}
+		return nil
+	})
+
+	// The first tick fails its check and is skipped
+	start := time.Now()
+	if got := receive(t, conn, time.Second); got != "WATCHDOG=1" {
+		t.Fatalf("notified %q, want WATCHDOG=1", got)
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("first ping after %v, want the failed check skipped", elapsed)
+	}
+}
+
+func TestListenerWithoutActivation(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "1")
+	listener, err := Listener("api")
+	if listener != nil || err != nil {
+		t.Errorf("Listener() without activation = %v, %v, want nil", listener, err)
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("LISTEN_FDS left in the environment")
+	}
+}
@@ -0,0 +1,414 @@
+// Package taskscheduler imports Windows Task Scheduler task definitions,
+// as exported by schtasks /query /xml or the Task Scheduler console, into
+// arcron jobs.
+package taskscheduler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/makalin/arcron/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// task is the part of a task definition the importer reads
+type task struct {
+	RegistrationInfo struct {
+		URI         string `xml:"URI"`
+		Description string `xml:"Description"`
+	} `xml:"RegistrationInfo"`
+	Triggers struct {
+		Calendar []calendarTrigger `xml:"CalendarTrigger"`
+		Time     []timeTrigger     `xml:"TimeTrigger"`
+		Boot     []struct{}        `xml:"BootTrigger"`
+		Logon    []struct{}        `xml:"LogonTrigger"`
+		Idle     []struct{}        `xml:"IdleTrigger"`
+		Event    []struct{}        `xml:"EventTrigger"`
+	} `xml:"Triggers"`
+	Settings struct {
+		Enabled            *bool  `xml:"Enabled"`
+		ExecutionTimeLimit string `xml:"ExecutionTimeLimit"`
+		RestartOnFailure   struct {
+			Count int `xml:"Count"`
+		} `xml:"RestartOnFailure"`
+	} `xml:"Settings"`
+	Actions struct {
+		Exec []struct {
+			Command          string `xml:"Command"`
+			Arguments        string `xml:"Arguments"`
+			WorkingDirectory string `xml:"WorkingDirectory"`
+		} `xml:"Exec"`
+	} `xml:"Actions"`
+}
+
+type repetition struct {
+	Interval string `xml:"Interval"`
+}
+
+type timeTrigger struct {
+	StartBoundary string     `xml:"StartBoundary"`
+	Enabled       *bool      `xml:"Enabled"`
+	Repetition    repetition `xml:"Repetition"`
+}
+
+type calendarTrigger struct {
+	StartBoundary string     `xml:"StartBoundary"`
+	Enabled       *bool      `xml:"Enabled"`
+	Repetition    repetition `xml:"Repetition"`
+	ScheduleByDay *struct {
+		DaysInterval int `xml:"DaysInterval"`
+	} `xml:"ScheduleByDay"`
+	ScheduleByWeek *struct {
+		WeeksInterval int      `xml:"WeeksInterval"`
+		DaysOfWeek    elements `xml:"DaysOfWeek"`
+	} `xml:"ScheduleByWeek"`
+	ScheduleByMonth *struct {
+		DaysOfMonth struct {
+			Days []string `xml:"Day"`
+		} `xml:"DaysOfMonth"`
+		Months elements `xml:"Months"`
+	} `xml:"ScheduleByMonth"`
+	ScheduleByMonthDayOfWeek *struct{} `xml:"ScheduleByMonthDayOfWeek"`
+}
+
+// elements collects the names of empty child elements, e.g. <Monday />
+type elements struct {
+	Names []xml.Name `xml:",any"`
+}
+
+func (e elements) list(values map[string]string) ([]string, error) {
+	var list []string
+	for _, name := range e.Names {
+		value, ok := values[name.Local]
+		if !ok {
+			return nil, fmt.Errorf("unknown %s", name.Local)
+		}
+		list = append(list, value)
+	}
+	return list, nil
+}
+
+var weekdays = map[string]string{
+	"Sunday": "SUN", "Monday": "MON", "Tuesday": "TUE", "Wednesday": "WED",
+	"Thursday": "THU", "Friday": "FRI", "Saturday": "SAT",
+}
+
+var months = map[string]string{
+	"January": "1", "February": "2", "March": "3", "April": "4", "May": "5", "June": "6",
+	"July": "7", "August": "8", "September": "9", "October": "10", "November": "11", "December": "12",
+}
+
+// Result is the outcome of an import: the jobs, and warnings about task
+// settings that could not be carried over exactly
+type Result struct {
+	Jobs     []config.JobConfig
+	Warnings []string
+}
+
+func (r *Result) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// ImportFiles imports task definition files, or directories of them (every
+// .xml file, and files without extension as in C:\Windows\System32\Tasks)
+func ImportFiles(paths ...string) (*Result, error) {
+	result := &Result{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{path}
+		if info.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read task directory: %v", err)
+			}
+			files = nil
+			for _, entry := range entries {
+				if ext := filepath.Ext(entry.Name()); !entry.IsDir() && (ext == "" || strings.EqualFold(ext, ".xml")) {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read task: %v", err)
+			}
+			name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+			if err := result.importTask(data, name); err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Import imports a single task definition. The task is named after its
+// URI, or after name if it has none.
+func Import(r io.Reader, name string) (*Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read task: %v", err)
+	}
+	result := &Result{}
+	if err := result.importTask(data, name); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importTask adds the jobs of a task definition: one job per supported
+// trigger
+func (r *Result) importTask(data []byte, name string) error {
+	var t task
+	decoder := xml.NewDecoder(bytes.NewReader(decodeUTF16(data)))
+	// The content is UTF-8 once decoded, whatever the declaration says
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err := decoder.Decode(&t); err != nil {
+		return fmt.Errorf("failed to parse task definition: %v", err)
+	}
+
+	if uri := t.RegistrationInfo.URI; uri != "" {
+		name = uri
+	}
+	name = jobName(name)
+	if t.Settings.Enabled != nil && !*t.Settings.Enabled {
+		r.warnf("%s: task is disabled, skipped", name)
+		return nil
+	}
+	if len(t.Actions.Exec) == 0 {
+		r.warnf("%s: task runs no program (only Exec actions are imported), skipped", name)
+		return nil
+	}
+
+	job := config.JobConfig{Name: name, Type: "imported"}
+	action := t.Actions.Exec[0]
+	if len(t.Actions.Exec) > 1 {
+		r.warnf("%s: only the first of %d actions is imported", name, len(t.Actions.Exec))
+	}
+	job.Command = strings.TrimSpace(strings.Trim(action.Command, `"`) + " " + action.Arguments)
+	if strings.ContainsAny(strings.Trim(action.Command, `"`), " \t") {
+		r.warnf("%s: the program path %q contains spaces; wrap the command in a script", name, action.Command)
+	}
+	if action.WorkingDirectory != "" {
+		r.warnf("%s: the working directory %s is not carried over", name, action.WorkingDirectory)
+	}
+	if limit := t.Settings.ExecutionTimeLimit; limit != "" && limit != "PT0S" {
+		timeout, err := parseDuration(limit)
+		if err != nil {
+			r.warnf("%s: execution time limit: %v", name, err)
+		} else {
+			job.Timeout = timeout
+		}
+	}
+	job.Retries = t.Settings.RestartOnFailure.Count
+	if t.RegistrationInfo.Description != "" {
+		job.Labels = map[string]string{"description": t.RegistrationInfo.Description}
+	}
+
+	var schedules []string
+	for _, trigger := range t.Triggers.Calendar {
+		if trigger.Enabled != nil && !*trigger.Enabled {
+			continue
+		}
+		schedule, err := trigger.schedule()
+		if err != nil {
+			r.warnf("%s: calendar trigger skipped: %v", name, err)
+			continue
+		}
+		if trigger.Repetition.Interval != "" {
+			r.warnf("%s: the trigger repeats every %s; import it as an @every schedule by hand", name, trigger.Repetition.Interval)
+		}
+		schedules = append(schedules, schedule)
+	}
+	for _, trigger := range t.Triggers.Time {
+		if trigger.Enabled != nil && !*trigger.Enabled {
+			continue
+		}
+		schedule, err := trigger.schedule()
+		if err != nil {
+			r.warnf("%s: time trigger skipped: %v", name, err)
+			continue
+		}
+		schedules = append(schedules, schedule)
+	}
+	if len(t.Triggers.Boot) > 0 {
+		schedules = append(schedules, "@reboot")
+	}
+	for _, unsupported := range []struct {
+		kind  string
+		count int
+	}{{"logon", len(t.Triggers.Logon)}, {"idle", len(t.Triggers.Idle)}, {"event", len(t.Triggers.Event)}} {
+		if unsupported.count > 0 {
+			r.warnf("%s: %s triggers have no arcron equivalent, skipped", name, unsupported.kind)
+		}
+	}
+	if len(schedules) == 0 {
+		r.warnf("%s: no trigger could be imported, skipped", name)
+		return nil
+	}
+
+	for i, schedule := range schedules {
+		imported := job
+		imported.Schedule = schedule
+		if i > 0 {
+			imported.Name = fmt.Sprintf("%s-%d", name, i+1)
+		}
+		r.Jobs = append(r.Jobs, imported)
+	}
+	return nil
+}
+
+// schedule converts a calendar trigger to a cron expression with a
+// leading seconds field, at the time of day of its start boundary
+func (t calendarTrigger) schedule() (string, error) {
+	start, err := parseBoundary(t.StartBoundary)
+	if err != nil {
+		return "", err
+	}
+	at := fmt.Sprintf("%d %d %d", start.Second(), start.Minute(), start.Hour())
+
+	switch {
+	case t.ScheduleByDay != nil:
+		if interval := t.ScheduleByDay.DaysInterval; interval > 1 {
+			return fmt.Sprintf("%s */%d * *", at, interval), nil
+		}
+		return at + " * * *", nil
+	case t.ScheduleByWeek != nil:
+		if t.ScheduleByWeek.WeeksInterval > 1 {
+			return "", fmt.Errorf("runs every %d weeks, which cron cannot express", t.ScheduleByWeek.WeeksInterval)
+		}
+		days, err := t.ScheduleByWeek.DaysOfWeek.list(weekdays)
+		if err != nil || len(days) == 0 {
+			return "", fmt.Errorf("invalid days of week")
+		}
+		return fmt.Sprintf("%s * * %s", at, strings.Join(days, ",")), nil
+	case t.ScheduleByMonth != nil:
+		days := t.ScheduleByMonth.DaysOfMonth.Days
+		for _, day := range days {
+			if _, err := strconv.Atoi(day); err != nil {
+				return "", fmt.Errorf("day of month %q is not supported", day)
+			}
+		}
+		if len(days) == 0 {
+			return "", fmt.Errorf("no days of month")
+		}
+		monthList, err := t.ScheduleByMonth.Months.list(months)
+		if err != nil {
+			return "", fmt.Errorf("invalid months")
+		}
+		monthField := "*"
+		if len(monthList) > 0 && len(monthList) < 12 {
+			monthField = strings.Join(monthList, ",")
+		}
+		return fmt.Sprintf("%s %s %s *", at, strings.Join(days, ","), monthField), nil
+	case t.ScheduleByMonthDayOfWeek != nil:
+		return "", fmt.Errorf("weekday-of-month schedules are not supported")
+	}
+	return "", fmt.Errorf("unknown calendar schedule")
+}
+
+// schedule converts a time trigger to a single run, or to an interval if
+// it repeats
+func (t timeTrigger) schedule() (string, error) {
+	if interval := t.Repetition.Interval; interval != "" {
+		every, err := parseDuration(interval)
+		if err != nil {
+			return "", err
+		}
+		return "@every " + every.String(), nil
+	}
+	start, err := parseBoundary(t.StartBoundary)
+	if err != nil {
+		return "", err
+	}
+	return "@once " + start.Format(time.RFC3339), nil
+}
+
+// parseBoundary parses a trigger start boundary, in local time unless it
+// gives a zone
+func parseBoundary(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("no start boundary")
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04:05", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start boundary %q", value)
+	}
+	return t, nil
+}
+
+// isoDuration matches the ISO 8601 durations of task definitions, without
+// years and months
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration parses an ISO 8601 duration such as PT1H30M or P3D
+func parseDuration(value string) (time.Duration, error) {
+	match := isoDuration.FindStringSubmatch(value)
+	if match == nil || value == "P" || value == "PT" {
+		return 0, fmt.Errorf("unsupported duration %q", value)
+	}
+	var duration time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if match[i+1] != "" {
+			n, _ := strconv.Atoi(match[i+1])
+			duration += time.Duration(n) * unit
+		}
+	}
+	return duration, nil
+}
+
+// unsafeNameChars are the characters of task paths replaced in job names
+var unsafeNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// jobName turns a task path such as \Backups\Nightly DB into a job name
+// such as backups-nightly-db
+func jobName(uri string) string {
+	return strings.Trim(unsafeNameChars.ReplaceAllString(strings.ToLower(uri), "-"), "-")
+}
+
+// decodeUTF16 converts UTF-16 task definitions, as Windows exports them,
+// to UTF-8; other data is returned as is
+func decodeUTF16(data []byte) []byte {
+	var order func([]byte) uint16
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		order = func(b []byte) uint16 { return uint16(b[0]) | uint16(b[1])<<8 }
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		order = func(b []byte) uint16 { return uint16(b[1]) | uint16(b[0])<<8 }
+	default:
+		return bytes.TrimPrefix(data, []byte{0xef, 0xbb, 0xbf})
+	}
+
+	units := make([]uint16, 0, len(data)/2)
+	for i := 2; i+1 < len(data); i += 2 {
+		units = append(units, order(data[i:i+2]))
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// WriteJobsFile writes the imported jobs as a YAML jobs file, to review and
+// drop into the jobs directory (scheduler.jobs_dir)
+func (r *Result) WriteJobsFile(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string][]config.JobConfig{"jobs": r.Jobs}); err != nil {
+		return fmt.Errorf("failed to write jobs file: %v", err)
+	}
+	return encoder.Close()
+}
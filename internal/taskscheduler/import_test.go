@@ -0,0 +1,157 @@
+package taskscheduler
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/makalin/arcron/internal/config"
+)
+
+const weeklyTask = `<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>
+    <URI>\Backups\Nightly DB</URI>
+  </RegistrationInfo>
+  <Triggers>
+    <CalendarTrigger>
+      <StartBoundary>2024-01-01T02:30:00</StartBoundary>
+      <ScheduleByWeek>
+        <WeeksInterval>1</WeeksInterval>
+        <DaysOfWeek><Monday /><Friday /></DaysOfWeek>
+      </ScheduleByWeek>
+    </CalendarTrigger>
+    <BootTrigger />
+    <LogonTrigger />
+  </Triggers>
+  <Settings>
+    <ExecutionTimeLimit>PT1H30M</ExecutionTimeLimit>
+    <RestartOnFailure><Interval>PT5M</Interval><Count>3</Count></RestartOnFailure>
+  </Settings>
+  <Actions Context="Author">
+    <Exec>
+      <Command>C:\Tools\backup.exe</Command>
+      <Arguments>--full</Arguments>
+    </Exec>
+  </Actions>
+</Task>`
+
+// utf16LE encodes a task definition the way Windows exports it
+func utf16LE(s string) []byte {
+	data := []byte{0xff, 0xfe}
+	for _, unit := range utf16.Encode([]rune(s)) {
+		data = append(data, byte(unit), byte(unit>>8))
+	}
+	return data
+}
+
+func TestImportWeeklyTask(t *testing.T) {
+	result, err := Import(bytes.NewReader(utf16LE(weeklyTask)), "ignored")
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if len(result.Jobs) != 2 {
+		t.Fatalf("jobs = %+v, want one per supported trigger", result.Jobs)
+	}
+
+	job := result.Jobs[0]
+	if job.Name != "backups-nightly-db" || job.Schedule != "0 30 2 * * MON,FRI" {
+		t.Errorf("job = %s %q, want backups-nightly-db at 0 30 2 * * MON,FRI", job.Name, job.Schedule)
+	}
+	if job.Command != `C:\Tools\backup.exe --full` || job.Timeout != 90*time.Minute || job.Retries != 3 {
+		t.Errorf("job = %q timeout %v retries %d", job.Command, job.Timeout, job.Retries)
+	}
+	if boot := result.Jobs[1]; boot.Name != "backups-nightly-db-2" || boot.Schedule != "@reboot" {
+		t.Errorf("boot job = %s %q", boot.Name, boot.Schedule)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "logon") {
+		t.Errorf("warnings = %v, want the logon trigger reported", result.Warnings)
+	}
+}
+
+func TestImportSchedules(t *testing.T) {
+	tests := []struct {
+		name    string
+		trigger string
+		want    string
+	}{
+		{"daily", `<CalendarTrigger><StartBoundary>2024-01-01T06:00:00</StartBoundary><ScheduleByDay><DaysInterval>1</DaysInterval></ScheduleByDay></CalendarTrigger>`, "0 0 6 * * *"},
+		{"every other day", `<CalendarTrigger><StartBoundary>2024-01-01T06:00:00</StartBoundary><ScheduleByDay><DaysInterval>2</DaysInterval></ScheduleByDay></CalendarTrigger>`, "0 0 6 */2 * *"},
+		{"monthly", `<CalendarTrigger><StartBoundary>2024-01-01T00:15:00</StartBoundary><ScheduleByMonth><DaysOfMonth><Day>1</Day><Day>15</Day></DaysOfMonth><Months><January /><July /></Months></ScheduleByMonth></CalendarTrigger>`, "0 15 0 1,15 1,7 *"},
+		{"repeating", `<TimeTrigger><StartBoundary>2024-01-01T00:00:00</StartBoundary><Repetition><Interval>PT15M</Interval></Repetition></TimeTrigger>`, "@every 15m0s"},
+		{"once", `<TimeTrigger><StartBoundary>2030-05-01T08:00:00Z</StartBoundary></TimeTrigger>`, "@once 2030-05-01T08:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xml := `<Task><Triggers>` + tt.trigger + `</Triggers><Actions><Exec><Command>report.cmd</Command></Exec></Actions></Task>`
+			result, err := Import(strings.NewReader(xml), "Report")
+			if err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			if len(result.Jobs) != 1 || result.Jobs[0].Schedule != tt.want {
+				t.Fatalf("jobs = %+v, warnings = %v, want schedule %q", result.Jobs, result.Warnings, tt.want)
+			}
+			if result.Jobs[0].Name != "report" {
+				t.Errorf("name = %s, want the given name", result.Jobs[0].Name)
+			}
+		})
+	}
+}
+
+func TestImportSkipsUnsupportedTasks(t *testing.T) {
+	tests := map[string]string{
+		"disabled":   `<Task><Settings><Enabled>false</Enabled></Settings><Triggers><BootTrigger /></Triggers><Actions><Exec><Command>a.exe</Command></Exec></Actions></Task>`,
+		"no program": `<Task><Triggers><BootTrigger /></Triggers><Actions><SendEmail /></Actions></Task>`,
+		"last day":   `<Task><Triggers><CalendarTrigger><StartBoundary>2024-01-01T00:00:00</StartBoundary><ScheduleByMonth><DaysOfMonth><Day>Last</Day></DaysOfMonth></ScheduleByMonth></CalendarTrigger></Triggers><Actions><Exec><Command>a.exe</Command></Exec></Actions></Task>`,
+	}
+
+	for name, xml := range tests {
+		result, err := Import(strings.NewReader(xml), name)
+		if err != nil {
+			t.Fatalf("%s: Import() error = %v", name, err)
+		}
+		if len(result.Jobs) != 0 || len(result.Warnings) == 0 {
+			t.Errorf("%s: jobs = %+v, warnings = %v, want a skipped task with a warning", name, result.Jobs, result.Warnings)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	for value, want := range map[string]time.Duration{"PT30S": 30 * time.Second, "P1DT2H": 26 * time.Hour, "PT72H": 72 * time.Hour} {
+		if got, err := parseDuration(value); err != nil || got != want {
+			t.Errorf("parseDuration(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+	if _, err := parseDuration("P1Y"); err == nil {
+		t.Error("parseDuration(P1Y) succeeded, want years rejected")
+	}
+}
+
+func TestWriteJobsFileLoads(t *testing.T) {
+	result, err := Import(bytes.NewReader(utf16LE(weeklyTask)), "ignored")
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	dir := t.TempDir()
+	file, err := os.Create(filepath.Join(dir, "imported.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := result.WriteJobsFile(file); err != nil {
+		t.Fatalf("WriteJobsFile() error = %v", err)
+	}
+	file.Close()
+
+	jobs, err := config.LoadJobsDir(dir, nil, true)
+	if err != nil {
+		t.Fatalf("LoadJobsDir() error = %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].Schedule != result.Jobs[0].Schedule || jobs[0].Timeout != 90*time.Minute {
+		t.Errorf("loaded jobs = %+v, want the imported ones", jobs)
+	}
+}
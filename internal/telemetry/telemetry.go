@@ -0,0 +1,280 @@
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the default histogram buckets in seconds
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metric is implemented by all metric types held by the registry
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry holds arcron's internal metrics
+type Registry struct {
+	mutex   sync.RWMutex
+	metrics map[string]metric
+	order   []string
+}
+
+// defaultRegistry is the registry used by the package-level constructors
+var defaultRegistry = NewRegistry()
+
+// NewRegistry creates a new, empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]metric),
+	}
+}
+
+// register adds a metric, replacing any previous metric of the same name
+func (r *Registry) register(name string, m metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.metrics[name]; !exists {
+		r.order = append(r.order, name)
+	}
+	r.metrics[name] = m
+}
+
+// WritePrometheus writes all metrics in the Prometheus text format
+func (r *Registry) WritePrometheus(w io.Writer) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, name := range r.order {
+		r.metrics[name].write(w)
+	}
+}
+
+// WritePrometheus writes all metrics of the default registry
+func WritePrometheus(w io.Writer) {
+	defaultRegistry.WritePrometheus(w)
+}
+
+// Counter is a monotonically increasing value partitioned by labels
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter creates a counter in the default registry
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+	}
+	defaultRegistry.register(name, c)
+	return c
+}
+
+// Inc increments the counter for the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta to the counter for the given label values
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	key := formatLabels(c.labels, labelValues)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.values[key] += delta
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", c.name, c.help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", c.name)
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %g\n", c.name, key, c.values[key])
+	}
+}
+
+// Gauge is a value that can go up and down partitioned by labels
+type Gauge struct {
+	name   string
+	help   string
+	labels []string
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+// NewGauge creates a gauge in the default registry
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+	}
+	defaultRegistry.register(name, g)
+	return g
+}
+
+// Set sets the gauge for the given label values
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	key := formatLabels(g.labels, labelValues)
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.values[key] = value
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", g.name, g.help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", g.name)
+	for _, key := range sortedKeys(g.values) {
+		fmt.Fprintf(w, "%s%s %g\n", g.name, key, g.values[key])
+	}
+}
+
+// GaugeFunc is a gauge whose value is read on every scrape
+type GaugeFunc struct {
+	name  string
+	help  string
+	value func() float64
+}
+
+// NewGaugeFunc creates a gauge in the default registry
+func NewGaugeFunc(name, help string, value func() float64) *GaugeFunc {
+	g := &GaugeFunc{
+		name:  name,
+		help:  help,
+		value: value,
+	}
+	defaultRegistry.register(name, g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", g.name, g.help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", g.name)
+	fmt.Fprintf(w, "%s %g\n", g.name, g.value())
+}
+
+// Histogram samples observations into buckets partitioned by labels
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mutex   sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram creates a histogram with DefaultBuckets in the default registry
+func NewHistogram(name, help string, labels ...string) *Histogram {
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: DefaultBuckets,
+		values:  make(map[string]*histogramValue),
+	}
+	defaultRegistry.register(name, h)
+	return h
+}
+
+// Observe records a single observation for the given label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := formatLabels(h.labels, labelValues)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			v.counts[i]++
+		}
+	}
+	v.sum += value
+	v.count++
+}
+
+// ObserveSince records the time elapsed since start in seconds
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", h.name, h.help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+	for _, key := range sortedKeys(h.values) {
+		v := h.values[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLabel(key, "le", fmt.Sprintf("%g", bound)), v.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, withLabel(key, "le", "+Inf"), v.count)
+		fmt.Fprintf(w, "%s_sum%s %g\n", h.name, key, v.sum)
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, key, v.count)
+	}
+}
+
+// formatLabels renders label names and values as {a="x",b="y"}
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = fmt.Sprintf("%s=%q", name, value)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// withLabel appends an extra label to an already formatted label set
+func withLabel(key, name, value string) string {
+	pair := fmt.Sprintf("%s=%q", name, value)
+	if key == "" {
+		return "{" + pair + "}"
+	}
+	return strings.TrimSuffix(key, "}") + "," + pair + "}"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
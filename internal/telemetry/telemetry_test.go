@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCounterWritePrometheus(t *testing.T) {
+	c := &Counter{name: "test_total", help: "Test counter", labels: []string{"channel", "result"}, values: map[string]float64{}}
+	c.Inc("slack", "success")
+	c.Inc("slack", "success")
+	c.Inc("email", "failure")
+
+	var buf bytes.Buffer
+	c.write(&buf)
+	output := buf.String()
+
+	if !strings.Contains(output, "# TYPE test_total counter") {
+		t.Errorf("Expected counter type line, got:\n%s", output)
+	}
+	if !strings.Contains(output, `test_total{channel="slack",result="success"} 2`) {
+		t.Errorf("Expected slack success count of 2, got:\n%s", output)
+	}
+	if !strings.Contains(output, `test_total{channel="email",result="failure"} 1`) {
+		t.Errorf("Expected email failure count of 1, got:\n%s", output)
+	}
+}
+
+func TestHistogramWritePrometheus(t *testing.T) {
+	h := &Histogram{name: "test_seconds", help: "Test histogram", buckets: []float64{0.1, 1}, values: map[string]*histogramValue{}}
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+
+	var buf bytes.Buffer
+	h.write(&buf)
+	output := buf.String()
+
+	expected := []string{
+		`test_seconds_bucket{le="0.1"} 1`,
+		`test_seconds_bucket{le="1"} 2`,
+		`test_seconds_bucket{le="+Inf"} 3`,
+		`test_seconds_sum 5.55`,
+		`test_seconds_count 3`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected line %q, got:\n%s", line, output)
+		}
+	}
+}
+
+func TestGaugeWritePrometheus(t *testing.T) {
+	g := &Gauge{name: "test_value", help: "Test gauge", labels: []string{"job"}, values: map[string]float64{}}
+	g.Set(10, "etl")
+	g.Set(3, "etl")
+	g.Set(1.5, "backup")
+
+	var buf bytes.Buffer
+	g.write(&buf)
+	output := buf.String()
+
+	if !strings.Contains(output, "# TYPE test_value gauge") {
+		t.Errorf("Expected gauge type line, got:\n%s", output)
+	}
+	if !strings.Contains(output, `test_value{job="etl"} 3`) {
+		t.Errorf("Expected the last etl value of 3, got:\n%s", output)
+	}
+	if !strings.Contains(output, `test_value{job="backup"} 1.5`) {
+		t.Errorf("Expected backup value of 1.5, got:\n%s", output)
+	}
+}
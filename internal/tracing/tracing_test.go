@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/makalin/arcron/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// restoreGlobals puts the no-op provider and propagator back once the
+// test ends
+func restoreGlobals(t *testing.T) {
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+}
+
+func TestSetupDisabled(t *testing.T) {
+	restoreGlobals(t)
+
+	shutdown, err := Setup(context.Background(), config.TracingConfig{})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	_, span := Start(context.Background(), "job")
+	End(span, nil)
+	if span.SpanContext().IsValid() {
+		t.Error("span recorded with tracing disabled")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown() error = %v", err)
+	}
+}
+
+func TestSetupExportsSpans(t *testing.T) {
+	restoreGlobals(t)
+
+	var exports atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/v1/traces" {
+			exports.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	shutdown, err := Setup(context.Background(), config.TracingConfig{
+		Enabled:     true,
+		Endpoint:    strings.TrimPrefix(collector.URL, "http://"),
+		Insecure:    true,
+		ServiceName: "arcron",
+		SampleRatio: 1,
+	})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	ctx, span := Start(context.Background(), "job")
+	if !span.SpanContext().IsValid() {
+		t.Fatal("span not recorded with tracing enabled")
+	}
+	env := EnvironmentFor(ctx)
+	if !strings.Contains(env["TRACEPARENT"], span.SpanContext().TraceID().String()) {
+		t.Errorf("TRACEPARENT = %q, want the trace %s", env["TRACEPARENT"], span.SpanContext().TraceID())
+	}
+	End(span, nil)
+
+	// Shutting down flushes the batched span to the collector
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+	if exports.Load() == 0 {
+		t.Error("no spans exported to the collector")
+	}
+}
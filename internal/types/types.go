@@ -15,29 +15,156 @@ const (
 	StatusRetrying  JobStatus = "retrying"
 )
 
+// FailureReason classifies why an execution failed
+type FailureReason string
+
+// Failure reasons of executions
+const (
+	// FailureStart means the command could not be started, e.g. because
+	// it does not exist or is not executable
+	FailureStart FailureReason = "start_failed"
+	// FailureExitCode means the command exited with a non-zero code
+	FailureExitCode FailureReason = "exit_code"
+	// FailureTimeout means the command was killed at the job timeout
+	FailureTimeout FailureReason = "timeout"
+	// FailureSignal means the command was killed by a signal from outside
+	FailureSignal FailureReason = "signal"
+	// FailureCancelled means arcron stopped before or while the attempt ran
+	FailureCancelled FailureReason = "cancelled"
+	// FailurePolicy means the security policy rejected the command
+	FailurePolicy FailureReason = "policy"
+)
+
 // JobExecution represents a single job execution
 type JobExecution struct {
-	ID          string    `json:"id"`
-	JobName     string    `json:"job_name"`
-	StartTime   time.Time `json:"start_time"`
-	EndTime     time.Time `json:"end_time"`
-	Duration    float64   `json:"duration"`
-	Status      JobStatus `json:"status"`
-	ExitCode    int       `json:"exit_code"`
-	Output      string    `json:"output"`
-	Error       string    `json:"error"`
-	RetryCount  int       `json:"retry_count"`
-	Environment string    `json:"environment"`
+	ID        string    `json:"id"`
+	JobName   string    `json:"job_name"`
+	Namespace string    `json:"namespace,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Duration  float64   `json:"duration"`
+	Status    JobStatus `json:"status"`
+	ExitCode  int       `json:"exit_code"`
+	Output    string    `json:"output"`
+	Error     string    `json:"error"`
+	// FailureReason classifies why a failed attempt failed
+	FailureReason FailureReason `json:"failure_reason,omitempty"`
+	RetryCount    int           `json:"retry_count"`
+	Environment   string        `json:"environment"`
+	// Attempt numbers the attempts of a run from 1; retries link to the
+	// first attempt by ParentExecutionID
+	Attempt           int    `json:"attempt"`
+	ParentExecutionID string `json:"parent_execution_id,omitempty"`
+	// CorrelationID is shared by the attempts of a run and the log lines
+	// about it
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// QueuedAt is when the attempt was due to run; concurrency limits and
+	// resource gates may hold it back until StartTime. QueueWait is that
+	// delay in seconds, once the attempt has started or given up waiting.
+	QueuedAt  time.Time `json:"queued_at"`
+	QueueWait float64   `json:"queue_wait"`
+	// StartMetrics and EndMetrics are the system metrics when the attempt
+	// started and ended, if any were collected
+	StartMetrics *MetricsSnapshot `json:"start_metrics,omitempty"`
+	EndMetrics   *MetricsSnapshot `json:"end_metrics,omitempty"`
+	// OutputMetrics are the values the output metric rules of the job
+	// extracted from the output, by metric name
+	OutputMetrics map[string]float64 `json:"output_metrics,omitempty"`
+}
+
+// MetricsSnapshot is the state of the system at one point of an
+// execution, taken from the latest collected metrics
+type MetricsSnapshot struct {
+	Timestamp      time.Time `json:"timestamp"`
+	CPUUsage       float64   `json:"cpu_usage"`
+	MemoryUsage    float64   `json:"memory_usage"`
+	DiskIOBytes    uint64    `json:"disk_io_bytes"`
+	NetworkIOBytes uint64    `json:"network_io_bytes"`
+	Load1          float64   `json:"load_1"`
+	GPUUsage       float64   `json:"gpu_usage"`
+	CPUTemperature float64   `json:"cpu_temperature"`
+}
+
+// NewMetricsSnapshot takes a snapshot of collected metrics
+func NewMetricsSnapshot(metrics *SystemMetrics) *MetricsSnapshot {
+	snapshot := &MetricsSnapshot{
+		Timestamp:      metrics.Timestamp,
+		CPUUsage:       metrics.CPUUsage,
+		MemoryUsage:    metrics.MemoryUsage,
+		DiskIOBytes:    metrics.DiskIO.ReadBytes + metrics.DiskIO.WriteBytes,
+		NetworkIOBytes: metrics.NetworkIO.BytesSent + metrics.NetworkIO.BytesRecv,
+		Load1:          metrics.LoadAvg.Load1,
+		GPUUsage:       metrics.GPUUsage(),
+	}
+	if metrics.Temperatures != nil {
+		snapshot.CPUTemperature = metrics.Temperatures.CPU
+	}
+	return snapshot
+}
+
+// SystemMetrics returns the metrics the snapshot was taken of, as far as
+// the snapshot keeps them: disk and network I/O are totals and GPU usage
+// is that of the busiest GPU
+func (s *MetricsSnapshot) SystemMetrics() *SystemMetrics {
+	return &SystemMetrics{
+		Timestamp:    s.Timestamp,
+		CPUUsage:     s.CPUUsage,
+		MemoryUsage:  s.MemoryUsage,
+		DiskIO:       DiskIO{ReadBytes: s.DiskIOBytes},
+		NetworkIO:    NetworkIO{BytesRecv: s.NetworkIOBytes},
+		LoadAvg:      LoadAvg{Load1: s.Load1},
+		GPUs:         []GPUMetrics{{Utilization: s.GPUUsage}},
+		Temperatures: &Temperatures{CPU: s.CPUTemperature},
+	}
 }
 
 // SystemMetrics represents collected system metrics
 type SystemMetrics struct {
-	Timestamp   time.Time `json:"timestamp"`
-	CPUUsage    float64   `json:"cpu_usage"`
-	MemoryUsage float64   `json:"memory_usage"`
-	DiskIO      DiskIO    `json:"disk_io"`
-	NetworkIO   NetworkIO `json:"network_io"`
-	LoadAvg     LoadAvg   `json:"load_avg"`
+	Timestamp    time.Time            `json:"timestamp"`
+	CPUUsage     float64              `json:"cpu_usage"`
+	MemoryUsage  float64              `json:"memory_usage"`
+	DiskIO       DiskIO               `json:"disk_io"`
+	NetworkIO    NetworkIO            `json:"network_io"`
+	LoadAvg      LoadAvg              `json:"load_avg"`
+	Disks        map[string]DiskIO    `json:"disks,omitempty"`
+	Interfaces   map[string]NetworkIO `json:"interfaces,omitempty"`
+	GPUs         []GPUMetrics         `json:"gpus,omitempty"`
+	Temperatures *Temperatures        `json:"temperatures,omitempty"`
+}
+
+// Clone returns a deep copy of the metrics, sharing no maps or slices
+// with them
+func (m *SystemMetrics) Clone() *SystemMetrics {
+	clone := *m
+	if m.Disks != nil {
+		clone.Disks = make(map[string]DiskIO, len(m.Disks))
+		for name, disk := range m.Disks {
+			clone.Disks[name] = disk
+		}
+	}
+	if m.Interfaces != nil {
+		clone.Interfaces = make(map[string]NetworkIO, len(m.Interfaces))
+		for name, iface := range m.Interfaces {
+			clone.Interfaces[name] = iface
+		}
+	}
+	clone.GPUs = append([]GPUMetrics(nil), m.GPUs...)
+	if m.Temperatures != nil {
+		temperatures := *m.Temperatures
+		clone.Temperatures = &temperatures
+	}
+	return &clone
+}
+
+// GPUUsage returns the highest utilization across all GPUs
+func (m *SystemMetrics) GPUUsage() float64 {
+	var usage float64
+	for _, gpu := range m.GPUs {
+		if gpu.Utilization > usage {
+			usage = gpu.Utilization
+		}
+	}
+	return usage
 }
 
 // DiskIO represents disk I/O metrics
@@ -51,11 +178,31 @@ type DiskIO struct {
 
 // NetworkIO represents network I/O metrics
 type NetworkIO struct {
-	BytesSent    uint64 `json:"bytes_sent"`
-	BytesRecv    uint64 `json:"bytes_recv"`
-	PacketsSent  uint64 `json:"packets_sent"`
-	PacketsRecv  uint64 `json:"packets_recv"`
-	Connections  int    `json:"connections"`
+	BytesSent   uint64 `json:"bytes_sent"`
+	BytesRecv   uint64 `json:"bytes_recv"`
+	PacketsSent uint64 `json:"packets_sent"`
+	PacketsRecv uint64 `json:"packets_recv"`
+	Connections int    `json:"connections"`
+	// Utilization is throughput as a percentage of link speed
+	Utilization float64 `json:"utilization"`
+}
+
+// GPUMetrics represents utilization of a single GPU
+type GPUMetrics struct {
+	Index             int     `json:"index"`
+	Name              string  `json:"name"`
+	Utilization       float64 `json:"utilization"`
+	MemoryUtilization float64 `json:"memory_utilization"`
+	MemoryUsed        uint64  `json:"memory_used"`
+	MemoryTotal       uint64  `json:"memory_total"`
+	Temperature       float64 `json:"temperature"`
+}
+
+// Temperatures represents the hottest CPU and disk sensor readings
+// in degrees Celsius
+type Temperatures struct {
+	CPU  float64 `json:"cpu"`
+	Disk float64 `json:"disk"`
 }
 
 // LoadAvg represents system load average
@@ -65,11 +212,500 @@ type LoadAvg struct {
 	Load15 float64 `json:"load_15"`
 }
 
-// Prediction represents a job execution prediction
+// Prediction represents a job execution prediction. ExpectedLoad is the
+// expected combined CPU and memory load at the optimal time and Method is
+// "model" or "heuristics".
 type Prediction struct {
-	JobName       string    `json:"job_name"`
-	OptimalTime   time.Time `json:"optimal_time"`
-	Confidence    float64   `json:"confidence"`
-	Reasoning     string    `json:"reasoning"`
-	ExpectedLoad  float64   `json:"expected_load"`
+	ID           uint         `json:"id,omitempty"`
+	JobName      string       `json:"job_name"`
+	PredictedAt  time.Time    `json:"predicted_at"`
+	OptimalTime  time.Time    `json:"optimal_time"`
+	Confidence   float64      `json:"confidence"`
+	Reasoning    string       `json:"reasoning"`
+	ExpectedLoad float64      `json:"expected_load"`
+	Method       string       `json:"method"`
+	Explanation  *Explanation `json:"explanation,omitempty"`
+}
+
+// Explanation records what drove a prediction: the feature contributions
+// of a linear model, or the heuristic branch taken and its inputs
+type Explanation struct {
+	Backend        string                `json:"backend,omitempty"`
+	Contributions  []FeatureContribution `json:"contributions,omitempty"`
+	Branch         string                `json:"branch,omitempty"`
+	Inputs         map[string]float64    `json:"inputs,omitempty"`
+	FallbackReason string                `json:"fallback_reason,omitempty"`
+	Forecast       *ForecastAdjustment   `json:"forecast,omitempty"`
+	Collision      *CollisionAvoidance   `json:"collision,omitempty"`
+}
+
+// CollisionAvoidance records a prediction moved so a heavy job does not
+// overlap another heavy job planned before it
+type CollisionAvoidance struct {
+	With string    `json:"with"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// FeatureContribution is how much a feature moved a linear model's output
+type FeatureContribution struct {
+	Feature      string  `json:"feature"`
+	Value        float64 `json:"value"`
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"`
+}
+
+// ForecastAdjustment records a prediction moved to a quieter forecast
+// interval
+type ForecastAdjustment struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	FromLoad float64   `json:"from_load"`
+	ToLoad   float64   `json:"to_load"`
+}
+
+// PredictionOutcome is a prediction evaluated against the system load and
+// job outcome observed at the predicted time
+type PredictionOutcome struct {
+	Prediction
+	ActualLoad  float64   `json:"actual_load"`
+	AbsError    float64   `json:"abs_error"`
+	JobStatus   string    `json:"job_status,omitempty"`
+	EvaluatedAt time.Time `json:"evaluated_at"`
+}
+
+// PredictionAccuracy summarizes evaluated predictions of a job by method.
+// Accuracy is the fraction of predictions within the error tolerance and
+// SuccessRate the fraction of predicted runs that completed.
+type PredictionAccuracy struct {
+	JobName     string  `json:"job_name"`
+	Method      string  `json:"method"`
+	Evaluated   int     `json:"evaluated"`
+	MAE         float64 `json:"mae"`
+	Accuracy    float64 `json:"accuracy"`
+	SuccessRate float64 `json:"success_rate"`
+}
+
+// ForecastPoint is the predicted combined CPU and memory load at a point
+// in time, with a 95% confidence band
+type ForecastPoint struct {
+	ID    uint      `json:"id,omitempty"`
+	Time  time.Time `json:"time"`
+	Load  float64   `json:"load"`
+	Lower float64   `json:"lower"`
+	Upper float64   `json:"upper"`
+}
+
+// LoadForecast is a load forecast over a horizon at a fixed interval
+type LoadForecast struct {
+	GeneratedAt time.Time       `json:"generated_at"`
+	Points      []ForecastPoint `json:"points"`
+}
+
+// ForecastOutcome is a forecast point evaluated against the measured load
+type ForecastOutcome struct {
+	ForecastPoint
+	ActualLoad  float64   `json:"actual_load"`
+	EvaluatedAt time.Time `json:"evaluated_at"`
+}
+
+// ForecastAccuracy summarizes evaluated forecast points. Coverage is the
+// fraction of measured loads within the confidence band.
+type ForecastAccuracy struct {
+	Evaluated int     `json:"evaluated"`
+	MAE       float64 `json:"mae"`
+	Coverage  float64 `json:"coverage"`
+}
+
+// Anomaly represents a metric value that deviates from its baseline
+type Anomaly struct {
+	ID          uint      `json:"id,omitempty"`
+	Type        string    `json:"type"`     // "cpu", "memory", "disk", "network"
+	Severity    string    `json:"severity"` // "low", "medium", "high", "critical"
+	Value       float64   `json:"value"`
+	Expected    float64   `json:"expected"`
+	Deviation   float64   `json:"deviation"` // Number of standard deviations
+	Timestamp   time.Time `json:"timestamp"`
+	Description string    `json:"description"`
+}
+
+// AnomalyBaseline represents the rolling statistics of a metric, for a
+// single hour of day or for all hours (Hour -1)
+type AnomalyBaseline struct {
+	Metric    string    `json:"metric"`
+	Hour      int       `json:"hour"`
+	Count     int       `json:"count"`
+	Mean      float64   `json:"mean"`
+	Variance  float64   `json:"variance"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AuditEntry represents a single mutating action recorded for compliance
+type AuditEntry struct {
+	ID        uint      `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Principal string    `json:"principal"`
+	SourceIP  string    `json:"source_ip"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	Payload   string    `json:"payload,omitempty"`
+}
+
+// MaintenanceState is the global pause of the scheduler. While paused no
+// job is started; running jobs finish.
+type MaintenanceState struct {
+	Paused   bool      `json:"paused"`
+	Reason   string    `json:"reason,omitempty"`
+	PausedBy string    `json:"paused_by,omitempty"`
+	Since    time.Time `json:"since,omitempty"`
+}
+
+// Outcomes of a schedule adjustment
+const (
+	AdjustmentPending   = "pending"
+	AdjustmentCompleted = "completed"
+	AdjustmentFailed    = "failed"
+	AdjustmentCancelled = "cancelled"
+	AdjustmentAdvised   = "advised"
+	AdjustmentSkipped   = "skipped"
+)
+
+// ScheduleAdjustment records a run moved by the intelligent scheduler, or
+// advised to be moved in dry-run mode, and what came of the moved run
+type ScheduleAdjustment struct {
+	ID           uint      `json:"id"`
+	JobName      string    `json:"job_name"`
+	AdjustedAt   time.Time `json:"adjusted_at"`
+	OriginalTime time.Time `json:"original_time"`
+	NewTime      time.Time `json:"new_time"`
+	PredictionID uint      `json:"prediction_id,omitempty"`
+	Method       string    `json:"method"`
+	Confidence   float64   `json:"confidence"`
+	ExpectedLoad float64   `json:"expected_load"`
+	Reasoning    string    `json:"reasoning"`
+	DryRun       bool      `json:"dry_run"`
+	Outcome      string    `json:"outcome"`
+	// SkipReason is why a skipped adjustment was not made
+	SkipReason string `json:"skip_reason,omitempty"`
+	// StaggeredAfter is the heavy job the run was moved after so the two
+	// would not overlap
+	StaggeredAfter string     `json:"staggered_after,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+}
+
+// DurationPercentiles are nearest-rank percentiles of the durations of
+// completed executions, in seconds
+type DurationPercentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// QueueWaitStatistics summarize how long started executions waited
+// between being queued and starting, in seconds
+type QueueWaitStatistics struct {
+	Executions int64   `json:"executions"`
+	Avg        float64 `json:"avg"`
+	P95        float64 `json:"p95"`
+	Max        float64 `json:"max"`
+}
+
+// DurationTrendPoint summarizes the completed executions of a job started
+// on one day (UTC)
+type DurationTrendPoint struct {
+	Day         string  `json:"day"`
+	Executions  int64   `json:"executions"`
+	AvgDuration float64 `json:"avg_duration"`
+	MaxDuration float64 `json:"max_duration"`
+}
+
+// FailureStreaks counts consecutive failed executions: the streak still
+// going on and the longest one
+type FailureStreaks struct {
+	Current int64 `json:"current"`
+	Longest int64 `json:"longest"`
+}
+
+// HeatmapCell summarizes the executions started in one hour of one day of
+// the week (UTC, Sunday is 0)
+type HeatmapCell struct {
+	Weekday     int     `json:"weekday"`
+	Hour        int     `json:"hour"`
+	Executions  int64   `json:"executions"`
+	Successful  int64   `json:"successful"`
+	SuccessRate float64 `json:"success_rate"`
+}
+
+// StartConditions averages the system metrics at the start of the
+// executions of a job with one status
+type StartConditions struct {
+	Status         string  `json:"status"`
+	Executions     int64   `json:"executions"`
+	AvgCPUUsage    float64 `json:"avg_cpu_usage"`
+	MaxCPUUsage    float64 `json:"max_cpu_usage"`
+	AvgMemoryUsage float64 `json:"avg_memory_usage"`
+	AvgLoad1       float64 `json:"avg_load_1"`
+}
+
+// JobImpact is the system load attributable to one execution: the load
+// while it ran less the load before and after it, in percentage points.
+// Score combines the CPU and memory deltas.
+type JobImpact struct {
+	ID          uint      `json:"id"`
+	ExecutionID string    `json:"execution_id"`
+	JobName     string    `json:"job_name"`
+	Namespace   string    `json:"namespace,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	CPUDelta    float64   `json:"cpu_delta"`
+	MemoryDelta float64   `json:"memory_delta"`
+	LoadDelta   float64   `json:"load_delta"`
+	Score       float64   `json:"score"`
+	// Samples is how many metrics samples were taken while it ran
+	Samples int `json:"samples"`
+}
+
+// ImpactSummary averages the impact of the scored executions of a job
+type ImpactSummary struct {
+	JobName        string  `json:"job_name"`
+	Executions     int64   `json:"executions"`
+	AvgScore       float64 `json:"avg_score"`
+	MaxScore       float64 `json:"max_score"`
+	AvgCPUDelta    float64 `json:"avg_cpu_delta"`
+	AvgMemoryDelta float64 `json:"avg_memory_delta"`
+	AvgLoadDelta   float64 `json:"avg_load_delta"`
+}
+
+// JobRanking summarizes the recent executions of a job for fleet-wide
+// rankings
+type JobRanking struct {
+	JobName     string  `json:"job_name"`
+	Executions  int64   `json:"executions"`
+	Failures    int64   `json:"failures"`
+	FailureRate float64 `json:"failure_rate"`
+	AvgDuration float64 `json:"avg_duration"`
+}
+
+// FleetStatistics summarizes the executions of all jobs since a time, with
+// the slowest and most failing jobs
+type FleetStatistics struct {
+	Since       time.Time    `json:"since"`
+	Executions  int64        `json:"executions"`
+	Failed      int64        `json:"failed"`
+	FailureRate float64      `json:"failure_rate"`
+	Slowest     []JobRanking `json:"slowest"`
+	MostFailing []JobRanking `json:"most_failing"`
+}
+
+// TimelineInterval is an execution on the timeline, queued at QueuedAt and
+// running from Start until End. Executions that are still running end now;
+// pending executions have not started and have no interval yet.
+type TimelineInterval struct {
+	ExecutionID string    `json:"execution_id"`
+	JobName     string    `json:"job_name"`
+	Attempt     int       `json:"attempt"`
+	Status      JobStatus `json:"status"`
+	QueuedAt    time.Time `json:"queued_at"`
+	Start       time.Time `json:"start,omitempty"`
+	End         time.Time `json:"end,omitempty"`
+	Running     bool      `json:"running"`
+	// QueueWait is the time in seconds between queuing and starting, or
+	// until now for pending executions
+	QueueWait float64 `json:"queue_wait"`
+	// Overlaps lists the executions running at the same time
+	Overlaps []string `json:"overlaps"`
+}
+
+// Timeline holds the executions overlapping a time range as intervals for
+// Gantt views, with the highest number of executions running at once
+type Timeline struct {
+	Start          time.Time          `json:"start"`
+	End            time.Time          `json:"end"`
+	Intervals      []TimelineInterval `json:"intervals"`
+	MaxConcurrency int                `json:"max_concurrency"`
+	Truncated      bool               `json:"truncated"`
+}
+
+// Statuses of an alert in the alert history
+const (
+	AlertStatusSent     = "sent"
+	AlertStatusRetrying = "retrying"
+	AlertStatusFailed   = "failed"
+	AlertStatusSilenced = "silenced"
+	// AlertStatusSuppressed is a repeated failure alert of a job whose
+	// failure is acknowledged and not resolved yet
+	AlertStatusSuppressed = "suppressed"
+)
+
+// AlertEntry is an alert in the alert history, with the channels it was
+// sent to or the silence that suppressed it
+type AlertEntry struct {
+	ID          uint              `json:"id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Level       string            `json:"level"`
+	Title       string            `json:"title"`
+	Message     string            `json:"message"`
+	JobName     string            `json:"job_name,omitempty"`
+	Namespace   string            `json:"namespace,omitempty"`
+	ExecutionID string            `json:"execution_id,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Status      string            `json:"status"`
+	Channels    []string          `json:"channels,omitempty"`
+	SilenceID   uint              `json:"silence_id,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	// Event is the job event alerted on, empty for system alerts
+	Event string `json:"event,omitempty"`
+	// ResolvesID is the failure alert a recovered alert resolves, and
+	// ResolvedAt when a failure alert was resolved by a successful run
+	ResolvesID uint       `json:"resolves_id,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	// AcknowledgedAt and AcknowledgedBy record the operator who took
+	// ownership of the alert
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string     `json:"acknowledged_by,omitempty"`
+}
+
+// States of a silence
+const (
+	SilenceStatePending = "pending"
+	SilenceStateActive  = "active"
+	SilenceStateExpired = "expired"
+)
+
+// Silence suppresses the alerts it matches between StartsAt and EndsAt.
+// Empty matchers match every alert.
+type Silence struct {
+	ID        uint              `json:"id"`
+	JobName   string            `json:"job_name,omitempty"`
+	Level     string            `json:"level,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Comment   string            `json:"comment,omitempty"`
+	CreatedBy string            `json:"created_by"`
+	StartsAt  time.Time         `json:"starts_at"`
+	EndsAt    time.Time         `json:"ends_at"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// State returns whether the silence is pending, active or expired at a
+// time
+func (s *Silence) State(now time.Time) string {
+	switch {
+	case now.Before(s.StartsAt):
+		return SilenceStatePending
+	case now.Before(s.EndsAt):
+		return SilenceStateActive
+	default:
+		return SilenceStateExpired
+	}
+}
+
+// DeadLetter is an alert delivery to a channel that failed every attempt.
+// Alert holds the alert as JSON so it can be redriven.
+type DeadLetter struct {
+	ID           uint       `json:"id"`
+	AlertID      uint       `json:"alert_id,omitempty"`
+	Channel      string     `json:"channel"`
+	SlackChannel string     `json:"slack_channel,omitempty"`
+	Alert        string     `json:"alert"`
+	Attempts     int        `json:"attempts"`
+	LastError    string     `json:"last_error"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RedrivenAt   *time.Time `json:"redriven_at,omitempty"`
+}
+
+// Artifact is a file a job produced, collected after an execution
+type Artifact struct {
+	ID          uint   `json:"id"`
+	ExecutionID string `json:"execution_id"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	// Location is where the file is kept: a local path or an s3:// URL
+	Location  string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FieldChange is a configuration key of a job whose value changed. Values
+// of secrets are redacted.
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// Actions recorded by a job revision
+const (
+	RevisionCreate = "create"
+	RevisionUpdate = "update"
+	RevisionDelete = "delete"
+)
+
+// JobRevision is a version of a job, recorded whenever the job is created,
+// changed or deleted. Definition holds its settings keyed as in a jobs
+// file, with the secrets of jobs not synced through the API redacted, and
+// Diff the keys changed since the previous revision.
+type JobRevision struct {
+	ID         uint                   `json:"id"`
+	JobName    string                 `json:"job_name"`
+	Revision   int                    `json:"revision"`
+	Action     string                 `json:"action"`
+	Author     string                 `json:"author"`
+	Source     string                 `json:"source,omitempty"`
+	Definition map[string]interface{} `json:"definition,omitempty"`
+	Diff       []FieldChange          `json:"diff,omitempty"`
+	RollbackOf int                    `json:"rollback_of,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// DurationRegression is a job whose runs have become significantly slower,
+// detected by a Mann-Kendall trend test over its latest completed runs.
+// Slope is the Sen's slope of the durations in seconds per run, and
+// RunsToTimeout how many more runs at that pace until a run would time
+// out, zero if unknown.
+type DurationRegression struct {
+	ID               uint      `json:"id"`
+	JobName          string    `json:"job_name"`
+	ExecutionID      string    `json:"execution_id"`
+	DetectedAt       time.Time `json:"detected_at"`
+	Runs             int       `json:"runs"`
+	Tau              float64   `json:"tau"`
+	PValue           float64   `json:"p_value"`
+	Slope            float64   `json:"slope"`
+	BaselineDuration float64   `json:"baseline_duration"`
+	RecentDuration   float64   `json:"recent_duration"`
+	IncreasePercent  float64   `json:"increase_percent"`
+	Timeout          float64   `json:"timeout,omitempty"`
+	RunsToTimeout    int       `json:"runs_to_timeout,omitempty"`
+}
+
+// Flakiness measures how much a job alternates between failing and
+// passing over its latest runs. Score, from 0 to 1, averages the share of
+// runs that only passed through a retry and the share of consecutive runs
+// whose outcome flipped; a job that always fails is broken, not flaky.
+type Flakiness struct {
+	Score     float64 `json:"score"`
+	Runs      int     `json:"runs"`
+	Recovered int     `json:"recovered"`
+	Failed    int     `json:"failed"`
+	Flips     int     `json:"flips"`
+}
+
+// Triggers of a queued run
+const (
+	TriggerManual = "manual"
+	TriggerSmart  = "smart"
+	TriggerRetry  = "retry"
+)
+
+// QueuedRun is a run of a job waiting in the execution queue. Runs start
+// in the order of their position once NotBefore has passed.
+type QueuedRun struct {
+	ID         uint      `json:"id"`
+	JobName    string    `json:"job_name"`
+	Trigger    string    `json:"trigger"`
+	Position   int       `json:"position"`
+	NotBefore  time.Time `json:"not_before"`
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	Reason     string    `json:"reason,omitempty"`
+	// ExecutionID is the pending execution a retry continues
+	ExecutionID   string `json:"execution_id,omitempty"`
+	CorrelationID string `json:"correlation_id,omitempty"`
 }
@@ -0,0 +1,44 @@
+package version
+
+import (
+	"runtime"
+	"time"
+)
+
+// Build information, injected at build time via -ldflags
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// startTime records when the process started
+var startTime = time.Now()
+
+// Info represents build and runtime information
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// StartTime returns the time the process started
+func StartTime() time.Time {
+	return startTime
+}
+
+// Uptime returns how long the process has been running
+func Uptime() time.Duration {
+	return time.Since(startTime)
+}
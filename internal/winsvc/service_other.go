@@ -0,0 +1,26 @@
+//go:build !windows
+
+package winsvc
+
+import "context"
+
+// IsService reports whether arcron was started by the service control
+// manager, which is never outside Windows
+func IsService() (bool, error) {
+	return false, nil
+}
+
+// Run is only supported on Windows
+func Run(name string, run func(ctx context.Context) error) error {
+	return ErrUnsupported
+}
+
+// Install is only supported on Windows
+func Install(cfg Config) error {
+	return ErrUnsupported
+}
+
+// Uninstall is only supported on Windows
+func Uninstall(name string) error {
+	return ErrUnsupported
+}
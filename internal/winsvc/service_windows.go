@@ -0,0 +1,124 @@
+//go:build windows
+
+package winsvc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// IsService reports whether arcron was started by the service control
+// manager
+func IsService() (bool, error) {
+	return svc.IsWindowsService()
+}
+
+// Run runs arcron as the named service until the service control manager
+// stops it or the system shuts down, which cancels the context run gets
+func Run(name string, run func(ctx context.Context) error) error {
+	return svc.Run(name, &handler{run: run})
+}
+
+// handler runs arcron under the service control manager
+type handler struct {
+	run func(ctx context.Context) error
+}
+
+// Execute implements svc.Handler
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- h.run(ctx) }()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				logrus.Errorf("Arcron stopped: %v", err)
+				return true, 1
+			}
+			return false, 0
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				status <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+				if err := <-done; err != nil {
+					logrus.Errorf("Arcron stopped: %v", err)
+				}
+				return false, 0
+			}
+		}
+	}
+}
+
+// Install registers arcron as an automatically started service, restarted
+// by the service control manager when it fails
+func Install(cfg Config) error {
+	if cfg.Name == "" {
+		cfg.Name = DefaultName
+	}
+
+	manager, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service control manager: %v", err)
+	}
+	defer manager.Disconnect()
+
+	if existing, err := manager.OpenService(cfg.Name); err == nil {
+		existing.Close()
+		return fmt.Errorf("service %s already exists", cfg.Name)
+	}
+
+	service, err := manager.CreateService(cfg.Name, cfg.Executable, mgr.Config{
+		DisplayName: cfg.DisplayName,
+		Description: cfg.Description,
+		StartType:   mgr.StartAutomatic,
+	}, cfg.Args...)
+	if err != nil {
+		return fmt.Errorf("failed to create service %s: %v", cfg.Name, err)
+	}
+	defer service.Close()
+
+	restart := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: time.Minute},
+	}
+	if err := service.SetRecoveryActions(restart, uint32((24 * time.Hour).Seconds())); err != nil {
+		return fmt.Errorf("failed to set recovery actions of service %s: %v", cfg.Name, err)
+	}
+	return nil
+}
+
+// Uninstall removes the named service; a running service is removed once
+// it stops
+func Uninstall(name string) error {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service control manager: %v", err)
+	}
+	defer manager.Disconnect()
+
+	service, err := manager.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed: %v", name, err)
+	}
+	defer service.Close()
+
+	if err := service.Delete(); err != nil {
+		return fmt.Errorf("failed to remove service %s: %v", name, err)
+	}
+	return nil
+}
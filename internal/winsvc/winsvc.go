@@ -0,0 +1,23 @@
+// Package winsvc runs arcron as a Windows service and installs it with the
+// service control manager. On other platforms it reports ErrUnsupported;
+// use the systemd units there.
+package winsvc
+
+import "errors"
+
+// ErrUnsupported is returned outside Windows
+var ErrUnsupported = errors.New("Windows services are only supported on Windows")
+
+// DefaultName is the service name arcron is installed under by default
+const DefaultName = "arcron"
+
+// Config describes the service to install
+type Config struct {
+	Name        string
+	DisplayName string
+	Description string
+	// Executable is the path of the arcron binary, and Args the arguments
+	// the service starts it with, e.g. --config C:\arcron\arcron.yaml
+	Executable string
+	Args       []string
+}